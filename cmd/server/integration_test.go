@@ -58,7 +58,7 @@ func TestServer(t *testing.T) {
 	handlers.RegisterSyncTools(srv, adapterSync, adapterVM)
 
 	// Register resources using the MCP-go implementation
-	resources.RegisterMCPResources(srv, adapterVM, executor)
+	resources.RegisterMCPResources(srv, adapterVM, adapterSync, executor)
 
 	// We're not starting the server for real in tests
 	// Just validating initialization
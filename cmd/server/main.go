@@ -4,19 +4,31 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/audit"
+	"github.com/vagrant-mcp/server/internal/events"
 	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/expiry"
 	"github.com/vagrant-mcp/server/internal/handlers"
+	"github.com/vagrant-mcp/server/internal/hooks"
+	"github.com/vagrant-mcp/server/internal/idle"
 	"github.com/vagrant-mcp/server/internal/resources"
+	"github.com/vagrant-mcp/server/internal/schedule"
 	"github.com/vagrant-mcp/server/internal/sync"
+	"github.com/vagrant-mcp/server/internal/tracing"
+	"github.com/vagrant-mcp/server/internal/transport"
 	"github.com/vagrant-mcp/server/internal/utils"
 	"github.com/vagrant-mcp/server/internal/vm"
+	"github.com/vagrant-mcp/server/internal/webhooks"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
 )
 
 // Build-time variables injected via ldflags
@@ -80,11 +92,18 @@ func main() {
 		Str("contact", Contact).
 		Msg("Starting Vagrant MCP Server")
 
+	// Read the server mode, which limits the tools that are registered
+	mode, err := handlers.ModeFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid MCP_SERVER_MODE")
+	}
+
 	// Check if Vagrant CLI is installed
 	if err := utils.CheckVagrantInstalled(); err != nil {
 		log.Fatal().Err(err).Msg("Vagrant CLI is required to run this server")
 	}
-	log.Info().Msg("Vagrant CLI detected")
+	capabilities := vm.GlobalCapabilities.Get(context.Background())
+	log.Info().Str("version", capabilities.VagrantVersion).Interface("providers", capabilities.Providers).Msg("Vagrant CLI detected")
 
 	// Initialize VM manager, sync engine, and executor
 	vmManager, err := vm.NewManager()
@@ -106,20 +125,70 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create executor")
 	}
+	// Guest lifecycle hooks run with the executor
+	hooks.GlobalRunner.SetExecutor(executor)
+
+	// Count the VMs each session creates as the VM manager reports them
+	limiter := mcp_pkg.NewRateLimiter(mcp_pkg.LimitsFromEnv())
+	events.GlobalBus.Subscribe(func(event events.Event) {
+		if event.Type != events.VMStateChanged {
+			return
+		}
+		switch event.Data["operation"] {
+		case "create":
+			limiter.VMCreated(event.VMName)
+		case "destroy":
+			limiter.VMDestroyed(event.VMName)
+		}
+	})
 
-	// Create a new MCP server with recovery middleware
+	// Create a new MCP server with recovery middleware; the SSE transport tracks its sessions
+	// through the hooks
+	hooks := &server.Hooks{}
 	srv := server.NewMCPServer(
 		"Vagrant Development VM MCP Server",
 		Version,
 		server.WithRecovery(),
+		server.WithHooks(hooks),
+		server.WithResourceCapabilities(true, true),
+		server.WithToolHandlerMiddleware(idle.GlobalTracker.Middleware()),
+		server.WithToolHandlerMiddleware(tracing.GlobalTracer.Middleware()),
+		server.WithToolHandlerMiddleware(audit.GlobalLog.Middleware()),
+		server.WithToolHandlerMiddleware(limiter.Middleware()),
 	)
 
+	// Suspend or halt VMs no tool has used for MCP_IDLE_TIMEOUT; open shells, background
+	// processes, dev servers and tunnels keep their VMs in use
+	idle.GlobalTracker.AddBusy(handlers.BusyVMs)
+	go idle.GlobalTracker.Run(context.Background(), adapterVM, idle.DefaultCheckInterval)
+
+	// Halt or destroy VMs whose time to live has run out; modes that leave out the destructive
+	// tools only halt them
+	if mode != handlers.ModeFull {
+		expiry.GlobalReaper.DisallowDestroy()
+	}
+	go expiry.GlobalReaper.Run(context.Background(), adapterVM, expiry.DefaultCheckInterval)
+
+	// Run the scheduled tasks, kept with the VMs; tasks whose tools the mode leaves out fail
+	if err := schedule.GlobalScheduler.Open(filepath.Join(vmManager.GetBaseDir(), schedule.StateFile)); err != nil {
+		log.Warn().Err(err).Msg("Failed to load scheduled tasks; new tasks replace them")
+	}
+	go schedule.GlobalScheduler.Run(context.Background(), handlers.ScheduledTaskRunner(adapterVM, adapterSync, executor, mode), schedule.DefaultCheckInterval)
+
+	// Post events to the webhooks in MCP_WEBHOOKS_FILE
+	go webhooks.GlobalNotifier.Run(context.Background(), events.GlobalBus)
+
 	// Register all tools using the unified registry
 	handlerRegistry := handlers.NewHandlerRegistry(adapterVM, adapterSync, executor)
 	handlerRegistry.RegisterAllTools(srv)
 
+	// Leave out the tools MCP_SERVER_MODE disables
+	if err := handlerRegistry.ApplyMode(srv, mode); err != nil {
+		log.Fatal().Err(err).Str("mode", mode).Msg("Failed to apply server mode")
+	}
+
 	// Register resources using the MCP-go implementation
-	resources.RegisterMCPResources(srv, adapterVM, executor)
+	resources.RegisterMCPResources(srv, adapterVM, adapterSync, executor)
 
 	// Determine which transport to use
 	transportType = os.Getenv("MCP_TRANSPORT")
@@ -129,12 +198,15 @@ func main() {
 
 	log.Info().Str("transport", transportType).Msg("Vagrant MCP Server starting")
 
+	// Both transports answer completion and resource subscription requests through the router
+	router := transport.NewRouter(srv, resources.NewCompleter(adapterVM), resources.GlobalSubscriptions)
+
 	// Start the server with the selected transport
 	switch transportType {
 	case "stdio":
 		// Start with stdio transport
 		log.Info().Msg("Starting with STDIO transport")
-		if err := server.ServeStdio(srv); err != nil {
+		if err := transport.ServeStdio(srv, router); err != nil {
 			log.Fatal().Err(err).Msg("STDIO server error")
 		}
 	case "sse":
@@ -144,13 +216,21 @@ func main() {
 			port = "8080" // Default port
 		}
 		log.Info().Str("port", port).Msg("Starting with SSE transport")
-		sseServer := server.NewSSEServer(srv)
-		if err := sseServer.Start(":" + port); err != nil {
+		if err := transport.NewSSE(srv, hooks, router).Start(":" + port); err != nil {
 			log.Fatal().Err(err).Msg("SSE server error")
 		}
 	default:
 		log.Fatal().Str("transport", transportType).Msg("Unsupported transport type")
 	}
 
+	// Export the spans still queued
+	if err := tracing.GlobalTracer.Shutdown(context.Background()); err != nil {
+		log.Warn().Err(err).Msg("Failed to export remaining spans")
+	}
+
+	if err := audit.GlobalLog.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close audit log")
+	}
+
 	log.Info().Msg("Vagrant MCP Server shutdown complete")
 }
@@ -0,0 +1,299 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package approval parks destructive operations until a human approves them
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+// Operation kinds that can be configured to require approval
+const (
+	// OperationDestroyVM covers destroying a development VM
+	OperationDestroyVM = "destroy_vm"
+	// OperationBulkHalt covers stopping several VMs in a single call
+	OperationBulkHalt = "bulk_halt"
+	// OperationSyncDeletions covers syncs that would delete more files than the threshold
+	OperationSyncDeletions = "sync_deletions"
+)
+
+// Default gate settings
+const (
+	DefaultDeleteThreshold = 10
+	DefaultTTL             = 15 * time.Minute
+)
+
+// Common errors returned by the approval gate
+var (
+	ErrOperationNotFound = errors.New("no pending operation for token")
+	ErrOperationExpired  = errors.New("pending operation expired")
+)
+
+// Action is the parked work executed once an operation is approved
+type Action func(ctx context.Context) (*mcp.CallToolResult, error)
+
+// Operation is a destructive operation waiting for approval. Its ID identifies it to the agent;
+// its token approves it and is only given to the operator.
+type Operation struct {
+	ID        string                 `json:"id"`
+	Token     string                 `json:"-"`
+	Kind      string                 `json:"kind"`
+	Tool      string                 `json:"tool"`
+	VMName    string                 `json:"vm_name,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	action    Action
+}
+
+// Gate decides which operations need approval and holds them until approved
+type Gate struct {
+	mu              sync.Mutex
+	required        map[string]bool
+	deleteThreshold int
+	ttl             time.Duration
+	pending         map[string]*Operation
+	bus             *events.Bus
+	// tokens receives the tokens of parked operations for the operator
+	tokens io.Writer
+}
+
+// NewGate creates a new approval gate for the given operation kinds
+func NewGate(required []string, deleteThreshold int, ttl time.Duration, bus *events.Bus) *Gate {
+	if deleteThreshold < 0 {
+		deleteThreshold = DefaultDeleteThreshold
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	g := &Gate{
+		required:        make(map[string]bool),
+		deleteThreshold: deleteThreshold,
+		ttl:             ttl,
+		pending:         make(map[string]*Operation),
+		bus:             bus,
+		tokens:          os.Stderr,
+	}
+	for _, kind := range required {
+		kind = strings.TrimSpace(kind)
+		if kind == "all" {
+			g.required[OperationDestroyVM] = true
+			g.required[OperationBulkHalt] = true
+			g.required[OperationSyncDeletions] = true
+			continue
+		}
+		if kind != "" {
+			g.required[kind] = true
+		}
+	}
+	return g
+}
+
+// NewGateFromEnv creates an approval gate configured from environment variables
+func NewGateFromEnv() *Gate {
+	var required []string
+	if value := os.Getenv("MCP_APPROVAL_REQUIRED"); value != "" {
+		required = strings.Split(value, ",")
+	}
+
+	threshold := DefaultDeleteThreshold
+	if value := os.Getenv("MCP_APPROVAL_DELETE_THRESHOLD"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			threshold = parsed
+		}
+	}
+
+	ttl := DefaultTTL
+	if value := os.Getenv("MCP_APPROVAL_TTL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			ttl = parsed
+		}
+	}
+
+	gate := NewGate(required, threshold, ttl, events.GlobalBus)
+	if path := os.Getenv("MCP_APPROVAL_TOKEN_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			log.Warn().Err(err).Str("file", path).Msg("Cannot open the approval token file, writing approval tokens to stderr")
+		} else {
+			gate.SetTokenWriter(file)
+		}
+	}
+	return gate
+}
+
+// SetTokenWriter sets where the tokens of parked operations are written. Tokens are kept out of
+// tool results, resources and events, which the agent that asked for an operation can read, so
+// only someone who can read w approves it.
+func (g *Gate) SetTokenWriter(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.tokens = w
+}
+
+// Requires reports whether operations of the given kind need approval
+func (g *Gate) Requires(kind string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.required[kind]
+}
+
+// RequiresForDeletions reports whether a sync deleting count files needs approval
+func (g *Gate) RequiresForDeletions(count int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.required[OperationSyncDeletions] && count > g.deleteThreshold
+}
+
+// DeleteThreshold returns the number of deletions allowed without approval
+func (g *Gate) DeleteThreshold() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.deleteThreshold
+}
+
+// Park stores an operation until it is approved, announces the request and writes its token for
+// the operator
+func (g *Gate) Park(kind, tool, vmName string, details map[string]interface{}, action Action) *Operation {
+	now := time.Now()
+	op := &Operation{
+		ID:        newToken()[:8],
+		Token:     newToken(),
+		Kind:      kind,
+		Tool:      tool,
+		VMName:    vmName,
+		Details:   details,
+		CreatedAt: now,
+		ExpiresAt: now.Add(g.ttl),
+		action:    action,
+	}
+
+	g.mu.Lock()
+	g.pruneLocked(now)
+	g.pending[op.Token] = op
+	tokens := g.tokens
+	g.mu.Unlock()
+
+	target := op.Tool
+	if op.VMName != "" {
+		target += " of VM '" + op.VMName + "'"
+	}
+	if _, err := fmt.Fprintf(tokens, "Approval requested for %s (operation %s, expires %s). To approve it, call approve_operation with token %s\n",
+		target, op.ID, op.ExpiresAt.Format(time.RFC3339), op.Token); err != nil {
+		log.Error().Err(err).Str("id", op.ID).Msg("Failed to write approval token")
+	}
+
+	g.publish(events.ApprovalRequested, op, nil)
+	return op
+}
+
+// Approve runs the parked operation matching token
+func (g *Gate) Approve(ctx context.Context, token string) (*Operation, *mcp.CallToolResult, error) {
+	op, err := g.take(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g.publish(events.ApprovalResolved, op, map[string]interface{}{"approved": true})
+	result, err := op.action(ctx)
+	return op, result, err
+}
+
+// Reject discards the parked operation matching token
+func (g *Gate) Reject(token string) (*Operation, error) {
+	op, err := g.take(token)
+	if err != nil {
+		return nil, err
+	}
+
+	g.publish(events.ApprovalResolved, op, map[string]interface{}{"approved": false})
+	return op, nil
+}
+
+// Pending returns the operations currently waiting for approval, oldest first
+func (g *Gate) Pending() []Operation {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pruneLocked(time.Now())
+	result := make([]Operation, 0, len(g.pending))
+	for _, op := range g.pending {
+		result = append(result, *op)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// take removes and returns the pending operation for token
+func (g *Gate) take(token string) (*Operation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	op, ok := g.pending[token]
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	delete(g.pending, token)
+
+	if time.Now().After(op.ExpiresAt) {
+		return nil, ErrOperationExpired
+	}
+	return op, nil
+}
+
+// pruneLocked drops expired operations; the caller must hold g.mu
+func (g *Gate) pruneLocked(now time.Time) {
+	for token, op := range g.pending {
+		if now.After(op.ExpiresAt) {
+			delete(g.pending, token)
+		}
+	}
+}
+
+// publish emits an approval event if the gate has a bus
+func (g *Gate) publish(eventType events.Type, op *Operation, extra map[string]interface{}) {
+	if g.bus == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"id":      op.ID,
+		"kind":    op.Kind,
+		"tool":    op.Tool,
+		"details": op.Details,
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+	g.bus.Publish(eventType, op.VMName, data)
+}
+
+// newToken returns a random approval token
+func newToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Global approval gate instance
+var GlobalGate = NewGateFromEnv()
@@ -0,0 +1,138 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+func TestGate_Requires(t *testing.T) {
+	testCases := []struct {
+		name      string
+		required  []string
+		kind      string
+		deletions int
+		expected  bool
+	}{
+		{
+			name:     "kind not configured",
+			required: nil,
+			kind:     OperationDestroyVM,
+			expected: false,
+		},
+		{
+			name:     "kind configured",
+			required: []string{"destroy_vm"},
+			kind:     OperationDestroyVM,
+			expected: true,
+		},
+		{
+			name:     "all enables every kind",
+			required: []string{"all"},
+			kind:     OperationBulkHalt,
+			expected: true,
+		},
+		{
+			name:      "deletions below threshold",
+			required:  []string{"sync_deletions"},
+			kind:      OperationSyncDeletions,
+			deletions: 5,
+			expected:  false,
+		},
+		{
+			name:      "deletions above threshold",
+			required:  []string{"sync_deletions"},
+			kind:      OperationSyncDeletions,
+			deletions: 11,
+			expected:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gate := NewGate(tc.required, 10, time.Minute, nil)
+
+			var actual bool
+			if tc.kind == OperationSyncDeletions {
+				actual = gate.RequiresForDeletions(tc.deletions)
+			} else {
+				actual = gate.Requires(tc.kind)
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %v but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGate_ApproveAndReject(t *testing.T) {
+	bus := events.NewBus(10)
+	gate := NewGate([]string{"all"}, 10, time.Minute, bus)
+	var tokens bytes.Buffer
+	gate.SetTokenWriter(&tokens)
+
+	ran := false
+	op := gate.Park(OperationDestroyVM, "destroy_dev_vm", "test-vm", nil, func(ctx context.Context) (*mcp.CallToolResult, error) {
+		ran = true
+		return mcp.NewToolResultText("done"), nil
+	})
+
+	if len(gate.Pending()) != 1 {
+		t.Fatalf("Expected 1 pending operation but got %d", len(gate.Pending()))
+	}
+	// Only the operator gets the token
+	if !strings.Contains(tokens.String(), op.Token) {
+		t.Errorf("Expected the token to be written for the operator but got %q", tokens.String())
+	}
+	pending, _ := json.Marshal(gate.Pending())
+	requested, _ := json.Marshal(bus.Recent(0, "test-vm"))
+	if strings.Contains(string(pending), op.Token) || strings.Contains(string(requested), op.Token) {
+		t.Errorf("Expected the token to be kept out of pending operations and events but got %s and %s", pending, requested)
+	}
+	if _, _, err := gate.Approve(context.Background(), op.ID); err != ErrOperationNotFound {
+		t.Errorf("Expected the operation ID not to approve it but got %v", err)
+	}
+	if _, _, err := gate.Approve(context.Background(), "wrong-token"); err != ErrOperationNotFound {
+		t.Errorf("Expected error %v but got %v", ErrOperationNotFound, err)
+	}
+
+	if _, _, err := gate.Approve(context.Background(), op.Token); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !ran {
+		t.Errorf("Expected approved action to run")
+	}
+	if _, _, err := gate.Approve(context.Background(), op.Token); err != ErrOperationNotFound {
+		t.Errorf("Expected token to be single use but got %v", err)
+	}
+
+	rejected := gate.Park(OperationDestroyVM, "destroy_dev_vm", "test-vm", nil, func(ctx context.Context) (*mcp.CallToolResult, error) {
+		t.Errorf("Rejected action must not run")
+		return nil, nil
+	})
+	if _, err := gate.Reject(rejected.Token); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	if count := len(bus.Recent(0, "test-vm")); count != 4 {
+		t.Errorf("Expected 4 approval events but got %d", count)
+	}
+}
+
+func TestGate_Expired(t *testing.T) {
+	gate := NewGate([]string{"all"}, 10, time.Millisecond, nil)
+	op := gate.Park(OperationDestroyVM, "destroy_dev_vm", "test-vm", nil, func(ctx context.Context) (*mcp.CallToolResult, error) {
+		return nil, nil
+	})
+
+	time.Sleep(5 * time.Millisecond)
+	if _, _, err := gate.Approve(context.Background(), op.Token); err != ErrOperationExpired {
+		t.Errorf("Expected error %v but got %v", ErrOperationExpired, err)
+	}
+}
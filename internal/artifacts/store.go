@@ -0,0 +1,316 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package artifacts stores large blobs, such as the full output of a command, coverage reports
+// and sync reports, on disk under the VM directory so results can refer to them instead of
+// carrying them
+package artifacts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Artifact kinds
+const (
+	KindExecOutput = "exec_output"
+	KindCoverage   = "coverage"
+	KindSyncReport = "sync_report"
+)
+
+// MaxPerVM is the number of artifacts kept for each VM; committing another removes the oldest
+const MaxPerVM = 100
+
+// URIPrefix starts the URI of every artifact
+const URIPrefix = "devvm://artifacts/"
+
+// dirName is the directory holding a VM's artifacts, inside the VM directory
+const dirName = "artifacts"
+
+// idPattern matches artifact IDs
+var idPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Artifact describes a stored blob
+type Artifact struct {
+	ID        string    `json:"id"`
+	URI       string    `json:"uri"`
+	VMName    string    `json:"vm_name"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps the artifacts of the VMs under a base directory
+type Store struct {
+	baseDir string
+}
+
+// NewStore creates a store for the VMs under baseDir
+func NewStore(baseDir string) *Store {
+	return &Store{baseDir: baseDir}
+}
+
+// URI returns the resource URI of an artifact
+func URI(id string) string {
+	return URIPrefix + id
+}
+
+// dir returns the directory holding a VM's artifacts
+func (s *Store) dir(vmName string) string {
+	return filepath.Join(s.baseDir, vmName, dirName)
+}
+
+// Create starts an artifact of a VM. Write its content to the returned writer, then Commit
+// it, or Discard it when it is not needed after all.
+func (s *Store) Create(vmName, kind, name string) (*Writer, error) {
+	if vmName == "" || filepath.Base(vmName) != vmName {
+		return nil, fmt.Errorf("invalid VM name '%s'", vmName)
+	}
+	dir := s.dir(vmName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, id+".data"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifact: %w", err)
+	}
+	return &Writer{
+		file: file,
+		dir:  dir,
+		artifact: Artifact{
+			ID:        id,
+			URI:       URI(id),
+			VMName:    vmName,
+			Kind:      kind,
+			Name:      name,
+			CreatedAt: time.Now(),
+		},
+	}, nil
+}
+
+// newID returns a random artifact ID
+func newID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate artifact ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// find returns the directory holding an artifact
+func (s *Store) find(id string) (string, error) {
+	if !idPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid artifact ID '%s'", id)
+	}
+	matches, err := filepath.Glob(filepath.Join(s.baseDir, "*", dirName, id+".json"))
+	if err != nil || len(matches) == 0 {
+		return "", fmt.Errorf("artifact '%s' not found", id)
+	}
+	return filepath.Dir(matches[0]), nil
+}
+
+// List returns the artifacts of a VM, or of every VM when vmName is empty, newest first
+func (s *Store) List(vmName string) ([]Artifact, error) {
+	vmPattern := "*"
+	if vmName != "" {
+		if filepath.Base(vmName) != vmName {
+			return nil, fmt.Errorf("invalid VM name '%s'", vmName)
+		}
+		vmPattern = vmName
+	}
+	matches, err := filepath.Glob(filepath.Join(s.baseDir, vmPattern, dirName, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	list := make([]Artifact, 0, len(matches))
+	for _, match := range matches {
+		id := strings.TrimSuffix(filepath.Base(match), ".json")
+		if !idPattern.MatchString(id) {
+			continue
+		}
+		artifact, err := readMetadata(filepath.Dir(match), id)
+		if err != nil {
+			continue
+		}
+		list = append(list, artifact)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// Latest returns the newest artifact of a kind a VM got since a time
+func (s *Store) Latest(vmName, kind string, since time.Time) (Artifact, bool) {
+	list, err := s.List(vmName)
+	if err != nil {
+		return Artifact{}, false
+	}
+	for _, artifact := range list {
+		if artifact.CreatedAt.Before(since) {
+			break
+		}
+		if artifact.Kind == kind {
+			return artifact, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// Put stores the content of r as an artifact of a VM
+func (s *Store) Put(vmName, kind, name string, r io.Reader) (Artifact, error) {
+	writer, err := s.Create(vmName, kind, name)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if _, err := io.Copy(writer, r); err != nil {
+		writer.Discard()
+		return Artifact{}, fmt.Errorf("failed to read artifact content: %w", err)
+	}
+	return writer.Commit()
+}
+
+// Get returns the description of an artifact
+func (s *Store) Get(id string) (Artifact, error) {
+	dir, err := s.find(id)
+	if err != nil {
+		return Artifact{}, err
+	}
+	return readMetadata(dir, id)
+}
+
+func readMetadata(dir, id string) (Artifact, error) {
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to read artifact '%s': %w", id, err)
+	}
+	var artifact Artifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return Artifact{}, fmt.Errorf("failed to parse artifact '%s': %w", id, err)
+	}
+	return artifact, nil
+}
+
+// Open returns the description of an artifact and its content, which the caller closes
+func (s *Store) Open(id string) (Artifact, *os.File, error) {
+	dir, err := s.find(id)
+	if err != nil {
+		return Artifact{}, nil, err
+	}
+	artifact, err := readMetadata(dir, id)
+	if err != nil {
+		return Artifact{}, nil, err
+	}
+	file, err := os.Open(filepath.Join(dir, id+".data"))
+	if err != nil {
+		return Artifact{}, nil, fmt.Errorf("failed to open artifact '%s': %w", id, err)
+	}
+	return artifact, file, nil
+}
+
+// ReadRange returns the description of an artifact and up to length bytes of its content from
+// offset; a zero length reads to the end
+func (s *Store) ReadRange(id string, offset, length int64) (Artifact, []byte, error) {
+	if offset < 0 || length < 0 {
+		return Artifact{}, nil, fmt.Errorf("offset and length must not be negative")
+	}
+	artifact, file, err := s.Open(id)
+	if err != nil {
+		return Artifact{}, nil, err
+	}
+	defer file.Close()
+	if offset >= artifact.Size {
+		return artifact, []byte{}, nil
+	}
+	if length == 0 || offset+length > artifact.Size {
+		length = artifact.Size - offset
+	}
+	data := make([]byte, length)
+	n, err := file.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return Artifact{}, nil, fmt.Errorf("failed to read artifact '%s': %w", id, err)
+	}
+	return artifact, data[:n], nil
+}
+
+// Writer writes the content of a new artifact. A failed write is reported by Commit, so the
+// writer can sit behind an io.MultiWriter without failing the other writes.
+type Writer struct {
+	file     *os.File
+	dir      string
+	artifact Artifact
+	err      error
+}
+
+// Write appends p to the artifact
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err == nil {
+		var n int
+		n, w.err = w.file.Write(p)
+		w.artifact.Size += int64(n)
+	}
+	return len(p), nil
+}
+
+// Commit finishes the artifact and returns its description
+func (w *Writer) Commit() (Artifact, error) {
+	if err := w.file.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		os.Remove(w.file.Name())
+		return Artifact{}, fmt.Errorf("failed to write artifact: %w", w.err)
+	}
+	data, err := json.MarshalIndent(w.artifact, "", "  ")
+	if err != nil {
+		os.Remove(w.file.Name())
+		return Artifact{}, fmt.Errorf("failed to marshal artifact: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(w.dir, w.artifact.ID+".json"), data, 0600); err != nil {
+		os.Remove(w.file.Name())
+		return Artifact{}, fmt.Errorf("failed to write artifact: %w", err)
+	}
+	prune(w.dir, MaxPerVM)
+	return w.artifact, nil
+}
+
+// prune removes the oldest artifacts in dir until keep are left
+func prune(dir string, keep int) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(matches) <= keep {
+		return
+	}
+	list := make([]Artifact, 0, len(matches))
+	for _, match := range matches {
+		if artifact, err := readMetadata(dir, strings.TrimSuffix(filepath.Base(match), ".json")); err == nil {
+			list = append(list, artifact)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	for _, artifact := range list[:max(len(list)-keep, 0)] {
+		os.Remove(filepath.Join(dir, artifact.ID+".json"))
+		os.Remove(filepath.Join(dir, artifact.ID+".data"))
+	}
+}
+
+// Discard drops the artifact
+func (w *Writer) Discard() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
@@ -0,0 +1,152 @@
+package artifacts
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreCommit(t *testing.T) {
+	store := NewStore(t.TempDir())
+	writer, err := store.Create("dev", KindExecOutput, "stdout")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	_, _ = writer.Write([]byte("hello "))
+	_, _ = writer.Write([]byte("world"))
+	artifact, err := writer.Commit()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if artifact.Size != 11 || artifact.URI != URIPrefix+artifact.ID || artifact.VMName != "dev" {
+		t.Errorf("Expected an 11 byte artifact of dev but got %+v", artifact)
+	}
+
+	got, file, err := store.Open(artifact.ID)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	defer file.Close()
+	content, _ := io.ReadAll(file)
+	if string(content) != "hello world" || got.Kind != KindExecOutput || got.Name != "stdout" {
+		t.Errorf("Expected the committed artifact but got %+v with %q", got, content)
+	}
+}
+
+func TestStoreDiscard(t *testing.T) {
+	baseDir := t.TempDir()
+	store := NewStore(baseDir)
+	writer, err := store.Create("dev", KindExecOutput, "stderr")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	_, _ = writer.Write([]byte("unused"))
+	writer.Discard()
+	entries, _ := os.ReadDir(filepath.Join(baseDir, "dev", dirName))
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be left but got %d", len(entries))
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for _, vmName := range []string{"", "../dev", "a/b"} {
+		if _, err := store.Create(vmName, KindExecOutput, "stdout"); err == nil || !strings.Contains(err.Error(), "invalid VM name") {
+			t.Errorf("Expected an invalid VM name error for %q but got %v", vmName, err)
+		}
+	}
+
+	testCases := []struct {
+		id       string
+		expected string
+	}{
+		{"../../etc/passwd", "invalid artifact ID"},
+		{"ABCDEF0123456789", "invalid artifact ID"},
+		{"0123456789abcdef", "not found"},
+	}
+	for _, tc := range testCases {
+		if _, err := store.Get(tc.id); err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("Expected error containing %q for %s but got %v", tc.expected, tc.id, err)
+		}
+	}
+}
+
+func TestStoreListAndRange(t *testing.T) {
+	store := NewStore(t.TempDir())
+	start := time.Now()
+	first, err := store.Put("dev", KindSyncReport, "to_vm", strings.NewReader(">f+++++++++ main.go\n"))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	second, err := store.Put("dev", KindCoverage, "coverage.out", strings.NewReader("mode: set\n"))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, err := store.Put("other", KindSyncReport, "from_vm", strings.NewReader("x")); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	list, err := store.List("dev")
+	if err != nil || len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Errorf("Expected the artifacts of dev newest first but got %+v (%v)", list, err)
+	}
+	if all, _ := store.List(""); len(all) != 3 {
+		t.Errorf("Expected 3 artifacts but got %d", len(all))
+	}
+	if latest, ok := store.Latest("dev", KindSyncReport, start); !ok || latest.ID != first.ID {
+		t.Errorf("Expected the latest sync report to be %s but got %+v", first.ID, latest)
+	}
+	if _, ok := store.Latest("dev", KindSyncReport, time.Now().Add(time.Minute)); ok {
+		t.Error("Expected no sync report after now")
+	}
+
+	testCases := []struct {
+		offset   int64
+		length   int64
+		expected string
+	}{
+		{0, 0, ">f+++++++++ main.go\n"},
+		{0, 3, ">f+"},
+		{12, 4, "main"},
+		{12, 100, "main.go\n"},
+		{100, 10, ""},
+	}
+	for _, tc := range testCases {
+		_, data, err := store.ReadRange(first.ID, tc.offset, tc.length)
+		if err != nil || string(data) != tc.expected {
+			t.Errorf("Expected %q at %d+%d but got %q (%v)", tc.expected, tc.offset, tc.length, data, err)
+		}
+	}
+	if _, _, err := store.ReadRange(first.ID, -1, 0); err == nil {
+		t.Error("Expected a negative offset to fail")
+	}
+}
+
+func TestStorePrunesOldest(t *testing.T) {
+	baseDir := t.TempDir()
+	store := NewStore(baseDir)
+	var ids []string
+	for i := 0; i < MaxPerVM+2; i++ {
+		artifact, err := store.Put("dev", KindExecOutput, "stdout", strings.NewReader("output"))
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		ids = append(ids, artifact.ID)
+	}
+	list, _ := store.List("dev")
+	if len(list) != MaxPerVM {
+		t.Fatalf("Expected %d artifacts to be kept but got %d", MaxPerVM, len(list))
+	}
+	for _, id := range ids[:2] {
+		if _, err := store.Get(id); err == nil {
+			t.Errorf("Expected artifact %s to be pruned", id)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Join(baseDir, "dev", dirName))
+	if len(entries) != 2*MaxPerVM {
+		t.Errorf("Expected %d files but got %d", 2*MaxPerVM, len(entries))
+	}
+}
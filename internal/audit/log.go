@@ -0,0 +1,369 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package audit records tool calls and the commands run in guests to an append-only JSONL file
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+)
+
+// Entry types
+const (
+	TypeToolCall     = "tool_call"
+	TypeGuestCommand = "guest_command"
+)
+
+// Rotation defaults
+const (
+	DefaultMaxBytes = 10 * 1024 * 1024
+	DefaultMaxFiles = 5
+)
+
+// Redacted replaces secret values in recorded arguments and commands
+const Redacted = "[REDACTED]"
+
+// sensitiveArguments are tool arguments whose values are never recorded, along with
+// arguments named with one of them as a suffix, e.g. smb_password
+var sensitiveArguments = map[string]bool{
+	"value":       true,
+	"password":    true,
+	"token":       true,
+	"secret":      true,
+	"private_key": true,
+}
+
+// sensitiveArgument reports whether the value of a tool argument is never recorded
+func sensitiveArgument(key string) bool {
+	if sensitiveArguments[key] {
+		return true
+	}
+	for name := range sensitiveArguments {
+		if name != "value" && strings.HasSuffix(key, "_"+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GlobalLog is the audit log shared by the server
+var GlobalLog = NewLogFromEnv()
+
+// Entry is one line of the audit log
+type Entry struct {
+	Time       time.Time              `json:"time"`
+	Type       string                 `json:"type"`
+	Tool       string                 `json:"tool,omitempty"`
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	IsError    bool                   `json:"is_error,omitempty"`
+	VM         string                 `json:"vm,omitempty"`
+	Command    string                 `json:"command,omitempty"`
+	WorkingDir string                 `json:"working_dir,omitempty"`
+	EnvKeys    []string               `json:"env_keys,omitempty"`
+	ExitCode   *int                   `json:"exit_code,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// Log appends entries to a JSONL file, rotating it once it reaches maxBytes and keeping
+// maxFiles rotated files named <path>.1 (newest) to <path>.<maxFiles>
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// NewLog creates an audit log writing to path. An empty path disables it.
+func NewLog(path string, maxBytes int64, maxFiles int) *Log {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+	return &Log{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+}
+
+// NewLogFromEnv creates an audit log configured from MCP_AUDIT_LOG, the file path or "off",
+// MCP_AUDIT_MAX_BYTES and MCP_AUDIT_MAX_FILES. The default file is ~/.vagrant-mcp/audit.jsonl.
+func NewLogFromEnv() *Log {
+	path := os.Getenv("MCP_AUDIT_LOG")
+	switch path {
+	case "off":
+		path = ""
+	case "":
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(homeDir, ".vagrant-mcp", "audit.jsonl")
+		}
+	}
+	maxBytes := int64(DefaultMaxBytes)
+	if value := os.Getenv("MCP_AUDIT_MAX_BYTES"); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			maxBytes = parsed
+		} else {
+			log.Warn().Str("value", value).Msg("Ignoring invalid MCP_AUDIT_MAX_BYTES")
+		}
+	}
+	maxFiles := DefaultMaxFiles
+	if value := os.Getenv("MCP_AUDIT_MAX_FILES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			maxFiles = parsed
+		} else {
+			log.Warn().Str("value", value).Msg("Ignoring invalid MCP_AUDIT_MAX_FILES")
+		}
+	}
+	return NewLog(path, maxBytes, maxFiles)
+}
+
+// Enabled reports whether entries are recorded
+func (l *Log) Enabled() bool {
+	return l.path != ""
+}
+
+// Path returns the file the log writes to
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends an entry to the log
+func (l *Log) Record(entry Entry) error {
+	if !l.Enabled() {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.open(); err != nil {
+		return err
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// open opens the log file for appending if it is not open yet
+func (l *Log) open() error {
+	if l.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files up by one, dropping the oldest, and starts a new file
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.file = nil
+	if l.maxFiles == 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to truncate audit log: %w", err)
+		}
+		return l.open()
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", l.path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", l.path, i+1)); err != nil {
+				return fmt.Errorf("failed to rotate audit log: %w", err)
+			}
+		}
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return l.open()
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Recent returns up to limit of the newest entries of the current file, oldest first
+func (l *Log) Recent(limit int) ([]Entry, error) {
+	if !l.Enabled() {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// RecordCommand records a command run in a guest. Only the names of the environment variables
+// passed with it are kept.
+func (l *Log) RecordCommand(vmName, command, workingDir string, env map[string]string, exitCode int, duration time.Duration, err error) {
+	entry := Entry{
+		Type:       TypeGuestCommand,
+		VM:         vmName,
+		Command:    command,
+		WorkingDir: workingDir,
+		ExitCode:   &exitCode,
+		DurationMs: duration.Milliseconds(),
+	}
+	for key := range env {
+		entry.EnvKeys = append(entry.EnvKeys, key)
+	}
+	sort.Strings(entry.EnvKeys)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if recordErr := l.Record(entry); recordErr != nil {
+		log.Warn().Err(recordErr).Msg("Failed to record guest command in audit log")
+	}
+}
+
+// Middleware records every tool call with its arguments, secrets removed
+func (l *Log) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !l.Enabled() {
+				return next(ctx, request)
+			}
+			started := time.Now()
+			result, err := next(ctx, request)
+			entry := Entry{
+				Time:       started.UTC(),
+				Type:       TypeToolCall,
+				Tool:       request.Params.Name,
+				Arguments:  RedactArguments(request.GetArguments()),
+				IsError:    err != nil || (result != nil && result.IsError),
+				DurationMs: time.Since(started).Milliseconds(),
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if recordErr := l.Record(entry); recordErr != nil {
+				log.Warn().Err(recordErr).Str("tool", request.Params.Name).Msg("Failed to record tool call in audit log")
+			}
+			return result, err
+		}
+	}
+}
+
+// RedactArguments returns a copy of tool arguments with secret values replaced: secret
+// parameters, the values of environment variable maps and credentials in proxy URLs
+func RedactArguments(arguments map[string]interface{}) map[string]interface{} {
+	if arguments == nil {
+		return nil
+	}
+	redactedArgs := make(map[string]interface{}, len(arguments))
+	for key, value := range arguments {
+		switch {
+		case sensitiveArgument(key):
+			redactedArgs[key] = Redacted
+		case key == "env_vars" || key == "environment":
+			if values, ok := value.(map[string]interface{}); ok {
+				masked := make(map[string]interface{}, len(values))
+				for name := range values {
+					masked[name] = Redacted
+				}
+				redactedArgs[key] = masked
+			} else {
+				redactedArgs[key] = Redacted
+			}
+		case strings.HasSuffix(key, "_proxy"):
+			redactedArgs[key] = redactURL(value)
+		default:
+			redactedArgs[key] = value
+		}
+	}
+	return redactedArgs
+}
+
+// redactURL removes the password from a URL value
+func redactURL(value interface{}) interface{} {
+	text, ok := value.(string)
+	if !ok {
+		return value
+	}
+	parsed, err := url.Parse(text)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), "xxxxx")
+	}
+	return parsed.String()
+}
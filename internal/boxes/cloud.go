@@ -0,0 +1,252 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package boxes searches Vagrant Cloud for boxes and reads their versions and providers
+package boxes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the Vagrant Cloud API
+const DefaultBaseURL = "https://vagrantcloud.com/api/v2"
+
+// Search defaults
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+)
+
+// sizeLookups bounds the concurrent HEAD requests measuring box downloads
+const sizeLookups = 8
+
+// boxTagPattern matches box tags such as ubuntu/jammy64
+var boxTagPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// Provider is a provider build of a box version
+type Provider struct {
+	Name                string `json:"name"`
+	Architecture        string `json:"architecture,omitempty"`
+	DefaultArchitecture bool   `json:"default_architecture,omitempty"`
+	DownloadURL         string `json:"download_url,omitempty"`
+	// SizeBytes is the download size, when it was measured
+	SizeBytes int64 `json:"size_bytes,omitempty"`
+}
+
+// Version is a released version of a box
+type Version struct {
+	Version   string     `json:"version"`
+	Status    string     `json:"status,omitempty"`
+	CreatedAt string     `json:"created_at,omitempty"`
+	Providers []Provider `json:"providers"`
+}
+
+// Box is a box published on Vagrant Cloud
+type Box struct {
+	Tag              string    `json:"tag"`
+	ShortDescription string    `json:"short_description,omitempty"`
+	Downloads        int64     `json:"downloads"`
+	UpdatedAt        string    `json:"updated_at,omitempty"`
+	CurrentVersion   *Version  `json:"current_version,omitempty"`
+	Versions         []Version `json:"versions,omitempty"`
+}
+
+// SearchOptions filters a box search
+type SearchOptions struct {
+	Query        string
+	Provider     string
+	Architecture string
+	Limit        int
+}
+
+// Client queries the Vagrant Cloud API
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a client for the Vagrant Cloud API at baseURL, authenticating with token
+// when it is set
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+// NewClientFromEnv creates a client configured from MCP_VAGRANT_CLOUD_URL and
+// VAGRANT_CLOUD_TOKEN, the token the vagrant CLI uses
+func NewClientFromEnv() *Client {
+	return NewClient(os.Getenv("MCP_VAGRANT_CLOUD_URL"), os.Getenv("VAGRANT_CLOUD_TOKEN"))
+}
+
+// Search returns the most downloaded boxes matching the options. Providers are filtered by
+// provider and architecture, and boxes left without a matching provider are dropped.
+func (c *Client) Search(ctx context.Context, options SearchOptions) ([]Box, error) {
+	limit := options.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	query := url.Values{}
+	query.Set("q", options.Query)
+	query.Set("sort", "downloads")
+	query.Set("order", "desc")
+	query.Set("limit", strconv.Itoa(limit))
+	if options.Provider != "" {
+		query.Set("provider", options.Provider)
+	}
+	if options.Architecture != "" {
+		query.Set("architecture", options.Architecture)
+	}
+
+	var response struct {
+		Boxes []Box `json:"boxes"`
+	}
+	if err := c.get(ctx, "/search?"+query.Encode(), &response); err != nil {
+		return nil, err
+	}
+	boxes := make([]Box, 0, len(response.Boxes))
+	for _, box := range response.Boxes {
+		if box.CurrentVersion == nil {
+			continue
+		}
+		box.CurrentVersion.Providers = filterProviders(box.CurrentVersion.Providers, options.Provider, options.Architecture)
+		if len(box.CurrentVersion.Providers) == 0 {
+			continue
+		}
+		boxes = append(boxes, box)
+	}
+	return boxes, nil
+}
+
+// GetBox returns a box with its versions, newest first, keeping the providers that match
+// provider and architecture when they are set
+func (c *Client) GetBox(ctx context.Context, tag, provider, architecture string) (Box, error) {
+	if !boxTagPattern.MatchString(tag) {
+		return Box{}, fmt.Errorf("invalid box '%s': expected <user>/<name>, e.g. ubuntu/jammy64", tag)
+	}
+	var box Box
+	if err := c.get(ctx, "/box/"+tag, &box); err != nil {
+		return Box{}, err
+	}
+	versions := make([]Version, 0, len(box.Versions))
+	for _, version := range box.Versions {
+		version.Providers = filterProviders(version.Providers, provider, architecture)
+		if len(version.Providers) > 0 {
+			versions = append(versions, version)
+		}
+	}
+	box.Versions = versions
+	if box.CurrentVersion != nil {
+		box.CurrentVersion.Providers = filterProviders(box.CurrentVersion.Providers, provider, architecture)
+	}
+	return box, nil
+}
+
+// get decodes the JSON response of an API path
+func (c *Client) get(ctx context.Context, path string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create Vagrant Cloud request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Vagrant Cloud: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("not found on Vagrant Cloud")
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("unexpected response from Vagrant Cloud: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to parse Vagrant Cloud response: %w", err)
+	}
+	return nil
+}
+
+// MeasureSizes sets the download size of providers from the Content-Length of their
+// download URLs. Sizes that cannot be measured are left unset.
+func (c *Client) MeasureSizes(ctx context.Context, providers []*Provider) {
+	semaphore := make(chan struct{}, sizeLookups)
+	var wg sync.WaitGroup
+	for _, provider := range providers {
+		if provider.DownloadURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(provider *Provider) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, provider.DownloadURL, nil)
+			if err != nil {
+				return
+			}
+			resp, err := c.http.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode < 300 && resp.ContentLength > 0 {
+				provider.SizeBytes = resp.ContentLength
+			}
+		}(provider)
+	}
+	wg.Wait()
+}
+
+// NormalizeArchitecture returns the Vagrant name of an architecture, accepting the kernel names
+// x86_64 and aarch64
+func NormalizeArchitecture(architecture string) string {
+	switch strings.ToLower(architecture) {
+	case "x86_64", "x64":
+		return "amd64"
+	case "aarch64", "arm64e":
+		return "arm64"
+	}
+	return strings.ToLower(architecture)
+}
+
+// filterProviders keeps the providers matching provider and architecture when they are set
+func filterProviders(providers []Provider, provider, architecture string) []Provider {
+	filtered := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		if provider != "" && p.Name != provider {
+			continue
+		}
+		// Boxes published before architectures were recorded are treated as amd64
+		arch := p.Architecture
+		if arch == "" || arch == "unknown" {
+			arch = "amd64"
+		}
+		if architecture != "" && arch != architecture {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
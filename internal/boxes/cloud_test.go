@@ -0,0 +1,123 @@
+package boxes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestCloud serves canned Vagrant Cloud responses and box downloads
+func newTestCloud(t *testing.T) (*httptest.Server, *http.Request) {
+	var lastSearch http.Request
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/search":
+			lastSearch = *r
+			w.Write([]byte(strings.ReplaceAll(`{"boxes": [
+				{"tag": "bento/ubuntu-22.04", "downloads": 900, "current_version": {"version": "202401.01.0", "providers": [
+					{"name": "virtualbox", "architecture": "amd64", "download_url": "URL/download/vb-amd64.box"},
+					{"name": "parallels", "architecture": "arm64", "download_url": "URL/download/prl-arm64.box"}
+				]}},
+				{"tag": "ubuntu/focal64", "downloads": 800, "current_version": {"version": "20240101.0.0", "providers": [
+					{"name": "virtualbox", "architecture": "unknown", "download_url": "URL/download/focal.box"}
+				]}},
+				{"tag": "empty/box", "downloads": 1}
+			]}`, "URL", server.URL)))
+		case r.URL.Path == "/box/bento/ubuntu-22.04":
+			w.Write([]byte(`{"tag": "bento/ubuntu-22.04", "downloads": 900, "versions": [
+				{"version": "2", "status": "active", "providers": [{"name": "libvirt", "architecture": "arm64"}, {"name": "virtualbox", "architecture": "amd64"}]},
+				{"version": "1", "status": "active", "providers": [{"name": "virtualbox", "architecture": "amd64"}]}
+			]}`))
+		case strings.HasPrefix(r.URL.Path, "/download/"):
+			w.Header().Set("Content-Length", "1048576")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &lastSearch
+}
+
+func TestSearch(t *testing.T) {
+	server, lastSearch := newTestCloud(t)
+	client := NewClient(server.URL, "secret")
+
+	testCases := []struct {
+		name         string
+		options      SearchOptions
+		expectedTags []string
+	}{
+		{"all", SearchOptions{Query: "ubuntu"}, []string{"bento/ubuntu-22.04", "ubuntu/focal64"}},
+		{"arm64", SearchOptions{Query: "ubuntu", Architecture: "arm64"}, []string{"bento/ubuntu-22.04"}},
+		{"legacy boxes are amd64", SearchOptions{Query: "ubuntu", Provider: "virtualbox", Architecture: "amd64"}, []string{"bento/ubuntu-22.04", "ubuntu/focal64"}},
+		{"no provider", SearchOptions{Query: "ubuntu", Provider: "hyperv"}, []string{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := client.Search(context.Background(), tc.options)
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if len(found) != len(tc.expectedTags) {
+				t.Fatalf("Expected %d boxes but got %d", len(tc.expectedTags), len(found))
+			}
+			for i, box := range found {
+				if box.Tag != tc.expectedTags[i] {
+					t.Errorf("Expected box %s but got %s", tc.expectedTags[i], box.Tag)
+				}
+			}
+		})
+	}
+
+	if got := lastSearch.URL.Query().Get("sort"); got != "downloads" {
+		t.Errorf("Expected search sorted by downloads but got %q", got)
+	}
+	if got := lastSearch.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Expected bearer token but got %q", got)
+	}
+}
+
+func TestGetBoxAndSizes(t *testing.T) {
+	server, _ := newTestCloud(t)
+	client := NewClient(server.URL, "")
+
+	box, err := client.GetBox(context.Background(), "bento/ubuntu-22.04", "", "arm64")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if len(box.Versions) != 1 || box.Versions[0].Version != "2" || box.Versions[0].Providers[0].Name != "libvirt" {
+		t.Errorf("Expected only the arm64 libvirt build of version 2 but got %+v", box.Versions)
+	}
+
+	if _, err := client.GetBox(context.Background(), "missing/box", "", ""); err == nil {
+		t.Errorf("Expected error for a missing box")
+	}
+	if _, err := client.GetBox(context.Background(), "../etc", "", ""); err == nil {
+		t.Errorf("Expected error for an invalid box name")
+	}
+
+	providers := []*Provider{
+		{Name: "virtualbox", DownloadURL: server.URL + "/download/a.box"},
+		{Name: "libvirt", DownloadURL: server.URL + "/missing.box"},
+		{Name: "hyperv"},
+	}
+	client.MeasureSizes(context.Background(), providers)
+	if providers[0].SizeBytes != 1048576 {
+		t.Errorf("Expected size 1048576 but got %d", providers[0].SizeBytes)
+	}
+	if providers[1].SizeBytes != 0 || providers[2].SizeBytes != 0 {
+		t.Errorf("Expected unmeasured sizes to stay unset")
+	}
+}
+
+func TestNormalizeArchitecture(t *testing.T) {
+	testCases := map[string]string{"x86_64": "amd64", "aarch64": "arm64", "ARM64": "arm64", "amd64": "amd64", "": ""}
+	for input, expected := range testCases {
+		if got := NormalizeArchitecture(input); got != expected {
+			t.Errorf("Expected %q for %q but got %q", expected, input, got)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package cmdexec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Machine states the fake provider reports, as 'vagrant status --machine-readable' does
+const (
+	FakeStateNotCreated = "not_created"
+	FakeStateRunning    = "running"
+	FakeStatePoweroff   = "poweroff"
+	FakeStateSaved      = "saved"
+)
+
+// FakeProvider is the provider name the fake reports
+const FakeProvider = "fake"
+
+// FakeResponse is a canned response to the vagrant commands starting with Args
+type FakeResponse struct {
+	// Args is the argument prefix the response answers, e.g. ["up"] or ["ssh", "-c"]
+	Args []string
+	// Output replaces the output the fake provider would print; usually machine-readable lines
+	Output string
+	// ExitCode is the status the command exits with; a failed command leaves the machine as it was
+	ExitCode int
+	// Delay is how long the command takes; the output is written spread over it
+	Delay time.Duration
+	// Times is how many commands the response answers before it is dropped; 0 answers all
+	Times int
+}
+
+// FakeExitError is returned for fake commands that exit with a non-zero status
+type FakeExitError struct {
+	Code int
+}
+
+func (e *FakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the status the command exited with, like exec.ExitError
+func (e *FakeExitError) ExitCode() int {
+	return e.Code
+}
+
+// FakeVagrant is a VagrantRunner for tests. It simulates a provider that keeps a machine
+// per working directory, moving it between states as up, halt, suspend, reload and destroy
+// would and printing machine-readable output like Vagrant's. Scripted responses replace the
+// output of matching commands, e.g. to make a provisioner fail or a boot take a while.
+type FakeVagrant struct {
+	mu        sync.Mutex
+	states    map[string]string
+	responses []*FakeResponse
+	calls     []VagrantCommand
+}
+
+// NewFakeVagrant creates a fake whose machines are all not created
+func NewFakeVagrant() *FakeVagrant {
+	return &FakeVagrant{states: make(map[string]string)}
+}
+
+// Script adds canned responses; the first one matching a command answers it
+func (f *FakeVagrant) Script(responses ...FakeResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range responses {
+		response := responses[i]
+		f.responses = append(f.responses, &response)
+	}
+}
+
+// SetState sets the state of the machine in dir
+func (f *FakeVagrant) SetState(dir, state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[dir] = state
+}
+
+// State returns the state of the machine in dir
+func (f *FakeVagrant) State(dir string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state(dir)
+}
+
+// Calls returns the commands run so far, without their writers
+func (f *FakeVagrant) Calls() []VagrantCommand {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := make([]VagrantCommand, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
+
+// Run answers a command with the first matching scripted response, else as the fake provider
+func (f *FakeVagrant) Run(ctx context.Context, cmd VagrantCommand) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, VagrantCommand{Dir: cmd.Dir, Args: append([]string{}, cmd.Args...)})
+	response := f.match(cmd.Args)
+	var output string
+	var code int
+	var delay time.Duration
+	var next string
+	if response != nil {
+		output, code, delay = response.Output, response.ExitCode, response.Delay
+		if code == 0 {
+			_, _, next = f.simulate(cmd)
+		}
+	} else {
+		output, code, next = f.simulate(cmd)
+	}
+	f.mu.Unlock()
+
+	if err := writeSpread(ctx, cmd.Stdout, output, delay); err != nil {
+		return err
+	}
+	if code != 0 {
+		return &FakeExitError{Code: code}
+	}
+	if next != "" {
+		f.SetState(cmd.Dir, next)
+	}
+	return nil
+}
+
+// match returns the first scripted response for args, dropping it once used up
+func (f *FakeVagrant) match(args []string) *FakeResponse {
+	for i, response := range f.responses {
+		if len(response.Args) > len(args) {
+			continue
+		}
+		matches := true
+		for j, arg := range response.Args {
+			matches = matches && args[j] == arg
+		}
+		if !matches {
+			continue
+		}
+		if response.Times > 0 {
+			response.Times--
+			if response.Times == 0 {
+				f.responses = append(f.responses[:i], f.responses[i+1:]...)
+			}
+		}
+		return response
+	}
+	return nil
+}
+
+func (f *FakeVagrant) state(dir string) string {
+	if state, ok := f.states[dir]; ok {
+		return state
+	}
+	return FakeStateNotCreated
+}
+
+// simulate returns the output and exit code of a command on the fake provider and the state
+// the machine moves to, or an empty state when it does not change
+func (f *FakeVagrant) simulate(cmd VagrantCommand) (string, int, string) {
+	if len(cmd.Args) == 0 {
+		return "", 0, ""
+	}
+	state := f.state(cmd.Dir)
+	now := time.Now().Unix()
+	line := func(kind string, data ...string) string {
+		return fmt.Sprintf("%d,default,%s,%s\n", now, kind, strings.Join(data, ","))
+	}
+	notCreated := fmt.Sprintf("%d,,error-exit,Vagrant::Errors::VMNotCreatedError,The machine must be created before running this command.\n", now)
+	notRunning := fmt.Sprintf("%d,,error-exit,Vagrant::Errors::VMNotRunningError,The machine must be running to run this command.\n", now)
+
+	switch cmd.Args[0] {
+	case "status":
+		return line("metadata", "provider", FakeProvider) + line("provider-name", FakeProvider) + line("state", state) + line("state-human-short", strings.ReplaceAll(state, "_", " ")), 0, ""
+	case "up", "reload":
+		if cmd.Args[0] == "reload" && state == FakeStateNotCreated {
+			return notCreated, 1, ""
+		}
+		output := line("action", cmd.Args[0], "start") +
+			line("ui", "info", fmt.Sprintf("Bringing machine 'default' up with '%s' provider...", FakeProvider)) +
+			line("ui", "output", "==> default: Booting VM...") +
+			line("ui", "output", "==> default: Machine booted and ready!") +
+			line("action", cmd.Args[0], "end")
+		return output, 0, FakeStateRunning
+	case "halt":
+		if state == FakeStateNotCreated {
+			return line("ui", "info", "==> default: VM not created. Moving on..."), 0, ""
+		}
+		return line("action", "halt", "start") + line("ui", "output", "==> default: Attempting graceful shutdown of VM...") + line("action", "halt", "end"), 0, FakeStatePoweroff
+	case "suspend":
+		if state != FakeStateRunning {
+			return notRunning, 1, ""
+		}
+		return line("ui", "output", "==> default: Saving VM state and suspending execution..."), 0, FakeStateSaved
+	case "destroy":
+		return line("action", "destroy", "start") + line("ui", "output", "==> default: Destroying VM and associated drives...") + line("action", "destroy", "end"), 0, FakeStateNotCreated
+	case "provision", "ssh", "ssh-config", "upload", "package":
+		if state == FakeStateNotCreated {
+			return notCreated, 1, ""
+		}
+		if state != FakeStateRunning {
+			return notRunning, 1, ""
+		}
+		switch cmd.Args[0] {
+		case "ssh-config":
+			return "Host default\n  HostName 127.0.0.1\n  User vagrant\n  Port 2222\n  IdentityFile " + cmd.Dir + "/.vagrant/machines/default/fake/private_key\n", 0, ""
+		case "package":
+			return line("ui", "output", "==> default: Exporting VM..."), 0, FakeStatePoweroff
+		}
+	case "validate":
+		return "Vagrantfile validated successfully.\n", 0, ""
+	}
+	return "", 0, ""
+}
+
+// writeSpread writes output to w a line at a time, spread over delay, stopping when ctx is done
+func writeSpread(ctx context.Context, w io.Writer, output string, delay time.Duration) error {
+	if w == nil {
+		w = io.Discard
+	}
+	lines := strings.SplitAfter(output, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var pause time.Duration
+	if len(lines) > 0 {
+		pause = delay / time.Duration(len(lines))
+	} else if delay > 0 {
+		lines, pause = []string{""}, delay
+	}
+	for _, line := range lines {
+		if pause > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pause):
+			}
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,84 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package cmdexec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/audit"
+)
+
+// SSHCommand is a command to run in a guest with the ssh executable
+type SSHCommand struct {
+	// VMName is the VM the guest belongs to, as recorded in the audit log
+	VMName string
+	// Args are the ssh arguments connecting to the guest, ending with user@host
+	Args []string
+	// Command is the command run in the guest
+	Command string
+	// AuditCommand is recorded in the audit log instead of Command when set, e.g. when
+	// Command holds a password
+	AuditCommand string
+	// Stdin is sent to the command; nil sends nothing
+	Stdin io.Reader
+	// Stdout and Stderr receive the output as it is written; nil discards it
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// RunSSH runs a command in a guest over ssh, killing it when ctx is done, and records it in
+// the audit log
+func RunSSH(ctx context.Context, cmd SSHCommand) error {
+	c := exec.CommandContext(ctx, "ssh", append(append([]string{}, cmd.Args...), cmd.Command)...)
+	c.Stdin = cmd.Stdin
+	c.Stdout = cmd.Stdout
+	c.Stderr = cmd.Stderr
+	auditCommand := cmd.Command
+	if cmd.AuditCommand != "" {
+		auditCommand = cmd.AuditCommand
+	}
+	start := time.Now()
+	err := c.Run()
+	recordGuestCommand(cmd.VMName, auditCommand, start, err)
+	return err
+}
+
+// SSHOutput runs a command in the guest of vmName over ssh, records it in the audit log and
+// returns its stdout. The stderr of a failed command is added to the error.
+func SSHOutput(ctx context.Context, vmName string, sshArgs []string, command string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	err := RunSSH(ctx, SSHCommand{VMName: vmName, Args: sshArgs, Command: command, Stdout: &stdout, Stderr: &stderr})
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), err
+}
+
+// RunInGuest runs a vagrant command running command in the guest of vmName, such as
+// 'vagrant ssh -c', and records command in the audit log
+func RunInGuest(ctx context.Context, runner VagrantRunner, vmName, command string, cmd VagrantCommand) error {
+	start := time.Now()
+	err := runner.Run(ctx, cmd)
+	recordGuestCommand(vmName, command, start, err)
+	return err
+}
+
+// recordGuestCommand records a guest command started at start that returned err in the audit
+// log. Commands that could not be started are recorded with exit code -1.
+func recordGuestCommand(vmName, command string, start time.Time, err error) {
+	exitCode := 0
+	if err != nil {
+		exitCode = -1
+		if exitErr, ok := err.(interface{ ExitCode() int }); ok {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+	audit.GlobalLog.RecordCommand(vmName, command, "", nil, exitCode, time.Since(start), err)
+}
@@ -0,0 +1,54 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package cmdexec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+)
+
+// VagrantCommand is a vagrant command to run
+type VagrantCommand struct {
+	// Dir is the working directory, usually the directory holding the Vagrantfile
+	Dir string
+	// Args are the arguments after 'vagrant', e.g. ["up", "--machine-readable"]
+	Args []string
+	// Stdout and Stderr receive the output as it is written; nil discards it
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// VagrantRunner runs vagrant commands. The VM manager runs every vagrant command through one,
+// so tests can replace the Vagrant CLI with a FakeVagrant.
+type VagrantRunner interface {
+	// Run runs a command until it exits, returning an error when it could not be started
+	// or exited with a non-zero status
+	Run(ctx context.Context, cmd VagrantCommand) error
+}
+
+// CLIRunner runs the vagrant executable on the PATH
+type CLIRunner struct{}
+
+// Run runs vagrant with the command's arguments, killing it when ctx is done
+func (CLIRunner) Run(ctx context.Context, cmd VagrantCommand) error {
+	c := exec.CommandContext(ctx, "vagrant", cmd.Args...)
+	c.Dir = cmd.Dir
+	c.Stdout = cmd.Stdout
+	c.Stderr = cmd.Stderr
+	return c.Run()
+}
+
+// CombinedOutput runs a vagrant command and returns its stdout and stderr together
+func CombinedOutput(ctx context.Context, runner VagrantRunner, dir string, args ...string) ([]byte, error) {
+	var output bytes.Buffer
+	err := runner.Run(ctx, VagrantCommand{Dir: dir, Args: args, Stdout: &output, Stderr: &output})
+	return output.Bytes(), err
+}
+
+// CommandLine returns the full command line of a vagrant command, as recorded in logs
+func CommandLine(args []string) []string {
+	return append([]string{"vagrant"}, args...)
+}
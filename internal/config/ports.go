@@ -0,0 +1,250 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// DefaultPortProfile is the profile used when create_dev_vm is given neither ports nor a profile
+const DefaultPortProfile = "default"
+
+// Port profile sources
+const (
+	PortProfileSourceBuiltin = "builtin"
+	PortProfileSourceUser    = "user"
+)
+
+// ProfilePort is a forwarded port in a port profile
+type ProfilePort struct {
+	Service string `json:"service"`
+	Guest   int    `json:"guest"`
+	Host    int    `json:"host"`
+}
+
+// PortProfile is a named bundle of port forwards for a stack
+type PortProfile struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Ports       []ProfilePort `json:"ports"`
+	Source      string        `json:"source"`
+}
+
+// PortProfileRegistry manages port profiles
+type PortProfileRegistry struct {
+	profiles map[string]PortProfile
+	mutex    sync.RWMutex
+}
+
+var (
+	// GlobalPortProfiles is the global port profile registry
+	GlobalPortProfiles = NewPortProfileRegistryFromEnv()
+)
+
+// NewPortProfileRegistry creates a port profile registry with the built-in profiles
+func NewPortProfileRegistry() *PortProfileRegistry {
+	registry := &PortProfileRegistry{
+		profiles: make(map[string]PortProfile),
+	}
+
+	// Register default profiles
+	registry.registerDefaultProfiles()
+
+	return registry
+}
+
+// NewPortProfileRegistryFromEnv creates a port profile registry and loads user profiles from
+// MCP_PORT_PROFILES_FILE, or ~/.vagrant-mcp/port-profiles.json when it is not set
+func NewPortProfileRegistryFromEnv() *PortProfileRegistry {
+	registry := NewPortProfileRegistry()
+
+	path := PortProfilesFile()
+	if path == "" {
+		return registry
+	}
+	if err := registry.LoadFile(path); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("file", path).Msg("Failed to load port profiles")
+	}
+	return registry
+}
+
+// PortProfilesFile returns the path of the user port profiles file
+func PortProfilesFile() string {
+	if path := os.Getenv("MCP_PORT_PROFILES_FILE"); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".vagrant-mcp", "port-profiles.json")
+}
+
+// registerDefaultProfiles registers the built-in port profiles
+func (r *PortProfileRegistry) registerDefaultProfiles() {
+	builtins := []PortProfile{
+		{
+			Name:        DefaultPortProfile,
+			Description: "Node.js, Python and common databases",
+			Ports: []ProfilePort{
+				{Service: "nodejs", Guest: DefaultVM.Ports.NodeJS.Guest, Host: DefaultVM.Ports.NodeJS.Host},
+				{Service: "python", Guest: DefaultVM.Ports.Python.Guest, Host: DefaultVM.Ports.Python.Host},
+				{Service: "postgresql", Guest: DefaultVM.Ports.PostgreSQL.Guest, Host: DefaultVM.Ports.PostgreSQL.Host},
+				{Service: "mysql", Guest: DefaultVM.Ports.MySQL.Guest, Host: DefaultVM.Ports.MySQL.Host},
+				{Service: "redis", Guest: DefaultVM.Ports.Redis.Guest, Host: DefaultVM.Ports.Redis.Host},
+			},
+		},
+		{
+			Name:        "web",
+			Description: "Front-end and Node.js web development",
+			Ports: []ProfilePort{
+				{Service: "http", Guest: DefaultVM.Ports.HTTP.Guest, Host: DefaultVM.Ports.HTTP.Host},
+				{Service: "https", Guest: DefaultVM.Ports.HTTPS.Guest, Host: DefaultVM.Ports.HTTPS.Host},
+				{Service: "nodejs", Guest: DefaultVM.Ports.NodeJS.Guest, Host: DefaultVM.Ports.NodeJS.Host},
+				{Service: "vite", Guest: 5173, Host: 5173},
+			},
+		},
+		{
+			Name:        "django",
+			Description: "Django development server with PostgreSQL and Redis",
+			Ports: []ProfilePort{
+				{Service: "django", Guest: DefaultVM.Ports.Python.Guest, Host: DefaultVM.Ports.Python.Host},
+				{Service: "postgresql", Guest: DefaultVM.Ports.PostgreSQL.Guest, Host: DefaultVM.Ports.PostgreSQL.Host},
+				{Service: "redis", Guest: DefaultVM.Ports.Redis.Guest, Host: DefaultVM.Ports.Redis.Host},
+			},
+		},
+		{
+			Name:        "rails",
+			Description: "Rails server with PostgreSQL and Redis",
+			Ports: []ProfilePort{
+				{Service: "rails", Guest: 3000, Host: 3000},
+				{Service: "postgresql", Guest: DefaultVM.Ports.PostgreSQL.Guest, Host: DefaultVM.Ports.PostgreSQL.Host},
+				{Service: "redis", Guest: DefaultVM.Ports.Redis.Guest, Host: DefaultVM.Ports.Redis.Host},
+			},
+		},
+		{
+			Name:        "spring",
+			Description: "Spring Boot application with remote debugging and PostgreSQL",
+			Ports: []ProfilePort{
+				{Service: "http", Guest: 8080, Host: 8080},
+				{Service: "jdwp", Guest: 5005, Host: 5005},
+				{Service: "postgresql", Guest: DefaultVM.Ports.PostgreSQL.Guest, Host: DefaultVM.Ports.PostgreSQL.Host},
+			},
+		},
+		{
+			Name:        "data-science",
+			Description: "Jupyter, TensorBoard and Streamlit",
+			Ports: []ProfilePort{
+				{Service: "jupyter", Guest: 8888, Host: 8888},
+				{Service: "tensorboard", Guest: 6006, Host: 6006},
+				{Service: "streamlit", Guest: 8501, Host: 8501},
+			},
+		},
+	}
+
+	for _, profile := range builtins {
+		profile.Source = PortProfileSourceBuiltin
+		r.RegisterProfile(profile)
+	}
+}
+
+// RegisterProfile registers a port profile, replacing any profile with the same name
+func (r *PortProfileRegistry) RegisterProfile(profile PortProfile) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.profiles[profile.Name] = profile
+}
+
+// LoadFile registers the user profiles in a JSON file holding a list of profiles
+func (r *PortProfileRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var profiles []PortProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("failed to parse port profiles: %w", err)
+	}
+
+	for _, profile := range profiles {
+		if err := validatePortProfile(profile); err != nil {
+			return err
+		}
+	}
+	for _, profile := range profiles {
+		profile.Source = PortProfileSourceUser
+		r.RegisterProfile(profile)
+	}
+	return nil
+}
+
+// GetProfile retrieves a port profile by name
+func (r *PortProfileRegistry) GetProfile(name string) (PortProfile, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	profile, exists := r.profiles[name]
+	if !exists {
+		return PortProfile{}, fmt.Errorf("port profile '%s' not found", name)
+	}
+	return profile, nil
+}
+
+// ListProfiles returns all port profiles sorted by name
+func (r *PortProfileRegistry) ListProfiles() []PortProfile {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	profiles := make([]PortProfile, 0, len(r.profiles))
+	for _, profile := range r.profiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// validatePortProfile checks that a user profile has a name and valid ports
+func validatePortProfile(profile PortProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("port profile without a name")
+	}
+	for _, port := range profile.Ports {
+		if port.Guest < 1 || port.Guest > 65535 || port.Host < 1 || port.Host > 65535 {
+			return fmt.Errorf("port profile '%s' has an invalid port mapping %d:%d", profile.Name, port.Host, port.Guest)
+		}
+	}
+	return nil
+}
+
+// AssignHostPorts maps profile ports to VM ports, moving host ports that are in use
+// to the next free port. used holds host ports taken by other VMs; available, when
+// not nil, reports whether a host port can be bound.
+func AssignHostPorts(ports []ProfilePort, used map[int]bool, available func(int) bool) []core.Port {
+	taken := make(map[int]bool, len(used)+len(ports))
+	for port := range used {
+		taken[port] = true
+	}
+
+	assigned := make([]core.Port, 0, len(ports))
+	for _, port := range ports {
+		host := port.Host
+		for host <= 65535 && (taken[host] || (available != nil && !available(host))) {
+			host++
+		}
+		if host > 65535 {
+			// No free port above the preferred one; keep it and let Vagrant's auto_correct decide
+			host = port.Host
+		}
+		taken[host] = true
+		assigned = append(assigned, core.Port{Guest: port.Guest, Host: host})
+	}
+	return assigned
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssignHostPorts(t *testing.T) {
+	ports := []ProfilePort{
+		{Service: "http", Guest: 80, Host: 8080},
+		{Service: "alt", Guest: 81, Host: 8081},
+		{Service: "db", Guest: 5432, Host: 5432},
+	}
+	used := map[int]bool{8080: true, 5432: true}
+	busy := map[int]bool{5433: true}
+
+	assigned := AssignHostPorts(ports, used, func(port int) bool { return !busy[port] })
+
+	// 8080 is taken so http moves to 8081, which pushes alt on to 8082
+	expected := []int{8081, 8082, 5434}
+	for i, port := range assigned {
+		if port.Host != expected[i] || port.Guest != ports[i].Guest {
+			t.Errorf("Expected %d:%d but got %d:%d", expected[i], ports[i].Guest, port.Host, port.Guest)
+		}
+	}
+}
+
+func TestLoadPortProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "port-profiles.json")
+	content := `[{"name": "web", "description": "Custom web", "ports": [{"service": "app", "guest": 4000, "host": 4000}]},
+		{"name": "go", "ports": [{"service": "api", "guest": 8080, "host": 9090}]}]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewPortProfileRegistry()
+	if err := registry.LoadFile(path); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	web, err := registry.GetProfile("web")
+	if err != nil || web.Source != PortProfileSourceUser || len(web.Ports) != 1 {
+		t.Errorf("Expected the user web profile to replace the built-in one but got %+v (%v)", web, err)
+	}
+	if _, err := registry.GetProfile("go"); err != nil {
+		t.Errorf("Expected the go profile to be registered but got %v", err)
+	}
+	if django, err := registry.GetProfile("django"); err != nil || django.Source != PortProfileSourceBuiltin {
+		t.Errorf("Expected the built-in django profile to remain but got %+v (%v)", django, err)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`[{"name": "bad", "ports": [{"guest": 0, "host": 80}]}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.LoadFile(invalid); err == nil {
+		t.Errorf("Expected an error for an invalid port mapping")
+	}
+}
+
+func TestLoadServerFile(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"tracing", `{"tracing": {"endpoint": "http://localhost:4318", "sample_ratio": 0.25}}`, true},
+		{"empty", `{}`, true},
+		{"endpoint without scheme", `{"tracing": {"endpoint": "localhost:4318"}}`, false},
+		{"ratio above 1", `{"tracing": {"endpoint": "http://localhost:4318", "sample_ratio": 1.5}}`, false},
+		{"invalid json", `{"tracing":`, false},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("config-%d.json", i))
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+			server, err := LoadServerFile(path)
+			if tc.valid && err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("Expected an error but got %+v", server)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Server is the server config file
+type Server struct {
+	Tracing Tracing `json:"tracing"`
+}
+
+// Tracing configures the export of spans to an OpenTelemetry collector
+type Tracing struct {
+	// Endpoint is the collector's OTLP/HTTP base URL, e.g. http://localhost:4318; spans are
+	// posted to its /v1/traces path. Tracing is off without it.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are sent to the collector with every export
+	Headers map[string]string `json:"headers,omitempty"`
+	// ServiceName is the service reported with the spans
+	ServiceName string `json:"service_name,omitempty"`
+	// SampleRatio is the share of traces recorded, from 0 to 1; every trace when unset
+	SampleRatio *float64 `json:"sample_ratio,omitempty"`
+}
+
+// ServerFile returns the path of the server config file: MCP_CONFIG_FILE, or
+// ~/.vagrant-mcp/config.json when it is not set
+func ServerFile() string {
+	if file := os.Getenv("MCP_CONFIG_FILE"); file != "" {
+		return file
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".vagrant-mcp", "config.json")
+}
+
+// LoadServerFile reads and validates a server config file
+func LoadServerFile(file string) (Server, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Server{}, err
+	}
+	var server Server
+	if err := json.Unmarshal(data, &server); err != nil {
+		return Server{}, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := server.Validate(); err != nil {
+		return Server{}, err
+	}
+	return server, nil
+}
+
+// Validate checks the settings of a server config file
+func (s Server) Validate() error {
+	if s.Tracing.Endpoint != "" {
+		parsed, err := url.Parse(s.Tracing.Endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid tracing endpoint %q: use an http or https URL", s.Tracing.Endpoint)
+		}
+	}
+	if ratio := s.Tracing.SampleRatio; ratio != nil && (*ratio < 0 || *ratio > 1) {
+		return fmt.Errorf("invalid tracing sample_ratio %v: use a number from 0 to 1", *ratio)
+	}
+	return nil
+}
@@ -29,7 +29,8 @@ type VMManager interface {
 	// GetVMConfig gets the configuration of a VM
 	GetVMConfig(ctx context.Context, name string) (VMConfig, error)
 
-	// UpdateVMConfig updates the configuration of a VM
+	// UpdateVMConfig updates the configuration of a VM; a config whose revision is not the
+	// stored one, including one without a revision, fails with a conflict error
 	UpdateVMConfig(ctx context.Context, name string, config VMConfig) error
 
 	// GetBaseDir gets the base directory for VMs
@@ -53,6 +54,10 @@ type SyncEngine interface {
 	// SyncToVM synchronizes files from host to VM
 	SyncToVM(ctx context.Context, vmName string, sourcePath string) (*SyncResult, error)
 
+	// SyncPendingToVM synchronizes the host files changed since the last sync to the VM, and
+	// nothing when none changed
+	SyncPendingToVM(ctx context.Context, vmName string) (*SyncResult, error)
+
 	// SyncFromVM synchronizes files from VM to host
 	SyncFromVM(ctx context.Context, vmName string, sourcePath string) (*SyncResult, error)
 
@@ -68,6 +73,9 @@ type SyncEngine interface {
 	// ResolveSyncConflict resolves a sync conflict
 	ResolveSyncConflict(ctx context.Context, vmName string, path string, resolution string) error
 
+	// GetSyncJournal returns the recorded sync operations and conflict resolutions for a VM
+	GetSyncJournal(ctx context.Context, vmName string) ([]SyncJournalEntry, error)
+
 	// SemanticSearch performs a semantic search across synchronized files
 	SemanticSearch(ctx context.Context, vmName string, query string, maxResults int) ([]SearchResult, error)
 
@@ -45,6 +45,10 @@ const (
 	SyncMethodSMB SyncMethod = "smb"
 	// SyncMethodVirtualBox uses VirtualBox shared folders
 	SyncMethodVirtualBox SyncMethod = "virtualbox"
+	// SyncMethodVirtioFS uses virtiofs shared folders of the libvirt provider
+	SyncMethodVirtioFS SyncMethod = "virtiofs"
+	// SyncMethod9P uses virtio 9p shared folders of the libvirt provider
+	SyncMethod9P SyncMethod = "9p"
 )
 
 // SyncConfig represents the configuration for file synchronization
@@ -56,12 +60,61 @@ type SyncConfig struct {
 	ExcludePatterns []string      `json:"exclude_patterns"`
 	WatchEnabled    bool          `json:"watch_enabled"`
 	WatchInterval   time.Duration `json:"watch_interval"`
+	// WatchMode is one of auto, notify or poll; empty means auto
+	WatchMode string `json:"watch_mode,omitempty"`
+	// WatchDepth limits how many directory levels are watched; deeper ones are polled. 0 watches every level.
+	WatchDepth int `json:"watch_depth,omitempty"`
+	// ConflictPolicy is one of prefer_host, prefer_vm, prefer_newest or always_manual
+	ConflictPolicy string `json:"conflict_policy"`
+	// ConflictPolicyOverrides apply different policies to matching paths
+	ConflictPolicyOverrides []ConflictPolicyOverride `json:"conflict_policy_overrides,omitempty"`
+	// ConflictStyle is the marker style of merges: merge, diff3 or zdiff3; empty means merge
+	ConflictStyle string `json:"conflict_style,omitempty"`
+}
+
+// ConflictPolicyOverride applies a different conflict policy to paths matching a pattern
+type ConflictPolicyOverride struct {
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Policy  string `json:"policy" yaml:"policy"`
+}
+
+// WatcherStats describes a running file watcher
+type WatcherStats struct {
+	Mode string `json:"mode"`
+	// Strategy is notify, poll, or hybrid when directories below the watch depth are polled
+	Strategy    string `json:"strategy"`
+	WatchedDirs int    `json:"watched_dirs"`
+	PolledRoots int    `json:"polled_roots"`
+	PolledPaths int    `json:"polled_paths"`
+	// WatchLimit is the system's inotify watch limit, when known
+	WatchLimit int       `json:"watch_limit,omitempty"`
+	Events     int       `json:"events"`
+	Batches    int       `json:"batches"`
+	Overflows  int       `json:"overflows"`
+	LastError  string    `json:"last_error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// SyncJournalEntry records a sync operation or conflict resolution
+type SyncJournalEntry struct {
+	Time       time.Time `json:"time"`
+	Operation  string    `json:"operation"`
+	Path       string    `json:"path,omitempty"`
+	Policy     string    `json:"policy,omitempty"`
+	Resolution string    `json:"resolution,omitempty"`
+	FileCount  int       `json:"file_count,omitempty"`
+	Message    string    `json:"message,omitempty"`
 }
 
 // SyncResult represents the result of a synchronization operation
 type SyncResult struct {
 	SyncedFiles []string `json:"synced_files"`
 	SyncTimeMs  int      `json:"sync_time_ms"`
+	// ReportArtifact is the URI of the changes the sync made, when the sync tool listed them
+	ReportArtifact string `json:"report_artifact,omitempty"`
+	// Conflicts are the files changed on both sides since the last sync, which the sync left
+	// alone and handed to the conflict policy
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 // SyncStatus represents the status of a synchronization operation
@@ -78,6 +131,32 @@ type SyncStatus struct {
 	TotalSyncs           int            `json:"total_syncs"`
 	TotalFilesSynced     int            `json:"total_files_synced"`
 	TotalSyncTimeMs      int            `json:"total_sync_time_ms"`
+	// QueuedSyncs is how many syncs are waiting for the one in progress
+	QueuedSyncs int `json:"queued_syncs"`
+	// PendingUploadTruncated is set when more host files changed than FilesPendingUpload lists,
+	// so only a sync of the whole project brings the VM up to date
+	PendingUploadTruncated bool `json:"pending_upload_truncated"`
+	// PendingDownloadTruncated is set when the last guest scan found more changed files than
+	// FilesPendingDownload lists
+	PendingDownloadTruncated bool `json:"pending_download_truncated"`
+	// LastGuestScan is when the VM was last scanned for files changed since the last sync
+	LastGuestScan time.Time `json:"last_guest_scan,omitempty"`
+	// Progress is the progress of the copy of the sync in progress, once rsync reported it
+	Progress *SyncProgress `json:"progress,omitempty"`
+}
+
+// SyncProgress is how far the copy of a sync in progress got
+type SyncProgress struct {
+	Percent          int    `json:"percent"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+	Rate             string `json:"rate,omitempty"`
+	// ETASeconds is the estimated time left
+	ETASeconds int `json:"eta_seconds"`
+	// FilesToCheck of TotalFiles are still to be compared with the other side
+	FilesTransferred int       `json:"files_transferred"`
+	FilesToCheck     int       `json:"files_to_check"`
+	TotalFiles       int       `json:"total_files"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // SyncConflict represents a file conflict during synchronization
@@ -90,6 +169,17 @@ type SyncConflict struct {
 	ConflictType string    `json:"conflict_type"`          // "modification", "deletion", "creation"
 }
 
+// ConflictDetail is a queued sync conflict with a unified diff of its host and VM versions
+type ConflictDetail struct {
+	SyncConflict
+	RelativePath  string `json:"relative_path"`
+	Policy        string `json:"policy"`
+	HasBase       bool   `json:"has_base"`
+	Diff          string `json:"diff,omitempty"`
+	DiffTruncated bool   `json:"diff_truncated,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
 // SearchResult represents a search result from the VM
 type SearchResult struct {
 	Path      string `json:"path"`
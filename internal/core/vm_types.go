@@ -3,16 +3,108 @@
 
 package core
 
+import (
+	"path/filepath"
+	"time"
+)
+
+// Operation logs hold the transcript of the last vagrant command of each kind run for a VM
+const (
+	// OperationLogUp is the transcript of the last "vagrant up"
+	OperationLogUp = "up"
+	// OperationLogProvision is the provisioner section of the last "vagrant up", or the last
+	// "vagrant provision"
+	OperationLogProvision = "provision"
+	// OperationLogHalt is the transcript of the last "vagrant halt"
+	OperationLogHalt = "halt"
+	// OperationLogDestroy is the transcript of the last "vagrant destroy"
+	OperationLogDestroy = "destroy"
+)
+
+// OperationLogs lists the operation log names
+var OperationLogs = []string{OperationLogUp, OperationLogProvision, OperationLogHalt, OperationLogDestroy}
+
+// OperationLogDir is the directory under a VM directory that holds its operation logs
+const OperationLogDir = "logs"
+
+// DestroyedLogDir is the directory under the base directory that keeps destroy logs,
+// since destroying a VM removes its directory
+const DestroyedLogDir = ".destroyed"
+
+// OperationLogPath returns the path of an operation log for a VM
+func OperationLogPath(baseDir, vmName, operation string) string {
+	if operation == OperationLogDestroy {
+		return filepath.Join(baseDir, DestroyedLogDir, vmName, operation+".log")
+	}
+	return filepath.Join(baseDir, vmName, OperationLogDir, operation+".log")
+}
+
 // Port represents a port mapping between guest and host
 type Port struct {
-	Guest int `json:"guest"`
-	Host  int `json:"host"`
+	Guest int `json:"guest" yaml:"guest"`
+	Host  int `json:"host" yaml:"host"`
+}
+
+// Disk is an additional disk attached to a VM, formatted and mounted by the guest
+type Disk struct {
+	Name       string `json:"name"`
+	SizeGB     int    `json:"size_gb"`
+	MountPoint string `json:"mount_point"`
+}
+
+// Proxy is the HTTP proxy configuration of a VM
+type Proxy struct {
+	HTTPProxy  string `json:"http_proxy,omitempty"`
+	HTTPSProxy string `json:"https_proxy,omitempty"`
+	NoProxy    string `json:"no_proxy,omitempty"`
+}
+
+// Firewall restricts the guest ports a VM accepts connections on
+type Firewall struct {
+	// AllowPorts are TCP ports opened in addition to SSH and the forwarded guest ports
+	AllowPorts []int `json:"allow_ports,omitempty"`
+}
+
+// Dotfiles personalizes the guest with a dotfiles repository and copies of host dotfiles
+type Dotfiles struct {
+	// Repo is a git URL cloned to ~/.dotfiles in the guest
+	Repo string `json:"repo,omitempty"`
+	// Ref is the branch, tag or commit checked out; the default branch when unset
+	Ref string `json:"ref,omitempty"`
+	// Install is a script in the repository run after it is cloned, e.g. install.sh
+	Install string `json:"install,omitempty"`
+	// Files are host dotfiles, relative to the home directory, copied to the same path in the
+	// guest's home directory; only allowlisted files can be copied
+	Files []string `json:"files,omitempty"`
+}
+
+// VagrantfileSnippet is raw Ruby added to a generated Vagrantfile inside its
+// Vagrant.configure block, after the named section
+type VagrantfileSnippet struct {
+	Name    string `json:"name"`
+	Section string `json:"section,omitempty"`
+	Content string `json:"content"`
+}
+
+// ProvisionStep is a named shell provisioner of a VM, run after the setup provisioner in the
+// order the steps are listed
+type ProvisionStep struct {
+	Name string `json:"name" yaml:"name"`
+	// Inline is the script to run; Path is a host script instead, relative to the project
+	Inline string `json:"inline,omitempty" yaml:"inline,omitempty"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Run is once (the default), on the first boot and provision_vm, or always, on every boot
+	Run string `json:"run,omitempty" yaml:"run,omitempty"`
+	// Privileged runs the step as root, the default, or as the vagrant user when false
+	Privileged *bool `json:"privileged,omitempty" yaml:"privileged,omitempty"`
 }
 
 // VMConfig represents the configuration for a virtual machine
 type VMConfig struct {
-	Name                string   `json:"name"`
-	Box                 string   `json:"box"`
+	Name string `json:"name"`
+	Box  string `json:"box"`
+	// Provider is the Vagrant provider that runs the VM; VirtualBox when unset
+	Provider            string   `json:"provider,omitempty"`
 	CPU                 int      `json:"cpu"`
 	Memory              int      `json:"memory"`
 	ProjectPath         string   `json:"project_path"`
@@ -23,6 +115,43 @@ type VMConfig struct {
 	Ports               []Port   `json:"ports,omitempty"`
 	Environment         []string `json:"environment,omitempty"`
 	Provisioners        []string `json:"provisioners,omitempty"`
+	DiskSizeGB          int      `json:"disk_size_gb,omitempty"`
+	Disks               []Disk   `json:"disks,omitempty"`
+	Network             string   `json:"network,omitempty"`
+	Proxy               *Proxy   `json:"proxy,omitempty"`
+	// ProvisionSteps are named provisioners, so a failure is attributed to its step
+	ProvisionSteps []ProvisionStep `json:"provision_steps,omitempty"`
+	// CACertificates are PEM encoded certificates the guest trusts in addition to its defaults
+	CACertificates []string `json:"ca_certificates,omitempty"`
+	// SharedPackageCache mounts the host's shared apt, npm, pip and Go module caches
+	SharedPackageCache bool `json:"shared_package_cache,omitempty"`
+	// LinkedClone creates the VM as a linked clone of its box instead of a full copy
+	LinkedClone bool `json:"linked_clone,omitempty"`
+	// CloudInit is a cloud-init user-data document applied on the first boot
+	CloudInit string `json:"cloud_init,omitempty"`
+	// Firewall, when set, blocks incoming connections except to SSH and the forwarded ports
+	Firewall *Firewall `json:"firewall,omitempty"`
+	// Dotfiles are applied on the first boot
+	Dotfiles *Dotfiles `json:"dotfiles,omitempty"`
+	// StartupCommands run in the synced folder as the vagrant user every time the VM boots
+	StartupCommands []string `json:"startup_commands,omitempty"`
+	// ForwardSSHAgent forwards the host's SSH agent to ssh sessions in the VM
+	ForwardSSHAgent bool `json:"forward_ssh_agent,omitempty"`
+	// Snippets are user-supplied Ruby added to the generated Vagrantfile
+	Snippets []VagrantfileSnippet `json:"vagrantfile_snippets,omitempty"`
+	// Template names a Vagrantfile template in the user's templates directory that replaces
+	// the built-in one
+	Template string `json:"vagrantfile_template,omitempty"`
+	// Tags label the VM, e.g. with its project or team, so batch tools can select it
+	Tags []string `json:"tags,omitempty"`
+	// CreatedAt is when the VM was created or adopted
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// Revision counts the saves of the configuration. A config saved with a revision other
+	// than the stored one is rejected, so concurrent read-modify-write updates cannot clobber
+	// each other; a zero revision is rejected too once the stored config has a revision.
+	Revision int `json:"revision,omitempty"`
+	// ExpiresAt is when the expiry reaper halts or destroys the VM; VMs without it never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // UploadOptions contains options for uploading files to a VM
@@ -0,0 +1,233 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package database builds the commands that manage the PostgreSQL and MySQL servers
+// installed in VMs. SQL is sent to the client on stdin and values are bound by the client,
+// so nothing user supplied is spliced into shell commands or SQL text.
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// Engine names, matching the tools setup_dev_environment installs
+const (
+	EnginePostgres = "postgresql"
+	EngineMySQL    = "mysql"
+)
+
+// Engines lists the supported engines
+var Engines = []string{EnginePostgres, EngineMySQL}
+
+// identifierPattern matches the database and user names the tools accept
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// Script is SQL together with the values the client binds into it
+type Script struct {
+	SQL string
+	// Vars are psql variables, referenced in the SQL as :'name'
+	Vars map[string]string
+}
+
+// ValidateEngine returns an error for unsupported engines
+func ValidateEngine(engine string) error {
+	for _, e := range Engines {
+		if e == engine {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported database engine '%s' (must be one of %s)", engine, strings.Join(Engines, ", "))
+}
+
+// ValidateIdentifier returns an error unless name is a plain database or user name
+func ValidateIdentifier(name string) error {
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid name '%s': use letters, digits and underscores, starting with a letter or underscore", name)
+	}
+	return nil
+}
+
+// DefaultPort returns the port an engine listens on
+func DefaultPort(engine string) int {
+	if engine == EngineMySQL {
+		return 3306
+	}
+	return 5432
+}
+
+// CreateDatabase returns the script that creates a database and, when user is set, a user
+// owning it
+func CreateDatabase(engine, name, user, password string) (Script, error) {
+	if err := ValidateEngine(engine); err != nil {
+		return Script{}, err
+	}
+	if err := ValidateIdentifier(name); err != nil {
+		return Script{}, err
+	}
+	if user != "" {
+		if err := ValidateIdentifier(user); err != nil {
+			return Script{}, err
+		}
+		if password == "" {
+			return Script{}, fmt.Errorf("a password is required for user '%s'", user)
+		}
+	}
+
+	var sql strings.Builder
+	script := Script{Vars: map[string]string{}}
+	switch engine {
+	case EnginePostgres:
+		if user != "" {
+			script.Vars["password"] = password
+			fmt.Fprintf(&sql, "CREATE USER \"%s\" WITH PASSWORD :'password';\n", user)
+			fmt.Fprintf(&sql, "CREATE DATABASE \"%s\" OWNER \"%s\";\n", name, user)
+		} else {
+			fmt.Fprintf(&sql, "CREATE DATABASE \"%s\";\n", name)
+		}
+	case EngineMySQL:
+		fmt.Fprintf(&sql, "CREATE DATABASE `%s`;\n", name)
+		if user != "" {
+			fmt.Fprintf(&sql, "CREATE USER IF NOT EXISTS '%s'@'%%' IDENTIFIED BY %s;\n", user, mysqlLiteral(password))
+			fmt.Fprintf(&sql, "GRANT ALL PRIVILEGES ON `%s`.* TO '%s'@'%%';\n", name, user)
+			sql.WriteString("FLUSH PRIVILEGES;\n")
+		}
+	}
+	script.SQL = sql.String()
+	return script, nil
+}
+
+// Query returns the script that runs sql with params bound to it. PostgreSQL refers to the
+// parameters as :'p1', :'p2', ...; MySQL as @p1, @p2, ...
+func Query(engine, sql string, params []string) (Script, error) {
+	if err := ValidateEngine(engine); err != nil {
+		return Script{}, err
+	}
+	script := Script{Vars: map[string]string{}}
+	var prefix strings.Builder
+	for i, param := range params {
+		name := fmt.Sprintf("p%d", i+1)
+		if engine == EnginePostgres {
+			script.Vars[name] = param
+		} else {
+			fmt.Fprintf(&prefix, "SET @%s = %s;\n", name, mysqlLiteral(param))
+		}
+	}
+	script.SQL = prefix.String() + sql
+	if !strings.HasSuffix(strings.TrimSpace(script.SQL), ";") {
+		script.SQL += ";"
+	}
+	script.SQL += "\n"
+	return script, nil
+}
+
+// Command returns the shell command that runs the script with the engine's client as the
+// database superuser, in database unless it is empty. PostgreSQL results are printed as CSV,
+// MySQL results tab separated.
+func (s Script) Command(engine, database string) string {
+	input := s.SQL
+	var client []string
+	switch engine {
+	case EngineMySQL:
+		client = []string{"sudo", "-n", "mysql", "--batch"}
+		if database != "" {
+			client = append(client, shellquote.Quote(database))
+		}
+	default:
+		// Variables are set on stdin rather than with -v so their values stay out of the
+		// guest's process list
+		names := make([]string, 0, len(s.Vars))
+		for name := range s.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var sets strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&sets, "\\set %s %s\n", name, psqlLiteral(s.Vars[name]))
+		}
+		input = sets.String() + input
+		// psql warns when it cannot read the working directory as the postgres user
+		client = []string{"cd", "/", "&&", "sudo", "-n", "-u", "postgres", "psql", "-X", "--csv", "-v", "ON_ERROR_STOP=1"}
+		if database != "" {
+			client = append(client, "-d", shellquote.Quote(database))
+		}
+	}
+	// base64 keeps the SQL out of reach of the shell
+	return fmt.Sprintf("echo %s | base64 -d | (%s)", base64.StdEncoding.EncodeToString([]byte(input)), strings.Join(client, " "))
+}
+
+// DumpCommand returns the shell command that writes an SQL dump of database to stdout
+func DumpCommand(engine, database string, schemaOnly bool) (string, error) {
+	if err := ValidateEngine(engine); err != nil {
+		return "", err
+	}
+	if err := ValidateIdentifier(database); err != nil {
+		return "", err
+	}
+	if engine == EngineMySQL {
+		command := "sudo -n mysqldump --single-transaction --routines --triggers"
+		if schemaOnly {
+			command += " --no-data"
+		}
+		return command + " " + shellquote.Quote(database), nil
+	}
+	command := "cd / && sudo -n -u postgres pg_dump --no-owner"
+	if schemaOnly {
+		command += " --schema-only"
+	}
+	return command + " " + shellquote.Quote(database), nil
+}
+
+// DumpAllCommand returns the shell command that writes an SQL dump of every database of the
+// engine's server, with its users and grants, to stdout
+func DumpAllCommand(engine string) (string, error) {
+	if err := ValidateEngine(engine); err != nil {
+		return "", err
+	}
+	if engine == EngineMySQL {
+		return "sudo -n mysqldump --all-databases --single-transaction --routines --triggers --events", nil
+	}
+	return "cd / && sudo -n -u postgres pg_dumpall --clean --if-exists", nil
+}
+
+// LoadCommand returns the shell command that runs an SQL dump read from stdin as the
+// database superuser, such as one written by DumpAllCommand
+func LoadCommand(engine string) (string, error) {
+	if err := ValidateEngine(engine); err != nil {
+		return "", err
+	}
+	if engine == EngineMySQL {
+		return "sudo -n mysql", nil
+	}
+	return "cd / && sudo -n -u postgres psql -q -X -d postgres", nil
+}
+
+// Dump runs a dump command in the VM over ssh and streams its output to w
+func Dump(ctx context.Context, vmName string, sshArgs []string, command string, w io.Writer) error {
+	var stderr bytes.Buffer
+	if err := cmdexec.RunSSH(ctx, cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: command, Stdout: w, Stderr: &stderr}); err != nil {
+		return fmt.Errorf("dump failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// psqlLiteral quotes s as a single quoted psql meta-command argument
+func psqlLiteral(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `''`, "\n", `\n`, "\r", `\r`)
+	return "'" + replacer.Replace(s) + "'"
+}
+
+// mysqlLiteral quotes s as a MySQL string literal
+func mysqlLiteral(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`, "\x1a", `\Z`)
+	return "'" + replacer.Replace(s) + "'"
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// decodeInput returns the stdin a command built by Script.Command feeds the client
+func decodeInput(t *testing.T, command string) string {
+	t.Helper()
+	encoded := strings.TrimPrefix(strings.SplitN(command, " |", 2)[0], "echo ")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode command input: %v", err)
+	}
+	return string(decoded)
+}
+
+func TestValidateIdentifier(t *testing.T) {
+	testCases := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "shop", valid: true},
+		{name: "_shop_2", valid: true},
+		{name: "", valid: false},
+		{name: "2shop", valid: false},
+		{name: "shop-db", valid: false},
+		{name: `shop"; DROP DATABASE x; --`, valid: false},
+		{name: strings.Repeat("a", 64), valid: false},
+	}
+
+	for _, tc := range testCases {
+		if err := ValidateIdentifier(tc.name); (err == nil) != tc.valid {
+			t.Errorf("Expected valid %v for '%s' but got error %v", tc.valid, tc.name, err)
+		}
+	}
+}
+
+func TestCreateDatabase(t *testing.T) {
+	testCases := []struct {
+		name     string
+		engine   string
+		user     string
+		password string
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name:     "postgres with user",
+			engine:   EnginePostgres,
+			user:     "app",
+			password: "it's secret",
+			contains: []string{`\set password 'it''s secret'`, `CREATE USER "app" WITH PASSWORD :'password';`, `CREATE DATABASE "shop" OWNER "app";`},
+		},
+		{
+			name:     "postgres without user",
+			engine:   EnginePostgres,
+			contains: []string{`CREATE DATABASE "shop";`},
+		},
+		{
+			name:     "mysql with user",
+			engine:   EngineMySQL,
+			user:     "app",
+			password: `it's \ secret`,
+			contains: []string{"CREATE DATABASE `shop`;", `IDENTIFIED BY 'it\'s \\ secret';`, "GRANT ALL PRIVILEGES ON `shop`.* TO 'app'@'%';"},
+		},
+		{
+			name:    "user without password",
+			engine:  EnginePostgres,
+			user:    "app",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported engine",
+			engine:  "sqlite",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			script, err := CreateDatabase(tc.engine, "shop", tc.user, tc.password)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("Expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			command := script.Command(tc.engine, "")
+			if strings.Contains(command, tc.password) && tc.password != "" {
+				t.Errorf("Expected the password to stay out of the command but got %s", command)
+			}
+			input := decodeInput(t, command)
+			for _, want := range tc.contains {
+				if !strings.Contains(input, want) {
+					t.Errorf("Expected input to contain %q but got:\n%s", want, input)
+				}
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	params := []string{"O'Brien", "42"}
+
+	script, err := Query(EnginePostgres, "SELECT * FROM users WHERE name = :'p1' AND age = :'p2'", params)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	command := script.Command(EnginePostgres, "shop")
+	if !strings.Contains(command, "psql -X --csv -v ON_ERROR_STOP=1 -d 'shop'") {
+		t.Errorf("Expected a psql command for database shop but got %s", command)
+	}
+	expected := "\\set p1 'O''Brien'\n\\set p2 '42'\nSELECT * FROM users WHERE name = :'p1' AND age = :'p2';\n"
+	if input := decodeInput(t, command); input != expected {
+		t.Errorf("Expected input %q but got %q", expected, input)
+	}
+
+	script, err = Query(EngineMySQL, "SELECT * FROM users WHERE name = @p1;", params)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	command = script.Command(EngineMySQL, "shop")
+	if !strings.Contains(command, "(sudo -n mysql --batch 'shop')") {
+		t.Errorf("Expected a mysql command for database shop but got %s", command)
+	}
+	expected = "SET @p1 = 'O\\'Brien';\nSET @p2 = '42';\nSELECT * FROM users WHERE name = @p1;\n"
+	if input := decodeInput(t, command); input != expected {
+		t.Errorf("Expected input %q but got %q", expected, input)
+	}
+}
+
+func TestDumpCommand(t *testing.T) {
+	testCases := []struct {
+		engine     string
+		schemaOnly bool
+		expected   string
+	}{
+		{engine: EnginePostgres, expected: "cd / && sudo -n -u postgres pg_dump --no-owner 'shop'"},
+		{engine: EnginePostgres, schemaOnly: true, expected: "cd / && sudo -n -u postgres pg_dump --no-owner --schema-only 'shop'"},
+		{engine: EngineMySQL, expected: "sudo -n mysqldump --single-transaction --routines --triggers 'shop'"},
+		{engine: EngineMySQL, schemaOnly: true, expected: "sudo -n mysqldump --single-transaction --routines --triggers --no-data 'shop'"},
+	}
+
+	for _, tc := range testCases {
+		command, err := DumpCommand(tc.engine, "shop", tc.schemaOnly)
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		if command != tc.expected {
+			t.Errorf("Expected %q but got %q", tc.expected, command)
+		}
+	}
+	if _, err := DumpCommand(EnginePostgres, "shop; rm -rf /", false); err == nil {
+		t.Error("Expected an error for an invalid database name but got none")
+	}
+}
@@ -22,6 +22,7 @@ var (
 	ErrDependencyMissing = errors.New("dependency is missing")
 	ErrInvalidState      = errors.New("invalid state for operation")
 	ErrValidationFailed  = errors.New("validation failed")
+	ErrConflict          = errors.New("changed concurrently")
 )
 
 // ErrorCode represents specific error codes for better error handling
@@ -44,6 +45,7 @@ const (
 	CodeVMError           ErrorCode = "vm_error"
 	CodeSyncError         ErrorCode = "sync_error"
 	CodeExecError         ErrorCode = "exec_error"
+	CodeConflict          ErrorCode = "conflict"
 )
 
 // AppError represents an application-specific error with context
@@ -140,6 +142,21 @@ func OperationFailed(operation string, err error) *AppError {
 	}
 }
 
+// Conflict creates a new error for a change based on an outdated revision of a resource
+func Conflict(resourceType, identifier string, expected, actual int) *AppError {
+	return &AppError{
+		Code:    CodeConflict,
+		Message: fmt.Sprintf("%s '%s' is at revision %d, not %d; read it again and retry", resourceType, identifier, actual, expected),
+		Err:     ErrConflict,
+		Context: map[string]interface{}{
+			"resourceType": resourceType,
+			"identifier":   identifier,
+			"expected":     expected,
+			"actual":       actual,
+		},
+	}
+}
+
 // IsNotFound checks if the error is a not found error
 func IsNotFound(err error) bool {
 	return Is(err, CodeNotFound) || errors.Is(err, ErrNotFound)
@@ -150,6 +167,11 @@ func IsAlreadyExists(err error) bool {
 	return Is(err, CodeAlreadyExists) || errors.Is(err, ErrAlreadyExists)
 }
 
+// IsConflict checks if the error is a conflicting concurrent change
+func IsConflict(err error) bool {
+	return Is(err, CodeConflict) || errors.Is(err, ErrConflict)
+}
+
 // Is checks if the error is of the specified code
 func Is(err error, code ErrorCode) bool {
 	var appErr *AppError
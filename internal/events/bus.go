@@ -0,0 +1,136 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package events provides an in-process event bus for server notifications
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of event published on the bus
+type Type string
+
+const (
+	// VMStateChanged is published when a VM lifecycle operation changes its state
+	VMStateChanged Type = "vm.state_changed"
+	// SyncCompleted is published when a sync operation finishes
+	SyncCompleted Type = "sync.completed"
+	// ConflictDetected is published when a sync conflict is recorded
+	ConflictDetected Type = "sync.conflict_detected"
+	// ConflictResolved is published when a sync conflict is resolved
+	ConflictResolved Type = "sync.conflict_resolved"
+	// WatcherError is published when a file watcher reports an error
+	WatcherError Type = "sync.watcher_error"
+	// ApprovalRequested is published when a destructive operation is parked for approval
+	ApprovalRequested Type = "approval.requested"
+	// ApprovalResolved is published when a parked operation is approved or rejected
+	ApprovalResolved Type = "approval.resolved"
+	// VMExpiring is published when a VM's time to live is about to run out
+	VMExpiring Type = "vm.expiring"
+	// VMExpired is published when an expired VM is halted or destroyed
+	VMExpired Type = "vm.expired"
+	// VMOperationFailed is published when booting or reloading a VM fails, e.g. in provisioning
+	VMOperationFailed Type = "vm.operation_failed"
+	// TaskRan is published when a scheduled task runs, fails or is skipped
+	TaskRan Type = "schedule.task_ran"
+)
+
+// Event represents a single server event
+type Event struct {
+	ID        uint64                 `json:"id"`
+	Type      Type                   `json:"type"`
+	VMName    string                 `json:"vm_name,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// Handler is a function that receives published events
+type Handler func(Event)
+
+// Bus distributes events to subscribers and keeps a bounded history
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[int]Handler
+	nextSubID   int
+	nextEventID uint64
+	history     []Event
+	historySize int
+}
+
+// NewBus creates a new event bus keeping at most historySize events
+func NewBus(historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = 100
+	}
+	return &Bus{
+		subscribers: make(map[int]Handler),
+		historySize: historySize,
+	}
+}
+
+// Publish records an event and delivers it to all subscribers
+func (b *Bus) Publish(eventType Type, vmName string, data map[string]interface{}) Event {
+	b.mu.Lock()
+	b.nextEventID++
+	event := Event{
+		ID:        b.nextEventID,
+		Type:      eventType,
+		VMName:    vmName,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+	handlers := make([]Handler, 0, len(b.subscribers))
+	for _, handler := range b.subscribers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.Unlock()
+
+	// Deliver outside the lock so handlers may publish or subscribe
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return event
+}
+
+// Subscribe registers a handler and returns a function that removes it
+func (b *Bus) Subscribe(handler Handler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextSubID
+	b.nextSubID++
+	b.subscribers[id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Recent returns events with an ID greater than sinceID, optionally filtered by VM name
+func (b *Bus) Recent(sinceID uint64, vmName string) []Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := []Event{}
+	for _, event := range b.history {
+		if event.ID <= sinceID {
+			continue
+		}
+		if vmName != "" && event.VMName != vmName {
+			continue
+		}
+		result = append(result, event)
+	}
+	return result
+}
+
+// Global event bus instance
+var GlobalBus = NewBus(256)
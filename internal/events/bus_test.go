@@ -0,0 +1,88 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	bus := NewBus(10)
+
+	var received []Event
+	unsubscribe := bus.Subscribe(func(e Event) {
+		received = append(received, e)
+	})
+
+	bus.Publish(VMStateChanged, "test-vm", map[string]interface{}{"state": "running"})
+	bus.Publish(SyncCompleted, "test-vm", nil)
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 events but got %d", len(received))
+	}
+	if received[0].Type != VMStateChanged {
+		t.Errorf("Expected first event type %s but got %s", VMStateChanged, received[0].Type)
+	}
+
+	unsubscribe()
+	bus.Publish(WatcherError, "test-vm", nil)
+
+	if len(received) != 2 {
+		t.Errorf("Expected no events after unsubscribe but got %d total", len(received))
+	}
+}
+
+func TestBus_Recent(t *testing.T) {
+	testCases := []struct {
+		name          string
+		sinceID       uint64
+		vmName        string
+		expectedCount int
+	}{
+		{
+			name:          "all events",
+			sinceID:       0,
+			vmName:        "",
+			expectedCount: 3,
+		},
+		{
+			name:          "events after id",
+			sinceID:       2,
+			vmName:        "",
+			expectedCount: 1,
+		},
+		{
+			name:          "filter by vm",
+			sinceID:       0,
+			vmName:        "vm-a",
+			expectedCount: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bus := NewBus(10)
+			bus.Publish(VMStateChanged, "vm-a", nil)
+			bus.Publish(VMStateChanged, "vm-b", nil)
+			bus.Publish(SyncCompleted, "vm-a", nil)
+
+			events := bus.Recent(tc.sinceID, tc.vmName)
+			if len(events) != tc.expectedCount {
+				t.Errorf("Expected %d events but got %d", tc.expectedCount, len(events))
+			}
+		})
+	}
+}
+
+func TestBus_HistoryBounded(t *testing.T) {
+	bus := NewBus(2)
+	for i := 0; i < 5; i++ {
+		bus.Publish(SyncCompleted, "test-vm", nil)
+	}
+
+	events := bus.Recent(0, "")
+	if len(events) != 2 {
+		t.Fatalf("Expected history of 2 events but got %d", len(events))
+	}
+	if events[0].ID != 4 || events[1].ID != 5 {
+		t.Errorf("Expected most recent events 4 and 5 but got %d and %d", events[0].ID, events[1].ID)
+	}
+}
@@ -5,11 +5,12 @@ package exec
 
 import (
 	"context"
-	"fmt"
-	"os/exec"
+	"time"
 
 	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/hostos"
 	syncmod "github.com/vagrant-mcp/server/internal/sync"
+	"github.com/vagrant-mcp/server/internal/tracing"
 	"github.com/vagrant-mcp/server/internal/vm"
 )
 
@@ -29,6 +30,9 @@ func (a *VMManagerAdapter) StartVM(ctx context.Context, name string) error {
 func (a *VMManagerAdapter) StopVM(ctx context.Context, name string) error {
 	return a.Real.StopVM(ctx, name)
 }
+func (a *VMManagerAdapter) SuspendVM(ctx context.Context, name string) error {
+	return a.Real.SuspendVM(ctx, name)
+}
 func (a *VMManagerAdapter) DestroyVM(ctx context.Context, name string) error {
 	return a.Real.DestroyVM(ctx, name)
 }
@@ -53,36 +57,70 @@ func (a *VMManagerAdapter) GetBaseDir() string {
 func (a *VMManagerAdapter) ListVMs(ctx context.Context) ([]string, error) {
 	return a.Real.ListVMs(ctx)
 }
+func (a *VMManagerAdapter) ListVMDetails(ctx context.Context) ([]vm.VMListing, error) {
+	return a.Real.ListVMDetails(ctx)
+}
+func (a *VMManagerAdapter) LastUsed(name string) (time.Time, bool) {
+	return a.Real.LastUsed(name)
+}
+func (a *VMManagerAdapter) ListVagrantEnvironments(ctx context.Context) ([]vm.VagrantEnvironment, error) {
+	return a.Real.ListVagrantEnvironments(ctx)
+}
+func (a *VMManagerAdapter) DestroyEnvironment(ctx context.Context, environment vm.VagrantEnvironment) (vm.EnvironmentRemoval, error) {
+	return a.Real.DestroyEnvironment(ctx, environment)
+}
+func (a *VMManagerAdapter) CurrentOperation(name string) (vm.Operation, bool) {
+	return a.Real.CurrentOperation(name)
+}
+func (a *VMManagerAdapter) ConnectVMs(ctx context.Context, network string, vmNames []string) (vm.Network, error) {
+	return a.Real.ConnectVMs(ctx, network, vmNames)
+}
+func (a *VMManagerAdapter) SetResources(ctx context.Context, name string, cpu, memory int) (core.VMConfig, core.VMConfig, error) {
+	return a.Real.SetResources(ctx, name, cpu, memory)
+}
+func (a *VMManagerAdapter) ResizeDisk(ctx context.Context, name string, sizeGB int) (core.VMConfig, error) {
+	return a.Real.ResizeDisk(ctx, name, sizeGB)
+}
+func (a *VMManagerAdapter) ProvisionVM(ctx context.Context, name string, provisioners []string) (vm.ProvisionResult, error) {
+	return a.Real.ProvisionVM(ctx, name, provisioners)
+}
+func (a *VMManagerAdapter) GetVMInfo(ctx context.Context, name string) (vm.VMInfo, error) {
+	return a.Real.GetVMInfo(ctx, name)
+}
+func (a *VMManagerAdapter) SetFirewall(ctx context.Context, name string, firewall *core.Firewall) (core.VMConfig, error) {
+	return a.Real.SetFirewall(ctx, name, firewall)
+}
+func (a *VMManagerAdapter) SetDotfiles(ctx context.Context, name string, dotfiles *core.Dotfiles) (core.VMConfig, error) {
+	return a.Real.SetDotfiles(ctx, name, dotfiles)
+}
+func (a *VMManagerAdapter) SetSSHAgentForwarding(ctx context.Context, name string, enabled bool) (core.VMConfig, error) {
+	return a.Real.SetSSHAgentForwarding(ctx, name, enabled)
+}
+func (a *VMManagerAdapter) SetVagrantfileSnippets(ctx context.Context, name string, snippets []core.VagrantfileSnippet) (core.VMConfig, error) {
+	return a.Real.SetVagrantfileSnippets(ctx, name, snippets)
+}
+func (a *VMManagerAdapter) AdoptVM(ctx context.Context, name, vagrantDir, machine string) (core.VMConfig, error) {
+	return a.Real.AdoptVM(ctx, name, vagrantDir, machine)
+}
+func (a *VMManagerAdapter) BakeBaseImage(ctx context.Context, vmName, boxName string, setDefault bool) (vm.BaseImage, error) {
+	return a.Real.BakeBaseImage(ctx, vmName, boxName, setDefault)
+}
+func (a *VMManagerAdapter) GetBaseImage() (*vm.BaseImage, error) {
+	return a.Real.GetBaseImage()
+}
+func (a *VMManagerAdapter) PackageCacheDir() string {
+	return a.Real.PackageCacheDir()
+}
+func (a *VMManagerAdapter) PackageCacheUsage() (map[string]int64, error) {
+	return a.Real.PackageCacheUsage()
+}
+func (a *VMManagerAdapter) ClearPackageCache(name string) (int64, error) {
+	return a.Real.ClearPackageCache(name)
+}
 
-// ExecuteCommand runs a command in the VM using SSH
+// ExecuteCommand runs a command in the VM
 func (a *VMManagerAdapter) ExecuteCommand(ctx context.Context, name string, cmd string, args []string, workingDir string) (string, string, int, error) {
-	sshConfig, err := a.Real.GetSSHConfig(ctx, name)
-	if err != nil {
-		return "", "", 1, err
-	}
-	sshArgs := []string{
-		"-p", sshConfig["Port"],
-		"-i", sshConfig["IdentityFile"],
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		fmt.Sprintf("%s@%s", sshConfig["User"], sshConfig["HostName"]),
-	}
-	fullCmd := cmd
-	if workingDir != "" {
-		fullCmd = fmt.Sprintf("cd %s && %s", workingDir, cmd)
-	}
-	sshArgs = append(sshArgs, fullCmd)
-	c := exec.CommandContext(ctx, "ssh", sshArgs...)
-	out, err := c.CombinedOutput()
-	exitCode := 0
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		} else {
-			exitCode = 1
-		}
-	}
-	return string(out), "", exitCode, err
+	return a.Real.ExecuteCommand(ctx, name, cmd, args, workingDir)
 }
 
 // SyncEngineAdapter adapts *sync.Engine to the core.SyncEngine interface
@@ -92,38 +130,60 @@ type SyncEngineAdapter struct {
 }
 
 func (a *SyncEngineAdapter) RegisterVM(ctx context.Context, vmName string, config core.SyncConfig) error {
-	mapped := syncmod.SyncConfig{
-		VMName:          config.VMName,
-		ProjectPath:     config.ProjectPath,
-		Method:          syncmod.SyncMethod(config.Method),
-		Direction:       syncmod.SyncDirection(config.Direction),
-		ExcludePatterns: config.ExcludePatterns,
-		WatchEnabled:    config.WatchEnabled,
-		WatchInterval:   config.WatchInterval,
-	}
-	return a.Real.RegisterVM(vmName, mapped)
+	return a.Real.RegisterVM(vmName, toSyncConfig(config))
 }
 func (a *SyncEngineAdapter) UnregisterVM(ctx context.Context, vmName string) error {
 	return a.Real.UnregisterVM(vmName)
 }
+func (a *SyncEngineAdapter) SyncPendingToVM(ctx context.Context, vmName string) (*core.SyncResult, error) {
+	_, span := tracing.Start(ctx, "sync pending to_vm", tracing.String("vm.name", vmName), tracing.String("sync.direction", "to_vm"))
+	r, err := a.Real.SyncPendingToVM(ctx, vmName)
+	if r != nil {
+		span.SetAttributes(tracing.Int("sync.files", len(r.SyncedFiles)), tracing.Int("sync.duration_ms", r.SyncTimeMs))
+	}
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+	return &core.SyncResult{
+		SyncedFiles:    r.SyncedFiles,
+		SyncTimeMs:     r.SyncTimeMs,
+		ReportArtifact: r.ReportArtifact,
+		Conflicts:      r.Conflicts,
+	}, nil
+}
 func (a *SyncEngineAdapter) SyncToVM(ctx context.Context, vmName string, sourcePath string) (*core.SyncResult, error) {
-	r, err := a.Real.SyncToVM(vmName, sourcePath)
+	_, span := tracing.Start(ctx, "sync to_vm", tracing.String("vm.name", vmName), tracing.String("sync.direction", "to_vm"))
+	r, err := a.Real.SyncToVM(ctx, vmName, sourcePath)
+	if r != nil {
+		span.SetAttributes(tracing.Int("sync.files", len(r.SyncedFiles)), tracing.Int("sync.duration_ms", r.SyncTimeMs))
+	}
+	span.End(err)
 	if err != nil {
 		return nil, err
 	}
 	return &core.SyncResult{
-		SyncedFiles: r.SyncedFiles,
-		SyncTimeMs:  r.SyncTimeMs,
+		SyncedFiles:    r.SyncedFiles,
+		SyncTimeMs:     r.SyncTimeMs,
+		ReportArtifact: r.ReportArtifact,
+		Conflicts:      r.Conflicts,
 	}, nil
 }
 func (a *SyncEngineAdapter) SyncFromVM(ctx context.Context, vmName string, sourcePath string) (*core.SyncResult, error) {
-	r, err := a.Real.SyncFromVM(vmName, sourcePath)
+	_, span := tracing.Start(ctx, "sync from_vm", tracing.String("vm.name", vmName), tracing.String("sync.direction", "from_vm"))
+	r, err := a.Real.SyncFromVM(ctx, vmName, sourcePath)
+	if r != nil {
+		span.SetAttributes(tracing.Int("sync.files", len(r.SyncedFiles)), tracing.Int("sync.duration_ms", r.SyncTimeMs))
+	}
+	span.End(err)
 	if err != nil {
 		return nil, err
 	}
 	return &core.SyncResult{
-		SyncedFiles: r.SyncedFiles,
-		SyncTimeMs:  r.SyncTimeMs,
+		SyncedFiles:    r.SyncedFiles,
+		SyncTimeMs:     r.SyncTimeMs,
+		ReportArtifact: r.ReportArtifact,
+		Conflicts:      r.Conflicts,
 	}, nil
 }
 func (a *SyncEngineAdapter) GetSyncStatus(ctx context.Context, vmName string) (core.SyncStatus, error) {
@@ -143,40 +203,117 @@ func (a *SyncEngineAdapter) GetSyncStatus(ctx context.Context, vmName string) (c
 		}
 	}
 	return core.SyncStatus{
-		LastSyncTime:         s.LastSyncTime,
-		InProgress:           s.InProgress,
-		Conflicts:            conflicts,
-		SynchronizedFiles:    s.SynchronizedFiles,
-		Error:                s.Error,
-		LastSyncToVM:         s.LastSyncToVM,
-		LastSyncFromVM:       s.LastSyncFromVM,
-		FilesPendingUpload:   s.FilesPendingUpload,
-		FilesPendingDownload: s.FilesPendingDownload,
-		TotalSyncs:           s.TotalSyncs,
-		TotalFilesSynced:     s.TotalFilesSynced,
-		TotalSyncTimeMs:      s.TotalSyncTimeMs,
+		LastSyncTime:             s.LastSyncTime,
+		InProgress:               s.InProgress,
+		Conflicts:                conflicts,
+		SynchronizedFiles:        s.SynchronizedFiles,
+		Error:                    s.Error,
+		LastSyncToVM:             s.LastSyncToVM,
+		LastSyncFromVM:           s.LastSyncFromVM,
+		FilesPendingUpload:       s.FilesPendingUpload,
+		FilesPendingDownload:     s.FilesPendingDownload,
+		TotalSyncs:               s.TotalSyncs,
+		TotalFilesSynced:         s.TotalFilesSynced,
+		TotalSyncTimeMs:          s.TotalSyncTimeMs,
+		QueuedSyncs:              s.QueuedSyncs,
+		PendingUploadTruncated:   s.PendingUploadTruncated,
+		PendingDownloadTruncated: s.PendingDownloadTruncated,
+		LastGuestScan:            s.LastGuestScan,
+		Progress:                 convertSyncProgress(s.Progress),
 	}, nil
 }
+
+// convertSyncProgress converts a sync progress report to its core form
+func convertSyncProgress(p *syncmod.SyncProgress) *core.SyncProgress {
+	if p == nil {
+		return nil
+	}
+	return &core.SyncProgress{
+		Percent:          p.Percent,
+		BytesTransferred: p.BytesTransferred,
+		Rate:             p.Rate,
+		ETASeconds:       p.ETASeconds,
+		FilesTransferred: p.FilesTransferred,
+		FilesToCheck:     p.FilesToCheck,
+		TotalFiles:       p.TotalFiles,
+		UpdatedAt:        p.UpdatedAt,
+	}
+}
+
+// OnSyncProgress calls listener with each progress report of the syncs of a VM until the
+// returned function is called
+func (a *SyncEngineAdapter) OnSyncProgress(vmName string, listener func(core.SyncProgress)) func() {
+	return a.Real.OnSyncProgress(vmName, func(p syncmod.SyncProgress) {
+		listener(*convertSyncProgress(&p))
+	})
+}
 func (a *SyncEngineAdapter) GetSyncConfig(ctx context.Context, vmName string) (core.SyncConfig, error) {
-	// No direct method in sync.Engine; return a minimal config for now
-	return core.SyncConfig{VMName: vmName}, nil
+	c, err := a.Real.GetSyncConfig(vmName)
+	if err != nil {
+		return core.SyncConfig{}, err
+	}
+	overrides := make([]core.ConflictPolicyOverride, len(c.ConflictPolicyOverrides))
+	for i, o := range c.ConflictPolicyOverrides {
+		overrides[i] = core.ConflictPolicyOverride{Pattern: o.Pattern, Policy: string(o.Policy)}
+	}
+	return core.SyncConfig{
+		VMName:                  c.VMName,
+		ProjectPath:             c.ProjectPath,
+		Method:                  core.SyncMethod(c.Method),
+		Direction:               core.SyncDirection(c.Direction),
+		ExcludePatterns:         c.ExcludePatterns,
+		WatchEnabled:            c.WatchEnabled,
+		WatchInterval:           c.WatchInterval,
+		WatchMode:               string(c.WatchMode),
+		WatchDepth:              c.WatchDepth,
+		ConflictPolicy:          string(c.ConflictPolicy),
+		ConflictPolicyOverrides: overrides,
+		ConflictStyle:           string(c.ConflictStyle),
+	}, nil
 }
 func (a *SyncEngineAdapter) UpdateSyncConfig(ctx context.Context, vmName string, config core.SyncConfig) error {
-	// No direct method in sync.Engine; simulate by unregistering and re-registering
-	err := a.Real.UnregisterVM(vmName)
+	return a.Real.UpdateSyncConfig(vmName, toSyncConfig(config))
+}
+func (a *SyncEngineAdapter) GetSyncJournal(ctx context.Context, vmName string) ([]core.SyncJournalEntry, error) {
+	j, err := a.Real.GetSyncJournal(vmName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	mapped := syncmod.SyncConfig{
-		VMName:          config.VMName,
-		ProjectPath:     config.ProjectPath,
-		Method:          syncmod.SyncMethod(config.Method),
-		Direction:       syncmod.SyncDirection(config.Direction),
-		ExcludePatterns: config.ExcludePatterns,
-		WatchEnabled:    config.WatchEnabled,
-		WatchInterval:   config.WatchInterval,
+	entries := make([]core.SyncJournalEntry, len(j))
+	for i, e := range j {
+		entries[i] = core.SyncJournalEntry{
+			Time:       e.Time,
+			Operation:  e.Operation,
+			Path:       e.Path,
+			Policy:     string(e.Policy),
+			Resolution: e.Resolution,
+			FileCount:  e.FileCount,
+			Message:    e.Message,
+		}
 	}
-	return a.Real.RegisterVM(vmName, mapped)
+	return entries, nil
+}
+func (a *SyncEngineAdapter) RecordGuestChanges(ctx context.Context, vmName string, paths []string, scannedAt time.Time) error {
+	return a.Real.RecordGuestChanges(vmName, paths, scannedAt)
+}
+func (a *SyncEngineAdapter) GetWatcherStats(ctx context.Context, vmName string) (*core.WatcherStats, error) {
+	s, err := a.Real.GetWatcherStats(vmName)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &core.WatcherStats{
+		Mode:        string(s.Mode),
+		Strategy:    s.Strategy,
+		WatchedDirs: s.WatchedDirs,
+		PolledRoots: s.PolledRoots,
+		PolledPaths: s.PolledPaths,
+		WatchLimit:  s.WatchLimit,
+		Events:      s.Events,
+		Batches:     s.Batches,
+		Overflows:   s.Overflows,
+		LastError:   s.LastError,
+		StartedAt:   s.StartedAt,
+	}, nil
 }
 func (a *SyncEngineAdapter) SemanticSearch(ctx context.Context, vmName string, query string, maxResults int) ([]core.SearchResult, error) {
 	r, err := a.Real.SemanticSearch(vmName, query, maxResults)
@@ -230,13 +367,91 @@ func (a *SyncEngineAdapter) Start(ctx context.Context) error { return nil }
 func (a *SyncEngineAdapter) Stop(ctx context.Context) error  { return nil }
 func (a *SyncEngineAdapter) IsRunning() bool                 { return true }
 func (a *SyncEngineAdapter) ResolveSyncConflict(ctx context.Context, vmName string, path string, resolution string) error {
-	return a.Real.ResolveSyncConflict(vmName, path, resolution)
+	return a.Real.ResolveSyncConflict(ctx, vmName, path, resolution)
+}
+func (a *SyncEngineAdapter) ResolveSyncConflictWithContent(ctx context.Context, vmName string, path string, content string) error {
+	return a.Real.ResolveSyncConflictWithContent(ctx, vmName, path, content)
+}
+func (a *SyncEngineAdapter) GetConflictDetails(ctx context.Context, vmName string) ([]core.ConflictDetail, error) {
+	d, err := a.Real.GetConflictDetails(vmName)
+	if err != nil {
+		return nil, err
+	}
+	details := make([]core.ConflictDetail, len(d))
+	for i, v := range d {
+		details[i] = core.ConflictDetail{
+			SyncConflict: core.SyncConflict{
+				Path:         v.Path,
+				HostModTime:  v.HostModTime,
+				VMModTime:    v.VMModTime,
+				HostContent:  v.HostContent,
+				VMContent:    v.VMContent,
+				ConflictType: v.ConflictType,
+			},
+			RelativePath:  v.RelativePath,
+			Policy:        string(v.Policy),
+			HasBase:       v.HasBase,
+			Diff:          v.Diff,
+			DiffTruncated: v.DiffTruncated,
+			Error:         v.Error,
+		}
+	}
+	return details, nil
+}
+
+func (a *VMManagerAdapter) SyncToVM(name, source, target string, excludes []string) error {
+	return a.Real.SyncToVM(name, source, target, excludes)
+}
+
+func (a *VMManagerAdapter) SyncFromVM(name, source, target string, excludes []string) error {
+	return a.Real.SyncFromVM(name, source, target, excludes)
+}
+
+func (a *VMManagerAdapter) SyncToVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error {
+	return a.Real.SyncToVMWithProgress(name, source, target, excludes, progress)
 }
 
-func (a *VMManagerAdapter) SyncToVM(name, source, target string) error {
-	return a.Real.SyncToVM(name, source, target)
+func (a *VMManagerAdapter) SyncFromVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error {
+	return a.Real.SyncFromVMWithProgress(name, source, target, excludes, progress)
 }
 
-func (a *VMManagerAdapter) SyncFromVM(name, source, target string) error {
-	return a.Real.SyncFromVM(name, source, target)
+func (a *VMManagerAdapter) ReadGuestFiles(name string, paths []string) (map[string]syncmod.GuestFileVersion, error) {
+	files, err := a.Real.ReadGuestFiles(context.Background(), name, paths)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]syncmod.GuestFileVersion, len(files))
+	for path, file := range files {
+		versions[path] = syncmod.GuestFileVersion{Content: file.Content, ModTime: file.ModTime}
+	}
+	return versions, nil
+}
+
+func (a *VMManagerAdapter) CheckSyncMount(name string) error {
+	return a.Real.CheckSyncMount(name)
+}
+
+// toSyncConfig maps a core sync config to the sync engine's config type
+func toSyncConfig(config core.SyncConfig) syncmod.SyncConfig {
+	var overrides []syncmod.ConflictPolicyOverride
+	if config.ConflictPolicyOverrides != nil {
+		overrides = make([]syncmod.ConflictPolicyOverride, len(config.ConflictPolicyOverrides))
+		for i, o := range config.ConflictPolicyOverrides {
+			overrides[i] = syncmod.ConflictPolicyOverride{Pattern: o.Pattern, Policy: syncmod.ConflictPolicy(o.Policy)}
+		}
+	}
+	return syncmod.SyncConfig{
+		VMName:                  config.VMName,
+		ProjectPath:             config.ProjectPath,
+		Method:                  syncmod.SyncMethod(config.Method),
+		Direction:               syncmod.SyncDirection(config.Direction),
+		ExcludePatterns:         config.ExcludePatterns,
+		WatchEnabled:            config.WatchEnabled,
+		WatchInterval:           config.WatchInterval,
+		WatchMode:               syncmod.WatchMode(config.WatchMode),
+		WatchDepth:              config.WatchDepth,
+		ConflictPolicy:          syncmod.ConflictPolicy(config.ConflictPolicy),
+		ConflictPolicyOverrides: overrides,
+		ConflictStyle:           syncmod.ConflictStyle(config.ConflictStyle),
+	}
 }
@@ -9,14 +9,23 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/artifacts"
+	"github.com/vagrant-mcp/server/internal/audit"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
 	"github.com/vagrant-mcp/server/internal/core"
 	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/hostos"
+	"github.com/vagrant-mcp/server/internal/secrets"
+	"github.com/vagrant-mcp/server/internal/tracing"
+	"github.com/vagrant-mcp/server/internal/vm"
 )
 
 // CommandResult contains the result of a command execution
@@ -25,6 +34,13 @@ type CommandResult struct {
 	Stdout   string  `json:"stdout"`
 	Stderr   string  `json:"stderr"`
 	Duration float64 `json:"duration_seconds"`
+	// Truncation is set when stdout or stderr exceeded the capture limit
+	Truncation *Truncation `json:"truncation,omitempty"`
+	// SyncBefore and SyncAfter are the results of the syncs run before and after the command
+	SyncBefore *core.SyncResult `json:"sync_before,omitempty"`
+	SyncAfter  *core.SyncResult `json:"sync_after,omitempty"`
+	// SyncAfterError is set when the sync after the command failed
+	SyncAfterError string `json:"sync_after_error,omitempty"`
 }
 
 // ExecutionContext contains the context for command execution
@@ -34,8 +50,43 @@ type ExecutionContext struct {
 	Environment map[string]string `json:"environment"`
 	SyncBefore  bool              `json:"sync_before"`
 	SyncAfter   bool              `json:"sync_after"`
+	// SyncMode selects what SyncBefore syncs; empty means SyncModeChanged
+	SyncMode SyncMode `json:"sync_mode"`
+	// Timeout bounds the command's run time; zero uses the executor's maximum
+	Timeout time.Duration `json:"timeout"`
+	// AuditCommand is recorded in the audit log in place of a command that carries secrets
+	AuditCommand string `json:"-"`
+	// MaxOutputBytes lowers the executor's capture limit for each output stream; zero uses it
+	MaxOutputBytes int `json:"max_output_bytes"`
+	// SpillOutput keeps the full output of truncated streams as artifacts
+	SpillOutput bool `json:"spill_output"`
+	// ForwardAgent forwards the host's SSH agent to the command even when the VM does not
+	// forward it by default
+	ForwardAgent bool `json:"forward_agent"`
+	// PolicyCommand is the command a client asked to run, checked against the exec policy
+	// before anything runs; it is empty for the commands the server composes itself
+	PolicyCommand string `json:"-"`
+	// PolicyDir is the guest directory PolicyCommand runs in; empty means WorkingDir
+	PolicyDir string `json:"-"`
 }
 
+// SyncMode selects what is synced to the VM before a command
+type SyncMode string
+
+const (
+	// SyncModeChanged only syncs the host files changed since the last sync, and nothing when
+	// the project is clean
+	SyncModeChanged SyncMode = "changed"
+	// SyncModeFull syncs the whole project
+	SyncModeFull SyncMode = "full"
+)
+
+// DefaultMaxTimeout is the longest a command may run unless MCP_EXEC_MAX_TIMEOUT says otherwise
+const DefaultMaxTimeout = 30 * time.Minute
+
+// remotePIDDir holds the files recording the process group of running commands in the VM
+const remotePIDDir = "/tmp/vagrant-mcp/exec"
+
 // OutputCallback is a function called with command output
 type OutputCallback func(data []byte, isStderr bool)
 
@@ -44,28 +95,84 @@ type OutputCallback func(data []byte, isStderr bool)
 type Executor struct {
 	vmManager  core.VMManager
 	syncEngine core.SyncEngine
-	mu         sync.Mutex
+	// mu guards vmSlots, which holds a one-command semaphore per VM
+	mu      sync.Mutex
+	vmSlots map[string]chan struct{}
+	// maxTimeout caps every command's run time; zero means no limit
+	maxTimeout time.Duration
+	// maxOutputBytes caps how much of each output stream is kept; zero means no limit
+	maxOutputBytes int
 }
 
 // NewExecutor creates a new command executor
 func NewExecutor(vmManager core.VMManager, syncEngine core.SyncEngine) (*Executor, error) {
+	maxTimeout := DefaultMaxTimeout
+	if value := os.Getenv("MCP_EXEC_MAX_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed >= 0 {
+			maxTimeout = parsed
+		} else {
+			log.Warn().Str("value", value).Msg("Ignoring invalid MCP_EXEC_MAX_TIMEOUT")
+		}
+	}
+	maxOutputBytes := DefaultMaxOutputBytes
+	if value := os.Getenv("MCP_EXEC_MAX_OUTPUT_BYTES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			maxOutputBytes = parsed
+		} else {
+			log.Warn().Str("value", value).Msg("Ignoring invalid MCP_EXEC_MAX_OUTPUT_BYTES")
+		}
+	}
 	return &Executor{
-		vmManager:  vmManager,
-		syncEngine: syncEngine,
+		vmManager:      vmManager,
+		syncEngine:     syncEngine,
+		maxTimeout:     maxTimeout,
+		maxOutputBytes: maxOutputBytes,
 	}, nil
 }
 
+// MaxTimeout returns the longest a command may run, or zero when there is no limit
+func (e *Executor) MaxTimeout() time.Duration {
+	return e.maxTimeout
+}
+
+// MaxOutputBytes returns how much of each output stream is kept, or zero when there is no limit
+func (e *Executor) MaxOutputBytes() int {
+	return e.maxOutputBytes
+}
+
+// effectiveOutputLimit returns the capture limit for a command given the requested one
+func (e *Executor) effectiveOutputLimit(requested int) int {
+	if requested <= 0 || (e.maxOutputBytes > 0 && requested > e.maxOutputBytes) {
+		return e.maxOutputBytes
+	}
+	return requested
+}
+
+// effectiveTimeout returns the timeout for a command given the requested one
+func (e *Executor) effectiveTimeout(requested time.Duration) time.Duration {
+	if requested <= 0 || (e.maxTimeout > 0 && requested > e.maxTimeout) {
+		return e.maxTimeout
+	}
+	return requested
+}
+
 // ExecuteCommand executes a command in a VM with the given context
 func (e *Executor) ExecuteCommand(ctx context.Context, command string, execCtx ExecutionContext, callback OutputCallback) (*CommandResult, error) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
 	// SAFEGUARD: Prevent execution on host or without VM context
 	if execCtx.VMName == "" || strings.ToLower(execCtx.VMName) == "host" {
 		errMsg := "SECURITY VIOLATION: Attempted to execute a shell command outside of a VM context. All commands must target a Vagrant VM."
 		log.Error().Msg(errMsg)
 		return nil, fmt.Errorf("%s", errMsg)
 	}
+	if execCtx.PolicyCommand != "" {
+		policyDir := execCtx.PolicyDir
+		if policyDir == "" {
+			policyDir = execCtx.WorkingDir
+		}
+		if err := GlobalPolicy.Check(execCtx.VMName, execCtx.PolicyCommand, policyDir); err != nil {
+			return nil, err
+		}
+	}
 
 	// Check if VM exists and is running
 	state, err := e.vmManager.GetVMState(ctx, execCtx.VMName)
@@ -76,40 +183,101 @@ func (e *Executor) ExecuteCommand(ctx context.Context, command string, execCtx E
 		return nil, errors.OperationFailed("VM is not running", nil)
 	}
 
-	// Perform pre-execution sync if requested
-	if execCtx.SyncBefore {
+	// Perform pre-execution sync if requested, with the VM's registered sync configuration
+	var syncBefore *core.SyncResult
+	if execCtx.SyncBefore && execCtx.SyncMode == SyncModeFull {
 		log.Info().Str("vm", execCtx.VMName).Msg("Syncing files to VM before command execution")
-		err := e.syncEngine.RegisterVM(ctx, execCtx.VMName, core.SyncConfig{})
+		syncBefore, err = e.syncEngine.SyncToVM(ctx, execCtx.VMName, "")
+		if err != nil {
+			return nil, errors.OperationFailed("sync files to VM", err)
+		}
+	} else if execCtx.SyncBefore {
+		syncBefore, err = e.syncEngine.SyncPendingToVM(ctx, execCtx.VMName)
 		if err != nil {
-			return nil, errors.OperationFailed("register VM for sync", err)
+			return nil, errors.OperationFailed("sync changed files to VM", err)
 		}
+		log.Info().Str("vm", execCtx.VMName).Int("files", len(syncBefore.SyncedFiles)).Msg("Synced changed files to VM before command execution")
 	}
 
-	// Execute command
+	// Execute command once the VM's previous command is done
+	release, err := e.lockVM(ctx, execCtx.VMName)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := tracing.Start(ctx, "exec", tracing.String("vm.name", execCtx.VMName), tracing.String("exec.command_name", tracing.CommandName(command)))
 	startTime := time.Now()
 	result, err := e.executeSSHCommand(ctx, command, execCtx, callback)
+	release()
 	duration := time.Since(startTime).Seconds()
 
 	// Set duration in result
+	exitCode := -1
 	if result != nil {
 		result.Duration = duration
+		result.SyncBefore = syncBefore
+		exitCode = result.ExitCode
+		span.SetAttributes(tracing.Int("exit_code", result.ExitCode))
 	}
+	span.End(err)
+	auditCommand := command
+	if execCtx.AuditCommand != "" {
+		auditCommand = execCtx.AuditCommand
+	}
+	audit.GlobalLog.RecordCommand(execCtx.VMName, auditCommand, execCtx.WorkingDir, execCtx.Environment, exitCode, time.Since(startTime), err)
 
 	// Handle execution error
 	if err != nil {
+		if errors.Is(err, errors.CodeTimeout) || errors.Is(err, errors.CodeCancelled) {
+			return result, err
+		}
 		return result, errors.OperationFailed("command execution failed", err)
 	}
 
-	// Perform post-execution sync if requested
+	// Perform post-execution sync if requested; the command ran, so a failed sync is reported
+	// in its result
 	if execCtx.SyncAfter {
 		log.Info().Str("vm", execCtx.VMName).Msg("Syncing files from VM after command execution")
-		// We don't actually need to do anything here since the RegisterVM above already set up the sync
-		// This would be handled by real syncing mechanisms in the actual implementation
+		if result.SyncAfter, err = e.syncEngine.SyncFromVM(ctx, execCtx.VMName, ""); err != nil {
+			log.Error().Err(err).Str("vm", execCtx.VMName).Msg("Failed to sync files from VM after command execution")
+			result.SyncAfterError = err.Error()
+		}
 	}
 
 	return result, nil
 }
 
+// lockVM waits until no other command runs in a VM, or until ctx is done, and returns the
+// function that lets the next command run
+func (e *Executor) lockVM(ctx context.Context, vmName string) (func(), error) {
+	e.mu.Lock()
+	if e.vmSlots == nil {
+		e.vmSlots = make(map[string]chan struct{})
+	}
+	slot, ok := e.vmSlots[vmName]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		e.vmSlots[vmName] = slot
+	}
+	e.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), errors.CodeCancelled, fmt.Sprintf("cancelled while waiting for the command running in VM '%s'", vmName))
+	}
+}
+
+// GuestWorkingDir returns the guest directory a command runs in for a working directory.
+// Directories outside /vagrant are taken relative to it.
+func GuestWorkingDir(workingDir string) string {
+	workingDir = hostos.GuestSlashes(workingDir)
+	if workingDir == "" || strings.HasPrefix(workingDir, "/vagrant") {
+		return workingDir
+	}
+	return "/vagrant/" + workingDir
+}
+
 // GetSSHConfig retrieves the SSH configuration for the VM using 'vagrant ssh-config'
 func (e *Executor) getSSHConfig(ctx context.Context, name string) (map[string]string, error) {
 	// Try to use the underlying adapter if available
@@ -121,32 +289,30 @@ func (e *Executor) getSSHConfig(ctx context.Context, name string) (map[string]st
 	return nil, errors.New(errors.CodeNotImplemented, "GetSSHConfig for this VMManager is not implemented")
 }
 
-// executeSSHCommand executes a command via SSH in a VM
-func (e *Executor) executeSSHCommand(ctx context.Context, command string, execCtx ExecutionContext, callback OutputCallback) (*CommandResult, error) {
-	// Get SSH config for the VM
-	sshConfig, err := e.getSSHConfig(ctx, execCtx.VMName)
+// SSHArgs returns the ssh arguments that connect to a VM, ending with the user@host destination
+func (e *Executor) SSHArgs(ctx context.Context, vmName string) ([]string, error) {
+	sshConfig, err := e.getSSHConfig(ctx, vmName)
 	if err != nil {
 		return nil, errors.OperationFailed("get SSH config", err)
 	}
+	return vm.SSHArgs(sshConfig), nil
+}
 
+// executeSSHCommand executes a command via SSH in a VM
+func (e *Executor) executeSSHCommand(ctx context.Context, command string, execCtx ExecutionContext, callback OutputCallback) (*CommandResult, error) {
 	// Build the SSH command
-	sshArgs := []string{
-		"-p", sshConfig["Port"],
-		"-i", sshConfig["IdentityFile"],
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		fmt.Sprintf("%s@%s", sshConfig["User"], sshConfig["HostName"]),
+	sshArgs, err := e.SSHArgs(ctx, execCtx.VMName)
+	if err != nil {
+		return nil, err
+	}
+	if execCtx.ForwardAgent {
+		sshArgs = vm.ForwardAgentArgs(sshArgs)
 	}
 
 	// Add working directory if specified
 	fullCommand := command
 	if execCtx.WorkingDir != "" {
-		if strings.HasPrefix(execCtx.WorkingDir, "/vagrant") {
-			fullCommand = fmt.Sprintf("cd %s && %s", execCtx.WorkingDir, command)
-		} else {
-			// If not absolute or under /vagrant, prepend /vagrant
-			fullCommand = fmt.Sprintf("cd /vagrant/%s && %s", execCtx.WorkingDir, command)
-		}
+		fullCommand = fmt.Sprintf("cd %s && %s", GuestWorkingDir(execCtx.WorkingDir), command)
 	}
 
 	// Add environment variables if specified
@@ -158,14 +324,28 @@ func (e *Executor) executeSSHCommand(ctx context.Context, command string, execCt
 		fullCommand = fmt.Sprintf("%s && %s", strings.Join(envParts, "; "), fullCommand)
 	}
 
-	// Add command to SSH args
-	sshArgs = append(sshArgs, fullCommand)
+	// Export secrets set with set_vm_secret; they live in the guest's tmpfs, not in the command
+	fullCommand = secrets.SourceCommand(fullCommand)
+
+	// Record the remote shell's process group so a timed out or cancelled command can be
+	// killed in the VM; killing the local ssh client alone leaves it running there
+	pidFile := fmt.Sprintf("%s/%d-%d", remotePIDDir, time.Now().UnixNano(), os.Getpid())
+	fullCommand = wrapWithPIDFile(fullCommand, pidFile)
+
+	runCtx := ctx
+	timeout := e.effectiveTimeout(execCtx.Timeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	// Create SSH command
-	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
+	cmd := exec.CommandContext(runCtx, "ssh", append(sshArgs, fullCommand)...)
 
-	// Capture stdout and stderr
-	var stdout, stderr bytes.Buffer
+	// Capture the head and tail of stdout and stderr, and their full content when it may be spilled
+	outputLimit := e.effectiveOutputLimit(execCtx.MaxOutputBytes)
+	stdout, stderr := newCappedBuffer(outputLimit), newCappedBuffer(outputLimit)
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -182,18 +362,39 @@ func (e *Executor) executeSSHCommand(ctx context.Context, command string, execCt
 		return nil, errors.OperationFailed("start command", err)
 	}
 
+	// Create the artifacts once the command runs, so a failed start leaves none behind
+	var stdoutWriter, stderrWriter io.Writer = stdout, stderr
+	var spills [2]*artifacts.Writer
+	if execCtx.SpillOutput && outputLimit > 0 {
+		store := artifacts.NewStore(e.vmManager.GetBaseDir())
+		for i, name := range []string{"stdout", "stderr"} {
+			spill, err := store.Create(execCtx.VMName, artifacts.KindExecOutput, name)
+			if err != nil {
+				log.Warn().Err(err).Str("vm", execCtx.VMName).Msg("Failed to create output artifact")
+				continue
+			}
+			spills[i] = spill
+		}
+		if spills[0] != nil {
+			stdoutWriter = io.MultiWriter(stdout, spills[0])
+		}
+		if spills[1] != nil {
+			stderrWriter = io.MultiWriter(stderr, spills[1])
+		}
+	}
+
 	// Process command output in separate goroutines
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		e.streamOutput(stdoutPipe, &stdout, false, callback)
+		e.streamOutput(stdoutPipe, stdoutWriter, false, callback)
 	}()
 
 	go func() {
 		defer wg.Done()
-		e.streamOutput(stderrPipe, &stderr, true, callback)
+		e.streamOutput(stderrPipe, stderrWriter, true, callback)
 	}()
 
 	// Wait for output processing to complete
@@ -204,8 +405,18 @@ func (e *Executor) executeSSHCommand(ctx context.Context, command string, execCt
 
 	// Create result
 	result := &CommandResult{
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		Truncation: truncation(outputLimit, stdout, stderr, spills),
+	}
+
+	if runCtx.Err() != nil {
+		e.killRemoteCommand(execCtx.VMName, sshArgs, pidFile)
+		result.ExitCode = -1
+		if ctx.Err() != nil {
+			return result, errors.Wrap(ctx.Err(), errors.CodeCancelled, "command cancelled")
+		}
+		return result, errors.Wrap(runCtx.Err(), errors.CodeTimeout, fmt.Sprintf("command timed out after %s", timeout))
 	}
 
 	// Handle exit code
@@ -223,21 +434,84 @@ func (e *Executor) executeSSHCommand(ctx context.Context, command string, execCt
 	return result, nil
 }
 
-// streamOutput processes and captures command output
-func (e *Executor) streamOutput(r io.Reader, buffer *bytes.Buffer, isStderr bool, callback OutputCallback) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Bytes()
+// killRemoteCommand kills the process group of a command whose ssh client was stopped
+func (e *Executor) killRemoteCommand(vmName string, sshArgs []string, pidFile string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	var output bytes.Buffer
+	kill := cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: buildKillCommand(pidFile), Stdout: &output, Stderr: &output}
+	if err := cmdexec.RunSSH(ctx, kill); err != nil {
+		log.Warn().Err(err).Str("vm", vmName).Str("output", output.String()).Msg("Failed to kill remote command")
+		return
+	}
+	log.Info().Str("vm", vmName).Msg("Killed remote command")
+}
 
-		// Write to buffer
-		buffer.Write(line)
-		buffer.WriteByte('\n')
+// wrapWithPIDFile makes command record its shell's process group in pidFile while it runs.
+// sshd starts the remote shell as a session leader, so its PID is the group of everything it runs.
+func wrapWithPIDFile(command, pidFile string) string {
+	return fmt.Sprintf("mkdir -p %[1]s && echo $$ > %[2]s; trap 'rm -f %[2]s' EXIT; %[3]s", remotePIDDir, pidFile, command)
+}
+
+// buildKillCommand returns the command that terminates the process group recorded in pidFile,
+// killing it if it is still running after a grace period
+func buildKillCommand(pidFile string) string {
+	return fmt.Sprintf("pgid=$(cat %[1]s 2>/dev/null) || exit 0; kill -TERM -- -$pgid 2>/dev/null; sleep 2; kill -KILL -- -$pgid 2>/dev/null; rm -f %[1]s; exit 0", pidFile)
+}
 
-		// Call callback if provided
-		if callback != nil {
-			lineCopy := make([]byte, len(line))
-			copy(lineCopy, line)
-			callback(lineCopy, isStderr)
+// truncation describes the streams that exceeded the capture limit, committing the artifacts
+// of truncated streams and discarding the others; it is nil when nothing was truncated
+func truncation(limit int, stdout, stderr *cappedBuffer, spills [2]*artifacts.Writer) *Truncation {
+	var t *Truncation
+	if stdout.Truncated() || stderr.Truncated() {
+		t = &Truncation{
+			LimitBytes:      limit,
+			StdoutBytes:     stdout.total,
+			StderrBytes:     stderr.total,
+			StdoutTruncated: stdout.Truncated(),
+			StderrTruncated: stderr.Truncated(),
+		}
+	}
+	for i, buffer := range []*cappedBuffer{stdout, stderr} {
+		if spills[i] == nil {
+			continue
+		}
+		if !buffer.Truncated() {
+			spills[i].Discard()
+			continue
+		}
+		artifact, err := spills[i].Commit()
+		switch {
+		case err != nil:
+			t.ArtifactError = err.Error()
+		case i == 0:
+			t.StdoutArtifact = artifact.URI
+		default:
+			t.StderrArtifact = artifact.URI
+		}
+	}
+	return t
+}
+
+// streamOutput processes and captures command output. Lines longer than the read buffer are
+// passed on in pieces rather than stopping the stream.
+func (e *Executor) streamOutput(r io.Reader, w io.Writer, isStderr bool, callback OutputCallback) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			// Write to buffer
+			_, _ = w.Write(chunk)
+
+			// Call callback if provided
+			if callback != nil {
+				line := make([]byte, len(chunk))
+				copy(line, chunk)
+				callback(bytes.TrimSuffix(line, []byte("\n")), isStderr)
+			}
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return
 		}
 	}
 }
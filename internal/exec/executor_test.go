@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
 	syncmod "github.com/vagrant-mcp/server/internal/sync"
 	"github.com/vagrant-mcp/server/internal/testsupport"
 	"github.com/vagrant-mcp/server/internal/utils"
@@ -106,6 +108,17 @@ func (f *testFixture) setupVM() error {
 		return fmt.Errorf("failed to start VM: %w", err)
 	}
 
+	// Register the VM for sync so commands can sync before and after running
+	syncConfig := core.SyncConfig{
+		VMName:      f.baseFixture.VMName,
+		ProjectPath: f.baseFixture.ProjectPath,
+		Method:      core.SyncMethodRsync,
+		Direction:   core.SyncBidirectional,
+	}
+	if err := f.SyncEngine.RegisterVM(f.ctx, f.baseFixture.VMName, syncConfig); err != nil {
+		return fmt.Errorf("failed to register VM for sync: %w", err)
+	}
+
 	// Wait for VM to be ready (this can take a while)
 	f.baseFixture.T.Logf("Waiting for VM %s to be ready", f.baseFixture.VMName)
 	deadline := time.Now().Add(5 * time.Minute)
@@ -247,3 +260,86 @@ func TestExecuteCommand_NotRunning(t *testing.T) {
 
 	t.Logf("Got expected error when VM is not running: %v", err)
 }
+
+func TestEffectiveTimeout(t *testing.T) {
+	testCases := []struct {
+		name      string
+		max       time.Duration
+		requested time.Duration
+		expected  time.Duration
+	}{
+		{name: "default to maximum", max: 30 * time.Minute, requested: 0, expected: 30 * time.Minute},
+		{name: "shorter than maximum", max: 30 * time.Minute, requested: 10 * time.Second, expected: 10 * time.Second},
+		{name: "capped at maximum", max: time.Minute, requested: time.Hour, expected: time.Minute},
+		{name: "no maximum", max: 0, requested: time.Hour, expected: time.Hour},
+		{name: "no limit at all", max: 0, requested: 0, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			executor := &Executor{maxTimeout: tc.max}
+			if got := executor.effectiveTimeout(tc.requested); got != tc.expected {
+				t.Errorf("Expected %s but got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLockVM(t *testing.T) {
+	executor := &Executor{}
+	release, err := executor.lockVM(context.Background(), "web")
+	if err != nil {
+		t.Fatalf("Failed to lock VM: %v", err)
+	}
+
+	// Another VM runs its command meanwhile
+	releaseOther, err := executor.lockVM(context.Background(), "db")
+	if err != nil {
+		t.Fatalf("Failed to lock another VM: %v", err)
+	}
+	releaseOther()
+
+	// A command waiting for the VM gives up when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := executor.lockVM(ctx, "web"); !errors.Is(err, errors.CodeCancelled) {
+		t.Fatalf("Expected a cancelled error but got %v", err)
+	}
+
+	// The next command runs once the first is done
+	acquired := make(chan struct{})
+	go func() {
+		next, err := executor.lockVM(context.Background(), "web")
+		if err == nil {
+			next()
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("Expected the command to wait for the running one")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the command to run once the VM was released")
+	}
+}
+
+func TestRemoteProcessGroupCommands(t *testing.T) {
+	pidFile := remotePIDDir + "/123-456"
+	wrapped := wrapWithPIDFile("cd /vagrant && make test", pidFile)
+	expected := "mkdir -p /tmp/vagrant-mcp/exec && echo $$ > /tmp/vagrant-mcp/exec/123-456; trap 'rm -f /tmp/vagrant-mcp/exec/123-456' EXIT; cd /vagrant && make test"
+	if wrapped != expected {
+		t.Errorf("Expected %q but got %q", expected, wrapped)
+	}
+
+	kill := buildKillCommand(pidFile)
+	for _, fragment := range []string{"pgid=$(cat /tmp/vagrant-mcp/exec/123-456 2>/dev/null) || exit 0", "kill -TERM -- -$pgid", "kill -KILL -- -$pgid"} {
+		if !strings.Contains(kill, fragment) {
+			t.Errorf("Expected kill command to contain %q but got %s", fragment, kill)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package exec
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DefaultMaxOutputBytes is how much of each of a command's output streams is kept unless
+// MCP_EXEC_MAX_OUTPUT_BYTES says otherwise
+const DefaultMaxOutputBytes = 256 * 1024
+
+// Truncation describes the output streams of a command that exceeded the capture limit.
+// Only their head and tail are kept, around a marker saying how much was left out.
+type Truncation struct {
+	// LimitBytes is the capture limit of each stream
+	LimitBytes      int   `json:"limit_bytes"`
+	StdoutBytes     int64 `json:"stdout_bytes"`
+	StderrBytes     int64 `json:"stderr_bytes"`
+	StdoutTruncated bool  `json:"stdout_truncated"`
+	StderrTruncated bool  `json:"stderr_truncated"`
+	// StdoutArtifact and StderrArtifact are the URIs of the full output of the truncated
+	// streams, when the command asked for it to be kept
+	StdoutArtifact string `json:"stdout_artifact,omitempty"`
+	StderrArtifact string `json:"stderr_artifact,omitempty"`
+	// ArtifactError says why the full output could not be kept
+	ArtifactError string `json:"artifact_error,omitempty"`
+}
+
+// cappedBuffer keeps the first and last bytes of a stream, up to limit bytes in all, and
+// counts the bytes written. A zero limit keeps everything.
+type cappedBuffer struct {
+	limit int
+	head  bytes.Buffer
+	tail  []byte
+	total int64
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	return &cappedBuffer{limit: limit}
+}
+
+// Write keeps p while the head has room, then the newest bytes in the tail
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	written := len(p)
+	b.total += int64(written)
+	if b.limit <= 0 {
+		b.head.Write(p)
+		return written, nil
+	}
+	headSize := b.limit / 2
+	if room := headSize - b.head.Len(); room > 0 {
+		n := min(room, len(p))
+		b.head.Write(p[:n])
+		p = p[n:]
+	}
+	tailSize := b.limit - headSize
+	b.tail = append(b.tail, p...)
+	// Drop old tail bytes once they take twice the room, so trimming stays cheap
+	if len(b.tail) > 2*tailSize {
+		b.tail = append([]byte{}, b.tail[len(b.tail)-tailSize:]...)
+	}
+	return written, nil
+}
+
+// Truncated reports whether bytes were left out
+func (b *cappedBuffer) Truncated() bool {
+	return b.limit > 0 && b.total > int64(b.limit)
+}
+
+// String returns the kept output. Truncated output holds a marker in place of the bytes left
+// out; the head and tail are cut at line boundaries when they hold one.
+func (b *cappedBuffer) String() string {
+	if !b.Truncated() {
+		return b.head.String() + string(b.tail)
+	}
+	head := b.head.Bytes()
+	if i := bytes.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+	tail := b.tail[len(b.tail)-(b.limit-b.limit/2):]
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	omitted := b.total - int64(len(head)) - int64(len(tail))
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", head, omitted, tail)
+}
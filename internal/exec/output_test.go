@@ -0,0 +1,105 @@
+package exec
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/artifacts"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	testCases := []struct {
+		name      string
+		limit     int
+		writes    []string
+		expected  string
+		truncated bool
+	}{
+		{"under the limit", 20, []string{"one\n", "two\n"}, "one\ntwo\n", false},
+		{"at the limit", 8, []string{"one\n", "two\n"}, "one\ntwo\n", false},
+		{"unlimited", 0, []string{strings.Repeat("x", 1000)}, strings.Repeat("x", 1000), false},
+		{"line aligned", 20, []string{"first\n", "second\n", "third\n", "fourth\n", "fifth\n"}, "first\n\n... [20 bytes truncated] ...\nfifth\n", true},
+		{"single long line", 10, []string{strings.Repeat("a", 50) + strings.Repeat("b", 50)}, "aaaaa\n... [90 bytes truncated] ...\nbbbbb", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buffer := newCappedBuffer(tc.limit)
+			for _, write := range tc.writes {
+				if n, err := buffer.Write([]byte(write)); err != nil || n != len(write) {
+					t.Fatalf("Expected %d bytes written but got %d (%v)", len(write), n, err)
+				}
+			}
+			if buffer.Truncated() != tc.truncated {
+				t.Errorf("Expected truncated %v but got %v", tc.truncated, buffer.Truncated())
+			}
+			if buffer.String() != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, buffer.String())
+			}
+		})
+	}
+}
+
+func TestTruncationSpillsTruncatedStreams(t *testing.T) {
+	baseDir := t.TempDir()
+	store := artifacts.NewStore(baseDir)
+	stdout, stderr := newCappedBuffer(10), newCappedBuffer(10)
+	var spills [2]*artifacts.Writer
+	for i, name := range []string{"stdout", "stderr"} {
+		spill, err := store.Create("dev", artifacts.KindExecOutput, name)
+		if err != nil {
+			t.Fatalf("Failed to create artifact: %v", err)
+		}
+		spills[i] = spill
+	}
+	full := strings.Repeat("line\n", 100)
+	for _, w := range []interface{ Write([]byte) (int, error) }{stdout, spills[0]} {
+		_, _ = w.Write([]byte(full))
+	}
+	for _, w := range []interface{ Write([]byte) (int, error) }{stderr, spills[1]} {
+		_, _ = w.Write([]byte("ok\n"))
+	}
+
+	result := truncation(10, stdout, stderr, spills)
+	if result == nil || !result.StdoutTruncated || result.StderrTruncated || result.StdoutBytes != int64(len(full)) {
+		t.Fatalf("Expected stdout to be truncated but got %+v", result)
+	}
+	if result.StderrArtifact != "" || !strings.HasPrefix(result.StdoutArtifact, artifacts.URIPrefix) {
+		t.Fatalf("Expected only the stdout artifact but got %+v", result)
+	}
+	_, file, err := store.Open(strings.TrimPrefix(result.StdoutArtifact, artifacts.URIPrefix))
+	if err != nil {
+		t.Fatalf("Failed to open artifact: %v", err)
+	}
+	defer file.Close()
+	var content bytes.Buffer
+	if _, err := content.ReadFrom(file); err != nil || content.String() != full {
+		t.Errorf("Expected the artifact to hold the full output but got %d bytes (%v)", content.Len(), err)
+	}
+	entries, _ := os.ReadDir(baseDir + "/dev/artifacts")
+	if len(entries) != 2 {
+		t.Errorf("Expected the untruncated stream's artifact to be discarded but got %d files", len(entries))
+	}
+
+	if result := truncation(10, newCappedBuffer(10), newCappedBuffer(10), [2]*artifacts.Writer{}); result != nil {
+		t.Errorf("Expected no truncation but got %+v", result)
+	}
+}
+
+func TestStreamOutputLongLines(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	input := "short\n" + long + "\nlast"
+	var captured bytes.Buffer
+	var lines int
+	e := &Executor{}
+	e.streamOutput(strings.NewReader(input), &captured, false, func(data []byte, isStderr bool) {
+		lines++
+	})
+	if captured.String() != input {
+		t.Errorf("Expected all %d bytes to be captured but got %d", len(input), captured.Len())
+	}
+	if lines < 3 {
+		t.Errorf("Expected the output to be passed on in at least 3 pieces but got %d", lines)
+	}
+}
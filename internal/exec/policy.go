@@ -0,0 +1,295 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Sudo settings of a command policy
+const (
+	SudoAllow = "allow"
+	SudoDeny  = "deny"
+)
+
+// Policy rules a decision can cite
+const (
+	RuleDeny        = "deny"
+	RuleDestructive = "destructive"
+	RuleAllow       = "allow"
+	RuleSudo        = "sudo"
+	RulePath        = "path"
+	RuleInvalid     = "invalid_policy"
+)
+
+// destructivePatterns are the commands blocked by deny_destructive
+var destructivePatterns = []string{
+	`\brm\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-[a-zA-Z]*\s+)*(/|/\*|~|\$HOME)(\s|$|;|&|\|)`,
+	`\bmkfs(\.\w+)?\b`,
+	`\bdd\b.*\bof=/dev/`,
+	`>\s*/dev/(sd|vd|hd|nvme|xvd)`,
+	`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`,
+	`\b(shutdown|poweroff|reboot|halt)\b`,
+	`\bchmod\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*R[a-zA-Z]*\s+\S+\s+/(\s|$)`,
+	`\bchown\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*R[a-zA-Z]*\s+\S+\s+/(\s|$)`,
+}
+
+// sudoPattern matches commands that raise privileges
+var sudoPattern = regexp.MustCompile(`(^|[;&|(\s])(sudo|su|doas|pkexec)(\s|$)`)
+
+// cdPattern matches the directory changes of a command
+var cdPattern = regexp.MustCompile(`(?:^|[;&|(\s])cd\s+("[^"]*"|'[^']*'|[^\s;&|)]+)`)
+
+// GlobalPolicy is the command policy shared by the server
+var GlobalPolicy = NewPolicyEngineFromEnv()
+
+// CommandPolicy restricts the commands the exec tools run in a VM
+type CommandPolicy struct {
+	// Deny lists regular expressions; a command matching any is blocked
+	Deny []string `json:"deny,omitempty"`
+	// DenyDestructive blocks a built-in list of destructive commands such as 'rm -rf /' and mkfs
+	DenyDestructive *bool `json:"deny_destructive,omitempty"`
+	// Allow lists regular expressions; when set, a command must match one
+	Allow []string `json:"allow,omitempty"`
+	// Sudo is "allow" or "deny" for commands that use sudo, su, doas or pkexec
+	Sudo string `json:"sudo,omitempty"`
+	// AllowedPaths lists the guest directories commands may run in or cd into
+	AllowedPaths []string `json:"allowed_paths,omitempty"`
+}
+
+// PolicyConfig is the command policy file: a policy for every VM and overrides per VM
+type PolicyConfig struct {
+	CommandPolicy
+	// VMs overrides the policy for single VMs. Deny patterns add to the server's; the other
+	// settings replace the server's when set.
+	VMs map[string]CommandPolicy `json:"vms,omitempty"`
+}
+
+// PolicyDecision explains whether a policy lets a command run
+type PolicyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// PolicyEngine evaluates commands against the command policy
+type PolicyEngine struct {
+	mu     sync.RWMutex
+	config PolicyConfig
+	source string
+	// loadErr blocks every command when the policy file cannot be used
+	loadErr error
+}
+
+// NewPolicyEngine creates a policy engine with the given policy
+func NewPolicyEngine(config PolicyConfig) (*PolicyEngine, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &PolicyEngine{config: config}, nil
+}
+
+// NewPolicyEngineFromEnv creates a policy engine that loads MCP_EXEC_POLICY_FILE, or
+// ~/.vagrant-mcp/exec-policy.json when it is not set. Without a file every command is allowed;
+// a file that cannot be read or parsed blocks every command until it is fixed.
+func NewPolicyEngineFromEnv() *PolicyEngine {
+	engine := &PolicyEngine{}
+	file := PolicyFile()
+	if file == "" {
+		return engine
+	}
+	if err := engine.LoadFile(file); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("file", file).Msg("Failed to load exec policy; blocking exec commands")
+		engine.loadErr = err
+		engine.source = file
+	}
+	return engine
+}
+
+// PolicyFile returns the path of the command policy file
+func PolicyFile() string {
+	if file := os.Getenv("MCP_EXEC_POLICY_FILE"); file != "" {
+		return file
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".vagrant-mcp", "exec-policy.json")
+}
+
+// LoadFile replaces the policy with the one in a JSON file
+func (e *PolicyEngine) LoadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config PolicyConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse exec policy file: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config = config
+	e.source = file
+	e.loadErr = nil
+	log.Info().Str("file", file).Int("vm_overrides", len(config.VMs)).Msg("Loaded exec policy")
+	return nil
+}
+
+// Validate checks the patterns and settings of a policy file
+func (c PolicyConfig) Validate() error {
+	if err := c.CommandPolicy.validate(""); err != nil {
+		return err
+	}
+	for name, policy := range c.VMs {
+		if err := policy.validate(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the patterns and settings of a policy, for the VM name when it is an override
+func (p CommandPolicy) validate(vmName string) error {
+	scope := "exec policy"
+	if vmName != "" {
+		scope = fmt.Sprintf("exec policy for VM '%s'", vmName)
+	}
+	for _, pattern := range append(append([]string{}, p.Deny...), p.Allow...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q in %s: %w", pattern, scope, err)
+		}
+	}
+	if p.Sudo != "" && p.Sudo != SudoAllow && p.Sudo != SudoDeny {
+		return fmt.Errorf("invalid sudo setting %q in %s: must be '%s' or '%s'", p.Sudo, scope, SudoAllow, SudoDeny)
+	}
+	for _, allowed := range p.AllowedPaths {
+		if !path.IsAbs(allowed) {
+			return fmt.Errorf("invalid allowed path %q in %s: must be an absolute guest path", allowed, scope)
+		}
+	}
+	return nil
+}
+
+// For returns the policy that applies to a VM: the server's policy with the VM's overrides
+func (e *PolicyEngine) For(vmName string) CommandPolicy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	policy := e.config.CommandPolicy
+	policy.Deny = append([]string{}, policy.Deny...)
+	override, ok := e.config.VMs[vmName]
+	if !ok {
+		return policy
+	}
+	policy.Deny = append(policy.Deny, override.Deny...)
+	if override.DenyDestructive != nil {
+		policy.DenyDestructive = override.DenyDestructive
+	}
+	if override.Allow != nil {
+		policy.Allow = override.Allow
+	}
+	if override.Sudo != "" {
+		policy.Sudo = override.Sudo
+	}
+	if override.AllowedPaths != nil {
+		policy.AllowedPaths = override.AllowedPaths
+	}
+	return policy
+}
+
+// Evaluate decides whether a command may run in a VM from the given working directory
+func (e *PolicyEngine) Evaluate(vmName, command, workingDir string) PolicyDecision {
+	e.mu.RLock()
+	loadErr, source := e.loadErr, e.source
+	e.mu.RUnlock()
+	if loadErr != nil {
+		return PolicyDecision{Rule: RuleInvalid, Reason: fmt.Sprintf("the exec policy file %s is invalid, so commands are blocked until it is fixed: %v", source, loadErr)}
+	}
+	return e.For(vmName).Evaluate(command, workingDir)
+}
+
+// Check returns a permission denied error explaining the rule that blocks a command, or nil
+// when the command may run
+func (e *PolicyEngine) Check(vmName, command, workingDir string) error {
+	decision := e.Evaluate(vmName, command, workingDir)
+	if decision.Allowed {
+		return nil
+	}
+	log.Warn().Str("vm", vmName).Str("command", command).Str("rule", decision.Rule).Msg("Command blocked by exec policy")
+	return errors.New(errors.CodePermissionDenied, "Command blocked by exec policy: "+decision.Reason)
+}
+
+// Evaluate decides whether the policy lets a command run from the given working directory
+func (p CommandPolicy) Evaluate(command, workingDir string) PolicyDecision {
+	for _, pattern := range p.Deny {
+		if regexp.MustCompile(pattern).MatchString(command) {
+			return PolicyDecision{Rule: RuleDeny, Pattern: pattern, Reason: fmt.Sprintf("the command matches the deny pattern %q", pattern)}
+		}
+	}
+	if p.DenyDestructive != nil && *p.DenyDestructive {
+		for _, pattern := range destructivePatterns {
+			if regexp.MustCompile(pattern).MatchString(command) {
+				return PolicyDecision{Rule: RuleDestructive, Pattern: pattern, Reason: "the command matches the built-in list of destructive commands"}
+			}
+		}
+	}
+	if p.Sudo == SudoDeny && sudoPattern.MatchString(command) {
+		return PolicyDecision{Rule: RuleSudo, Reason: "the command raises privileges with sudo, su, doas or pkexec, which the policy denies"}
+	}
+	if len(p.AllowedPaths) > 0 {
+		workingDir = GuestWorkingDir(workingDir)
+		dirs := []string{workingDir}
+		for _, match := range cdPattern.FindAllStringSubmatch(command, -1) {
+			dirs = append(dirs, strings.Trim(match[1], `"'`))
+		}
+		for _, dir := range dirs {
+			if dir != "" && !p.pathAllowed(workingDir, dir) {
+				return PolicyDecision{Rule: RulePath, Pattern: dir, Reason: fmt.Sprintf("the directory %s is outside the allowed paths %s", dir, strings.Join(p.AllowedPaths, ", "))}
+			}
+		}
+	}
+	if len(p.Allow) > 0 {
+		for _, pattern := range p.Allow {
+			if regexp.MustCompile(pattern).MatchString(command) {
+				return PolicyDecision{Allowed: true, Rule: RuleAllow, Pattern: pattern, Reason: fmt.Sprintf("the command matches the allow pattern %q", pattern)}
+			}
+		}
+		return PolicyDecision{Rule: RuleAllow, Reason: "the command matches none of the allow patterns"}
+	}
+	return PolicyDecision{Allowed: true, Reason: "no policy rule blocks the command"}
+}
+
+// pathAllowed reports whether a directory, relative to the working directory, is inside one of
+// the allowed paths. Directories the shell expands, such as ~ or $HOME, are never allowed.
+func (p CommandPolicy) pathAllowed(workingDir, dir string) bool {
+	if strings.ContainsAny(dir, "~$`") {
+		return false
+	}
+	if !path.IsAbs(dir) {
+		dir = path.Join(workingDir, dir)
+	}
+	dir = path.Clean(dir)
+	for _, allowed := range p.AllowedPaths {
+		allowed = path.Clean(allowed)
+		if dir == allowed || strings.HasPrefix(dir, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
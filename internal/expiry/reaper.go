@@ -0,0 +1,287 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package expiry halts or destroys VMs whose time to live has run out
+package expiry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+// Actions taken on expired VMs
+const (
+	// ActionHalt shuts the VM down, keeping its disk
+	ActionHalt = "halt"
+	// ActionDestroy destroys the VM
+	ActionDestroy = "destroy"
+)
+
+// Default reaper settings
+const (
+	DefaultCheckInterval = time.Minute
+	DefaultGrace         = 15 * time.Minute
+)
+
+// MinTTL is the shortest time to live a VM can be given
+const MinTTL = 5 * time.Minute
+
+// GlobalReaper is the expiry reaper shared by the server
+var GlobalReaper = NewReaperFromEnv()
+
+// VMController is the part of the VM manager the reaper needs to find and reap expired VMs
+type VMController interface {
+	ListVMs(ctx context.Context) ([]string, error)
+	GetVMConfig(ctx context.Context, name string) (core.VMConfig, error)
+	GetVMState(ctx context.Context, name string) (core.VMState, error)
+	StopVM(ctx context.Context, name string) error
+	DestroyVM(ctx context.Context, name string) error
+}
+
+// warning records the expiry a VM was warned about
+type warning struct {
+	expiresAt time.Time
+	at        time.Time
+}
+
+// Reaper warns about VMs close to expiry and reaps the expired ones
+type Reaper struct {
+	mu         sync.Mutex
+	action     string
+	grace      time.Duration
+	defaultTTL time.Duration
+	warned     map[string]warning
+	bus        *events.Bus
+	now        func() time.Time
+}
+
+// NewReaper creates a reaper that applies action to expired VMs, warning grace before. VMs
+// created without a time to live get defaultTTL; zero leaves them without one.
+func NewReaper(action string, grace, defaultTTL time.Duration, bus *events.Bus) *Reaper {
+	if action != ActionDestroy {
+		action = ActionHalt
+	}
+	if grace < 0 {
+		grace = DefaultGrace
+	}
+	if defaultTTL < 0 {
+		defaultTTL = 0
+	}
+	return &Reaper{
+		action:     action,
+		grace:      grace,
+		defaultTTL: defaultTTL,
+		warned:     make(map[string]warning),
+		bus:        bus,
+		now:        time.Now,
+	}
+}
+
+// NewReaperFromEnv creates a reaper configured from MCP_VM_DEFAULT_TTL and MCP_EXPIRY_GRACE,
+// durations such as "8h", and MCP_EXPIRY_ACTION, "halt" or "destroy"
+func NewReaperFromEnv() *Reaper {
+	defaultTTL := durationFromEnv("MCP_VM_DEFAULT_TTL", 0)
+	grace := durationFromEnv("MCP_EXPIRY_GRACE", DefaultGrace)
+	return NewReaper(os.Getenv("MCP_EXPIRY_ACTION"), grace, defaultTTL, events.GlobalBus)
+}
+
+// durationFromEnv returns the duration in an environment variable, or fallback when it is
+// unset or invalid
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Str("value", value).Msgf("Ignoring invalid %s", name)
+		return fallback
+	}
+	return parsed
+}
+
+// DisallowDestroy makes the reaper halt expired VMs even when it was configured to destroy
+// them, for server modes that leave out the tools that destroy VMs. It is called before the
+// reaper runs.
+func (r *Reaper) DisallowDestroy() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.action == ActionDestroy {
+		log.Warn().Msg("Halting expired VMs instead of destroying them, as the server mode does not destroy VMs")
+		r.action = ActionHalt
+	}
+}
+
+// Action returns what is done to expired VMs. They are halted rather than destroyed while
+// destroying a VM needs approval, as nobody is there to approve the reaper's destroys.
+func (r *Reaper) Action() string {
+	r.mu.Lock()
+	action := r.action
+	r.mu.Unlock()
+	if action == ActionDestroy && approval.GlobalGate.Requires(approval.OperationDestroyVM) {
+		return ActionHalt
+	}
+	return action
+}
+
+// Grace returns how long before expiry a VM is warned about
+func (r *Reaper) Grace() time.Duration {
+	return r.grace
+}
+
+// DefaultTTL returns the time to live of VMs created without one
+func (r *Reaper) DefaultTTL() time.Duration {
+	return r.defaultTTL
+}
+
+// ExpiresAt returns when a VM given ttl from now expires, or nil for a zero ttl
+func (r *Reaper) ExpiresAt(ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	expiresAt := r.now().Add(ttl).UTC().Truncate(time.Second)
+	return &expiresAt
+}
+
+// Expired reports whether a VM's time to live has run out
+func (r *Reaper) Expired(config core.VMConfig) bool {
+	return config.ExpiresAt != nil && !r.now().Before(*config.ExpiresAt)
+}
+
+// Check warns about the VMs that expire within the grace period and reaps the expired ones,
+// returning their names. A VM is reaped no sooner than the grace period after its warning,
+// so one that expired while the server was down is warned about first.
+func (r *Reaper) Check(ctx context.Context, vms VMController) []string {
+	names, err := vms.ListVMs(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list VMs for expiry")
+		return nil
+	}
+	var reaped []string
+	for _, name := range names {
+		config, err := vms.GetVMConfig(ctx, name)
+		if err != nil || config.ExpiresAt == nil {
+			r.forget(name)
+			continue
+		}
+		now := r.now()
+		expiresAt := *config.ExpiresAt
+		if now.Before(expiresAt.Add(-r.grace)) {
+			// Not close to expiry, or extended since its warning
+			r.forget(name)
+			continue
+		}
+		warned, ok := r.warning(name, expiresAt)
+		if !ok {
+			r.warn(name, expiresAt)
+			continue
+		}
+		if now.Before(expiresAt) || now.Before(warned.at.Add(r.grace)) {
+			continue
+		}
+		done, err := r.reap(ctx, vms, name)
+		if err != nil {
+			log.Warn().Err(err).Str("name", name).Str("action", r.Action()).Msg("Failed to reap expired VM")
+			continue
+		}
+		if !done {
+			continue
+		}
+		// A halted VM keeps its warning, so it is not announced again while it stays expired
+		if r.Action() == ActionDestroy {
+			r.forget(name)
+		}
+		log.Info().Str("name", name).Str("action", r.Action()).Time("expires_at", expiresAt).Msg("Reaped expired VM")
+		r.publish(events.VMExpired, name, expiresAt)
+		reaped = append(reaped, name)
+	}
+	return reaped
+}
+
+// reap applies the reaper's action to an expired VM and reports whether it did anything;
+// halting a VM that is not running does nothing
+func (r *Reaper) reap(ctx context.Context, vms VMController, name string) (bool, error) {
+	if r.Action() == ActionDestroy {
+		return true, vms.DestroyVM(ctx, name)
+	}
+	state, err := vms.GetVMState(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get VM state: %w", err)
+	}
+	if state != core.Running {
+		return false, nil
+	}
+	return true, vms.StopVM(ctx, name)
+}
+
+// warning returns the warning a VM got for an expiry
+func (r *Reaper) warning(name string, expiresAt time.Time) (warning, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	warned, ok := r.warned[name]
+	return warned, ok && warned.expiresAt.Equal(expiresAt)
+}
+
+// warn records and announces that a VM is about to expire
+func (r *Reaper) warn(name string, expiresAt time.Time) {
+	r.mu.Lock()
+	r.warned[name] = warning{expiresAt: expiresAt, at: r.now()}
+	r.mu.Unlock()
+	log.Info().Str("name", name).Str("action", r.Action()).Time("expires_at", expiresAt).Msg("VM is about to expire")
+	r.publish(events.VMExpiring, name, expiresAt)
+}
+
+// forget drops the warning recorded for a VM
+func (r *Reaper) forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.warned, name)
+}
+
+// publish emits an expiry event if the reaper has a bus
+func (r *Reaper) publish(eventType events.Type, name string, expiresAt time.Time) {
+	if r.bus == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"action":     r.Action(),
+		"expires_at": expiresAt,
+	}
+	if eventType == events.VMExpiring {
+		data["message"] = fmt.Sprintf("VM '%s' will be %s; extend it with set_vm_ttl to keep it", name, r.verb())
+	}
+	r.bus.Publish(eventType, name, data)
+}
+
+// verb returns the past participle of the reaper's action
+func (r *Reaper) verb() string {
+	if r.Action() == ActionDestroy {
+		return "destroyed"
+	}
+	return "halted"
+}
+
+// Run checks for expired VMs every interval until ctx is done
+func (r *Reaper) Run(ctx context.Context, vms VMController, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx, vms)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package expiry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+// fakeVMs records the VMs reaped
+type fakeVMs struct {
+	configs   map[string]core.VMConfig
+	states    map[string]core.VMState
+	halted    []string
+	destroyed []string
+}
+
+func (f *fakeVMs) ListVMs(ctx context.Context) ([]string, error) {
+	return []string{"db", "scratch", "web"}, nil
+}
+
+func (f *fakeVMs) GetVMConfig(ctx context.Context, name string) (core.VMConfig, error) {
+	config, ok := f.configs[name]
+	if !ok {
+		return core.VMConfig{}, fmt.Errorf("VM '%s' not found", name)
+	}
+	return config, nil
+}
+
+func (f *fakeVMs) GetVMState(ctx context.Context, name string) (core.VMState, error) {
+	return f.states[name], nil
+}
+
+func (f *fakeVMs) StopVM(ctx context.Context, name string) error {
+	f.halted = append(f.halted, name)
+	f.states[name] = core.Stopped
+	return nil
+}
+
+func (f *fakeVMs) DestroyVM(ctx context.Context, name string) error {
+	f.destroyed = append(f.destroyed, name)
+	delete(f.configs, name)
+	return nil
+}
+
+// newTestReaper returns a reaper with a clock the test moves and the events it published
+func newTestReaper(action string) (*Reaper, *time.Time, *[]events.Event) {
+	bus := events.NewBus(100)
+	published := &[]events.Event{}
+	bus.Subscribe(func(event events.Event) { *published = append(*published, event) })
+	reaper := NewReaper(action, 15*time.Minute, 0, bus)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	reaper.now = func() time.Time { return now }
+	return reaper, &now, published
+}
+
+// eventTypes returns the types of events published for a VM
+func eventTypes(published []events.Event, name string) []events.Type {
+	var types []events.Type
+	for _, event := range published {
+		if event.VMName == name {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+func TestReaper_Check(t *testing.T) {
+	testCases := []struct {
+		name              string
+		action            string
+		disallowDestroy   bool
+		destroyApproval   bool
+		expectedHalted    []string
+		expectedDestroyed []string
+	}{
+		{"halt", ActionHalt, false, false, []string{"web"}, nil},
+		{"destroy", ActionDestroy, false, false, nil, []string{"db", "web"}},
+		{"destroy disallowed by the server mode", ActionDestroy, true, false, []string{"web"}, nil},
+		{"destroy needing approval", ActionDestroy, false, true, []string{"web"}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reaper, now, published := newTestReaper(tc.action)
+			if tc.disallowDestroy {
+				reaper.DisallowDestroy()
+			}
+			if tc.destroyApproval {
+				previous := approval.GlobalGate
+				approval.GlobalGate = approval.NewGate([]string{approval.OperationDestroyVM}, 0, 0, nil)
+				defer func() { approval.GlobalGate = previous }()
+			}
+			vms := &fakeVMs{
+				configs: map[string]core.VMConfig{
+					"web":     {ExpiresAt: reaper.ExpiresAt(time.Hour)},
+					"db":      {ExpiresAt: reaper.ExpiresAt(time.Hour)},
+					"scratch": {},
+				},
+				states: map[string]core.VMState{"web": core.Running, "db": core.Stopped, "scratch": core.Running},
+			}
+
+			// Nothing happens before the grace period
+			if reaped := reaper.Check(context.Background(), vms); reaped != nil || len(*published) != 0 {
+				t.Fatalf("Expected no reaping or events but got %v and %v", reaped, *published)
+			}
+
+			*now = now.Add(50 * time.Minute)
+			if reaped := reaper.Check(context.Background(), vms); reaped != nil {
+				t.Fatalf("Expected no reaping during the grace period but got %v", reaped)
+			}
+			if types := eventTypes(*published, "web"); !reflect.DeepEqual(types, []events.Type{events.VMExpiring}) {
+				t.Errorf("Expected an expiring event for web but got %v", types)
+			}
+
+			*now = now.Add(15 * time.Minute)
+			reaper.Check(context.Background(), vms)
+			if !reflect.DeepEqual(vms.halted, tc.expectedHalted) || !reflect.DeepEqual(vms.destroyed, tc.expectedDestroyed) {
+				t.Errorf("Expected halted %v and destroyed %v but got %v and %v", tc.expectedHalted, tc.expectedDestroyed, vms.halted, vms.destroyed)
+			}
+			if types := eventTypes(*published, "web"); !reflect.DeepEqual(types, []events.Type{events.VMExpiring, events.VMExpired}) {
+				t.Errorf("Expected expiring and expired events for web but got %v", types)
+			}
+
+			// An expired VM is not announced or reaped again
+			*now = now.Add(time.Hour)
+			if reaped := reaper.Check(context.Background(), vms); reaped != nil {
+				t.Errorf("Expected nothing to reap but got %v", reaped)
+			}
+			if types := eventTypes(*published, "web"); len(types) != 2 {
+				t.Errorf("Expected no further events for web but got %v", types)
+			}
+			if len(eventTypes(*published, "scratch")) != 0 {
+				t.Errorf("Expected no events for a VM without a time to live")
+			}
+		})
+	}
+}
+
+func TestReaper_CheckWarnsBeforeReapingLateVMs(t *testing.T) {
+	reaper, now, published := newTestReaper(ActionHalt)
+	vms := &fakeVMs{
+		configs: map[string]core.VMConfig{"web": {ExpiresAt: reaper.ExpiresAt(time.Hour)}},
+		states:  map[string]core.VMState{"web": core.Running},
+	}
+
+	// The server was down when the VM expired
+	*now = now.Add(2 * time.Hour)
+	reaper.Check(context.Background(), vms)
+	if len(vms.halted) != 0 || len(*published) != 1 {
+		t.Fatalf("Expected a warning before halting but got halted %v and events %v", vms.halted, *published)
+	}
+
+	*now = now.Add(15 * time.Minute)
+	if reaped := reaper.Check(context.Background(), vms); !reflect.DeepEqual(reaped, []string{"web"}) {
+		t.Errorf("Expected web to be reaped after the grace period but got %v", reaped)
+	}
+}
+
+func TestReaper_CheckExtended(t *testing.T) {
+	reaper, now, _ := newTestReaper(ActionHalt)
+	vms := &fakeVMs{
+		configs: map[string]core.VMConfig{"web": {ExpiresAt: reaper.ExpiresAt(time.Hour)}},
+		states:  map[string]core.VMState{"web": core.Running},
+	}
+
+	*now = now.Add(50 * time.Minute)
+	reaper.Check(context.Background(), vms)
+	vms.configs["web"] = core.VMConfig{ExpiresAt: reaper.ExpiresAt(4 * time.Hour)}
+
+	*now = now.Add(time.Hour)
+	if reaped := reaper.Check(context.Background(), vms); reaped != nil {
+		t.Errorf("Expected the extended VM to be kept but got %v", reaped)
+	}
+	if reaper.Expired(vms.configs["web"]) {
+		t.Errorf("Expected the extended VM not to be expired")
+	}
+}
+
+func TestReaper_ExpiresAt(t *testing.T) {
+	reaper, now, _ := newTestReaper(ActionHalt)
+	if reaper.ExpiresAt(0) != nil {
+		t.Errorf("Expected no expiry for a zero time to live")
+	}
+	if expiresAt := reaper.ExpiresAt(90 * time.Minute); expiresAt == nil || !expiresAt.Equal(now.Add(90*time.Minute)) {
+		t.Errorf("Expected expiry at %v but got %v", now.Add(90*time.Minute), expiresAt)
+	}
+	if NewReaper("delete", -1, 0, nil).Action() != ActionHalt {
+		t.Errorf("Expected unknown actions to halt")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
+)
+
+// ApprovalRequiredResponse is returned by a destructive tool parked until approve_operation
+// is called with its token. The token is not in the response: only the operator gets it.
+type ApprovalRequiredResponse struct {
+	Status    string                 `json:"status"`
+	ID        string                 `json:"id"`
+	Kind      string                 `json:"kind"`
+	Tool      string                 `json:"tool"`
+	VMName    string                 `json:"vm_name"`
+	Details   map[string]interface{} `json:"details"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Message   string                 `json:"message"`
+}
+
+// RejectedOperationResponse is the result of approve_operation rejecting an operation; an
+// approved operation returns the result of the tool it ran
+type RejectedOperationResponse struct {
+	Status string `json:"status"`
+	Kind   string `json:"kind"`
+	Tool   string `json:"tool"`
+	VMName string `json:"vm_name"`
+}
+
+// RegisterApprovalTools registers the approval workflow tools with the MCP server
+func RegisterApprovalTools(srv *server.MCPServer, gate *approval.Gate) {
+	approveTool := mcp.NewTool("approve_operation",
+		mcp.WithDescription("Approve or reject a destructive operation that is waiting for human approval"),
+		mcp.WithString("token", mcp.Required(), mcp.Description("Approval token of the parked operation, which the server gives to its operator only: ask the user for it")),
+		mcp.WithBoolean("reject", mcp.Description("Reject the operation instead of approving it")),
+	)
+
+	srv.AddTool(approveTool, handleApproveOperation(gate))
+
+	log.Info().Msg("Approval tools registered")
+}
+
+// handleApproveOperation handles the approve_operation tool
+func handleApproveOperation(gate *approval.Gate) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		token, err := request.RequireString("token")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Missing or invalid 'token' parameter: %v", err)), nil
+		}
+
+		if request.GetBool("reject", false) {
+			op, err := gate.Reject(token)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to reject operation: %v", err)), nil
+			}
+
+			result := RejectedOperationResponse{
+				Status: "rejected",
+				Kind:   op.Kind,
+				Tool:   op.Tool,
+				VMName: op.VMName,
+			}
+			jsonData, err := json.Marshal(result)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
+			}
+			return mcp.NewToolResultText(string(jsonData)), nil
+		}
+
+		op, result, err := gate.Approve(ctx, token)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to approve operation: %v", err)), nil
+		}
+
+		log.Info().Str("kind", op.Kind).Str("tool", op.Tool).Str("vm", op.VMName).Msg("Approved operation executed")
+		return result, nil
+	}
+}
+
+// approvalRequiredResult parks an operation and returns the response asking for approval
+func approvalRequiredResult(gate *approval.Gate, kind, tool, vmName string, details map[string]interface{}, action approval.Action) (*mcp.CallToolResult, error) {
+	op := gate.Park(kind, tool, vmName, details, action)
+
+	result := ApprovalRequiredResponse{
+		Status:    "approval_required",
+		ID:        op.ID,
+		Kind:      op.Kind,
+		Tool:      op.Tool,
+		VMName:    op.VMName,
+		Details:   op.Details,
+		ExpiresAt: op.ExpiresAt,
+		Message:   fmt.Sprintf("Operation '%s' (%s) requires human approval. Its approval token was given to the server's operator; ask the user to approve it, then call approve_operation with the token they provide.", op.Tool, op.ID),
+	}
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,121 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/artifacts"
+	"github.com/vagrant-mcp/server/internal/core"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// Range limits for get_artifact, in bytes
+const (
+	defaultArtifactLength = 64 * 1024
+	maxArtifactLength     = 1024 * 1024
+)
+
+// GetArtifactResponse is the result of get_artifact
+type GetArtifactResponse struct {
+	Artifact artifacts.Artifact `json:"artifact"`
+	Offset   int64              `json:"offset"`
+	Length   int64              `json:"length"`
+	// Encoding is "text" for UTF-8 content and "base64" otherwise
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+	EOF      bool   `json:"eof"`
+	// NextOffset continues the read after this range; unset at the end of the artifact
+	NextOffset int64 `json:"next_offset,omitempty"`
+}
+
+// RegisterArtifactTools registers the artifact tools with the MCP server
+func RegisterArtifactTools(srv *server.MCPServer, vmManager core.VMManager) {
+	// Get artifact tool
+	type GetArtifactArgs struct {
+		ID     string  `json:"id"`
+		Offset float64 `json:"offset"`
+		Length float64 `json:"length"`
+	}
+	getArtifactTool := mcp.NewTool("get_artifact",
+		mcp.WithDescription("Read a range of a stored artifact, such as the full output of a truncated command, a coverage report or a sync report. List artifacts with the devvm://artifacts resource"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("ID of the artifact, or its devvm://artifacts/{id} URI")),
+		mcp.WithNumber("offset",
+			mcp.Description("Byte offset to read from"),
+			mcp.DefaultNumber(0)),
+		mcp.WithNumber("length",
+			mcp.Description("Number of bytes to read (default: 65536, max: 1048576)"),
+			mcp.DefaultNumber(defaultArtifactLength)),
+	)
+
+	mcp_pkg.RegisterTypedTool(srv, getArtifactTool, func(ctx context.Context, request mcp.CallToolRequest, args GetArtifactArgs) (*mcp.CallToolResult, error) {
+		id := strings.TrimPrefix(args.ID, artifacts.URIPrefix)
+		if id == "" {
+			return mcp.NewToolResultError("Missing required parameter: id"), nil
+		}
+		length := int64(args.Length)
+		if length == 0 {
+			length = defaultArtifactLength
+		}
+		if args.Offset < 0 || length < 0 || length > maxArtifactLength {
+			return mcp.NewToolResultErrorf("'offset' must not be negative and 'length' must be between 1 and %d", maxArtifactLength), nil
+		}
+
+		store := artifacts.NewStore(vmManager.GetBaseDir())
+		artifact, data, err := store.ReadRange(id, int64(args.Offset), length)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to read artifact: %v", err), nil
+		}
+		response := GetArtifactResponse{
+			Artifact: artifact,
+			Offset:   int64(args.Offset),
+		}
+		var consumed int
+		response.Encoding, response.Content, consumed = encodeArtifactContent(data)
+		response.Length = int64(consumed)
+		end := response.Offset + response.Length
+		response.EOF = end >= artifact.Size
+		if !response.EOF {
+			response.NextOffset = end
+		}
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	log.Info().Msg("Artifact tools registered")
+}
+
+// encodeArtifactContent returns a range of an artifact as text, or as base64 when it is not
+// UTF-8, and the number of bytes returned. Text cut inside a multi-byte character leaves the
+// partial character to the next range.
+func encodeArtifactContent(data []byte) (string, string, int) {
+	text := data
+	for i := 0; i < utf8.UTFMax-1 && len(text) > 0; i++ {
+		r, size := utf8.DecodeLastRune(text)
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		text = text[:len(text)-1]
+	}
+	if len(text) > 0 && utf8.Valid(text) {
+		return "text", string(text), len(text)
+	}
+	if len(data) == 0 {
+		return "text", "", 0
+	}
+	return "base64", base64.StdEncoding.EncodeToString(data), len(data)
+}
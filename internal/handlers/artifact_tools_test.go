@@ -0,0 +1,27 @@
+package handlers
+
+import "testing"
+
+func TestEncodeArtifactContent(t *testing.T) {
+	testCases := []struct {
+		name             string
+		data             []byte
+		expectedEncoding string
+		expectedContent  string
+		expectedLength   int
+	}{
+		{"text", []byte("ok\n"), "text", "ok\n", 3},
+		{"empty", []byte{}, "text", "", 0},
+		{"cut character", []byte("caf\xc3"), "text", "caf", 3},
+		{"whole character", []byte("caf\xc3\xa9"), "text", "café", 5},
+		{"binary", []byte{0xff, 0xfe, 0x00, 0x01}, "base64", "//4AAQ==", 4},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoding, content, length := encodeArtifactContent(tc.data)
+			if encoding != tc.expectedEncoding || content != tc.expectedContent || length != tc.expectedLength {
+				t.Errorf("Expected %s %q (%d bytes) but got %s %q (%d bytes)", tc.expectedEncoding, tc.expectedContent, tc.expectedLength, encoding, content, length)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// BackupVMDataResponse is the result of backup_vm_data
+type BackupVMDataResponse struct {
+	Backup vm.Backup `json:"backup"`
+	// Removed are the oldest backups removed to keep the retention
+	Removed []vm.Backup `json:"removed"`
+	// Backups are the backups of the VM that are kept, newest first
+	Backups []vm.Backup `json:"backups"`
+}
+
+// RestoreVMDataResponse is the result of restore_vm_data
+type RestoreVMDataResponse struct {
+	Name   string    `json:"name"`
+	Backup vm.Backup `json:"backup"`
+}
+
+// RegisterBackupTools registers the guest data backup tools with the MCP server
+func RegisterBackupTools(srv *server.MCPServer, vmManager core.VMManager) {
+	// Backup VM data tool
+	type BackupVMDataArgs struct {
+		Name  string   `json:"name"`
+		Paths []string `json:"paths"`
+		Keep  int      `json:"keep"`
+	}
+	backupTool := mcp.NewTool("backup_vm_data",
+		mcp.WithDescription("Archive project data from a running VM, such as the synced project, the home directory and dumps of its databases, to a timestamped backup on the host that outlives the VM. The oldest backups beyond the retention are removed"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithArray("paths",
+			mcp.Description(fmt.Sprintf("What to back up: the presets '%s' (the synced project directory), '%s' (/home/vagrant) and '%s' (dumps of the running PostgreSQL, MySQL, MongoDB and Redis servers), or absolute guest paths (default: ['%s', '%s'])",
+				vm.BackupPresetProject, vm.BackupPresetHome, vm.BackupPresetDatabases, vm.BackupPresetProject, vm.BackupPresetHome)),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("keep",
+			mcp.Description(fmt.Sprintf("Number of backups of the VM to keep, including this one (default: %d)", vm.DefaultBackupRetention))),
+	)
+	mcp_pkg.RegisterTypedTool(srv, backupTool, func(ctx context.Context, request mcp.CallToolRequest, args BackupVMDataArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		if args.Keep == 0 {
+			args.Keep = vm.DefaultBackupRetention
+		}
+		config, err := vmManager.GetVMConfig(ctx, args.Name)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get VM config: %v", err), nil
+		}
+		paths, err := vm.BackupPaths(args.Paths, guestProjectRoot(config))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.Name); result != nil {
+			return result, nil
+		}
+		sshArgs, err := vmSSHArgs(ctx, vmManager, args.Name)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get SSH configuration: %v", err), nil
+		}
+		backup, removed, err := vm.CreateBackup(ctx, vmManager.GetBaseDir(), args.Name, sshArgs, paths, args.Keep)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to back up VM data: %v", err), nil
+		}
+		backups, err := vm.ListBackups(vmManager.GetBaseDir(), args.Name)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to list backups: %v", err), nil
+		}
+		if removed == nil {
+			removed = []vm.Backup{}
+		}
+		jsonResponse, err := json.Marshal(BackupVMDataResponse{Backup: backup, Removed: removed, Backups: backups})
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Restore VM data tool
+	type RestoreVMDataArgs struct {
+		Name   string `json:"name"`
+		Backup string `json:"backup"`
+	}
+	restoreTool := mcp.NewTool("restore_vm_data",
+		mcp.WithDescription("Restore a backup taken by backup_vm_data into a running VM, e.g. after it was destroyed and recreated or an experiment went wrong. Files in the backup overwrite those in the VM; other files are left alone. Database dumps are loaded into the running servers"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM the backup was taken from")),
+		mcp.WithString("backup",
+			mcp.Description("ID of the backup to restore (default: the latest)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, restoreTool, func(ctx context.Context, request mcp.CallToolRequest, args RestoreVMDataArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.Name); result != nil {
+			return result, nil
+		}
+		sshArgs, err := vmSSHArgs(ctx, vmManager, args.Name)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get SSH configuration: %v", err), nil
+		}
+		backup, err := vm.RestoreBackup(ctx, vmManager.GetBaseDir(), args.Name, args.Backup, sshArgs)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return mcp.NewToolResultErrorf("%v; backups of VM '%s': %s", err, args.Name, backupIDs(vmManager.GetBaseDir(), args.Name)), nil
+			}
+			return mcp.NewToolResultErrorf("Failed to restore VM data: %v", err), nil
+		}
+		jsonResponse, err := json.Marshal(RestoreVMDataResponse{Name: args.Name, Backup: backup})
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Backup tools registered")
+}
+
+// backupIDs lists the IDs of a VM's backups for error messages
+func backupIDs(baseDir, vmName string) string {
+	backups, err := vm.ListBackups(baseDir, vmName)
+	if err != nil || len(backups) == 0 {
+		return "none"
+	}
+	ids := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		ids = append(ids, backup.ID)
+	}
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,309 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/hooks"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// Batch parallelism limits
+const (
+	defaultBatchParallelism = 4
+	maxBatchParallelism     = 16
+)
+
+// Statuses of a VM in a batch result
+const (
+	batchSkipped          = "skipped"
+	batchFailed           = "failed"
+	batchApprovalRequired = "approval_required"
+)
+
+// vmSelector picks the managed VMs a batch tool acts on. Empty fields match every VM.
+type vmSelector struct {
+	Names []string
+	// Pattern is a glob matched against VM names, e.g. shop-*
+	Pattern string
+	Tag     string
+}
+
+// empty reports whether the selector matches every VM
+func (s vmSelector) empty() bool {
+	return len(s.Names) == 0 && s.Pattern == "" && s.Tag == ""
+}
+
+// batchResult is the outcome of a batch operation on one VM
+type batchResult struct {
+	VMName     string `json:"vm_name"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	// Hooks are the results of the lifecycle hooks run for the VM
+	Hooks []hooks.Result `json:"hooks,omitempty"`
+}
+
+// BatchResponse is the result of stop_all_vms, destroy_vms and sync_all
+type BatchResponse struct {
+	Operation string        `json:"operation"`
+	Results   []batchResult `json:"results"`
+	Total     int           `json:"total"`
+	// Counts is the number of VMs with each status
+	Counts map[string]int `json:"counts"`
+}
+
+// batchAction runs a batch operation on the VM of a result and sets its status and message; a
+// failed operation returns an error instead
+type batchAction func(ctx context.Context, result *batchResult) error
+
+// selectVMs returns the VMs among names matching every field of the selector. tags returns the
+// tags of a VM.
+func selectVMs(names []string, selector vmSelector, tags func(vmName string) []string) ([]string, error) {
+	if selector.Pattern != "" {
+		if _, err := path.Match(selector.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name_pattern '%s': %w", selector.Pattern, err)
+		}
+	}
+	for _, name := range selector.Names {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("VM '%s' not found", name)
+		}
+	}
+	selected := []string{}
+	for _, name := range names {
+		if len(selector.Names) > 0 && !slices.Contains(selector.Names, name) {
+			continue
+		}
+		if selector.Pattern != "" {
+			if matched, _ := path.Match(selector.Pattern, name); !matched {
+				continue
+			}
+		}
+		if selector.Tag != "" && !slices.Contains(tags(name), selector.Tag) {
+			continue
+		}
+		selected = append(selected, name)
+	}
+	return selected, nil
+}
+
+// selectManagedVMs returns the managed VMs matching the selector, sorted by name
+func selectManagedVMs(ctx context.Context, vmManager core.VMManager, selector vmSelector) ([]string, error) {
+	names, err := vmManager.ListVMs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	slices.Sort(names)
+	return selectVMs(names, selector, func(vmName string) []string {
+		config, err := vmManager.GetVMConfig(ctx, vmName)
+		if err != nil {
+			return nil
+		}
+		return config.Tags
+	})
+}
+
+// runBatch runs action on the VMs, at most parallelism at a time, and returns their results in
+// the order of names
+func runBatch(ctx context.Context, names []string, parallelism int, action batchAction) []batchResult {
+	if parallelism <= 0 {
+		parallelism = defaultBatchParallelism
+	}
+	parallelism = min(parallelism, maxBatchParallelism)
+
+	results := make([]batchResult, len(names))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			started := time.Now()
+			result := &results[i]
+			result.VMName = name
+			if err := action(ctx, result); err != nil {
+				result.Status, result.Message = batchFailed, err.Error()
+			}
+			result.DurationMs = time.Since(started).Milliseconds()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// batchResponse returns the tool result aggregating the results of a batch operation
+func batchResponse(operation string, results []batchResult) (*mcp.CallToolResult, error) {
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	response := BatchResponse{
+		Operation: operation,
+		Results:   results,
+		Total:     len(results),
+		Counts:    counts,
+	}
+	jsonData, err := json.Marshal(response)
+	if err != nil {
+		return mcp.NewToolResultError("Failed to marshal response"), nil
+	}
+	if counts[batchFailed] > 0 {
+		log.Warn().Str("operation", operation).Int("failed", counts[batchFailed]).Int("total", len(results)).Msg("Batch operation failed on some VMs")
+	}
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// RegisterBatchTools registers the tools that act on several VMs in one call
+func RegisterBatchTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine core.SyncEngine) {
+	type BatchArgs struct {
+		Names       []string `json:"names"`
+		NamePattern string   `json:"name_pattern"`
+		Tag         string   `json:"tag"`
+		Parallelism float64  `json:"parallelism"`
+	}
+	selectorOptions := func(scope string) []mcp.ToolOption {
+		return []mcp.ToolOption{
+			mcp.WithArray("names",
+				mcp.Description("Names of the VMs to "+scope),
+				mcp.Items(map[string]any{"type": "string"})),
+			mcp.WithString("name_pattern",
+				mcp.Description("Glob matched against VM names, e.g. shop-*")),
+			mcp.WithString("tag",
+				mcp.Description("Only VMs created with this tag")),
+			mcp.WithNumber("parallelism",
+				mcp.Description(fmt.Sprintf("VMs processed at once, up to %d", maxBatchParallelism)),
+				mcp.DefaultNumber(defaultBatchParallelism)),
+		}
+	}
+
+	// Stop all VMs tool
+	stopAllTool := mcp.NewTool("stop_all_vms", append([]mcp.ToolOption{
+		mcp.WithDescription("Halt the running development VMs, all of them or those matching names, name_pattern and tag, in parallel, reporting the outcome for each VM"),
+	}, selectorOptions("halt (default: all)")...)...)
+	mcp_pkg.RegisterTypedTool(srv, stopAllTool, func(ctx context.Context, request mcp.CallToolRequest, args BatchArgs) (*mcp.CallToolResult, error) {
+		names, err := selectManagedVMs(ctx, vmManager, vmSelector{Names: args.Names, Pattern: args.NamePattern, Tag: args.Tag})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		stop := func(ctx context.Context) (*mcp.CallToolResult, error) {
+			results := runBatch(ctx, names, int(args.Parallelism), func(ctx context.Context, result *batchResult) error {
+				state, err := vmManager.GetVMState(ctx, result.VMName)
+				if err != nil {
+					return fmt.Errorf("failed to get VM state: %w", err)
+				}
+				if state != core.Running {
+					result.Status, result.Message = batchSkipped, fmt.Sprintf("VM is %s", state)
+					return nil
+				}
+				if err := vmManager.StopVM(ctx, result.VMName); err != nil {
+					return err
+				}
+				result.Status = "stopped"
+				return nil
+			})
+			return batchResponse("stop", results)
+		}
+		if len(names) > 1 && approval.GlobalGate.Requires(approval.OperationBulkHalt) {
+			return approvalRequiredResult(approval.GlobalGate, approval.OperationBulkHalt, "stop_all_vms", "", map[string]interface{}{"vms": names}, stop)
+		}
+		return stop(ctx)
+	})
+
+	// Destroy VMs tool
+	destroyVMsTool := mcp.NewTool("destroy_vms", append([]mcp.ToolOption{
+		mcp.WithDescription("Destroy the development VMs matching names, name_pattern and tag in parallel, reporting the outcome for each VM; at least one of them is required"),
+	}, selectorOptions("destroy")...)...)
+	mcp_pkg.RegisterTypedTool(srv, destroyVMsTool, func(ctx context.Context, request mcp.CallToolRequest, args BatchArgs) (*mcp.CallToolResult, error) {
+		selector := vmSelector{Names: args.Names, Pattern: args.NamePattern, Tag: args.Tag}
+		// Destroying every VM must be asked for explicitly, e.g. with name_pattern '*'
+		if selector.empty() {
+			return mcp.NewToolResultError("Missing required parameter: names, name_pattern or tag"), nil
+		}
+		names, err := selectManagedVMs(ctx, vmManager, selector)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		destroy := func(ctx context.Context) (*mcp.CallToolResult, error) {
+			results := runBatch(ctx, names, int(args.Parallelism), func(ctx context.Context, result *batchResult) error {
+				target := hookTarget(ctx, vmManager, result.VMName)
+				var err error
+				if result.Hooks, err = hooks.GlobalRunner.Run(ctx, hooks.PreDestroy, target); err != nil {
+					return err
+				}
+				if err := vmManager.DestroyVM(ctx, result.VMName); err != nil {
+					return err
+				}
+				postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostDestroy, target)
+				result.Hooks = append(result.Hooks, postResults...)
+				result.Status = "destroyed"
+				return nil
+			})
+			return batchResponse("destroy", results)
+		}
+		if len(names) > 0 && approval.GlobalGate.Requires(approval.OperationDestroyVM) {
+			return approvalRequiredResult(approval.GlobalGate, approval.OperationDestroyVM, "destroy_vms", "", map[string]interface{}{"vms": names}, destroy)
+		}
+		return destroy(ctx)
+	})
+
+	// Sync all tool
+	syncAllTool := mcp.NewTool("sync_all", append([]mcp.ToolOption{
+		mcp.WithDescription("Sync the project files of the running development VMs, all of them or those matching names, name_pattern and tag, from the host in parallel, reporting the outcome for each VM. VMs whose sync needs deletion approval are left for sync_to_vm"),
+	}, selectorOptions("sync (default: all)")...)...)
+	mcp_pkg.RegisterTypedTool(srv, syncAllTool, func(ctx context.Context, request mcp.CallToolRequest, args BatchArgs) (*mcp.CallToolResult, error) {
+		names, err := selectManagedVMs(ctx, vmManager, vmSelector{Names: args.Names, Pattern: args.NamePattern, Tag: args.Tag})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		results := runBatch(ctx, names, int(args.Parallelism), func(ctx context.Context, result *batchResult) error {
+			state, err := vmManager.GetVMState(ctx, result.VMName)
+			if err != nil {
+				return fmt.Errorf("failed to get VM state: %w", err)
+			}
+			if state != core.Running {
+				result.Status, result.Message = batchSkipped, fmt.Sprintf("VM is %s", state)
+				return nil
+			}
+			if details := syncDeletionApprovalDetails(ctx, syncEngine, vmManager, result.VMName, "to_vm"); details != nil {
+				result.Status = batchApprovalRequired
+				result.Message = "the files the sync would delete could not be estimated; run sync_to_vm to approve it"
+				if deletions, ok := details["deletions"].(int); ok {
+					result.Message = fmt.Sprintf("the sync would delete %d files in the VM; run sync_to_vm to approve it", deletions)
+				}
+				return nil
+			}
+			target := hookTarget(ctx, vmManager, result.VMName)
+			if result.Hooks, err = hooks.GlobalRunner.Run(ctx, hooks.PreSync, target); err != nil {
+				return err
+			}
+			synced, err := syncEngine.SyncToVM(ctx, result.VMName, "")
+			if err != nil {
+				return err
+			}
+			postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostSync, target)
+			result.Hooks = append(result.Hooks, postResults...)
+			result.Status, result.Message = "synced", fmt.Sprintf("%d files in %dms", len(synced.SyncedFiles), synced.SyncTimeMs)
+			return nil
+		})
+		return batchResponse("sync", results)
+	})
+
+	log.Info().Msg("Batch tools registered")
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSelectVMs(t *testing.T) {
+	names := []string{"api", "shop-db", "shop-web", "tools"}
+	tags := map[string][]string{
+		"api":      {"backend"},
+		"shop-db":  {"shop", "backend"},
+		"shop-web": {"shop"},
+	}
+
+	testCases := []struct {
+		name      string
+		selector  vmSelector
+		expected  []string
+		expectErr bool
+	}{
+		{"empty selects all", vmSelector{}, names, false},
+		{"names", vmSelector{Names: []string{"tools", "api"}}, []string{"api", "tools"}, false},
+		{"pattern", vmSelector{Pattern: "shop-*"}, []string{"shop-db", "shop-web"}, false},
+		{"tag", vmSelector{Tag: "backend"}, []string{"api", "shop-db"}, false},
+		{"pattern and tag", vmSelector{Pattern: "shop-*", Tag: "backend"}, []string{"shop-db"}, false},
+		{"no match", vmSelector{Tag: "frontend"}, []string{}, false},
+		{"unknown name", vmSelector{Names: []string{"missing"}}, nil, true},
+		{"invalid pattern", vmSelector{Pattern: "shop-["}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := selectVMs(names, tc.selector, func(vmName string) []string { return tags[vmName] })
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("Expected an error but got %v", selected)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if !reflect.DeepEqual(selected, tc.expected) {
+				t.Errorf("Expected %v but got %v", tc.expected, selected)
+			}
+		})
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	var running, peak int32
+	names := []string{"a", "b", "c", "d", "e"}
+	results := runBatch(context.Background(), names, 2, func(ctx context.Context, result *batchResult) error {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(&peak)
+			if current <= seen || atomic.CompareAndSwapInt32(&peak, seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		switch result.VMName {
+		case "b":
+			return errors.New("halt failed")
+		case "d":
+			result.Status, result.Message = batchSkipped, "VM is stopped"
+			return nil
+		}
+		result.Status = "stopped"
+		return nil
+	})
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 VMs at once but got %d", peak)
+	}
+	expected := []struct{ name, status, message string }{
+		{"a", "stopped", ""},
+		{"b", batchFailed, "halt failed"},
+		{"c", "stopped", ""},
+		{"d", batchSkipped, "VM is stopped"},
+		{"e", "stopped", ""},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results but got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		if result.VMName != expected[i].name || result.Status != expected[i].status || result.Message != expected[i].message {
+			t.Errorf("Expected result %+v but got %+v", expected[i], result)
+		}
+	}
+}
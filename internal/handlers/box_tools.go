@@ -0,0 +1,127 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/boxes"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// maxBoxVersions bounds the versions search_boxes returns for a single box
+const maxBoxVersions = 10
+
+// SearchBoxesResponse is the result of search_boxes with a query
+type SearchBoxesResponse struct {
+	Provider     string      `json:"provider"`
+	Architecture string      `json:"architecture"`
+	Query        string      `json:"query"`
+	Boxes        []boxes.Box `json:"boxes"`
+	Count        int         `json:"count"`
+}
+
+// BoxVersionsResponse is the result of search_boxes with a box
+type BoxVersionsResponse struct {
+	Provider     string    `json:"provider"`
+	Architecture string    `json:"architecture"`
+	Box          boxes.Box `json:"box"`
+}
+
+// RegisterBoxTools registers the Vagrant Cloud box tools with the MCP server
+func RegisterBoxTools(srv *server.MCPServer, client *boxes.Client) {
+	// Search boxes tool
+	type SearchBoxesArgs struct {
+		Query        string `json:"query"`
+		Box          string `json:"box"`
+		Provider     string `json:"provider"`
+		Architecture string `json:"architecture"`
+		Limit        int    `json:"limit"`
+		IncludeSizes *bool  `json:"include_sizes"`
+	}
+	searchBoxesTool := mcp.NewTool("search_boxes",
+		mcp.WithDescription("Search Vagrant Cloud for boxes by keyword, provider and architecture, most downloaded first, with their current version and download sizes. Pass 'box' instead of 'query' to list the versions of one box. Use it to pick a box that runs on the host, e.g. an arm64 box on Apple Silicon, instead of guessing a box name"),
+		mcp.WithString("query",
+			mcp.Description("Keywords to search for, e.g. 'ubuntu 22.04' or 'debian'")),
+		mcp.WithString("box",
+			mcp.Description("Box to describe instead of searching, e.g. 'bento/ubuntu-22.04'")),
+		mcp.WithString("provider",
+			mcp.Description("Only boxes built for this provider, e.g. virtualbox, libvirt, qemu, vmware_desktop, parallels, hyperv or docker")),
+		mcp.WithString("architecture",
+			mcp.Description("Only boxes built for this architecture, e.g. amd64 or arm64")),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of boxes to return (default: 10, at most 50)")),
+		mcp.WithBoolean("include_sizes",
+			mcp.Description("Measure the download size of each listed provider build"),
+			mcp.DefaultBool(true)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, searchBoxesTool, func(ctx context.Context, request mcp.CallToolRequest, args SearchBoxesArgs) (*mcp.CallToolResult, error) {
+		if args.Query == "" && args.Box == "" {
+			return mcp.NewToolResultError("Missing required parameter: query or box"), nil
+		}
+		architecture := boxes.NormalizeArchitecture(args.Architecture)
+		includeSizes := args.IncludeSizes == nil || *args.IncludeSizes
+
+		var response interface{}
+		if args.Box != "" {
+			box, err := client.GetBox(ctx, args.Box, args.Provider, architecture)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Failed to get box '%s': %v", args.Box, err), nil
+			}
+			if len(box.Versions) > maxBoxVersions {
+				box.Versions = box.Versions[:maxBoxVersions]
+			}
+			if includeSizes {
+				var providers []*boxes.Provider
+				for i := range box.Versions {
+					for j := range box.Versions[i].Providers {
+						providers = append(providers, &box.Versions[i].Providers[j])
+					}
+				}
+				client.MeasureSizes(ctx, providers)
+			}
+			// The versions list repeats the current version
+			box.CurrentVersion = nil
+			response = BoxVersionsResponse{Provider: args.Provider, Architecture: architecture, Box: box}
+		} else {
+			found, err := client.Search(ctx, boxes.SearchOptions{
+				Query:        args.Query,
+				Provider:     args.Provider,
+				Architecture: architecture,
+				Limit:        args.Limit,
+			})
+			if err != nil {
+				return mcp.NewToolResultErrorf("Failed to search Vagrant Cloud: %v", err), nil
+			}
+			if includeSizes {
+				var providers []*boxes.Provider
+				for i := range found {
+					for j := range found[i].CurrentVersion.Providers {
+						providers = append(providers, &found[i].CurrentVersion.Providers[j])
+					}
+				}
+				client.MeasureSizes(ctx, providers)
+			}
+			response = SearchBoxesResponse{
+				Provider:     args.Provider,
+				Architecture: architecture,
+				Query:        args.Query,
+				Boxes:        found,
+				Count:        len(found),
+			}
+		}
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	log.Info().Msg("Box tools registered")
+}
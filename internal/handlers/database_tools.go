@@ -0,0 +1,336 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/audit"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/database"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/tunnel"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// CreateDatabaseResponse is the result of create_database
+type CreateDatabaseResponse struct {
+	VMName   string `json:"vm_name"`
+	Engine   string `json:"engine"`
+	Database string `json:"database"`
+	Status   string `json:"status"`
+	User     string `json:"user,omitempty"`
+	// Forward and ConnectionURL are set when the database port is reachable on the host,
+	// ForwardError when forwarding it failed
+	Forward       *HostForward `json:"forward,omitempty"`
+	ConnectionURL string       `json:"connection_url,omitempty"`
+	ForwardError  string       `json:"forward_error,omitempty"`
+}
+
+// RunSQLResponse is the result of run_sql
+type RunSQLResponse struct {
+	VMName   string `json:"vm_name"`
+	Engine   string `json:"engine"`
+	Database string `json:"database"`
+	// Format is csv for PostgreSQL and tsv for MySQL
+	Format  string `json:"format"`
+	Output  string `json:"output"`
+	Notices string `json:"notices,omitempty"`
+}
+
+// DumpDatabaseResponse is the result of dump_database
+type DumpDatabaseResponse struct {
+	VMName     string `json:"vm_name"`
+	Engine     string `json:"engine"`
+	Database   string `json:"database"`
+	OutputPath string `json:"output_path"`
+	SizeBytes  int64  `json:"size_bytes"`
+	SchemaOnly bool   `json:"schema_only"`
+}
+
+// RegisterDatabaseTools registers the tools that manage the PostgreSQL and MySQL servers
+// installed in VMs
+func RegisterDatabaseTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor, tunnels *tunnel.Manager) {
+	// Create database tool
+	type CreateDatabaseArgs struct {
+		VMName   string  `json:"vm_name"`
+		Engine   string  `json:"engine"`
+		Name     string  `json:"name"`
+		User     string  `json:"user"`
+		Password string  `json:"password"`
+		Forward  *bool   `json:"forward"`
+		HostPort float64 `json:"host_port"`
+	}
+	createDatabaseTool := mcp.NewTool("create_database",
+		mcp.WithDescription("Create a database, and optionally a user owning it, in the PostgreSQL or MySQL server installed in a VM, and forward the server's port to the host"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("engine",
+			mcp.Required(),
+			mcp.Description("Database server"),
+			mcp.Enum(database.Engines...)),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the database (letters, digits and underscores)")),
+		mcp.WithString("user",
+			mcp.Description("User to create and grant the database to (letters, digits and underscores)")),
+		mcp.WithString("password",
+			mcp.Description("Password of the user; required with user")),
+		mcp.WithBoolean("forward",
+			mcp.Description("Make the server reachable from the host, opening a tunnel when needed"),
+			mcp.DefaultBool(true)),
+		mcp.WithNumber("host_port",
+			mcp.Description("Host port of a tunnel opened for the server (default: the server's port when free, otherwise any free port)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, createDatabaseTool, func(ctx context.Context, request mcp.CallToolRequest, args CreateDatabaseArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Engine == "" || args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name, engine or name"), nil
+		}
+		script, err := database.CreateDatabase(args.Engine, args.Name, args.User, args.Password)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+		execCtx := exec.ExecutionContext{VMName: args.VMName}
+		if args.Password != "" {
+			// Keep the password out of the audit log
+			if redacted, err := database.CreateDatabase(args.Engine, args.Name, args.User, audit.Redacted); err == nil {
+				execCtx.AuditCommand = redacted.Command(args.Engine, "")
+			}
+		}
+		result, err := executor.ExecuteCommand(ctx, script.Command(args.Engine, ""), execCtx, nil)
+		if err != nil {
+			return commandFailedResult("Failed to create database", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return databaseFailedResult("Failed to create database", args.Engine, result), nil
+		}
+
+		response := CreateDatabaseResponse{
+			VMName:   args.VMName,
+			Engine:   args.Engine,
+			Database: args.Name,
+			Status:   "created",
+			User:     args.User,
+		}
+		if args.Forward == nil || *args.Forward {
+			// Both servers listen on the guest's loopback only in their default configuration
+			vmConfig, err := vmManager.GetVMConfig(ctx, args.VMName)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Database created but the VM config is unavailable: %v", err), nil
+			}
+			hostPort, forward, err := ensureHostForward(ctx, executor, tunnels, vmConfig, args.VMName, database.DefaultPort(args.Engine), int(args.HostPort), true)
+			if err != nil {
+				response.ForwardError = fmt.Sprintf("the %s port is not forwarded: %v", args.Engine, err)
+			} else {
+				response.Forward = &forward
+				response.ConnectionURL = connectionURL(args.Engine, args.User, args.Name, hostPort)
+			}
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Run SQL tool
+	type RunSQLArgs struct {
+		VMName         string   `json:"vm_name"`
+		Engine         string   `json:"engine"`
+		Database       string   `json:"database"`
+		SQL            string   `json:"sql"`
+		Params         []string `json:"params"`
+		TimeoutSeconds float64  `json:"timeout_seconds"`
+	}
+	runSQLTool := mcp.NewTool("run_sql",
+		mcp.WithDescription("Run SQL as the database superuser against the PostgreSQL or MySQL server installed in a VM. Pass values as params instead of writing them into the SQL: PostgreSQL refers to them as :'p1', :'p2', ...; MySQL as @p1, @p2, ..."),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("engine",
+			mcp.Required(),
+			mcp.Description("Database server"),
+			mcp.Enum(database.Engines...)),
+		mcp.WithString("database",
+			mcp.Description("Database to connect to (default: the server's default database)")),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("SQL statements to run; execution stops at the first error")),
+		mcp.WithArray("params",
+			mcp.Description("Values bound to the SQL as p1, p2, ..."),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum run time in seconds (default: the server's maximum)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, runSQLTool, func(ctx context.Context, request mcp.CallToolRequest, args RunSQLArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Engine == "" || strings.TrimSpace(args.SQL) == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name, engine or sql"), nil
+		}
+		if args.Database != "" {
+			if err := database.ValidateIdentifier(args.Database); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		script, err := database.Query(args.Engine, args.SQL, args.Params)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+		execCtx := exec.ExecutionContext{
+			VMName:  args.VMName,
+			Timeout: secondsToDuration(args.TimeoutSeconds),
+		}
+		result, err := executor.ExecuteCommand(ctx, script.Command(args.Engine, args.Database), execCtx, nil)
+		if err != nil {
+			return commandFailedResult("SQL failed", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return databaseFailedResult("SQL failed", args.Engine, result), nil
+		}
+		format := "csv"
+		if args.Engine == database.EngineMySQL {
+			format = "tsv"
+		}
+		response := RunSQLResponse{
+			VMName:   args.VMName,
+			Engine:   args.Engine,
+			Database: args.Database,
+			Format:   format,
+			Output:   result.Stdout,
+			Notices:  strings.TrimSpace(result.Stderr),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Dump database tool
+	type DumpDatabaseArgs struct {
+		VMName     string `json:"vm_name"`
+		Engine     string `json:"engine"`
+		Database   string `json:"database"`
+		OutputPath string `json:"output_path"`
+		SchemaOnly bool   `json:"schema_only"`
+	}
+	dumpDatabaseTool := mcp.NewTool("dump_database",
+		mcp.WithDescription("Write an SQL dump of a database in a VM to a file on the host"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("engine",
+			mcp.Required(),
+			mcp.Description("Database server"),
+			mcp.Enum(database.Engines...)),
+		mcp.WithString("database",
+			mcp.Required(),
+			mcp.Description("Database to dump")),
+		mcp.WithString("output_path",
+			mcp.Required(),
+			mcp.Description("Host path of the dump file; an existing file is replaced")),
+		mcp.WithBoolean("schema_only",
+			mcp.Description("Dump the schema without the data"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, dumpDatabaseTool, func(ctx context.Context, request mcp.CallToolRequest, args DumpDatabaseArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Engine == "" || args.Database == "" || args.OutputPath == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name, engine, database or output_path"), nil
+		}
+		command, err := database.DumpCommand(args.Engine, args.Database, args.SchemaOnly)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+		sshArgs, err := executor.SSHArgs(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get SSH configuration: %v", err), nil
+		}
+
+		outputPath, err := filepath.Abs(args.OutputPath)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Invalid output path: %v", err), nil
+		}
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return mcp.NewToolResultErrorf("Failed to create output directory: %v", err), nil
+		}
+		// Write next to the target so a failed dump leaves an existing file untouched
+		file, err := os.CreateTemp(filepath.Dir(outputPath), filepath.Base(outputPath)+".*.tmp")
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to create dump file: %v", err), nil
+		}
+		dumpErr := database.Dump(ctx, args.VMName, sshArgs, command, file)
+		closeErr := file.Close()
+		if dumpErr == nil {
+			dumpErr = closeErr
+		}
+		if dumpErr == nil {
+			dumpErr = os.Rename(file.Name(), outputPath)
+		}
+		if dumpErr != nil {
+			os.Remove(file.Name())
+			return mcp.NewToolResultErrorf("Failed to dump database: %v", dumpErr), nil
+		}
+		info, err := os.Stat(outputPath)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to read dump file: %v", err), nil
+		}
+
+		response := DumpDatabaseResponse{
+			VMName:     args.VMName,
+			Engine:     args.Engine,
+			Database:   args.Database,
+			OutputPath: outputPath,
+			SizeBytes:  info.Size(),
+			SchemaOnly: args.SchemaOnly,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Database tools registered")
+}
+
+// databaseFailedResult returns the tool error for a database client that exited with an error,
+// pointing to setup_dev_environment when the server is not installed
+func databaseFailedResult(message, engine string, result *exec.CommandResult) *mcp.CallToolResult {
+	output := strings.TrimSpace(result.Stderr + result.Stdout)
+	if result.ExitCode == 127 || strings.Contains(output, "command not found") {
+		return mcp.NewToolResultErrorf("%s: %s is not installed in the VM; install it with install_dev_tools (tools: [\"%s\"])", message, engine, engine)
+	}
+	return mcp.NewToolResultErrorf("%s (exit code %d): %s", message, result.ExitCode, output)
+}
+
+// connectionURL returns the URL host tools connect to a forwarded database with, leaving out
+// the password
+func connectionURL(engine, user, name string, hostPort int) string {
+	scheme := "postgresql"
+	if engine == database.EngineMySQL {
+		scheme = "mysql"
+	}
+	userInfo := ""
+	if user != "" {
+		userInfo = user + "@"
+	}
+	return fmt.Sprintf("%s://%s127.0.0.1:%d/%s", scheme, userInfo, hostPort, name)
+}
@@ -0,0 +1,315 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/process"
+	"github.com/vagrant-mcp/server/internal/tunnel"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// Dev server startup limits
+const (
+	defaultDevServerWait  = 60 * time.Second
+	devServerPollInterval = time.Second
+	devServerLogLines     = 20
+)
+
+// Forward types reported by start_dev_server
+const (
+	forwardVagrant = "vagrant"
+	forwardTunnel  = "tunnel"
+)
+
+// HostForward describes how a guest port is reachable on the host
+type HostForward struct {
+	// Type is vagrant for a port forwarded in the Vagrantfile or tunnel for an SSH tunnel
+	Type     string `json:"type"`
+	HostPort int    `json:"host_port"`
+	TunnelID string `json:"tunnel_id,omitempty"`
+	// Opened is true when the tunnel was opened for this call
+	Opened bool `json:"opened,omitempty"`
+}
+
+// StartDevServerResponse is the result of start_dev_server
+type StartDevServerResponse struct {
+	Process         process.Process `json:"process"`
+	GuestPort       int             `json:"guest_port"`
+	ListenAddresses []string        `json:"listen_addresses"`
+	Forward         HostForward     `json:"forward"`
+	URL             string          `json:"url"`
+}
+
+// listenCommand prints the listening TCP sockets of the guest
+const listenCommand = "ss -Hltn"
+
+// RegisterDevServerTools registers the dev server tools with the MCP server
+func RegisterDevServerTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor, processes *process.Registry, tunnels *tunnel.Manager) {
+	// Start dev server tool
+	type StartDevServerArgs struct {
+		VMName         string  `json:"vm_name"`
+		Command        string  `json:"command"`
+		Name           string  `json:"name"`
+		WorkingDir     string  `json:"working_dir"`
+		Port           float64 `json:"port"`
+		HostPort       float64 `json:"host_port"`
+		Mode           string  `json:"mode"`
+		TimeoutSeconds float64 `json:"timeout_seconds"`
+	}
+	startDevServerTool := mcp.NewTool("start_dev_server",
+		mcp.WithDescription("Start a dev server (npm run dev, flask run, ...) as a background process in the VM, wait until it listens, make sure its port is reachable from the host and return the host URL"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("command",
+			mcp.Required(),
+			mcp.Description("Command that starts the dev server")),
+		mcp.WithString("name",
+			mcp.Description("Name to refer to the process by"),
+			mcp.DefaultString("dev-server")),
+		mcp.WithString("working_dir",
+			mcp.Description("Working directory in the guest (default: the synced project directory)")),
+		mcp.WithNumber("port",
+			mcp.Description("Port the server listens on in the guest (default: the first port it starts listening on)")),
+		mcp.WithNumber("host_port",
+			mcp.Description("Host port of a tunnel opened for the server (default: the guest port when free, otherwise any free port)")),
+		mcp.WithString("mode",
+			mcp.Description("How to run the process (see start_background_process)"),
+			mcp.Enum(process.Modes...),
+			mcp.DefaultString(process.ModeNohup)),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("How long to wait for the server to listen"),
+			mcp.DefaultNumber(defaultDevServerWait.Seconds())),
+	)
+	mcp_pkg.RegisterTypedTool(srv, startDevServerTool, func(ctx context.Context, request mcp.CallToolRequest, args StartDevServerArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Command == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or command"), nil
+		}
+		mode := args.Mode
+		if mode == "" {
+			mode = process.ModeNohup
+		}
+		if !isProcessMode(mode) {
+			return mcp.NewToolResultErrorf("Unsupported mode '%s' (must be one of %s)", mode, strings.Join(process.Modes, ", ")), nil
+		}
+		if args.Port < 0 || args.Port > 65535 {
+			return mcp.NewToolResultErrorf("Invalid port %d", int(args.Port)), nil
+		}
+		name := args.Name
+		if name == "" {
+			name = "dev-server"
+		}
+		if _, err := processes.Get(args.VMName, name); err == nil {
+			return mcp.NewToolResultErrorf("A background process named '%s' is already tracked in VM '%s'", name, args.VMName), nil
+		}
+		wait := defaultDevServerWait
+		if args.TimeoutSeconds > 0 {
+			wait = secondsToDuration(args.TimeoutSeconds)
+		}
+
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+		vmConfig, err := vmManager.GetVMConfig(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get VM config: %v", err), nil
+		}
+		workingDir := args.WorkingDir
+		if workingDir == "" {
+			workingDir = guestProjectRoot(vmConfig)
+		}
+
+		// Ports that were already open are not the dev server's
+		execCtx := exec.ExecutionContext{VMName: args.VMName}
+		before, err := executor.ExecuteCommand(ctx, listenCommand, execCtx, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to list listening ports: %v", err), nil
+		}
+		existing := parseListeningSockets(before.Stdout)
+		if port := int(args.Port); port > 0 && len(existing[port]) > 0 {
+			return mcp.NewToolResultErrorf("Port %d is already in use in VM '%s'", port, args.VMName), nil
+		}
+
+		proc := processes.New(args.VMName, name, args.Command, workingDir, mode)
+		if mode == process.ModeSystemd {
+			proc.LogFile = ""
+		}
+		startCtx := execCtx
+		startCtx.PolicyCommand, startCtx.PolicyDir = args.Command, workingDir
+		result, err := executor.ExecuteCommand(ctx, buildProcessStartCommand(proc), startCtx, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to start dev server: %v", err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to start dev server (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr+result.Stdout)), nil
+		}
+		proc.PID = parseProcessPID(result.Stdout)
+		processes.Add(proc)
+
+		guestPort, addresses, err := waitForDevServer(ctx, executor, proc, int(args.Port), existing, wait)
+		if err != nil {
+			logTail := ""
+			if logResult, logErr := executor.ExecuteCommand(ctx, buildProcessLogCommand(proc, devServerLogLines), execCtx, nil); logErr == nil {
+				logTail = strings.TrimSpace(logResult.Stdout)
+			}
+			return mcp.NewToolResultErrorf("%v\nLast output:\n%s", err, logTail), nil
+		}
+
+		hostPort, forward, err := ensureHostForward(ctx, executor, tunnels, vmConfig, args.VMName, guestPort, int(args.HostPort), loopbackOnly(addresses))
+		if err != nil {
+			return mcp.NewToolResultErrorf("Dev server listens on guest port %d but %v", guestPort, err), nil
+		}
+
+		response := StartDevServerResponse{
+			Process:         proc,
+			GuestPort:       guestPort,
+			ListenAddresses: addresses,
+			Forward:         forward,
+			URL:             fmt.Sprintf("http://127.0.0.1:%d", hostPort),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Dev server tools registered")
+}
+
+// ensureHostForward makes a guest port reachable on the host, through the VM's Vagrant forward
+// or an existing tunnel when there is one, otherwise through a new tunnel on hostPort or,
+// when it is 0, on the guest port if it is free. It returns the host port and a description
+// of the forward. A Vagrant forward reaches the guest's network interface, so services bound
+// to loopback always need a tunnel.
+func ensureHostForward(ctx context.Context, executor *exec.Executor, tunnels *tunnel.Manager, vmConfig core.VMConfig, vmName string, guestPort, hostPort int, loopback bool) (int, HostForward, error) {
+	if !loopback {
+		for _, port := range vmConfig.Ports {
+			if port.Guest == guestPort && port.Host > 0 {
+				return port.Host, HostForward{Type: forwardVagrant, HostPort: port.Host}, nil
+			}
+		}
+	}
+	for _, info := range tunnels.List(vmName) {
+		if info.Direction == tunnel.DirectionLocal && info.GuestPort == guestPort && info.TargetHost == "localhost" {
+			return info.HostPort, HostForward{Type: forwardTunnel, TunnelID: info.ID, HostPort: info.HostPort}, nil
+		}
+	}
+
+	sshArgs, err := executor.SSHArgs(ctx, vmName)
+	if err != nil {
+		return 0, HostForward{}, fmt.Errorf("its SSH configuration is unavailable: %w", err)
+	}
+	spec := tunnel.Spec{Direction: tunnel.DirectionLocal, HostPort: hostPort, GuestPort: guestPort}
+	if spec.HostPort == 0 && hostPortAvailable(guestPort) {
+		// Keep the port the service reports itself
+		spec.HostPort = guestPort
+	}
+	info, err := tunnels.Open(vmName, spec, sshArgs)
+	if err != nil {
+		return 0, HostForward{}, fmt.Errorf("the tunnel failed: %w", err)
+	}
+	return info.HostPort, HostForward{Type: forwardTunnel, TunnelID: info.ID, HostPort: info.HostPort, Opened: true}, nil
+}
+
+// waitForDevServer polls the guest until proc listens on port, or on any port not in existing
+// when port is 0, and returns the port and its listening addresses. It fails when proc exits or
+// wait elapses; the process is left running on timeout.
+func waitForDevServer(ctx context.Context, executor *exec.Executor, proc process.Process, port int, existing map[int][]string, wait time.Duration) (int, []string, error) {
+	command := buildProcessStatusCommand([]process.Process{proc}) + "; " + listenCommand
+	deadline := time.Now().Add(wait)
+	for {
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: proc.VMName}, nil)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to check the dev server: %w", err)
+		}
+		listening := parseListeningSockets(result.Stdout)
+		if found := newListeningPort(listening, existing, port); found > 0 {
+			return found, listening[found], nil
+		}
+		if parseProcessStates(result.Stdout)[proc.ID] == process.StateExited {
+			return 0, nil, fmt.Errorf("dev server '%s' exited before it listened on a port", proc.Name)
+		}
+		if time.Now().After(deadline) {
+			return 0, nil, fmt.Errorf("dev server '%s' did not listen on a port within %s; it keeps running as background process %s", proc.Name, wait, proc.ID)
+		}
+		select {
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		case <-time.After(devServerPollInterval):
+		}
+	}
+}
+
+// parseListeningSockets parses ss -Hltn output into listening addresses by port
+func parseListeningSockets(output string) map[int][]string {
+	sockets := make(map[int][]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "LISTEN" {
+			continue
+		}
+		local := fields[3]
+		sep := strings.LastIndex(local, ":")
+		if sep < 0 {
+			continue
+		}
+		port, err := strconv.Atoi(local[sep+1:])
+		if err != nil {
+			continue
+		}
+		sockets[port] = append(sockets[port], local[:sep])
+	}
+	return sockets
+}
+
+// newListeningPort returns port when it is listening, or the lowest listening port that is
+// not in existing when port is 0; it returns 0 when there is none
+func newListeningPort(listening, existing map[int][]string, port int) int {
+	if port > 0 {
+		if len(listening[port]) > 0 {
+			return port
+		}
+		return 0
+	}
+	var ports []int
+	for p := range listening {
+		if _, seen := existing[p]; !seen {
+			ports = append(ports, p)
+		}
+	}
+	if len(ports) == 0 {
+		return 0
+	}
+	sort.Ints(ports)
+	return ports[0]
+}
+
+// loopbackOnly reports whether every listening address is a loopback address
+func loopbackOnly(addresses []string) bool {
+	for _, address := range addresses {
+		address = strings.Trim(address, "[]")
+		if !strings.HasPrefix(address, "127.") && address != "::1" && address != "localhost" {
+			return false
+		}
+	}
+	return len(addresses) > 0
+}
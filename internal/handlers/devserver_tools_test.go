@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListeningSockets(t *testing.T) {
+	output := `LISTEN 0      4096   127.0.0.53%lo:53        0.0.0.0:*
+LISTEN 0      128          0.0.0.0:22        0.0.0.0:*
+LISTEN 0      511        127.0.0.1:5173      0.0.0.0:*
+LISTEN 0      511            [::1]:5173         [::]:*
+LISTEN 0      511                *:3000            *:*
+abc123 running`
+
+	expected := map[int][]string{
+		53:   {"127.0.0.53%lo"},
+		22:   {"0.0.0.0"},
+		5173: {"127.0.0.1", "[::1]"},
+		3000: {"*"},
+	}
+	if sockets := parseListeningSockets(output); !reflect.DeepEqual(sockets, expected) {
+		t.Errorf("Expected %v but got %v", expected, sockets)
+	}
+}
+
+func TestNewListeningPort(t *testing.T) {
+	existing := map[int][]string{22: {"0.0.0.0"}}
+	testCases := []struct {
+		name      string
+		listening map[int][]string
+		port      int
+		expected  int
+	}{
+		{
+			name:      "nothing new",
+			listening: map[int][]string{22: {"0.0.0.0"}},
+			expected:  0,
+		},
+		{
+			name:      "lowest new port",
+			listening: map[int][]string{22: {"0.0.0.0"}, 24678: {"*"}, 5173: {"127.0.0.1"}},
+			expected:  5173,
+		},
+		{
+			name:      "declared port not yet listening",
+			listening: map[int][]string{22: {"0.0.0.0"}, 24678: {"*"}},
+			port:      5173,
+			expected:  0,
+		},
+		{
+			name:      "declared port listening",
+			listening: map[int][]string{22: {"0.0.0.0"}, 3000: {"*"}, 8080: {"*"}},
+			port:      8080,
+			expected:  8080,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if port := newListeningPort(tc.listening, existing, tc.port); port != tc.expected {
+				t.Errorf("Expected port %d but got %d", tc.expected, port)
+			}
+		})
+	}
+}
+
+func TestLoopbackOnly(t *testing.T) {
+	testCases := []struct {
+		addresses []string
+		expected  bool
+	}{
+		{addresses: []string{"127.0.0.1"}, expected: true},
+		{addresses: []string{"127.0.0.1", "[::1]"}, expected: true},
+		{addresses: []string{"127.0.0.1", "0.0.0.0"}, expected: false},
+		{addresses: []string{"*"}, expected: false},
+		{addresses: nil, expected: false},
+	}
+
+	for _, tc := range testCases {
+		if result := loopbackOnly(tc.addresses); result != tc.expected {
+			t.Errorf("Expected loopbackOnly(%v) to be %v but got %v", tc.addresses, tc.expected, result)
+		}
+	}
+}
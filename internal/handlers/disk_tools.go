@@ -0,0 +1,137 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// AnalyzeDiskUsageResponse is the result of analyze_disk_usage
+type AnalyzeDiskUsageResponse struct {
+	VMName string             `json:"vm_name"`
+	Usage  vm.DiskUsageReport `json:"usage"`
+}
+
+// CleanupVMResponse is the result of cleanup_vm
+type CleanupVMResponse struct {
+	VMName  string           `json:"vm_name"`
+	Cleanup vm.CleanupReport `json:"cleanup"`
+}
+
+// RegisterDiskTools registers the tools that report and recover guest disk space
+func RegisterDiskTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	// Analyze disk usage tool
+	type AnalyzeDiskUsageArgs struct {
+		VMName string  `json:"vm_name"`
+		Path   string  `json:"path"`
+		Top    float64 `json:"top"`
+	}
+	analyzeDiskTool := mcp.NewTool("analyze_disk_usage",
+		mcp.WithDescription("Report how full the disks of a VM are, the largest directories under a path and the size of package, log, Docker and temporary file caches, suggesting cleanup_vm actions that would free space"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("path",
+			mcp.Description("Guest directory whose subdirectories are measured; the breakdown stays on its filesystem"),
+			mcp.DefaultString("/")),
+		mcp.WithNumber("top",
+			mcp.Description("Number of largest subdirectories to list"),
+			mcp.DefaultNumber(vm.DefaultDiskUsageEntries)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, analyzeDiskTool, func(ctx context.Context, request mcp.CallToolRequest, args AnalyzeDiskUsageArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		path := args.Path
+		if path == "" {
+			path = "/"
+		}
+		command, err := vm.DiskUsageCommand(path, int(args.Top))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to analyze disk usage", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to analyze disk usage: %s", strings.TrimSpace(result.Stderr+result.Stdout)), nil
+		}
+		response := AnalyzeDiskUsageResponse{
+			VMName: args.VMName,
+			Usage:  vm.ParseDiskUsage(path, result.Stdout),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Cleanup VM tool
+	type CleanupVMArgs struct {
+		VMName     string   `json:"vm_name"`
+		Actions    []string `json:"actions"`
+		TmpAgeDays float64  `json:"tmp_age_days"`
+	}
+	cleanupTool := mcp.NewTool("cleanup_vm",
+		mcp.WithDescription("Free disk space in a VM: apt cleans the package cache, journal vacuums the systemd journal to 100MB, docker prunes stopped containers, unused networks, dangling images and build cache, and tmp deletes old files from /tmp and /var/tmp"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithArray("actions",
+			mcp.Description("Cleanup actions to run (default: all)"),
+			mcp.Items(map[string]any{"type": "string", "enum": vm.CleanupActions})),
+		mcp.WithNumber("tmp_age_days",
+			mcp.Description("Age in days above which tmp deletes files"),
+			mcp.DefaultNumber(vm.DefaultTmpAgeDays)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, cleanupTool, func(ctx context.Context, request mcp.CallToolRequest, args CleanupVMArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		command, err := vm.CleanupCommand(args.Actions, int(args.TmpAgeDays))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to clean up VM", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to clean up VM: %s", strings.TrimSpace(result.Stderr+result.Stdout)), nil
+		}
+		report := vm.ParseCleanup(result.Stdout)
+		log.Info().Str("vm", args.VMName).Int64("freed_kb", report.FreedKB).Msg("Cleaned up VM disk space")
+		response := CleanupVMResponse{
+			VMName:  args.VMName,
+			Cleanup: report,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Disk tools registered")
+}
@@ -0,0 +1,367 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// Container log limits
+const (
+	defaultContainerLogLines = 100
+	maxContainerLogLines     = 5000
+)
+
+// defaultComposeFile is the compose file used when none is given
+const defaultComposeFile = "docker-compose.yml"
+
+// dockerCommand runs docker in the guest; the vagrant user is not in the docker group after
+// get.docker.com installs it
+const dockerCommand = "sudo -n docker"
+
+// composeFunction defines a shell function running Compose v2, or the standalone v1 binary
+// that setup_dev_environment installs
+const composeFunction = "compose() { if sudo -n docker compose version >/dev/null 2>&1; then sudo -n docker compose \"$@\"; else sudo -n docker-compose \"$@\"; fi; }"
+
+// dockerContainer is a container as listed by docker ps
+type dockerContainer struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Image          string `json:"image"`
+	State          string `json:"state"`
+	Status         string `json:"status"`
+	Ports          string `json:"ports,omitempty"`
+	ComposeProject string `json:"compose_project,omitempty"`
+	ComposeService string `json:"compose_service,omitempty"`
+}
+
+// ListContainersResponse is the result of list_containers
+type ListContainersResponse struct {
+	VMName     string            `json:"vm_name"`
+	Containers []dockerContainer `json:"containers"`
+	Count      int               `json:"count"`
+}
+
+// ComposeResponse is the result of compose_up and compose_down
+type ComposeResponse struct {
+	VMName  string `json:"vm_name"`
+	File    string `json:"file"`
+	Command string `json:"command"`
+	// Output holds compose's progress, which it reports on stderr
+	Output     string  `json:"output"`
+	DurationS  float64 `json:"duration_s"`
+	WorkingDir string  `json:"working_dir"`
+}
+
+// ContainerLogsResponse is the result of container_logs. Containers write to both streams;
+// docker logs keeps them apart.
+type ContainerLogsResponse struct {
+	VMName    string `json:"vm_name"`
+	Container string `json:"container"`
+	Lines     int    `json:"lines"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+}
+
+// RegisterDockerTools registers the tools that manage Docker containers inside VMs
+func RegisterDockerTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	// List containers tool
+	type ListContainersArgs struct {
+		VMName string `json:"vm_name"`
+		All    bool   `json:"all"`
+	}
+	listContainersTool := mcp.NewTool("list_containers",
+		mcp.WithDescription("List the Docker containers running in a VM"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithBoolean("all",
+			mcp.Description("Include stopped containers"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, listContainersTool, func(ctx context.Context, request mcp.CallToolRequest, args ListContainersArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+		command := dockerCommand + " ps --no-trunc --format '{{json .}}'"
+		if args.All {
+			command += " --all"
+		}
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to list containers", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return dockerFailedResult("Failed to list containers", result), nil
+		}
+		containers := parseDockerContainers(result.Stdout)
+		response := ListContainersResponse{
+			VMName:     args.VMName,
+			Containers: containers,
+			Count:      len(containers),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Compose up tool
+	type ComposeUpArgs struct {
+		VMName         string   `json:"vm_name"`
+		File           string   `json:"file"`
+		Services       []string `json:"services"`
+		Build          bool     `json:"build"`
+		TimeoutSeconds float64  `json:"timeout_seconds"`
+	}
+	composeUpTool := mcp.NewTool("compose_up",
+		mcp.WithDescription("Start the services of the synced project's Docker Compose file in the VM, detached"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("file",
+			mcp.Description("Compose file, relative to the project directory"),
+			mcp.DefaultString(defaultComposeFile)),
+		mcp.WithArray("services",
+			mcp.Description("Services to start (default: all)"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithBoolean("build",
+			mcp.Description("Build images before starting the containers"),
+			mcp.DefaultBool(false)),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum run time in seconds, including image pulls and builds (default: the server's maximum)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, composeUpTool, func(ctx context.Context, request mcp.CallToolRequest, args ComposeUpArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		parts := []string{"up", "--detach"}
+		if args.Build {
+			parts = append(parts, "--build")
+		}
+		for _, service := range args.Services {
+			parts = append(parts, shellquote.Quote(service))
+		}
+		return runCompose(ctx, request, vmManager, executor, args.VMName, args.File, parts, args.TimeoutSeconds)
+	})
+
+	// Compose down tool
+	type ComposeDownArgs struct {
+		VMName         string  `json:"vm_name"`
+		File           string  `json:"file"`
+		Volumes        bool    `json:"volumes"`
+		TimeoutSeconds float64 `json:"timeout_seconds"`
+	}
+	composeDownTool := mcp.NewTool("compose_down",
+		mcp.WithDescription("Stop and remove the containers and networks of the synced project's Docker Compose file in the VM"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("file",
+			mcp.Description("Compose file, relative to the project directory"),
+			mcp.DefaultString(defaultComposeFile)),
+		mcp.WithBoolean("volumes",
+			mcp.Description("Also remove the named volumes, deleting the data of databases and caches"),
+			mcp.DefaultBool(false)),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum run time in seconds (default: the server's maximum)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, composeDownTool, func(ctx context.Context, request mcp.CallToolRequest, args ComposeDownArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		parts := []string{"down"}
+		if args.Volumes {
+			parts = append(parts, "--volumes")
+		}
+		return runCompose(ctx, request, vmManager, executor, args.VMName, args.File, parts, args.TimeoutSeconds)
+	})
+
+	// Container logs tool
+	type ContainerLogsArgs struct {
+		VMName    string  `json:"vm_name"`
+		Container string  `json:"container"`
+		Lines     float64 `json:"lines"`
+		Since     string  `json:"since"`
+	}
+	containerLogsTool := mcp.NewTool("container_logs",
+		mcp.WithDescription("Return the last lines of a Docker container's output in a VM"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("container",
+			mcp.Required(),
+			mcp.Description("Name or ID of the container")),
+		mcp.WithNumber("lines",
+			mcp.Description("Number of lines to return"),
+			mcp.DefaultNumber(defaultContainerLogLines)),
+		mcp.WithString("since",
+			mcp.Description("Only return output since a timestamp or relative time, e.g. 10m or 2025-01-02T15:04:05")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, containerLogsTool, func(ctx context.Context, request mcp.CallToolRequest, args ContainerLogsArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Container == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or container"), nil
+		}
+		lines := int(args.Lines)
+		if lines <= 0 {
+			lines = defaultContainerLogLines
+		}
+		if lines > maxContainerLogLines {
+			lines = maxContainerLogLines
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+		command := fmt.Sprintf("%s logs --tail %d", dockerCommand, lines)
+		if args.Since != "" {
+			command += " --since " + shellquote.Quote(args.Since)
+		}
+		command += " " + shellquote.Quote(args.Container)
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to read container logs", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return dockerFailedResult("Failed to read container logs", result), nil
+		}
+		response := ContainerLogsResponse{
+			VMName:    args.VMName,
+			Container: args.Container,
+			Lines:     lines,
+			Stdout:    result.Stdout,
+			Stderr:    result.Stderr,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Docker tools registered")
+}
+
+// runCompose runs a docker compose subcommand against a compose file of the synced project
+func runCompose(ctx context.Context, request mcp.CallToolRequest, vmManager core.VMManager, executor *exec.Executor, vmName, file string, parts []string, timeoutSeconds float64) (*mcp.CallToolResult, error) {
+	if file == "" {
+		file = defaultComposeFile
+	}
+	if path.IsAbs(file) || strings.HasPrefix(path.Clean(file), "..") {
+		return mcp.NewToolResultErrorf("Compose file '%s' must be relative to the project directory", file), nil
+	}
+	if result := requireRunningVM(ctx, vmManager, vmName); result != nil {
+		return result, nil
+	}
+	config, err := vmManager.GetVMConfig(ctx, vmName)
+	if err != nil {
+		return mcp.NewToolResultErrorf("Failed to get VM config: %v", err), nil
+	}
+	projectDir := guestProjectRoot(config)
+
+	command := fmt.Sprintf("%s; cd %s && compose --file %s %s", composeFunction, shellquote.Quote(projectDir), shellquote.Quote(file), strings.Join(parts, " "))
+	execCtx := exec.ExecutionContext{
+		VMName:  vmName,
+		Timeout: secondsToDuration(timeoutSeconds),
+	}
+	result, err := executor.ExecuteCommand(ctx, command, execCtx, progressOutputCallback(ctx, request))
+	if err != nil {
+		return commandFailedResult(fmt.Sprintf("docker compose %s failed", parts[0]), result, err), nil
+	}
+	if result.ExitCode != 0 {
+		return dockerFailedResult(fmt.Sprintf("docker compose %s failed", parts[0]), result), nil
+	}
+	response := ComposeResponse{
+		VMName:     vmName,
+		File:       path.Join(projectDir, file),
+		Command:    "docker compose " + strings.Join(parts, " "),
+		Output:     strings.TrimSpace(result.Stderr + result.Stdout),
+		DurationS:  result.Duration,
+		WorkingDir: projectDir,
+	}
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		return mcp.NewToolResultError("Failed to marshal response"), nil
+	}
+	return mcp.NewToolResultText(string(jsonResponse)), nil
+}
+
+// requireRunningVM returns an error result unless the VM exists and is running
+func requireRunningVM(ctx context.Context, vmManager core.VMManager, vmName string) *mcp.CallToolResult {
+	state, err := vmManager.GetVMState(ctx, vmName)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", vmName, err))
+	}
+	if state != core.Running {
+		return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", vmName, state))
+	}
+	return nil
+}
+
+// dockerFailedResult returns the tool error for a docker command that exited with an error,
+// pointing to setup_dev_environment when docker is not installed
+func dockerFailedResult(message string, result *exec.CommandResult) *mcp.CallToolResult {
+	output := strings.TrimSpace(result.Stderr + result.Stdout)
+	if result.ExitCode == 127 || strings.Contains(output, "command not found") {
+		return mcp.NewToolResultErrorf("%s: Docker is not installed in the VM; install it with setup_dev_environment (tools: [\"docker\"])", message)
+	}
+	return mcp.NewToolResultErrorf("%s (exit code %d): %s", message, result.ExitCode, output)
+}
+
+// parseDockerContainers parses docker ps --format '{{json .}}' output
+func parseDockerContainers(output string) []dockerContainer {
+	containers := []dockerContainer{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var entry struct {
+			ID     string `json:"ID"`
+			Names  string `json:"Names"`
+			Image  string `json:"Image"`
+			State  string `json:"State"`
+			Status string `json:"Status"`
+			Ports  string `json:"Ports"`
+			Labels string `json:"Labels"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		container := dockerContainer{
+			ID:     entry.ID,
+			Name:   entry.Names,
+			Image:  entry.Image,
+			State:  entry.State,
+			Status: entry.Status,
+			Ports:  entry.Ports,
+		}
+		for _, label := range strings.Split(entry.Labels, ",") {
+			key, value, _ := strings.Cut(label, "=")
+			switch key {
+			case "com.docker.compose.project":
+				container.ComposeProject = value
+			case "com.docker.compose.service":
+				container.ComposeService = value
+			}
+		}
+		containers = append(containers, container)
+	}
+	return containers
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDockerContainers(t *testing.T) {
+	output := `{"Command":"\"docker-entrypoint.s…\"","ID":"3f2a","Image":"postgres:16","Labels":"com.docker.compose.project=shop,com.docker.compose.service=db","Names":"shop-db-1","Ports":"0.0.0.0:5432->5432/tcp","State":"running","Status":"Up 2 minutes"}
+not json
+{"ID":"9b1c","Image":"redis:7","Labels":"","Names":"cache","Ports":"","State":"exited","Status":"Exited (0) 1 hour ago"}
+`
+	expected := []dockerContainer{
+		{
+			ID:             "3f2a",
+			Name:           "shop-db-1",
+			Image:          "postgres:16",
+			State:          "running",
+			Status:         "Up 2 minutes",
+			Ports:          "0.0.0.0:5432->5432/tcp",
+			ComposeProject: "shop",
+			ComposeService: "db",
+		},
+		{
+			ID:     "9b1c",
+			Name:   "cache",
+			Image:  "redis:7",
+			State:  "exited",
+			Status: "Exited (0) 1 hour ago",
+		},
+	}
+	if containers := parseDockerContainers(output); !reflect.DeepEqual(containers, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, containers)
+	}
+	if containers := parseDockerContainers(""); len(containers) != 0 || containers == nil {
+		t.Errorf("Expected an empty list but got %v", containers)
+	}
+}
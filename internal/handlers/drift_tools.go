@@ -0,0 +1,405 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/project"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// runtimeProbes print the version of the runtimes setup_dev_environment installs
+var runtimeProbes = map[string]string{
+	"node":   "node --version",
+	"python": "python3 --version",
+	"go":     "go version",
+	"ruby":   "ruby --version",
+	"php":    "php --version",
+	"java":   "java -version",
+}
+
+// toolProbes print the version of the tools install_dev_tools knows; other tools are looked up
+// on the PATH
+var toolProbes = map[string]string{
+	"git":            "git --version",
+	"docker":         "docker --version",
+	"docker-compose": "docker-compose --version",
+	"nginx":          "nginx -v",
+	"postgresql":     "psql --version",
+	"mysql":          "mysql --version",
+	"mongodb":        "mongod --version",
+	"redis":          "redis-server --version",
+}
+
+// probeVersionPattern matches the version in the output of a probe
+var probeVersionPattern = regexp.MustCompile(`[0-9]+(\.[0-9]+)*`)
+
+// InstalledComponent is a runtime or tool declared by a project manifest and what the VM has
+type InstalledComponent struct {
+	Name string `json:"name"`
+	// Pinned is the version the manifest pins the runtime to
+	Pinned    string `json:"pinned,omitempty"`
+	Installed bool   `json:"installed"`
+	Version   string `json:"version,omitempty"`
+}
+
+// EnvironmentDrift is a difference between a VM and its project manifest
+type EnvironmentDrift struct {
+	// Kind is runtime, tool, resources or sync
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Expected    string `json:"expected"`
+	Actual      string `json:"actual"`
+	Remediation string `json:"remediation"`
+	// Command fixes the drift when run in the VM, e.g. with exec_in_vm
+	Command string `json:"command,omitempty"`
+	// Tool and Arguments name the tool call that fixes the drift
+	Tool      string         `json:"tool,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// CheckEnvironmentResponse is the result of check_environment
+type CheckEnvironmentResponse struct {
+	VMName   string               `json:"vm_name"`
+	Manifest string               `json:"manifest"`
+	InSync   bool                 `json:"in_sync"`
+	Runtimes []InstalledComponent `json:"runtimes"`
+	Tools    []InstalledComponent `json:"tools"`
+	Drift    []EnvironmentDrift   `json:"drift"`
+}
+
+// RegisterDriftTools registers the environment drift tools with the MCP server
+func RegisterDriftTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine core.SyncEngine, executor *exec.Executor) {
+	// Check environment tool
+	type CheckEnvironmentArgs struct {
+		VMName      string `json:"vm_name"`
+		ProjectPath string `json:"project_path"`
+	}
+	checkEnvironmentTool := mcp.NewTool("check_environment",
+		mcp.WithDescription("Compare the runtimes, tools, versions, resources and sync configuration of a running VM with its project's "+project.ManifestFile+" manifest, and report drift with the commands or tool calls that fix it"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("project_path",
+			mcp.Description("Project directory holding the manifest (default: the VM's project)")),
+	)
+
+	mcp_pkg.RegisterTypedTool(srv, checkEnvironmentTool, func(ctx context.Context, request mcp.CallToolRequest, args CheckEnvironmentArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+		config, err := vmManager.GetVMConfig(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get VM configuration: %v", err), nil
+		}
+		projectPath := args.ProjectPath
+		if projectPath == "" {
+			projectPath = config.ProjectPath
+		}
+		manifest, err := project.LoadManifest(projectPath)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if manifest == nil {
+			return mcp.NewToolResultErrorf("No %s in %s to compare the VM with; bootstrap_project_files writes one", project.ManifestFile, projectPath), nil
+		}
+
+		execCtx := exec.ExecutionContext{
+			VMName:     args.VMName,
+			WorkingDir: "/home/vagrant",
+			SyncBefore: false,
+			SyncAfter:  false,
+		}
+		result, err := executor.ExecuteCommand(ctx, environmentProbeScript(manifest), execCtx, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to inspect the VM: %v", err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to inspect the VM: %s", strings.TrimSpace(result.Stderr)), nil
+		}
+		var syncConfig *core.SyncConfig
+		if current, err := syncEngine.GetSyncConfig(ctx, args.VMName); err == nil {
+			syncConfig = &current
+		}
+
+		response := checkEnvironment(args.VMName, manifest, config, syncConfig, parseEnvironmentProbe(result.Stdout))
+		response.Manifest = filepath.Join(projectPath, project.ManifestFile)
+		log.Info().Str("vm", args.VMName).Int("drift", len(response.Drift)).Msg("Environment checked")
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	log.Info().Msg("Drift tools registered")
+}
+
+// environmentProbeScript returns the script that prints, for each runtime and tool of a
+// manifest, its key and the first line of its probe's output, or only its key when the probe
+// fails
+func environmentProbeScript(manifest *project.Manifest) string {
+	var b strings.Builder
+	b.WriteString(`probe() { key=$1; shift; if out=$("$@" 2>&1); then printf '%s\t%s\n' "$key" "$(printf '%s\n' "$out" | head -n 1)"; else printf '%s\n' "$key"; fi; }` + "\n")
+	for _, runtime := range manifest.Runtimes {
+		name, _ := project.SplitRuntime(runtime)
+		fmt.Fprintf(&b, "probe %s %s\n", shellquote.Quote("runtime:"+name), probeCommand(runtimeProbes, name))
+	}
+	for _, tool := range manifest.Tools {
+		fmt.Fprintf(&b, "probe %s %s\n", shellquote.Quote("tool:"+tool), probeCommand(toolProbes, tool))
+	}
+	return b.String()
+}
+
+// probeCommand returns the command that prints the version of a runtime or tool, or finds it
+// on the PATH when its version command is not known
+func probeCommand(probes map[string]string, name string) string {
+	if command, ok := probes[name]; ok {
+		return command
+	}
+	return "command -v " + shellquote.Quote(name)
+}
+
+// parseEnvironmentProbe maps the key of each successful probe to the first line of its output
+func parseEnvironmentProbe(output string) map[string]string {
+	found := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, out, ok := strings.Cut(line, "\t"); ok {
+			found[key] = strings.TrimSpace(out)
+		}
+	}
+	return found
+}
+
+// checkEnvironment compares a VM, its sync configuration (nil when it has none) and the output
+// of its probes with a manifest
+func checkEnvironment(vmName string, manifest *project.Manifest, config core.VMConfig, syncConfig *core.SyncConfig, probes map[string]string) CheckEnvironmentResponse {
+	response := CheckEnvironmentResponse{
+		VMName:   vmName,
+		Runtimes: []InstalledComponent{},
+		Tools:    []InstalledComponent{},
+		Drift:    []EnvironmentDrift{},
+	}
+
+	for _, runtime := range manifest.Runtimes {
+		name, pinned := project.SplitRuntime(runtime)
+		component := probedComponent(runtimeProbes, "runtime:"+name, name, probes)
+		component.Pinned = pinned
+		response.Runtimes = append(response.Runtimes, component)
+		install, err := runtimeInstallCommand(runtime)
+		switch {
+		case err != nil:
+			response.Drift = append(response.Drift, EnvironmentDrift{
+				Kind: "runtime", Name: name, Expected: "installed", Actual: installedState(component),
+				Remediation: fmt.Sprintf("%s cannot be installed by setup_dev_environment (%v); fix the manifest or install it by hand", runtime, err),
+			})
+		case !component.Installed:
+			response.Drift = append(response.Drift, EnvironmentDrift{
+				Kind: "runtime", Name: name, Expected: "installed", Actual: "missing",
+				Remediation: fmt.Sprintf("Install %s in the VM", name),
+				Command:     install,
+			})
+		case pinned != "" && !versionMatches(component.Version, pinned):
+			response.Drift = append(response.Drift, EnvironmentDrift{
+				Kind: "runtime", Name: name, Expected: pinned, Actual: component.Version,
+				Remediation: fmt.Sprintf("Switch %s to %s with its version manager", name, pinned),
+				Tool:        "set_runtime_version",
+				Arguments:   map[string]any{"vm_name": vmName, "runtime": name, "version": pinned},
+			})
+		}
+	}
+
+	for _, tool := range manifest.Tools {
+		component := probedComponent(toolProbes, "tool:"+tool, tool, probes)
+		response.Tools = append(response.Tools, component)
+		if !component.Installed {
+			response.Drift = append(response.Drift, EnvironmentDrift{
+				Kind: "tool", Name: tool, Expected: "installed", Actual: "missing",
+				Remediation: fmt.Sprintf("Install %s in the VM", tool),
+				Command:     toolInstallCommand(tool),
+			})
+		}
+	}
+
+	response.Drift = append(response.Drift, resourceDrift(vmName, manifest, config)...)
+	response.Drift = append(response.Drift, syncDrift(vmName, manifest, syncConfig)...)
+	response.InSync = len(response.Drift) == 0
+	return response
+}
+
+// probedComponent returns what the probe with key found about a runtime or tool
+func probedComponent(probes map[string]string, key, name string, found map[string]string) InstalledComponent {
+	component := InstalledComponent{Name: name}
+	output, ok := found[key]
+	if !ok {
+		return component
+	}
+	component.Installed = true
+	// Tools found on the PATH print their path, not a version
+	if _, known := probes[name]; known {
+		component.Version = probeVersionPattern.FindString(output)
+	}
+	return component
+}
+
+// installedState describes whether a component is installed
+func installedState(component InstalledComponent) string {
+	if component.Installed {
+		return "installed"
+	}
+	return "missing"
+}
+
+// versionMatches reports whether version is pinned or a release of it, e.g. 20.11.1 of 20
+func versionMatches(version, pinned string) bool {
+	return version == pinned || strings.HasPrefix(version, pinned+".")
+}
+
+// resourceDrift compares the box and resources of a VM with a manifest
+func resourceDrift(vmName string, manifest *project.Manifest, config core.VMConfig) []EnvironmentDrift {
+	var drift []EnvironmentDrift
+	if manifest.Box != "" && manifest.Box != config.Box {
+		drift = append(drift, EnvironmentDrift{
+			Kind: "resources", Name: "box", Expected: manifest.Box, Actual: config.Box,
+			Remediation: "The box of a VM cannot change; recreate the VM with destroy_dev_vm and ensure_dev_vm",
+		})
+	}
+	if manifest.CPU > 0 && manifest.CPU != config.CPU {
+		drift = append(drift, EnvironmentDrift{
+			Kind: "resources", Name: "cpu", Expected: fmt.Sprint(manifest.CPU), Actual: fmt.Sprint(config.CPU),
+			Remediation: "Change the CPU cores of the VM",
+			Tool:        "set_vm_resources",
+			Arguments:   map[string]any{"name": vmName, "cpu": manifest.CPU},
+		})
+	}
+	if manifest.Memory > 0 && manifest.Memory != config.Memory {
+		drift = append(drift, EnvironmentDrift{
+			Kind: "resources", Name: "memory", Expected: fmt.Sprint(manifest.Memory), Actual: fmt.Sprint(config.Memory),
+			Remediation: "Change the memory of the VM",
+			Tool:        "set_vm_resources",
+			Arguments:   map[string]any{"name": vmName, "memory": manifest.Memory},
+		})
+	}
+	if manifest.DiskSizeGB > 0 && manifest.DiskSizeGB != config.DiskSizeGB {
+		entry := EnvironmentDrift{
+			Kind: "resources", Name: "disk_size_gb", Expected: fmt.Sprint(manifest.DiskSizeGB), Actual: fmt.Sprint(config.DiskSizeGB),
+			Remediation: "Disks can only grow; recreate the VM with destroy_dev_vm and ensure_dev_vm",
+		}
+		if manifest.DiskSizeGB > config.DiskSizeGB {
+			entry.Remediation = "Grow the root disk of the VM"
+			entry.Tool = "resize_vm_disk"
+			entry.Arguments = map[string]any{"name": vmName, "size_gb": manifest.DiskSizeGB}
+		}
+		drift = append(drift, entry)
+	}
+	return drift
+}
+
+// syncDrift compares the sync configuration of a VM, nil when it has none, with a manifest
+func syncDrift(vmName string, manifest *project.Manifest, syncConfig *core.SyncConfig) []EnvironmentDrift {
+	declared := manifest.Sync
+	if syncConfig == nil {
+		method := declared.Method
+		if method == "" {
+			method = string(core.SyncMethodRsync)
+		}
+		arguments := map[string]any{"vm_name": vmName, "sync_type": method}
+		if declared.Exclude != nil {
+			arguments["exclude_patterns"] = declared.Exclude
+		}
+		return []EnvironmentDrift{{
+			Kind: "sync", Name: "configuration", Expected: "configured", Actual: "missing",
+			Remediation: "Configure sync for the VM",
+			Tool:        "configure_sync",
+			Arguments:   arguments,
+		}}
+	}
+
+	var drift []EnvironmentDrift
+	method := string(syncConfig.Method)
+	exclude := syncConfig.ExcludePatterns
+	if declared.Exclude != nil {
+		exclude = declared.Exclude
+	}
+	if declared.Method != "" && declared.Method != method {
+		drift = append(drift, EnvironmentDrift{
+			Kind: "sync", Name: "method", Expected: declared.Method, Actual: method,
+			Remediation: "Change the sync method of the VM",
+			Tool:        "configure_sync",
+			Arguments:   map[string]any{"vm_name": vmName, "sync_type": declared.Method, "exclude_patterns": exclude},
+		})
+		method = declared.Method
+	}
+	if declared.Exclude != nil && !samePatterns(declared.Exclude, syncConfig.ExcludePatterns) {
+		drift = append(drift, EnvironmentDrift{
+			Kind: "sync", Name: "exclude", Expected: strings.Join(declared.Exclude, ", "), Actual: strings.Join(syncConfig.ExcludePatterns, ", "),
+			Remediation: "Change the sync exclude patterns of the VM",
+			Tool:        "configure_sync",
+			Arguments:   map[string]any{"vm_name": vmName, "sync_type": method, "exclude_patterns": declared.Exclude},
+		})
+	}
+
+	policy := syncConfig.ConflictPolicy
+	if declared.ConflictPolicy != "" {
+		policy = declared.ConflictPolicy
+	}
+	overrides := syncConfig.ConflictPolicyOverrides
+	if declared.ConflictPolicyOverrides != nil {
+		overrides = declared.ConflictPolicyOverrides
+	}
+	if policy != syncConfig.ConflictPolicy {
+		drift = append(drift, EnvironmentDrift{
+			Kind: "sync", Name: "conflict_policy", Expected: policy, Actual: syncConfig.ConflictPolicy,
+			Remediation: "Change the conflict policy of the VM",
+			Tool:        "set_conflict_policy",
+			Arguments:   map[string]any{"vm_name": vmName, "policy": policy, "overrides": overrides},
+		})
+	} else if !slices.Equal(overrides, syncConfig.ConflictPolicyOverrides) && policy != "" {
+		drift = append(drift, EnvironmentDrift{
+			Kind: "sync", Name: "conflict_policy_overrides", Expected: describeOverrides(overrides), Actual: describeOverrides(syncConfig.ConflictPolicyOverrides),
+			Remediation: "Change the conflict policy overrides of the VM",
+			Tool:        "set_conflict_policy",
+			Arguments:   map[string]any{"vm_name": vmName, "policy": policy, "overrides": overrides},
+		})
+	}
+	return drift
+}
+
+// samePatterns reports whether two lists hold the same patterns, in any order
+func samePatterns(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// describeOverrides lists conflict policy overrides as pattern=policy
+func describeOverrides(overrides []core.ConflictPolicyOverride) string {
+	described := make([]string, 0, len(overrides))
+	for _, override := range overrides {
+		described = append(described, override.Pattern+"="+override.Policy)
+	}
+	return strings.Join(described, ", ")
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/project"
+)
+
+func TestEnvironmentProbeScript(t *testing.T) {
+	sh, err := osexec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+	head, err := osexec.LookPath("head")
+	if err != nil {
+		t.Skip("head is not available")
+	}
+	// Only the fake binaries below are on the PATH
+	bin := t.TempDir()
+	if err := os.Symlink(head, filepath.Join(bin, "head")); err != nil {
+		t.Fatalf("Failed to link head: %v", err)
+	}
+	fakes := map[string]string{
+		"node": "echo v20.11.1",
+		"java": "echo 'openjdk version \"17.0.9\" 2023-10-17' >&2; echo 'OpenJDK Runtime Environment' >&2",
+		"jq":   "exit 0",
+	}
+	for name, body := range fakes {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!"+sh+"\n"+body+"\n"), 0755); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	manifest := &project.Manifest{Runtimes: []string{"node@20", "java", "go"}, Tools: []string{"jq", "docker"}}
+	cmd := osexec.Command(sh, "-c", environmentProbeScript(manifest))
+	cmd.Env = []string{"PATH=" + bin}
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected the script to succeed but got %v", err)
+	}
+	probes := parseEnvironmentProbe(string(output))
+
+	expected := map[string]string{
+		"runtime:node": "v20.11.1",
+		"runtime:java": `openjdk version "17.0.9" 2023-10-17`,
+		"tool:jq":      filepath.Join(bin, "jq"),
+	}
+	if len(probes) != len(expected) {
+		t.Errorf("Expected probes %v but got %v", expected, probes)
+	}
+	for key, value := range expected {
+		if probes[key] != value {
+			t.Errorf("Expected %s to print %q but got %q", key, value, probes[key])
+		}
+	}
+}
+
+func TestCheckEnvironment(t *testing.T) {
+	manifest := &project.Manifest{
+		Box:      "ubuntu/jammy64",
+		CPU:      4,
+		Memory:   2048,
+		Runtimes: []string{"node@18", "python@3.10", "go"},
+		Tools:    []string{"git", "jq"},
+		Sync: project.ManifestSync{
+			Method:         "rsync",
+			Exclude:        []string{"node_modules", ".git"},
+			ConflictPolicy: "prefer_host",
+		},
+	}
+	config := core.VMConfig{Box: "ubuntu/jammy64", CPU: 2, Memory: 2048}
+	syncConfig := &core.SyncConfig{Method: core.SyncMethodRsync, ExcludePatterns: []string{".git", "node_modules"}, ConflictPolicy: "prefer_host"}
+	probes := map[string]string{
+		"runtime:node":   "v20.11.1",
+		"runtime:python": "Python 3.10.12",
+		"tool:git":       "git version 2.34.1",
+	}
+
+	response := checkEnvironment("dev", manifest, config, syncConfig, probes)
+	if response.InSync {
+		t.Error("Expected drift to be reported")
+	}
+	if response.Runtimes[0].Version != "20.11.1" || response.Runtimes[0].Pinned != "18" {
+		t.Errorf("Expected node 20.11.1 pinned to 18 but got %+v", response.Runtimes[0])
+	}
+	if response.Tools[0].Version != "2.34.1" || response.Tools[1].Installed {
+		t.Errorf("Expected git 2.34.1 and no jq but got %+v", response.Tools)
+	}
+
+	// python 3.10.12 matches its pin and the excludes only differ in order
+	expected := []struct {
+		kind, name, tool string
+		command          bool
+	}{
+		{"runtime", "node", "set_runtime_version", false},
+		{"runtime", "go", "", true},
+		{"tool", "jq", "", true},
+		{"resources", "cpu", "set_vm_resources", false},
+	}
+	if len(response.Drift) != len(expected) {
+		t.Fatalf("Expected %d drifts but got %+v", len(expected), response.Drift)
+	}
+	for i, e := range expected {
+		drift := response.Drift[i]
+		if drift.Kind != e.kind || drift.Name != e.name || drift.Tool != e.tool || (drift.Command != "") != e.command {
+			t.Errorf("Expected %s drift of %s fixed by %q but got %+v", e.kind, e.name, e.tool, drift)
+		}
+	}
+	if response.Drift[3].Arguments["cpu"] != 4 {
+		t.Errorf("Expected set_vm_resources to set 4 CPUs but got %v", response.Drift[3].Arguments)
+	}
+
+	// Without a sync configuration, configure_sync sets it up from the manifest
+	response = checkEnvironment("dev", &project.Manifest{Sync: manifest.Sync}, config, nil, probes)
+	if len(response.Drift) != 1 || response.Drift[0].Tool != "configure_sync" || response.Drift[0].Arguments["sync_type"] != "rsync" {
+		t.Errorf("Expected a configure_sync remediation but got %+v", response.Drift)
+	}
+
+	// A different conflict policy keeps the current overrides
+	syncConfig.ConflictPolicy = "prefer_vm"
+	syncConfig.ConflictPolicyOverrides = []core.ConflictPolicyOverride{{Pattern: "*.lock", Policy: "prefer_host"}}
+	response = checkEnvironment("dev", &project.Manifest{Sync: manifest.Sync}, config, syncConfig, probes)
+	if len(response.Drift) != 1 || response.Drift[0].Name != "conflict_policy" || response.Drift[0].Arguments["policy"] != "prefer_host" {
+		t.Fatalf("Expected a conflict policy drift but got %+v", response.Drift)
+	}
+	if overrides, ok := response.Drift[0].Arguments["overrides"].([]core.ConflictPolicyOverride); !ok || len(overrides) != 1 {
+		t.Errorf("Expected the current overrides to be kept but got %v", response.Drift[0].Arguments["overrides"])
+	}
+}
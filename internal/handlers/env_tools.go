@@ -5,8 +5,10 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -15,29 +17,105 @@ import (
 	"github.com/vagrant-mcp/server/internal/core"
 	"github.com/vagrant-mcp/server/internal/errors"
 	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/project"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	"github.com/vagrant-mcp/server/internal/vm"
 	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
 )
 
+// InstallResult is the outcome of installing a runtime or tool
+type InstallResult struct {
+	Success bool   `json:"success"`
+	Output  string `json:"output"`
+	Error   string `json:"error,omitempty"`
+}
+
+// newInstallResult returns the outcome of an install that printed output and failed with err
+func newInstallResult(output string, err error) InstallResult {
+	result := InstallResult{Success: err == nil, Output: output}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// SetupDevEnvironmentResponse is the result of setup_dev_environment
+type SetupDevEnvironmentResponse struct {
+	VMName   string                   `json:"vm_name"`
+	Runtimes map[string]InstallResult `json:"runtimes"`
+	Tools    map[string]InstallResult `json:"tools,omitempty"`
+}
+
+// InstallDevToolsResponse is the result of install_dev_tools, keyed by tool
+type InstallDevToolsResponse map[string]InstallResult
+
+// BakeBaseImageResponse is the result of bake_base_image
+type BakeBaseImageResponse struct {
+	Image     vm.BaseImage `json:"image"`
+	Installed []string     `json:"installed"`
+	VMState   core.VMState `json:"vm_state"`
+	Note      string       `json:"note,omitempty"`
+}
+
+// PackageCacheResponse is the result of package_cache
+type PackageCacheResponse struct {
+	CacheDir string `json:"cache_dir"`
+	// Cleared and FreedBytes are set when a cache was cleared
+	Cleared    string `json:"cleared,omitempty"`
+	FreedBytes *int64 `json:"freed_bytes,omitempty"`
+	// SizeBytes maps each cache to its size
+	SizeBytes map[string]int64 `json:"size_bytes"`
+}
+
+// SetRuntimeVersionResponse is the result of set_runtime_version
+type SetRuntimeVersionResponse struct {
+	VMName         string `json:"vm_name"`
+	Runtime        string `json:"runtime"`
+	VersionManager string `json:"version_manager"`
+	// Version is the version that was requested and Active the one the runtime now reports
+	Version string `json:"version"`
+	Active  string `json:"active"`
+	Output  string `json:"output"`
+}
+
+// ConfigureShellResponse is the result of configure_shell
+type ConfigureShellResponse struct {
+	VMName    string   `json:"vm_name"`
+	ShellType string   `json:"shell_type"`
+	Action    string   `json:"action"`
+	RCFile    string   `json:"rc_file"`
+	Aliases   []string `json:"aliases"`
+	EnvVars   []string `json:"env_vars"`
+	// Output is what happened to the rc file: updated, unchanged or restored
+	Output string `json:"output"`
+	// Backup is the copy of the rc file from before it was updated, used by rollback
+	Backup string `json:"backup,omitempty"`
+}
+
 // RegisterEnvTools registers all environment-related tools with the MCP server
 func RegisterEnvTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
 	// Setup dev environment tool
 	type SetupEnvArgs struct {
-		VMName   string   `json:"vm_name"`
-		Runtimes []string `json:"runtimes"`
-		Tools    []string `json:"tools"`
+		VMName      string   `json:"vm_name"`
+		Runtimes    []string `json:"runtimes"`
+		Tools       []string `json:"tools"`
+		MaxParallel float64  `json:"max_parallel"`
 	}
 	setupEnvTool := mcp.NewTool("setup_dev_environment",
-		mcp.WithDescription("Install language runtimes, tools, and dependencies in the VM"),
+		mcp.WithDescription("Install language runtimes, tools, and dependencies in the VM. The package lists are refreshed once, then independent installs run at the same time, and installs that depend on others, such as docker-compose on docker, run after them. Each install's start and end is sent as a progress notification when the request carries a progress token"),
 		mcp.WithString("vm_name",
 			mcp.Required(),
 			mcp.Description("Name of the development VM")),
 		mcp.WithArray("runtimes",
 			mcp.Required(),
-			mcp.Description("Language runtimes to install (e.g., 'node', 'python', 'go', etc.)"),
+			mcp.Description("Language runtimes to install (e.g., 'node', 'python', 'go', etc.). Pin an exact version, e.g. 'node@20.11.1' or 'python@3.12.2', to install it with the runtime's version manager (nvm, pyenv, rbenv or gvm) instead of the distribution's package"),
 			mcp.Items(map[string]any{"type": "string"})),
 		mcp.WithArray("tools",
 			mcp.Description("Additional tools to install"),
 			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("max_parallel",
+			mcp.Description(fmt.Sprintf("Installs that may run at the same time, at most %d; 1 installs one at a time", maxInstallParallelism)),
+			mcp.DefaultNumber(defaultInstallParallelism)),
 	)
 
 	mcp_pkg.RegisterTypedTool(srv, setupEnvTool, func(ctx context.Context, request mcp.CallToolRequest, args SetupEnvArgs) (*mcp.CallToolResult, error) {
@@ -47,6 +125,13 @@ func RegisterEnvTools(srv *server.MCPServer, vmManager core.VMManager, executor
 		if len(args.Runtimes) == 0 {
 			return mcp.NewToolResultError("missing or invalid 'runtimes' parameter"), nil
 		}
+		parallelism := int(args.MaxParallel)
+		if parallelism == 0 {
+			parallelism = defaultInstallParallelism
+		}
+		if parallelism < 1 || parallelism > maxInstallParallelism {
+			return mcp.NewToolResultErrorf("'max_parallel' must be between 1 and %d", maxInstallParallelism), nil
+		}
 		// Check VM state
 		state, err := vmManager.GetVMState(ctx, args.VMName)
 		if err != nil {
@@ -57,47 +142,30 @@ func RegisterEnvTools(srv *server.MCPServer, vmManager core.VMManager, executor
 			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
 		}
 
-		// Process each runtime
-		results := make(map[string]interface{})
-		for _, runtime := range args.Runtimes {
-			cmdResult, err := installRuntime(ctx, executor, args.VMName, runtime)
-			results[runtime] = map[string]interface{}{
-				"success": err == nil,
-				"output":  cmdResult,
-				"error":   err,
-			}
+		runtimes, tools, err := installInParallel(ctx, request, executor, args.VMName, args.Runtimes, args.Tools, parallelism)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to install: %v", err), nil
 		}
-
-		// Get tools to install
-		var tools []string
-		toolsObj := request.GetArguments()["tools"]
-		if toolsList, ok := toolsObj.([]interface{}); ok {
-			for _, tool := range toolsList {
-				if toolStr, ok := tool.(string); ok {
-					tools = append(tools, toolStr)
-				}
-			}
+		response := SetupDevEnvironmentResponse{
+			VMName:   args.VMName,
+			Runtimes: runtimes,
 		}
-
-		// Process each tool
-		if len(tools) > 0 {
-			toolResults := make(map[string]interface{})
-			for _, tool := range tools {
-				cmdResult, err := installTool(ctx, executor, args.VMName, tool)
-				toolResults[tool] = map[string]interface{}{
-					"success": err == nil,
-					"output":  cmdResult,
-					"error":   err,
-				}
-			}
-			results["tools"] = toolResults
+		if len(args.Tools) > 0 {
+			response.Tools = tools
 		}
 
-		// Return results
-		return mcp.NewToolResultText(fmt.Sprintf("%v", results)), nil
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
 	})
 
 	// Install dev tools tool
+	type InstallToolsArgs struct {
+		VMName string   `json:"vm_name"`
+		Tools  []string `json:"tools"`
+	}
 	installToolsTool := mcp.NewTool("install_dev_tools",
 		mcp.WithDescription("Install specific development tools in the VM"),
 		mcp.WithString("vm_name",
@@ -109,171 +177,362 @@ func RegisterEnvTools(srv *server.MCPServer, vmManager core.VMManager, executor
 			mcp.Items(map[string]any{"type": "string"})),
 	)
 
-	srv.AddTool(installToolsTool, handleInstallDevTools(vmManager, executor))
+	mcp_pkg.RegisterTypedTool(srv, installToolsTool, func(ctx context.Context, request mcp.CallToolRequest, args InstallToolsArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("missing or invalid 'vm_name' parameter"), nil
+		}
+		if len(args.Tools) == 0 {
+			return mcp.NewToolResultError("missing or invalid 'tools' parameter"), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+
+		results := make(InstallDevToolsResponse)
+		for _, tool := range args.Tools {
+			cmdResult, err := installTool(ctx, executor, args.VMName, tool)
+			results[tool] = newInstallResult(cmdResult, err)
+		}
+
+		jsonData, err := json.Marshal(results)
+		if err != nil {
+			return mcp.NewToolResultError("failed to marshal result: " + err.Error()), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Set runtime version tool
+	type SetRuntimeVersionArgs struct {
+		VMName  string `json:"vm_name"`
+		Runtime string `json:"runtime"`
+		Version string `json:"version"`
+	}
+	setRuntimeVersionTool := mcp.NewTool("set_runtime_version",
+		mcp.WithDescription("Install an exact version of a runtime with its version manager (nvm for node, pyenv for python, rbenv for ruby, gvm for go), installing the manager when missing, and make it the version every command in the VM uses. Versions installed earlier are kept, so switching back is quick"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("runtime",
+			mcp.Required(),
+			mcp.Description("Runtime to switch"),
+			mcp.Enum(versionManagedRuntimes()...)),
+		mcp.WithString("version",
+			mcp.Required(),
+			mcp.Description("Version to use, e.g. 20.11.1 for node or 3.12.2 for python")),
+	)
+
+	mcp_pkg.RegisterTypedTool(srv, setRuntimeVersionTool, func(ctx context.Context, request mcp.CallToolRequest, args SetRuntimeVersionArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Runtime == "" || args.Version == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name, runtime and version"), nil
+		}
+		command, err := versionManagerCommand(args.Runtime, args.Version)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+
+		execCtx := exec.ExecutionContext{
+			VMName:     args.VMName,
+			WorkingDir: "/home/vagrant",
+			SyncBefore: false,
+			SyncAfter:  false,
+		}
+		result, err := executor.ExecuteCommand(ctx, command, execCtx, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to set %s version: %v", args.Runtime, err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to install %s %s with %s: %s", args.Runtime, args.Version, versionManagers[args.Runtime].Name, strings.TrimSpace(result.Stdout+"\n"+result.Stderr)), nil
+		}
+
+		output := strings.TrimSpace(result.Stdout)
+		response := SetRuntimeVersionResponse{
+			VMName:         args.VMName,
+			Runtime:        args.Runtime,
+			VersionManager: versionManagers[args.Runtime].Name,
+			Version:        args.Version,
+			Active:         probeVersionPattern.FindString(output[strings.LastIndex(output, "\n")+1:]),
+			Output:         output,
+		}
+		log.Info().Str("vm", args.VMName).Str("runtime", args.Runtime).Str("version", response.Active).Msg("Runtime version set")
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
 
 	// Configure shell tool
+	type ConfigureShellArgs struct {
+		VMName    string   `json:"vm_name"`
+		ShellType string   `json:"shell_type"`
+		Action    string   `json:"action"`
+		Aliases   []string `json:"aliases"`
+		EnvVars   []string `json:"env_vars"`
+	}
 	configureShellTool := mcp.NewTool("configure_shell",
-		mcp.WithDescription("Configure shell environment in the VM"),
+		mcp.WithDescription("Configure shell environment in the VM. The aliases and environment variables live in a marked block of the shell's rc file that each call replaces, so repeated calls do not duplicate them; the previous rc file is kept for rollback"),
 		mcp.WithString("vm_name",
 			mcp.Required(),
 			mcp.Description("Name of the development VM")),
 		mcp.WithString("shell_type",
 			mcp.Description("Shell type to configure"),
-			mcp.DefaultString("bash")),
+			mcp.DefaultString("bash"),
+			mcp.Enum("bash", "zsh")),
+		mcp.WithString("action",
+			mcp.Description("set replaces the managed block with aliases and env_vars, remove deletes it, rollback restores the rc file from before the last change"),
+			mcp.DefaultString(shellActionSet),
+			mcp.Enum(shellActionSet, shellActionRemove, shellActionRollback)),
 		mcp.WithArray("aliases",
-			mcp.Description("Shell aliases to configure"),
+			mcp.Description("Shell aliases to configure, as name=value, e.g. ll='ls -la'"),
 			mcp.Items(map[string]any{"type": "string"})),
 		mcp.WithArray("env_vars",
-			mcp.Description("Environment variables to set"),
+			mcp.Description("Environment variables to set, as NAME=value"),
 			mcp.Items(map[string]any{"type": "string"})),
 	)
 
-	srv.AddTool(configureShellTool, handleConfigureShell(vmManager, executor))
-
-	log.Info().Msg("Environment tools registered")
-}
-
-// handleInstallDevTools handles the install_dev_tools tool
-func handleInstallDevTools(manager core.VMManager, executor *exec.Executor) server.ToolHandlerFunc {
-	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		vmName, err := request.RequireString("vm_name")
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("missing or invalid 'vm_name' parameter: %v", err)), nil
+	mcp_pkg.RegisterTypedTool(srv, configureShellTool, func(ctx context.Context, request mcp.CallToolRequest, args ConfigureShellArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("missing or invalid 'vm_name' parameter"), nil
 		}
-
-		toolsObj := request.GetArguments()["tools"]
-		var tools []string
-
-		if toolsList, ok := toolsObj.([]interface{}); ok {
-			for _, tool := range toolsList {
-				if toolStr, ok := tool.(string); ok {
-					tools = append(tools, toolStr)
-				}
-			}
+		if args.ShellType == "" {
+			args.ShellType = "bash"
 		}
-
-		if len(tools) == 0 {
-			return mcp.NewToolResultError("missing or invalid 'tools' parameter"), nil
+		if args.Action == "" {
+			args.Action = shellActionSet
 		}
-
-		// Check VM state
-		state, err := manager.GetVMState(ctx, vmName)
+		state, err := vmManager.GetVMState(ctx, args.VMName)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", vmName, err)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
 		}
-
 		if state != core.Running {
-			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", vmName, state)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
 		}
 
-		// Process each tool
-		results := make(map[string]interface{})
-		for _, tool := range tools {
-			cmdResult, err := installTool(ctx, executor, vmName, tool)
-			results[tool] = map[string]interface{}{
-				"success": err == nil,
-				"output":  cmdResult,
-				"error":   err,
-			}
+		configResult, err := configureShellEnv(ctx, executor, args.VMName, args.ShellType, args.Action, args.Aliases, args.EnvVars)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to configure shell: %v", err)), nil
 		}
 
-		// Return results
-		jsonData, err := json.Marshal(results)
-		if err != nil {
-			return mcp.NewToolResultError("failed to marshal result: " + err.Error()), nil
+		result := ConfigureShellResponse{
+			VMName:    args.VMName,
+			ShellType: args.ShellType,
+			Action:    args.Action,
+			RCFile:    shellRCFiles[args.ShellType],
+			Aliases:   args.Aliases,
+			EnvVars:   args.EnvVars,
+			Output:    configResult,
+		}
+		if configResult == "updated" {
+			result.Backup = result.RCFile + shellBackupSuffix
 		}
-		return mcp.NewToolResultText(string(jsonData)), nil
-	}
-}
 
-// handleConfigureShell handles the configure_shell tool
-func handleConfigureShell(manager core.VMManager, executor *exec.Executor) server.ToolHandlerFunc {
-	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		vmName, err := request.RequireString("vm_name")
+		jsonData, err := json.Marshal(result)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("missing or invalid 'vm_name' parameter: %v", err)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
 		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
 
-		shellType := request.GetString("shell_type", "bash")
-
-		// Check VM state
-		state, err := manager.GetVMState(ctx, vmName)
+	// Bake base image tool
+	type BakeBaseImageArgs struct {
+		VMName     string   `json:"vm_name"`
+		BoxName    string   `json:"box_name"`
+		Runtimes   []string `json:"runtimes"`
+		Tools      []string `json:"tools"`
+		SetDefault *bool    `json:"set_default"`
+	}
+	bakeBaseImageTool := mcp.NewTool("bake_base_image",
+		mcp.WithDescription("Install runtimes and tools in a running VM, package it with 'vagrant package' and register it as a local box that create_dev_vm uses as its base, so new environments start in seconds instead of minutes. The VM is halted by packaging"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the running development VM to bake")),
+		mcp.WithString("box_name",
+			mcp.Required(),
+			mcp.Description("Name of the local box to create, e.g. mycorp/node-dev; an existing box with the name is replaced")),
+		mcp.WithArray("runtimes",
+			mcp.Description("Language runtimes to install before packaging (node, python, go, ruby, php, java)"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithArray("tools",
+			mcp.Description("Development tools to install before packaging"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithBoolean("set_default",
+			mcp.Description("Use the box for create_dev_vm and ensure_dev_vm calls that do not name a box"),
+			mcp.DefaultBool(true)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, bakeBaseImageTool, func(ctx context.Context, request mcp.CallToolRequest, args BakeBaseImageArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		if args.BoxName == "" {
+			return mcp.NewToolResultError("Missing required parameter: box_name"), nil
+		}
+		baker, ok := vmManager.(interface {
+			BakeBaseImage(ctx context.Context, vmName, boxName string, setDefault bool) (vm.BaseImage, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support baking base images"), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", vmName, err)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
 		}
-
 		if state != core.Running {
-			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", vmName, state)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
 		}
 
-		// Process aliases
-		aliasesObj := request.GetArguments()["aliases"]
-		var aliases []string
-		if aliasesList, ok := aliasesObj.([]interface{}); ok {
-			for _, alias := range aliasesList {
-				if aliasStr, ok := alias.(string); ok {
-					aliases = append(aliases, aliasStr)
-				}
+		var installed []string
+		for _, runtime := range args.Runtimes {
+			if _, err := installRuntime(ctx, executor, args.VMName, runtime); err != nil {
+				return mcp.NewToolResultErrorf("Failed to install runtime %s: %v", runtime, err), nil
 			}
+			installed = append(installed, runtime)
 		}
-
-		// Process env vars
-		envVarsObj := request.GetArguments()["env_vars"]
-		var envVars []string
-		if envVarsList, ok := envVarsObj.([]interface{}); ok {
-			for _, envVar := range envVarsList {
-				if envVarStr, ok := envVar.(string); ok {
-					envVars = append(envVars, envVarStr)
-				}
+		for _, tool := range args.Tools {
+			if _, err := installTool(ctx, executor, args.VMName, tool); err != nil {
+				return mcp.NewToolResultErrorf("Failed to install tool %s: %v", tool, err), nil
 			}
+			installed = append(installed, tool)
 		}
 
-		// Configure shell
-		configResult, err := configureShellEnv(ctx, executor, vmName, shellType, aliases, envVars)
+		setDefault := args.SetDefault == nil || *args.SetDefault
+		image, err := baker.BakeBaseImage(ctx, args.VMName, args.BoxName, setDefault)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to configure shell: %v", err)), nil
+			return mcp.NewToolResultErrorf("Failed to bake base image: %v", err), nil
 		}
+		response := BakeBaseImageResponse{
+			Image:     image,
+			Installed: installed,
+			VMState:   core.Stopped,
+		}
+		if setDefault {
+			response.Note = fmt.Sprintf("create_dev_vm now uses box '%s' unless given another; pass linked_clone for the fastest creation", image.Box)
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
 
-		// Return results
-		result := map[string]interface{}{
-			"vm_name":    vmName,
-			"shell_type": shellType,
-			"aliases":    aliases,
-			"env_vars":   envVars,
-			"output":     configResult,
+	// Package cache tool
+	type PackageCacheArgs struct {
+		Clear string `json:"clear"`
+	}
+	packageCacheTool := mcp.NewTool("package_cache",
+		mcp.WithDescription("Show the size of the package caches shared by VMs created with shared_package_cache, or clear them"),
+		mcp.WithString("clear",
+			mcp.Description("Cache to clear: apt, npm, pip, go or all (default: clear nothing)"),
+			mcp.Enum("apt", "npm", "pip", "go", "all")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, packageCacheTool, func(ctx context.Context, request mcp.CallToolRequest, args PackageCacheArgs) (*mcp.CallToolResult, error) {
+		caches, ok := vmManager.(interface {
+			PackageCacheDir() string
+			PackageCacheUsage() (map[string]int64, error)
+			ClearPackageCache(name string) (int64, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support shared package caches"), nil
 		}
 
-		jsonData, err := json.Marshal(result)
+		response := PackageCacheResponse{
+			CacheDir: caches.PackageCacheDir(),
+		}
+		if args.Clear != "" {
+			name := args.Clear
+			if name == "all" {
+				name = ""
+			}
+			freed, err := caches.ClearPackageCache(name)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Failed to clear package cache: %v", err), nil
+			}
+			response.Cleared = args.Clear
+			response.FreedBytes = &freed
+		}
+		usage, err := caches.PackageCacheUsage()
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to marshal results: %v", err)), nil
+			return mcp.NewToolResultErrorf("Failed to measure package cache: %v", err), nil
 		}
+		response.SizeBytes = usage
 
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
 		return mcp.NewToolResultText(string(jsonData)), nil
-	}
+	})
+
+	log.Info().Msg("Environment tools registered")
 }
 
 // Helper functions
 
 // installRuntime installs a specific language runtime
 func installRuntime(ctx context.Context, executor *exec.Executor, vmName string, runtime string) (string, error) {
-	var cmd string
+	cmd, err := runtimeInstallCommand(runtime)
+	if err != nil {
+		return "", err
+	}
+
+	// Setup execution context
+	execCtx := exec.ExecutionContext{
+		VMName:     vmName,
+		WorkingDir: "/home/vagrant",
+		SyncBefore: false,
+		SyncAfter:  false,
+	}
+
+	// Execute the command
+	result, err := executor.ExecuteCommand(ctx, cmd, execCtx, nil)
+	if err != nil {
+		return "", errors.OperationFailed("install runtime", err)
+	}
+	if result.ExitCode != 0 {
+		return result.Stdout, errors.OperationFailed("install runtime", fmt.Errorf("exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr)))
+	}
+
+	return result.Stdout, nil
+}
 
+// runtimeInstallCommand returns the shell command that installs a language runtime. A runtime
+// pinned to a version, such as node@20.11.1, is installed with its version manager.
+func runtimeInstallCommand(runtime string) (string, error) {
+	if name, version := project.SplitRuntime(runtime); version != "" {
+		return versionManagerCommand(name, version)
+	}
 	switch runtime {
 	case "node":
-		cmd = "curl -sL https://deb.nodesource.com/setup_16.x | sudo -E bash - && sudo apt-get install -y nodejs"
+		return "curl -sL https://deb.nodesource.com/setup_16.x | sudo -E bash - && sudo apt-get install -y nodejs", nil
 	case "python":
-		cmd = "sudo apt-get update && sudo apt-get install -y python3 python3-pip python3-venv"
+		return "sudo apt-get update && sudo apt-get install -y python3 python3-pip python3-venv", nil
 	case "go":
-		cmd = "sudo apt-get update && sudo apt-get install -y golang"
+		return "sudo apt-get update && sudo apt-get install -y golang", nil
 	case "ruby":
-		cmd = "sudo apt-get update && sudo apt-get install -y ruby-full"
+		return "sudo apt-get update && sudo apt-get install -y ruby-full", nil
 	case "php":
-		cmd = "sudo apt-get update && sudo apt-get install -y php php-cli php-fpm php-json php-common php-mysql php-zip php-gd php-mbstring php-curl php-xml php-pear php-bcmath"
+		return "sudo apt-get update && sudo apt-get install -y php php-cli php-fpm php-json php-common php-mysql php-zip php-gd php-mbstring php-curl php-xml php-pear php-bcmath", nil
 	case "java":
-		cmd = "sudo apt-get update && sudo apt-get install -y default-jdk"
+		return "sudo apt-get update && sudo apt-get install -y default-jdk", nil
 	default:
 		return "", errors.InvalidInput(fmt.Sprintf("unsupported runtime: %s", runtime))
 	}
+}
+
+// installTool installs a specific development tool
+func installTool(ctx context.Context, executor *exec.Executor, vmName string, tool string) (string, error) {
+	cmd := toolInstallCommand(tool)
 
 	// Setup execution context
 	execCtx := exec.ExecutionContext{
@@ -286,66 +545,138 @@ func installRuntime(ctx context.Context, executor *exec.Executor, vmName string,
 	// Execute the command
 	result, err := executor.ExecuteCommand(ctx, cmd, execCtx, nil)
 	if err != nil {
-		return "", errors.OperationFailed("install runtime", err)
+		return "", errors.OperationFailed("install tool", err)
 	}
 
 	return result.Stdout, nil
 }
 
-// installTool installs a specific development tool
-func installTool(ctx context.Context, executor *exec.Executor, vmName string, tool string) (string, error) {
-	var cmd string
-
+// toolInstallCommand returns the shell command that installs a development tool
+func toolInstallCommand(tool string) string {
 	switch tool {
 	case "git":
-		cmd = "sudo apt-get update && sudo apt-get install -y git"
+		return "sudo apt-get update && sudo apt-get install -y git"
 	case "docker":
-		cmd = "curl -fsSL https://get.docker.com -o get-docker.sh && sudo sh get-docker.sh"
+		return "curl -fsSL https://get.docker.com -o get-docker.sh && sudo sh get-docker.sh"
 	case "docker-compose":
-		cmd = "sudo curl -L \"https://github.com/docker/compose/releases/download/1.29.2/docker-compose-$(uname -s)-$(uname -m)\" -o /usr/local/bin/docker-compose && sudo chmod +x /usr/local/bin/docker-compose"
+		return "sudo curl -L \"https://github.com/docker/compose/releases/download/1.29.2/docker-compose-$(uname -s)-$(uname -m)\" -o /usr/local/bin/docker-compose && sudo chmod +x /usr/local/bin/docker-compose"
 	case "nginx":
-		cmd = "sudo apt-get update && sudo apt-get install -y nginx"
+		return "sudo apt-get update && sudo apt-get install -y nginx"
 	case "postgresql":
-		cmd = "sudo apt-get update && sudo apt-get install -y postgresql postgresql-contrib"
+		return "sudo apt-get update && sudo apt-get install -y postgresql postgresql-contrib"
 	case "mysql":
-		cmd = "sudo apt-get update && sudo apt-get install -y mysql-server"
+		return "sudo apt-get update && sudo apt-get install -y mysql-server"
 	case "mongodb":
-		cmd = "sudo apt-get update && sudo apt-get install -y mongodb"
+		return "sudo apt-get update && sudo apt-get install -y mongodb"
 	case "redis":
-		cmd = "sudo apt-get update && sudo apt-get install -y redis-server"
+		return "sudo apt-get update && sudo apt-get install -y redis-server"
 	default:
 		// Try to install as a generic package
-		cmd = fmt.Sprintf("sudo apt-get update && sudo apt-get install -y %s", tool)
+		return fmt.Sprintf("sudo apt-get update && sudo apt-get install -y %s", tool)
 	}
+}
 
-	// Setup execution context
-	execCtx := exec.ExecutionContext{
-		VMName:     vmName,
-		WorkingDir: "/home/vagrant",
-		SyncBefore: false,
-		SyncAfter:  false,
-	}
+// Markers of the block configure_shell manages in a shell rc file
+const (
+	shellBlockBegin = "# BEGIN vagrant-mcp shell"
+	shellBlockEnd   = "# END vagrant-mcp shell"
+)
 
-	// Execute the command
-	result, err := executor.ExecuteCommand(ctx, cmd, execCtx, nil)
-	if err != nil {
-		return "", errors.OperationFailed("install tool", err)
+// configure_shell actions
+const (
+	shellActionSet      = "set"
+	shellActionRemove   = "remove"
+	shellActionRollback = "rollback"
+)
+
+// shellBackupSuffix names the copy of an rc file kept before configure_shell last changed it
+const shellBackupSuffix = ".vagrant-mcp.bak"
+
+// shellRCFiles maps the shells configure_shell supports to their rc files
+var shellRCFiles = map[string]string{
+	"bash": "/home/vagrant/.bashrc",
+	"zsh":  "/home/vagrant/.zshrc",
+}
+
+// shellVariablePattern matches environment variable names
+var shellVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellBlock returns the managed block defining aliases (name=value) and environment variables
+// (NAME=value), or an empty string when there are none
+func shellBlock(aliases, envVars []string) (string, error) {
+	if len(aliases) == 0 && len(envVars) == 0 {
+		return "", nil
+	}
+	var block strings.Builder
+	block.WriteString(shellBlockBegin + "\n")
+	block.WriteString("# Managed by configure_shell; changes inside this block are overwritten\n")
+	for _, alias := range aliases {
+		name, _, found := strings.Cut(alias, "=")
+		if !found || name == "" || strings.ContainsAny(alias, "\n\r") {
+			return "", errors.InvalidInput(fmt.Sprintf("invalid alias '%s' (expected name=value)", alias))
+		}
+		block.WriteString("alias " + alias + "\n")
 	}
+	for _, envVar := range envVars {
+		name, _, found := strings.Cut(envVar, "=")
+		if !found || !shellVariablePattern.MatchString(name) || strings.ContainsAny(envVar, "\n\r") {
+			return "", errors.InvalidInput(fmt.Sprintf("invalid environment variable '%s' (expected NAME=value)", envVar))
+		}
+		block.WriteString("export " + envVar + "\n")
+	}
+	block.WriteString(shellBlockEnd + "\n")
+	return block.String(), nil
+}
 
-	return result.Stdout, nil
+// shellConfigScript returns the script applying a configure_shell action to an rc file. Set and
+// remove replace the managed block through a temporary file renamed over the rc file, keeping
+// the previous rc file as a backup; they leave an rc file that would not change untouched.
+// Rollback restores the backup. The script prints what it did.
+func shellConfigScript(rcFile, action, block string) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, "set -e\nrc=%s\nbackup=%s\n", shellquote.Quote(rcFile), shellquote.Quote(rcFile+shellBackupSuffix))
+	if action == shellActionRollback {
+		script.WriteString(`[ -f "$backup" ] || { echo "there is no backup of $rc to roll back to" >&2; exit 1; }` + "\n")
+		script.WriteString(`tmp=$(mktemp "$rc.XXXXXX")` + "\n")
+		script.WriteString(`cp -p "$backup" "$tmp"` + "\n")
+		script.WriteString(`mv -f "$tmp" "$rc"` + "\n")
+		script.WriteString(`rm -f "$backup"` + "\n")
+		script.WriteString("echo restored")
+		return script.String()
+	}
+	script.WriteString(`touch "$rc"` + "\n")
+	script.WriteString(`tmp=$(mktemp "$rc.XXXXXX")` + "\n")
+	fmt.Fprintf(&script, "sed '/^%s$/,/^%s$/d' \"$rc\" > \"$tmp\"\n", shellBlockBegin, shellBlockEnd)
+	if block != "" {
+		// base64 keeps the block's quotes out of the script
+		fmt.Fprintf(&script, "echo '%s' | base64 -d >> \"$tmp\"\n", base64.StdEncoding.EncodeToString([]byte(block)))
+	}
+	script.WriteString(`if cmp -s "$tmp" "$rc"; then rm -f "$tmp"; echo unchanged; exit 0; fi` + "\n")
+	script.WriteString(`cp -p "$rc" "$backup"` + "\n")
+	script.WriteString(`chmod --reference="$rc" "$tmp"` + "\n")
+	script.WriteString(`mv -f "$tmp" "$rc"` + "\n")
+	script.WriteString("echo updated")
+	return script.String()
 }
 
-// configureShellEnv configures shell environment
-func configureShellEnv(ctx context.Context, executor *exec.Executor, vmName string, shellType string, aliases []string, envVars []string) (string, error) {
-	var rcFile string
-	switch shellType {
-	case "bash":
-		rcFile = "/home/vagrant/.bashrc"
-	case "zsh":
-		rcFile = "/home/vagrant/.zshrc"
-	default:
+// configureShellEnv applies a configure_shell action to the rc file of a shell and returns
+// what the guest script did: updated, unchanged or restored
+func configureShellEnv(ctx context.Context, executor *exec.Executor, vmName string, shellType string, action string, aliases []string, envVars []string) (string, error) {
+	rcFile, ok := shellRCFiles[shellType]
+	if !ok {
 		return "", errors.InvalidInput(fmt.Sprintf("unsupported shell type: %s", shellType))
 	}
+	var block string
+	switch action {
+	case shellActionSet:
+		var err error
+		if block, err = shellBlock(aliases, envVars); err != nil {
+			return "", err
+		}
+	case shellActionRemove, shellActionRollback:
+	default:
+		return "", errors.InvalidInput(fmt.Sprintf("unsupported action: %s (must be %s, %s or %s)", action, shellActionSet, shellActionRemove, shellActionRollback))
+	}
 
 	// Setup execution context
 	execCtx := exec.ExecutionContext{
@@ -355,36 +686,12 @@ func configureShellEnv(ctx context.Context, executor *exec.Executor, vmName stri
 		SyncAfter:  false,
 	}
 
-	// Build shell configuration
-	var config strings.Builder
-	config.WriteString("\n# Configured by vagrant-mcp-server\n")
-
-	// Add aliases
-	if len(aliases) > 0 {
-		config.WriteString("\n# Aliases\n")
-		for _, alias := range aliases {
-			config.WriteString(fmt.Sprintf("alias %s\n", alias))
-		}
-	}
-
-	// Add environment variables
-	if len(envVars) > 0 {
-		config.WriteString("\n# Environment Variables\n")
-		for _, envVar := range envVars {
-			config.WriteString(fmt.Sprintf("export %s\n", envVar))
-		}
-	}
-
-	// Write to rc file
-	appendCmd := fmt.Sprintf("echo '%s' >> %s", config.String(), rcFile)
-	result, err := executor.ExecuteCommand(ctx, appendCmd, execCtx, nil)
+	result, err := executor.ExecuteCommand(ctx, shellConfigScript(rcFile, action, block), execCtx, nil)
 	if err != nil {
 		return "", errors.OperationFailed("configure shell", err)
 	}
-
-	// Source the file to apply changes
-	sourceCmd := fmt.Sprintf("source %s", rcFile)
-	_, _ = executor.ExecuteCommand(ctx, sourceCmd, execCtx, nil)
-
-	return result.Stdout, nil
+	if result.ExitCode != 0 {
+		return "", errors.OperationFailed("configure shell", fmt.Errorf("%s", strings.TrimSpace(result.Stderr)))
+	}
+	return strings.TrimSpace(result.Stdout), nil
 }
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/vm"
+)
+
+func TestShellBlock(t *testing.T) {
+	block, err := shellBlock([]string{"ll='ls -la'"}, []string{"EDITOR=vim"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	expected := shellBlockBegin + "\n# Managed by configure_shell; changes inside this block are overwritten\nalias ll='ls -la'\nexport EDITOR=vim\n" + shellBlockEnd + "\n"
+	if block != expected {
+		t.Errorf("Expected block %q but got %q", expected, block)
+	}
+	if block, err := shellBlock(nil, nil); err != nil || block != "" {
+		t.Errorf("Expected no block but got %q (%v)", block, err)
+	}
+
+	testCases := []struct {
+		name    string
+		aliases []string
+		envVars []string
+	}{
+		{"alias without value", []string{"ll"}, nil},
+		{"alias with newline", []string{"ll=ls\nrm -rf /"}, nil},
+		{"invalid variable name", nil, []string{"1FOO=bar"}},
+		{"variable without value", nil, []string{"FOO"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := shellBlock(tc.aliases, tc.envVars); err == nil {
+				t.Error("Expected an error but got none")
+			}
+		})
+	}
+}
+
+func TestShellConfigScript(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("The script uses GNU coreutils")
+	}
+	bash, err := osexec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	rcFile := filepath.Join(t.TempDir(), ".bashrc")
+	original := "# user settings\nalias gs='git status'\n"
+	if err := os.WriteFile(rcFile, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write rc file: %v", err)
+	}
+	run := func(action, block string) string {
+		output, err := osexec.Command(bash, "-c", shellConfigScript(rcFile, action, block)).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Expected %s to succeed but got %v: %s", action, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	read := func() string {
+		data, err := os.ReadFile(rcFile)
+		if err != nil {
+			t.Fatalf("Failed to read rc file: %v", err)
+		}
+		return string(data)
+	}
+
+	block, _ := shellBlock([]string{"ll='ls -la'"}, []string{"EDITOR=vim"})
+	if output := run(shellActionSet, block); output != "updated" {
+		t.Errorf("Expected the first set to update the rc file but got %s", output)
+	}
+	if output := run(shellActionSet, block); output != "unchanged" {
+		t.Errorf("Expected setting the same block again to leave the rc file unchanged but got %s", output)
+	}
+	if content := read(); content != original+block {
+		t.Errorf("Expected the block once after the user settings but got %q", content)
+	}
+
+	replaced, _ := shellBlock(nil, []string{"EDITOR=nano"})
+	run(shellActionSet, replaced)
+	if content := read(); content != original+replaced {
+		t.Errorf("Expected the block to be replaced but got %q", content)
+	}
+
+	if output := run(shellActionRollback, ""); output != "restored" || read() != original+block {
+		t.Errorf("Expected rollback to restore the previous block but got %s: %q", output, read())
+	}
+
+	run(shellActionRemove, "")
+	if content := read(); content != original {
+		t.Errorf("Expected remove to leave the user settings but got %q", content)
+	}
+
+	run(shellActionRollback, "")
+	if _, err := osexec.Command(bash, "-c", shellConfigScript(rcFile, shellActionRollback, "")).CombinedOutput(); err == nil {
+		t.Error("Expected a rollback without a backup to fail")
+	}
+}
+
+func TestEnvToolsDecodeTypedArguments(t *testing.T) {
+	ctx := context.Background()
+	baseDir := filepath.Join(t.TempDir(), "vms")
+	fake := cmdexec.NewFakeVagrant()
+	manager, err := vm.NewManagerWithRunner(baseDir, fake)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.CreateVM(ctx, "env-vm", t.TempDir(), core.VMConfig{Name: "env-vm", Box: "ubuntu/jammy64"}); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+	srv := server.NewMCPServer("test", "1.0")
+	RegisterEnvTools(srv, &exec.VMManagerAdapter{Real: manager}, nil)
+
+	call := func(name string, arguments map[string]any) (string, bool) {
+		t.Helper()
+		request, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "tools/call",
+			"params":  map[string]any{"name": name, "arguments": arguments},
+		})
+		message := srv.HandleMessage(ctx, request)
+		response, ok := message.(mcpgo.JSONRPCResponse)
+		if !ok {
+			t.Fatalf("Expected a response but got %T", message)
+		}
+		result, ok := response.Result.(mcpgo.CallToolResult)
+		if !ok {
+			t.Fatalf("Expected a tool result but got %T", response.Result)
+		}
+		return extractTextContent(result.Content), result.IsError
+	}
+
+	testCases := []struct {
+		name      string
+		tool      string
+		arguments map[string]any
+		expected  string
+	}{
+		{"install without vm_name", "install_dev_tools", map[string]any{"tools": []string{"go"}}, "missing or invalid 'vm_name' parameter"},
+		{"install without tools", "install_dev_tools", map[string]any{"vm_name": "env-vm", "tools": []string{}}, "missing or invalid 'tools' parameter"},
+		{"install with tools that are not a list", "install_dev_tools", map[string]any{"vm_name": "env-vm", "tools": "go"}, "cannot unmarshal"},
+		// The tools are decoded, so the VM state is checked next
+		{"install on a stopped VM", "install_dev_tools", map[string]any{"vm_name": "env-vm", "tools": []string{"go", "docker"}}, "is not running"},
+		{"configure shell without vm_name", "configure_shell", map[string]any{"aliases": []string{"ll='ls -la'"}}, "missing or invalid 'vm_name' parameter"},
+		{"configure shell with defaults on a stopped VM", "configure_shell", map[string]any{"vm_name": "env-vm", "env_vars": []string{"EDITOR=vim"}}, "is not running"},
+		{"configure shell on a VM never created", "configure_shell", map[string]any{"vm_name": "missing-vm"}, "current state: not_created"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			text, isError := call(tc.tool, tc.arguments)
+			if !isError || !strings.Contains(text, tc.expected) {
+				t.Errorf("Expected an error containing %q but got %q", tc.expected, text)
+			}
+		})
+	}
+}
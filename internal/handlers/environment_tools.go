@@ -0,0 +1,190 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// environmentManager is implemented by VM managers that can list and destroy every Vagrant
+// environment on the host, not only the VMs they manage
+type environmentManager interface {
+	ListVagrantEnvironments(ctx context.Context) ([]vm.VagrantEnvironment, error)
+	DestroyEnvironment(ctx context.Context, environment vm.VagrantEnvironment) (vm.EnvironmentRemoval, error)
+}
+
+// ListEnvironmentsResponse is the result of list_all_vagrant_environments
+type ListEnvironmentsResponse struct {
+	Environments []vm.VagrantEnvironment `json:"environments"`
+	Count        int                     `json:"count"`
+	// Summary counts the environments by ownership
+	Summary map[string]int `json:"summary"`
+}
+
+// CleanupOrphansResponse is the result of cleanup_orphans
+type CleanupOrphansResponse struct {
+	Orphans   []vm.VagrantEnvironment `json:"orphans"`
+	Count     int                     `json:"count"`
+	Destroyed []string                `json:"destroyed"`
+	// Removals report how each destroyed machine was removed and which provider machines went with it
+	Removals []vm.EnvironmentRemoval `json:"removals,omitempty"`
+	// Failed maps the IDs of machines that could not be destroyed to the error
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// RegisterEnvironmentTools registers the host-wide Vagrant environment tools with the MCP server
+func RegisterEnvironmentTools(srv *server.MCPServer, vmManager core.VMManager) {
+	// List all Vagrant environments tool
+	type ListEnvironmentsArgs struct {
+		Ownership string `json:"ownership"`
+	}
+	listEnvironmentsTool := mcp.NewTool("list_all_vagrant_environments",
+		mcp.WithDescription("List every Vagrant machine on the host from 'vagrant global-status', marking which are managed by this server, unmanaged, or orphaned by crashed runs"),
+		mcp.WithString("ownership",
+			mcp.Description("Only list environments with this ownership"),
+			mcp.Enum(vm.EnvironmentManaged, vm.EnvironmentUnmanaged, vm.EnvironmentOrphaned)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, listEnvironmentsTool, func(ctx context.Context, request mcp.CallToolRequest, args ListEnvironmentsArgs) (*mcp.CallToolResult, error) {
+		manager, ok := vmManager.(environmentManager)
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support listing Vagrant environments"), nil
+		}
+		environments, err := manager.ListVagrantEnvironments(ctx)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to list Vagrant environments: %v", err), nil
+		}
+		environments = filterEnvironments(environments, args.Ownership)
+
+		counts := map[string]int{vm.EnvironmentManaged: 0, vm.EnvironmentUnmanaged: 0, vm.EnvironmentOrphaned: 0}
+		for _, environment := range environments {
+			counts[environment.Ownership]++
+		}
+		response := ListEnvironmentsResponse{
+			Environments: environments,
+			Count:        len(environments),
+			Summary:      counts,
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Cleanup orphans tool
+	type CleanupOrphansArgs struct {
+		Destroy bool     `json:"destroy"`
+		IDs     []string `json:"ids"`
+	}
+	cleanupOrphansTool := mcp.NewTool("cleanup_orphans",
+		mcp.WithDescription("Find Vagrant machines left behind by crashed runs (their directory is gone, or no managed VM is registered for it) and optionally force-destroy them. Machines whose directory is gone are deleted through VirtualBox or libvirt and pruned from Vagrant's machine index; the result reports what was removed"),
+		mcp.WithBoolean("destroy",
+			mcp.Description("Force-destroy the orphaned machines instead of only listing them"),
+			mcp.DefaultBool(false)),
+		mcp.WithArray("ids",
+			mcp.Description("Only clean up the orphaned machines with these global-status IDs"),
+			mcp.Items(map[string]any{"type": "string"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, cleanupOrphansTool, func(ctx context.Context, request mcp.CallToolRequest, args CleanupOrphansArgs) (*mcp.CallToolResult, error) {
+		manager, ok := vmManager.(environmentManager)
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support listing Vagrant environments"), nil
+		}
+		environments, err := manager.ListVagrantEnvironments(ctx)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to list Vagrant environments: %v", err), nil
+		}
+		orphans := selectOrphans(environments, args.IDs)
+
+		if !args.Destroy || len(orphans) == 0 {
+			response := CleanupOrphansResponse{
+				Orphans:   orphans,
+				Count:     len(orphans),
+				Destroyed: []string{},
+			}
+			jsonData, err := json.Marshal(response)
+			if err != nil {
+				return mcp.NewToolResultError("Failed to marshal response"), nil
+			}
+			return mcp.NewToolResultText(string(jsonData)), nil
+		}
+
+		cleanup := func(ctx context.Context) (*mcp.CallToolResult, error) {
+			destroyed := make([]string, 0, len(orphans))
+			removals := make([]vm.EnvironmentRemoval, 0, len(orphans))
+			failed := make(map[string]string)
+			for _, orphan := range orphans {
+				removal, err := manager.DestroyEnvironment(ctx, orphan)
+				if err != nil {
+					log.Warn().Err(err).Str("id", orphan.ID).Msg("Failed to destroy orphaned Vagrant environment")
+					failed[orphan.ID] = err.Error()
+					continue
+				}
+				destroyed = append(destroyed, orphan.ID)
+				removals = append(removals, removal)
+			}
+			response := CleanupOrphansResponse{
+				Orphans:   orphans,
+				Count:     len(orphans),
+				Destroyed: destroyed,
+				Removals:  removals,
+				Failed:    failed,
+			}
+			jsonData, err := json.Marshal(response)
+			if err != nil {
+				return mcp.NewToolResultError("Failed to marshal response"), nil
+			}
+			return mcp.NewToolResultText(string(jsonData)), nil
+		}
+		if approval.GlobalGate.Requires(approval.OperationDestroyVM) {
+			ids := make([]string, 0, len(orphans))
+			for _, orphan := range orphans {
+				ids = append(ids, orphan.ID)
+			}
+			return approvalRequiredResult(approval.GlobalGate, approval.OperationDestroyVM, "cleanup_orphans", "", map[string]interface{}{"ids": ids}, cleanup)
+		}
+		return cleanup(ctx)
+	})
+
+	log.Info().Msg("Vagrant environment tools registered")
+}
+
+// filterEnvironments returns the environments with the given ownership, or all of them when ownership is empty
+func filterEnvironments(environments []vm.VagrantEnvironment, ownership string) []vm.VagrantEnvironment {
+	if ownership == "" {
+		return environments
+	}
+	filtered := make([]vm.VagrantEnvironment, 0, len(environments))
+	for _, environment := range environments {
+		if environment.Ownership == ownership {
+			filtered = append(filtered, environment)
+		}
+	}
+	return filtered
+}
+
+// selectOrphans returns the orphaned environments, limited to ids when any are given.
+// Managed and unmanaged environments are never selected, even when their ID is listed.
+func selectOrphans(environments []vm.VagrantEnvironment, ids []string) []vm.VagrantEnvironment {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	orphans := make([]vm.VagrantEnvironment, 0)
+	for _, environment := range filterEnvironments(environments, vm.EnvironmentOrphaned) {
+		if len(ids) == 0 || wanted[environment.ID] {
+			orphans = append(orphans, environment)
+		}
+	}
+	return orphans
+}
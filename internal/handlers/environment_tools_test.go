@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/vm"
+)
+
+func TestSelectOrphans(t *testing.T) {
+	environments := []vm.VagrantEnvironment{
+		{ID: "a", Ownership: vm.EnvironmentManaged},
+		{ID: "b", Ownership: vm.EnvironmentOrphaned},
+		{ID: "c", Ownership: vm.EnvironmentUnmanaged},
+		{ID: "d", Ownership: vm.EnvironmentOrphaned},
+	}
+
+	testCases := []struct {
+		name     string
+		ids      []string
+		expected []string
+	}{
+		{"all orphans", nil, []string{"b", "d"}},
+		{"selected orphan", []string{"d"}, []string{"d"}},
+		{"managed and unmanaged IDs are ignored", []string{"a", "c"}, []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			orphans := selectOrphans(environments, tc.ids)
+			if len(orphans) != len(tc.expected) {
+				t.Fatalf("Expected orphans %v but got %v", tc.expected, orphans)
+			}
+			for i, orphan := range orphans {
+				if orphan.ID != tc.expected[i] {
+					t.Errorf("Expected orphan %s but got %s", tc.expected[i], orphan.ID)
+				}
+			}
+		})
+	}
+}
@@ -7,25 +7,117 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/artifacts"
 	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
 	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	"github.com/vagrant-mcp/server/internal/testrun"
 	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
 )
 
+// ExecDryRunResponse is the result of exec_in_vm with dry_run
+type ExecDryRunResponse struct {
+	VMName   string              `json:"vm_name"`
+	Command  string              `json:"command"`
+	DryRun   bool                `json:"dry_run"`
+	Decision exec.PolicyDecision `json:"decision"`
+}
+
+// ExecResponse is the result of exec_in_vm and, with the sync flags, of exec_with_sync
+type ExecResponse struct {
+	VMName     string  `json:"vm_name"`
+	Command    string  `json:"command"`
+	ExitCode   int     `json:"exit_code"`
+	Stdout     string  `json:"stdout"`
+	Stderr     string  `json:"stderr"`
+	DurationS  float64 `json:"duration_s"`
+	SyncBefore *bool   `json:"sync_before,omitempty"`
+	SyncAfter  *bool   `json:"sync_after,omitempty"`
+	// SyncBeforeResult and SyncAfterResult describe the syncs run around the command, and
+	// SyncAfterError why the sync after it failed
+	SyncBeforeResult *core.SyncResult `json:"sync_before_result,omitempty"`
+	SyncAfterResult  *core.SyncResult `json:"sync_after_result,omitempty"`
+	SyncAfterError   string           `json:"sync_after_error,omitempty"`
+	// Truncation is set when stdout or stderr exceeded the output limit
+	Truncation *exec.Truncation `json:"truncation,omitempty"`
+}
+
+// BackgroundTaskResponse is the result of run_background_task
+type BackgroundTaskResponse struct {
+	VMName   string `json:"vm_name"`
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	LogFile  string `json:"log_file"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// RunScriptResponse is the result of run_script_in_vm
+type RunScriptResponse struct {
+	VMName      string  `json:"vm_name"`
+	Interpreter string  `json:"interpreter"`
+	ScriptPath  string  `json:"script_path"`
+	ExitCode    int     `json:"exit_code"`
+	Stdout      string  `json:"stdout"`
+	Stderr      string  `json:"stderr"`
+	DurationS   float64 `json:"duration_s"`
+	// Truncation is set when stdout or stderr exceeded the output limit
+	Truncation *exec.Truncation `json:"truncation,omitempty"`
+}
+
+// RunTestsResponse is the result of run_tests
+type RunTestsResponse struct {
+	VMName     string          `json:"vm_name"`
+	Framework  string          `json:"framework"`
+	Command    string          `json:"command"`
+	WorkingDir string          `json:"working_dir"`
+	Passed     bool            `json:"passed"`
+	ExitCode   int             `json:"exit_code"`
+	Summary    testrun.Summary `json:"summary"`
+	Stdout     string          `json:"stdout"`
+	Stderr     string          `json:"stderr"`
+	DurationS  float64         `json:"duration_s"`
+	Synced     bool            `json:"synced"`
+	Coverage   bool            `json:"coverage"`
+	// The coverage fields are set when the coverage report was fetched to the host
+	CoverageArtifacts []string `json:"coverage_artifacts,omitempty"`
+	CoverageError     string   `json:"coverage_error,omitempty"`
+	CoverageDir       string   `json:"coverage_dir,omitempty"`
+	// CoverageReport is the URI of a copy of a single-file coverage report, readable with
+	// get_artifact
+	CoverageReport string `json:"coverage_report,omitempty"`
+	// Truncation is set when stdout or stderr exceeded the output limit; the summary is parsed
+	// from the output kept
+	Truncation *exec.Truncation `json:"truncation,omitempty"`
+}
+
 // RegisterExecTools registers all execution-related tools with the MCP server
 func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine core.SyncEngine, executor *exec.Executor) {
 	// Execute in VM tool
 	type ExecInVMArgs struct {
-		VMName     string `json:"vm_name"`
-		Command    string `json:"command"`
-		WorkingDir string `json:"working_dir"`
+		VMName         string  `json:"vm_name"`
+		Command        string  `json:"command"`
+		WorkingDir     string  `json:"working_dir"`
+		TimeoutSeconds float64 `json:"timeout_seconds"`
+		MaxOutputBytes int     `json:"max_output_bytes"`
+		SpillOutput    bool    `json:"spill_output"`
+		ForwardAgent   bool    `json:"forward_agent"`
+		DryRun         bool    `json:"dry_run"`
 	}
 	execInVMTool := mcp.NewTool("exec_in_vm",
-		mcp.WithDescription("Execute a command in the VM without file synchronization"),
+		mcp.WithDescription("Execute a command in the VM without file synchronization. Commands are checked against the server's exec policy first; a blocked command is not run and the response explains which rule blocked it"),
 		mcp.WithString("vm_name",
 			mcp.Required(),
 			mcp.Description("Name of the development VM")),
@@ -35,6 +127,19 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 		mcp.WithString("working_dir",
 			mcp.Description("Working directory"),
 			mcp.DefaultString("/home/vagrant")),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum run time in seconds; the command is killed in the VM when it is exceeded (default: the server's maximum)")),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Keep at most this many bytes of stdout and of stderr, their head and tail around a truncation marker (default and maximum: the server's limit)")),
+		mcp.WithBoolean("spill_output",
+			mcp.Description("Keep the full output of a truncated stream as an artifact, readable at the devvm://artifacts/{id} URI in the result's truncation"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("forward_agent",
+			mcp.Description("Forward the host's SSH agent to the command, e.g. to sign commits or fetch private dependencies; VMs with forward_agent set forward it to every command"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only check the command against the exec policy and explain whether it would be blocked, without running it"),
+			mcp.DefaultBool(false)),
 	)
 
 	mcp_pkg.RegisterTypedTool(srv, execInVMTool, func(ctx context.Context, request mcp.CallToolRequest, args ExecInVMArgs) (*mcp.CallToolResult, error) {
@@ -45,23 +150,42 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 		if workingDir == "" {
 			workingDir = "/home/vagrant"
 		}
+		if args.DryRun {
+			response := ExecDryRunResponse{
+				VMName:   args.VMName,
+				Command:  args.Command,
+				DryRun:   true,
+				Decision: exec.GlobalPolicy.Evaluate(args.VMName, args.Command, workingDir),
+			}
+			jsonResponse, err := json.Marshal(response)
+			if err != nil {
+				return mcp.NewToolResultError("Failed to marshal response"), nil
+			}
+			return mcp.NewToolResultText(string(jsonResponse)), nil
+		}
 		execCtx := exec.ExecutionContext{
-			VMName:     args.VMName,
-			WorkingDir: workingDir,
-			SyncBefore: false,
-			SyncAfter:  false,
+			VMName:         args.VMName,
+			WorkingDir:     workingDir,
+			SyncBefore:     false,
+			SyncAfter:      false,
+			Timeout:        secondsToDuration(args.TimeoutSeconds),
+			MaxOutputBytes: args.MaxOutputBytes,
+			SpillOutput:    args.SpillOutput,
+			ForwardAgent:   args.ForwardAgent,
+			PolicyCommand:  args.Command,
 		}
 		result, err := executor.ExecuteCommand(ctx, args.Command, execCtx, nil)
 		if err != nil {
-			return mcp.NewToolResultErrorf("Command execution failed: %v", err), nil
+			return commandFailedResult("Command execution failed", result, err), nil
 		}
-		response := map[string]interface{}{
-			"vm_name":    args.VMName,
-			"command":    args.Command,
-			"exit_code":  result.ExitCode,
-			"stdout":     result.Stdout,
-			"stderr":     result.Stderr,
-			"duration_s": result.Duration,
+		response := ExecResponse{
+			VMName:     args.VMName,
+			Command:    args.Command,
+			ExitCode:   result.ExitCode,
+			Stdout:     result.Stdout,
+			Stderr:     result.Stderr,
+			DurationS:  result.Duration,
+			Truncation: result.Truncation,
 		}
 		jsonResponse, err := json.Marshal(response)
 		if err != nil {
@@ -72,11 +196,16 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 
 	// Execute with sync tool
 	type ExecWithSyncArgs struct {
-		VMName     string `json:"vm_name"`
-		Command    string `json:"command"`
-		WorkingDir string `json:"working_dir"`
-		SyncBefore bool   `json:"sync_before"`
-		SyncAfter  bool   `json:"sync_after"`
+		VMName         string  `json:"vm_name"`
+		Command        string  `json:"command"`
+		WorkingDir     string  `json:"working_dir"`
+		SyncBefore     bool    `json:"sync_before"`
+		SyncAfter      bool    `json:"sync_after"`
+		SyncMode       string  `json:"sync_mode"`
+		TimeoutSeconds float64 `json:"timeout_seconds"`
+		MaxOutputBytes int     `json:"max_output_bytes"`
+		SpillOutput    bool    `json:"spill_output"`
+		ForwardAgent   bool    `json:"forward_agent"`
 	}
 	execWithSyncTool := mcp.NewTool("exec_with_sync",
 		mcp.WithDescription("Execute a command in the VM with file synchronization before and after"),
@@ -95,6 +224,20 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 		mcp.WithBoolean("sync_after",
 			mcp.Description("Sync files from VM after execution"),
 			mcp.DefaultBool(true)),
+		mcp.WithString("sync_mode",
+			mcp.Description("What sync_before syncs: changed (only the files changed on the host since the last sync, nothing when none did) or full (the whole project)"),
+			mcp.Enum(string(exec.SyncModeChanged), string(exec.SyncModeFull)),
+			mcp.DefaultString(string(exec.SyncModeChanged))),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum run time in seconds; the command is killed in the VM when it is exceeded (default: the server's maximum)")),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Keep at most this many bytes of stdout and of stderr, their head and tail around a truncation marker (default and maximum: the server's limit)")),
+		mcp.WithBoolean("spill_output",
+			mcp.Description("Keep the full output of a truncated stream as an artifact, readable at the devvm://artifacts/{id} URI in the result's truncation"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("forward_agent",
+			mcp.Description("Forward the host's SSH agent to the command, e.g. to sign commits or fetch private dependencies; VMs with forward_agent set forward it to every command"),
+			mcp.DefaultBool(false)),
 	)
 
 	mcp_pkg.RegisterTypedTool(srv, execWithSyncTool, func(ctx context.Context, request mcp.CallToolRequest, args ExecWithSyncArgs) (*mcp.CallToolResult, error) {
@@ -105,6 +248,11 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 		if workingDir == "" {
 			workingDir = "/home/vagrant"
 		}
+		switch exec.SyncMode(args.SyncMode) {
+		case "", exec.SyncModeChanged, exec.SyncModeFull:
+		default:
+			return mcp.NewToolResultErrorf("Invalid sync_mode '%s': must be 'changed' or 'full'", args.SyncMode), nil
+		}
 		log.Info().
 			Str("vm", args.VMName).
 			Str("command", args.Command).
@@ -112,24 +260,34 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 			Bool("sync_after", args.SyncAfter).
 			Msg("Executing command with sync")
 		execCtx := exec.ExecutionContext{
-			VMName:     args.VMName,
-			WorkingDir: workingDir,
-			SyncBefore: args.SyncBefore,
-			SyncAfter:  args.SyncAfter,
+			VMName:         args.VMName,
+			WorkingDir:     workingDir,
+			SyncBefore:     args.SyncBefore,
+			SyncAfter:      args.SyncAfter,
+			SyncMode:       exec.SyncMode(args.SyncMode),
+			Timeout:        secondsToDuration(args.TimeoutSeconds),
+			MaxOutputBytes: args.MaxOutputBytes,
+			SpillOutput:    args.SpillOutput,
+			ForwardAgent:   args.ForwardAgent,
+			PolicyCommand:  args.Command,
 		}
 		result, err := executor.ExecuteCommand(ctx, args.Command, execCtx, nil)
 		if err != nil {
-			return mcp.NewToolResultErrorf("Command execution failed: %v", err), nil
-		}
-		response := map[string]interface{}{
-			"vm_name":     args.VMName,
-			"command":     args.Command,
-			"exit_code":   result.ExitCode,
-			"stdout":      result.Stdout,
-			"stderr":      result.Stderr,
-			"duration_s":  result.Duration,
-			"sync_before": args.SyncBefore,
-			"sync_after":  args.SyncAfter,
+			return commandFailedResult("Command execution failed", result, err), nil
+		}
+		response := ExecResponse{
+			VMName:           args.VMName,
+			Command:          args.Command,
+			ExitCode:         result.ExitCode,
+			Stdout:           result.Stdout,
+			Stderr:           result.Stderr,
+			DurationS:        result.Duration,
+			SyncBefore:       &args.SyncBefore,
+			SyncAfter:        &args.SyncAfter,
+			Truncation:       result.Truncation,
+			SyncBeforeResult: result.SyncBefore,
+			SyncAfterResult:  result.SyncAfter,
+			SyncAfterError:   result.SyncAfterError,
 		}
 		jsonResponse, err := json.Marshal(response)
 		if err != nil {
@@ -174,18 +332,130 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 			WorkingDir: workingDir,
 			SyncBefore: args.SyncBefore,
 			SyncAfter:  false, // No sync after for background tasks
+			// The policy sees the command, not the nohup wrapper
+			PolicyCommand: args.Command,
 		}
 		bgCommand := fmt.Sprintf("nohup %s > /tmp/bg_%s.log 2>&1 &", args.Command, args.VMName)
 		result, err := executor.ExecuteCommand(ctx, bgCommand, execCtx, nil)
 		if err != nil {
 			return mcp.NewToolResultErrorf("Background task start failed: %v", err), nil
 		}
-		response := map[string]interface{}{
-			"vm_name":   args.VMName,
-			"command":   args.Command,
-			"status":    "started",
-			"log_file":  fmt.Sprintf("/tmp/bg_%s.log", args.VMName),
-			"exit_code": result.ExitCode,
+		response := BackgroundTaskResponse{
+			VMName:   args.VMName,
+			Command:  args.Command,
+			Status:   "started",
+			LogFile:  fmt.Sprintf("/tmp/bg_%s.log", args.VMName),
+			ExitCode: result.ExitCode,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Run script tool
+	type RunScriptArgs struct {
+		VMName         string   `json:"vm_name"`
+		Script         string   `json:"script"`
+		Interpreter    string   `json:"interpreter"`
+		Args           []string `json:"args"`
+		WorkingDir     string   `json:"working_dir"`
+		TimeoutSeconds float64  `json:"timeout_seconds"`
+		MaxOutputBytes int      `json:"max_output_bytes"`
+		SpillOutput    bool     `json:"spill_output"`
+	}
+	runScriptTool := mcp.NewTool("run_script_in_vm",
+		mcp.WithDescription("Upload an inline multi-line script to the VM and run it, returning its exit code and output. Output lines are streamed as progress notifications when the request carries a progress token"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("script",
+			mcp.Required(),
+			mcp.Description("Script content")),
+		mcp.WithString("interpreter",
+			mcp.Description("Interpreter to run the script with"),
+			mcp.Enum(scriptInterpreterNames()...),
+			mcp.DefaultString("bash")),
+		mcp.WithArray("args",
+			mcp.Description("Arguments passed to the script"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("working_dir",
+			mcp.Description("Working directory"),
+			mcp.DefaultString("/home/vagrant")),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum run time in seconds; the command is killed in the VM when it is exceeded (default: the server's maximum)")),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Keep at most this many bytes of stdout and of stderr, their head and tail around a truncation marker (default and maximum: the server's limit)")),
+		mcp.WithBoolean("spill_output",
+			mcp.Description("Keep the full output of a truncated stream as an artifact, readable at the devvm://artifacts/{id} URI in the result's truncation"),
+			mcp.DefaultBool(false)),
+	)
+
+	mcp_pkg.RegisterTypedTool(srv, runScriptTool, func(ctx context.Context, request mcp.CallToolRequest, args RunScriptArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Script == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or script"), nil
+		}
+		interpreter := args.Interpreter
+		if interpreter == "" {
+			interpreter = "bash"
+		}
+		runtime, ok := scriptInterpreters[interpreter]
+		if !ok {
+			return mcp.NewToolResultErrorf("Unsupported interpreter '%s' (must be one of %s)", interpreter, strings.Join(scriptInterpreterNames(), ", ")), nil
+		}
+		workingDir := args.WorkingDir
+		if workingDir == "" {
+			workingDir = "/home/vagrant"
+		}
+		// Checked before the upload too, so a blocked script is not left in the guest
+		if err := exec.GlobalPolicy.Check(args.VMName, args.Script, workingDir); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// Write the script to a host temp file and upload it instead of embedding it in the SSH command
+		localFile, err := os.CreateTemp("", "vagrant-mcp-script-*"+runtime.extension)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to create script file: %v", err), nil
+		}
+		defer os.Remove(localFile.Name())
+		if _, err := localFile.WriteString(args.Script); err != nil {
+			localFile.Close()
+			return mcp.NewToolResultErrorf("Failed to write script file: %v", err), nil
+		}
+		if err := localFile.Close(); err != nil {
+			return mcp.NewToolResultErrorf("Failed to write script file: %v", err), nil
+		}
+
+		remotePath := "/tmp/" + filepath.Base(localFile.Name())
+		if err := vmManager.UploadToVM(ctx, args.VMName, localFile.Name(), remotePath, false, ""); err != nil {
+			return mcp.NewToolResultErrorf("Failed to upload script: %v", err), nil
+		}
+
+		command := buildScriptCommand(runtime.command, remotePath, workingDir, args.Args)
+		execCtx := exec.ExecutionContext{
+			VMName:         args.VMName,
+			SyncBefore:     false,
+			SyncAfter:      false,
+			Timeout:        secondsToDuration(args.TimeoutSeconds),
+			MaxOutputBytes: args.MaxOutputBytes,
+			SpillOutput:    args.SpillOutput,
+			PolicyCommand:  args.Script,
+			PolicyDir:      workingDir,
+		}
+		result, err := executor.ExecuteCommand(ctx, command, execCtx, progressOutputCallback(ctx, request))
+		if err != nil {
+			return commandFailedResult("Script execution failed", result, err), nil
+		}
+		response := RunScriptResponse{
+			VMName:      args.VMName,
+			Interpreter: interpreter,
+			ScriptPath:  remotePath,
+			ExitCode:    result.ExitCode,
+			Stdout:      result.Stdout,
+			Stderr:      result.Stderr,
+			DurationS:   result.Duration,
+			Truncation:  result.Truncation,
 		}
 		jsonResponse, err := json.Marshal(response)
 		if err != nil {
@@ -194,5 +464,269 @@ func RegisterExecTools(srv *server.MCPServer, vmManager core.VMManager, syncEngi
 		return mcp.NewToolResultText(string(jsonResponse)), nil
 	})
 
+	// Run tests tool
+	type RunTestsArgs struct {
+		VMName         string   `json:"vm_name"`
+		Framework      string   `json:"framework"`
+		WorkingDir     string   `json:"working_dir"`
+		Filter         string   `json:"filter"`
+		Args           []string `json:"args"`
+		Coverage       bool     `json:"coverage"`
+		Sync           *bool    `json:"sync"`
+		FetchCoverage  *bool    `json:"fetch_coverage"`
+		TimeoutSeconds float64  `json:"timeout_seconds"`
+		MaxOutputBytes int      `json:"max_output_bytes"`
+		SpillOutput    bool     `json:"spill_output"`
+	}
+	runTestsTool := mcp.NewTool("run_tests",
+		mcp.WithDescription("Sync the project and run its test suite in the VM (go test, npm test, pytest or cargo test, detected from the project files), returning a summary of passed, failed and skipped tests with the failing test names. Output lines are streamed as progress notifications when the request carries a progress token"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("framework",
+			mcp.Description("Test framework; detected from the project when omitted"),
+			mcp.Enum(testrun.Frameworks...)),
+		mcp.WithString("working_dir",
+			mcp.Description("Directory of the project to test, relative to the synced project root")),
+		mcp.WithString("filter",
+			mcp.Description("Run only matching tests: a -run pattern for go, a name filter for cargo and npm, a -k expression for pytest")),
+		mcp.WithArray("args",
+			mcp.Description("Extra arguments for the test command"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithBoolean("coverage",
+			mcp.Description("Write a coverage report"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("sync",
+			mcp.Description("Sync the project to the VM before running the tests"),
+			mcp.DefaultBool(true)),
+		mcp.WithBoolean("fetch_coverage",
+			mcp.Description("Copy the coverage report back into the host project"),
+			mcp.DefaultBool(true)),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Maximum run time in seconds; the tests are killed in the VM when it is exceeded (default: the server's maximum)")),
+		mcp.WithNumber("max_output_bytes",
+			mcp.Description("Keep at most this many bytes of stdout and of stderr, their head and tail around a truncation marker (default and maximum: the server's limit)")),
+		mcp.WithBoolean("spill_output",
+			mcp.Description("Keep the full output of a truncated stream as an artifact, readable at the devvm://artifacts/{id} URI in the result's truncation"),
+			mcp.DefaultBool(false)),
+	)
+
+	mcp_pkg.RegisterTypedTool(srv, runTestsTool, func(ctx context.Context, request mcp.CallToolRequest, args RunTestsArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		workingDir := filepath.ToSlash(filepath.Clean("/" + args.WorkingDir))[1:]
+		config, err := vmManager.GetVMConfig(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get VM config: %v", err), nil
+		}
+		hostRoot := config.HostPath
+		if hostRoot == "" {
+			hostRoot = config.ProjectPath
+		}
+		guestRoot := guestProjectRoot(config)
+		hostDir := filepath.Join(hostRoot, filepath.FromSlash(workingDir))
+		guestDir := path.Join(guestRoot, workingDir)
+
+		framework := args.Framework
+		if framework == "" {
+			if framework, err = testrun.Detect(hostDir); err != nil {
+				return mcp.NewToolResultErrorf("Failed to detect test framework: %v; pass framework explicitly", err), nil
+			}
+		}
+		command, err := testrun.Command(framework, testrun.Options{Filter: args.Filter, Coverage: args.Coverage, Args: args.Args})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		syncBefore := args.Sync == nil || *args.Sync
+		fetchCoverage := args.Coverage && (args.FetchCoverage == nil || *args.FetchCoverage) && testrun.CoverageArtifact(framework) != ""
+
+		runTests := func(ctx context.Context) (*mcp.CallToolResult, error) {
+			if syncBefore {
+				if _, err := syncEngine.SyncToVM(ctx, args.VMName, ""); err != nil {
+					return mcp.NewToolResultErrorf("Sync to VM failed: %v", err), nil
+				}
+			}
+			log.Info().Str("vm", args.VMName).Str("framework", framework).Str("command", command).Msg("Running tests")
+			// The guest path may lie outside /vagrant, which ExecutionContext.WorkingDir assumes
+			execCtx := exec.ExecutionContext{
+				VMName:         args.VMName,
+				Timeout:        secondsToDuration(args.TimeoutSeconds),
+				MaxOutputBytes: args.MaxOutputBytes,
+				SpillOutput:    args.SpillOutput,
+			}
+			result, err := executor.ExecuteCommand(ctx, fmt.Sprintf("cd %s && %s", shellquote.Quote(guestDir), command), execCtx, progressOutputCallback(ctx, request))
+			if err != nil {
+				return commandFailedResult("Test run failed", result, err), nil
+			}
+
+			summary := testrun.Parse(framework, result.Stdout+"\n"+result.Stderr)
+			response := RunTestsResponse{
+				VMName:     args.VMName,
+				Framework:  framework,
+				Command:    command,
+				WorkingDir: guestDir,
+				Passed:     result.ExitCode == 0,
+				ExitCode:   result.ExitCode,
+				Summary:    summary,
+				Stdout:     result.Stdout,
+				Stderr:     result.Stderr,
+				DurationS:  result.Duration,
+				Synced:     syncBefore,
+				Coverage:   args.Coverage,
+				Truncation: result.Truncation,
+			}
+			if fetchCoverage {
+				sshArgs, err := executor.SSHArgs(ctx, args.VMName)
+				if err == nil {
+					var fetched []string
+					fetched, err = testrun.FetchArtifacts(ctx, args.VMName, sshArgs, guestDir, hostDir, []string{testrun.CoverageArtifact(framework)})
+					response.CoverageArtifacts = fetched
+				}
+				if err == nil && len(response.CoverageArtifacts) > 0 {
+					response.CoverageReport = storeCoverageReport(vmManager.GetBaseDir(), args.VMName, filepath.Join(hostDir, response.CoverageArtifacts[0]))
+				}
+				if err != nil {
+					response.CoverageError = err.Error()
+				}
+				response.CoverageDir = hostDir
+			}
+			jsonResponse, err := json.Marshal(response)
+			if err != nil {
+				return mcp.NewToolResultError("Failed to marshal response"), nil
+			}
+			return mcp.NewToolResultText(string(jsonResponse)), nil
+		}
+
+		// The sync mirrors deletions, so it needs approval like sync_to_vm
+		if syncBefore {
+			if details := syncDeletionApprovalDetails(ctx, syncEngine, vmManager, args.VMName, "to_vm"); details != nil {
+				return approvalRequiredResult(approval.GlobalGate, approval.OperationSyncDeletions, "run_tests", args.VMName, details, runTests)
+			}
+		}
+		return runTests(ctx)
+	})
+
 	log.Info().Msg("Execution tools registered")
 }
+
+// storeCoverageReport copies a coverage report to the artifacts of a VM and returns its URI.
+// Reports written as directories, such as an HTML report, are left out.
+func storeCoverageReport(baseDir, vmName, reportPath string) string {
+	file, err := os.Open(reportPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	artifact, err := artifacts.NewStore(baseDir).Put(vmName, artifacts.KindCoverage, filepath.Base(reportPath), file)
+	if err != nil {
+		log.Warn().Err(err).Str("vm", vmName).Msg("Failed to store coverage report")
+		return ""
+	}
+	return artifact.URI
+}
+
+// guestProjectRoot returns the directory the project is synced to in the VM
+func guestProjectRoot(config core.VMConfig) string {
+	if config.GuestPath != "" {
+		return config.GuestPath
+	}
+	return "/vagrant"
+}
+
+// secondsToDuration converts a timeout_seconds parameter to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// commandFailedResult returns the tool error for a failed command, keeping the output a
+// timed out or cancelled command produced before it was killed
+func commandFailedResult(message string, result *exec.CommandResult, err error) *mcp.CallToolResult {
+	if errors.Is(err, errors.CodePermissionDenied) {
+		return mcp.NewToolResultError(err.Error())
+	}
+	if result != nil && (errors.Is(err, errors.CodeTimeout) || errors.Is(err, errors.CodeCancelled)) {
+		text := fmt.Sprintf("%s: %v\nstdout:\n%s\nstderr:\n%s", message, err, result.Stdout, result.Stderr)
+		if t := result.Truncation; t != nil {
+			for _, uri := range []string{t.StdoutArtifact, t.StderrArtifact} {
+				if uri != "" {
+					text += "\nfull output: " + uri
+				}
+			}
+		}
+		return mcp.NewToolResultError(text)
+	}
+	return mcp.NewToolResultErrorf("%s: %v", message, err)
+}
+
+// scriptInterpreter describes how run_script_in_vm runs a script
+type scriptInterpreter struct {
+	command   string
+	extension string
+}
+
+// scriptInterpreters lists the interpreters run_script_in_vm supports
+var scriptInterpreters = map[string]scriptInterpreter{
+	"bash":   {command: "bash", extension: ".sh"},
+	"sh":     {command: "sh", extension: ".sh"},
+	"python": {command: "python3", extension: ".py"},
+}
+
+// scriptInterpreterNames returns the supported interpreter names in sorted order
+func scriptInterpreterNames() []string {
+	names := make([]string, 0, len(scriptInterpreters))
+	for name := range scriptInterpreters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildScriptCommand returns the command that runs an uploaded script and removes it afterwards
+func buildScriptCommand(interpreter, scriptPath, workingDir string, scriptArgs []string) string {
+	quotedArgs := make([]string, 0, len(scriptArgs))
+	for _, arg := range scriptArgs {
+		quotedArgs = append(quotedArgs, shellquote.Quote(arg))
+	}
+	run := fmt.Sprintf("%s %s", interpreter, shellquote.Quote(scriptPath))
+	if len(quotedArgs) > 0 {
+		run += " " + strings.Join(quotedArgs, " ")
+	}
+	return fmt.Sprintf("chmod 700 %[1]s && cd %[2]s && %[3]s; status=$?; rm -f %[1]s; exit $status",
+		shellquote.Quote(scriptPath), shellquote.Quote(workingDir), run)
+}
+
+// progressOutputCallback streams command output lines to the client as progress notifications,
+// or returns nil when the request did not ask for progress
+func progressOutputCallback(ctx context.Context, request mcp.CallToolRequest) exec.OutputCallback {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return nil
+	}
+
+	token := request.Params.Meta.ProgressToken
+	var mu sync.Mutex
+	lines := 0
+	return func(data []byte, isStderr bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines++
+		message := string(data)
+		if isStderr {
+			message = "stderr: " + message
+		}
+		if err := mcpServer.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      lines,
+			"message":       message,
+		}); err != nil {
+			log.Debug().Err(err).Msg("Failed to send output progress notification")
+		}
+	}
+}
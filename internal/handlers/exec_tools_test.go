@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+)
+
+func TestBuildScriptCommand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		interpreter string
+		workingDir  string
+		args        []string
+		expected    string
+	}{
+		{
+			name:        "no arguments",
+			interpreter: "bash",
+			workingDir:  "/home/vagrant",
+			expected:    "chmod 700 '/tmp/s.sh' && cd '/home/vagrant' && bash '/tmp/s.sh'; status=$?; rm -f '/tmp/s.sh'; exit $status",
+		},
+		{
+			name:        "arguments are quoted",
+			interpreter: "python3",
+			workingDir:  "/srv/my app",
+			args:        []string{"--name", "it's $HOME"},
+			expected:    `chmod 700 '/tmp/s.sh' && cd '/srv/my app' && python3 '/tmp/s.sh' '--name' 'it'\''s $HOME'; status=$?; rm -f '/tmp/s.sh'; exit $status`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := buildScriptCommand(tc.interpreter, "/tmp/s.sh", tc.workingDir, tc.args); actual != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// FindFilesResponse is the result of find_files
+type FindFilesResponse struct {
+	VMName    string         `json:"vm_name"`
+	Files     []vm.FoundFile `json:"files"`
+	Count     int            `json:"count"`
+	Truncated bool           `json:"truncated"`
+}
+
+// SearchVMResponse is the result of search_vm
+type SearchVMResponse struct {
+	VMName    string            `json:"vm_name"`
+	Pattern   string            `json:"pattern"`
+	Paths     []string          `json:"paths"`
+	Matches   []vm.ContentMatch `json:"matches"`
+	Count     int               `json:"count"`
+	Truncated bool              `json:"truncated"`
+}
+
+// WriteFileResponse is the result of write_vm_file and patch_vm_file
+type WriteFileResponse struct {
+	VMName string            `json:"vm_name"`
+	File   vm.GuestFileWrite `json:"file"`
+}
+
+// RegisterFileTools registers the tools that find and write files anywhere in a VM, outside the
+// synced project directory
+func RegisterFileTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	// Write VM file tool
+	type WriteVMFileArgs struct {
+		VMName     string `json:"vm_name"`
+		Path       string `json:"path"`
+		Content    string `json:"content"`
+		Mode       string `json:"mode"`
+		Owner      string `json:"owner"`
+		Sudo       bool   `json:"sudo"`
+		Backup     *bool  `json:"backup"`
+		CreateDirs bool   `json:"create_dirs"`
+		DryRun     bool   `json:"dry_run"`
+	}
+	writeFileTool := mcp.NewTool("write_vm_file",
+		mcp.WithDescription("Create or replace a file at any path in a VM, such as /etc/nginx/sites-available/app, returning a diff against the previous content. Use the sync tools for files in the project directory"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Absolute guest path of the file")),
+		mcp.WithString("content",
+			mcp.Required(),
+			mcp.Description("New content of the file (up to 64KB)")),
+		mcp.WithString("mode",
+			mcp.Description("Octal file mode, e.g. 0644; new files default to 0644 and existing files keep their mode")),
+		mcp.WithString("owner",
+			mcp.Description("Owner as user or user:group, e.g. www-data:www-data; requires sudo for other users")),
+		mcp.WithBoolean("sudo",
+			mcp.Description("Write the file as root, for system files the vagrant user cannot write"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("backup",
+			mcp.Description("Copy an existing file to <path>.bak.<timestamp> before replacing it"),
+			mcp.DefaultBool(true)),
+		mcp.WithBoolean("create_dirs",
+			mcp.Description("Create missing parent directories"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only return the diff without writing the file"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, writeFileTool, func(ctx context.Context, request mcp.CallToolRequest, args WriteVMFileArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Path == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or path"), nil
+		}
+		file := vm.GuestFile{
+			Path:       args.Path,
+			Content:    args.Content,
+			Mode:       args.Mode,
+			Owner:      args.Owner,
+			Sudo:       args.Sudo,
+			Backup:     args.Backup == nil || *args.Backup,
+			CreateDirs: args.CreateDirs,
+			DryRun:     args.DryRun,
+		}
+		if err := vm.ValidateGuestFile(file); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+		return writeGuestFile(ctx, executor, args.VMName, file)
+	})
+
+	// Patch VM file tool
+	type PatchVMFileArgs struct {
+		VMName string        `json:"vm_name"`
+		Path   string        `json:"path"`
+		Edits  []vm.FileEdit `json:"edits"`
+		Sudo   bool          `json:"sudo"`
+		Backup *bool         `json:"backup"`
+		DryRun bool          `json:"dry_run"`
+	}
+	patchFileTool := mcp.NewTool("patch_vm_file",
+		mcp.WithDescription("Edit an existing file at any path in a VM by replacing text, returning the diff. Each edit's old text must occur exactly once unless replace_all is set"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Absolute guest path of the file")),
+		mcp.WithArray("edits",
+			mcp.Required(),
+			mcp.Description("Replacements applied in order, as {\"old\": \"listen 80;\", \"new\": \"listen 8080;\", \"replace_all\": false} objects"),
+			mcp.Items(map[string]any{"type": "object"})),
+		mcp.WithBoolean("sudo",
+			mcp.Description("Read and write the file as root, for system files"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("backup",
+			mcp.Description("Copy the file to <path>.bak.<timestamp> before changing it"),
+			mcp.DefaultBool(true)),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only return the diff without changing the file"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, patchFileTool, func(ctx context.Context, request mcp.CallToolRequest, args PatchVMFileArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Path == "" || len(args.Edits) == 0 {
+			return mcp.NewToolResultError("Missing required parameter: vm_name, path or edits"), nil
+		}
+		readCommand, err := vm.ReadFileCommand(args.Path, args.Sudo)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, readCommand, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to read file", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return guestFileFailedResult("Failed to read file", result, args.Sudo), nil
+		}
+		current, err := base64.StdEncoding.DecodeString(strings.TrimSpace(result.Stdout))
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to decode file content: %v", err), nil
+		}
+		content, err := vm.ApplyEdits(string(current), args.Edits)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		file := vm.GuestFile{
+			Path:    args.Path,
+			Content: content,
+			Sudo:    args.Sudo,
+			Backup:  args.Backup == nil || *args.Backup,
+			DryRun:  args.DryRun,
+		}
+		if err := vm.ValidateGuestFile(file); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return writeGuestFile(ctx, executor, args.VMName, file)
+	})
+
+	// Find files tool
+	type FindFilesArgs struct {
+		VMName          string   `json:"vm_name"`
+		Path            string   `json:"path"`
+		Name            string   `json:"name"`
+		CaseInsensitive bool     `json:"case_insensitive"`
+		Type            string   `json:"type"`
+		MinSizeKB       float64  `json:"min_size_kb"`
+		MaxSizeKB       float64  `json:"max_size_kb"`
+		ModifiedWithin  string   `json:"modified_within"`
+		MaxDepth        float64  `json:"max_depth"`
+		Exclude         []string `json:"exclude"`
+		MaxResults      float64  `json:"max_results"`
+		Sudo            bool     `json:"sudo"`
+	}
+	findFilesTool := mcp.NewTool("find_files",
+		mcp.WithDescription("Find files in a VM by name, type, size and modification time, such as build artifacts, logs or configuration files, returning their paths with size, mode and modification time"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("path",
+			mcp.Description("Guest directory to search; relative paths start from /vagrant"),
+			mcp.DefaultString("/vagrant")),
+		mcp.WithString("name",
+			mcp.Description("Glob matched against file names, e.g. *.log")),
+		mcp.WithBoolean("case_insensitive",
+			mcp.Description("Match name case-insensitively"),
+			mcp.DefaultBool(false)),
+		mcp.WithString("type",
+			mcp.Description("Only files of this type"),
+			mcp.Enum("file", "directory", "symlink")),
+		mcp.WithNumber("min_size_kb",
+			mcp.Description("Only files of at least this size in KB")),
+		mcp.WithNumber("max_size_kb",
+			mcp.Description("Only files of at most this size in KB")),
+		mcp.WithString("modified_within",
+			mcp.Description("Only files modified within this duration, e.g. 30m or 24h")),
+		mcp.WithNumber("max_depth",
+			mcp.Description("Directory levels to descend below path")),
+		mcp.WithArray("exclude",
+			mcp.Description("Directory names not to descend into, e.g. node_modules or .git"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("max_results",
+			mcp.Description("Maximum number of files to return (up to 2000)"),
+			mcp.DefaultNumber(vm.DefaultFindResults)),
+		mcp.WithBoolean("sudo",
+			mcp.Description("Search as root, for directories the vagrant user cannot read"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, findFilesTool, func(ctx context.Context, request mcp.CallToolRequest, args FindFilesArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		query := vm.FileQuery{
+			Root:            args.Path,
+			Name:            args.Name,
+			CaseInsensitive: args.CaseInsensitive,
+			Type:            args.Type,
+			MinSizeKB:       int(args.MinSizeKB),
+			MaxSizeKB:       int(args.MaxSizeKB),
+			MaxDepth:        int(args.MaxDepth),
+			Exclude:         args.Exclude,
+			MaxResults:      int(args.MaxResults),
+			Sudo:            args.Sudo,
+		}
+		if args.ModifiedWithin != "" {
+			within, err := time.ParseDuration(args.ModifiedWithin)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Invalid modified_within '%s': use a duration such as 30m or 24h", args.ModifiedWithin), nil
+			}
+			query.ModifiedIn = within
+		}
+		command, err := vm.FindFilesCommand(query)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to find files", result, err), nil
+		}
+		files, truncated := vm.ParseFindOutput(result.Stdout, query.MaxResults)
+		response := FindFilesResponse{
+			VMName:    args.VMName,
+			Files:     files,
+			Count:     len(files),
+			Truncated: truncated,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Search VM tool
+	type SearchVMArgs struct {
+		VMName          string   `json:"vm_name"`
+		Pattern         string   `json:"pattern"`
+		Paths           []string `json:"paths"`
+		Regex           bool     `json:"regex"`
+		CaseInsensitive bool     `json:"case_insensitive"`
+		Include         []string `json:"include"`
+		Exclude         []string `json:"exclude"`
+		MaxResults      float64  `json:"max_results"`
+		Sudo            bool     `json:"sudo"`
+	}
+	searchVMTool := mcp.NewTool("search_vm",
+		mcp.WithDescription("Search the content of files anywhere in a VM, such as /var/log, /etc or a virtualenv, with grep over ssh, returning the matching lines with their paths and line numbers. Binary files are skipped. Use search_code for the synced project"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("pattern",
+			mcp.Required(),
+			mcp.Description("Text to search for, or an extended regular expression when regex is set")),
+		mcp.WithArray("paths",
+			mcp.Required(),
+			mcp.Description("Guest files and directories to search, e.g. ['/var/log', '/etc/nginx']; relative paths start from /vagrant"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithBoolean("regex",
+			mcp.Description("Match pattern as an extended regular expression"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("case_insensitive",
+			mcp.Description("Match pattern case-insensitively"),
+			mcp.DefaultBool(false)),
+		mcp.WithArray("include",
+			mcp.Description("Only search files whose names match these globs, e.g. *.conf"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithArray("exclude",
+			mcp.Description("Directory names not to descend into, e.g. __pycache__ or .git"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("max_results",
+			mcp.Description("Maximum number of matching lines to return (up to 1000)"),
+			mcp.DefaultNumber(vm.DefaultGrepResults)),
+		mcp.WithBoolean("sudo",
+			mcp.Description("Search as root, for files the vagrant user cannot read such as /var/log/syslog"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, searchVMTool, func(ctx context.Context, request mcp.CallToolRequest, args SearchVMArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Pattern == "" || len(args.Paths) == 0 {
+			return mcp.NewToolResultError("Missing required parameter: vm_name, pattern or paths"), nil
+		}
+		query := vm.ContentQuery{
+			Paths:           args.Paths,
+			Pattern:         args.Pattern,
+			Regex:           args.Regex,
+			CaseInsensitive: args.CaseInsensitive,
+			Include:         args.Include,
+			Exclude:         args.Exclude,
+			MaxResults:      int(args.MaxResults),
+			Sudo:            args.Sudo,
+		}
+		command, err := vm.GrepCommand(query)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to search files", result, err), nil
+		}
+		// The exit code is that of head unless sudo failed
+		if result.ExitCode != 0 && result.Stdout == "" {
+			return mcp.NewToolResultErrorf("Failed to search files: %s", strings.TrimSpace(result.Stderr)), nil
+		}
+		matches, truncated := vm.ParseGrepOutput(result.Stdout, query.MaxResults)
+		response := SearchVMResponse{
+			VMName:    args.VMName,
+			Pattern:   args.Pattern,
+			Paths:     args.Paths,
+			Matches:   matches,
+			Count:     len(matches),
+			Truncated: truncated,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("File tools registered")
+}
+
+// writeGuestFile writes a file in a running VM and returns its diff as the tool result
+func writeGuestFile(ctx context.Context, executor *exec.Executor, vmName string, file vm.GuestFile) (*mcp.CallToolResult, error) {
+	command, err := vm.WriteFileCommand(file, time.Now())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: vmName}, nil)
+	if err != nil {
+		return commandFailedResult("Failed to write file", result, err), nil
+	}
+	if result.ExitCode != 0 {
+		return guestFileFailedResult("Failed to write file", result, file.Sudo), nil
+	}
+	written := vm.ParseWriteFileOutput(file, result.Stdout)
+	if written.Written {
+		log.Info().Str("vm", vmName).Str("path", written.Path).Bool("changed", written.Changed).Msg("Wrote guest file")
+	}
+	response := WriteFileResponse{
+		VMName: vmName,
+		File:   written,
+	}
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		return mcp.NewToolResultError("Failed to marshal response"), nil
+	}
+	return mcp.NewToolResultText(string(jsonResponse)), nil
+}
+
+// guestFileFailedResult returns the tool error for a guest file command that failed, pointing
+// to sudo when permission was denied
+func guestFileFailedResult(message string, result *exec.CommandResult, sudo bool) *mcp.CallToolResult {
+	output := strings.TrimSpace(result.Stderr + result.Stdout)
+	if !sudo && strings.Contains(output, "Permission denied") {
+		return mcp.NewToolResultErrorf("%s: %s (set sudo to write system files)", message, output)
+	}
+	return mcp.NewToolResultErrorf("%s: %s", message, output)
+}
@@ -0,0 +1,284 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/secrets"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// Defaults of the credential helper set up by setup_git_access
+const (
+	defaultCredentialHost     = "github.com"
+	defaultCredentialUsername = "x-access-token"
+)
+
+var (
+	// credentialHostPattern matches the host, and optional port, a credential helper serves
+	credentialHostPattern = regexp.MustCompile(`^[A-Za-z0-9.-]+(:[0-9]+)?$`)
+	// credentialUsernamePattern matches the usernames a credential helper answers with
+	credentialUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+)
+
+// SetupGitAccessResponse is the result of setup_git_access
+type SetupGitAccessResponse struct {
+	VMName    string `json:"vm_name"`
+	UserName  string `json:"user_name,omitempty"`
+	UserEmail string `json:"user_email,omitempty"`
+	// ForwardAgent reports whether the host's SSH agent is forwarded to the VM
+	ForwardAgent bool `json:"forward_agent"`
+	// AgentReachable reports whether ssh sessions in the VM reach a forwarded agent
+	AgentReachable bool `json:"agent_reachable"`
+	// CredentialHost and TokenSecret describe the credential helper, when one is set up
+	CredentialHost string   `json:"credential_host,omitempty"`
+	TokenSecret    string   `json:"token_secret,omitempty"`
+	Notes          []string `json:"notes,omitempty"`
+}
+
+// gitAccess is what setup_git_access configures in the guest
+type gitAccess struct {
+	UserName  string
+	UserEmail string
+	// CredentialHost is the host whose credential helper is set up or removed
+	CredentialHost string
+	// TokenSecret names the environment variable secret holding the access token; empty with
+	// RemoveHelper unset leaves the credential helper alone
+	TokenSecret        string
+	CredentialUsername string
+	RemoveHelper       bool
+}
+
+// RegisterGitTools registers the git access tools with the MCP server
+func RegisterGitTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor, store *secrets.Store) {
+	// Setup git access tool
+	type SetupGitAccessArgs struct {
+		VMName                 string `json:"vm_name"`
+		CopyIdentity           *bool  `json:"copy_identity"`
+		UserName               string `json:"user_name"`
+		UserEmail              string `json:"user_email"`
+		ForwardAgent           *bool  `json:"forward_agent"`
+		TokenSecret            string `json:"token_secret"`
+		CredentialHost         string `json:"credential_host"`
+		CredentialUsername     string `json:"credential_username"`
+		RemoveCredentialHelper bool   `json:"remove_credential_helper"`
+	}
+	setupGitAccessTool := mcp.NewTool("setup_git_access",
+		mcp.WithDescription("Let git in a VM commit as the host user and push to and pull from private repositories: copies the host's git user.name and user.email, forwards the host's SSH agent to the VM, and sets up a credential helper that answers HTTPS logins with an access token stored with set_vm_secret. Keys and tokens never reach the VM's disk"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithBoolean("copy_identity",
+			mcp.Description("Set the guest's git user.name and user.email (default: true)")),
+		mcp.WithString("user_name",
+			mcp.Description("git user.name for the guest (default: the host's global user.name)")),
+		mcp.WithString("user_email",
+			mcp.Description("git user.email for the guest (default: the host's global user.email)")),
+		mcp.WithBoolean("forward_agent",
+			mcp.Description("Forward the host's SSH agent to ssh sessions in the VM, so git can use the host's SSH keys (default: unchanged)")),
+		mcp.WithString("token_secret",
+			mcp.Description("Name of an environment variable secret set with set_vm_secret that holds an access token; git uses it for HTTPS logins to credential_host")),
+		mcp.WithString("credential_host",
+			mcp.Description("Host the credential helper serves, e.g. gitlab.com (default: github.com)")),
+		mcp.WithString("credential_username",
+			mcp.Description("Username sent with the token (default: x-access-token)")),
+		mcp.WithBoolean("remove_credential_helper",
+			mcp.Description("Remove the credential helper of credential_host instead of setting one up")),
+	)
+
+	mcp_pkg.RegisterTypedTool(srv, setupGitAccessTool, func(ctx context.Context, request mcp.CallToolRequest, args SetupGitAccessArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		if args.TokenSecret != "" && args.RemoveCredentialHelper {
+			return mcp.NewToolResultError("'token_secret' and 'remove_credential_helper' cannot be combined"), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+
+		access := gitAccess{
+			CredentialHost:     args.CredentialHost,
+			TokenSecret:        args.TokenSecret,
+			CredentialUsername: args.CredentialUsername,
+			RemoveHelper:       args.RemoveCredentialHelper,
+		}
+		if access.CredentialHost == "" {
+			access.CredentialHost = defaultCredentialHost
+		}
+		if access.CredentialUsername == "" {
+			access.CredentialUsername = defaultCredentialUsername
+		}
+		response := SetupGitAccessResponse{VMName: args.VMName}
+		if args.CopyIdentity == nil || *args.CopyIdentity {
+			access.UserName, access.UserEmail = args.UserName, args.UserEmail
+			if access.UserName == "" {
+				access.UserName = hostGitConfig(ctx, "user.name")
+			}
+			if access.UserEmail == "" {
+				access.UserEmail = hostGitConfig(ctx, "user.email")
+			}
+			if access.UserName == "" || access.UserEmail == "" {
+				response.Notes = append(response.Notes, "The host has no global git user.name or user.email; pass user_name and user_email to set them")
+			}
+		}
+		if access.TokenSecret != "" && !hasEnvSecret(store, args.VMName, access.TokenSecret) {
+			return mcp.NewToolResultErrorf("VM '%s' has no environment variable secret '%s'; set it with set_vm_secret first", args.VMName, access.TokenSecret), nil
+		}
+		if err := access.validate(); err != nil {
+			return mcp.NewToolResultErrorf("Invalid git access settings: %v", err), nil
+		}
+
+		if args.ForwardAgent != nil {
+			forwarder, ok := vmManager.(interface {
+				SetSSHAgentForwarding(ctx context.Context, name string, enabled bool) (core.VMConfig, error)
+			})
+			if !ok {
+				return mcp.NewToolResultError("VM manager does not support SSH agent forwarding"), nil
+			}
+			if _, err := forwarder.SetSSHAgentForwarding(ctx, args.VMName, *args.ForwardAgent); err != nil {
+				return mcp.NewToolResultErrorf("Failed to configure SSH agent forwarding: %v", err), nil
+			}
+			if *args.ForwardAgent && os.Getenv("SSH_AUTH_SOCK") == "" {
+				response.Notes = append(response.Notes, "SSH_AUTH_SOCK is not set for the server, so there is no agent to forward; start ssh-agent and add your keys")
+			}
+		}
+		if config, err := vmManager.GetVMConfig(ctx, args.VMName); err == nil {
+			response.ForwardAgent = config.ForwardSSHAgent
+		}
+
+		output, err := configureGitAccess(ctx, executor, args.VMName, access)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to configure git access: %v", err), nil
+		}
+		response.UserName, response.UserEmail = access.UserName, access.UserEmail
+		response.AgentReachable = strings.Contains(output, "agent=reachable")
+		if access.TokenSecret != "" {
+			response.CredentialHost = access.CredentialHost
+			response.TokenSecret = access.TokenSecret
+		}
+		if response.ForwardAgent && !response.AgentReachable {
+			response.Notes = append(response.Notes, "The VM cannot reach a forwarded SSH agent; check that ssh-agent runs on the host and holds your keys")
+		}
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	log.Info().Msg("Git tools registered")
+}
+
+// hostGitConfig returns a value of the host's global git configuration, or "" when git or the
+// value is missing
+func hostGitConfig(ctx context.Context, key string) string {
+	output, err := osexec.CommandContext(ctx, "git", "config", "--global", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// hasEnvSecret reports whether a VM has an environment variable secret
+func hasEnvSecret(store *secrets.Store, vmName, name string) bool {
+	for _, secret := range store.Get(vmName) {
+		if secret.Kind == secrets.KindEnv && secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the git access settings before they reach the guest
+func (a gitAccess) validate() error {
+	if strings.ContainsAny(a.UserName+a.UserEmail, "\n\r") {
+		return errors.InvalidInput("user_name and user_email must be a single line")
+	}
+	if !credentialHostPattern.MatchString(a.CredentialHost) {
+		return errors.InvalidInput(fmt.Sprintf("invalid credential host '%s'", a.CredentialHost))
+	}
+	if !credentialUsernamePattern.MatchString(a.CredentialUsername) {
+		return errors.InvalidInput(fmt.Sprintf("invalid credential username '%s'", a.CredentialUsername))
+	}
+	if a.TokenSecret != "" {
+		if err := (secrets.Secret{Name: a.TokenSecret, Kind: secrets.KindEnv}).Validate(); err != nil {
+			return errors.InvalidInput(err.Error())
+		}
+	}
+	return nil
+}
+
+// credentialHelper returns the git credential helper that answers logins with the token in an
+// environment variable secret. It reads the secret from the guest's tmpfs when asked, so git
+// run outside the server's commands, e.g. in an interactive shell, can use it too.
+func credentialHelper(username, secretName string) string {
+	return fmt.Sprintf(`!f() { test "$1" = get || exit 0; if [ -r %[1]s ]; then . %[1]s; fi; echo username=%[2]s; echo "password=$%[3]s"; }; f`,
+		secrets.GuestEnvFile, username, secretName)
+}
+
+// gitAccessScript returns the script, run as the vagrant user, that applies git access
+// settings and reports whether a forwarded SSH agent is reachable
+func gitAccessScript(access gitAccess) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	b.WriteString("command -v git >/dev/null || sudo DEBIAN_FRONTEND=noninteractive apt-get install -y git >/dev/null\n")
+	if access.UserName != "" {
+		fmt.Fprintf(&b, "git config --global user.name %s\n", shellquote.Quote(access.UserName))
+	}
+	if access.UserEmail != "" {
+		fmt.Fprintf(&b, "git config --global user.email %s\n", shellquote.Quote(access.UserEmail))
+	}
+	url := "https://" + access.CredentialHost
+	if access.TokenSecret != "" || access.RemoveHelper {
+		fmt.Fprintf(&b, "git config --global --unset-all %s || true\n", shellquote.Quote("credential."+url+".helper"))
+		fmt.Fprintf(&b, "git config --global --unset-all %s || true\n", shellquote.Quote("credential."+url+".username"))
+	}
+	if access.TokenSecret != "" {
+		// The empty helper drops helpers configured for every host, such as a credential store
+		fmt.Fprintf(&b, "git config --global --add %s ''\n", shellquote.Quote("credential."+url+".helper"))
+		fmt.Fprintf(&b, "git config --global --add %s %s\n", shellquote.Quote("credential."+url+".helper"), shellquote.Quote(credentialHelper(access.CredentialUsername, access.TokenSecret)))
+		fmt.Fprintf(&b, "git config --global %s %s\n", shellquote.Quote("credential."+url+".username"), shellquote.Quote(access.CredentialUsername))
+	}
+	// ssh-add exits with 0, or 1 for an agent without keys, when it reaches an agent
+	b.WriteString("set +e\nssh-add -l >/dev/null 2>&1\ncase $? in 0|1) echo agent=reachable ;; *) echo agent=unreachable ;; esac\n")
+	return b.String()
+}
+
+// configureGitAccess applies git access settings in a running VM and returns the script's output
+func configureGitAccess(ctx context.Context, executor *exec.Executor, vmName string, access gitAccess) (string, error) {
+	execCtx := exec.ExecutionContext{
+		VMName:     vmName,
+		WorkingDir: "/home/vagrant",
+		SyncBefore: false,
+		SyncAfter:  false,
+	}
+	result, err := executor.ExecuteCommand(ctx, gitAccessScript(access), execCtx, nil)
+	if err != nil {
+		return "", errors.OperationFailed("configure git", err)
+	}
+	if result.ExitCode != 0 {
+		return "", errors.OperationFailed("configure git", fmt.Errorf("%s", strings.TrimSpace(result.Stderr)))
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"os"
+	osexec "os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGitAccessValidate(t *testing.T) {
+	valid := gitAccess{UserName: "Dev", UserEmail: "dev@example.com", CredentialHost: "github.com", CredentialUsername: "x-access-token", TokenSecret: "GH_TOKEN"}
+	if err := valid.validate(); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(*gitAccess)
+	}{
+		{"multi-line user name", func(a *gitAccess) { a.UserName = "Dev\n[core]" }},
+		{"host with path", func(a *gitAccess) { a.CredentialHost = "github.com/org" }},
+		{"host with shell characters", func(a *gitAccess) { a.CredentialHost = "github.com;id" }},
+		{"username with spaces", func(a *gitAccess) { a.CredentialUsername = "x access" }},
+		{"invalid secret name", func(a *gitAccess) { a.TokenSecret = "GH-TOKEN" }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			access := valid
+			tc.modify(&access)
+			if err := access.validate(); err == nil {
+				t.Error("Expected an error but got none")
+			}
+		})
+	}
+}
+
+func TestGitAccessScript(t *testing.T) {
+	bash, err := osexec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	if _, err := osexec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	home := t.TempDir()
+	env := append(os.Environ(), "HOME="+home, "XDG_CONFIG_HOME="+home, "GIT_CONFIG_NOSYSTEM=1", "GIT_TERMINAL_PROMPT=0", "SSH_AUTH_SOCK=", "GH_TOKEN=s3cret")
+	run := func(access gitAccess) string {
+		cmd := osexec.Command(bash, "-c", gitAccessScript(access))
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Expected the script to succeed but got %v: %s", err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	fill := func() string {
+		cmd := osexec.Command("git", "credential", "fill")
+		cmd.Env = env
+		cmd.Stdin = strings.NewReader("protocol=https\nhost=github.com\n\n")
+		output, _ := cmd.CombinedOutput()
+		return string(output)
+	}
+
+	access := gitAccess{UserName: "Dev O'Brien", UserEmail: "dev@example.com", CredentialHost: "github.com", CredentialUsername: "x-access-token", TokenSecret: "GH_TOKEN"}
+	if output := run(access); output != "agent=unreachable" {
+		t.Errorf("Expected no reachable agent but got %s", output)
+	}
+	run(access)
+	cmd := osexec.Command("git", "config", "--global", "--get", "user.name")
+	cmd.Env = env
+	if output, err := cmd.Output(); err != nil || strings.TrimSpace(string(output)) != "Dev O'Brien" {
+		t.Errorf("Expected user.name Dev O'Brien but got %q (%v)", output, err)
+	}
+	if output := fill(); !strings.Contains(output, "username=x-access-token\n") || !strings.Contains(output, "password=s3cret\n") {
+		t.Errorf("Expected the credential helper to answer with the token but got %q", output)
+	}
+
+	run(gitAccess{CredentialHost: "github.com", CredentialUsername: "x-access-token", RemoveHelper: true})
+	if output := fill(); strings.Contains(output, "s3cret") {
+		t.Errorf("Expected the credential helper to be removed but got %q", output)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/project"
+)
+
+// Limits of the parallel installer
+const (
+	defaultInstallParallelism = 3
+	maxInstallParallelism     = 8
+	// installLogBytes is how much of the end of each item's output is returned
+	installLogBytes = 16 * 1024
+)
+
+// guestAPTLockFile makes apt wait for the dpkg lock instead of failing, so installs running at
+// the same time take turns at it
+const guestAPTLockFile = "/etc/apt/apt.conf.d/97vagrant-mcp-lock-timeout"
+
+// installMarker starts the lines the install script prints about its items
+const installMarker = "@@install"
+
+// aptUpdatePrefix is how install commands refresh the package lists, which the installer does
+// once for all of them
+const aptUpdatePrefix = "sudo apt-get update && "
+
+// installDependencies lists what must be installed before a runtime or tool when both are
+// installed together
+var installDependencies = map[string][]string{
+	"docker-compose": {"docker"},
+}
+
+// installItem is a runtime or tool installed by the parallel installer
+type installItem struct {
+	// Kind is runtime or tool
+	Kind string
+	// Name is the runtime or tool as requested, e.g. node@20.11.1
+	Name    string
+	Command string
+	// After holds the indexes of the items installed before this one
+	After []int
+}
+
+// newInstallPlan returns the items that install runtimes and then tools, with the order
+// installDependencies requires between them, and the runtimes that cannot be installed
+func newInstallPlan(runtimes, tools []string) ([]installItem, map[string]error) {
+	var items []installItem
+	invalid := make(map[string]error)
+	for _, runtime := range runtimes {
+		command, err := runtimeInstallCommand(runtime)
+		if err != nil {
+			invalid[runtime] = err
+			continue
+		}
+		items = append(items, installItem{Kind: "runtime", Name: runtime, Command: command})
+	}
+	for _, tool := range tools {
+		items = append(items, installItem{Kind: "tool", Name: tool, Command: toolInstallCommand(tool)})
+	}
+	index := make(map[string]int)
+	for i, item := range items {
+		name, _ := project.SplitRuntime(item.Name)
+		index[name] = i
+	}
+	for i := range items {
+		name, _ := project.SplitRuntime(items[i].Name)
+		for _, dependency := range installDependencies[name] {
+			if j, ok := index[dependency]; ok && j != i {
+				items[i].After = append(items[i].After, j)
+			}
+		}
+	}
+	return items, invalid
+}
+
+// installLevels groups the items so each one only depends on items of earlier levels
+func installLevels(items []installItem) ([][]int, error) {
+	level := make([]int, len(items))
+	var visit func(i int, path map[int]bool) (int, error)
+	visit = func(i int, path map[int]bool) (int, error) {
+		if level[i] > 0 {
+			return level[i], nil
+		}
+		if path[i] {
+			return 0, errors.InvalidInput(fmt.Sprintf("%s depends on itself", items[i].Name))
+		}
+		path[i] = true
+		defer delete(path, i)
+		deepest := 0
+		for _, j := range items[i].After {
+			l, err := visit(j, path)
+			if err != nil {
+				return 0, err
+			}
+			deepest = max(deepest, l)
+		}
+		level[i] = deepest + 1
+		return level[i], nil
+	}
+	var levels [][]int
+	for i := range items {
+		l, err := visit(i, map[int]bool{})
+		if err != nil {
+			return nil, err
+		}
+		for len(levels) < l {
+			levels = append(levels, nil)
+		}
+		levels[l-1] = append(levels[l-1], i)
+	}
+	return levels, nil
+}
+
+// parallelInstallScript returns the bash script that refreshes the package lists once, then
+// installs the items level by level, at most parallelism at a time. Items whose dependencies
+// failed are skipped. It prints installMarker lines as items start and finish, and ends with
+// the status and the end of the output of every item.
+func parallelInstallScript(items []installItem, levels [][]int, parallelism int) string {
+	var b strings.Builder
+	b.WriteString(`dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+export DEBIAN_FRONTEND=noninteractive
+`)
+	fmt.Fprintf(&b, "echo 'DPkg::Lock::Timeout \"600\";' | sudo tee %s >/dev/null\n", guestAPTLockFile)
+	fmt.Fprintf(&b, `echo "%[1]s update start"
+sudo apt-get update >"$dir/update.log" 2>&1
+echo "%[1]s update done $?"
+run() {
+  echo "%[1]s $1 start"
+  bash "$dir/$1.sh" >"$dir/$1.log" 2>&1
+  status=$?
+  echo "$status" >"$dir/$1.status"
+  echo "%[1]s $1 done $status"
+}
+ok() { [ "$(cat "$dir/$1.status" 2>/dev/null)" = 0 ]; }
+`, installMarker)
+	for i, item := range items {
+		command := strings.TrimPrefix(item.Command, aptUpdatePrefix)
+		fmt.Fprintf(&b, "echo %s | base64 -d >\"$dir/%d.sh\"\n", base64.StdEncoding.EncodeToString([]byte(command)), i)
+	}
+	for _, level := range levels {
+		for _, i := range level {
+			b.WriteString("if true")
+			for _, j := range items[i].After {
+				fmt.Fprintf(&b, " && ok %d", j)
+			}
+			fmt.Fprintf(&b, `; then
+  while [ "$(jobs -rp | wc -l)" -ge %d ]; do wait -n; done
+  run %d &
+else
+  echo skipped >"$dir/%d.status"
+  echo "%s %d skipped"
+fi
+`, parallelism, i, i, installMarker, i)
+		}
+		b.WriteString("wait\n")
+	}
+	for i := range items {
+		fmt.Fprintf(&b, "echo \"%s %d result $(cat \"$dir/%d.status\")\"\ntail -c %d \"$dir/%d.log\" 2>/dev/null\necho\n", installMarker, i, i, installLogBytes, i)
+	}
+	return b.String()
+}
+
+// parseInstallResults returns the result of each item from the end of the install script's
+// output
+func parseInstallResults(items []installItem, output string) []InstallResult {
+	results := make([]InstallResult, len(items))
+	for i := range results {
+		results[i] = InstallResult{Error: "the installer did not report a result"}
+	}
+	current := -1
+	outputs := make([]strings.Builder, len(items))
+	for _, line := range strings.Split(output, "\n") {
+		if rest, ok := strings.CutPrefix(line, installMarker+" "); ok {
+			fields := strings.Fields(rest)
+			if len(fields) == 3 && fields[1] == "result" {
+				i, err := strconv.Atoi(fields[0])
+				if err != nil || i < 0 || i >= len(items) {
+					continue
+				}
+				current = i
+				switch status := fields[2]; status {
+				case "0":
+					results[i] = InstallResult{Success: true}
+				case "skipped":
+					results[i] = InstallResult{Error: "skipped because a dependency failed to install"}
+				default:
+					results[i] = InstallResult{Error: "install failed with exit code " + status}
+				}
+				continue
+			}
+		}
+		if current >= 0 {
+			outputs[current].WriteString(line + "\n")
+		}
+	}
+	for i := range results {
+		results[i].Output = strings.TrimSpace(outputs[i].String())
+	}
+	return results
+}
+
+// installProgressCallback sends a progress notification as each item of an install starts
+// and finishes, or returns nil when the request did not ask for progress
+func installProgressCallback(ctx context.Context, request mcp.CallToolRequest, items []installItem) exec.OutputCallback {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return nil
+	}
+
+	token := request.Params.Meta.ProgressToken
+	var mu sync.Mutex
+	finished := 0
+	return func(data []byte, isStderr bool) {
+		rest, ok := strings.CutPrefix(string(data), installMarker+" ")
+		if isStderr || !ok {
+			return
+		}
+		message, done := describeInstallEvent(items, strings.Fields(rest))
+		if message == "" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			finished++
+		}
+		if err := mcpServer.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      finished,
+			"total":         len(items),
+			"message":       message,
+		}); err != nil {
+			log.Debug().Err(err).Msg("Failed to send install progress notification")
+		}
+	}
+}
+
+// describeInstallEvent describes the fields of an installMarker line, and reports whether an
+// item finished with it. Lines that are not progress events are described by "".
+func describeInstallEvent(items []installItem, fields []string) (string, bool) {
+	if len(fields) < 2 {
+		return "", false
+	}
+	name := "package lists"
+	if fields[0] != "update" {
+		i, err := strconv.Atoi(fields[0])
+		if err != nil || i < 0 || i >= len(items) {
+			return "", false
+		}
+		name = items[i].Kind + " " + items[i].Name
+	}
+	isItem := fields[0] != "update"
+	switch {
+	case fields[1] == "start":
+		return name + ": started", false
+	case fields[1] == "skipped":
+		return name + ": skipped because a dependency failed", isItem
+	case fields[1] == "done" && len(fields) == 3 && fields[2] == "0":
+		return name + ": done", isItem
+	case fields[1] == "done" && len(fields) == 3:
+		return fmt.Sprintf("%s: failed with exit code %s", name, fields[2]), isItem
+	}
+	return "", false
+}
+
+// installInParallel installs runtimes and tools in a running VM with the parallel installer,
+// and returns the result of each keyed by name
+func installInParallel(ctx context.Context, request mcp.CallToolRequest, executor *exec.Executor, vmName string, runtimes, tools []string, parallelism int) (map[string]InstallResult, map[string]InstallResult, error) {
+	items, invalid := newInstallPlan(runtimes, tools)
+	runtimeResults := make(map[string]InstallResult)
+	toolResults := make(map[string]InstallResult)
+	for runtime, err := range invalid {
+		runtimeResults[runtime] = newInstallResult("", err)
+	}
+	if len(items) == 0 {
+		return runtimeResults, toolResults, nil
+	}
+	levels, err := installLevels(items)
+	if err != nil {
+		return nil, nil, err
+	}
+	script := parallelInstallScript(items, levels, parallelism)
+	execCtx := exec.ExecutionContext{
+		VMName:     vmName,
+		WorkingDir: "/home/vagrant",
+		SyncBefore: false,
+		SyncAfter:  false,
+	}
+	// base64 keeps the script intact through ssh's shell, and bash runs it for wait -n
+	command := fmt.Sprintf("echo %s | base64 -d | bash", base64.StdEncoding.EncodeToString([]byte(script)))
+	result, err := executor.ExecuteCommand(ctx, command, execCtx, installProgressCallback(ctx, request, items))
+	if err != nil {
+		return nil, nil, errors.OperationFailed("install runtimes and tools", err)
+	}
+
+	for i, itemResult := range parseInstallResults(items, result.Stdout) {
+		if items[i].Kind == "runtime" {
+			runtimeResults[items[i].Name] = itemResult
+		} else {
+			toolResults[items[i].Name] = itemResult
+		}
+	}
+	return runtimeResults, toolResults, nil
+}
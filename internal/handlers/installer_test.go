@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewInstallPlan(t *testing.T) {
+	items, invalid := newInstallPlan([]string{"node", "cobol"}, []string{"docker-compose", "git", "docker"})
+	if len(items) != 4 || invalid["cobol"] == nil {
+		t.Fatalf("Expected 4 items and cobol to be invalid but got %+v, %v", items, invalid)
+	}
+	// docker-compose waits for docker, wherever it is in the list
+	if !slices.Equal(items[1].After, []int{3}) {
+		t.Errorf("Expected docker-compose to be installed after docker but got %v", items[1].After)
+	}
+	levels, err := installLevels(items)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if len(levels) != 2 || !slices.Equal(levels[0], []int{0, 2, 3}) || !slices.Equal(levels[1], []int{1}) {
+		t.Errorf("Expected levels [[0 2 3] [1]] but got %v", levels)
+	}
+
+	if _, err := installLevels([]installItem{{Name: "a", After: []int{1}}, {Name: "b", After: []int{0}}}); err == nil {
+		t.Error("Expected an error for a dependency cycle")
+	}
+}
+
+func TestParallelInstallScript(t *testing.T) {
+	bash, err := osexec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	// sudo only swallows the apt configuration and the package list refresh
+	bin := t.TempDir()
+	fakeSudo := "#!/bin/sh\ncase \"$1\" in tee) cat >/dev/null ;; apt-get) echo refreshed ;; *) exec \"$@\" ;; esac\n"
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), []byte(fakeSudo), 0755); err != nil {
+		t.Fatalf("Failed to write sudo: %v", err)
+	}
+
+	items := []installItem{
+		{Kind: "runtime", Name: "node", Command: aptUpdatePrefix + "echo node installed"},
+		{Kind: "tool", Name: "docker", Command: "echo docker failed >&2; exit 3"},
+		{Kind: "tool", Name: "docker-compose", Command: "echo never", After: []int{1}},
+		{Kind: "tool", Name: "git", Command: "sleep 0.2; echo git installed"},
+	}
+	levels, err := installLevels(items)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	cmd := osexec.Command(bash, "-c", parallelInstallScript(items, levels, 2))
+	cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected the script to succeed but got %v: %s", err, output)
+	}
+
+	results := parseInstallResults(items, string(output))
+	expected := []struct {
+		success bool
+		output  string
+		err     string
+	}{
+		{true, "node installed", ""},
+		{false, "docker failed", "exit code 3"},
+		{false, "", "skipped"},
+		{true, "git installed", ""},
+	}
+	for i, e := range expected {
+		result := results[i]
+		if result.Success != e.success || result.Output != e.output || !strings.Contains(result.Error, e.err) {
+			t.Errorf("Expected %s to give %+v but got %+v", items[i].Name, e, result)
+		}
+	}
+
+	// Each item reports its start and end, and docker-compose starts only after docker failed
+	var events []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if rest, ok := strings.CutPrefix(line, installMarker+" "); ok {
+			if message, _ := describeInstallEvent(items, strings.Fields(rest)); message != "" {
+				events = append(events, message)
+			}
+		}
+	}
+	for _, event := range []string{"package lists: done", "runtime node: done", "tool docker: failed with exit code 3", "tool docker-compose: skipped because a dependency failed", "tool git: done"} {
+		if !slices.Contains(events, event) {
+			t.Errorf("Expected event %q in %v", event, events)
+		}
+	}
+	if slices.Index(events, "tool docker-compose: skipped because a dependency failed") < slices.Index(events, "tool git: done") {
+		t.Errorf("Expected docker-compose to wait for the first level but got %v", events)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// Page size limits for query_vm_journal
+const (
+	defaultJournalLimit = 100
+	maxJournalLimit     = 1000
+)
+
+// journalPriorities lists syslog priority names in journald order (0 = emerg)
+var journalPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var (
+	// journalUnitPattern restricts systemd unit names, including \x2d style escapes
+	journalUnitPattern = regexp.MustCompile(`^[A-Za-z0-9@._:\\-]+$`)
+	// journalTimePattern restricts journalctl time specifications such as "-1h" or "2025-01-02 10:00:00"
+	journalTimePattern = regexp.MustCompile(`^[A-Za-z0-9 :+.\-]+$`)
+	// journalCursorPattern restricts journal cursors returned by a previous query
+	journalCursorPattern = regexp.MustCompile(`^[A-Za-z0-9=;_\-]+$`)
+)
+
+// journalQuery holds the filters for a guest journal query
+type journalQuery struct {
+	Unit     string
+	Priority string
+	Since    string
+	Until    string
+	Grep     string
+	Cursor   string
+	Limit    int
+}
+
+// vmJournalEntry is a parsed guest journal entry
+type vmJournalEntry struct {
+	Time       time.Time `json:"time"`
+	Priority   int       `json:"priority"`
+	Level      string    `json:"level"`
+	Unit       string    `json:"unit,omitempty"`
+	Identifier string    `json:"identifier,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	Message    string    `json:"message"`
+	Cursor     string    `json:"cursor"`
+}
+
+// QueryJournalResponse is the result of query_vm_journal
+type QueryJournalResponse struct {
+	VMName  string           `json:"vm_name"`
+	Count   int              `json:"count"`
+	Entries []vmJournalEntry `json:"entries"`
+	HasMore bool             `json:"has_more"`
+	// NextCursor continues the query after the last entry
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// RegisterJournalTools registers the guest journal tools with the MCP server
+func RegisterJournalTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	// Query VM journal tool
+	type QueryVMJournalArgs struct {
+		VMName   string  `json:"vm_name"`
+		Unit     string  `json:"unit"`
+		Priority string  `json:"priority"`
+		Since    string  `json:"since"`
+		Until    string  `json:"until"`
+		Grep     string  `json:"grep"`
+		Cursor   string  `json:"cursor"`
+		Limit    float64 `json:"limit"`
+	}
+	queryJournalTool := mcp.NewTool("query_vm_journal",
+		mcp.WithDescription("Query the VM's systemd journal with structured filters and return parsed entries. Without 'since' or 'cursor' the most recent entries are returned; pass 'next_cursor' from a previous result as 'cursor' to page forward"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("unit",
+			mcp.Description("Only show entries for this systemd unit (e.g., 'nginx.service')")),
+		mcp.WithString("priority",
+			mcp.Description("Only show entries at this priority or more severe"),
+			mcp.Enum(journalPriorities...)),
+		mcp.WithString("since",
+			mcp.Description("Only show entries on or after this time (e.g., '2025-01-02 10:00:00', '-1h', 'today')")),
+		mcp.WithString("until",
+			mcp.Description("Only show entries on or before this time")),
+		mcp.WithString("grep",
+			mcp.Description("Only show entries whose message matches this regular expression")),
+		mcp.WithString("cursor",
+			mcp.Description("Continue after this cursor (the 'next_cursor' of a previous query)")),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of entries to return (max 1000)"),
+			mcp.DefaultNumber(defaultJournalLimit)),
+	)
+
+	mcp_pkg.RegisterTypedTool(srv, queryJournalTool, func(ctx context.Context, request mcp.CallToolRequest, args QueryVMJournalArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		query := journalQuery{
+			Unit:     args.Unit,
+			Priority: args.Priority,
+			Since:    args.Since,
+			Until:    args.Until,
+			Grep:     args.Grep,
+			Cursor:   args.Cursor,
+			Limit:    int(args.Limit),
+		}
+		command, err := buildJournalCommand(&query)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// Check VM state
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+
+		execCtx := exec.ExecutionContext{
+			VMName:     args.VMName,
+			WorkingDir: "/",
+			SyncBefore: false,
+			SyncAfter:  false,
+		}
+		result, err := executor.ExecuteCommand(ctx, command, execCtx, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Journal query failed: %v", err), nil
+		}
+
+		entries, err := parseJournalOutput(result.Stdout)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to parse journal output: %v", err), nil
+		}
+		if len(entries) == 0 && result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Journal query failed (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr)), nil
+		}
+
+		// One extra entry is fetched when paging forward to tell whether more follow
+		hasMore := false
+		if len(entries) > query.Limit {
+			entries = entries[:query.Limit]
+			hasMore = true
+		}
+
+		response := QueryJournalResponse{
+			VMName:  args.VMName,
+			Count:   len(entries),
+			Entries: entries,
+			HasMore: hasMore,
+		}
+		if len(entries) > 0 {
+			response.NextCursor = entries[len(entries)-1].Cursor
+		} else {
+			response.NextCursor = query.Cursor
+		}
+
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Journal tools registered")
+}
+
+// buildJournalCommand validates query, applies defaults and returns the journalctl command line
+func buildJournalCommand(query *journalQuery) (string, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultJournalLimit
+	}
+	if query.Limit > maxJournalLimit {
+		query.Limit = maxJournalLimit
+	}
+
+	args := []string{"sudo", "-n", "journalctl", "--no-pager", "--output=json"}
+	if query.Unit != "" {
+		if !journalUnitPattern.MatchString(query.Unit) {
+			return "", fmt.Errorf("invalid 'unit' parameter: %s", query.Unit)
+		}
+		args = append(args, "--unit="+shellquote.Quote(query.Unit))
+	}
+	if query.Priority != "" {
+		if priorityLevel(query.Priority) < 0 {
+			return "", fmt.Errorf("invalid 'priority' parameter: %s (must be one of %s)", query.Priority, strings.Join(journalPriorities, ", "))
+		}
+		args = append(args, "--priority="+query.Priority)
+	}
+	if query.Since != "" {
+		if !journalTimePattern.MatchString(query.Since) {
+			return "", fmt.Errorf("invalid 'since' parameter: %s", query.Since)
+		}
+		args = append(args, "--since="+shellquote.Quote(query.Since))
+	}
+	if query.Until != "" {
+		if !journalTimePattern.MatchString(query.Until) {
+			return "", fmt.Errorf("invalid 'until' parameter: %s", query.Until)
+		}
+		args = append(args, "--until="+shellquote.Quote(query.Until))
+	}
+	if query.Grep != "" {
+		if _, err := regexp.Compile(query.Grep); err != nil {
+			return "", fmt.Errorf("invalid 'grep' parameter: %v", err)
+		}
+		args = append(args, "--grep="+shellquote.Quote(query.Grep))
+	}
+	if query.Cursor != "" {
+		if !journalCursorPattern.MatchString(query.Cursor) {
+			return "", fmt.Errorf("invalid 'cursor' parameter")
+		}
+		args = append(args, "--after-cursor="+shellquote.Quote(query.Cursor))
+	}
+
+	// Without a starting point show the latest entries; otherwise page forward from it
+	if query.Since == "" && query.Cursor == "" {
+		args = append(args, fmt.Sprintf("--lines=%d", query.Limit))
+		return strings.Join(args, " "), nil
+	}
+	return fmt.Sprintf("%s | head -n %d", strings.Join(args, " "), query.Limit+1), nil
+}
+
+// parseJournalOutput parses journalctl --output=json lines into entries
+func parseJournalOutput(output string) ([]vmJournalEntry, error) {
+	entries := []vmJournalEntry{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return nil, err
+		}
+
+		entry := vmJournalEntry{
+			Unit:       journalField(fields, "_SYSTEMD_UNIT"),
+			Identifier: journalField(fields, "SYSLOG_IDENTIFIER"),
+			Message:    journalField(fields, "MESSAGE"),
+			Cursor:     journalField(fields, "__CURSOR"),
+			Priority:   6,
+		}
+		if usec, err := strconv.ParseInt(journalField(fields, "__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+			entry.Time = time.UnixMicro(usec).UTC()
+		}
+		if priority, err := strconv.Atoi(journalField(fields, "PRIORITY")); err == nil && priority >= 0 && priority < len(journalPriorities) {
+			entry.Priority = priority
+		}
+		entry.Level = journalPriorities[entry.Priority]
+		if pid, err := strconv.Atoi(journalField(fields, "_PID")); err == nil {
+			entry.PID = pid
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// journalField returns a journal field as a string; binary fields are encoded as byte arrays
+func journalField(fields map[string]interface{}, name string) string {
+	switch value := fields[name].(type) {
+	case string:
+		return value
+	case []interface{}:
+		data := make([]byte, 0, len(value))
+		for _, b := range value {
+			if n, ok := b.(float64); ok {
+				data = append(data, byte(n))
+			}
+		}
+		return string(data)
+	}
+	return ""
+}
+
+// priorityLevel returns the numeric level of a priority name, or -1 if unknown
+func priorityLevel(name string) int {
+	for i, priority := range journalPriorities {
+		if priority == name {
+			return i
+		}
+	}
+	return -1
+}
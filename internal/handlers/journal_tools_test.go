@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildJournalCommand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		query       journalQuery
+		contains    []string
+		expectError bool
+	}{
+		{
+			name:     "latest entries",
+			query:    journalQuery{},
+			contains: []string{"journalctl --no-pager --output=json", "--lines=100"},
+		},
+		{
+			name:     "filters are quoted",
+			query:    journalQuery{Unit: "nginx.service", Priority: "err", Since: "-1h", Grep: "it's down", Limit: 10},
+			contains: []string{"--unit='nginx.service'", "--priority=err", "--since='-1h'", `--grep='it'\''s down'`, "| head -n 11"},
+		},
+		{
+			name:     "cursor pages forward",
+			query:    journalQuery{Cursor: "s=abc;i=1f", Limit: 5000},
+			contains: []string{"--after-cursor='s=abc;i=1f'", "| head -n 1001"},
+		},
+		{
+			name:        "invalid priority",
+			query:       journalQuery{Priority: "loud"},
+			expectError: true,
+		},
+		{
+			name:        "invalid since",
+			query:       journalQuery{Since: "$(reboot)"},
+			expectError: true,
+		},
+		{
+			name:        "invalid grep",
+			query:       journalQuery{Grep: "("},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			command, err := buildJournalCommand(&tc.query)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error but got command %q", command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			for _, expected := range tc.contains {
+				if !strings.Contains(command, expected) {
+					t.Errorf("Expected command to contain %q but got %q", expected, command)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJournalOutput(t *testing.T) {
+	output := `{"__CURSOR":"s=1","__REALTIME_TIMESTAMP":"1735812000000000","PRIORITY":"3","_SYSTEMD_UNIT":"nginx.service","SYSLOG_IDENTIFIER":"nginx","_PID":"42","MESSAGE":"bind failed"}
+{"__CURSOR":"s=2","__REALTIME_TIMESTAMP":"1735812001000000","MESSAGE":[104,105]}
+`
+	entries, err := parseJournalOutput(output)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries but got %d", len(entries))
+	}
+
+	first := entries[0]
+	if first.Level != "err" || first.Unit != "nginx.service" || first.PID != 42 || first.Message != "bind failed" || first.Cursor != "s=1" {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if first.Time.Unix() != 1735812000 {
+		t.Errorf("Expected time 1735812000 but got %d", first.Time.Unix())
+	}
+
+	second := entries[1]
+	if second.Message != "hi" || second.Level != "info" {
+		t.Errorf("Unexpected second entry: %+v", second)
+	}
+}
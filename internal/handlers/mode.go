@@ -0,0 +1,195 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+)
+
+// Server modes, from the most to the least capable
+const (
+	// ModeFull registers every tool
+	ModeFull = "full"
+	// ModeNoDestroy leaves out the tools that destroy VMs, containers or files
+	ModeNoDestroy = "no_destroy"
+	// ModeReadOnly registers only the tools that inspect VMs and projects without changing them
+	ModeReadOnly = "read_only"
+)
+
+// Modes lists the server modes
+var Modes = []string{ModeFull, ModeNoDestroy, ModeReadOnly}
+
+// destructiveTools destroy VMs, containers or files; no_destroy and read_only leave them out
+var destructiveTools = []string{
+	"destroy_dev_vm",
+	"destroy_vms",
+	"cleanup_orphans",
+	"cleanup_vm",
+	"compose_down",
+	// Resolving with use_host or use_vm overwrites one side of the conflict, and the
+	// prefer_* policies do so automatically
+	"resolve_sync_conflicts",
+	"set_conflict_policy",
+	// Restoring a backup overwrites the guest files it holds
+	"restore_vm_data",
+	// A time to live ends with the VM halted or, with MCP_EXPIRY_ACTION=destroy, destroyed
+	"set_vm_ttl",
+	// Writing or patching a guest file replaces its previous content
+	"write_vm_file",
+	"patch_vm_file",
+	"delete_vm_secret",
+	// SQL can drop tables and delete rows
+	"run_sql",
+	// Syncs mirror directories, deleting the files the destination holds and the source lacks,
+	// and overwrite changed files; uploads overwrite guest files
+	"sync_to_vm",
+	"sync_from_vm",
+	"sync_all",
+	"upload_to_vm",
+	// Scheduled tasks run syncs and commands later, outside the mode's checks
+	"schedule_task",
+	// Dotfiles overwrite the files of the guest home directory
+	"configure_dotfiles",
+	// Rotating the key removes every other key from the vagrant user's authorized keys
+	"rotate_vagrant_key",
+	// Commands and shells can do anything the tools above do, such as deleting files
+	"exec_in_vm",
+	"exec_with_sync",
+	"run_script_in_vm",
+	"run_background_task",
+	"start_background_process",
+	"start_dev_server",
+	"open_vm_shell",
+	"send_to_shell",
+}
+
+// readOnlyTools are the only tools read_only registers. Tools added later stay out of
+// read_only until they are listed here.
+var readOnlyTools = []string{
+	"analyze_disk_usage",
+	"container_logs",
+	"describe_tool_output",
+	"detect_project",
+	"diagnose_provision_failure",
+	"find_files",
+	"find_symbol",
+	"get_artifact",
+	"get_boot_report",
+	"get_ssh_info",
+	"get_vm_info",
+	"get_vm_operation_log",
+	"get_vm_status",
+	"lint_vagrantfile",
+	"list_all_vagrant_environments",
+	"list_background_processes",
+	"list_containers",
+	"list_port_profiles",
+	"list_scheduled_tasks",
+	"list_tunnels",
+	"list_vm_secrets",
+	"preflight_check",
+	"query_vm_journal",
+	"search_boxes",
+	"search_code",
+	"search_vm",
+	"suggest_exclude_patterns",
+	"sync_status",
+	"tail_background_process_log",
+	"verify_sync",
+}
+
+// ParseMode validates a server mode; an empty mode is full
+func ParseMode(mode string) (string, error) {
+	if mode == "" {
+		return ModeFull, nil
+	}
+	for _, m := range Modes {
+		if m == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid server mode '%s' (must be one of %s)", mode, strings.Join(Modes, ", "))
+}
+
+// ModeFromEnv returns the server mode set with MCP_SERVER_MODE
+func ModeFromEnv() (string, error) {
+	return ParseMode(os.Getenv("MCP_SERVER_MODE"))
+}
+
+// disabledTools returns the registered tools a mode leaves out, sorted
+func disabledTools(mode string, registered []string) []string {
+	var disabled []string
+	switch mode {
+	case ModeNoDestroy:
+		destructive := make(map[string]bool, len(destructiveTools))
+		for _, name := range destructiveTools {
+			destructive[name] = true
+		}
+		for _, name := range registered {
+			if destructive[name] {
+				disabled = append(disabled, name)
+			}
+		}
+	case ModeReadOnly:
+		readOnly := make(map[string]bool, len(readOnlyTools))
+		for _, name := range readOnlyTools {
+			readOnly[name] = true
+		}
+		for _, name := range registered {
+			if !readOnly[name] {
+				disabled = append(disabled, name)
+			}
+		}
+	}
+	sort.Strings(disabled)
+	return disabled
+}
+
+// modeAllowsTool reports whether a mode keeps a tool
+func modeAllowsTool(mode, tool string) bool {
+	return len(disabledTools(mode, []string{tool})) == 0
+}
+
+// registeredToolNames returns the names of the tools registered with a server, as tools/list
+// reports them
+func registeredToolNames(srv *server.MCPServer) ([]string, error) {
+	message := srv.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	response, ok := message.(mcp.JSONRPCResponse)
+	if !ok {
+		return nil, fmt.Errorf("unexpected tools/list response %T", message)
+	}
+	result, ok := response.Result.(mcp.ListToolsResult)
+	if !ok {
+		return nil, fmt.Errorf("unexpected tools/list result %T", response.Result)
+	}
+	names := make([]string, 0, len(result.Tools))
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names, nil
+}
+
+// applyMode removes the tools a mode leaves out from a server
+func applyMode(srv *server.MCPServer, mode string) error {
+	if mode == ModeFull {
+		return nil
+	}
+	registered, err := registeredToolNames(srv)
+	if err != nil {
+		return err
+	}
+	disabled := disabledTools(mode, registered)
+	srv.DeleteTools(disabled...)
+	log.Info().Str("mode", mode).Strs("disabled", disabled).Msg("Tools disabled by server mode")
+	return nil
+}
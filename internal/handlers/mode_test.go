@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestParseMode(t *testing.T) {
+	testCases := []struct {
+		mode     string
+		expected string
+		wantErr  bool
+	}{
+		{"", ModeFull, false},
+		{"full", ModeFull, false},
+		{"no_destroy", ModeNoDestroy, false},
+		{"read_only", ModeReadOnly, false},
+		{"readonly", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			mode, err := ParseMode(tc.mode)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v but got %v", tc.wantErr, err)
+			}
+			if mode != tc.expected {
+				t.Errorf("Expected mode %q but got %q", tc.expected, mode)
+			}
+		})
+	}
+}
+
+func TestApplyMode(t *testing.T) {
+	newServer := func() *server.MCPServer {
+		srv := server.NewMCPServer("test", "1.0")
+		handler := func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			return mcpgo.NewToolResultText("ok"), nil
+		}
+		for _, name := range []string{"get_vm_status", "exec_in_vm", "destroy_dev_vm", "resolve_sync_conflicts", "write_vm_file"} {
+			srv.AddTool(mcpgo.NewTool(name), handler)
+		}
+		return srv
+	}
+	testCases := []struct {
+		mode     string
+		expected []string
+	}{
+		{ModeFull, []string{"destroy_dev_vm", "exec_in_vm", "get_vm_status", "resolve_sync_conflicts", "write_vm_file"}},
+		{ModeNoDestroy, []string{"get_vm_status"}},
+		{ModeReadOnly, []string{"get_vm_status"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			srv := newServer()
+			if err := applyMode(srv, tc.mode); err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			names, err := registeredToolNames(srv)
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if len(names) != len(tc.expected) {
+				t.Fatalf("Expected tools %v but got %v", tc.expected, names)
+			}
+			for i, name := range names {
+				if name != tc.expected[i] {
+					t.Errorf("Expected tools %v but got %v", tc.expected, names)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestModeToolListsNameRealTools(t *testing.T) {
+	srv := server.NewMCPServer("test", "1.0")
+	NewHandlerRegistry(nil, nil, nil).RegisterAllTools(srv)
+	names, err := registeredToolNames(srv)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	registered := make(map[string]bool, len(names))
+	for _, name := range names {
+		registered[name] = true
+	}
+	for _, name := range append(append([]string{}, destructiveTools...), readOnlyTools...) {
+		if !registered[name] {
+			t.Errorf("Expected mode tool list entry %s to be a registered tool", name)
+		}
+	}
+}
+
+func TestNoDestroyLeavesOutOverwritingTools(t *testing.T) {
+	srv := server.NewMCPServer("test", "1.0")
+	NewHandlerRegistry(nil, nil, nil).RegisterAllTools(srv)
+	if err := applyMode(srv, ModeNoDestroy); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	names, err := registeredToolNames(srv)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	registered := make(map[string]bool, len(names))
+	for _, name := range names {
+		registered[name] = true
+	}
+	for _, name := range []string{
+		"sync_to_vm", "sync_from_vm", "sync_all", "upload_to_vm", "schedule_task", "configure_dotfiles", "rotate_vagrant_key",
+		"exec_in_vm", "exec_with_sync", "run_script_in_vm", "run_background_task", "start_background_process", "start_dev_server", "open_vm_shell", "send_to_shell",
+	} {
+		if registered[name] {
+			t.Errorf("Expected %s to be left out in %s mode", name, ModeNoDestroy)
+		}
+	}
+	if !registered["get_vm_status"] {
+		t.Errorf("Expected get_vm_status to stay registered in %s mode", ModeNoDestroy)
+	}
+}
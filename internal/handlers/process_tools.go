@@ -0,0 +1,416 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/events"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/process"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// Background process log limits
+const (
+	defaultProcessLogLines = 50
+	maxProcessLogLines     = 5000
+)
+
+// ProcessStatus is a tracked background process and whether it is still running
+type ProcessStatus struct {
+	ID         string    `json:"id"`
+	VMName     string    `json:"vm_name"`
+	Name       string    `json:"name"`
+	Command    string    `json:"command"`
+	WorkingDir string    `json:"working_dir"`
+	Mode       string    `json:"mode"`
+	PID        int       `json:"pid"`
+	LogFile    string    `json:"log_file"`
+	StartedAt  time.Time `json:"started_at"`
+	State      string    `json:"state"`
+}
+
+// ListProcessesResponse is the result of list_background_processes
+type ListProcessesResponse struct {
+	Processes []ProcessStatus `json:"processes"`
+	Count     int             `json:"count"`
+}
+
+// ProcessLogResponse is the result of tail_background_process_log
+type ProcessLogResponse struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	VMName string `json:"vm_name"`
+	Lines  int    `json:"lines"`
+	Output string `json:"output"`
+}
+
+// StopProcessResponse is the result of stop_background_process
+type StopProcessResponse struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	VMName string `json:"vm_name"`
+	Status string `json:"status"`
+	Output string `json:"output"`
+}
+
+// RegisterProcessTools registers the background process management tools with the MCP server
+func RegisterProcessTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor, processes *process.Registry) {
+	// Processes do not survive their VM stopping
+	events.GlobalBus.Subscribe(func(event events.Event) {
+		if event.Type != events.VMStateChanged {
+			return
+		}
+		if state, ok := event.Data["state"].(core.VMState); ok && state != core.Running {
+			processes.RemoveVM(event.VMName)
+		}
+	})
+
+	// Start background process tool
+	type StartProcessArgs struct {
+		VMName     string `json:"vm_name"`
+		Command    string `json:"command"`
+		Name       string `json:"name"`
+		WorkingDir string `json:"working_dir"`
+		Mode       string `json:"mode"`
+	}
+	startProcessTool := mcp.NewTool("start_background_process",
+		mcp.WithDescription("Start a long-running command such as a dev server or file watcher in the VM and track it, so it can be listed, its log tailed and stopped later"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("command",
+			mcp.Required(),
+			mcp.Description("Command to run")),
+		mcp.WithString("name",
+			mcp.Description("Name to refer to the process by (default: its generated ID)")),
+		mcp.WithString("working_dir",
+			mcp.Description("Working directory"),
+			mcp.DefaultString("/home/vagrant")),
+		mcp.WithString("mode",
+			mcp.Description("How to run the process: nohup detaches it, systemd runs it as a transient unit logging to the journal, tmux runs it in a session that can be attached to"),
+			mcp.Enum(process.Modes...),
+			mcp.DefaultString(process.ModeNohup)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, startProcessTool, func(ctx context.Context, request mcp.CallToolRequest, args StartProcessArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Command == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or command"), nil
+		}
+		mode := args.Mode
+		if mode == "" {
+			mode = process.ModeNohup
+		}
+		if !isProcessMode(mode) {
+			return mcp.NewToolResultErrorf("Unsupported mode '%s' (must be one of %s)", mode, strings.Join(process.Modes, ", ")), nil
+		}
+		workingDir := args.WorkingDir
+		if workingDir == "" {
+			workingDir = "/home/vagrant"
+		}
+		if args.Name != "" {
+			if _, err := processes.Get(args.VMName, args.Name); err == nil {
+				return mcp.NewToolResultErrorf("A background process named '%s' is already tracked in VM '%s'", args.Name, args.VMName), nil
+			}
+		}
+
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+
+		proc := processes.New(args.VMName, args.Name, args.Command, workingDir, mode)
+		if mode == process.ModeSystemd {
+			// systemd keeps the output in the journal instead of a log file
+			proc.LogFile = ""
+		}
+		execCtx := exec.ExecutionContext{VMName: args.VMName, PolicyCommand: args.Command, PolicyDir: workingDir}
+		result, err := executor.ExecuteCommand(ctx, buildProcessStartCommand(proc), execCtx, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to start background process: %v", err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to start background process (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr+result.Stdout)), nil
+		}
+		proc.PID = parseProcessPID(result.Stdout)
+		processes.Add(proc)
+
+		jsonResponse, err := json.Marshal(proc)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// List background processes tool
+	type ListProcessesArgs struct {
+		VMName string `json:"vm_name"`
+	}
+	listProcessesTool := mcp.NewTool("list_background_processes",
+		mcp.WithDescription("List tracked background processes and whether they are still running"),
+		mcp.WithString("vm_name",
+			mcp.Description("Only list processes in this VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, listProcessesTool, func(ctx context.Context, request mcp.CallToolRequest, args ListProcessesArgs) (*mcp.CallToolResult, error) {
+		tracked := processes.List(args.VMName)
+
+		// Check all processes of a VM with a single command
+		byVM := make(map[string][]process.Process)
+		for _, proc := range tracked {
+			byVM[proc.VMName] = append(byVM[proc.VMName], proc)
+		}
+		states := make(map[string]string, len(tracked))
+		for vmName, vmProcesses := range byVM {
+			result, err := executor.ExecuteCommand(ctx, buildProcessStatusCommand(vmProcesses), exec.ExecutionContext{VMName: vmName}, nil)
+			if err != nil {
+				log.Warn().Err(err).Str("vm", vmName).Msg("Failed to check background processes")
+				continue
+			}
+			for id, state := range parseProcessStates(result.Stdout) {
+				states[id] = state
+			}
+		}
+
+		items := make([]ProcessStatus, 0, len(tracked))
+		for _, proc := range tracked {
+			state := states[proc.ID]
+			if state == "" {
+				state = process.StateUnknown
+			}
+			items = append(items, ProcessStatus{
+				ID:         proc.ID,
+				VMName:     proc.VMName,
+				Name:       proc.Name,
+				Command:    proc.Command,
+				WorkingDir: proc.WorkingDir,
+				Mode:       proc.Mode,
+				PID:        proc.PID,
+				LogFile:    proc.LogFile,
+				StartedAt:  proc.StartedAt,
+				State:      state,
+			})
+		}
+		response := ListProcessesResponse{
+			Processes: items,
+			Count:     len(items),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Tail background process log tool
+	type TailProcessLogArgs struct {
+		Process string  `json:"process"`
+		VMName  string  `json:"vm_name"`
+		Lines   float64 `json:"lines"`
+	}
+	tailProcessLogTool := mcp.NewTool("tail_background_process_log",
+		mcp.WithDescription("Return the last lines of a background process's output"),
+		mcp.WithString("process",
+			mcp.Required(),
+			mcp.Description("ID or name of the background process")),
+		mcp.WithString("vm_name",
+			mcp.Description("VM the process runs in, to disambiguate names")),
+		mcp.WithNumber("lines",
+			mcp.Description("Number of lines to return"),
+			mcp.DefaultNumber(defaultProcessLogLines)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, tailProcessLogTool, func(ctx context.Context, request mcp.CallToolRequest, args TailProcessLogArgs) (*mcp.CallToolResult, error) {
+		if args.Process == "" {
+			return mcp.NewToolResultError("Missing required parameter: process"), nil
+		}
+		proc, err := processes.Get(args.VMName, args.Process)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Background process '%s' not found", args.Process), nil
+		}
+		lines := int(args.Lines)
+		if lines <= 0 {
+			lines = defaultProcessLogLines
+		}
+		if lines > maxProcessLogLines {
+			lines = maxProcessLogLines
+		}
+
+		result, err := executor.ExecuteCommand(ctx, buildProcessLogCommand(proc, lines), exec.ExecutionContext{VMName: proc.VMName}, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to read process log: %v", err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to read process log (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr)), nil
+		}
+		response := ProcessLogResponse{
+			ID:     proc.ID,
+			Name:   proc.Name,
+			VMName: proc.VMName,
+			Lines:  lines,
+			Output: result.Stdout,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Stop background process tool
+	type StopProcessArgs struct {
+		Process string `json:"process"`
+		VMName  string `json:"vm_name"`
+	}
+	stopProcessTool := mcp.NewTool("stop_background_process",
+		mcp.WithDescription("Stop a tracked background process and its child processes"),
+		mcp.WithString("process",
+			mcp.Required(),
+			mcp.Description("ID or name of the background process")),
+		mcp.WithString("vm_name",
+			mcp.Description("VM the process runs in, to disambiguate names")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, stopProcessTool, func(ctx context.Context, request mcp.CallToolRequest, args StopProcessArgs) (*mcp.CallToolResult, error) {
+		if args.Process == "" {
+			return mcp.NewToolResultError("Missing required parameter: process"), nil
+		}
+		proc, err := processes.Get(args.VMName, args.Process)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Background process '%s' not found", args.Process), nil
+		}
+		if proc.Mode == process.ModeNohup && proc.PID <= 0 {
+			processes.Remove(proc.ID)
+			return mcp.NewToolResultErrorf("The PID of background process '%s' is unknown; stop it with exec_in_vm", args.Process), nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, buildProcessStopCommand(proc), exec.ExecutionContext{VMName: proc.VMName}, nil)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to stop background process: %v", err), nil
+		}
+		processes.Remove(proc.ID)
+		response := StopProcessResponse{
+			ID:     proc.ID,
+			Name:   proc.Name,
+			VMName: proc.VMName,
+			Status: "stopped",
+			Output: strings.TrimSpace(result.Stdout),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Background process tools registered")
+}
+
+// isProcessMode reports whether mode is a supported launch mode
+func isProcessMode(mode string) bool {
+	for _, m := range process.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// buildProcessStartCommand returns the command that launches proc and prints its PID
+func buildProcessStartCommand(proc process.Process) string {
+	command := shellquote.Quote(proc.Command)
+	switch proc.Mode {
+	case process.ModeSystemd:
+		unit := shellquote.Quote(proc.Unit())
+		return fmt.Sprintf("sudo -n systemd-run --unit=%[1]s --uid=$(id -u) --gid=$(id -g) --working-directory=%[2]s --setenv=HOME=\"$HOME\" sh -c %[3]s >/dev/null && systemctl show -p MainPID --value %[1]s",
+			unit, shellquote.Quote(proc.WorkingDir), command)
+	case process.ModeTmux:
+		session := shellquote.Quote(proc.Unit())
+		inner := shellquote.Quote(fmt.Sprintf("sh -c %s 2>&1 | tee -a %s", command, shellquote.Quote(proc.LogFile)))
+		return fmt.Sprintf("mkdir -p %[1]s && tmux new-session -d -s %[2]s -c %[3]s %[4]s && tmux list-panes -t %[2]s -F '#{pane_pid}'",
+			shellquote.Quote(process.LogDir), session, shellquote.Quote(proc.WorkingDir), inner)
+	default:
+		// setsid puts the process in its own process group so stopping it also stops its children
+		return fmt.Sprintf("mkdir -p %s && cd %s && setsid nohup sh -c %s > %s 2>&1 < /dev/null & echo $!",
+			shellquote.Quote(process.LogDir), shellquote.Quote(proc.WorkingDir), command, shellquote.Quote(proc.LogFile))
+	}
+}
+
+// buildProcessStatusCommand returns a command printing "<id> running|exited" for each process
+func buildProcessStatusCommand(procs []process.Process) string {
+	checks := make([]string, 0, len(procs))
+	for _, proc := range procs {
+		var check string
+		switch proc.Mode {
+		case process.ModeSystemd:
+			check = fmt.Sprintf("systemctl is-active --quiet %s", shellquote.Quote(proc.Unit()))
+		case process.ModeTmux:
+			check = fmt.Sprintf("tmux has-session -t %s 2>/dev/null", shellquote.Quote(proc.Unit()))
+		default:
+			check = "false"
+			if proc.PID > 0 {
+				check = fmt.Sprintf("kill -0 %d 2>/dev/null", proc.PID)
+			}
+		}
+		checks = append(checks, fmt.Sprintf("if %s; then echo '%s %s'; else echo '%s %s'; fi",
+			check, proc.ID, process.StateRunning, proc.ID, process.StateExited))
+	}
+	return strings.Join(checks, "; ")
+}
+
+// buildProcessLogCommand returns the command printing the last lines of proc's output
+func buildProcessLogCommand(proc process.Process, lines int) string {
+	if proc.Mode == process.ModeSystemd {
+		return fmt.Sprintf("sudo -n journalctl --no-pager --output=cat --lines=%d --unit=%s", lines, shellquote.Quote(proc.Unit()))
+	}
+	return fmt.Sprintf("tail -n %d %s", lines, shellquote.Quote(proc.LogFile))
+}
+
+// buildProcessStopCommand returns the command that stops proc and its children
+func buildProcessStopCommand(proc process.Process) string {
+	switch proc.Mode {
+	case process.ModeSystemd:
+		return fmt.Sprintf("sudo -n systemctl stop %s", shellquote.Quote(proc.Unit()))
+	case process.ModeTmux:
+		return fmt.Sprintf("tmux kill-session -t %s", shellquote.Quote(proc.Unit()))
+	default:
+		// Signal the whole process group, falling back to the process itself
+		return fmt.Sprintf("kill -TERM -- -%[1]d 2>/dev/null || kill -TERM %[1]d", proc.PID)
+	}
+}
+
+// parseProcessPID returns the PID printed on the last line of a start command's output
+func parseProcessPID(output string) int {
+	lines := strings.Fields(output)
+	if len(lines) == 0 {
+		return 0
+	}
+	pid, err := strconv.Atoi(lines[len(lines)-1])
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// parseProcessStates parses the output of a status command into process states by ID
+func parseProcessStates(output string) map[string]string {
+	states := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			states[fields[0]] = fields[1]
+		}
+	}
+	return states
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/process"
+)
+
+func TestBuildProcessCommands(t *testing.T) {
+	proc := process.Process{
+		ID:         "abc123",
+		Command:    "npm run dev -- --host '0.0.0.0'",
+		WorkingDir: "/vagrant/app",
+		PID:        4242,
+		LogFile:    "/tmp/vagrant-mcp/abc123.log",
+	}
+
+	testCases := []struct {
+		name     string
+		mode     string
+		start    []string
+		stop     string
+		logLines string
+	}{
+		{
+			name:     "nohup",
+			mode:     process.ModeNohup,
+			start:    []string{"cd '/vagrant/app'", `setsid nohup sh -c 'npm run dev -- --host '\''0.0.0.0'\'''`, "> '/tmp/vagrant-mcp/abc123.log' 2>&1", "echo $!"},
+			stop:     "kill -TERM -- -4242 2>/dev/null || kill -TERM 4242",
+			logLines: "tail -n 20 '/tmp/vagrant-mcp/abc123.log'",
+		},
+		{
+			name:     "systemd",
+			mode:     process.ModeSystemd,
+			start:    []string{"systemd-run --unit='mcp-abc123'", "--working-directory='/vagrant/app'", "systemctl show -p MainPID --value 'mcp-abc123'"},
+			stop:     "sudo -n systemctl stop 'mcp-abc123'",
+			logLines: "sudo -n journalctl --no-pager --output=cat --lines=20 --unit='mcp-abc123'",
+		},
+		{
+			name:     "tmux",
+			mode:     process.ModeTmux,
+			start:    []string{"tmux new-session -d -s 'mcp-abc123' -c '/vagrant/app'", "tee -a", "#{pane_pid}"},
+			stop:     "tmux kill-session -t 'mcp-abc123'",
+			logLines: "tail -n 20 '/tmp/vagrant-mcp/abc123.log'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := proc
+			p.Mode = tc.mode
+			start := buildProcessStartCommand(p)
+			for _, fragment := range tc.start {
+				if !strings.Contains(start, fragment) {
+					t.Errorf("Expected start command to contain %q but got %s", fragment, start)
+				}
+			}
+			if stop := buildProcessStopCommand(p); stop != tc.stop {
+				t.Errorf("Expected stop command %q but got %q", tc.stop, stop)
+			}
+			if logLines := buildProcessLogCommand(p, 20); logLines != tc.logLines {
+				t.Errorf("Expected log command %q but got %q", tc.logLines, logLines)
+			}
+		})
+	}
+}
+
+func TestParseProcessStates(t *testing.T) {
+	procs := []process.Process{
+		{ID: "a1", Mode: process.ModeNohup, PID: 100},
+		{ID: "b2", Mode: process.ModeNohup},
+	}
+	command := buildProcessStatusCommand(procs)
+	if !strings.Contains(command, "kill -0 100") || strings.Contains(command, "kill -0 0") {
+		t.Errorf("Unexpected status command: %s", command)
+	}
+
+	states := parseProcessStates("a1 running\nb2 exited\n")
+	if states["a1"] != process.StateRunning || states["b2"] != process.StateExited {
+		t.Errorf("Expected a1 running and b2 exited but got %v", states)
+	}
+	if pid := parseProcessPID("Running as unit: mcp-a1.service\n1234\n"); pid != 1234 {
+		t.Errorf("Expected PID 1234 but got %d", pid)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// DiagnoseProvisionResponse is the result of diagnose_provision_failure
+type DiagnoseProvisionResponse struct {
+	// Log is the operation log the diagnosis was made from
+	Log       string                `json:"log"`
+	Diagnosis vm.ProvisionDiagnosis `json:"diagnosis"`
+	Notes     []string              `json:"notes,omitempty"`
+}
+
+// RegisterProvisionTools registers the provisioning diagnosis tools with the MCP server
+func RegisterProvisionTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	type DiagnoseProvisionArgs struct {
+		Name      string `json:"name"`
+		FetchLogs *bool  `json:"fetch_logs"`
+	}
+	diagnoseProvisionTool := mcp.NewTool("diagnose_provision_failure",
+		mcp.WithDescription("Diagnose the last failed provisioning of a VM, by vagrant up or provision_vm: the failing provisioner or provisioning step, the commands it ran last, its error lines, the likely causes with suggested fixes, and the end of the guest logs that explain them"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithBoolean("fetch_logs",
+			mcp.Description("Fetch the journal errors and the guest logs relevant to the causes found, when the VM is running"),
+			mcp.DefaultBool(true)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, diagnoseProvisionTool, func(ctx context.Context, request mcp.CallToolRequest, args DiagnoseProvisionArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		path := core.OperationLogPath(vmManager.GetBaseDir(), args.Name, core.OperationLogProvision)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return mcp.NewToolResultErrorf("No provisioning recorded for VM '%s'", args.Name), nil
+			}
+			return mcp.NewToolResultErrorf("Failed to read provision log: %v", err), nil
+		}
+		// Without the configuration the provisioning steps are not named in the diagnosis
+		config, _ := vmManager.GetVMConfig(ctx, args.Name)
+		response := DiagnoseProvisionResponse{
+			Log:       path,
+			Diagnosis: vm.DiagnoseProvisionLog(args.Name, string(data), config),
+		}
+		if !response.Diagnosis.Failed {
+			response.Notes = append(response.Notes, "The last provisioning did not fail")
+		}
+
+		if response.Diagnosis.Failed && (args.FetchLogs == nil || *args.FetchLogs) {
+			state, err := vmManager.GetVMState(ctx, args.Name)
+			if err == nil && state == core.Running {
+				command := vm.ProvisionLogsCommand(vm.DiagnosisGuestLogs(response.Diagnosis))
+				result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.Name}, nil)
+				if err != nil {
+					response.Notes = append(response.Notes, "Failed to fetch guest logs: "+err.Error())
+				} else {
+					response.Diagnosis.GuestLogs = vm.ParseProvisionLogs(result.Stdout)
+				}
+			} else {
+				response.Notes = append(response.Notes, "Guest logs were not fetched because the VM is not running")
+			}
+		}
+
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Provision tools registered")
+}
@@ -16,8 +16,21 @@ func NewResponseHelper() *ResponseHelper {
 	return &ResponseHelper{}
 }
 
-// MarshalSuccessResponse marshals a response map to JSON and returns a successful MCP result
-func (h *ResponseHelper) MarshalSuccessResponse(response map[string]interface{}) (*mcp.CallToolResult, error) {
+// SyncResponse is the result of sync_to_vm and sync_from_vm
+type SyncResponse struct {
+	Status      string   `json:"status"`
+	Operation   string   `json:"operation"`
+	VMName      string   `json:"vm_name"`
+	SyncedFiles []string `json:"synced_files"`
+	SyncTimeMs  int      `json:"sync_time_ms"`
+	FileCount   int      `json:"file_count"`
+	Timestamp   string   `json:"timestamp"`
+	// ReportArtifact is the URI of the changes the sync made, readable with get_artifact
+	ReportArtifact string `json:"report_artifact,omitempty"`
+}
+
+// MarshalSuccessResponse marshals a response to JSON and returns a successful MCP result
+func (h *ResponseHelper) MarshalSuccessResponse(response interface{}) (*mcp.CallToolResult, error) {
 	jsonData, err := json.Marshal(response)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
@@ -26,15 +39,15 @@ func (h *ResponseHelper) MarshalSuccessResponse(response map[string]interface{})
 }
 
 // CreateSyncResponse creates a standardized sync response
-func (h *ResponseHelper) CreateSyncResponse(vmName string, syncedFiles []string, syncTimeMs int, operation string) map[string]interface{} {
-	return map[string]interface{}{
-		"status":       "success",
-		"operation":    operation,
-		"vm_name":      vmName,
-		"synced_files": syncedFiles,
-		"sync_time_ms": syncTimeMs,
-		"file_count":   len(syncedFiles),
-		"timestamp":    getCurrentTimestamp(),
+func (h *ResponseHelper) CreateSyncResponse(vmName string, syncedFiles []string, syncTimeMs int, operation string) SyncResponse {
+	return SyncResponse{
+		Status:      "success",
+		Operation:   operation,
+		VMName:      vmName,
+		SyncedFiles: syncedFiles,
+		SyncTimeMs:  syncTimeMs,
+		FileCount:   len(syncedFiles),
+		Timestamp:   getCurrentTimestamp(),
 	}
 }
 
@@ -0,0 +1,257 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/schedule"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// aptUpgradeCommand upgrades the packages of the guest without prompting
+const aptUpgradeCommand = "sudo DEBIAN_FRONTEND=noninteractive apt-get update -q && " +
+	"sudo DEBIAN_FRONTEND=noninteractive apt-get upgrade -y -q -o Dpkg::Options::=--force-confold"
+
+// ScheduledTasksResponse is the result of list_scheduled_tasks
+type ScheduledTasksResponse struct {
+	Tasks []schedule.Task `json:"tasks"`
+}
+
+// RegisterScheduleTools registers the scheduled task tools with the MCP server
+func RegisterScheduleTools(srv *server.MCPServer, vmManager core.VMManager, scheduler *schedule.Scheduler) {
+	// Schedule task tool
+	type ScheduleTaskArgs struct {
+		Name     string   `json:"name"`
+		Action   string   `json:"action"`
+		Schedule string   `json:"schedule"`
+		Command  string   `json:"command"`
+		Paths    []string `json:"paths"`
+		Keep     int      `json:"keep"`
+	}
+	scheduleTaskTool := mcp.NewTool("schedule_task",
+		mcp.WithDescription("Run an action on a VM on a recurring schedule, e.g. a nightly sync_from_vm of build artifacts, a weekly apt upgrade or a periodic backup of its data. Tasks are kept across server restarts; a task whose VM is not running is skipped"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("action",
+			mcp.Required(),
+			mcp.Description("What to run: 'exec' (the command in the VM), 'apt_upgrade' (upgrade the VM's packages), 'sync_from_vm' (sync the project from the VM to the host) or 'backup' (as backup_vm_data)"),
+			mcp.Enum(schedule.Actions...)),
+		mcp.WithString("schedule",
+			mcp.Required(),
+			mcp.Description("When to run, in the server's local time: a cron expression such as '0 2 * * *' (2am daily) or '0 6 * * 1' (Mondays at 6am), @hourly, @daily, @weekly or @monthly, or an interval such as '@every 6h'")),
+		mcp.WithString("command",
+			mcp.Description("Command to run in the VM, for exec tasks")),
+		mcp.WithArray("paths",
+			mcp.Description("Presets or absolute guest paths to back up, for backup tasks (default: ['project', 'home'])"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("keep",
+			mcp.Description(fmt.Sprintf("Number of backups to keep, for backup tasks (default: %d)", vm.DefaultBackupRetention))),
+	)
+	mcp_pkg.RegisterTypedTool(srv, scheduleTaskTool, func(ctx context.Context, request mcp.CallToolRequest, args ScheduleTaskArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" || args.Action == "" || args.Schedule == "" {
+			return mcp.NewToolResultError("Missing required parameter: name, action or schedule"), nil
+		}
+		if _, err := vmManager.GetVMConfig(ctx, args.Name); err != nil {
+			return mcp.NewToolResultErrorf("VM '%s' does not exist: %v", args.Name, err), nil
+		}
+		if args.Action == schedule.ActionBackup {
+			if _, err := vm.BackupPaths(args.Paths, "/vagrant"); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		task := schedule.Task{
+			VMName:   args.Name,
+			Action:   args.Action,
+			Schedule: args.Schedule,
+			Command:  args.Command,
+			Paths:    args.Paths,
+			Keep:     args.Keep,
+		}
+		if command := scheduledCommand(task); command != "" {
+			if err := exec.GlobalPolicy.Check(task.VMName, command, ""); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		task, err := scheduler.Add(task)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to schedule task: %v", err), nil
+		}
+		jsonResponse, err := json.Marshal(task)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// List scheduled tasks tool
+	type ListScheduledTasksArgs struct {
+		Name string `json:"name"`
+	}
+	listScheduledTasksTool := mcp.NewTool("list_scheduled_tasks",
+		mcp.WithDescription("List the scheduled tasks, soonest first, with their next run and the outcome of their last run"),
+		mcp.WithString("name",
+			mcp.Description("Only list the tasks of this VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, listScheduledTasksTool, func(ctx context.Context, request mcp.CallToolRequest, args ListScheduledTasksArgs) (*mcp.CallToolResult, error) {
+		jsonResponse, err := json.Marshal(ScheduledTasksResponse{Tasks: scheduler.Tasks(args.Name)})
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Unschedule task tool
+	type UnscheduleTaskArgs struct {
+		ID string `json:"id"`
+	}
+	unscheduleTaskTool := mcp.NewTool("unschedule_task",
+		mcp.WithDescription("Stop running a scheduled task"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("ID of the scheduled task")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, unscheduleTaskTool, func(ctx context.Context, request mcp.CallToolRequest, args UnscheduleTaskArgs) (*mcp.CallToolResult, error) {
+		if args.ID == "" {
+			return mcp.NewToolResultError("Missing required parameter: id"), nil
+		}
+		task, err := scheduler.Remove(args.ID)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to unschedule task: %v", err), nil
+		}
+		jsonResponse, err := json.Marshal(task)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Schedule tools registered")
+}
+
+// ScheduledTaskRunner returns the function that performs the actions of scheduled tasks in a
+// server mode. Tasks whose tool the mode leaves out fail, so tasks saved under a more capable
+// mode do not outlive it.
+func ScheduledTaskRunner(vmManager core.VMManager, syncEngine core.SyncEngine, executor *exec.Executor, mode string) schedule.RunFunc {
+	return func(ctx context.Context, task schedule.Task) (string, error) {
+		if tool := scheduledTaskTool(task.Action); tool != "" && !modeAllowsTool(mode, tool) {
+			return "", errors.New(errors.CodePermissionDenied, fmt.Sprintf("the %s server mode leaves out %s, which %s tasks run", mode, tool, task.Action))
+		}
+		// The policy may have changed since the task was scheduled; a blocked run fails
+		if command := scheduledCommand(task); command != "" {
+			if err := exec.GlobalPolicy.Check(task.VMName, command, ""); err != nil {
+				return "", err
+			}
+		}
+		// Exec runs hold the executor like the commands of tools do, so a run gets no longer
+		// than they get
+		timeout := exec.DefaultMaxTimeout
+		if executor != nil && executor.MaxTimeout() > 0 {
+			timeout = executor.MaxTimeout()
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		state, err := vmManager.GetVMState(ctx, task.VMName)
+		if err != nil {
+			return "", fmt.Errorf("failed to get VM state: %w", err)
+		}
+		if state != core.Running {
+			return "", fmt.Errorf("%w: VM '%s' is %s", schedule.ErrSkipped, task.VMName, state)
+		}
+
+		switch task.Action {
+		case schedule.ActionExec, schedule.ActionAptUpgrade:
+			command := scheduledCommand(task)
+			result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: task.VMName, PolicyCommand: command}, nil)
+			if err != nil {
+				return "", err
+			}
+			output := strings.TrimSpace(result.Stdout + result.Stderr)
+			if result.ExitCode != 0 {
+				return output, fmt.Errorf("command exited with code %d", result.ExitCode)
+			}
+			return output, nil
+		case schedule.ActionSyncFromVM:
+			// Nobody is there to approve a scheduled sync, so one that needs approval fails
+			// rather than mirroring the deletions onto the host
+			if details := syncDeletionApprovalDetails(ctx, syncEngine, vmManager, task.VMName, "from_vm"); details != nil {
+				return "", errors.New(errors.CodePermissionDenied, scheduledSyncApprovalMessage(details))
+			}
+			result, err := syncEngine.SyncFromVM(ctx, task.VMName, "")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("synced %d files in %dms", len(result.SyncedFiles), result.SyncTimeMs), nil
+		case schedule.ActionBackup:
+			config, err := vmManager.GetVMConfig(ctx, task.VMName)
+			if err != nil {
+				return "", fmt.Errorf("failed to get VM config: %w", err)
+			}
+			paths, err := vm.BackupPaths(task.Paths, guestProjectRoot(config))
+			if err != nil {
+				return "", err
+			}
+			sshArgs, err := vmSSHArgs(ctx, vmManager, task.VMName)
+			if err != nil {
+				return "", err
+			}
+			keep := task.Keep
+			if keep == 0 {
+				keep = vm.DefaultBackupRetention
+			}
+			backup, removed, err := vm.CreateBackup(ctx, vmManager.GetBaseDir(), task.VMName, sshArgs, paths, keep)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("backup %s of %d bytes, %d old backups removed", backup.ID, backup.SizeBytes, len(removed)), nil
+		}
+		return "", fmt.Errorf("unknown action %q", task.Action)
+	}
+}
+
+// scheduledSyncApprovalMessage explains why a scheduled sync needs approval, from the details
+// of syncDeletionApprovalDetails
+func scheduledSyncApprovalMessage(details map[string]interface{}) string {
+	if reason, ok := details["error"].(string); ok {
+		return fmt.Sprintf("sync from VM needs approval and was not run: %s", reason)
+	}
+	return fmt.Sprintf("sync from VM needs approval and was not run: it would delete %v host files, more than the threshold of %v; run sync_from_vm to approve it", details["deletions"], details["threshold"])
+}
+
+// scheduledTaskTool returns the tool that performs the action of a task when it is called
+// directly
+func scheduledTaskTool(action string) string {
+	switch action {
+	case schedule.ActionExec, schedule.ActionAptUpgrade:
+		return "exec_in_vm"
+	case schedule.ActionSyncFromVM:
+		return "sync_from_vm"
+	case schedule.ActionBackup:
+		return "backup_vm_data"
+	}
+	return ""
+}
+
+// scheduledCommand returns the guest command a task runs, or an empty string for the actions
+// that run none
+func scheduledCommand(task schedule.Task) string {
+	switch task.Action {
+	case schedule.ActionExec:
+		return task.Command
+	case schedule.ActionAptUpgrade:
+		return aptUpgradeCommand
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/schedule"
+	"github.com/vagrant-mcp/server/internal/sync"
+	"github.com/vagrant-mcp/server/internal/vm"
+)
+
+func TestScheduledTaskRunnerChecksPolicy(t *testing.T) {
+	engine, err := exec.NewPolicyEngine(exec.PolicyConfig{CommandPolicy: exec.CommandPolicy{Sudo: exec.SudoDeny}})
+	if err != nil {
+		t.Fatalf("Failed to create policy engine: %v", err)
+	}
+	previous := exec.GlobalPolicy
+	exec.GlobalPolicy = engine
+	defer func() { exec.GlobalPolicy = previous }()
+
+	// The check comes before the VM is looked up, so the runner needs no VM manager
+	run := ScheduledTaskRunner(nil, nil, nil, ModeFull)
+	for _, task := range []schedule.Task{
+		{VMName: "web", Action: schedule.ActionExec, Command: "sudo make install"},
+		{VMName: "web", Action: schedule.ActionAptUpgrade},
+	} {
+		if _, err := run(context.Background(), task); !errors.Is(err, errors.CodePermissionDenied) {
+			t.Errorf("Expected the %s task to be blocked by the exec policy but got %v", task.Action, err)
+		}
+	}
+}
+
+func TestScheduledSyncFromVMNeedsApproval(t *testing.T) {
+	previous := approval.GlobalGate
+	approval.GlobalGate = approval.NewGate([]string{approval.OperationSyncDeletions}, 0, 0, nil)
+	defer func() { approval.GlobalGate = previous }()
+
+	ctx := context.Background()
+	baseDir := filepath.Join(t.TempDir(), "vms")
+	fake := cmdexec.NewFakeVagrant()
+	manager, err := vm.NewManagerWithRunner(baseDir, fake)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	projectPath := t.TempDir()
+	hostFile := filepath.Join(projectPath, "main.go")
+	if err := os.WriteFile(hostFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+	if err := manager.CreateVM(ctx, "sched-vm", projectPath, core.VMConfig{Name: "sched-vm", Box: "ubuntu/jammy64"}); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+	fake.SetState(filepath.Join(baseDir, "sched-vm"), cmdexec.FakeStateRunning)
+	engine, err := sync.NewEngine()
+	if err != nil {
+		t.Fatalf("Failed to create sync engine: %v", err)
+	}
+
+	// The guest holds no files, so mirroring it would delete the project's file
+	run := ScheduledTaskRunner(&exec.VMManagerAdapter{Real: manager}, &exec.SyncEngineAdapter{Real: engine}, nil, ModeFull)
+	_, err = run(ctx, schedule.Task{VMName: "sched-vm", Action: schedule.ActionSyncFromVM})
+	if !errors.Is(err, errors.CodePermissionDenied) || !strings.Contains(err.Error(), "needs approval") {
+		t.Errorf("Expected the scheduled sync to need approval but got %v", err)
+	}
+	if _, err := os.Stat(hostFile); err != nil {
+		t.Errorf("Expected the host file to be kept but got %v", err)
+	}
+}
+
+func TestScheduledTasksDoNotRunInReadOnlyMode(t *testing.T) {
+	// A task saved while the server ran in full mode
+	stateFile := filepath.Join(t.TempDir(), schedule.StateFile)
+	tasks := []schedule.Task{{ID: "task-1", VMName: "web", Action: schedule.ActionExec, Schedule: "@every 1h", Command: "make clean", NextRun: time.Now().Add(-time.Minute)}}
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		t.Fatalf("Failed to marshal tasks: %v", err)
+	}
+	if err := os.WriteFile(stateFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	scheduler := schedule.NewScheduler(nil)
+	if err := scheduler.Open(stateFile); err != nil {
+		t.Fatalf("Failed to open state file: %v", err)
+	}
+
+	// The mode check comes before the VM is looked up, so the runner needs no VM manager
+	ran := scheduler.RunDue(context.Background(), ScheduledTaskRunner(nil, nil, nil, ModeReadOnly))
+	if len(ran) != 1 {
+		t.Fatalf("Expected the due task to be attempted but got %v", ran)
+	}
+	lastRun := scheduler.Tasks("web")[0].LastRun
+	if lastRun == nil || !strings.Contains(lastRun.Error, "read_only server mode leaves out exec_in_vm") {
+		t.Errorf("Expected the exec task to fail in read_only mode but got %+v", lastRun)
+	}
+
+	for _, action := range []string{schedule.ActionAptUpgrade, schedule.ActionSyncFromVM, schedule.ActionBackup} {
+		run := ScheduledTaskRunner(nil, nil, nil, ModeReadOnly)
+		if _, err := run(context.Background(), schedule.Task{VMName: "web", Action: action}); !errors.Is(err, errors.CodePermissionDenied) {
+			t.Errorf("Expected the %s task to fail in read_only mode but got %v", action, err)
+		}
+	}
+}
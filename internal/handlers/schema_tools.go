@@ -0,0 +1,203 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/preflight"
+	"github.com/vagrant-mcp/server/internal/process"
+	"github.com/vagrant-mcp/server/internal/project"
+	"github.com/vagrant-mcp/server/internal/schedule"
+	"github.com/vagrant-mcp/server/internal/schema"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// DescribeToolOutputResponse is the result of describe_tool_output without a tool
+type DescribeToolOutputResponse struct {
+	SchemaVersion string          `json:"schema_version"`
+	Tools         []schema.Output `json:"tools"`
+	Count         int             `json:"count"`
+}
+
+// registerToolOutputs records what every tool returns. Failures are always plain-text error
+// results, so only successful results are described.
+func registerToolOutputs(registry *schema.Registry) {
+	// VM tools
+	registry.Register("create_dev_vm", CreateVMResponse{})
+	registry.RegisterText("ensure_dev_vm", "A message saying whether the VM was created, started, resumed or already running")
+	registry.Register("connect_vms", ConnectVMsResponse{})
+	registry.Register("set_vm_resources", SetVMResourcesResponse{})
+	registry.Register("resize_vm_disk", ResizeVMDiskResponse{})
+	registry.Register("provision_vm", vm.ProvisionResult{})
+	registry.Register("diagnose_provision_failure", DiagnoseProvisionResponse{})
+	registry.Register("configure_firewall", ConfigureFirewallResponse{})
+	registry.Register("configure_dotfiles", ConfigureDotfilesResponse{})
+	registry.Register("set_vagrantfile_snippets", SetSnippetsResponse{})
+	registry.Register("adopt_existing_vm", AdoptVMResponse{})
+	registry.RegisterTextOr("destroy_dev_vm", "A message saying the VM was destroyed and which directories were preserved", ApprovalRequiredResponse{})
+	registry.Register("get_vm_status", VMStatus{}, VMStatusListResponse{})
+	registry.Register("get_vm_info", vm.VMInfo{})
+	registry.Register("set_vm_ttl", SetVMTTLResponse{})
+	registry.Register("get_ssh_info", SSHInfoResponse{})
+	registry.Register("get_vm_operation_log", OperationLogResponse{})
+	registry.Register("lint_vagrantfile", LintVagrantfileResponse{})
+	registry.Register("get_boot_report", BootReportResponse{})
+	registry.Register("list_port_profiles", PortProfilesResponse{})
+	registry.Register("detect_project", project.Detection{})
+
+	// Sync tools
+	registry.Register("configure_sync", ConfigureSyncResponse{})
+	registry.Register("preflight_check", preflight.Report{})
+	registry.Register("verify_sync", VerifySyncResponse{})
+	registry.Register("sync_to_vm", SyncResponse{}, ApprovalRequiredResponse{})
+	registry.Register("sync_from_vm", SyncResponse{}, ApprovalRequiredResponse{})
+	registry.Register("upload_to_vm", UploadResponse{})
+	registry.Register("sync_status", SyncStatusResponse{})
+	registry.Register("resolve_sync_conflicts", ResolveConflictResponse{})
+	registry.Register("set_conflict_policy", SetConflictPolicyResponse{})
+	registry.Register("search_code", SearchCodeResponse{})
+	registry.Register("suggest_exclude_patterns", SuggestExcludesResponse{})
+	registry.Register("bootstrap_project_files", BootstrapFilesResponse{})
+
+	// Exec tools
+	registry.Register("exec_in_vm", ExecResponse{}, ExecDryRunResponse{})
+	registry.Register("exec_with_sync", ExecResponse{})
+	registry.Register("run_background_task", BackgroundTaskResponse{})
+	registry.Register("run_script_in_vm", RunScriptResponse{})
+	registry.Register("run_tests", RunTestsResponse{}, ApprovalRequiredResponse{})
+	registry.Register("get_artifact", GetArtifactResponse{})
+
+	// Environment tools
+	registry.Register("setup_dev_environment", SetupDevEnvironmentResponse{})
+	registry.Register("install_dev_tools", InstallDevToolsResponse{})
+	registry.Register("set_runtime_version", SetRuntimeVersionResponse{})
+	registry.Register("configure_shell", ConfigureShellResponse{})
+	registry.Register("bake_base_image", BakeBaseImageResponse{})
+	registry.Register("package_cache", PackageCacheResponse{})
+	registry.Register("list_all_vagrant_environments", ListEnvironmentsResponse{})
+	registry.Register("cleanup_orphans", CleanupOrphansResponse{}, ApprovalRequiredResponse{})
+
+	// Guest tools
+	registry.Register("query_vm_journal", QueryJournalResponse{})
+	registry.Register("open_vm_shell", OpenShellResponse{})
+	registry.Register("send_to_shell", SendToShellResponse{})
+	registry.Register("read_shell_output", ReadShellOutputResponse{})
+	registry.Register("close_vm_shell", CloseShellResponse{})
+	registry.Register("start_background_process", process.Process{})
+	registry.Register("list_background_processes", ListProcessesResponse{})
+	registry.Register("tail_background_process_log", ProcessLogResponse{})
+	registry.Register("stop_background_process", StopProcessResponse{})
+	registry.Register("open_tunnel", OpenTunnelResponse{})
+	registry.Register("list_tunnels", ListTunnelsResponse{})
+	registry.Register("close_tunnel", CloseTunnelResponse{})
+	registry.Register("start_dev_server", StartDevServerResponse{})
+	registry.Register("list_containers", ListContainersResponse{})
+	registry.Register("compose_up", ComposeResponse{})
+	registry.Register("compose_down", ComposeResponse{})
+	registry.Register("container_logs", ContainerLogsResponse{})
+	registry.Register("create_database", CreateDatabaseResponse{})
+	registry.Register("run_sql", RunSQLResponse{})
+	registry.Register("dump_database", DumpDatabaseResponse{})
+	registry.Register("create_vm_user", CreateUserResponse{})
+	registry.Register("add_authorized_key", AddAuthorizedKeyResponse{})
+	registry.Register("rotate_vagrant_key", RotateKeyResponse{})
+	registry.Register("find_files", FindFilesResponse{})
+	registry.Register("search_vm", SearchVMResponse{})
+	registry.Register("find_symbol", FindSymbolResponse{})
+	registry.Register("write_vm_file", WriteFileResponse{})
+	registry.Register("patch_vm_file", WriteFileResponse{})
+	registry.Register("analyze_disk_usage", AnalyzeDiskUsageResponse{})
+	registry.Register("cleanup_vm", CleanupVMResponse{})
+	registry.Register("backup_vm_data", BackupVMDataResponse{})
+	registry.Register("restore_vm_data", RestoreVMDataResponse{})
+	registry.Register("schedule_task", schedule.Task{})
+	registry.Register("list_scheduled_tasks", ScheduledTasksResponse{})
+	registry.Register("unschedule_task", schedule.Task{})
+	registry.Register("set_vm_secret", SetSecretResponse{})
+	registry.Register("list_vm_secrets", ListSecretsResponse{})
+	registry.Register("delete_vm_secret", DeleteSecretResponse{})
+	registry.Register("setup_git_access", SetupGitAccessResponse{})
+	registry.Register("check_environment", CheckEnvironmentResponse{})
+
+	// Host-wide tools
+	registry.Register("stop_all_vms", BatchResponse{}, ApprovalRequiredResponse{})
+	registry.Register("destroy_vms", BatchResponse{}, ApprovalRequiredResponse{})
+	registry.Register("sync_all", BatchResponse{})
+	registry.Register("search_boxes", SearchBoxesResponse{}, BoxVersionsResponse{})
+	registry.RegisterTextOr("approve_operation", "The result of the approved operation, as described by the output of the tool that parked it", RejectedOperationResponse{})
+	registry.Register("describe_tool_output", schema.Output{}, DescribeToolOutputResponse{})
+
+	// Tools running lifecycle hooks attach their results
+	for _, tool := range []string{"create_dev_vm", "ensure_dev_vm", "destroy_dev_vm", "sync_to_vm", "sync_from_vm"} {
+		registry.Attach(tool, HookResults{})
+	}
+}
+
+// RegisterSchemaTools records the outputs of the server's tools in registry and registers the
+// describe_tool_output tool
+func RegisterSchemaTools(srv *server.MCPServer, registry *schema.Registry) {
+	registerToolOutputs(registry)
+
+	// Describe tool output tool
+	type DescribeToolOutputArgs struct {
+		Tool string `json:"tool"`
+	}
+	describeTool := mcp.NewTool("describe_tool_output",
+		mcp.WithDescription("Describe what tools return: the JSON Schema of their results, or what their plain-text results say. Errors are always plain-text error results. Use it to know which fields to expect before calling a tool"),
+		mcp.WithString("tool",
+			mcp.Description("Tool to describe (default: every tool the server exposes)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, describeTool, func(ctx context.Context, request mcp.CallToolRequest, args DescribeToolOutputArgs) (*mcp.CallToolResult, error) {
+		var response interface{}
+		if args.Tool != "" {
+			output, ok := registry.Lookup(args.Tool)
+			if !ok {
+				return mcp.NewToolResultErrorf("No output is described for tool '%s'", args.Tool), nil
+			}
+			response = output
+		} else {
+			outputs := exposedOutputs(srv, registry)
+			response = DescribeToolOutputResponse{
+				SchemaVersion: schema.Version,
+				Tools:         outputs,
+				Count:         len(outputs),
+			}
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	log.Info().Msg("Schema tools registered")
+}
+
+// exposedOutputs returns the outputs of the tools the server lists, leaving out the tools its
+// mode removed
+func exposedOutputs(srv *server.MCPServer, registry *schema.Registry) []schema.Output {
+	outputs := registry.All()
+	names, err := registeredToolNames(srv)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list registered tools")
+		return outputs
+	}
+	exposed := make(map[string]bool, len(names))
+	for _, name := range names {
+		exposed[name] = true
+	}
+	filtered := make([]schema.Output, 0, len(outputs))
+	for _, output := range outputs {
+		if exposed[output.Tool] {
+			filtered = append(filtered, output)
+		}
+	}
+	return filtered
+}
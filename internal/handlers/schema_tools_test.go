@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/process"
+	"github.com/vagrant-mcp/server/internal/schema"
+	"github.com/vagrant-mcp/server/internal/tunnel"
+)
+
+func TestToolOutputsCoverRegisteredTools(t *testing.T) {
+	srv := server.NewMCPServer("test", "1.0")
+	NewHandlerRegistry(nil, nil, nil).RegisterAllTools(srv)
+	names, err := registeredToolNames(srv)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	registered := make(map[string]bool, len(names))
+	for _, name := range names {
+		registered[name] = true
+		output, ok := schema.GlobalRegistry.Lookup(name)
+		if !ok {
+			t.Errorf("Expected an output for tool %s", name)
+			continue
+		}
+		if output.Schema == nil && output.Text == "" {
+			t.Errorf("Expected a schema or text description for tool %s", name)
+		}
+	}
+	for _, output := range schema.GlobalRegistry.All() {
+		if !registered[output.Tool] {
+			t.Errorf("Expected output entry %s to be a registered tool", output.Tool)
+		}
+	}
+}
+
+func TestResponsesMatchToolOutputs(t *testing.T) {
+	registry := schema.NewRegistry()
+	registerToolOutputs(registry)
+	syncBefore := true
+	exitCode := 0
+
+	testCases := []struct {
+		name     string
+		tool     string
+		response interface{}
+		valid    bool
+	}{
+		{"exec", "exec_in_vm", ExecResponse{VMName: "dev", Command: "ls", Stdout: "a\n", DurationS: 0.5}, true},
+		{"exec dry run", "exec_in_vm", ExecDryRunResponse{VMName: "dev", Command: "rm -rf /", DryRun: true, Decision: exec.PolicyDecision{}}, true},
+		{"exec with sync", "exec_with_sync", ExecResponse{VMName: "dev", Command: "make", SyncBefore: &syncBefore, SyncAfter: &syncBefore}, true},
+		{"vm status", "get_vm_status", VMStatus{Name: "dev", State: core.Running}, true},
+		{"vm status list", "get_vm_status", VMStatusListResponse{}, true},
+		{"batch", "destroy_vms", BatchResponse{Operation: "destroy", Total: 0, Counts: map[string]int{}}, true},
+		{"approval required", "destroy_vms", ApprovalRequiredResponse{Status: "approval_required", ID: "abc", Kind: approval.OperationDestroyVM, Tool: "destroy_vms", ExpiresAt: time.Now()}, true},
+		{"rejected", "approve_operation", RejectedOperationResponse{Status: "rejected", Tool: "destroy_dev_vm"}, true},
+		{"process", "start_background_process", process.Process{ID: "p1", VMName: "dev", StartedAt: time.Now()}, true},
+		{"tunnels", "list_tunnels", ListTunnelsResponse{Tunnels: []tunnel.Info{{ID: "t1", VMName: "dev"}}, Count: 1}, true},
+		{"shell exited", "read_shell_output", ReadShellOutputResponse{SessionID: "s1", Exited: true, ExitCode: &exitCode}, true},
+		{"install results", "install_dev_tools", InstallDevToolsResponse{"git": newInstallResult("", nil)}, true},
+		{"database forward", "create_database", CreateDatabaseResponse{VMName: "dev", Forward: &HostForward{Type: forwardTunnel, HostPort: 5432}}, true},
+		{"wrong tool", "list_tunnels", ExecResponse{VMName: "dev"}, false},
+		{"missing field", "close_tunnel", map[string]interface{}{"status": "closed"}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, ok := registry.Lookup(tc.tool)
+			if !ok || output.Schema == nil {
+				t.Fatalf("Expected a schema for tool %s", tc.tool)
+			}
+			data, err := json.Marshal(tc.response)
+			if err != nil {
+				t.Fatalf("Failed to marshal response: %v", err)
+			}
+			err = output.Schema.Validate(data)
+			if tc.valid && err != nil {
+				t.Errorf("Expected %s to match the output of %s but got %v", data, tc.tool, err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected %s not to match the output of %s", data, tc.tool)
+			}
+		})
+	}
+}
+
+func TestDescribeToolOutputFollowsMode(t *testing.T) {
+	srv := server.NewMCPServer("test", "1.0")
+	NewHandlerRegistry(nil, nil, nil).RegisterAllTools(srv)
+	if err := applyMode(srv, ModeReadOnly); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	message := srv.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"describe_tool_output"}}`))
+	response, ok := message.(mcpgo.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("Expected a response but got %T", message)
+	}
+	result, ok := response.Result.(mcpgo.CallToolResult)
+	if !ok || result.IsError {
+		t.Fatalf("Expected a successful result but got %+v", response.Result)
+	}
+	text := extractTextContent(result.Content)
+	output, _ := schema.GlobalRegistry.Lookup("describe_tool_output")
+	if err := output.Schema.Validate([]byte(text)); err != nil {
+		t.Errorf("Expected describe_tool_output to match its own output but got %v", err)
+	}
+	var described DescribeToolOutputResponse
+	if err := json.Unmarshal([]byte(text), &described); err != nil {
+		t.Fatalf("Failed to parse result: %v", err)
+	}
+	if described.Count != len(readOnlyTools) {
+		t.Errorf("Expected the %d read_only tools but got %d", len(readOnlyTools), described.Count)
+	}
+	for _, tool := range described.Tools {
+		if tool.Tool == "destroy_dev_vm" {
+			t.Error("Expected tools removed by the mode to be left out")
+		}
+	}
+}
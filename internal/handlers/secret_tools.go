@@ -0,0 +1,271 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/events"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/secrets"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// syncedGuestRoot is where the project is synced to in the guest
+const syncedGuestRoot = "/vagrant"
+
+// SetSecretResponse is the result of set_vm_secret; values are never returned
+type SetSecretResponse struct {
+	Secret    secrets.Secret `json:"secret"`
+	GuestPath string         `json:"guest_path"`
+	Installed bool           `json:"installed"`
+	// SyncExcluded is the sync exclude pattern added for a file secret inside the project
+	SyncExcluded string `json:"sync_excluded,omitempty"`
+	Note         string `json:"note,omitempty"`
+}
+
+// ListSecretsResponse is the result of list_vm_secrets
+type ListSecretsResponse struct {
+	VMName  string           `json:"vm_name"`
+	Secrets []secrets.Secret `json:"secrets"`
+	Count   int              `json:"count"`
+}
+
+// DeleteSecretResponse is the result of delete_vm_secret
+type DeleteSecretResponse struct {
+	Secret secrets.Secret `json:"secret"`
+	Status string         `json:"status"`
+}
+
+// RegisterSecretTools registers the secret injection tools with the MCP server
+func RegisterSecretTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine core.SyncEngine, executor *exec.Executor, store *secrets.Store) {
+	// Secrets live in the guest's tmpfs, so they are installed again whenever the VM comes up
+	// and forgotten when it is destroyed
+	events.GlobalBus.Subscribe(func(event events.Event) {
+		if event.Type != events.VMStateChanged {
+			return
+		}
+		state, ok := event.Data["state"].(core.VMState)
+		if !ok {
+			return
+		}
+		switch {
+		case state == core.Running && len(store.Get(event.VMName)) > 0:
+			go func(vmName string) {
+				if err := installSecrets(context.Background(), executor, store, vmName, nil); err != nil {
+					log.Warn().Err(err).Str("vm", vmName).Msg("Failed to reinstall secrets")
+				}
+			}(event.VMName)
+		case event.Data["operation"] == "destroy":
+			store.DeleteVM(event.VMName)
+		}
+	})
+
+	// Set VM secret tool
+	type SetVMSecretArgs struct {
+		VMName string `json:"vm_name"`
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Kind   string `json:"kind"`
+		Path   string `json:"path"`
+	}
+	setSecretTool := mcp.NewTool("set_vm_secret",
+		mcp.WithDescription("Inject a secret into a VM as an environment variable for executed commands or as a file, e.g. a .env file or cloud credentials. Secrets are kept in memory and in the guest's tmpfs only: they are never written to the Vagrantfile or the VM's disk, and are excluded from sync"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Environment variable name, or the name of a file secret")),
+		mcp.WithString("value",
+			mcp.Required(),
+			mcp.Description("Secret value or file content")),
+		mcp.WithString("kind",
+			mcp.Description("'env' exports the secret to commands run in the VM; 'file' writes it to a file"),
+			mcp.Enum(secrets.KindEnv, secrets.KindFile),
+			mcp.DefaultString(secrets.KindEnv)),
+		mcp.WithString("path",
+			mcp.Description("Absolute guest path where a file secret is linked, e.g. /vagrant/.env (default: "+secrets.GuestFilesDir+"/<name>)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, setSecretTool, func(ctx context.Context, request mcp.CallToolRequest, args SetVMSecretArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		if args.Kind == "" {
+			args.Kind = secrets.KindEnv
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+
+		var previousPath string
+		for _, existing := range store.Get(args.VMName) {
+			if existing.Kind == args.Kind && existing.Name == args.Name {
+				previousPath = existing.Path
+			}
+		}
+		secret, err := store.Set(args.VMName, secrets.Secret{Name: args.Name, Kind: args.Kind, Value: args.Value, Path: args.Path})
+		if err != nil {
+			return mcp.NewToolResultErrorf("Invalid secret: %v", err), nil
+		}
+
+		response := SetSecretResponse{
+			Secret:    secret,
+			GuestPath: secret.GuestPath(),
+		}
+		if pattern := syncExcludePattern(secret.Path); pattern != "" {
+			if err := addSyncExclude(ctx, syncEngine, args.VMName, pattern); err != nil {
+				log.Warn().Err(err).Str("vm", args.VMName).Str("pattern", pattern).Msg("Failed to exclude secret from sync")
+			} else {
+				response.SyncExcluded = pattern
+			}
+		}
+		if state == core.Running {
+			var removed []string
+			if previousPath != "" && previousPath != secret.Path {
+				removed = append(removed, previousPath)
+			}
+			if err := installSecrets(ctx, executor, store, args.VMName, removed); err != nil {
+				return mcp.NewToolResultErrorf("Secret saved but not installed: %v", err), nil
+			}
+			response.Installed = true
+		} else {
+			response.Note = fmt.Sprintf("VM is %s; the secret is installed when it starts", state)
+		}
+
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// List VM secrets tool
+	type ListVMSecretsArgs struct {
+		VMName string `json:"vm_name"`
+	}
+	listSecretsTool := mcp.NewTool("list_vm_secrets",
+		mcp.WithDescription("List the secrets set for a VM. Values are never returned"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, listSecretsTool, func(ctx context.Context, request mcp.CallToolRequest, args ListVMSecretsArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		list := store.Get(args.VMName)
+		response := ListSecretsResponse{
+			VMName:  args.VMName,
+			Secrets: list,
+			Count:   len(list),
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Delete VM secret tool
+	type DeleteVMSecretArgs struct {
+		VMName string `json:"vm_name"`
+		Name   string `json:"name"`
+		Kind   string `json:"kind"`
+	}
+	deleteSecretTool := mcp.NewTool("delete_vm_secret",
+		mcp.WithDescription("Remove a secret from a VM and from its tmpfs"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the secret")),
+		mcp.WithString("kind",
+			mcp.Description("Kind of the secret"),
+			mcp.Enum(secrets.KindEnv, secrets.KindFile),
+			mcp.DefaultString(secrets.KindEnv)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, deleteSecretTool, func(ctx context.Context, request mcp.CallToolRequest, args DeleteVMSecretArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		if args.Kind == "" {
+			args.Kind = secrets.KindEnv
+		}
+		secret, ok := store.Delete(args.VMName, args.Kind, args.Name)
+		if !ok {
+			return mcp.NewToolResultErrorf("Secret '%s' of kind %s is not set for VM '%s'", args.Name, args.Kind, args.VMName), nil
+		}
+
+		response := DeleteSecretResponse{
+			Secret: secret,
+			Status: "deleted",
+		}
+		if state, err := vmManager.GetVMState(ctx, args.VMName); err == nil && state == core.Running {
+			var removed []string
+			if secret.Path != "" {
+				removed = append(removed, secret.Path)
+			}
+			if err := installSecrets(ctx, executor, store, args.VMName, removed); err != nil {
+				return mcp.NewToolResultErrorf("Secret deleted but the VM could not be updated: %v", err), nil
+			}
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	log.Info().Msg("Secret tools registered")
+}
+
+// installSecrets replaces the secrets in a running VM with the ones in the store
+func installSecrets(ctx context.Context, executor *exec.Executor, store *secrets.Store, vmName string, removedPaths []string) error {
+	sshArgs, err := executor.SSHArgs(ctx, vmName)
+	if err != nil {
+		return err
+	}
+	return secrets.Install(ctx, vmName, sshArgs, store.Get(vmName), removedPaths)
+}
+
+// syncExcludePattern returns the sync exclude pattern for a file secret linked inside the synced
+// project, or "" when the path is outside it
+func syncExcludePattern(guestPath string) string {
+	if !strings.HasPrefix(guestPath, syncedGuestRoot+"/") {
+		return ""
+	}
+	return strings.TrimPrefix(path.Clean(guestPath), syncedGuestRoot+"/")
+}
+
+// addSyncExclude adds a pattern to a VM's sync exclude patterns
+func addSyncExclude(ctx context.Context, syncEngine core.SyncEngine, vmName, pattern string) error {
+	config, err := syncEngine.GetSyncConfig(ctx, vmName)
+	if err != nil {
+		return err
+	}
+	for _, existing := range config.ExcludePatterns {
+		if existing == pattern {
+			return nil
+		}
+	}
+	config.ExcludePatterns = append(config.ExcludePatterns, pattern)
+	return syncEngine.UpdateSyncConfig(ctx, vmName, config)
+}
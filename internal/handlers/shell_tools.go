@@ -0,0 +1,269 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/secrets"
+	"github.com/vagrant-mcp/server/internal/shell"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// defaultShellReadWait is how long read_shell_output waits for output by default
+const defaultShellReadWait = 1000
+
+// OpenShellResponse is the result of open_vm_shell
+type OpenShellResponse struct {
+	SessionID string `json:"session_id"`
+	VMName    string `json:"vm_name"`
+	Output    string `json:"output"`
+	Exited    bool   `json:"exited"`
+}
+
+// SendToShellResponse is the result of send_to_shell
+type SendToShellResponse struct {
+	SessionID string `json:"session_id"`
+	SentBytes int    `json:"sent_bytes"`
+}
+
+// ReadShellOutputResponse is the result of read_shell_output
+type ReadShellOutputResponse struct {
+	SessionID string `json:"session_id"`
+	Output    string `json:"output"`
+	Exited    bool   `json:"exited"`
+	// ExitCode is set once the shell has exited
+	ExitCode     *int `json:"exit_code,omitempty"`
+	DroppedBytes int  `json:"dropped_bytes,omitempty"`
+}
+
+// CloseShellResponse is the result of close_vm_shell
+type CloseShellResponse struct {
+	SessionID string `json:"session_id"`
+	Status    string `json:"status"`
+}
+
+// shellLines keeps the input sent to each shell session since its last newline, so the exec
+// policy checks whole command lines, also when they are typed a piece at a time
+type shellLines struct {
+	mu      sync.Mutex
+	pending map[string]string
+}
+
+// check evaluates the lines input completes or continues against the exec policy and, when
+// they may be sent, keeps what follows the last newline for the next input
+func (l *shellLines) check(vmName, sessionID, input string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	text := l.pending[sessionID] + input
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		if err := exec.GlobalPolicy.Check(vmName, line, ""); err != nil {
+			return err
+		}
+	}
+	if i := strings.LastIndexAny(text, "\r\n"); i >= 0 {
+		text = text[i+1:]
+	}
+	l.pending[sessionID] = text
+	return nil
+}
+
+// forget drops the pending input of a closed session
+func (l *shellLines) forget(sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.pending, sessionID)
+}
+
+// RegisterShellTools registers the interactive shell session tools with the MCP server
+func RegisterShellTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor, sessions *shell.Manager) {
+	lines := &shellLines{pending: make(map[string]string)}
+
+	// Open VM shell tool
+	type OpenShellArgs struct {
+		VMName     string `json:"vm_name"`
+		WorkingDir string `json:"working_dir"`
+	}
+	openShellTool := mcp.NewTool("open_vm_shell",
+		mcp.WithDescription("Open a persistent interactive shell in the VM. State such as the current directory, exported variables and activated virtualenvs is kept across send_to_shell calls"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("working_dir",
+			mcp.Description("Directory to change to after the shell starts")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, openShellTool, func(ctx context.Context, request mcp.CallToolRequest, args OpenShellArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+
+		sshArgs, err := executor.SSHArgs(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get SSH configuration: %v", err), nil
+		}
+		info, err := sessions.Open(args.VMName, sshArgs)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to open shell: %v", err), nil
+		}
+		if err := sessions.Send(info.ID, secrets.SourceCommand(":")+"\n"); err != nil {
+			return mcp.NewToolResultErrorf("Failed to load secrets: %v", err), nil
+		}
+		if args.WorkingDir != "" {
+			if err := sessions.Send(info.ID, "cd "+shellquote.Quote(args.WorkingDir)+"\n"); err != nil {
+				return mcp.NewToolResultErrorf("Failed to change directory: %v", err), nil
+			}
+		}
+
+		output, err := sessions.Read(ctx, info.ID, time.Duration(defaultShellReadWait)*time.Millisecond, true)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to read shell output: %v", err), nil
+		}
+		response := OpenShellResponse{
+			SessionID: info.ID,
+			VMName:    info.VMName,
+			Output:    output.Output,
+			Exited:    output.Exited,
+		}
+		return marshalShellResponse(response)
+	})
+
+	// Send to shell tool
+	type SendToShellArgs struct {
+		SessionID string `json:"session_id"`
+		Input     string `json:"input"`
+		NoNewline bool   `json:"no_newline"`
+	}
+	sendToShellTool := mcp.NewTool("send_to_shell",
+		mcp.WithDescription("Send input to an open VM shell. Use read_shell_output to get the resulting output"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by open_vm_shell")),
+		mcp.WithString("input",
+			mcp.Required(),
+			mcp.Description("Input to send, such as a command line")),
+		mcp.WithBoolean("no_newline",
+			mcp.Description("Do not append a newline to the input (e.g., to answer a prompt character by character or send control characters)"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, sendToShellTool, func(ctx context.Context, request mcp.CallToolRequest, args SendToShellArgs) (*mcp.CallToolResult, error) {
+		if args.SessionID == "" {
+			return mcp.NewToolResultError("Missing required parameter: session_id"), nil
+		}
+		input := args.Input
+		if !args.NoNewline {
+			input += "\n"
+		}
+		info, err := sessions.Info(args.SessionID)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to send input: %v", err), nil
+		}
+		if err := lines.check(info.VMName, args.SessionID, input); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := sessions.Send(args.SessionID, input); err != nil {
+			return mcp.NewToolResultErrorf("Failed to send input: %v", err), nil
+		}
+		response := SendToShellResponse{
+			SessionID: args.SessionID,
+			SentBytes: len(input),
+		}
+		return marshalShellResponse(response)
+	})
+
+	// Read shell output tool
+	type ReadShellOutputArgs struct {
+		SessionID string  `json:"session_id"`
+		WaitMs    float64 `json:"wait_ms"`
+		Raw       bool    `json:"raw"`
+	}
+	readShellOutputTool := mcp.NewTool("read_shell_output",
+		mcp.WithDescription("Read the output an open VM shell produced since the last read"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by open_vm_shell")),
+		mcp.WithNumber("wait_ms",
+			mcp.Description("How long to wait for output when none is available yet, in milliseconds"),
+			mcp.DefaultNumber(defaultShellReadWait)),
+		mcp.WithBoolean("raw",
+			mcp.Description("Keep terminal escape sequences and carriage returns"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, readShellOutputTool, func(ctx context.Context, request mcp.CallToolRequest, args ReadShellOutputArgs) (*mcp.CallToolResult, error) {
+		if args.SessionID == "" {
+			return mcp.NewToolResultError("Missing required parameter: session_id"), nil
+		}
+		wait := defaultShellReadWait
+		if _, ok := request.GetArguments()["wait_ms"]; ok {
+			wait = int(args.WaitMs)
+		}
+		output, err := sessions.Read(ctx, args.SessionID, time.Duration(wait)*time.Millisecond, !args.Raw)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to read shell output: %v", err), nil
+		}
+		response := ReadShellOutputResponse{
+			SessionID:    args.SessionID,
+			Output:       output.Output,
+			Exited:       output.Exited,
+			DroppedBytes: output.Dropped,
+		}
+		if output.Exited {
+			response.ExitCode = &output.ExitCode
+		}
+		return marshalShellResponse(response)
+	})
+
+	// Close VM shell tool
+	type CloseShellArgs struct {
+		SessionID string `json:"session_id"`
+	}
+	closeShellTool := mcp.NewTool("close_vm_shell",
+		mcp.WithDescription("Close an open VM shell"),
+		mcp.WithString("session_id",
+			mcp.Required(),
+			mcp.Description("Session ID returned by open_vm_shell")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, closeShellTool, func(ctx context.Context, request mcp.CallToolRequest, args CloseShellArgs) (*mcp.CallToolResult, error) {
+		if args.SessionID == "" {
+			return mcp.NewToolResultError("Missing required parameter: session_id"), nil
+		}
+		if err := sessions.Close(args.SessionID); err != nil {
+			return mcp.NewToolResultErrorf("Failed to close shell: %v", err), nil
+		}
+		lines.forget(args.SessionID)
+		response := CloseShellResponse{
+			SessionID: args.SessionID,
+			Status:    "closed",
+		}
+		return marshalShellResponse(response)
+	})
+
+	log.Info().Msg("Shell tools registered")
+}
+
+// marshalShellResponse returns response as a JSON tool result
+func marshalShellResponse(response interface{}) (*mcp.CallToolResult, error) {
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		return mcp.NewToolResultError("Failed to marshal response"), nil
+	}
+	return mcp.NewToolResultText(string(jsonResponse)), nil
+}
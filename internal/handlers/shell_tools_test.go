@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/exec"
+)
+
+func TestShellLinesCheckWholeLines(t *testing.T) {
+	engine, err := exec.NewPolicyEngine(exec.PolicyConfig{CommandPolicy: exec.CommandPolicy{Deny: []string{`^rm -rf /tmp/cache$`}}})
+	if err != nil {
+		t.Fatalf("Failed to create policy engine: %v", err)
+	}
+	previous := exec.GlobalPolicy
+	exec.GlobalPolicy = engine
+	defer func() { exec.GlobalPolicy = previous }()
+
+	lines := &shellLines{pending: make(map[string]string)}
+	for _, input := range []string{"rm -rf ", "/tmp/"} {
+		if err := lines.check("dev", "s1", input); err != nil {
+			t.Fatalf("Expected the start of a line to be sent but got %v", err)
+		}
+	}
+	// The line typed a piece at a time is blocked once it is whole
+	if err := lines.check("dev", "s1", "cache\n"); err == nil {
+		t.Error("Expected the completed line to be blocked")
+	}
+	if err := lines.check("dev", "s2", "ls /tmp\nrm -rf /tmp/cache\n"); err == nil {
+		t.Error("Expected a blocked line among several to block the input")
+	}
+	if err := lines.check("dev", "s3", "ls /tmp\n"); err != nil {
+		t.Errorf("Expected an allowed line to be sent but got %v", err)
+	}
+	if pending := lines.pending["s3"]; pending != "" {
+		t.Errorf("Expected no pending input after a newline but got %q", pending)
+	}
+}
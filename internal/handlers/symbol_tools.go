@@ -0,0 +1,178 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// maxGoReferenceLookups bounds the gopls definitions whose references are looked up; a name
+// defined in many packages is better narrowed down with kind or path
+const maxGoReferenceLookups = 3
+
+// noIndexerNote explains how to get definitions when the VM has no indexer
+const noIndexerNote = "No symbol indexer found in the VM; install universal-ctags, or gopls for Go modules, to find definitions"
+
+// FindSymbolResponse is the result of find_symbol
+type FindSymbolResponse struct {
+	VMName string `json:"vm_name"`
+	Name   string `json:"name"`
+	// Indexers are the indexers found in the VM
+	Indexers    []string            `json:"indexers"`
+	Definitions []vm.SymbolLocation `json:"definitions"`
+	References  []vm.SymbolLocation `json:"references,omitempty"`
+	// Truncated is set when more definitions or references were found than max_results
+	Truncated bool     `json:"truncated"`
+	Notes     []string `json:"notes,omitempty"`
+}
+
+// RegisterSymbolTools registers the code navigation tools with the MCP server
+func RegisterSymbolTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	type FindSymbolArgs struct {
+		VMName     string  `json:"vm_name"`
+		Name       string  `json:"name"`
+		Path       string  `json:"path"`
+		Kind       string  `json:"kind"`
+		References *bool   `json:"references"`
+		MaxResults float64 `json:"max_results"`
+	}
+	findSymbolTool := mcp.NewTool("find_symbol",
+		mcp.WithDescription("Find the definitions and references of a symbol in the project with the indexers installed in the VM: gopls for Go modules and Universal Ctags for other languages, returning the file, line and kind of each. References of symbols gopls does not resolve are found with a whole-word grep"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Identifier to look up, e.g. parseConfig or UserService")),
+		mcp.WithString("path",
+			mcp.Description("Guest directory to search; relative paths start from /vagrant"),
+			mcp.DefaultString("/vagrant")),
+		mcp.WithString("kind",
+			mcp.Description("Only definitions of this kind, e.g. function, method, class or struct")),
+		mcp.WithBoolean("references",
+			mcp.Description("Also find the references of the symbol"),
+			mcp.DefaultBool(true)),
+		mcp.WithNumber("max_results",
+			mcp.Description("Maximum number of definitions and of references to return (up to 500)"),
+			mcp.DefaultNumber(vm.DefaultSymbolResults)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, findSymbolTool, func(ctx context.Context, request mcp.CallToolRequest, args FindSymbolArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or name"), nil
+		}
+		query := vm.SymbolQuery{Name: args.Name, Root: args.Path, Kind: args.Kind, MaxResults: int(args.MaxResults)}
+		if query.MaxResults <= 0 {
+			query.MaxResults = vm.DefaultSymbolResults
+		}
+		query.MaxResults = min(query.MaxResults, vm.MaxSymbolResults)
+		command, err := vm.SymbolDefinitionsCommand(query)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		execCtx := exec.ExecutionContext{VMName: args.VMName}
+		result, err := executor.ExecuteCommand(ctx, command, execCtx, nil)
+		if err != nil {
+			return commandFailedResult("Failed to look up definitions", result, err), nil
+		}
+		definitions, indexers := vm.ParseSymbolDefinitions(query, result.Stdout)
+		response := FindSymbolResponse{
+			VMName:      args.VMName,
+			Name:        args.Name,
+			Indexers:    indexers,
+			Definitions: definitions,
+		}
+		if len(indexers) == 0 {
+			response.Notes = append(response.Notes, noIndexerNote)
+		}
+
+		if args.References == nil || *args.References {
+			references, notes, err := findSymbolReferences(ctx, executor, execCtx, query, definitions)
+			if err != nil {
+				return commandFailedResult("Failed to look up references", nil, err), nil
+			}
+			response.References = references
+			response.Notes = append(response.Notes, notes...)
+		}
+		if len(response.Definitions) > query.MaxResults {
+			response.Definitions, response.Truncated = response.Definitions[:query.MaxResults], true
+		}
+		if len(response.References) > query.MaxResults {
+			response.References, response.Truncated = response.References[:query.MaxResults], true
+		}
+
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("Symbol tools registered")
+}
+
+// findSymbolReferences finds the references of a symbol: with gopls for the Go definitions it
+// found, otherwise with a whole-word grep leaving out the definitions
+func findSymbolReferences(ctx context.Context, executor *exec.Executor, execCtx exec.ExecutionContext, query vm.SymbolQuery, definitions []vm.SymbolLocation) ([]vm.SymbolLocation, []string, error) {
+	var goDefinitions []vm.SymbolLocation
+	for _, definition := range definitions {
+		if definition.Indexer == vm.IndexerGopls {
+			goDefinitions = append(goDefinitions, definition)
+		}
+	}
+
+	var notes []string
+	if len(goDefinitions) > 0 {
+		if len(goDefinitions) > maxGoReferenceLookups {
+			notes = append(notes, "Only the references of the first Go definitions were looked up; narrow the lookup with kind or path")
+			goDefinitions = goDefinitions[:maxGoReferenceLookups]
+		}
+		references := []vm.SymbolLocation{}
+		for _, definition := range goDefinitions {
+			command, err := vm.GoReferencesCommand(query, definition)
+			if err != nil {
+				return nil, nil, err
+			}
+			result, err := executor.ExecuteCommand(ctx, command, execCtx, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			if result.ExitCode != 0 {
+				notes = append(notes, fmt.Sprintf("gopls could not resolve the references of %s: %s", definition.Path, strings.TrimSpace(result.Stderr)))
+				continue
+			}
+			references = append(references, vm.ParseGoReferences(query, result.Stdout)...)
+		}
+		return references, notes, nil
+	}
+
+	grepQuery, err := vm.SymbolReferencesQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	command, err := vm.GrepCommand(grepQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := executor.ExecuteCommand(ctx, command, execCtx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	matches, _ := vm.ParseGrepOutput(result.Stdout, grepQuery.MaxResults)
+	return vm.GrepReferences(matches, definitions), notes, nil
+}
@@ -7,34 +7,85 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	mcpgo "github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
 	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/hooks"
+	"github.com/vagrant-mcp/server/internal/paging"
+	"github.com/vagrant-mcp/server/internal/preflight"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	syncmod "github.com/vagrant-mcp/server/internal/sync"
 	"github.com/vagrant-mcp/server/pkg/mcp"
 )
 
+// defaultPendingFilesLimit is the number of files sync_status lists from each pending file list
+// by default
+const defaultPendingFilesLimit = 100
+
 // RegisterSyncTools registers all sync-related tools with the MCP server
 func RegisterSyncTools(srv *server.MCPServer, syncEngine core.SyncEngine, vmManager core.VMManager) {
 	// Configure sync tool
 	configureSyncTool := mcpgo.NewTool("configure_sync",
 		mcpgo.WithDescription("Configure sync method and options"),
 		mcpgo.WithString("vm_name", mcpgo.Required(), mcpgo.Description("Name of the development VM")),
-		mcpgo.WithString("sync_type", mcpgo.Required(), mcpgo.Description("Type of sync to use (rsync, nfs, etc.)")),
+		mcpgo.WithString("sync_type", mcpgo.Required(), mcpgo.Description("Type of sync to use (rsync, nfs, smb, virtualbox, or virtiofs and 9p with the libvirt provider)")),
 		mcpgo.WithString("host_path", mcpgo.Description("Host path to sync")),
 		mcpgo.WithString("guest_path", mcpgo.Description("Guest path to sync")),
 		mcpgo.WithArray("exclude_patterns",
 			mcpgo.Description("Patterns to exclude from sync"),
 			mcpgo.Items(map[string]any{"type": "string"})),
+		mcpgo.WithBoolean("skip_preflight",
+			mcpgo.Description("Configure the sync type even when preflight_check reports failures"),
+			mcpgo.DefaultBool(false)),
+		mcpgo.WithBoolean("watch",
+			mcpgo.Description("Watch the project for changes and sync them to the VM automatically")),
+		mcpgo.WithString("watch_mode",
+			mcpgo.Description("How changes are detected: auto (fsnotify, polling once the system watch limit is reached), notify (fsnotify only) or poll"),
+			mcpgo.Enum("auto", "notify", "poll")),
+		mcpgo.WithNumber("watch_depth",
+			mcpgo.Description("Directory levels watched with fsnotify; deeper directories are polled. 0 watches every level")),
+		mcpgo.WithString("smb_username",
+			mcpgo.Description("Host user the smb sync type mounts the project as; stored next to the VM's Vagrantfile so 'vagrant up' does not prompt for it")),
+		mcpgo.WithString("smb_password",
+			mcpgo.Description("Password of smb_username")),
 	)
 
 	srv.AddTool(configureSyncTool, handleConfigureSync(vmManager, syncEngine))
 
+	// Preflight check tool
+	preflightCheckTool := mcpgo.NewTool("preflight_check",
+		mcpgo.WithDescription("Check the host and guest prerequisites of a sync type (NFS server and exports permissions, SMB availability, VirtualBox guest additions) and report what to fix before 'vagrant up' fails"),
+		mcpgo.WithString("sync_type", mcpgo.Required(), mcpgo.Description("Sync type to check: rsync, nfs, smb, virtualbox, virtiofs or 9p")),
+		mcpgo.WithString("vm_name", mcpgo.Description("VM to check; a running VM also gets its guest checked")),
+	)
+
+	srv.AddTool(preflightCheckTool, handlePreflightCheck(vmManager, preflight.NewChecker()))
+
+	// Verify sync tool
+	verifySyncTool := mcpgo.NewTool("verify_sync",
+		mcpgo.WithDescription("Compare file checksums of the project on the host with the synced copy in the VM and report missing, extra and differing files"),
+		mcpgo.WithString("vm_name", mcpgo.Required(), mcpgo.Description("Name of the development VM")),
+		mcpgo.WithArray("exclude_patterns",
+			mcpgo.Description("Additional patterns to leave out of the comparison; the VM's sync exclude patterns always apply"),
+			mcpgo.Items(map[string]any{"type": "string"})),
+		mcpgo.WithNumber("max_paths", mcpgo.Description("Maximum number of paths listed per category"),
+			mcpgo.DefaultNumber(100)),
+	)
+
+	srv.AddTool(verifySyncTool, handleVerifySync(syncEngine, vmManager))
+
 	// Sync to VM tool
 	syncToVMTool := mcpgo.NewTool("sync_to_vm",
-		mcpgo.WithDescription("Sync files from host to VM"),
+		mcpgo.WithDescription("Sync files from host to VM. The progress of the copy is sent as progress notifications when the request carries a progress token, and is in sync_status while the sync runs"),
 		mcpgo.WithString("vm_name", mcpgo.Required(), mcpgo.Description("Name of the development VM")),
 	)
 
@@ -42,7 +93,7 @@ func RegisterSyncTools(srv *server.MCPServer, syncEngine core.SyncEngine, vmMana
 
 	// Sync from VM tool
 	syncFromVMTool := mcpgo.NewTool("sync_from_vm",
-		mcpgo.WithDescription("Sync files from VM to host"),
+		mcpgo.WithDescription("Sync files from VM to host. The progress of the copy is sent as progress notifications when the request carries a progress token, and is in sync_status while the sync runs"),
 		mcpgo.WithString("vm_name", mcpgo.Required(), mcpgo.Description("Name of the development VM")),
 	)
 
@@ -64,21 +115,57 @@ func RegisterSyncTools(srv *server.MCPServer, syncEngine core.SyncEngine, vmMana
 	syncStatusTool := mcpgo.NewTool("sync_status",
 		mcpgo.WithDescription("Get sync status information"),
 		mcpgo.WithString("vm_name", mcpgo.Required(), mcpgo.Description("Name of the development VM")),
+		mcpgo.WithNumber("journal_limit", mcpgo.Description("Number of recent sync journal entries to include"),
+			mcpgo.DefaultNumber(20)),
+		mcpgo.WithNumber("limit", mcpgo.Description(fmt.Sprintf("Maximum number of files to list from each pending file list (at most %d)", paging.MaxLimit)),
+			mcpgo.DefaultNumber(defaultPendingFilesLimit)),
+		mcpgo.WithString("cursor", mcpgo.Description("Continue the pending file lists after this cursor (the 'next_cursor' of a previous page)")),
+		mcpgo.WithString("path_prefix", mcpgo.Description("Only list pending files whose path starts with this prefix")),
+		mcpgo.WithArray("fields", mcpgo.Description("Top-level fields to return (default: all), e.g. ['sync_status', 'pending_upload']"),
+			mcpgo.Items(map[string]any{"type": "string"})),
+		mcpgo.WithBoolean("scan_vm",
+			mcpgo.Description("Scan the running VM for files changed since the last sync and list them as pending download"),
+			mcpgo.DefaultBool(false)),
 	)
 
 	srv.AddTool(syncStatusTool, handleSyncStatus(syncEngine, vmManager))
 
 	// Resolve sync conflicts tool
 	resolveSyncConflictTool := mcpgo.NewTool("resolve_sync_conflicts",
-		mcpgo.WithDescription("Handle sync conflicts interactively"),
+		mcpgo.WithDescription("Handle sync conflicts interactively. Review the queued conflicts and their diffs in the devvm://conflicts/{vmName} resource"),
 		mcpgo.WithString("vm_name", mcpgo.Required(), mcpgo.Description("Name of the development VM")),
 		mcpgo.WithString("path", mcpgo.Required(), mcpgo.Description("Path of the conflicted file")),
 		mcpgo.WithString("resolution", mcpgo.Required(),
-			mcpgo.Description("Resolution method: 'use_host', 'use_vm', 'merge', 'keep_both'")),
+			mcpgo.Description("Resolution method: 'use_host', 'use_vm', 'merge', 'keep_both', or 'use_merged' to replace both versions with merged_content")),
+		mcpgo.WithString("merged_content",
+			mcpgo.Description("Full content of the resolved file; required for the 'use_merged' resolution")),
 	)
 
 	srv.AddTool(resolveSyncConflictTool, handleResolveSyncConflict(vmManager, syncEngine))
 
+	// Conflict policy tool
+	conflictPolicyTool := mcpgo.NewTool("set_conflict_policy",
+		mcpgo.WithDescription("Set how sync conflicts are resolved automatically for a VM, optionally per path pattern"),
+		mcpgo.WithString("vm_name", mcpgo.Required(), mcpgo.Description("Name of the development VM")),
+		mcpgo.WithString("policy", mcpgo.Required(),
+			mcpgo.Description("Default policy: 'prefer_host', 'prefer_vm', 'prefer_newest', or 'always_manual'")),
+		mcpgo.WithArray("overrides",
+			mcpgo.Description("Per-path overrides as objects with 'pattern' (glob or directory) and 'policy'; the first match wins"),
+			mcpgo.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"pattern": map[string]any{"type": "string"},
+					"policy":  map[string]any{"type": "string"},
+				},
+				"required": []string{"pattern", "policy"},
+			})),
+		mcpgo.WithString("conflict_style",
+			mcpgo.Description("Markers 'merge' resolutions leave in hunks they cannot merge: 'merge' shows the host and VM versions, 'diff3' also the version of the last sync, 'zdiff3' is diff3 without the lines common to both sides (default: keep the current style)"),
+			mcpgo.Enum("merge", "diff3", "zdiff3")),
+	)
+
+	srv.AddTool(conflictPolicyTool, handleSetConflictPolicy(syncEngine))
+
 	// Semantic search tool
 	semanticSearchTool := mcpgo.NewTool("search_code",
 		mcpgo.WithDescription("Search code semantically in the VM"),
@@ -86,16 +173,219 @@ func RegisterSyncTools(srv *server.MCPServer, syncEngine core.SyncEngine, vmMana
 		mcpgo.WithString("query", mcpgo.Required(), mcpgo.Description("Search query")),
 		mcpgo.WithString("search_type", mcpgo.Description("Type of search: 'semantic', 'exact', or 'fuzzy'"),
 			mcpgo.DefaultString("semantic")),
-		mcpgo.WithNumber("max_results", mcpgo.Description("Maximum number of results to return"),
+		mcpgo.WithNumber("max_results", mcpgo.Description("Maximum number of matches to search for"),
 			mcpgo.DefaultNumber(20)),
 		mcpgo.WithBoolean("case_sensitive", mcpgo.Description("Whether the search is case sensitive")),
+		mcpgo.WithString("path_prefix", mcpgo.Description("Only return matches in files whose path starts with this prefix")),
+		mcpgo.WithNumber("limit", mcpgo.Description("Maximum number of matches in a page (default: max_results)")),
+		mcpgo.WithString("cursor", mcpgo.Description("Continue the results after this cursor (the 'next_cursor' of a previous page)")),
+		mcpgo.WithString("sort", mcpgo.Description("Field to sort the matches by: 'path', 'line' or 'match_type'; prefix with '-' for descending order (default: relevance)")),
+		mcpgo.WithArray("fields", mcpgo.Description("Fields to keep for each match (default: all), e.g. ['path', 'line']"),
+			mcpgo.Items(map[string]any{"type": "string"})),
 	)
 
 	srv.AddTool(semanticSearchTool, handleSearchCode(vmManager, syncEngine))
 
+	// Exclude suggestion tool
+	suggestExcludesTool := mcpgo.NewTool("suggest_exclude_patterns",
+		mcpgo.WithDescription("Scan a project for VCS metadata, dependency, build output and cache directories and recommend sync exclude patterns with the estimated size reduction"),
+		mcpgo.WithString("vm_name", mcpgo.Description("Name of the development VM whose project should be scanned")),
+		mcpgo.WithString("project_path", mcpgo.Description("Host project path to scan (defaults to the VM's project path)")),
+		mcpgo.WithNumber("large_dir_threshold_mb", mcpgo.Description("Size in MB above which other directories are reported as large"),
+			mcpgo.DefaultNumber(50)),
+	)
+
+	srv.AddTool(suggestExcludesTool, handleSuggestExcludePatterns(syncEngine, vmManager))
+
+	// Project file bootstrap tool
+	bootstrapFilesTool := mcpgo.NewTool("bootstrap_project_files",
+		mcpgo.WithDescription("Write .gitattributes, .editorconfig and a .vagrant-mcp.yaml skeleton into the project so line endings and formatting match how files are synced to the VM"),
+		mcpgo.WithString("vm_name", mcpgo.Description("Name of the development VM whose sync settings should be used")),
+		mcpgo.WithString("project_path", mcpgo.Description("Host project path to write into (defaults to the VM's project path)")),
+		mcpgo.WithArray("files", mcpgo.Description("Files to write (default: all)"),
+			mcpgo.Items(map[string]any{"type": "string", "enum": syncmod.ProjectFiles})),
+		mcpgo.WithBoolean("overwrite", mcpgo.Description("Replace files that already exist"),
+			mcpgo.DefaultBool(false)),
+		mcpgo.WithBoolean("dry_run", mcpgo.Description("Return the generated content without writing anything"),
+			mcpgo.DefaultBool(false)),
+	)
+
+	srv.AddTool(bootstrapFilesTool, handleBootstrapProjectFiles(syncEngine, vmManager))
+
 	log.Info().Msg("Sync tools registered")
 }
 
+// ConfigureSyncResponse is the result of configure_sync
+type ConfigureSyncResponse struct {
+	VMName          string             `json:"vm_name"`
+	State           core.VMState       `json:"state"`
+	SyncType        string             `json:"sync_type"`
+	HostPath        string             `json:"host_path"`
+	GuestPath       string             `json:"guest_path"`
+	ExcludePatterns []string           `json:"exclude_patterns"`
+	Watcher         *core.WatcherStats `json:"watcher"`
+	// ReloadRequired is set when the VM runs with another synced folder than the new sync type
+	ReloadRequired bool   `json:"reload_required,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// VerifySyncResponse is the result of verify_sync
+type VerifySyncResponse struct {
+	VMName     string `json:"vm_name"`
+	HostPath   string `json:"host_path"`
+	GuestPath  string `json:"guest_path"`
+	InSync     bool   `json:"in_sync"`
+	HostFiles  int    `json:"host_files"`
+	GuestFiles int    `json:"guest_files"`
+	Matching   int    `json:"matching"`
+	// The counts cover every path; the lists are limited to max_paths
+	MissingCount    int      `json:"missing_count"`
+	ExtraCount      int      `json:"extra_count"`
+	DifferingCount  int      `json:"differing_count"`
+	Missing         []string `json:"missing"`
+	Extra           []string `json:"extra"`
+	Differing       []string `json:"differing"`
+	ExcludePatterns []string `json:"exclude_patterns"`
+	DurationMs      int64    `json:"duration_ms"`
+}
+
+// SyncStatusResponse is the result of sync_status
+type SyncStatusResponse struct {
+	VMName     string          `json:"vm_name"`
+	VMState    core.VMState    `json:"vm_state"`
+	SyncStatus core.SyncStatus `json:"sync_status"`
+	// SyncNeeded is set when host files changed since they were last synced to the VM
+	SyncNeeded        bool                `json:"sync_needed"`
+	LastSyncTime      time.Time           `json:"last_sync_time"`
+	InProgress        bool                `json:"in_progress"`
+	Conflicts         []core.SyncConflict `json:"conflicts"`
+	SynchronizedFiles int                 `json:"synchronized_files"`
+	TotalSyncs        int                 `json:"total_syncs"`
+	TotalFilesSynced  int                 `json:"total_files_synced"`
+	TotalSyncTimeMs   int                 `json:"total_sync_time_ms"`
+	ConflictPolicy    string              `json:"conflict_policy"`
+	// SyncMethod is how the project reaches the VM, and PerformanceNote how that method performs
+	SyncMethod      string `json:"sync_method"`
+	PerformanceNote string `json:"performance_note,omitempty"`
+	// MountError is set when the synced folder of a running VM using a mounted sync method is
+	// not mounted or does not respond
+	MountError string                  `json:"mount_error,omitempty"`
+	Journal    []core.SyncJournalEntry `json:"journal"`
+	Watcher    *core.WatcherStats      `json:"watcher"`
+	// PendingUpload and PendingDownload describe the pages of the pending file lists in
+	// SyncStatus
+	PendingUpload   paging.Page `json:"pending_upload"`
+	PendingDownload paging.Page `json:"pending_download"`
+}
+
+// ResolveConflictResponse is the result of resolve_sync_conflicts
+type ResolveConflictResponse struct {
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	VMName     string `json:"vm_name"`
+	Path       string `json:"path"`
+	Resolution string `json:"resolution"`
+}
+
+// SearchCodeResponse is the result of search_code
+type SearchCodeResponse struct {
+	Status     string              `json:"status"`
+	VMName     string              `json:"vm_name"`
+	Query      string              `json:"query"`
+	SearchType string              `json:"search_type"`
+	Results    []core.SearchResult `json:"results"`
+	Total      int                 `json:"total"`
+	Page       paging.Page         `json:"page"`
+}
+
+// UploadResponse is the result of upload_to_vm
+type UploadResponse struct {
+	Status      string `json:"status"`
+	VMName      string `json:"vm_name"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	UploadTime  string `json:"upload_time"`
+}
+
+// SuggestExcludesResponse is the result of suggest_exclude_patterns
+type SuggestExcludesResponse struct {
+	VMName              string                      `json:"vm_name"`
+	ProjectPath         string                      `json:"project_path"`
+	TotalSizeBytes      int64                       `json:"total_size_bytes"`
+	TotalFiles          int                         `json:"total_files"`
+	Suggestions         []syncmod.ExcludeSuggestion `json:"suggestions"`
+	RecommendedPatterns []string                    `json:"recommended_patterns"`
+	// NewPatterns are the recommended patterns that are not configured yet
+	NewPatterns               []string                 `json:"new_patterns"`
+	CurrentPatterns           []string                 `json:"current_patterns"`
+	ExcludedSizeBytes         int64                    `json:"excluded_size_bytes"`
+	RemainingSizeBytes        int64                    `json:"remaining_size_bytes"`
+	EstimatedReductionPercent float64                  `json:"estimated_reduction_percent"`
+	LargeDirectories          []syncmod.LargeDirectory `json:"large_directories"`
+}
+
+// BootstrapFilesResponse is the result of bootstrap_project_files
+type BootstrapFilesResponse struct {
+	VMName      string                      `json:"vm_name"`
+	ProjectPath string                      `json:"project_path"`
+	DryRun      bool                        `json:"dry_run"`
+	Files       []syncmod.ProjectFileResult `json:"files"`
+}
+
+// SetConflictPolicyResponse is the result of set_conflict_policy
+type SetConflictPolicyResponse struct {
+	VMName         string                        `json:"vm_name"`
+	ConflictPolicy string                        `json:"conflict_policy"`
+	Overrides      []core.ConflictPolicyOverride `json:"overrides"`
+	ConflictStyle  string                        `json:"conflict_style,omitempty"`
+}
+
+// handlePreflightCheck handles the preflight_check tool
+func handlePreflightCheck(manager core.VMManager, checker *preflight.Checker) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		syncType, err := request.RequireString("sync_type")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Missing or invalid 'sync_type' parameter: %v", err)), nil
+		}
+		vmName := request.GetString("vm_name", "")
+
+		var config *core.VMConfig
+		state := core.NotCreated
+		if vmName != "" {
+			vmConfig, err := manager.GetVMConfig(ctx, vmName)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get VM config: %v", err)), nil
+			}
+			config = &vmConfig
+			if state, err = manager.GetVMState(ctx, vmName); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get VM state: %v", err)), nil
+			}
+		}
+
+		report := checker.CheckHost(syncType, config)
+		if state == core.Running {
+			output, err := runGuestProbe(ctx, manager, vmName)
+			if err != nil {
+				log.Warn().Err(err).Str("vm", vmName).Msg("Failed to probe guest for preflight checks")
+			} else {
+				preflight.CheckGuest(&report, output, checker.VirtualBoxVersion())
+			}
+		}
+
+		jsonData, err := json.Marshal(report)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+}
+
+// runGuestProbe runs the preflight guest probe in a running VM
+func runGuestProbe(ctx context.Context, manager core.VMManager, vmName string) (string, error) {
+	stdout, _, _, err := manager.ExecuteCommand(ctx, vmName, preflight.GuestProbeCommand, nil, "")
+	return stdout, err
+}
+
 // handleConfigureSync handles the configure_sync tool
 func handleConfigureSync(manager core.VMManager, syncEngine core.SyncEngine) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
@@ -112,6 +402,15 @@ func handleConfigureSync(manager core.VMManager, syncEngine core.SyncEngine) ser
 		hostPath := request.GetString("host_path", "")
 		guestPath := request.GetString("guest_path", "")
 
+		watchMode := request.GetString("watch_mode", "")
+		if err := syncmod.ValidateWatchMode(syncmod.WatchMode(watchMode)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		watchDepth := int(request.GetFloat("watch_depth", 0))
+		if watchDepth < 0 {
+			return mcp.NewToolResultError("watch_depth must not be negative"), nil
+		}
+
 		// Get exclude patterns
 		var excludePatterns []string
 		if patterns, ok := request.GetArguments()["exclude_patterns"].([]interface{}); ok {
@@ -134,31 +433,88 @@ func handleConfigureSync(manager core.VMManager, syncEngine core.SyncEngine) ser
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to get VM config: %v", err)), nil
 		}
 
-		// Update sync config
-		config.SyncType = syncType
-		if hostPath != "" {
-			config.HostPath = hostPath
-		}
-		if guestPath != "" {
-			config.GuestPath = guestPath
+		// Refuse sync types whose prerequisites are missing rather than failing in vagrant up
+		if syncType != config.SyncType && !request.GetBool("skip_preflight", false) {
+			report := preflight.NewChecker().CheckHost(syncType, &config)
+			if !report.OK {
+				reportJSON, _ := json.Marshal(report)
+				return mcp.NewToolResultError(fmt.Sprintf("Preflight checks for %s sync failed; fix them or pass skip_preflight: %s", syncType, reportJSON)), nil
+			}
 		}
-		if len(excludePatterns) > 0 {
-			config.SyncExcludePatterns = excludePatterns
+
+		// SMB credentials are stored by the VM manager rather than in the VM config
+		if username := request.GetString("smb_username", ""); username != "" {
+			storer, ok := manager.(interface {
+				SetSMBCredentials(name, username, password string) error
+			})
+			if !ok {
+				return mcp.NewToolResultError("SMB credentials cannot be stored for this VM manager"), nil
+			}
+			if err := storer.SetSMBCredentials(vmName, username, request.GetString("smb_password", "")); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to store SMB credentials: %v", err)), nil
+			}
 		}
 
-		// Update config file
-		if err := manager.UpdateVMConfig(ctx, vmName, config); err != nil {
+		// Update sync config
+		syncTypeChanged := syncType != config.SyncType
+		config, err = updateVMConfig(ctx, manager, vmName, func(config *core.VMConfig) error {
+			config.SyncType = syncType
+			if hostPath != "" {
+				config.HostPath = hostPath
+			}
+			if guestPath != "" {
+				config.GuestPath = guestPath
+			}
+			if len(excludePatterns) > 0 {
+				config.SyncExcludePatterns = excludePatterns
+			}
+			return nil
+		})
+		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to update VM config: %v", err)), nil
 		}
 
+		// Update the exclude patterns of syncs and the file watcher when any of their options
+		// were given
+		arguments := request.GetArguments()
+		_, hasWatch := arguments["watch"]
+		_, hasDepth := arguments["watch_depth"]
+		if hasWatch || hasDepth || watchMode != "" || len(excludePatterns) > 0 || syncTypeChanged {
+			syncConfig, err := syncEngine.GetSyncConfig(ctx, vmName)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get sync config: %v", err)), nil
+			}
+			if hasWatch {
+				syncConfig.WatchEnabled = request.GetBool("watch", false)
+			}
+			if watchMode != "" {
+				syncConfig.WatchMode = watchMode
+			}
+			if hasDepth {
+				syncConfig.WatchDepth = watchDepth
+			}
+			if len(excludePatterns) > 0 {
+				syncConfig.ExcludePatterns = excludePatterns
+			}
+			syncConfig.Method = core.SyncMethod(syncType)
+			if err := syncEngine.UpdateSyncConfig(ctx, vmName, syncConfig); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to update sync config: %v", err)), nil
+			}
+		}
+
 		// Return result using MCP-Go's helper
-		result := map[string]interface{}{
-			"vm_name":          vmName,
-			"state":            state,
-			"sync_type":        syncType,
-			"host_path":        config.HostPath,
-			"guest_path":       config.GuestPath,
-			"exclude_patterns": config.SyncExcludePatterns,
+		result := ConfigureSyncResponse{
+			VMName:          vmName,
+			State:           state,
+			SyncType:        syncType,
+			HostPath:        config.HostPath,
+			GuestPath:       config.GuestPath,
+			ExcludePatterns: config.SyncExcludePatterns,
+			Watcher:         watcherStats(ctx, syncEngine, vmName),
+		}
+		if syncTypeChanged && state == core.Running {
+			result.ReloadRequired = true
+			result.Message = fmt.Sprintf("The Vagrantfile now uses a %s synced folder; reload the VM to mount it", syncType)
 		}
 
 		jsonData, err := json.Marshal(result)
@@ -170,6 +526,130 @@ func handleConfigureSync(manager core.VMManager, syncEngine core.SyncEngine) ser
 	}
 }
 
+// handleVerifySync handles the verify_sync tool
+func handleVerifySync(syncEngine core.SyncEngine, vmManager core.VMManager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		vmName, err := request.RequireString("vm_name")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Missing or invalid 'vm_name' parameter: %v", err)), nil
+		}
+		maxPaths := int(request.GetFloat("max_paths", 100))
+
+		state, err := vmManager.GetVMState(ctx, vmName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", vmName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", vmName, state)), nil
+		}
+		config, err := vmManager.GetVMConfig(ctx, vmName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get VM config: %v", err)), nil
+		}
+
+		hostPath := config.HostPath
+		if hostPath == "" {
+			hostPath = config.ProjectPath
+		}
+		guestPath := config.GuestPath
+		if guestPath == "" {
+			guestPath = "/vagrant"
+		}
+
+		// Compare what sync would transfer: the VM's and the sync engine's excludes apply
+		patterns := syncedExcludePatterns(ctx, syncEngine, vmName, config)
+		if extra, ok := request.GetArguments()["exclude_patterns"].([]interface{}); ok {
+			for _, p := range extra {
+				if pattern, ok := p.(string); ok {
+					patterns = append(patterns, pattern)
+				}
+			}
+		}
+
+		startTime := time.Now()
+		hostHashes, err := syncmod.HashTree(hostPath, patterns)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to hash host files: %v", err)), nil
+		}
+
+		output, stderr, _, err := vmManager.ExecuteCommand(ctx, vmName, syncmod.GuestHashCommand(guestPath, patterns), nil, "")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to hash files in VM: %v: %s", err, strings.TrimSpace(stderr))), nil
+		}
+		guestHashes := syncmod.ParseGuestHashes(output, patterns)
+
+		report := syncmod.CompareHashes(hostHashes, guestHashes)
+		result := VerifySyncResponse{
+			VMName:          vmName,
+			HostPath:        hostPath,
+			GuestPath:       guestPath,
+			InSync:          report.InSync,
+			HostFiles:       len(hostHashes),
+			GuestFiles:      len(guestHashes),
+			Matching:        report.Matching,
+			MissingCount:    len(report.Missing),
+			ExtraCount:      len(report.Extra),
+			DifferingCount:  len(report.Differing),
+			Missing:         limitPaths(report.Missing, maxPaths),
+			Extra:           limitPaths(report.Extra, maxPaths),
+			Differing:       limitPaths(report.Differing, maxPaths),
+			ExcludePatterns: patterns,
+			DurationMs:      time.Since(startTime).Milliseconds(),
+		}
+
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+}
+
+// syncedExcludePatterns returns the patterns of the files sync leaves out of a VM's project
+func syncedExcludePatterns(ctx context.Context, syncEngine core.SyncEngine, vmName string, config core.VMConfig) []string {
+	patterns := append([]string{}, syncmod.DefaultVerifyExcludes...)
+	patterns = append(patterns, config.SyncExcludePatterns...)
+	if syncConfig, err := syncEngine.GetSyncConfig(ctx, vmName); err == nil {
+		patterns = append(patterns, syncConfig.ExcludePatterns...)
+	}
+	return patterns
+}
+
+// scanGuestChanges lists the files changed in a running VM since its last sync and records
+// them with the sync engine as pending download
+func scanGuestChanges(ctx context.Context, syncEngine core.SyncEngine, vmManager core.VMManager, vmName string, since time.Time) error {
+	recorder, ok := syncEngine.(interface {
+		RecordGuestChanges(ctx context.Context, vmName string, paths []string, scannedAt time.Time) error
+	})
+	if !ok {
+		return fmt.Errorf("the sync engine does not track changes in the VM")
+	}
+	config, err := vmManager.GetVMConfig(ctx, vmName)
+	if err != nil {
+		return fmt.Errorf("failed to get VM config: %w", err)
+	}
+	guestPath := config.GuestPath
+	if guestPath == "" {
+		guestPath = "/vagrant"
+	}
+	patterns := syncedExcludePatterns(ctx, syncEngine, vmName, config)
+
+	scannedAt := time.Now()
+	output, stderr, _, err := vmManager.ExecuteCommand(ctx, vmName, syncmod.GuestChangesCommand(guestPath, patterns, since), nil, "")
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+	}
+	return recorder.RecordGuestChanges(ctx, vmName, syncmod.ParseGuestChanges(output, patterns), scannedAt)
+}
+
+// limitPaths returns at most max paths; a negative max lists them all
+func limitPaths(paths []string, max int) []string {
+	if max >= 0 && len(paths) > max {
+		return paths[:max]
+	}
+	return paths
+}
+
 // handleSyncToVM handles the sync_to_vm tool
 func handleSyncToVM(syncEngine core.SyncEngine, vmManager core.VMManager) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -189,14 +669,31 @@ func handleSyncToVM(syncEngine core.SyncEngine, vmManager core.VMManager) server
 		}
 
 		// Perform sync to VM
-		result, err := syncEngine.SyncToVM(ctx, vmName, "")
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Sync to VM failed: %v", err)), nil
+		runSync := func(ctx context.Context) (*mcp.CallToolResult, error) {
+			target := hookTarget(ctx, vmManager, vmName)
+			hookResults, err := hooks.GlobalRunner.Run(ctx, hooks.PreSync, target)
+			if err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("Sync was not run: %v", err), hookResults), nil
+			}
+			stopProgress := notifySyncProgress(ctx, request, syncEngine, vmName)
+			result, err := syncEngine.SyncToVM(ctx, vmName, "")
+			stopProgress()
+			if err != nil {
+				return withHookResults(mcp.NewToolResultError(fmt.Sprintf("Sync to VM failed: %v", err)), hookResults), nil
+			}
+			postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostSync, target)
+
+			// Create standardized response using helper
+			response := responseHelper.CreateSyncResponse(vmName, result.SyncedFiles, result.SyncTimeMs, "sync_to_vm")
+			response.ReportArtifact = result.ReportArtifact
+			toolResult, err := responseHelper.MarshalSuccessResponse(response)
+			return withHookResults(toolResult, append(hookResults, postResults...)), err
 		}
 
-		// Create standardized response using helper
-		response := responseHelper.CreateSyncResponse(vmName, result.SyncedFiles, result.SyncTimeMs, "sync_to_vm")
-		return responseHelper.MarshalSuccessResponse(response)
+		if details := syncDeletionApprovalDetails(ctx, syncEngine, vmManager, vmName, "to_vm"); details != nil {
+			return approvalRequiredResult(approval.GlobalGate, approval.OperationSyncDeletions, "sync_to_vm", vmName, details, runSync)
+		}
+		return runSync(ctx)
 	}
 }
 
@@ -219,14 +716,31 @@ func handleSyncFromVM(syncEngine core.SyncEngine, vmManager core.VMManager) serv
 		}
 
 		// Perform sync from VM
-		result, err := syncEngine.SyncFromVM(ctx, vmName, "")
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Sync from VM failed: %v", err)), nil
+		runSync := func(ctx context.Context) (*mcp.CallToolResult, error) {
+			target := hookTarget(ctx, vmManager, vmName)
+			hookResults, err := hooks.GlobalRunner.Run(ctx, hooks.PreSync, target)
+			if err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("Sync was not run: %v", err), hookResults), nil
+			}
+			stopProgress := notifySyncProgress(ctx, request, syncEngine, vmName)
+			result, err := syncEngine.SyncFromVM(ctx, vmName, "")
+			stopProgress()
+			if err != nil {
+				return withHookResults(mcp.NewToolResultError(fmt.Sprintf("Sync from VM failed: %v", err)), hookResults), nil
+			}
+			postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostSync, target)
+
+			// Create standardized response using helper
+			response := responseHelper.CreateSyncResponse(vmName, result.SyncedFiles, result.SyncTimeMs, "sync_from_vm")
+			response.ReportArtifact = result.ReportArtifact
+			toolResult, err := responseHelper.MarshalSuccessResponse(response)
+			return withHookResults(toolResult, append(hookResults, postResults...)), err
 		}
 
-		// Create standardized response using helper
-		response := responseHelper.CreateSyncResponse(vmName, result.SyncedFiles, result.SyncTimeMs, "sync_from_vm")
-		return responseHelper.MarshalSuccessResponse(response)
+		if details := syncDeletionApprovalDetails(ctx, syncEngine, vmManager, vmName, "from_vm"); details != nil {
+			return approvalRequiredResult(approval.GlobalGate, approval.OperationSyncDeletions, "sync_from_vm", vmName, details, runSync)
+		}
+		return runSync(ctx)
 	}
 }
 
@@ -249,22 +763,81 @@ func handleSyncStatus(syncEngine core.SyncEngine, vmManager core.VMManager) serv
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to get sync status: %v", err)), nil
 		}
+		if request.GetBool("scan_vm", false) {
+			if state != core.Running {
+				return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", vmName, state)), nil
+			}
+			if err := scanGuestChanges(ctx, syncEngine, vmManager, vmName, status.LastSyncTime); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to scan the VM for changes: %v", err)), nil
+			}
+			if status, err = syncEngine.GetSyncStatus(ctx, vmName); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get sync status: %v", err)), nil
+			}
+		}
+		syncNeeded := len(status.FilesPendingUpload) > 0 || status.PendingUploadTruncated
+
+		// Include the most recent journal entries and the active conflict policy
+		journal, err := syncEngine.GetSyncJournal(ctx, vmName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get sync journal: %v", err)), nil
+		}
+		if limit := int(request.GetFloat("journal_limit", 20)); limit >= 0 && len(journal) > limit {
+			journal = journal[len(journal)-limit:]
+		}
+
+		// Page the pending file lists, which can hold a whole project
+		fields := request.GetStringSlice("fields", nil)
+		if err := paging.CheckFields(SyncStatusResponse{}, fields); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid 'fields' parameter: %v", err)), nil
+		}
+		pathPrefix := request.GetString("path_prefix", "")
+		params := paging.Params{
+			Limit:  int(request.GetFloat("limit", defaultPendingFilesLimit)),
+			Cursor: request.GetString("cursor", ""),
+		}
+		var uploadPage, downloadPage paging.Page
+		status.FilesPendingUpload, uploadPage, err = pagePendingFiles(status.FilesPendingUpload, pathPrefix, params)
+		if err == nil {
+			status.FilesPendingDownload, downloadPage, err = pagePendingFiles(status.FilesPendingDownload, pathPrefix, params)
+		}
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid paging parameters: %v", err)), nil
+		}
+		conflictPolicy, syncMethod, mountError := "", syncmod.SyncMethod(""), ""
+		if syncConfig, err := syncEngine.GetSyncConfig(ctx, vmName); err == nil {
+			conflictPolicy = syncConfig.ConflictPolicy
+			syncMethod = syncmod.SyncMethod(syncConfig.Method)
+		}
+		if checker, ok := vmManager.(interface{ CheckSyncMount(string) error }); ok && syncMethod.Mounted() && state == core.Running {
+			if err := checker.CheckSyncMount(vmName); err != nil {
+				mountError = err.Error()
+			}
+		}
 
 		// Return status using MCP-Go's JSON result
-		result := map[string]interface{}{
-			"vm_name":            vmName,
-			"vm_state":           state,
-			"sync_status":        status,
-			"last_sync_time":     status.LastSyncTime,
-			"in_progress":        status.InProgress,
-			"conflicts":          status.Conflicts,
-			"synchronized_files": status.SynchronizedFiles,
-			"total_syncs":        status.TotalSyncs,
-			"total_files_synced": status.TotalFilesSynced,
-			"total_sync_time_ms": status.TotalSyncTimeMs,
+		result := SyncStatusResponse{
+			VMName:            vmName,
+			VMState:           state,
+			SyncStatus:        status,
+			SyncNeeded:        syncNeeded,
+			LastSyncTime:      status.LastSyncTime,
+			InProgress:        status.InProgress,
+			Conflicts:         status.Conflicts,
+			SynchronizedFiles: status.SynchronizedFiles,
+			TotalSyncs:        status.TotalSyncs,
+			TotalFilesSynced:  status.TotalFilesSynced,
+			TotalSyncTimeMs:   status.TotalSyncTimeMs,
+			ConflictPolicy:    conflictPolicy,
+			SyncMethod:        string(syncMethod),
+			PerformanceNote:   syncMethod.PerformanceNote(),
+			MountError:        mountError,
+			Journal:           journal,
+			Watcher:           watcherStats(ctx, syncEngine, vmName),
+			PendingUpload:     uploadPage,
+			PendingDownload:   downloadPage,
 		}
 
-		jsonData, err := json.Marshal(result)
+		jsonData, err := paging.Marshal(result, "", fields)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
 		}
@@ -272,6 +845,76 @@ func handleSyncStatus(syncEngine core.SyncEngine, vmManager core.VMManager) serv
 	}
 }
 
+// syncProgressInterval is the least time between two progress notifications of a sync
+const syncProgressInterval = time.Second
+
+// notifySyncProgress sends the progress reports of the syncs of a VM to the client as progress
+// notifications until the returned function is called. Requests without a progress token get
+// none.
+func notifySyncProgress(ctx context.Context, request mcp.CallToolRequest, syncEngine core.SyncEngine, vmName string) func() {
+	engine, ok := syncEngine.(interface {
+		OnSyncProgress(vmName string, listener func(core.SyncProgress)) func()
+	})
+	if !ok || request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return func() {}
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return func() {}
+	}
+
+	token := request.Params.Meta.ProgressToken
+	var mu sync.Mutex
+	var sentAt time.Time
+	sentPercent := -1
+	return engine.OnSyncProgress(vmName, func(progress core.SyncProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Progress has to increase, and rsync reports it several times a second
+		if progress.Percent <= sentPercent || progress.Percent < 100 && time.Since(sentAt) < syncProgressInterval {
+			return
+		}
+		sentAt, sentPercent = time.Now(), progress.Percent
+		if err := mcpServer.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      progress.Percent,
+			"total":         100,
+			"message":       describeSyncProgress(progress),
+		}); err != nil {
+			log.Debug().Err(err).Msg("Failed to send sync progress notification")
+		}
+	})
+}
+
+// describeSyncProgress describes a sync progress report for a progress notification
+func describeSyncProgress(progress core.SyncProgress) string {
+	message := fmt.Sprintf("%d%% synced, %d bytes at %s", progress.Percent, progress.BytesTransferred, progress.Rate)
+	if progress.TotalFiles > 0 {
+		message += fmt.Sprintf(", %d of %d files checked", progress.TotalFiles-progress.FilesToCheck, progress.TotalFiles)
+	}
+	if progress.Percent < 100 {
+		message += fmt.Sprintf(", about %s left", time.Duration(progress.ETASeconds)*time.Second)
+	}
+	return message
+}
+
+// watcherStats returns the statistics of a VM's file watcher, or nil when it is not watching
+// or the sync engine does not report them
+func watcherStats(ctx context.Context, syncEngine core.SyncEngine, vmName string) *core.WatcherStats {
+	reporter, ok := syncEngine.(interface {
+		GetWatcherStats(ctx context.Context, vmName string) (*core.WatcherStats, error)
+	})
+	if !ok {
+		return nil
+	}
+	stats, err := reporter.GetWatcherStats(ctx, vmName)
+	if err != nil {
+		log.Debug().Err(err).Str("vm", vmName).Msg("Failed to get watcher stats")
+		return nil
+	}
+	return stats
+}
+
 // handleResolveSyncConflict handles the resolve_sync_conflicts tool
 func handleResolveSyncConflict(manager core.VMManager, syncEngine core.SyncEngine) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -291,6 +934,15 @@ func handleResolveSyncConflict(manager core.VMManager, syncEngine core.SyncEngin
 			return mcp.NewToolResultError(fmt.Sprintf("Missing or invalid 'resolution' parameter: %v", err)), nil
 		}
 
+		// The merged content may legitimately be empty, so its presence is checked rather than its value
+		mergedContent, hasMergedContent := request.GetArguments()["merged_content"].(string)
+		if resolution == syncmod.ResolutionUseMerged && !hasMergedContent {
+			return mcp.NewToolResultError("The 'use_merged' resolution requires the 'merged_content' parameter"), nil
+		}
+		if resolution != syncmod.ResolutionUseMerged && hasMergedContent {
+			return mcp.NewToolResultError("The 'merged_content' parameter is only used by the 'use_merged' resolution"), nil
+		}
+
 		// Check VM state
 		state, err := manager.GetVMState(ctx, vmName)
 		if err != nil {
@@ -302,18 +954,28 @@ func handleResolveSyncConflict(manager core.VMManager, syncEngine core.SyncEngin
 		}
 
 		// Resolve conflict
-		err = syncEngine.ResolveSyncConflict(ctx, vmName, path, resolution)
+		if resolution == syncmod.ResolutionUseMerged {
+			resolver, ok := syncEngine.(interface {
+				ResolveSyncConflictWithContent(ctx context.Context, vmName string, path string, content string) error
+			})
+			if !ok {
+				return mcp.NewToolResultError("The sync engine does not accept merged content"), nil
+			}
+			err = resolver.ResolveSyncConflictWithContent(ctx, vmName, path, mergedContent)
+		} else {
+			err = syncEngine.ResolveSyncConflict(ctx, vmName, path, resolution)
+		}
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to resolve conflict: %v", err)), nil
 		}
 
 		// Return success response
-		result := map[string]interface{}{
-			"status":     "success",
-			"message":    fmt.Sprintf("Conflict for path '%s' resolved using '%s' strategy", path, resolution),
-			"vm_name":    vmName,
-			"path":       path,
-			"resolution": resolution,
+		result := ResolveConflictResponse{
+			Status:     "success",
+			Message:    fmt.Sprintf("Conflict for path '%s' resolved using '%s' strategy", path, resolution),
+			VMName:     vmName,
+			Path:       path,
+			Resolution: resolution,
 		}
 
 		// Convert to JSON
@@ -326,6 +988,19 @@ func handleResolveSyncConflict(manager core.VMManager, syncEngine core.SyncEngin
 	}
 }
 
+// pagePendingFiles returns the page params select of a pending file list, sorted by path and
+// filtered by a path prefix
+func pagePendingFiles(files []string, pathPrefix string, params paging.Params) ([]string, paging.Page, error) {
+	selected := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.HasPrefix(file, pathPrefix) {
+			selected = append(selected, file)
+		}
+	}
+	sort.Strings(selected)
+	return paging.Apply(selected, params, "path_prefix="+pathPrefix)
+}
+
 // handleSearchCode handles the search_code tool
 func handleSearchCode(manager core.VMManager, syncEngine core.SyncEngine) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -363,7 +1038,7 @@ func handleSearchCode(manager core.VMManager, syncEngine core.SyncEngine) server
 		}
 
 		// Perform search based on type
-		var results interface{}
+		var results []core.SearchResult
 		var searchErr error
 
 		switch searchType {
@@ -381,18 +1056,42 @@ func handleSearchCode(manager core.VMManager, syncEngine core.SyncEngine) server
 			return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", searchErr)), nil
 		}
 
+		// Filter and page the matches
+		pathPrefix := request.GetString("path_prefix", "")
+		if pathPrefix != "" {
+			filtered := results[:0]
+			for _, result := range results {
+				if strings.HasPrefix(result.Path, pathPrefix) {
+					filtered = append(filtered, result)
+				}
+			}
+			results = filtered
+		}
+		params := paging.Params{
+			Limit:  int(request.GetFloat("limit", float64(maxResults))),
+			Cursor: request.GetString("cursor", ""),
+			Sort:   request.GetString("sort", ""),
+			Fields: request.GetStringSlice("fields", nil),
+		}
+		scope := fmt.Sprintf("%s\x00%s\x00%t\x00%d\x00%s", query, searchType, caseSensitive, maxResults, pathPrefix)
+		results, page, err := paging.Apply(results, params, scope)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid paging parameters: %v", err)), nil
+		}
+
 		// Format the response
-		response := map[string]interface{}{
-			"status":      "success",
-			"vm_name":     vmName,
-			"query":       query,
-			"search_type": searchType,
-			"results":     results,
-			"total":       len(results.([]interface{})),
+		response := SearchCodeResponse{
+			Status:     "success",
+			VMName:     vmName,
+			Query:      query,
+			SearchType: searchType,
+			Results:    results,
+			Total:      page.Total,
+			Page:       page,
 		}
 
 		// Convert to JSON
-		jsonData, err := json.Marshal(response)
+		jsonData, err := paging.Marshal(response, "results", params.Fields)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal results: %v", err)), nil
 		}
@@ -440,12 +1139,12 @@ func handleUploadToVM(manager core.VMManager) server.ToolHandlerFunc {
 		}
 
 		// Format the response
-		response := map[string]interface{}{
-			"status":      "success",
-			"vm_name":     vmName,
-			"source":      source,
-			"destination": destination,
-			"upload_time": time.Now().Format(time.RFC3339),
+		response := UploadResponse{
+			Status:      "success",
+			VMName:      vmName,
+			Source:      source,
+			Destination: destination,
+			UploadTime:  time.Now().Format(time.RFC3339),
 		}
 
 		// Convert to JSON
@@ -457,3 +1156,329 @@ func handleUploadToVM(manager core.VMManager) server.ToolHandlerFunc {
 		return mcp.NewToolResultText(string(jsonData)), nil
 	}
 }
+
+// handleSuggestExcludePatterns handles the suggest_exclude_patterns tool
+func handleSuggestExcludePatterns(syncEngine core.SyncEngine, vmManager core.VMManager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		vmName := request.GetString("vm_name", "")
+		projectPath := request.GetString("project_path", "")
+		thresholdMB := request.GetFloat("large_dir_threshold_mb", 50)
+
+		if vmName == "" && projectPath == "" {
+			return mcp.NewToolResultError("Either 'vm_name' or 'project_path' must be provided"), nil
+		}
+
+		// Resolve project path and current excludes from the VM when given
+		var existing []string
+		if vmName != "" {
+			if syncConfig, err := syncEngine.GetSyncConfig(ctx, vmName); err == nil {
+				existing = syncConfig.ExcludePatterns
+				if projectPath == "" {
+					projectPath = syncConfig.ProjectPath
+				}
+			} else {
+				vmConfig, err := vmManager.GetVMConfig(ctx, vmName)
+				if err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("Failed to get VM configuration: %v", err)), nil
+				}
+				existing = vmConfig.SyncExcludePatterns
+				if projectPath == "" {
+					projectPath = vmConfig.ProjectPath
+				}
+			}
+		}
+
+		if projectPath == "" {
+			return mcp.NewToolResultError(fmt.Sprintf("No project path configured for VM '%s'", vmName)), nil
+		}
+
+		report, err := syncmod.SuggestExcludePatterns(projectPath, existing, int64(thresholdMB*1024*1024))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to scan project: %v", err)), nil
+		}
+
+		// Only recommend patterns that are not configured yet
+		newPatterns := []string{}
+		for _, suggestion := range report.Suggestions {
+			if !suggestion.AlreadyConfigured {
+				newPatterns = append(newPatterns, suggestion.Pattern)
+			}
+		}
+
+		result := SuggestExcludesResponse{
+			VMName:                    vmName,
+			ProjectPath:               report.ProjectPath,
+			TotalSizeBytes:            report.TotalSizeBytes,
+			TotalFiles:                report.TotalFiles,
+			Suggestions:               report.Suggestions,
+			RecommendedPatterns:       report.RecommendedPatterns,
+			NewPatterns:               newPatterns,
+			CurrentPatterns:           existing,
+			ExcludedSizeBytes:         report.ExcludedSizeBytes,
+			RemainingSizeBytes:        report.RemainingSizeBytes,
+			EstimatedReductionPercent: report.EstimatedReductionPercent,
+			LargeDirectories:          report.LargeDirectories,
+		}
+
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+}
+
+// handleBootstrapProjectFiles handles the bootstrap_project_files tool
+func handleBootstrapProjectFiles(syncEngine core.SyncEngine, vmManager core.VMManager) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		vmName := request.GetString("vm_name", "")
+		projectPath := request.GetString("project_path", "")
+		overwrite := request.GetBool("overwrite", false)
+		dryRun := request.GetBool("dry_run", false)
+		files := request.GetStringSlice("files", nil)
+
+		if vmName == "" && projectPath == "" {
+			return mcp.NewToolResultError("Either 'vm_name' or 'project_path' must be provided"), nil
+		}
+
+		var vmConfig core.VMConfig
+		var syncConfig core.SyncConfig
+		if vmName != "" {
+			var err error
+			vmConfig, err = vmManager.GetVMConfig(ctx, vmName)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to get VM configuration: %v", err)), nil
+			}
+			if config, err := syncEngine.GetSyncConfig(ctx, vmName); err == nil {
+				syncConfig = config
+			} else {
+				syncConfig = core.SyncConfig{
+					VMName:          vmName,
+					ProjectPath:     vmConfig.ProjectPath,
+					Method:          core.SyncMethod(vmConfig.SyncType),
+					ExcludePatterns: vmConfig.SyncExcludePatterns,
+				}
+			}
+			if projectPath == "" {
+				projectPath = syncConfig.ProjectPath
+			}
+			if projectPath == "" {
+				projectPath = vmConfig.ProjectPath
+			}
+		}
+
+		if projectPath == "" {
+			return mcp.NewToolResultError(fmt.Sprintf("No project path configured for VM '%s'", vmName)), nil
+		}
+
+		results, err := syncmod.BootstrapProjectFiles(projectPath, files, vmConfig, syncConfig, overwrite, dryRun)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to bootstrap project files: %v", err)), nil
+		}
+
+		result := BootstrapFilesResponse{
+			VMName:      vmName,
+			ProjectPath: projectPath,
+			DryRun:      dryRun,
+			Files:       results,
+		}
+
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+}
+
+// syncDeletionApprovalDetails returns approval details when a sync would delete more files
+// than the approval threshold allows, or nil when the sync may proceed
+func syncDeletionApprovalDetails(ctx context.Context, syncEngine core.SyncEngine, vmManager core.VMManager, vmName, direction string) map[string]interface{} {
+	gate := approval.GlobalGate
+	if !gate.Requires(approval.OperationSyncDeletions) {
+		return nil
+	}
+
+	deletions, err := estimateSyncDeletions(ctx, syncEngine, vmManager, vmName, direction)
+	if err != nil {
+		// Without an estimate the sync could delete anything, so ask for approval
+		log.Warn().Err(err).Str("vm", vmName).Msg("Failed to estimate sync deletions")
+		return map[string]interface{}{
+			"direction": direction,
+			"error":     fmt.Sprintf("could not estimate deletions: %v", err),
+		}
+	}
+	if !gate.RequiresForDeletions(len(deletions)) {
+		return nil
+	}
+
+	sample := deletions
+	if len(sample) > 20 {
+		sample = sample[:20]
+	}
+	return map[string]interface{}{
+		"direction":      direction,
+		"deletions":      len(deletions),
+		"threshold":      gate.DeleteThreshold(),
+		"sample_deleted": sample,
+	}
+}
+
+// estimateSyncDeletions lists the files a mirroring sync would delete on the destination side
+func estimateSyncDeletions(ctx context.Context, syncEngine core.SyncEngine, vmManager core.VMManager, vmName, direction string) ([]string, error) {
+	vmConfig, err := vmManager.GetVMConfig(ctx, vmName)
+	if err != nil {
+		return nil, err
+	}
+
+	projectPath := vmConfig.ProjectPath
+	excludes := vmConfig.SyncExcludePatterns
+	if syncConfig, err := syncEngine.GetSyncConfig(ctx, vmName); err == nil {
+		if syncConfig.ProjectPath != "" {
+			projectPath = syncConfig.ProjectPath
+		}
+		if len(syncConfig.ExcludePatterns) > 0 {
+			excludes = syncConfig.ExcludePatterns
+		}
+	}
+	guestPath := vmConfig.GuestPath
+	if guestPath == "" {
+		guestPath = "/vagrant"
+	}
+
+	hostFiles, err := listHostFiles(projectPath, excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, _, exitCode, err := vmManager.ExecuteCommand(ctx, vmName, fmt.Sprintf("find %s -type f -printf '%%P\\n'", shellquote.Quote(guestPath)), nil, "")
+	if err != nil || exitCode != 0 {
+		return nil, fmt.Errorf("failed to list guest files (exit code %d): %v", exitCode, err)
+	}
+	guestFiles := make(map[string]bool)
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !isExcludedPath(line, excludes) {
+			guestFiles[line] = true
+		}
+	}
+
+	// A mirroring sync deletes destination files missing from the source
+	source, destination := hostFiles, guestFiles
+	if direction == "from_vm" {
+		source, destination = guestFiles, hostFiles
+	}
+	deletions := []string{}
+	for path := range destination {
+		if !source[path] {
+			deletions = append(deletions, path)
+		}
+	}
+	return deletions, nil
+}
+
+// listHostFiles returns the relative paths of files under root that are not excluded
+func listHostFiles(root string, excludes []string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if isExcludedPath(rel, excludes) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			files[filepath.ToSlash(rel)] = true
+		}
+		return nil
+	})
+	return files, err
+}
+
+// isExcludedPath reports whether any component of path matches an exclude pattern
+func isExcludedPath(path string, excludes []string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		for _, pattern := range excludes {
+			if matched, _ := filepath.Match(strings.TrimSuffix(pattern, "/"), part); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleSetConflictPolicy handles the set_conflict_policy tool
+func handleSetConflictPolicy(syncEngine core.SyncEngine) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		vmName, err := request.RequireString("vm_name")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Missing or invalid 'vm_name' parameter: %v", err)), nil
+		}
+
+		policy, err := request.RequireString("policy")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Missing or invalid 'policy' parameter: %v", err)), nil
+		}
+		if err := syncmod.ValidateConflictPolicy(syncmod.ConflictPolicy(policy)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// Parse per-path overrides; an empty list clears existing overrides
+		overrides := []core.ConflictPolicyOverride{}
+		if items, ok := request.GetArguments()["overrides"].([]interface{}); ok {
+			for _, item := range items {
+				entry, ok := item.(map[string]interface{})
+				if !ok {
+					return mcp.NewToolResultError("Invalid 'overrides' entry: expected an object with 'pattern' and 'policy'"), nil
+				}
+				pattern, _ := entry["pattern"].(string)
+				overridePolicy, _ := entry["policy"].(string)
+				if pattern == "" {
+					return mcp.NewToolResultError("Invalid 'overrides' entry: 'pattern' is required"), nil
+				}
+				if err := syncmod.ValidateConflictPolicy(syncmod.ConflictPolicy(overridePolicy)); err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("Invalid override for '%s': %v", pattern, err)), nil
+				}
+				overrides = append(overrides, core.ConflictPolicyOverride{Pattern: pattern, Policy: overridePolicy})
+			}
+		}
+
+		style := request.GetString("conflict_style", "")
+		if err := syncmod.ValidateConflictStyle(syncmod.ConflictStyle(style)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		config, err := syncEngine.GetSyncConfig(ctx, vmName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get sync config: %v", err)), nil
+		}
+		config.ConflictPolicy = policy
+		config.ConflictPolicyOverrides = overrides
+		if style != "" {
+			config.ConflictStyle = style
+		}
+		if err := syncEngine.UpdateSyncConfig(ctx, vmName, config); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to update sync config: %v", err)), nil
+		}
+
+		result := SetConflictPolicyResponse{
+			VMName:         vmName,
+			ConflictPolicy: policy,
+			Overrides:      overrides,
+			ConflictStyle:  config.ConflictStyle,
+		}
+
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal result: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+}
@@ -2,12 +2,23 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/paging"
+	"github.com/vagrant-mcp/server/internal/sync"
 	testfixture "github.com/vagrant-mcp/server/internal/testing"
+	"github.com/vagrant-mcp/server/internal/vm"
 	"github.com/vagrant-mcp/server/pkg/mcp"
 )
 
@@ -109,3 +120,121 @@ func TestUploadToVMHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestPagePendingFiles(t *testing.T) {
+	files := []string{"src/b.go", "docs/readme.md", "src/a.go", "src/c.go"}
+
+	page, info, err := pagePendingFiles(files, "src/", paging.Params{Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if strings.Join(page, ",") != "src/a.go,src/b.go" || info.Total != 3 || info.NextCursor == "" {
+		t.Fatalf("Expected the first sorted page of src/ but got %v (%+v)", page, info)
+	}
+	next := info.NextCursor
+	page, info, err = pagePendingFiles(files, "src/", paging.Params{Limit: 2, Cursor: next})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if strings.Join(page, ",") != "src/c.go" || info.NextCursor != "" {
+		t.Errorf("Expected the last page of src/ but got %v (%+v)", page, info)
+	}
+	if files[0] != "src/b.go" {
+		t.Errorf("Expected the engine's list to be left unsorted but got %v", files)
+	}
+	if _, _, err := pagePendingFiles(files, "docs/", paging.Params{Limit: 2, Cursor: next}); err == nil {
+		t.Error("Expected a cursor of another path prefix to be rejected")
+	}
+}
+
+// newFakeSyncVM creates a running VM on a fake Vagrant provider whose project holds main.go
+func newFakeSyncVM(t *testing.T, vmName string) (*vm.Manager, *cmdexec.FakeVagrant, string) {
+	t.Helper()
+	baseDir := filepath.Join(t.TempDir(), "vms")
+	fake := cmdexec.NewFakeVagrant()
+	manager, err := vm.NewManagerWithRunner(baseDir, fake)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	projectPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectPath, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+	if err := manager.CreateVM(context.Background(), vmName, projectPath, core.VMConfig{Name: vmName, Box: "ubuntu/jammy64"}); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+	fake.SetState(filepath.Join(baseDir, vmName), cmdexec.FakeStateRunning)
+	return manager, fake, projectPath
+}
+
+// guestCommands returns the commands run in the guest with 'vagrant ssh -c'
+func guestCommands(fake *cmdexec.FakeVagrant) []string {
+	var commands []string
+	for _, call := range fake.Calls() {
+		if len(call.Args) >= 3 && call.Args[0] == "ssh" && call.Args[1] == "-c" {
+			commands = append(commands, call.Args[2])
+		}
+	}
+	return commands
+}
+
+// TestVerifySyncHashesGuestFilesThroughRunner tests that verify_sync hashes the guest files
+// through the Vagrant runner and compares them with the host files
+func TestVerifySyncHashesGuestFilesThroughRunner(t *testing.T) {
+	manager, fake, _ := newFakeSyncVM(t, "verify-vm")
+	hash := sha256.Sum256([]byte("package main\n"))
+	fake.Script(cmdexec.FakeResponse{Args: []string{"ssh", "-c"}, Output: fmt.Sprintf("%x  ./main.go\n%x  ./extra.go\n", hash, hash)})
+	engine, err := sync.NewEngine()
+	if err != nil {
+		t.Fatalf("Failed to create sync engine: %v", err)
+	}
+
+	handler := handleVerifySync(&exec.SyncEngineAdapter{Real: engine}, &exec.VMManagerAdapter{Real: manager})
+	result, err := handler(context.Background(), mcp.CallToolRequest{
+		Params: mcpgo.CallToolParams{Name: "verify_sync", Arguments: map[string]interface{}{"vm_name": "verify-vm"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Expected success result, got error: %s", extractTextContent(result.Content))
+	}
+	var response VerifySyncResponse
+	if err := json.Unmarshal([]byte(extractTextContent(result.Content)), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.InSync || response.Matching != 1 || response.ExtraCount != 1 || len(response.Extra) != 1 || response.Extra[0] != "extra.go" {
+		t.Errorf("Expected main.go to match and extra.go to be extra but got %+v", response)
+	}
+	if commands := guestCommands(fake); len(commands) != 1 || !strings.Contains(commands[0], "sha256sum") {
+		t.Errorf("Expected the guest files to be hashed with vagrant ssh but got %v", commands)
+	}
+}
+
+// TestScanGuestChangesRunsThroughRunner tests that the files changed in the guest are listed
+// through the Vagrant runner and recorded as pending download
+func TestScanGuestChangesRunsThroughRunner(t *testing.T) {
+	manager, fake, projectPath := newFakeSyncVM(t, "scan-vm")
+	fake.Script(cmdexec.FakeResponse{Args: []string{"ssh", "-c"}, Output: "./out.log\n./node_modules/x.js\n"})
+	engine, err := sync.NewEngine()
+	if err != nil {
+		t.Fatalf("Failed to create sync engine: %v", err)
+	}
+	if err := engine.RegisterVM("scan-vm", sync.SyncConfig{ProjectPath: projectPath, ExcludePatterns: []string{"node_modules"}}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	if err := scanGuestChanges(context.Background(), &exec.SyncEngineAdapter{Real: engine}, &exec.VMManagerAdapter{Real: manager}, "scan-vm", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to scan guest changes: %v", err)
+	}
+	status, err := engine.GetSyncStatus("scan-vm")
+	if err != nil {
+		t.Fatalf("Failed to get sync status: %v", err)
+	}
+	if len(status.FilesPendingDownload) != 1 || status.FilesPendingDownload[0] != "out.log" {
+		t.Errorf("Expected out.log to be pending download but got %v", status.FilesPendingDownload)
+	}
+	if commands := guestCommands(fake); len(commands) != 1 || !strings.Contains(commands[0], "-newermt") {
+		t.Errorf("Expected the guest changes to be listed with vagrant ssh but got %v", commands)
+	}
+}
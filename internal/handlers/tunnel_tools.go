@@ -0,0 +1,177 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/events"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/tunnel"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// OpenTunnelResponse is the result of open_tunnel
+type OpenTunnelResponse struct {
+	Tunnel tunnel.Info `json:"tunnel"`
+	// Address is where the forwarded port is reachable
+	Address string `json:"address"`
+}
+
+// ListTunnelsResponse is the result of list_tunnels
+type ListTunnelsResponse struct {
+	Tunnels []tunnel.Info `json:"tunnels"`
+	Count   int           `json:"count"`
+}
+
+// CloseTunnelResponse is the result of close_tunnel
+type CloseTunnelResponse struct {
+	Tunnel tunnel.Info `json:"tunnel"`
+	Status string      `json:"status"`
+}
+
+// RegisterTunnelTools registers the SSH tunnel tools with the MCP server
+func RegisterTunnelTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor, tunnels *tunnel.Manager) {
+	// Tunnels do not survive their VM stopping
+	events.GlobalBus.Subscribe(func(event events.Event) {
+		if event.Type != events.VMStateChanged {
+			return
+		}
+		if state, ok := event.Data["state"].(core.VMState); ok && state != core.Running {
+			tunnels.CloseVM(event.VMName)
+		}
+	})
+
+	// Open tunnel tool
+	type OpenTunnelArgs struct {
+		VMName     string  `json:"vm_name"`
+		GuestPort  float64 `json:"guest_port"`
+		HostPort   float64 `json:"host_port"`
+		Direction  string  `json:"direction"`
+		TargetHost string  `json:"target_host"`
+	}
+	openTunnelTool := mcp.NewTool("open_tunnel",
+		mcp.WithDescription("Open an SSH port forward between the host and a VM without changing its Vagrantfile, e.g. to reach the guest's database from the host. The tunnel is closed when the VM stops"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithNumber("guest_port",
+			mcp.Required(),
+			mcp.Description("Port in the guest: the target of a local forward, or the listening port of a remote forward")),
+		mcp.WithNumber("host_port",
+			mcp.Description("Port on the host: the listening port of a local forward (default: a free port), or the target of a remote forward")),
+		mcp.WithString("direction",
+			mcp.Description("'local' exposes a guest port on the host; 'remote' exposes a host port in the guest"),
+			mcp.Enum(tunnel.DirectionLocal, tunnel.DirectionRemote),
+			mcp.DefaultString(tunnel.DirectionLocal)),
+		mcp.WithString("target_host",
+			mcp.Description("Host the forwarded connections go to, resolved in the guest for local forwards and on the host for remote forwards (default: localhost)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, openTunnelTool, func(ctx context.Context, request mcp.CallToolRequest, args OpenTunnelArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		if args.GuestPort == 0 {
+			return mcp.NewToolResultError("Missing required parameter: guest_port"), nil
+		}
+		if args.Direction == tunnel.DirectionRemote && args.HostPort == 0 {
+			return mcp.NewToolResultError("Missing required parameter for a remote forward: host_port"), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' does not exist: %v", args.VMName, err)), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultError(fmt.Sprintf("VM '%s' is not running (current state: %s)", args.VMName, state)), nil
+		}
+
+		sshArgs, err := executor.SSHArgs(ctx, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get SSH configuration: %v", err), nil
+		}
+		spec := tunnel.Spec{
+			Direction:  args.Direction,
+			HostPort:   int(args.HostPort),
+			GuestPort:  int(args.GuestPort),
+			TargetHost: args.TargetHost,
+		}
+		info, err := tunnels.Open(args.VMName, spec, sshArgs)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to open tunnel: %v", err), nil
+		}
+
+		response := OpenTunnelResponse{
+			Tunnel: info,
+		}
+		if info.Direction == tunnel.DirectionLocal {
+			response.Address = fmt.Sprintf("127.0.0.1:%d", info.HostPort)
+		} else {
+			response.Address = fmt.Sprintf("localhost:%d (in the guest)", info.GuestPort)
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// List tunnels tool
+	type ListTunnelsArgs struct {
+		VMName string `json:"vm_name"`
+	}
+	listTunnelsTool := mcp.NewTool("list_tunnels",
+		mcp.WithDescription("List the open SSH tunnels"),
+		mcp.WithString("vm_name",
+			mcp.Description("Only list the tunnels to this VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, listTunnelsTool, func(ctx context.Context, request mcp.CallToolRequest, args ListTunnelsArgs) (*mcp.CallToolResult, error) {
+		infos := tunnels.List(args.VMName)
+		response := ListTunnelsResponse{
+			Tunnels: infos,
+			Count:   len(infos),
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Close tunnel tool
+	type CloseTunnelArgs struct {
+		TunnelID string `json:"tunnel_id"`
+	}
+	closeTunnelTool := mcp.NewTool("close_tunnel",
+		mcp.WithDescription("Close an open SSH tunnel"),
+		mcp.WithString("tunnel_id",
+			mcp.Required(),
+			mcp.Description("Tunnel ID returned by open_tunnel")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, closeTunnelTool, func(ctx context.Context, request mcp.CallToolRequest, args CloseTunnelArgs) (*mcp.CallToolResult, error) {
+		if args.TunnelID == "" {
+			return mcp.NewToolResultError("Missing required parameter: tunnel_id"), nil
+		}
+		info, err := tunnels.Close(args.TunnelID)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to close tunnel: %v", err), nil
+		}
+		response := CloseTunnelResponse{
+			Tunnel: info,
+			Status: "closed",
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	log.Info().Msg("Tunnel tools registered")
+}
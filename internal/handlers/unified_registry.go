@@ -3,8 +3,16 @@ package handlers
 
 import (
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/boxes"
 	"github.com/vagrant-mcp/server/internal/core"
 	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/process"
+	"github.com/vagrant-mcp/server/internal/schedule"
+	"github.com/vagrant-mcp/server/internal/schema"
+	"github.com/vagrant-mcp/server/internal/secrets"
+	"github.com/vagrant-mcp/server/internal/shell"
+	"github.com/vagrant-mcp/server/internal/tunnel"
 )
 
 // HandlerRegistry provides unified handler registration functionality
@@ -27,7 +35,54 @@ func NewHandlerRegistry(vmManager core.VMManager, syncEngine core.SyncEngine, ex
 func (r *HandlerRegistry) RegisterAllTools(srv *server.MCPServer) {
 	// Use existing registration functions but centralize the call
 	RegisterVMTools(srv, r.vmManager, r.syncEngine)
+	RegisterProvisionTools(srv, r.vmManager, r.executor)
 	RegisterSyncTools(srv, r.syncEngine, r.vmManager)
 	RegisterExecTools(srv, r.vmManager, r.syncEngine, r.executor)
+	RegisterArtifactTools(srv, r.vmManager)
 	RegisterEnvTools(srv, r.vmManager, r.executor)
+	RegisterDriftTools(srv, r.vmManager, r.syncEngine, r.executor)
+	RegisterJournalTools(srv, r.vmManager, r.executor)
+	RegisterShellTools(srv, r.vmManager, r.executor, shell.GlobalManager)
+	RegisterProcessTools(srv, r.vmManager, r.executor, process.GlobalRegistry)
+	RegisterTunnelTools(srv, r.vmManager, r.executor, tunnel.GlobalManager)
+	RegisterDevServerTools(srv, r.vmManager, r.executor, process.GlobalRegistry, tunnel.GlobalManager)
+	RegisterDockerTools(srv, r.vmManager, r.executor)
+	RegisterDatabaseTools(srv, r.vmManager, r.executor, tunnel.GlobalManager)
+	RegisterUserTools(srv, r.vmManager, r.executor)
+	RegisterFileTools(srv, r.vmManager, r.executor)
+	RegisterSymbolTools(srv, r.vmManager, r.executor)
+	RegisterDiskTools(srv, r.vmManager, r.executor)
+	RegisterBackupTools(srv, r.vmManager)
+	RegisterScheduleTools(srv, r.vmManager, schedule.GlobalScheduler)
+	RegisterSecretTools(srv, r.vmManager, r.syncEngine, r.executor, secrets.GlobalStore)
+	RegisterGitTools(srv, r.vmManager, r.executor, secrets.GlobalStore)
+	RegisterEnvironmentTools(srv, r.vmManager)
+	RegisterBatchTools(srv, r.vmManager, r.syncEngine)
+	RegisterBoxTools(srv, boxes.NewClientFromEnv())
+	RegisterApprovalTools(srv, approval.GlobalGate)
+	RegisterSchemaTools(srv, schema.GlobalRegistry)
+}
+
+// BusyVMs returns the VMs with an open shell session, a tracked background process or dev
+// server, or an open tunnel, which count as activity for the idle tracker
+func BusyVMs() []string {
+	var names []string
+	for _, session := range shell.GlobalManager.List() {
+		if !session.Exited {
+			names = append(names, session.VMName)
+		}
+	}
+	for _, proc := range process.GlobalRegistry.List("") {
+		names = append(names, proc.VMName)
+	}
+	for _, open := range tunnel.GlobalManager.List("") {
+		names = append(names, open.VMName)
+	}
+	return names
+}
+
+// ApplyMode removes the tools a server mode leaves out. It runs after RegisterAllTools so the
+// disabled tools are never listed or callable.
+func (r *HandlerRegistry) ApplyMode(srv *server.MCPServer, mode string) error {
+	return applyMode(srv, mode)
 }
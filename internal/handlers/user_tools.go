@@ -0,0 +1,254 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/vm"
+	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
+)
+
+// defaultGuestUser is the user whose authorized keys add_authorized_key changes by default
+const defaultGuestUser = "vagrant"
+
+// CreateUserResponse is the result of create_vm_user
+type CreateUserResponse struct {
+	VMName           string `json:"vm_name"`
+	Username         string `json:"username"`
+	Sudo             bool   `json:"sudo"`
+	PasswordlessSudo bool   `json:"passwordless_sudo"`
+	// AuthorizedKeys holds the fingerprints of the keys authorized for the user
+	AuthorizedKeys []string `json:"authorized_keys"`
+}
+
+// AddAuthorizedKeyResponse is the result of add_authorized_key
+type AddAuthorizedKeyResponse struct {
+	VMName      string `json:"vm_name"`
+	Username    string `json:"username"`
+	Fingerprint string `json:"fingerprint"`
+	Replaced    bool   `json:"replaced"`
+}
+
+// RotateKeyResponse is the result of rotate_vagrant_key
+type RotateKeyResponse struct {
+	VMName string        `json:"vm_name"`
+	Key    vm.RotatedKey `json:"key"`
+}
+
+// RegisterUserTools registers the tools that manage guest users and their SSH keys
+func RegisterUserTools(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	// Create VM user tool
+	type CreateVMUserArgs struct {
+		VMName           string   `json:"vm_name"`
+		Username         string   `json:"username"`
+		Shell            string   `json:"shell"`
+		Sudo             bool     `json:"sudo"`
+		PasswordlessSudo bool     `json:"passwordless_sudo"`
+		AuthorizedKeys   []string `json:"authorized_keys"`
+	}
+	createUserTool := mcp.NewTool("create_vm_user",
+		mcp.WithDescription("Create a user in a VM, optionally with sudo rights and SSH public keys it can log in with"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("username",
+			mcp.Required(),
+			mcp.Description("Name of the user to create")),
+		mcp.WithString("shell",
+			mcp.Description("Login shell"),
+			mcp.DefaultString("/bin/bash")),
+		mcp.WithBoolean("sudo",
+			mcp.Description("Add the user to the sudo group"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("passwordless_sudo",
+			mcp.Description("Let the user run sudo without a password, as the vagrant user does"),
+			mcp.DefaultBool(false)),
+		mcp.WithArray("authorized_keys",
+			mcp.Description("SSH public keys, or paths of public key files on the host, to authorize for the user"),
+			mcp.Items(map[string]any{"type": "string"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, createUserTool, func(ctx context.Context, request mcp.CallToolRequest, args CreateVMUserArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.Username == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or username"), nil
+		}
+		command, err := vm.CreateUserCommand(vm.GuestUser{
+			Name:             args.Username,
+			Shell:            args.Shell,
+			Sudo:             args.Sudo,
+			PasswordlessSudo: args.PasswordlessSudo,
+		})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		keys, err := loadAuthorizedKeys(args.AuthorizedKeys)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(keys) > 0 {
+			keysCommand, err := vm.AuthorizedKeysCommand(args.Username, keys, false)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			command += " && " + keysCommand
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to create user", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to create user: %s", result.Stderr+result.Stdout), nil
+		}
+		response := CreateUserResponse{
+			VMName:           args.VMName,
+			Username:         args.Username,
+			Sudo:             args.Sudo || args.PasswordlessSudo,
+			PasswordlessSudo: args.PasswordlessSudo,
+			AuthorizedKeys:   keyFingerprints(keys),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Add authorized key tool
+	type AddAuthorizedKeyArgs struct {
+		VMName    string `json:"vm_name"`
+		Username  string `json:"username"`
+		PublicKey string `json:"public_key"`
+		Replace   bool   `json:"replace"`
+	}
+	addKeyTool := mcp.NewTool("add_authorized_key",
+		mcp.WithDescription("Authorize an SSH public key to log in to a VM as a guest user"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("public_key",
+			mcp.Required(),
+			mcp.Description("SSH public key, or the path of a public key file on the host")),
+		mcp.WithString("username",
+			mcp.Description("Guest user the key logs in as"),
+			mcp.DefaultString(defaultGuestUser)),
+		mcp.WithBoolean("replace",
+			mcp.Description("Replace the user's authorized keys instead of adding to them. Replacing the vagrant user's keys stops Vagrant from connecting; use rotate_vagrant_key for that user instead"),
+			mcp.DefaultBool(false)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, addKeyTool, func(ctx context.Context, request mcp.CallToolRequest, args AddAuthorizedKeyArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" || args.PublicKey == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name or public_key"), nil
+		}
+		username := args.Username
+		if username == "" {
+			username = defaultGuestUser
+		}
+		if args.Replace && username == defaultGuestUser {
+			return mcp.NewToolResultError("Replacing the vagrant user's keys would lock Vagrant out of the VM; use rotate_vagrant_key instead"), nil
+		}
+		keys, err := loadAuthorizedKeys([]string{args.PublicKey})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		command, err := vm.AuthorizedKeysCommand(username, keys, args.Replace)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+
+		result, err := executor.ExecuteCommand(ctx, command, exec.ExecutionContext{VMName: args.VMName}, nil)
+		if err != nil {
+			return commandFailedResult("Failed to add authorized key", result, err), nil
+		}
+		if result.ExitCode != 0 {
+			return mcp.NewToolResultErrorf("Failed to add authorized key: %s", result.Stderr+result.Stdout), nil
+		}
+		response := AddAuthorizedKeyResponse{
+			VMName:      args.VMName,
+			Username:    username,
+			Fingerprint: vm.KeyFingerprint(keys[0]),
+			Replaced:    args.Replace,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Rotate vagrant key tool
+	type RotateVagrantKeyArgs struct {
+		VMName string `json:"vm_name"`
+	}
+	rotateKeyTool := mcp.NewTool("rotate_vagrant_key",
+		mcp.WithDescription("Replace the SSH key Vagrant uses to connect to a VM with a newly generated one, and remove every other key from the vagrant user's authorized keys"),
+		mcp.WithString("vm_name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, rotateKeyTool, func(ctx context.Context, request mcp.CallToolRequest, args RotateVagrantKeyArgs) (*mcp.CallToolResult, error) {
+		if args.VMName == "" {
+			return mcp.NewToolResultError("Missing required parameter: vm_name"), nil
+		}
+		if result := requireRunningVM(ctx, vmManager, args.VMName); result != nil {
+			return result, nil
+		}
+		sshConfig, err := vmSSHConfig(ctx, vmManager, args.VMName)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get SSH configuration: %v", err), nil
+		}
+		rotated, err := vm.RotateSSHKey(ctx, args.VMName, sshConfig)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to rotate key: %v", err), nil
+		}
+		log.Info().Str("vm", args.VMName).Str("fingerprint", rotated.Fingerprint).Msg("Rotated VM SSH key")
+
+		response := RotateKeyResponse{
+			VMName: args.VMName,
+			Key:    rotated,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	log.Info().Msg("User tools registered")
+}
+
+// loadAuthorizedKeys reads public keys given inline or as host file paths
+func loadAuthorizedKeys(sources []string) ([]string, error) {
+	keys := make([]string, 0, len(sources))
+	for _, source := range sources {
+		key, err := vm.LoadAuthorizedKey(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorized key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// keyFingerprints returns the fingerprints of public keys
+func keyFingerprints(keys []string) []string {
+	fingerprints := make([]string, 0, len(keys))
+	for _, key := range keys {
+		fingerprints = append(fingerprints, vm.KeyFingerprint(key))
+	}
+	return fingerprints
+}
@@ -0,0 +1,145 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// runtimeVersionPattern matches the versions a version manager can install, e.g. 20, 3.12 or 3.12.2
+var runtimeVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}$`)
+
+// versionManager installs exact versions of a runtime for the vagrant user
+type versionManager struct {
+	// Name is the version manager, e.g. nvm
+	Name string
+	// Binaries are linked into /usr/local/bin so commands run over ssh, which do not read the
+	// shell's rc files, use the selected version
+	Binaries []string
+	// script installs the version manager when missing, then installs version and makes it the
+	// default. It sets bin to the directory holding the binaries of the version.
+	script func(version string) string
+}
+
+// versionManagers are the version managers of the runtimes that can be pinned to a version
+var versionManagers = map[string]versionManager{
+	"node": {
+		Name:     "nvm",
+		Binaries: []string{"node", "npm", "npx", "corepack"},
+		script: func(version string) string {
+			return fmt.Sprintf(`export NVM_DIR="$HOME/.nvm"
+if [ ! -s "$NVM_DIR/nvm.sh" ]; then
+  curl -fsSL https://raw.githubusercontent.com/nvm-sh/nvm/v0.39.7/install.sh | PROFILE=/dev/null bash >/dev/null || exit 1
+fi
+. "$NVM_DIR/nvm.sh"
+nvm install %[1]s || exit 1
+nvm alias default %[1]s >/dev/null || exit 1
+bin=$(dirname "$(nvm which %[1]s)")
+`, version)
+		},
+	},
+	"python": {
+		Name:     "pyenv",
+		Binaries: []string{"python", "python3", "pip", "pip3"},
+		script: func(version string) string {
+			return fmt.Sprintf(`export PYENV_ROOT="$HOME/.pyenv"
+sudo DEBIAN_FRONTEND=noninteractive apt-get install -y build-essential curl git libbz2-dev libffi-dev liblzma-dev libncursesw5-dev libreadline-dev libsqlite3-dev libssl-dev tk-dev xz-utils zlib1g-dev >/dev/null || exit 1
+if [ -d "$PYENV_ROOT/.git" ]; then
+  git -C "$PYENV_ROOT" pull --quiet || true
+else
+  git clone --quiet https://github.com/pyenv/pyenv.git "$PYENV_ROOT" || exit 1
+fi
+"$PYENV_ROOT/bin/pyenv" install --skip-existing %[1]s || exit 1
+"$PYENV_ROOT/bin/pyenv" global %[1]s || exit 1
+"$PYENV_ROOT/bin/pyenv" rehash
+bin="$PYENV_ROOT/shims"
+`, version)
+		},
+	},
+	"ruby": {
+		Name:     "rbenv",
+		Binaries: []string{"ruby", "gem", "bundle", "irb", "rake"},
+		script: func(version string) string {
+			return fmt.Sprintf(`export RBENV_ROOT="$HOME/.rbenv"
+sudo DEBIAN_FRONTEND=noninteractive apt-get install -y build-essential git libffi-dev libreadline-dev libssl-dev libyaml-dev zlib1g-dev >/dev/null || exit 1
+if [ ! -d "$RBENV_ROOT/.git" ]; then
+  git clone --quiet https://github.com/rbenv/rbenv.git "$RBENV_ROOT" || exit 1
+fi
+if [ -d "$RBENV_ROOT/plugins/ruby-build/.git" ]; then
+  git -C "$RBENV_ROOT/plugins/ruby-build" pull --quiet || true
+else
+  git clone --quiet https://github.com/rbenv/ruby-build.git "$RBENV_ROOT/plugins/ruby-build" || exit 1
+fi
+"$RBENV_ROOT/bin/rbenv" install --skip-existing %[1]s || exit 1
+"$RBENV_ROOT/bin/rbenv" global %[1]s || exit 1
+"$RBENV_ROOT/bin/rbenv" rehash
+bin="$RBENV_ROOT/shims"
+`, version)
+		},
+	},
+	"go": {
+		Name:     "gvm",
+		Binaries: []string{"go", "gofmt"},
+		script: func(version string) string {
+			return fmt.Sprintf(`sudo DEBIAN_FRONTEND=noninteractive apt-get install -y binutils bison build-essential curl git mercurial >/dev/null || exit 1
+if [ ! -s "$HOME/.gvm/scripts/gvm" ]; then
+  curl -fsSL https://raw.githubusercontent.com/moovweb/gvm/master/binscripts/gvm-installer | bash >/dev/null || exit 1
+fi
+. "$HOME/.gvm/scripts/gvm"
+if [ ! -x "$HOME/.gvm/gos/go%[1]s/bin/go" ]; then
+  gvm install go%[1]s -B || exit 1
+fi
+gvm use go%[1]s --default >/dev/null || exit 1
+bin="$HOME/.gvm/gos/go%[1]s/bin"
+`, version)
+		},
+	},
+}
+
+// versionManagedRuntimes returns the runtimes that can be pinned to a version
+func versionManagedRuntimes() []string {
+	runtimes := make([]string, 0, len(versionManagers))
+	for runtime := range versionManagers {
+		runtimes = append(runtimes, runtime)
+	}
+	sort.Strings(runtimes)
+	return runtimes
+}
+
+// versionManagerScript returns the script, run as the vagrant user, that installs a version of
+// a runtime with its version manager, makes it the default and prints the active version
+func versionManagerScript(runtime, version string) (string, error) {
+	manager, ok := versionManagers[runtime]
+	if !ok {
+		return "", errors.InvalidInput(fmt.Sprintf("runtime %s cannot be pinned to a version; pinned runtimes are %s", runtime, strings.Join(versionManagedRuntimes(), ", ")))
+	}
+	if !runtimeVersionPattern.MatchString(version) {
+		return "", errors.InvalidInput(fmt.Sprintf("invalid %s version '%s': use a version such as 20.11.1", runtime, version))
+	}
+	var b strings.Builder
+	b.WriteString(manager.script(version))
+	fmt.Fprintf(&b, "for binary in %s; do\n", strings.Join(manager.Binaries, " "))
+	b.WriteString(`  if [ -e "$bin/$binary" ]; then sudo ln -sf "$bin/$binary" "/usr/local/bin/$binary" || exit 1; fi
+done
+`)
+	fmt.Fprintf(&b, "%s 2>&1 | head -n 1\n", runtimeProbes[runtime])
+	return b.String(), nil
+}
+
+// versionManagerCommand returns the command that runs versionManagerScript as the vagrant user,
+// both from a root provisioner and over ssh
+func versionManagerCommand(runtime, version string) (string, error) {
+	script, err := versionManagerScript(runtime, version)
+	if err != nil {
+		return "", err
+	}
+	// base64 keeps the script intact through the provisioner's and ssh's shells
+	return fmt.Sprintf("echo %s | base64 -d | sudo -u vagrant -H bash", base64.StdEncoding.EncodeToString([]byte(script))), nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/base64"
+	osexec "os/exec"
+	"strings"
+	"testing"
+)
+
+func TestVersionManagerScript(t *testing.T) {
+	bash, err := osexec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	testCases := []struct {
+		runtime  string
+		version  string
+		expected string
+	}{
+		{"node", "20.11.1", "nvm install 20.11.1"},
+		{"python", "3.12.2", `pyenv" install --skip-existing 3.12.2`},
+		{"ruby", "3.3.0", `rbenv" install --skip-existing 3.3.0`},
+		{"go", "1.22.1", "gvm install go1.22.1 -B"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.runtime, func(t *testing.T) {
+			script, err := versionManagerScript(tc.runtime, tc.version)
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if !strings.Contains(script, tc.expected) {
+				t.Errorf("Expected the script to contain %q but got:\n%s", tc.expected, script)
+			}
+			if !strings.Contains(script, `sudo ln -sf "$bin/$binary" "/usr/local/bin/$binary"`) {
+				t.Errorf("Expected the script to link the binaries but got:\n%s", script)
+			}
+			if output, err := osexec.Command(bash, "-n", "-c", script).CombinedOutput(); err != nil {
+				t.Errorf("Expected a valid script but got %v: %s", err, output)
+			}
+		})
+	}
+
+	for _, invalid := range [][2]string{{"php", "8.3.0"}, {"node", "latest"}, {"node", "20; id"}, {"python", "3.12.2.1"}} {
+		if _, err := versionManagerScript(invalid[0], invalid[1]); err == nil {
+			t.Errorf("Expected an error for %s %s", invalid[0], invalid[1])
+		}
+	}
+}
+
+func TestRuntimeInstallCommandPinned(t *testing.T) {
+	command, err := runtimeInstallCommand("node@20.11.1")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	encoded, found := strings.CutPrefix(command, "echo ")
+	encoded, _, _ = strings.Cut(encoded, " ")
+	script, err := base64.StdEncoding.DecodeString(encoded)
+	if !found || err != nil || !strings.HasSuffix(command, "| base64 -d | sudo -u vagrant -H bash") {
+		t.Fatalf("Expected a base64 script run as vagrant but got %q", command)
+	}
+	if !strings.Contains(string(script), "nvm alias default 20.11.1") {
+		t.Errorf("Expected the nvm script but got:\n%s", script)
+	}
+
+	if command, err := runtimeInstallCommand("node"); err != nil || !strings.Contains(command, "apt-get install -y nodejs") {
+		t.Errorf("Expected the distribution package for an unpinned runtime but got %q, %v", command, err)
+	}
+}
@@ -7,27 +7,236 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/config"
 	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/expiry"
+	"github.com/vagrant-mcp/server/internal/hooks"
+	"github.com/vagrant-mcp/server/internal/idle"
+	"github.com/vagrant-mcp/server/internal/paging"
+	"github.com/vagrant-mcp/server/internal/project"
+	"github.com/vagrant-mcp/server/internal/vm"
 	mcp_pkg "github.com/vagrant-mcp/server/pkg/mcp"
 )
 
+// CreateVMResponse is the result of create_dev_vm
+type CreateVMResponse struct {
+	Name                string        `json:"name"`
+	ProjectPath         string        `json:"project_path"`
+	Config              core.VMConfig `json:"config"`
+	Platform            string        `json:"platform"`
+	Status              string        `json:"status"`
+	Timestamp           string        `json:"timestamp"`
+	PortProfile         string        `json:"port_profile,omitempty"`
+	VagrantfileTemplate string        `json:"vagrantfile_template,omitempty"`
+	// Bootstrap is what auto_bootstrap detected in the project
+	Bootstrap *BootstrapSummary `json:"bootstrap,omitempty"`
+}
+
+// BootstrapSummary is the stacks, runtimes and tools auto_bootstrap set up
+type BootstrapSummary struct {
+	Stacks   []project.Stack `json:"stacks"`
+	Runtimes []string        `json:"runtimes"`
+	Tools    []string        `json:"tools"`
+}
+
+// ConnectVMsResponse is the result of connect_vms
+type ConnectVMsResponse struct {
+	Network string             `json:"network"`
+	Subnet  string             `json:"subnet"`
+	Members []vm.NetworkMember `json:"members"`
+}
+
+// VMResources are the CPU cores and memory of a VM
+type VMResources struct {
+	CPU    int `json:"cpu"`
+	Memory int `json:"memory"`
+}
+
+// SetVMResourcesResponse is the result of set_vm_resources
+type SetVMResourcesResponse struct {
+	Name    string       `json:"name"`
+	Before  VMResources  `json:"before"`
+	After   VMResources  `json:"after"`
+	State   core.VMState `json:"state"`
+	Message string       `json:"message,omitempty"`
+}
+
+// ResizeVMDiskResponse is the result of resize_vm_disk
+type ResizeVMDiskResponse struct {
+	Name       string       `json:"name"`
+	DiskSizeGB int          `json:"disk_size_gb"`
+	State      core.VMState `json:"state"`
+	Message    string       `json:"message,omitempty"`
+}
+
+// ConfigureFirewallResponse is the result of configure_firewall
+type ConfigureFirewallResponse struct {
+	Name      string       `json:"name"`
+	Enabled   bool         `json:"enabled"`
+	State     core.VMState `json:"state"`
+	OpenPorts []int        `json:"open_ports,omitempty"`
+	Message   string       `json:"message,omitempty"`
+}
+
+// ConfigureDotfilesResponse is the result of configure_dotfiles
+type ConfigureDotfilesResponse struct {
+	Name     string         `json:"name"`
+	Dotfiles *core.Dotfiles `json:"dotfiles"`
+	State    core.VMState   `json:"state"`
+	// Applied reports whether the dotfiles were copied and cloned into the running VM
+	Applied bool `json:"applied"`
+	// Allowed lists the host dotfiles the server lets VMs copy
+	Allowed []string `json:"allowed_files"`
+	Message string   `json:"message,omitempty"`
+}
+
+// SetSnippetsResponse is the result of set_vagrantfile_snippets
+type SetSnippetsResponse struct {
+	Name     string                    `json:"name"`
+	Snippets []core.VagrantfileSnippet `json:"snippets"`
+	State    core.VMState              `json:"state"`
+	Message  string                    `json:"message,omitempty"`
+}
+
+// AdoptVMResponse is the result of adopt_existing_vm
+type AdoptVMResponse struct {
+	Name        string      `json:"name"`
+	VagrantDir  string      `json:"vagrant_dir"`
+	Machine     string      `json:"machine"`
+	Box         string      `json:"box"`
+	CPU         int         `json:"cpu"`
+	Memory      int         `json:"memory"`
+	Ports       []core.Port `json:"ports"`
+	ProjectPath string      `json:"project_path"`
+	GuestPath   string      `json:"guest_path"`
+	SyncType    string      `json:"sync_type"`
+}
+
+// defaultVMStatusLimit is the number of VMs get_vm_status lists by default
+const defaultVMStatusLimit = 50
+
+// VMStatus is the state of a VM and the lifecycle operation running on it
+type VMStatus struct {
+	Name      string        `json:"name"`
+	State     core.VMState  `json:"state"`
+	Operation *vm.Operation `json:"operation,omitempty"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+	// ProjectPath, Tags, CreatedAt and LastUsedAt are read from the VM's saved configuration
+	// and operation logs
+	ProjectPath string     `json:"project_path,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+}
+
+// VMStatusListResponse is the result of get_vm_status without a name
+type VMStatusListResponse struct {
+	VMs  []VMStatus  `json:"vms"`
+	Page paging.Page `json:"page"`
+}
+
+// SetVMTTLResponse is the result of set_vm_ttl
+type SetVMTTLResponse struct {
+	Name string `json:"name"`
+	// ExpiresAt is null when the VM is kept until destroyed
+	ExpiresAt *time.Time `json:"expires_at"`
+	Action    string     `json:"action"`
+}
+
+// SSHInfoResponse is the result of get_ssh_info
+type SSHInfoResponse struct {
+	Name string     `json:"name"`
+	SSH  vm.SSHInfo `json:"ssh"`
+}
+
+// OperationLogResponse is the result of get_vm_operation_log
+type OperationLogResponse struct {
+	Name      string    `json:"name"`
+	Operation string    `json:"operation"`
+	Path      string    `json:"path"`
+	Modified  time.Time `json:"modified"`
+	Size      int64     `json:"size"`
+	Truncated bool      `json:"truncated"`
+	Content   string    `json:"content"`
+}
+
+// LintVagrantfileResponse is the result of lint_vagrantfile
+type LintVagrantfileResponse struct {
+	Name     string           `json:"name"`
+	Path     string           `json:"path"`
+	Findings []vm.LintFinding `json:"findings"`
+	Summary  map[string]int   `json:"summary"`
+}
+
+// BootReportResponse is the result of get_boot_report
+type BootReportResponse struct {
+	Name       string            `json:"name"`
+	Latest     vm.BootReport     `json:"latest"`
+	Comparison vm.BootComparison `json:"comparison"`
+	History    []vm.BootReport   `json:"history"`
+}
+
+// PortProfilesResponse is the result of list_port_profiles
+type PortProfilesResponse struct {
+	Profiles     []config.PortProfile `json:"profiles"`
+	Default      string               `json:"default"`
+	ProfilesFile string               `json:"profiles_file"`
+}
+
+// HookResults is the second content item of tools that ran lifecycle hooks
+type HookResults struct {
+	Hooks []hooks.Result `json:"hooks"`
+}
+
 // RegisterVMTools registers all VM-related tools with the MCP server
 func RegisterVMTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine core.SyncEngine) {
 	// Create dev VM tool
 	type CreateVMArgs struct {
-		Name            string                   `json:"name"`
-		ProjectPath     string                   `json:"project_path"`
-		CPU             float64                  `json:"cpu"`
-		Memory          float64                  `json:"memory"`
-		Box             string                   `json:"box"`
-		SyncType        string                   `json:"sync_type"`
-		Ports           []map[string]interface{} `json:"ports"`
-		ExcludePatterns []string                 `json:"exclude_patterns"`
+		Name            string                    `json:"name"`
+		ProjectPath     string                    `json:"project_path"`
+		CPU             float64                   `json:"cpu"`
+		Memory          float64                   `json:"memory"`
+		Box             string                    `json:"box"`
+		Provider        string                    `json:"provider"`
+		SyncType        string                    `json:"sync_type"`
+		Ports           []map[string]interface{}  `json:"ports"`
+		PortProfile     string                    `json:"port_profile"`
+		ExcludePatterns []string                  `json:"exclude_patterns"`
+		DiskSizeGB      float64                   `json:"disk_size_gb"`
+		Disks           []core.Disk               `json:"disks"`
+		Network         string                    `json:"network"`
+		HTTPProxy       string                    `json:"http_proxy"`
+		HTTPSProxy      string                    `json:"https_proxy"`
+		NoProxy         string                    `json:"no_proxy"`
+		CACertificates  []string                  `json:"ca_certificates"`
+		SharedCache     bool                      `json:"shared_package_cache"`
+		LinkedClone     bool                      `json:"linked_clone"`
+		AutoBootstrap   bool                      `json:"auto_bootstrap"`
+		CloudInit       string                    `json:"cloud_init"`
+		Firewall        bool                      `json:"firewall"`
+		FirewallPorts   []int                     `json:"firewall_allow_ports"`
+		DotfilesRepo    string                    `json:"dotfiles_repo"`
+		DotfilesRef     string                    `json:"dotfiles_ref"`
+		DotfilesInstall string                    `json:"dotfiles_install"`
+		DotfilesFiles   []string                  `json:"dotfiles_files"`
+		ForwardAgent    bool                      `json:"forward_agent"`
+		Snippets        []core.VagrantfileSnippet `json:"vagrantfile_snippets"`
+		ProvisionSteps  []core.ProvisionStep      `json:"provision_steps"`
+		Template        string                    `json:"vagrantfile_template"`
+		Tags            []string                  `json:"tags"`
+		TTL             string                    `json:"ttl"`
 	}
 	createVMTool := mcp.NewTool("create_dev_vm",
 		mcp.WithDescription("Create and configure a development VM with Vagrant"),
@@ -44,17 +253,79 @@ func RegisterVMTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine
 			mcp.Description("Amount of memory in MB"),
 			mcp.DefaultNumber(2048)),
 		mcp.WithString("box",
-			mcp.Description("Vagrant box to use"),
-			mcp.DefaultString("ubuntu/focal64")),
+			mcp.Description("Vagrant box to use (default: the image baked with bake_base_image, or the standard box of the provider on this host, e.g. ubuntu/focal64 with VirtualBox or bento/ubuntu-22.04 with VMware on Apple Silicon)")),
+		mcp.WithString("provider",
+			mcp.Description("Vagrant provider to run the VM with: virtualbox, vmware_desktop, parallels, libvirt or qemu (default: VAGRANT_DEFAULT_PROVIDER, or the first installed provider that runs on this host's architecture)")),
 		mcp.WithString("sync_type",
 			mcp.Description("Sync type to use"),
 			mcp.DefaultString("rsync")),
 		mcp.WithArray("ports",
-			mcp.Description("Ports to forward (format: [host:guest])"),
+			mcp.Description("Ports to forward (format: [host:guest]); overrides port_profile"),
 			mcp.Items(map[string]any{"type": "object"})),
+		mcp.WithString("port_profile",
+			mcp.Description("Named port profile to forward (see list_port_profiles); host ports used by other VMs are reassigned"),
+			mcp.DefaultString(config.DefaultPortProfile)),
 		mcp.WithArray("exclude_patterns",
 			mcp.Description("Patterns to exclude from sync"),
 			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithNumber("disk_size_gb",
+			mcp.Description("Size of the root disk in GB (default: the box's disk size)")),
+		mcp.WithArray("disks",
+			mcp.Description("Additional disks to attach, format and mount (format: {\"name\": \"data\", \"size_gb\": 20, \"mount_point\": \"/data\"})"),
+			mcp.Items(map[string]any{"type": "object"})),
+		mcp.WithString("network",
+			mcp.Description("Shared private network to join, e.g. the project name; VMs on the same network reach each other by VM name")),
+		mcp.WithString("http_proxy",
+			mcp.Description("HTTP proxy for apt and commands in the VM, e.g. http://proxy.corp.example:3128; it must be reachable from the guest")),
+		mcp.WithString("https_proxy",
+			mcp.Description("HTTPS proxy for apt and commands in the VM")),
+		mcp.WithString("no_proxy",
+			mcp.Description("Comma-separated hosts and domains that bypass the proxy, e.g. localhost,127.0.0.1,.corp.example")),
+		mcp.WithArray("ca_certificates",
+			mcp.Description("Additional CA certificates for the VM to trust, as host paths of PEM files or PEM content"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithBoolean("shared_package_cache",
+			mcp.Description("Mount the host's apt, npm, pip and Go module caches, shared by every VM that enables them, so repeated setups do not download packages again"),
+			mcp.DefaultBool(false)),
+		mcp.WithBoolean("linked_clone",
+			mcp.Description("Create the VM as a linked clone of its box with VirtualBox, VMware or Parallels, which takes seconds instead of copying the whole disk"),
+			mcp.DefaultBool(false)),
+		mcp.WithString("cloud_init",
+			mcp.Description("cloud-init user-data applied on the first boot, as a host file path or the document itself starting with #cloud-config, e.g. to declare users, SSH keys, packages and files")),
+		mcp.WithBoolean("firewall",
+			mcp.Description("Block incoming connections in the guest except to SSH, the forwarded guest ports and firewall_allow_ports"),
+			mcp.DefaultBool(false)),
+		mcp.WithArray("firewall_allow_ports",
+			mcp.Description("Additional guest TCP ports the firewall leaves open"),
+			mcp.Items(map[string]any{"type": "number"})),
+		mcp.WithString("dotfiles_repo",
+			mcp.Description("Git URL of a dotfiles repository cloned to ~/.dotfiles on the first boot; it must be cloneable from the guest")),
+		mcp.WithString("dotfiles_ref",
+			mcp.Description("Branch, tag or commit of the dotfiles repository (default: its default branch)")),
+		mcp.WithString("dotfiles_install",
+			mcp.Description("Script in the dotfiles repository to run after cloning it, e.g. install.sh")),
+		mcp.WithArray("dotfiles_files",
+			mcp.Description("Host dotfiles to copy to the guest's home directory, e.g. .gitconfig or .ssh/known_hosts; each file is opt-in and must be in the server's allowlist"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithBoolean("forward_agent",
+			mcp.Description("Forward the host's SSH agent to vagrant ssh and to every command the server runs in the VM, for commit signing with SSH keys and access to private repositories and registries (see setup_git_access)"),
+			mcp.DefaultBool(false)),
+		mcp.WithArray("vagrantfile_snippets",
+			mcp.Description("Raw Ruby added to the generated Vagrantfile inside Vagrant.configure, after a section: box, provider, settings, network, sync or provisioning (default), e.g. {\"name\": \"gui\", \"section\": \"provider\", \"content\": \"config.vm.provider 'virtualbox' do |vb|\\n  vb.gui = true\\nend\"}; checked with vagrant validate"),
+			mcp.Items(map[string]any{"type": "object"})),
+		mcp.WithArray("provision_steps",
+			mcp.Description("Named shell provisioners run in order after the setup provisioner, so a failure names its step, e.g. {\"name\": \"migrate\", \"inline\": \"cd /vagrant && make migrate\", \"run\": \"once\", \"privileged\": false}; each step has either an inline script or the path of a host script relative to the project, runs once (default) or always, on every boot, and runs as root unless privileged is false"),
+			mcp.Items(map[string]any{"type": "object"})),
+		mcp.WithString("vagrantfile_template",
+			mcp.Description("Name of a Vagrantfile template in the templates directory (<name>.tmpl) to render instead of the built-in template; without it Vagrantfile.tmpl in the project or the templates directory is used when present")),
+		mcp.WithArray("tags",
+			mcp.Description("Labels for the VM, e.g. its project or team, that stop_all_vms, destroy_vms and sync_all can select it by"),
+			mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("ttl",
+			mcp.Description("Time to live, e.g. 8h or 72h, after which the VM is halted or destroyed (see MCP_EXPIRY_ACTION); 0 keeps it until destroyed (default: MCP_VM_DEFAULT_TTL)")),
+		mcp.WithBoolean("auto_bootstrap",
+			mcp.Description("Apply the recommendation of detect_project: its ports, exclude patterns, CPU and memory fill the parameters not given, and its runtimes and tools are installed on the first boot"),
+			mcp.DefaultBool(false)),
 	)
 
 	mcp_pkg.RegisterTypedTool(srv, createVMTool, func(ctx context.Context, request mcp.CallToolRequest, args CreateVMArgs) (*mcp.CallToolResult, error) {
@@ -73,73 +344,236 @@ func RegisterVMTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine
 			}
 			ports = append(ports, port)
 		}
+		var detection *project.Detection
+		if args.AutoBootstrap {
+			detected, err := project.Detect(args.ProjectPath)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Failed to detect project: %v", err), nil
+			}
+			detection = &detected
+			// Explicit parameters win over the recommendation
+			given := request.GetArguments()
+			if _, ok := given["cpu"]; !ok {
+				args.CPU = float64(detection.Config.CPU)
+			}
+			if _, ok := given["memory"]; !ok {
+				args.Memory = float64(detection.Config.Memory)
+			}
+			if len(args.ExcludePatterns) == 0 {
+				args.ExcludePatterns = detection.ExcludePatterns
+			}
+			if _, ok := given["port_profile"]; len(ports) == 0 && !ok && len(detection.Ports) > 0 {
+				ports = config.AssignHostPorts(detection.Ports, usedHostPorts(vmManager.GetBaseDir(), args.Name), hostPortAvailable)
+			}
+		}
+		profileName := ""
 		if len(ports) == 0 {
-			// Default ports
-			ports = []core.Port{
-				{Guest: 3000, Host: 3000},
-				{Guest: 8000, Host: 8000},
-				{Guest: 5432, Host: 5432},
-				{Guest: 3306, Host: 3306},
-				{Guest: 6379, Host: 6379},
+			profileName = args.PortProfile
+			if profileName == "" {
+				profileName = config.DefaultPortProfile
 			}
+			profile, err := config.GlobalPortProfiles.GetProfile(profileName)
+			if err != nil {
+				return mcp.NewToolResultErrorf("%v (use list_port_profiles to see the available profiles)", err), nil
+			}
+			ports = config.AssignHostPorts(profile.Ports, usedHostPorts(vmManager.GetBaseDir(), args.Name), hostPortAvailable)
 		}
 		// Exclude patterns
 		excludePatterns := args.ExcludePatterns
 		if len(excludePatterns) == 0 {
 			excludePatterns = []string{"node_modules", ".git", "*.log", "dist", "build", "__pycache__", "*.pyc", "venv", ".venv", "*.o", "*.out"}
 		}
-		config := core.VMConfig{
+		vmConfig := core.VMConfig{
 			Box:                 args.Box,
+			Provider:            args.Provider,
 			CPU:                 int(args.CPU),
 			Memory:              int(args.Memory),
 			SyncType:            args.SyncType,
 			Ports:               ports,
 			SyncExcludePatterns: excludePatterns,
+			DiskSizeGB:          int(args.DiskSizeGB),
+			Disks:               args.Disks,
+			Network:             args.Network,
+			SharedPackageCache:  args.SharedCache,
+			LinkedClone:         args.LinkedClone,
+			Snippets:            args.Snippets,
+			ProvisionSteps:      args.ProvisionSteps,
+			Template:            args.Template,
+			Tags:                args.Tags,
+		}
+		expiresAt, err := vmExpiry(args.TTL)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		vmConfig.ExpiresAt = expiresAt
+		if detection != nil {
+			for _, runtime := range detection.Runtimes {
+				if command, err := runtimeInstallCommand(runtime); err == nil {
+					vmConfig.Provisioners = append(vmConfig.Provisioners, command)
+				}
+			}
+			for _, tool := range detection.Tools {
+				vmConfig.Provisioners = append(vmConfig.Provisioners, toolInstallCommand(tool))
+			}
+		}
+		if args.HTTPProxy != "" || args.HTTPSProxy != "" || args.NoProxy != "" {
+			vmConfig.Proxy = &core.Proxy{HTTPProxy: args.HTTPProxy, HTTPSProxy: args.HTTPSProxy, NoProxy: args.NoProxy}
+		}
+		for _, source := range args.CACertificates {
+			certificate, err := vm.LoadCACertificate(source)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Invalid CA certificate: %v", err), nil
+			}
+			vmConfig.CACertificates = append(vmConfig.CACertificates, certificate)
+		}
+		if args.CloudInit != "" {
+			userData, err := vm.LoadCloudInit(args.CloudInit)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Invalid cloud-init user-data: %v", err), nil
+			}
+			vmConfig.CloudInit = userData
+		}
+		if args.Firewall || len(args.FirewallPorts) > 0 {
+			vmConfig.Firewall = &core.Firewall{AllowPorts: args.FirewallPorts}
+		}
+		if err := vm.ValidateFirewall(vmConfig); err != nil {
+			return mcp.NewToolResultErrorf("Invalid firewall configuration: %v", err), nil
+		}
+		vmConfig.ForwardSSHAgent = args.ForwardAgent
+		if args.DotfilesRepo != "" || args.DotfilesRef != "" || args.DotfilesInstall != "" || len(args.DotfilesFiles) > 0 {
+			vmConfig.Dotfiles = &core.Dotfiles{Repo: args.DotfilesRepo, Ref: args.DotfilesRef, Install: args.DotfilesInstall, Files: args.DotfilesFiles}
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return mcp.NewToolResultErrorf("Failed to find the home directory: %v", err), nil
+			}
+			if err := vm.ValidateDotfiles(vmConfig, homeDir); err != nil {
+				return mcp.NewToolResultErrorf("Invalid dotfiles configuration: %v", err), nil
+			}
+		}
+		if err := vm.ValidateCloudInit(vmConfig); err != nil {
+			return mcp.NewToolResultErrorf("Invalid cloud-init user-data: %v", err), nil
+		}
+		if err := vm.ValidateDisks(vmConfig); err != nil {
+			return mcp.NewToolResultErrorf("Invalid disk configuration: %v", err), nil
+		}
+		if err := vm.ValidateProxy(vmConfig); err != nil {
+			return mcp.NewToolResultErrorf("Invalid proxy configuration: %v", err), nil
+		}
+		if err := vm.ValidateSnippets(vmConfig); err != nil {
+			return mcp.NewToolResultErrorf("Invalid Vagrantfile snippets: %v", err), nil
+		}
+		if err := vm.ValidateTemplate(vmConfig); err != nil {
+			return mcp.NewToolResultErrorf("Invalid Vagrantfile template: %v", err), nil
+		}
+		steps := vmConfig
+		steps.ProjectPath = args.ProjectPath
+		if err := vm.ValidateProvisionSteps(steps); err != nil {
+			return mcp.NewToolResultErrorf("Invalid provisioning steps: %v", err), nil
+		}
+		target := hooks.Target{VMName: args.Name, ProjectPath: args.ProjectPath}
+		hookResults, err := hooks.GlobalRunner.Run(ctx, hooks.PreCreate, target)
+		if err != nil {
+			return withHookResults(mcp.NewToolResultErrorf("VM was not created: %v", err), hookResults), nil
 		}
-		if err := vmManager.CreateVM(ctx, args.Name, args.ProjectPath, config); err != nil {
-			return mcp.NewToolResultErrorf("Failed to create VM: %v", err), nil
+		if err := vmManager.CreateVM(ctx, args.Name, args.ProjectPath, vmConfig); err != nil {
+			return withHookResults(mcp.NewToolResultErrorf("Failed to create VM: %v", err), hookResults), nil
 		}
-		response := map[string]interface{}{
-			"name":         args.Name,
-			"project_path": args.ProjectPath,
-			"config":       config,
-			"status":       "created",
-			"timestamp":    time.Now().Format(time.RFC3339),
+		postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostCreate, target)
+		hookResults = append(hookResults, postResults...)
+		// The VM manager fills in defaults such as the box
+		if created, err := vmManager.GetVMConfig(ctx, args.Name); err == nil {
+			vmConfig = created
+		}
+		response := CreateVMResponse{
+			Name:        args.Name,
+			ProjectPath: args.ProjectPath,
+			Config:      vmConfig,
+			Platform:    vm.HostPlatform().String(),
+			Status:      "created",
+			Timestamp:   time.Now().Format(time.RFC3339),
+			PortProfile: profileName,
+		}
+		if templatePath, err := vm.VagrantfileTemplate(vmConfig); err == nil {
+			response.VagrantfileTemplate = templatePath
+		}
+		if detection != nil {
+			response.Bootstrap = &BootstrapSummary{
+				Stacks:   detection.Stacks,
+				Runtimes: detection.Runtimes,
+				Tools:    detection.Tools,
+			}
 		}
 		jsonResponse, err := json.Marshal(response)
 		if err != nil {
 			return mcp.NewToolResultError("Failed to marshal response"), nil
 		}
-		return mcp.NewToolResultText(string(jsonResponse)), nil
+		return withHookResults(mcp.NewToolResultText(string(jsonResponse)), hookResults), nil
 	})
 
 	// Ensure dev VM tool
 	type EnsureVMArgs struct {
-		Name        string `json:"name"`
-		ProjectPath string `json:"project_path"`
+		Name             string `json:"name"`
+		ProjectPath      string `json:"project_path"`
+		RestoreWarmCache *bool  `json:"restore_warm_cache"`
+		ReadyTimeout     string `json:"ready_timeout"`
 	}
 	ensureVMTool := mcp.NewTool("ensure_dev_vm",
-		mcp.WithDescription("Ensure development VM is running, create if it doesn't exist"),
+		mcp.WithDescription("Ensure development VM is running, create if it doesn't exist and resume it if it was suspended. A "+project.ManifestFile+" manifest in the project declares the VM's name, box, resources, runtimes, ports, sync excludes and startup commands, so a project with one needs no other parameters"),
 		mcp.WithString("name",
-			mcp.Required(),
-			mcp.Description("Name of the development VM")),
+			mcp.Description("Name of the development VM (default: the name in the project manifest)")),
 		mcp.WithString("project_path",
-			mcp.Description("Path to the project directory to sync (only needed for creation)")),
+			mcp.Description("Path to the project directory to sync, read for its "+project.ManifestFile+" manifest (needed for creation; default: the server's working directory when no name is given)")),
+		mcp.WithBoolean("restore_warm_cache",
+			mcp.Description("On the first boot of a recreated VM, restore the directories preserved by destroy_dev_vm"),
+			mcp.DefaultBool(true)),
+		mcp.WithString("ready_timeout",
+			mcp.Description("How long to wait for cloud-init and systemd to finish booting the VM before reporting it running, e.g. 10m; 0 does not wait"),
+			mcp.DefaultString(vm.DefaultReadyTimeout.String())),
 	)
 
 	mcp_pkg.RegisterTypedTool(srv, ensureVMTool, func(ctx context.Context, request mcp.CallToolRequest, args EnsureVMArgs) (*mcp.CallToolResult, error) {
+		readyTimeout := vm.DefaultReadyTimeout
+		if args.ReadyTimeout != "" {
+			timeout, err := time.ParseDuration(args.ReadyTimeout)
+			if err != nil || timeout < 0 {
+				return mcp.NewToolResultErrorf("Invalid ready_timeout '%s': use a duration such as 10m, or 0", args.ReadyTimeout), nil
+			}
+			readyTimeout = timeout
+		}
+		if args.Name == "" && args.ProjectPath == "" {
+			workingDir, err := os.Getwd()
+			if err != nil {
+				return mcp.NewToolResultError("Missing required parameter: name or project_path"), nil
+			}
+			args.ProjectPath = workingDir
+		}
+		var manifest *project.Manifest
+		if args.ProjectPath != "" {
+			var err error
+			if manifest, err = project.LoadManifest(args.ProjectPath); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
 		if args.Name == "" {
-			return mcp.NewToolResultError("Missing required parameter: name"), nil
+			if manifest == nil || manifest.Name == "" {
+				return mcp.NewToolResultErrorf("Missing required parameter: name (or a %s in the project declaring it)", project.ManifestFile), nil
+			}
+			args.Name = manifest.Name
 		}
 		// Get VM state
 		state, err := vmManager.GetVMState(ctx, args.Name)
+		if err == nil && state == core.NotCreated {
+			// A destroyed VM is reported not created once its directory is gone
+			if _, configErr := vmManager.GetVMConfig(ctx, args.Name); configErr != nil {
+				err = configErr
+			}
+		}
 		if err != nil {
 			// VM doesn't exist, see if we can create it
 			if args.ProjectPath == "" {
 				return mcp.NewToolResultError("VM doesn't exist. Missing required parameter for creation: project_path"), nil
 			}
 			config := core.VMConfig{
-				Box:    "ubuntu/focal64",
 				CPU:    2,
 				Memory: 2048,
 				Ports: []core.Port{
@@ -151,10 +585,41 @@ func RegisterVMTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine
 				SyncExcludePatterns: []string{
 					"node_modules", ".git", "*.log", "dist", "build",
 				},
+				ExpiresAt: expiry.GlobalReaper.ExpiresAt(expiry.GlobalReaper.DefaultTTL()),
+			}
+			if manifest != nil {
+				manifest.Apply(&config)
+				for _, runtime := range manifest.Runtimes {
+					command, err := runtimeInstallCommand(runtime)
+					if err != nil {
+						return mcp.NewToolResultErrorf("Invalid %s: %v", project.ManifestFile, err), nil
+					}
+					config.Provisioners = append(config.Provisioners, command)
+				}
+				for _, tool := range manifest.Tools {
+					config.Provisioners = append(config.Provisioners, toolInstallCommand(tool))
+				}
+			}
+			target := hooks.Target{VMName: args.Name, ProjectPath: args.ProjectPath}
+			hookResults, err := hooks.GlobalRunner.Run(ctx, hooks.PreCreate, target)
+			if err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("VM was not created: %v", err), hookResults), nil
 			}
 			if err := vmManager.CreateVM(ctx, args.Name, args.ProjectPath, config); err != nil {
-				return mcp.NewToolResultErrorf("Failed to create VM: %v", err), nil
+				return withHookResults(mcp.NewToolResultErrorf("Failed to create VM: %v", err), hookResults), nil
+			}
+			postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostCreate, target)
+			hookResults = append(hookResults, postResults...)
+			upResults, err := hooks.GlobalRunner.Run(ctx, hooks.PreUp, target)
+			hookResults = append(hookResults, upResults...)
+			if err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("VM '%s' was created but not started: %v", args.Name, err), hookResults), nil
 			}
+			if err := vmManager.StartVM(ctx, args.Name); err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("VM '%s' was created but failed to start: %v", args.Name, err), hookResults), nil
+			}
+			upResults, _ = hooks.GlobalRunner.Run(ctx, hooks.PostUp, target)
+			hookResults = append(hookResults, upResults...)
 			syncConfig := core.SyncConfig{
 				VMName:          args.Name,
 				ProjectPath:     args.ProjectPath,
@@ -162,90 +627,1171 @@ func RegisterVMTools(srv *server.MCPServer, vmManager core.VMManager, syncEngine
 				Direction:       core.SyncToVM,
 				ExcludePatterns: config.SyncExcludePatterns,
 			}
+			message := fmt.Sprintf("VM '%s' created and started", args.Name)
+			if manifest != nil {
+				syncConfig.ConflictPolicy = manifest.Sync.ConflictPolicy
+				syncConfig.ConflictPolicyOverrides = manifest.Sync.ConflictPolicyOverrides
+				message += " from " + project.ManifestFile
+			}
+			if args.RestoreWarmCache == nil || *args.RestoreWarmCache {
+				message += restoreWarmCache(ctx, vmManager, args.Name)
+			}
 			if err := syncEngine.RegisterVM(ctx, args.Name, syncConfig); err != nil {
 				log.Error().Err(err).Msg("Failed to register VM with sync engine")
 			}
-			return mcp.NewToolResultText(fmt.Sprintf("VM '%s' created and started", args.Name)), nil
+			ready, notReady := awaitReady(ctx, vmManager, args.Name, readyTimeout)
+			if notReady != nil {
+				return withHookResults(notReady, hookResults), nil
+			}
+			return withHookResults(mcp.NewToolResultText(message+ready), hookResults), nil
 		}
 		if state != core.Running {
+			// An expired VM would be reaped again at the next check
+			if config, err := vmManager.GetVMConfig(ctx, args.Name); err == nil && expiry.GlobalReaper.Expired(config) {
+				return mcp.NewToolResultErrorf("VM '%s' expired at %s; extend it with set_vm_ttl before starting it", args.Name, config.ExpiresAt.Format(time.RFC3339)), nil
+			}
+			target := hookTarget(ctx, vmManager, args.Name)
+			hookResults, err := hooks.GlobalRunner.Run(ctx, hooks.PreUp, target)
+			if err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("VM was not started: %v", err), hookResults), nil
+			}
 			if err := vmManager.StartVM(ctx, args.Name); err != nil {
-				return mcp.NewToolResultErrorf("Failed to start VM: %v", err), nil
+				return withHookResults(mcp.NewToolResultErrorf("Failed to start VM: %v", err), hookResults), nil
 			}
-			return mcp.NewToolResultText(fmt.Sprintf("VM '%s' started", args.Name)), nil
+			postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostUp, target)
+			hookResults = append(hookResults, postResults...)
+			message := fmt.Sprintf("VM '%s' started", args.Name)
+			if state == core.Suspended {
+				message = fmt.Sprintf("VM '%s' resumed", args.Name)
+			}
+			if stop, ok := idle.GlobalTracker.TakeAutoStop(args.Name); ok {
+				message += "; " + stop.Describe()
+			}
+			if state == core.NotCreated && (args.RestoreWarmCache == nil || *args.RestoreWarmCache) {
+				message += restoreWarmCache(ctx, vmManager, args.Name)
+			}
+			ready, notReady := awaitReady(ctx, vmManager, args.Name, readyTimeout)
+			if notReady != nil {
+				return withHookResults(notReady, hookResults), nil
+			}
+			return withHookResults(mcp.NewToolResultText(message+ready), hookResults), nil
 		}
-		return mcp.NewToolResultText(fmt.Sprintf("VM '%s' is already running", args.Name)), nil
+		// A VM started by an earlier call may still be booting
+		ready, notReady := awaitReady(ctx, vmManager, args.Name, readyTimeout)
+		if notReady != nil {
+			return notReady, nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("VM '%s' is already running%s", args.Name, ready)), nil
 	})
 
-	// Destroy dev VM tool
-	type DestroyVMArgs struct {
-		Name string `json:"name"`
+	// Connect VMs tool
+	type ConnectVMsArgs struct {
+		Network string   `json:"network"`
+		VMNames []string `json:"vm_names"`
 	}
-	destroyVMTool := mcp.NewTool("destroy_dev_vm",
-		mcp.WithDescription("Clean up development VM and associated resources"),
+	connectVMsTool := mcp.NewTool("connect_vms",
+		mcp.WithDescription("Put existing development VMs on a shared private network so they reach each other by hostname. Running VMs that join are reloaded"),
+		mcp.WithString("network",
+			mcp.Required(),
+			mcp.Description("Name of the shared network, e.g. the project name")),
+		mcp.WithArray("vm_names",
+			mcp.Required(),
+			mcp.Description("Names of the VMs to connect"),
+			mcp.Items(map[string]any{"type": "string"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, connectVMsTool, func(ctx context.Context, request mcp.CallToolRequest, args ConnectVMsArgs) (*mcp.CallToolResult, error) {
+		if args.Network == "" {
+			return mcp.NewToolResultError("Missing required parameter: network"), nil
+		}
+		if len(args.VMNames) == 0 {
+			return mcp.NewToolResultError("Missing required parameter: vm_names"), nil
+		}
+		connector, ok := vmManager.(interface {
+			ConnectVMs(ctx context.Context, network string, vmNames []string) (vm.Network, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support private networks"), nil
+		}
+		network, err := connector.ConnectVMs(ctx, args.Network, args.VMNames)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to connect VMs: %v", err), nil
+		}
+		response := ConnectVMsResponse{
+			Network: network.Name,
+			Subnet:  network.Subnet + ".0/24",
+			Members: network.SortedMembers(),
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Set VM resources tool
+	type SetVMResourcesArgs struct {
+		Name   string  `json:"name"`
+		CPU    float64 `json:"cpu"`
+		Memory float64 `json:"memory"`
+	}
+	setVMResourcesTool := mcp.NewTool("set_vm_resources",
+		mcp.WithDescription("Change the CPU cores and memory of an existing development VM. A running VM is reloaded so the change takes effect"),
 		mcp.WithString("name",
 			mcp.Required(),
 			mcp.Description("Name of the development VM")),
+		mcp.WithNumber("cpu",
+			mcp.Description("New number of CPU cores (default: unchanged)")),
+		mcp.WithNumber("memory",
+			mcp.Description("New amount of memory in MB (default: unchanged)")),
 	)
-	mcp_pkg.RegisterTypedTool(srv, destroyVMTool, func(ctx context.Context, request mcp.CallToolRequest, args DestroyVMArgs) (*mcp.CallToolResult, error) {
+	mcp_pkg.RegisterTypedTool(srv, setVMResourcesTool, func(ctx context.Context, request mcp.CallToolRequest, args SetVMResourcesArgs) (*mcp.CallToolResult, error) {
 		if args.Name == "" {
 			return mcp.NewToolResultError("Missing required parameter: name"), nil
 		}
-		if err := vmManager.DestroyVM(ctx, args.Name); err != nil {
-			return mcp.NewToolResultErrorf("Failed to destroy VM: %v", err), nil
+		if args.CPU == 0 && args.Memory == 0 {
+			return mcp.NewToolResultError("Missing required parameter: cpu or memory"), nil
+		}
+		resourcer, ok := vmManager.(interface {
+			SetResources(ctx context.Context, name string, cpu, memory int) (core.VMConfig, core.VMConfig, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support changing VM resources"), nil
+		}
+		before, after, err := resourcer.SetResources(ctx, args.Name, int(args.CPU), int(args.Memory))
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to set VM resources: %v", err), nil
+		}
+		state, _ := vmManager.GetVMState(ctx, args.Name)
+		response := SetVMResourcesResponse{
+			Name:   args.Name,
+			Before: VMResources{CPU: before.CPU, Memory: before.Memory},
+			After:  VMResources{CPU: after.CPU, Memory: after.Memory},
+			State:  state,
+		}
+		if state != core.Running {
+			response.Message = "The new resources apply the next time the VM starts"
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
 		}
-		return mcp.NewToolResultText(fmt.Sprintf("VM '%s' destroyed", args.Name)), nil
+		return mcp.NewToolResultText(string(jsonData)), nil
 	})
 
-	// Get VM status tool
-	type GetVMStatusArgs struct {
-		Name string `json:"name"`
+	// Resize VM disk tool
+	type ResizeVMDiskArgs struct {
+		Name   string  `json:"name"`
+		SizeGB float64 `json:"size_gb"`
 	}
-	getStatusTool := mcp.NewTool("get_vm_status",
-		mcp.WithDescription("Get status of one or all development VMs"),
+	resizeVMDiskTool := mcp.NewTool("resize_vm_disk",
+		mcp.WithDescription("Grow the root disk of a development VM. A running VM is restarted so the disk and its root filesystem are expanded"),
 		mcp.WithString("name",
-			mcp.Description("Name of the development VM (optional)")),
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithNumber("size_gb",
+			mcp.Required(),
+			mcp.Description("New root disk size in GB; disks can only grow")),
 	)
-	mcp_pkg.RegisterTypedTool(srv, getStatusTool, func(ctx context.Context, request mcp.CallToolRequest, args GetVMStatusArgs) (*mcp.CallToolResult, error) {
-		if args.Name != "" {
-			state, err := vmManager.GetVMState(ctx, args.Name)
-			if err != nil {
-				return mcp.NewToolResultErrorf("Failed to get VM status: %v", err), nil
-			}
-			response := map[string]interface{}{
-				"name":  args.Name,
-				"state": state,
-			}
-			jsonResponse, err := json.Marshal(response)
-			if err != nil {
-				return mcp.NewToolResultError("Failed to marshal response"), nil
-			}
-			return mcp.NewToolResultText(string(jsonResponse)), nil
+	mcp_pkg.RegisterTypedTool(srv, resizeVMDiskTool, func(ctx context.Context, request mcp.CallToolRequest, args ResizeVMDiskArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		if args.SizeGB <= 0 {
+			return mcp.NewToolResultError("Missing required parameter: size_gb"), nil
 		}
-		vmNames, err := vmManager.ListVMs(ctx)
+		resizer, ok := vmManager.(interface {
+			ResizeDisk(ctx context.Context, name string, sizeGB int) (core.VMConfig, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support resizing disks"), nil
+		}
+		config, err := resizer.ResizeDisk(ctx, args.Name, int(args.SizeGB))
 		if err != nil {
-			return mcp.NewToolResultErrorf("Failed to list VMs: %v", err), nil
+			return mcp.NewToolResultErrorf("Failed to resize VM disk: %v", err), nil
 		}
-		vmStates := make([]map[string]interface{}, 0, len(vmNames))
-		for _, vmName := range vmNames {
-			state, err := vmManager.GetVMState(ctx, vmName)
-			var stateStr string
-			if err != nil {
-				stateStr = "unknown"
-			} else {
-				stateStr = string(state)
-			}
-			vmStates = append(vmStates, map[string]interface{}{
-				"name":  vmName,
-				"state": stateStr,
-			})
+		state, _ := vmManager.GetVMState(ctx, args.Name)
+		response := ResizeVMDiskResponse{
+			Name:       args.Name,
+			DiskSizeGB: config.DiskSizeGB,
+			State:      state,
 		}
-		response := map[string]interface{}{
-			"vms": vmStates,
+		if state != core.Running {
+			response.Message = "The disk is resized the next time the VM starts"
 		}
-		jsonResponse, err := json.Marshal(response)
+		jsonData, err := json.Marshal(response)
 		if err != nil {
 			return mcp.NewToolResultError("Failed to marshal response"), nil
 		}
-		return mcp.NewToolResultText(string(jsonResponse)), nil
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Provision VM tool
+	type ProvisionVMArgs struct {
+		Name         string   `json:"name"`
+		Provisioners []string `json:"provisioners"`
+	}
+	provisionVMTool := mcp.NewTool("provision_vm",
+		mcp.WithDescription("Rerun the provisioners of a running development VM with 'vagrant provision', all of them or only those named, without reloading it, reporting whether each provisioner succeeded"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithArray("provisioners",
+			mcp.Description("Names or types of the provisioners to run, e.g. setup or shell (default: all)"),
+			mcp.Items(map[string]any{"type": "string"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, provisionVMTool, func(ctx context.Context, request mcp.CallToolRequest, args ProvisionVMArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		provisioner, ok := vmManager.(interface {
+			ProvisionVM(ctx context.Context, name string, provisioners []string) (vm.ProvisionResult, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support provisioning"), nil
+		}
+		result, err := provisioner.ProvisionVM(ctx, args.Name, args.Provisioners)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to provision VM: %v", err), nil
+		}
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Configure firewall tool
+	type ConfigureFirewallArgs struct {
+		Name       string `json:"name"`
+		Enabled    bool   `json:"enabled"`
+		AllowPorts []int  `json:"allow_ports"`
+	}
+	configureFirewallTool := mcp.NewTool("configure_firewall",
+		mcp.WithDescription("Enable or disable the guest firewall of a development VM. When enabled, only SSH, the forwarded guest ports and allow_ports accept connections; VMs on the same shared network can reach every port. A running VM is changed in place"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithBoolean("enabled",
+			mcp.Description("Whether the firewall is enabled"),
+			mcp.DefaultBool(true)),
+		mcp.WithArray("allow_ports",
+			mcp.Description("Additional guest TCP ports to leave open; replaces the previous list"),
+			mcp.Items(map[string]any{"type": "number"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, configureFirewallTool, func(ctx context.Context, request mcp.CallToolRequest, args ConfigureFirewallArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		if _, ok := request.GetArguments()["enabled"]; !ok {
+			args.Enabled = true
+		}
+		firewaller, ok := vmManager.(interface {
+			SetFirewall(ctx context.Context, name string, firewall *core.Firewall) (core.VMConfig, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support configuring the firewall"), nil
+		}
+		var firewall *core.Firewall
+		if args.Enabled {
+			firewall = &core.Firewall{AllowPorts: args.AllowPorts}
+		}
+		config, err := firewaller.SetFirewall(ctx, args.Name, firewall)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to configure firewall: %v", err), nil
+		}
+		state, _ := vmManager.GetVMState(ctx, args.Name)
+		response := ConfigureFirewallResponse{
+			Name:    args.Name,
+			Enabled: config.Firewall != nil,
+			State:   state,
+		}
+		if config.Firewall != nil {
+			response.OpenPorts = vm.FirewallPorts(config)
+		}
+		if state != core.Running {
+			response.Message = "The firewall settings apply the next time the VM starts"
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Configure dotfiles tool
+	type ConfigureDotfilesArgs struct {
+		Name    string   `json:"name"`
+		Repo    string   `json:"repo"`
+		Ref     string   `json:"ref"`
+		Install string   `json:"install"`
+		Files   []string `json:"files"`
+	}
+	configureDotfilesTool := mcp.NewTool("configure_dotfiles",
+		mcp.WithDescription("Personalize a development VM with a dotfiles repository cloned to ~/.dotfiles and copies of selected host dotfiles. Only files in the server's allowlist can be copied, and each must be asked for. A running VM gets them right away; the settings replace the previous ones and apply again when the VM is recreated. Without repo and files the settings are removed, leaving the guest's copies"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("repo",
+			mcp.Description("Git URL of the dotfiles repository, e.g. https://github.com/me/dotfiles.git; it must be cloneable from the guest")),
+		mcp.WithString("ref",
+			mcp.Description("Branch, tag or commit to check out (default: the repository's default branch)")),
+		mcp.WithString("install",
+			mcp.Description("Script in the repository to run after cloning it, e.g. install.sh")),
+		mcp.WithArray("files",
+			mcp.Description("Host dotfiles, relative to the home directory, to copy to the same path in the guest, e.g. .gitconfig, .vimrc or .ssh/known_hosts"),
+			mcp.Items(map[string]any{"type": "string"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, configureDotfilesTool, func(ctx context.Context, request mcp.CallToolRequest, args ConfigureDotfilesArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		configurer, ok := vmManager.(interface {
+			SetDotfiles(ctx context.Context, name string, dotfiles *core.Dotfiles) (core.VMConfig, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support configuring dotfiles"), nil
+		}
+		var dotfiles *core.Dotfiles
+		if args.Repo != "" || args.Ref != "" || args.Install != "" || len(args.Files) > 0 {
+			dotfiles = &core.Dotfiles{Repo: args.Repo, Ref: args.Ref, Install: args.Install, Files: args.Files}
+		}
+		config, err := configurer.SetDotfiles(ctx, args.Name, dotfiles)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to configure dotfiles: %v", err), nil
+		}
+		state, _ := vmManager.GetVMState(ctx, args.Name)
+		response := ConfigureDotfilesResponse{
+			Name:     args.Name,
+			Dotfiles: config.Dotfiles,
+			State:    state,
+			Applied:  state == core.Running && config.Dotfiles != nil,
+			Allowed:  vm.DotfileAllowlist(),
+		}
+		if config.Dotfiles != nil && state != core.Running {
+			response.Message = "The dotfiles apply when the VM is next started after being created, or when configure_dotfiles runs again while it is running"
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
 	})
+
+	// Set Vagrantfile snippets tool
+	type SetSnippetsArgs struct {
+		Name     string                    `json:"name"`
+		Snippets []core.VagrantfileSnippet `json:"snippets"`
+	}
+	setSnippetsTool := mcp.NewTool("set_vagrantfile_snippets",
+		mcp.WithDescription("Replace the raw Ruby snippets added to the generated Vagrantfile of a development VM, for settings the other tools do not cover. The Vagrantfile is checked with vagrant validate and left unchanged if it fails. A running VM is reloaded so the snippets take effect"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithArray("snippets",
+			mcp.Required(),
+			mcp.Description("Snippets as {\"name\": \"gui\", \"section\": \"provider\", \"content\": \"<ruby>\"} objects, added inside Vagrant.configure after their section: box, provider, settings, network, sync or provisioning (default). An empty list removes them all"),
+			mcp.Items(map[string]any{"type": "object"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, setSnippetsTool, func(ctx context.Context, request mcp.CallToolRequest, args SetSnippetsArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		snippeter, ok := vmManager.(interface {
+			SetVagrantfileSnippets(ctx context.Context, name string, snippets []core.VagrantfileSnippet) (core.VMConfig, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support Vagrantfile snippets"), nil
+		}
+		config, err := snippeter.SetVagrantfileSnippets(ctx, args.Name, args.Snippets)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to set Vagrantfile snippets: %v", err), nil
+		}
+		state, _ := vmManager.GetVMState(ctx, args.Name)
+		response := SetSnippetsResponse{
+			Name:     args.Name,
+			Snippets: config.Snippets,
+			State:    state,
+		}
+		if state != core.Running {
+			response.Message = "The snippets apply the next time the VM starts"
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Adopt existing VM tool
+	type AdoptVMArgs struct {
+		Name       string `json:"name"`
+		VagrantDir string `json:"vagrant_dir"`
+		Machine    string `json:"machine"`
+	}
+	adoptVMTool := mcp.NewTool("adopt_existing_vm",
+		mcp.WithDescription("Register an existing Vagrant environment so the other tools can manage it, without generating a new Vagrantfile"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name to manage the VM under")),
+		mcp.WithString("vagrant_dir",
+			mcp.Required(),
+			mcp.Description("Directory containing the existing Vagrantfile")),
+		mcp.WithString("machine",
+			mcp.Description("Machine to adopt from a multi-machine Vagrantfile (optional when it defines a single machine)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, adoptVMTool, func(ctx context.Context, request mcp.CallToolRequest, args AdoptVMArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		if args.VagrantDir == "" {
+			return mcp.NewToolResultError("Missing required parameter: vagrant_dir"), nil
+		}
+		adopter, ok := vmManager.(interface {
+			AdoptVM(ctx context.Context, name, vagrantDir, machine string) (core.VMConfig, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support adopting existing Vagrant environments"), nil
+		}
+		config, err := adopter.AdoptVM(ctx, args.Name, args.VagrantDir, args.Machine)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to adopt VM: %v", err), nil
+		}
+		syncConfig := core.SyncConfig{
+			VMName:          args.Name,
+			ProjectPath:     config.ProjectPath,
+			Method:          core.SyncMethod(config.SyncType),
+			Direction:       core.SyncToVM,
+			ExcludePatterns: config.SyncExcludePatterns,
+		}
+		if err := syncEngine.RegisterVM(ctx, args.Name, syncConfig); err != nil {
+			log.Error().Err(err).Msg("Failed to register VM with sync engine")
+		}
+
+		response := AdoptVMResponse{
+			Name:        args.Name,
+			VagrantDir:  args.VagrantDir,
+			Machine:     vm.MachineName(vmManager.GetBaseDir(), args.Name),
+			Box:         config.Box,
+			CPU:         config.CPU,
+			Memory:      config.Memory,
+			Ports:       config.Ports,
+			ProjectPath: config.ProjectPath,
+			GuestPath:   config.GuestPath,
+			SyncType:    config.SyncType,
+		}
+		jsonData, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonData)), nil
+	})
+
+	// Destroy dev VM tool
+	type DestroyVMArgs struct {
+		Name          string   `json:"name"`
+		PreservePaths []string `json:"preserve_paths"`
+	}
+	destroyVMTool := mcp.NewTool("destroy_dev_vm",
+		mcp.WithDescription("Clean up development VM and associated resources"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithArray("preserve_paths",
+			mcp.Description("Absolute guest directories (e.g., package caches, dependency or database data directories) to archive before destroying and restore on the first boot of the recreated VM"),
+			mcp.Items(map[string]any{"type": "string"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, destroyVMTool, func(ctx context.Context, request mcp.CallToolRequest, args DestroyVMArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		destroy := func(ctx context.Context) (*mcp.CallToolResult, error) {
+			target := hookTarget(ctx, vmManager, args.Name)
+			hookResults, err := hooks.GlobalRunner.Run(ctx, hooks.PreDestroy, target)
+			if err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("VM was not destroyed: %v", err), hookResults), nil
+			}
+			message := fmt.Sprintf("VM '%s' destroyed", args.Name)
+			if len(args.PreservePaths) > 0 {
+				cache, err := saveWarmCache(ctx, vmManager, args.Name, args.PreservePaths)
+				if err != nil {
+					return withHookResults(mcp.NewToolResultErrorf("Failed to preserve guest directories, VM was not destroyed: %v", err), hookResults), nil
+				}
+				message += fmt.Sprintf("; preserved %s (%d bytes) for its next first boot", strings.Join(cache.Paths, ", "), cache.SizeBytes)
+			}
+			if err := vmManager.DestroyVM(ctx, args.Name); err != nil {
+				return withHookResults(mcp.NewToolResultErrorf("Failed to destroy VM: %v", err), hookResults), nil
+			}
+			postResults, _ := hooks.GlobalRunner.Run(ctx, hooks.PostDestroy, target)
+			return withHookResults(mcp.NewToolResultText(message), append(hookResults, postResults...)), nil
+		}
+		if approval.GlobalGate.Requires(approval.OperationDestroyVM) {
+			return approvalRequiredResult(approval.GlobalGate, approval.OperationDestroyVM, "destroy_dev_vm", args.Name, nil, destroy)
+		}
+		return destroy(ctx)
+	})
+
+	// Get VM status tool
+	type GetVMStatusArgs struct {
+		Name   string   `json:"name"`
+		State  string   `json:"state"`
+		Limit  int      `json:"limit"`
+		Cursor string   `json:"cursor"`
+		Sort   string   `json:"sort"`
+		Fields []string `json:"fields"`
+	}
+	getStatusTool := mcp.NewTool("get_vm_status",
+		mcp.WithDescription("Get status of one or all development VMs, including the lifecycle operation in progress on each. Without a name the VMs are paged; pass 'next_cursor' from the result's page as 'cursor' to continue"),
+		mcp.WithString("name",
+			mcp.Description("Name of the development VM (optional)")),
+		mcp.WithString("state",
+			mcp.Description("Only list VMs in this state, e.g. 'running' or 'stopped'")),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of VMs to list (default: %d, at most %d)", defaultVMStatusLimit, paging.MaxLimit))),
+		mcp.WithString("cursor",
+			mcp.Description("Continue the list after this cursor (the 'next_cursor' of a previous page)")),
+		mcp.WithString("sort",
+			mcp.Description("Field to sort the VMs by: 'name', 'state', 'created_at', 'last_used_at' or 'expires_at'; prefix with '-' for descending order")),
+		mcp.WithArray("fields",
+			mcp.Description("Fields to keep for each VM (default: all), e.g. ['name', 'state']"),
+			mcp.Items(map[string]any{"type": "string"})),
+	)
+	mcp_pkg.RegisterTypedTool(srv, getStatusTool, func(ctx context.Context, request mcp.CallToolRequest, args GetVMStatusArgs) (*mcp.CallToolResult, error) {
+		if args.Name != "" {
+			state, err := vmManager.GetVMState(ctx, args.Name)
+			if err != nil {
+				return mcp.NewToolResultErrorf("Failed to get VM status: %v", err), nil
+			}
+			jsonResponse, err := json.Marshal(vmStatus(ctx, vmManager, args.Name, state))
+			if err != nil {
+				return mcp.NewToolResultError("Failed to marshal response"), nil
+			}
+			return mcp.NewToolResultText(string(jsonResponse)), nil
+		}
+		listings, err := listVMs(ctx, vmManager)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to list VMs: %v", err), nil
+		}
+		if args.Limit == 0 {
+			args.Limit = defaultVMStatusLimit
+		}
+		// Querying a VM's state runs vagrant, so unless the list is filtered or sorted by the
+		// status only the VMs of the page are queried; the other fields come from the listing
+		byName := args.State == "" && strings.TrimPrefix(args.Sort, "-") != "state"
+		vmStates := make([]VMStatus, 0, len(listings))
+		for _, listing := range listings {
+			status := VMStatus{
+				Name:        listing.Name,
+				ExpiresAt:   listing.ExpiresAt,
+				ProjectPath: listing.ProjectPath,
+				Tags:        listing.Tags,
+				CreatedAt:   listing.CreatedAt,
+				LastUsedAt:  listing.LastUsedAt,
+			}
+			if !byName {
+				status = queryVMStatus(ctx, vmManager, listing.Name)
+				if args.State != "" && string(status.State) != args.State {
+					continue
+				}
+			}
+			vmStates = append(vmStates, status)
+		}
+		vmStates, page, err := paging.Apply(vmStates, paging.Params{Limit: args.Limit, Cursor: args.Cursor, Sort: args.Sort, Fields: args.Fields}, "state="+args.State)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Invalid paging parameters: %v", err), nil
+		}
+		if byName {
+			for i := range vmStates {
+				vmStates[i] = queryVMStatus(ctx, vmManager, vmStates[i].Name)
+			}
+		}
+		response := VMStatusListResponse{
+			VMs:  vmStates,
+			Page: page,
+		}
+		jsonResponse, err := paging.Marshal(response, "vms", args.Fields)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Set VM TTL tool
+	type SetVMTTLArgs struct {
+		Name string `json:"name"`
+		TTL  string `json:"ttl"`
+	}
+	setVMTTLTool := mcp.NewTool("set_vm_ttl",
+		mcp.WithDescription("Set or extend the time to live of a development VM, counted from now, after which it is halted or destroyed; an expired VM can be started again once extended"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("ttl",
+			mcp.Required(),
+			mcp.Description("Time to live from now, e.g. 4h; 0 keeps the VM until destroyed")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, setVMTTLTool, func(ctx context.Context, request mcp.CallToolRequest, args SetVMTTLArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" || args.TTL == "" {
+			return mcp.NewToolResultError("Missing required parameter: name or ttl"), nil
+		}
+		expiresAt, err := vmExpiry(args.TTL)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		_, err = updateVMConfig(ctx, vmManager, args.Name, func(config *core.VMConfig) error {
+			config.ExpiresAt = expiresAt
+			return nil
+		})
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to update VM config: %v", err), nil
+		}
+		response := SetVMTTLResponse{
+			Name:      args.Name,
+			ExpiresAt: expiresAt,
+			Action:    expiry.GlobalReaper.Action(),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Get SSH info tool
+	type GetSSHInfoArgs struct {
+		Name string `json:"name"`
+	}
+	getSSHInfoTool := mcp.NewTool("get_ssh_info",
+		mcp.WithDescription("Get the host, port, user and identity file to connect to a running development VM with ssh, with a ready-to-paste ssh command and ~/.ssh/config entry"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, getSSHInfoTool, func(ctx context.Context, request mcp.CallToolRequest, args GetSSHInfoArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		state, err := vmManager.GetVMState(ctx, args.Name)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get VM state: %v", err), nil
+		}
+		if state != core.Running {
+			return mcp.NewToolResultErrorf("VM must be running to get its SSH configuration (current state: %s)", state), nil
+		}
+		sshConfig, err := vmSSHConfig(ctx, vmManager, args.Name)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get SSH configuration: %v", err), nil
+		}
+		response := SSHInfoResponse{
+			Name: args.Name,
+			SSH:  vm.NewSSHInfo(args.Name, sshConfig),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Get VM info tool
+	type GetVMInfoArgs struct {
+		Name string `json:"name"`
+	}
+	getVMInfoTool := mcp.NewTool("get_vm_info",
+		mcp.WithDescription("Get the full details of a development VM in one document: state, provider and its machine ID, box and box version, resources, forwarded ports, SSH connection, what VirtualBox (VBoxManage showvminfo) or libvirt (virsh dominfo) reports about the machine and, when it is running, guest facts such as IP addresses, uptime, OS, memory and root disk usage"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, getVMInfoTool, func(ctx context.Context, request mcp.CallToolRequest, args GetVMInfoArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		infoer, ok := vmManager.(interface {
+			GetVMInfo(ctx context.Context, name string) (vm.VMInfo, error)
+		})
+		if !ok {
+			return mcp.NewToolResultError("VM manager does not support VM details"), nil
+		}
+		info, err := infoer.GetVMInfo(ctx, args.Name)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to get VM info: %v", err), nil
+		}
+		jsonResponse, err := json.Marshal(info)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Get VM operation log tool
+	type GetOperationLogArgs struct {
+		Name      string  `json:"name"`
+		Operation string  `json:"operation"`
+		Lines     float64 `json:"lines"`
+	}
+	getOperationLogTool := mcp.NewTool("get_vm_operation_log",
+		mcp.WithDescription("Get the transcript of the last vagrant up, provision, halt or destroy run for a VM"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithString("operation",
+			mcp.Description("Operation whose log to return"),
+			mcp.Enum(core.OperationLogs...),
+			mcp.DefaultString(core.OperationLogUp)),
+		mcp.WithNumber("lines",
+			mcp.Description("Only return the last N lines (default: the whole log)")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, getOperationLogTool, func(ctx context.Context, request mcp.CallToolRequest, args GetOperationLogArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		operation := args.Operation
+		if operation == "" {
+			operation = core.OperationLogUp
+		}
+		if !slices.Contains(core.OperationLogs, operation) {
+			return mcp.NewToolResultErrorf("Invalid operation '%s' (must be one of %s)", operation, strings.Join(core.OperationLogs, ", ")), nil
+		}
+
+		path := core.OperationLogPath(vmManager.GetBaseDir(), args.Name, operation)
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return mcp.NewToolResultErrorf("No %s log recorded for VM '%s'", operation, args.Name), nil
+			}
+			return mcp.NewToolResultErrorf("Failed to read %s log: %v", operation, err), nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to read %s log: %v", operation, err), nil
+		}
+
+		content := string(data)
+		truncated := false
+		if args.Lines > 0 {
+			lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+			if len(lines) > int(args.Lines) {
+				content = strings.Join(lines[len(lines)-int(args.Lines):], "\n")
+				truncated = true
+			}
+		}
+
+		response := OperationLogResponse{
+			Name:      args.Name,
+			Operation: operation,
+			Path:      path,
+			Modified:  info.ModTime(),
+			Size:      info.Size(),
+			Truncated: truncated,
+			Content:   content,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Lint Vagrantfile tool
+	type LintVagrantfileArgs struct {
+		Name string `json:"name"`
+	}
+	lintVagrantfileTool := mcp.NewTool("lint_vagrantfile",
+		mcp.WithDescription("Check a VM's Vagrantfile for insecure synced folder options, unpinned box versions, deprecated options and port collisions with other managed VMs"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, lintVagrantfileTool, func(ctx context.Context, request mcp.CallToolRequest, args LintVagrantfileArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		baseDir := vmManager.GetBaseDir()
+		path := filepath.Join(vm.VMDir(baseDir, args.Name), "Vagrantfile")
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return mcp.NewToolResultErrorf("VM '%s' has no Vagrantfile", args.Name), nil
+			}
+			return mcp.NewToolResultErrorf("Failed to read Vagrantfile: %v", err), nil
+		}
+
+		findings := vm.LintVagrantfile(string(content), otherVMHostPorts(baseDir, args.Name))
+		summary := map[string]int{
+			vm.LintSeverityError:   0,
+			vm.LintSeverityWarning: 0,
+			vm.LintSeverityInfo:    0,
+		}
+		for _, finding := range findings {
+			summary[finding.Severity]++
+		}
+
+		response := LintVagrantfileResponse{
+			Name:     args.Name,
+			Path:     path,
+			Findings: findings,
+			Summary:  summary,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Get boot report tool
+	type GetBootReportArgs struct {
+		Name    string  `json:"name"`
+		History float64 `json:"history"`
+	}
+	getBootReportTool := mcp.NewTool("get_boot_report",
+		mcp.WithDescription("Get phase timings (box download, import, boot, SSH ready, each provisioner) of a VM's last bring-up, compared with previous boots of the same box to flag regressions"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the development VM")),
+		mcp.WithNumber("history",
+			mcp.Description("Number of earlier boots of this VM to include"),
+			mcp.DefaultNumber(5)),
+	)
+	mcp_pkg.RegisterTypedTool(srv, getBootReportTool, func(ctx context.Context, request mcp.CallToolRequest, args GetBootReportArgs) (*mcp.CallToolResult, error) {
+		if args.Name == "" {
+			return mcp.NewToolResultError("Missing required parameter: name"), nil
+		}
+		historyLimit := int(args.History)
+		if historyLimit <= 0 {
+			historyLimit = 5
+		}
+
+		reports, err := vm.LoadBootReports(vmManager.GetBaseDir())
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to load boot reports: %v", err), nil
+		}
+		var vmReports []vm.BootReport
+		for _, report := range reports {
+			if report.VMName == args.Name {
+				vmReports = append(vmReports, report)
+			}
+		}
+		if len(vmReports) == 0 {
+			return mcp.NewToolResultErrorf("No boot recorded for VM '%s' yet", args.Name), nil
+		}
+
+		latest := vmReports[len(vmReports)-1]
+		previous := vmReports[:len(vmReports)-1]
+		if len(previous) > historyLimit {
+			previous = previous[len(previous)-historyLimit:]
+		}
+
+		response := BootReportResponse{
+			Name:       args.Name,
+			Latest:     latest,
+			Comparison: vm.CompareBootReport(latest, reports),
+			History:    previous,
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// List port profiles tool
+	listPortProfilesTool := mcp.NewTool("list_port_profiles",
+		mcp.WithDescription("List the named port profiles that create_dev_vm can forward"),
+	)
+	srv.AddTool(listPortProfilesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		response := PortProfilesResponse{
+			Profiles:     config.GlobalPortProfiles.ListProfiles(),
+			Default:      config.DefaultPortProfile,
+			ProfilesFile: config.PortProfilesFile(),
+		}
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+
+	// Detect project tool
+	type DetectProjectArgs struct {
+		ProjectPath string `json:"project_path"`
+	}
+	detectProjectTool := mcp.NewTool("detect_project",
+		mcp.WithDescription("Inspect a host project (package.json, go.mod, requirements.txt, Gemfile, Dockerfile, docker-compose.yml) and recommend the VM configuration, runtimes, tools and ports it needs. create_dev_vm applies the recommendation with auto_bootstrap"),
+		mcp.WithString("project_path",
+			mcp.Required(),
+			mcp.Description("Path to the project directory")),
+	)
+	mcp_pkg.RegisterTypedTool(srv, detectProjectTool, func(ctx context.Context, request mcp.CallToolRequest, args DetectProjectArgs) (*mcp.CallToolResult, error) {
+		if args.ProjectPath == "" {
+			return mcp.NewToolResultError("Missing required parameter: project_path"), nil
+		}
+		detection, err := project.Detect(args.ProjectPath)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Failed to detect project: %v", err), nil
+		}
+		jsonResponse, err := json.Marshal(detection)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to marshal response"), nil
+		}
+		return mcp.NewToolResultText(string(jsonResponse)), nil
+	})
+}
+
+// usedHostPorts returns the host ports forwarded by every managed VM except vmName
+func usedHostPorts(baseDir, vmName string) map[int]bool {
+	used := make(map[int]bool)
+	for _, hostPorts := range otherVMHostPorts(baseDir, vmName) {
+		for _, port := range hostPorts {
+			used[port] = true
+		}
+	}
+	return used
+}
+
+// otherVMHostPorts returns the host ports forwarded by every managed VM except vmName
+func otherVMHostPorts(baseDir, vmName string) map[string][]int {
+	ports := make(map[string][]int)
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return ports
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == vmName || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(vm.VMDir(baseDir, entry.Name()), "Vagrantfile"))
+		if err != nil {
+			continue
+		}
+		if hostPorts := vm.ForwardedHostPorts(string(content)); len(hostPorts) > 0 {
+			ports[entry.Name()] = hostPorts
+		}
+	}
+	return ports
+}
+
+// hostPortAvailable reports whether a host port can currently be bound on localhost
+func hostPortAvailable(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// maxConfigUpdateAttempts bounds the attempts of a VM config update that races other updates
+const maxConfigUpdateAttempts = 3
+
+// updateVMConfig applies change to the current configuration of a VM and saves it. An update
+// saved between the read and the save makes the save conflict; it is retried on the newer
+// configuration so neither update is lost.
+func updateVMConfig(ctx context.Context, vmManager core.VMManager, name string, change func(config *core.VMConfig) error) (core.VMConfig, error) {
+	var err error
+	for attempt := 0; attempt < maxConfigUpdateAttempts; attempt++ {
+		var config core.VMConfig
+		if config, err = vmManager.GetVMConfig(ctx, name); err != nil {
+			return core.VMConfig{}, err
+		}
+		if err = change(&config); err != nil {
+			return core.VMConfig{}, err
+		}
+		if err = vmManager.UpdateVMConfig(ctx, name, config); !errors.IsConflict(err) {
+			return config, err
+		}
+	}
+	return core.VMConfig{}, err
+}
+
+// vmExpiry returns when a VM given a time to live from now expires: the default time to live
+// when ttl is empty, and never when it is 0
+func vmExpiry(ttl string) (*time.Time, error) {
+	if ttl == "" {
+		return expiry.GlobalReaper.ExpiresAt(expiry.GlobalReaper.DefaultTTL()), nil
+	}
+	duration, err := time.ParseDuration(ttl)
+	if ttl != "0" && (err != nil || duration <= 0) {
+		return nil, fmt.Errorf("invalid ttl '%s': use a positive duration such as 8h, or 0", ttl)
+	}
+	if duration > 0 && duration < expiry.MinTTL {
+		return nil, fmt.Errorf("invalid ttl '%s': a time to live is at least %s", ttl, expiry.MinTTL)
+	}
+	return expiry.GlobalReaper.ExpiresAt(duration), nil
+}
+
+// hookTarget returns the hook target of an existing VM
+func hookTarget(ctx context.Context, vmManager core.VMManager, name string) hooks.Target {
+	target := hooks.Target{VMName: name}
+	if config, err := vmManager.GetVMConfig(ctx, name); err == nil {
+		target.ProjectPath = config.ProjectPath
+	}
+	return target
+}
+
+// withHookResults attaches the results of the lifecycle hooks a tool ran to its response, as
+// a second content item holding {"hooks": [...]}
+func withHookResults(result *mcp.CallToolResult, results []hooks.Result) *mcp.CallToolResult {
+	if result == nil || len(results) == 0 {
+		return result
+	}
+	jsonData, err := json.Marshal(HookResults{Hooks: results})
+	if err != nil {
+		return result
+	}
+	result.Content = append(result.Content, mcp.NewTextContent(string(jsonData)))
+	return result
+}
+
+// queryVMStatus returns the status of a VM, whose state is unknown when it cannot be queried
+func queryVMStatus(ctx context.Context, vmManager core.VMManager, name string) VMStatus {
+	state, err := vmManager.GetVMState(ctx, name)
+	if err != nil {
+		state = core.Unknown
+	}
+	return vmStatus(ctx, vmManager, name, state)
+}
+
+// vmStatus returns the status of a VM in a given state
+func vmStatus(ctx context.Context, vmManager core.VMManager, name string, state core.VMState) VMStatus {
+	status := VMStatus{Name: name, State: state}
+	if operation, ok := currentOperation(vmManager, name); ok {
+		status.Operation = &operation
+	}
+	if config, err := vmManager.GetVMConfig(ctx, name); err == nil {
+		status.ExpiresAt = config.ExpiresAt
+		status.ProjectPath = config.ProjectPath
+		status.Tags = config.Tags
+		status.CreatedAt = config.CreatedAt
+	}
+	if tracker, ok := vmManager.(interface {
+		LastUsed(name string) (time.Time, bool)
+	}); ok {
+		if lastUsed, ok := tracker.LastUsed(name); ok {
+			status.LastUsedAt = &lastUsed
+		}
+	}
+	return status
+}
+
+// listVMs returns the VMs with their saved configuration when the VM manager lists it, or only
+// their names
+func listVMs(ctx context.Context, vmManager core.VMManager) ([]vm.VMListing, error) {
+	if lister, ok := vmManager.(interface {
+		ListVMDetails(ctx context.Context) ([]vm.VMListing, error)
+	}); ok {
+		return lister.ListVMDetails(ctx)
+	}
+	names, err := vmManager.ListVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listings := make([]vm.VMListing, 0, len(names))
+	for _, name := range names {
+		listings = append(listings, vm.VMListing{Name: name})
+	}
+	return listings, nil
+}
+
+// currentOperation returns the lifecycle operation running on a VM when the VM manager tracks them
+func currentOperation(vmManager core.VMManager, name string) (vm.Operation, bool) {
+	tracker, ok := vmManager.(interface {
+		CurrentOperation(name string) (vm.Operation, bool)
+	})
+	if !ok {
+		return vm.Operation{}, false
+	}
+	return tracker.CurrentOperation(name)
+}
+
+// vmSSHConfig returns the SSH configuration of a VM when the VM manager exposes it
+func vmSSHConfig(ctx context.Context, vmManager core.VMManager, name string) (map[string]string, error) {
+	provider, ok := vmManager.(interface {
+		GetSSHConfig(context.Context, string) (map[string]string, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("SSH configuration is not available for this VM manager")
+	}
+	return provider.GetSSHConfig(ctx, name)
+}
+
+// vmSSHArgs returns the ssh arguments for a VM when the VM manager exposes its SSH configuration
+func vmSSHArgs(ctx context.Context, vmManager core.VMManager, name string) ([]string, error) {
+	sshConfig, err := vmSSHConfig(ctx, vmManager, name)
+	if err != nil {
+		return nil, err
+	}
+	return vm.SSHArgs(sshConfig), nil
+}
+
+// awaitReady waits until a running VM has finished booting, returning what to add to the
+// message reporting it running, or an error result when it did not become ready in time
+func awaitReady(ctx context.Context, vmManager core.VMManager, name string, timeout time.Duration) (string, *mcp.CallToolResult) {
+	if timeout <= 0 {
+		return "", nil
+	}
+	sshArgs, err := vmSSHArgs(ctx, vmManager, name)
+	if err != nil {
+		return fmt.Sprintf("; readiness was not checked: %v", err), nil
+	}
+	readiness, err := vm.WaitUntilReady(ctx, name, sshArgs, timeout)
+	if err != nil {
+		return "", mcp.NewToolResultErrorf("Stopped waiting for VM '%s' to be ready: %v", name, err)
+	}
+	if !readiness.Ready {
+		return "", mcp.NewToolResultErrorf("VM '%s' is running but not ready after %s: %s", name, time.Duration(readiness.WaitedSeconds*float64(time.Second)).Round(time.Second), readiness.Describe())
+	}
+	message := ""
+	if readiness.WaitedSeconds >= 1 {
+		message = fmt.Sprintf("; ready after %.0fs", readiness.WaitedSeconds)
+	}
+	if len(readiness.FailedUnits) > 0 {
+		message += "; failed units: " + strings.Join(readiness.FailedUnits, ", ")
+	}
+	return message, nil
+}
+
+// saveWarmCache archives guest directories of a running VM before it is destroyed
+func saveWarmCache(ctx context.Context, vmManager core.VMManager, name string, paths []string) (vm.WarmCache, error) {
+	state, err := vmManager.GetVMState(ctx, name)
+	if err != nil {
+		return vm.WarmCache{}, err
+	}
+	if state != core.Running {
+		return vm.WarmCache{}, fmt.Errorf("VM must be running (current state: %s)", state)
+	}
+	sshArgs, err := vmSSHArgs(ctx, vmManager, name)
+	if err != nil {
+		return vm.WarmCache{}, err
+	}
+	box := ""
+	if vmConfig, err := vmManager.GetVMConfig(ctx, name); err == nil {
+		box = vmConfig.Box
+	}
+	return vm.SaveWarmCache(ctx, vmManager.GetBaseDir(), name, box, sshArgs, paths)
+}
+
+// restoreWarmCache restores the warm cache of a freshly created VM and describes the outcome
+// as a suffix for the tool result; it returns an empty string when there is no warm cache
+func restoreWarmCache(ctx context.Context, vmManager core.VMManager, name string) string {
+	baseDir := vmManager.GetBaseDir()
+	cache, err := vm.LoadWarmCache(baseDir, name)
+	if err != nil {
+		return fmt.Sprintf("; warm cache could not be read: %v", err)
+	}
+	if cache == nil {
+		return ""
+	}
+	// Caches of compiled dependencies rarely carry over to a different box
+	if vmConfig, err := vmManager.GetVMConfig(ctx, name); err == nil && cache.Box != "" && vmConfig.Box != cache.Box {
+		return fmt.Sprintf("; warm cache skipped because it was taken from box '%s', not '%s'", cache.Box, vmConfig.Box)
+	}
+	sshArgs, err := vmSSHArgs(ctx, vmManager, name)
+	if err == nil {
+		cache, err = vm.RestoreWarmCache(ctx, baseDir, name, sshArgs)
+	}
+	if err != nil {
+		return fmt.Sprintf("; warm cache was kept but could not be restored: %v", err)
+	}
+	return fmt.Sprintf("; restored warm cache of %s", strings.Join(cache.Paths, ", "))
 }
@@ -10,8 +10,13 @@ import (
 	"time"
 
 	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
 	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/sync"
 	testfixture "github.com/vagrant-mcp/server/internal/testing"
+	"github.com/vagrant-mcp/server/internal/vm"
 	"github.com/vagrant-mcp/server/pkg/mcp"
 )
 
@@ -282,3 +287,142 @@ func TestHandleCreateDevVM_ValidationError(t *testing.T) {
 		}
 	}
 }
+
+func TestEnsureDevVMRecreatesDestroyedVMWithWarmCache(t *testing.T) {
+	ctx := context.Background()
+	baseDir := filepath.Join(t.TempDir(), "vms")
+	fake := cmdexec.NewFakeVagrant()
+	manager, err := vm.NewManagerWithRunner(baseDir, fake)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	engine, err := sync.NewEngine()
+	if err != nil {
+		t.Fatalf("Failed to create sync engine: %v", err)
+	}
+	srv := server.NewMCPServer("test", "1.0")
+	RegisterVMTools(srv, &exec.VMManagerAdapter{Real: manager}, &exec.SyncEngineAdapter{Real: engine})
+
+	ensure := func() (string, bool) {
+		t.Helper()
+		request, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "tools/call",
+			"params": map[string]any{
+				"name":      "ensure_dev_vm",
+				"arguments": map[string]any{"name": "warm-vm", "project_path": t.TempDir(), "ready_timeout": "0"},
+			},
+		})
+		message := srv.HandleMessage(ctx, request)
+		response, ok := message.(mcpgo.JSONRPCResponse)
+		if !ok {
+			t.Fatalf("Expected a response but got %T", message)
+		}
+		result, ok := response.Result.(mcpgo.CallToolResult)
+		if !ok {
+			t.Fatalf("Expected a tool result but got %T", response.Result)
+		}
+		return extractTextContent(result.Content), result.IsError
+	}
+
+	if text, isError := ensure(); isError || !strings.Contains(text, "created and started") {
+		t.Fatalf("Expected the VM to be created and started but got %q", text)
+	}
+	if err := manager.DestroyVM(ctx, "warm-vm"); err != nil {
+		t.Fatalf("Failed to destroy VM: %v", err)
+	}
+	// The warm cache destroy_dev_vm would have saved
+	cacheDir := filepath.Join(baseDir, ".warm-cache")
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		t.Fatalf("Failed to create warm cache directory: %v", err)
+	}
+	archive := filepath.Join(cacheDir, "warm-vm.tar.gz")
+	if err := os.WriteFile(archive, nil, 0644); err != nil {
+		t.Fatalf("Failed to write warm cache archive: %v", err)
+	}
+	manifest, _ := json.Marshal(vm.WarmCache{VMName: "warm-vm", Paths: []string{"/home/vagrant/.cache"}, Archive: archive})
+	if err := os.WriteFile(filepath.Join(cacheDir, "warm-vm.json"), manifest, 0644); err != nil {
+		t.Fatalf("Failed to write warm cache manifest: %v", err)
+	}
+
+	text, isError := ensure()
+	if isError || !strings.Contains(text, "created and started") {
+		t.Fatalf("Expected the destroyed VM to be created and started again but got %q", text)
+	}
+	// The fake provider has no SSH server, so the restore is attempted and the cache kept
+	if !strings.Contains(text, "warm cache") {
+		t.Errorf("Expected the warm cache to be restored on the first boot but got %q", text)
+	}
+	if state, err := manager.GetVMState(ctx, "warm-vm"); err != nil || state != core.Running {
+		t.Errorf("Expected the recreated VM to be running but got %s (%v)", state, err)
+	}
+}
+
+func TestUpdateVMConfigRetriesConcurrentUpdate(t *testing.T) {
+	ctx := context.Background()
+	manager, err := vm.NewManagerWithRunner(filepath.Join(t.TempDir(), "vms"), cmdexec.NewFakeVagrant())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.CreateVM(ctx, "race-vm", t.TempDir(), core.VMConfig{Name: "race-vm", Box: "ubuntu/jammy64"}); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+	before, err := manager.GetVMConfig(ctx, "race-vm")
+	if err != nil {
+		t.Fatalf("Failed to get VM config: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	attempts := 0
+	_, err = updateVMConfig(ctx, manager, "race-vm", func(config *core.VMConfig) error {
+		attempts++
+		if attempts == 1 {
+			// Another update is saved between this update's read and its save
+			if _, err := updateVMConfig(ctx, manager, "race-vm", func(config *core.VMConfig) error {
+				config.SyncType = "rsync"
+				return nil
+			}); err != nil {
+				t.Fatalf("Failed to apply the concurrent update: %v", err)
+			}
+		}
+		config.ExpiresAt = &expiresAt
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the update to succeed but got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected the conflicting update to be retried once but got %d attempts", attempts)
+	}
+
+	after, err := manager.GetVMConfig(ctx, "race-vm")
+	if err != nil {
+		t.Fatalf("Failed to get VM config: %v", err)
+	}
+	if after.SyncType != "rsync" {
+		t.Errorf("Expected the concurrent sync type update to persist but got %q", after.SyncType)
+	}
+	if after.ExpiresAt == nil || !after.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected the expiry update to persist but got %v", after.ExpiresAt)
+	}
+	if after.Revision != before.Revision+2 {
+		t.Errorf("Expected revision %d but got %d", before.Revision+2, after.Revision)
+	}
+}
+
+func TestVMExpiryRejectsShortTTL(t *testing.T) {
+	for _, ttl := range []string{"1s", "4m59s", "-1h", "soon"} {
+		if _, err := vmExpiry(ttl); err == nil {
+			t.Errorf("Expected ttl %q to be rejected", ttl)
+		}
+	}
+	for _, ttl := range []string{"5m", "8h"} {
+		if expiresAt, err := vmExpiry(ttl); err != nil || expiresAt == nil {
+			t.Errorf("Expected ttl %q to set an expiry but got %v, %v", ttl, expiresAt, err)
+		}
+	}
+	if expiresAt, err := vmExpiry("0"); err != nil || expiresAt != nil {
+		t.Errorf("Expected ttl 0 to clear the expiry but got %v, %v", expiresAt, err)
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package hooks runs user-configured commands at VM lifecycle points
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/hostos"
+)
+
+// Lifecycle points hooks run at
+const (
+	PreCreate   = "pre_create"
+	PostCreate  = "post_create"
+	PreUp       = "pre_up"
+	PostUp      = "post_up"
+	PreSync     = "pre_sync"
+	PostSync    = "post_sync"
+	PreDestroy  = "pre_destroy"
+	PostDestroy = "post_destroy"
+)
+
+// Events lists the lifecycle points
+var Events = []string{PreCreate, PostCreate, PreUp, PostUp, PreSync, PostSync, PreDestroy, PostDestroy}
+
+// guestlessEvents are the lifecycle points at which the VM is not running, so only host hooks
+// can run
+var guestlessEvents = map[string]bool{PreCreate: true, PreUp: true, PostDestroy: true}
+
+// DefaultTimeout bounds a hook that sets no timeout
+const DefaultTimeout = 5 * time.Minute
+
+// maxOutputBytes bounds the output of a hook kept in its result
+const maxOutputBytes = 8 * 1024
+
+// Targets of a hook command
+const (
+	TargetHost  = "host"
+	TargetGuest = "guest"
+)
+
+// GlobalRunner is the hook runner shared by the server
+var GlobalRunner = NewRunnerFromEnv()
+
+// Hook is a command run at a lifecycle point, on the host or in the VM
+type Hook struct {
+	Name  string `json:"name"`
+	Event string `json:"event"`
+	// Host is run with sh -c, or cmd /C on Windows, in the project directory
+	Host string `json:"host,omitempty"`
+	// Guest is run in the VM in /vagrant
+	Guest string `json:"guest,omitempty"`
+	// VMs lists globs of the VM names the hook runs for; every VM when empty
+	VMs []string `json:"vms,omitempty"`
+	// Timeout is a duration such as "2m"; DefaultTimeout when empty
+	Timeout string `json:"timeout,omitempty"`
+	// Abort makes a failing pre_* hook stop the operation
+	Abort bool `json:"abort,omitempty"`
+}
+
+// Config is the hooks file
+type Config struct {
+	Hooks []Hook `json:"hooks"`
+}
+
+// Target is the VM a lifecycle point happens to
+type Target struct {
+	VMName      string
+	ProjectPath string
+}
+
+// Result is the outcome of a hook
+type Result struct {
+	Name       string `json:"name"`
+	Event      string `json:"event"`
+	Target     string `json:"target"`
+	ExitCode   int    `json:"exit_code"`
+	Output     string `json:"output,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Aborted is set on the failed hook that stopped the operation
+	Aborted bool `json:"aborted,omitempty"`
+}
+
+// Failed reports whether the hook did not run to a zero exit code
+func (r Result) Failed() bool {
+	return r.ExitCode != 0 || r.TimedOut || r.Error != ""
+}
+
+// GuestExecutor runs commands in a VM
+type GuestExecutor interface {
+	ExecuteCommand(ctx context.Context, command string, execCtx exec.ExecutionContext, callback exec.OutputCallback) (*exec.CommandResult, error)
+}
+
+// Runner runs the configured hooks
+type Runner struct {
+	mu       sync.RWMutex
+	hooks    []Hook
+	source   string
+	executor GuestExecutor
+}
+
+// NewRunnerFromEnv creates a runner with the hooks in MCP_HOOKS_FILE, or
+// ~/.vagrant-mcp/hooks.json when it is not set. Without a valid file no hooks run.
+func NewRunnerFromEnv() *Runner {
+	runner := &Runner{}
+	file := File()
+	if file == "" {
+		return runner
+	}
+	if err := runner.LoadFile(file); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("file", file).Msg("Failed to load lifecycle hooks; no hooks will run")
+	}
+	return runner
+}
+
+// File returns the path of the hooks file
+func File() string {
+	if file := os.Getenv("MCP_HOOKS_FILE"); file != "" {
+		return file
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".vagrant-mcp", "hooks.json")
+}
+
+// LoadFile replaces the hooks with the ones in a JSON file
+func (r *Runner) LoadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse hooks file: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = config.Hooks
+	r.source = file
+	log.Info().Str("file", file).Int("hooks", len(config.Hooks)).Msg("Loaded lifecycle hooks")
+	return nil
+}
+
+// SetHooks replaces the hooks
+func (r *Runner) SetHooks(hooks []Hook) error {
+	if err := (Config{Hooks: hooks}).Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = hooks
+	return nil
+}
+
+// SetExecutor sets the executor guest hooks run with
+func (r *Runner) SetExecutor(executor GuestExecutor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executor = executor
+}
+
+// Validate checks the hooks of a hooks file
+func (c Config) Validate() error {
+	for i, hook := range c.Hooks {
+		name := hook.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		known := false
+		for _, event := range Events {
+			known = known || hook.Event == event
+		}
+		if !known {
+			return fmt.Errorf("invalid event %q in hook %s: use %s", hook.Event, name, strings.Join(Events, ", "))
+		}
+		if (hook.Host == "") == (hook.Guest == "") {
+			return fmt.Errorf("hook %s must set exactly one of host or guest", name)
+		}
+		if hook.Guest != "" && guestlessEvents[hook.Event] {
+			return fmt.Errorf("hook %s cannot run in the guest at %s, when the VM is not running", name, hook.Event)
+		}
+		if hook.Timeout != "" {
+			if timeout, err := time.ParseDuration(hook.Timeout); err != nil || timeout <= 0 {
+				return fmt.Errorf("invalid timeout %q in hook %s", hook.Timeout, name)
+			}
+		}
+		for _, pattern := range hook.VMs {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid VM pattern %q in hook %s: %w", pattern, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// For returns the hooks that run for a VM at a lifecycle point, in file order
+func (r *Runner) For(event, vmName string) []Hook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var matched []Hook
+	for _, hook := range r.hooks {
+		if hook.Event == event && hook.matches(vmName) {
+			matched = append(matched, hook)
+		}
+	}
+	return matched
+}
+
+// matches reports whether a hook runs for a VM
+func (h Hook) matches(vmName string) bool {
+	if len(h.VMs) == 0 {
+		return true
+	}
+	for _, pattern := range h.VMs {
+		if matched, _ := path.Match(pattern, vmName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// timeout returns how long a hook may run
+func (h Hook) timeout() time.Duration {
+	if timeout, err := time.ParseDuration(h.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return DefaultTimeout
+}
+
+// Run runs the hooks of a lifecycle point in order and returns their results. A failed hook
+// with abort set at a pre_* point stops the remaining hooks and returns an error, which the
+// caller reports instead of running the operation.
+func (r *Runner) Run(ctx context.Context, event string, target Target) ([]Result, error) {
+	var results []Result
+	for _, hook := range r.For(event, target.VMName) {
+		result := r.run(ctx, hook, target)
+		if result.Failed() {
+			log.Warn().Str("hook", hook.Name).Str("event", event).Str("vm", target.VMName).Int("exit_code", result.ExitCode).Str("error", result.Error).Msg("Lifecycle hook failed")
+		}
+		if result.Failed() && hook.Abort && strings.HasPrefix(event, "pre_") {
+			result.Aborted = true
+			results = append(results, result)
+			return results, fmt.Errorf("%s hook '%s' failed: %s", event, hook.Name, result.summary())
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// run runs a hook and returns its result
+func (r *Runner) run(ctx context.Context, hook Hook, target Target) Result {
+	result := Result{Name: hook.Name, Event: hook.Event, Target: TargetHost}
+	ctx, cancel := context.WithTimeout(ctx, hook.timeout())
+	defer cancel()
+	env := map[string]string{
+		"VAGRANT_MCP_EVENT":   hook.Event,
+		"VAGRANT_MCP_VM":      target.VMName,
+		"VAGRANT_MCP_PROJECT": target.ProjectPath,
+	}
+
+	started := time.Now()
+	var output string
+	var err error
+	if hook.Guest != "" {
+		result.Target = TargetGuest
+		output, result.ExitCode, err = r.runGuest(ctx, hook, target, env)
+	} else {
+		output, result.ExitCode, err = runHost(ctx, hook, target, env)
+	}
+	result.DurationMs = time.Since(started).Milliseconds()
+	result.Output = truncate(output)
+	if ctx.Err() == context.DeadlineExceeded {
+		result.TimedOut = true
+		result.Error = fmt.Sprintf("timed out after %s", hook.timeout())
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runGuest runs a guest hook with the runner's executor
+func (r *Runner) runGuest(ctx context.Context, hook Hook, target Target, env map[string]string) (string, int, error) {
+	r.mu.RLock()
+	executor := r.executor
+	r.mu.RUnlock()
+	if executor == nil {
+		return "", -1, fmt.Errorf("guest hooks are not available")
+	}
+	execCtx := exec.ExecutionContext{
+		VMName:      target.VMName,
+		WorkingDir:  "/vagrant",
+		Environment: env,
+		Timeout:     hook.timeout(),
+	}
+	result, err := executor.ExecuteCommand(ctx, hook.Guest, execCtx, nil)
+	if result == nil {
+		return "", -1, err
+	}
+	return result.Stdout + result.Stderr, result.ExitCode, err
+}
+
+// runHost runs a host hook in the project directory, or the current directory when the
+// project directory does not exist
+func runHost(ctx context.Context, hook Hook, target Target, env map[string]string) (string, int, error) {
+	cmd := osexec.CommandContext(ctx, "sh", "-c", hook.Host)
+	if hostos.IsWindows() {
+		cmd = osexec.CommandContext(ctx, "cmd", "/C", hook.Host)
+	}
+	if info, err := os.Stat(target.ProjectPath); err == nil && info.IsDir() {
+		cmd.Dir = target.ProjectPath
+	}
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if exitErr, ok := err.(*osexec.ExitError); ok {
+		return output.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return output.String(), -1, err
+	}
+	return output.String(), 0, nil
+}
+
+// summary describes why a hook failed
+func (r Result) summary() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	summary := fmt.Sprintf("exit code %d", r.ExitCode)
+	if output := strings.TrimSpace(r.Output); output != "" {
+		lines := strings.Split(output, "\n")
+		summary += ": " + lines[len(lines)-1]
+	}
+	return summary
+}
+
+// truncate keeps the end of long hook output, where failures are reported
+func truncate(output string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+	return "...\n" + output[len(output)-maxOutputBytes:]
+}
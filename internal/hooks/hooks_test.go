@@ -0,0 +1,131 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/exec"
+)
+
+// fakeExecutor records the guest commands run
+type fakeExecutor struct {
+	commands []string
+	execCtx  exec.ExecutionContext
+}
+
+func (f *fakeExecutor) ExecuteCommand(ctx context.Context, command string, execCtx exec.ExecutionContext, callback exec.OutputCallback) (*exec.CommandResult, error) {
+	f.commands = append(f.commands, command)
+	f.execCtx = execCtx
+	return &exec.CommandResult{ExitCode: 0, Stdout: "smoke test passed\n"}, nil
+}
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		hook      Hook
+		expectErr bool
+	}{
+		{"host hook", Hook{Name: "backup", Event: PreDestroy, Host: "make backup"}, false},
+		{"guest hook", Hook{Name: "smoke", Event: PostUp, Guest: "curl -f localhost:3000", Timeout: "30s"}, false},
+		{"unknown event", Hook{Name: "x", Event: "post_halt", Host: "true"}, true},
+		{"no command", Hook{Name: "x", Event: PostUp}, true},
+		{"both commands", Hook{Name: "x", Event: PostUp, Host: "true", Guest: "true"}, true},
+		{"guest before up", Hook{Name: "x", Event: PreUp, Guest: "true"}, true},
+		{"guest after destroy", Hook{Name: "x", Event: PostDestroy, Guest: "true"}, true},
+		{"invalid timeout", Hook{Name: "x", Event: PostUp, Host: "true", Timeout: "soon"}, true},
+		{"invalid VM pattern", Hook{Name: "x", Event: PostUp, Host: "true", VMs: []string{"web-["}}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Config{Hooks: []Hook{tc.hook}}.Validate()
+			if tc.expectErr && err == nil {
+				t.Errorf("Expected an error")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("Expected no error but got %v", err)
+			}
+		})
+	}
+}
+
+func TestRunnerFor(t *testing.T) {
+	runner := &Runner{}
+	err := runner.SetHooks([]Hook{
+		{Name: "all", Event: PostUp, Host: "true"},
+		{Name: "shop", Event: PostUp, Host: "true", VMs: []string{"shop-*"}},
+		{Name: "sync", Event: PostSync, Host: "true"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	names := func(hooks []Hook) string {
+		var names []string
+		for _, hook := range hooks {
+			names = append(names, hook.Name)
+		}
+		return strings.Join(names, ",")
+	}
+	if got := names(runner.For(PostUp, "shop-web")); got != "all,shop" {
+		t.Errorf("Expected all,shop but got %s", got)
+	}
+	if got := names(runner.For(PostUp, "api")); got != "all" {
+		t.Errorf("Expected all but got %s", got)
+	}
+	if got := names(runner.For(PreDestroy, "api")); got != "" {
+		t.Errorf("Expected no hooks but got %s", got)
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("host hooks run with sh in this test")
+	}
+	project := t.TempDir()
+	executor := &fakeExecutor{}
+	runner := &Runner{}
+	runner.SetExecutor(executor)
+	err := runner.SetHooks([]Hook{
+		{Name: "record", Event: PreDestroy, Host: `echo "$VAGRANT_MCP_EVENT $VAGRANT_MCP_VM" > hook.out`},
+		{Name: "warn", Event: PreDestroy, Host: "echo disk almost full; exit 3"},
+		{Name: "backup", Event: PreDestroy, Host: "echo backup failed >&2; exit 1", Abort: true},
+		{Name: "never", Event: PreDestroy, Host: "touch never.out"},
+		{Name: "slow", Event: PostSync, Host: "sleep 5", Timeout: "100ms"},
+		{Name: "smoke", Event: PostUp, Guest: "./smoke.sh"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	target := Target{VMName: "web", ProjectPath: project}
+
+	results, err := runner.Run(context.Background(), PreDestroy, target)
+	if err == nil || !strings.Contains(err.Error(), "pre_destroy hook 'backup' failed: exit code 1: backup failed") {
+		t.Errorf("Expected the backup hook to abort but got %v", err)
+	}
+	if len(results) != 3 || !results[2].Aborted || results[1].ExitCode != 3 || results[1].Aborted {
+		t.Errorf("Expected three results with only the last aborting but got %+v", results)
+	}
+	if content, _ := os.ReadFile(filepath.Join(project, "hook.out")); string(content) != "pre_destroy web\n" {
+		t.Errorf("Expected the hook to run in the project with its environment but got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(project, "never.out")); err == nil {
+		t.Errorf("Expected the hooks after the aborting one not to run")
+	}
+
+	results, err = runner.Run(context.Background(), PostSync, target)
+	if err != nil || len(results) != 1 || !results[0].TimedOut {
+		t.Errorf("Expected the slow hook to time out without an error but got %+v and %v", results, err)
+	}
+
+	results, err = runner.Run(context.Background(), PostUp, target)
+	if err != nil || len(results) != 1 || results[0].Target != TargetGuest || results[0].Output != "smoke test passed\n" {
+		t.Errorf("Expected the guest hook result but got %+v and %v", results, err)
+	}
+	if len(executor.commands) != 1 || executor.execCtx.VMName != "web" || executor.execCtx.Environment["VAGRANT_MCP_EVENT"] != PostUp {
+		t.Errorf("Expected the guest hook to run in web but got %v and %+v", executor.commands, executor.execCtx)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package hostos adapts the host paths and commands the server uses to the host operating
+// system, so syncs and ssh work on Windows hosts as well as Unix ones
+package hostos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Mirror tools, in the order they are tried on Windows
+const (
+	ToolRsync    = "rsync"
+	ToolWSL      = "wsl"
+	ToolRobocopy = "robocopy"
+)
+
+// robocopyFailure is the lowest robocopy exit code that means the copy failed; lower codes
+// report what was copied
+const robocopyFailure = 8
+
+// drivePattern matches a Windows path starting with a drive letter, e.g. C:\Users
+var drivePattern = regexp.MustCompile(`^([A-Za-z]):[\\/]?(.*)$`)
+
+// goos is the host operating system; it is replaced in tests
+var goos = runtime.GOOS
+
+// lookPath finds host commands; it is replaced in tests
+var lookPath = exec.LookPath
+
+var (
+	wslRsyncOnce sync.Once
+	wslRsync     bool
+)
+
+// hasWSLRsync reports whether rsync runs in the default WSL distribution; it is replaced in tests
+var hasWSLRsync = func() bool {
+	wslRsyncOnce.Do(func() {
+		if _, err := lookPath("wsl"); err != nil {
+			return
+		}
+		wslRsync = exec.Command("wsl", "-e", "rsync", "--version").Run() == nil
+	})
+	return wslRsync
+}
+
+// IsWindows reports whether the server runs on a Windows host
+func IsWindows() bool {
+	return goos == "windows"
+}
+
+// GuestSlashes returns a guest path with forward slashes. On Windows hosts, backslashes, as
+// in host relative paths, are turned into slashes.
+func GuestSlashes(p string) string {
+	if IsWindows() {
+		return strings.ReplaceAll(p, `\`, "/")
+	}
+	return p
+}
+
+// GuestPath joins guest path elements, which may come from host paths, with forward slashes
+func GuestPath(elem ...string) string {
+	slashed := make([]string, len(elem))
+	for i, e := range elem {
+		slashed[i] = GuestSlashes(e)
+	}
+	return path.Join(slashed...)
+}
+
+// NullDevice returns the file ssh options such as UserKnownHostsFile use to discard data:
+// NUL for ssh.exe on Windows, otherwise /dev/null
+func NullDevice() string {
+	if IsWindows() {
+		return "NUL"
+	}
+	return "/dev/null"
+}
+
+// CygwinPath returns the /cygdrive form of a Windows path that the Windows builds of rsync
+// expect; rsync would read C:\ as a remote host named C
+func CygwinPath(p string) string {
+	if match := drivePattern.FindStringSubmatch(p); match != nil {
+		return "/cygdrive/" + strings.ToLower(match[1]) + "/" + strings.ReplaceAll(match[2], `\`, "/")
+	}
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// WSLPath returns the path under /mnt at which WSL sees a Windows path
+func WSLPath(p string) string {
+	if match := drivePattern.FindStringSubmatch(p); match != nil {
+		return "/mnt/" + strings.ToLower(match[1]) + "/" + strings.ReplaceAll(match[2], `\`, "/")
+	}
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// MirrorTool returns the tool Mirror uses: rsync on Unix hosts; on Windows hosts rsync from
+// PATH, such as cwRsync or Cygwin's, then rsync in WSL, then robocopy
+func MirrorTool() string {
+	if !IsWindows() {
+		return ToolRsync
+	}
+	if _, err := lookPath("rsync"); err == nil {
+		return ToolRsync
+	}
+	if hasWSLRsync() {
+		return ToolWSL
+	}
+	return ToolRobocopy
+}
+
+// mirrorCommand returns the command line making target a copy of the directory source
+func mirrorCommand(tool, source, target string) (string, []string) {
+	switch tool {
+	case ToolWSL:
+		return "wsl", []string{"-e", "rsync", "-az", "--delete", "--itemize-changes", WSLPath(source) + "/", WSLPath(target) + "/"}
+	case ToolRobocopy:
+		return "robocopy", []string{filepath.Clean(source), filepath.Clean(target), "/MIR", "/NFL", "/NDL", "/NJH", "/NJS", "/NP"}
+	}
+	if IsWindows() {
+		return "rsync", []string{"-az", "--delete", "--itemize-changes", CygwinPath(source) + "/", CygwinPath(target) + "/"}
+	}
+	return "rsync", []string{"-az", "--delete", "--itemize-changes", source + "/", target + "/"}
+}
+
+// Mirror makes the host directory target a copy of the host directory source, deleting the
+// files missing from source, and returns the output of the tool that copied them; rsync lists
+// the changes it made, one per line
+func Mirror(source, target string) ([]byte, error) {
+	tool := MirrorTool()
+	name, args := mirrorCommand(tool, source, target)
+	output, err := exec.Command(name, args...).CombinedOutput()
+	var exitErr *exec.ExitError
+	if tool == ToolRobocopy && errors.As(err, &exitErr) && exitErr.ExitCode() < robocopyFailure {
+		err = nil
+	}
+	if err != nil {
+		return output, fmt.Errorf("%s failed: %w", tool, err)
+	}
+	return output, nil
+}
+
+// ErrNoRsync is returned when the host has no rsync to copy files over ssh with
+var ErrNoRsync = errors.New("copying files over ssh needs rsync on the host; on Windows install cwRsync or rsync in WSL")
+
+// rsyncOverSSHCommand returns the command line copying between the host path hostPath and
+// remote, a user@host:path, with rsync running ssh with sshOptions. Host paths, including the
+// identity file, are turned into the form the Windows builds of rsync and ssh expect.
+func rsyncOverSSHCommand(tool string, sshOptions []string, hostPath, remote string, upload bool, args []string) (string, []string) {
+	hostForm := func(p string) string { return p }
+	switch {
+	case tool == ToolWSL:
+		hostForm = WSLPath
+	case IsWindows():
+		hostForm = CygwinPath
+	}
+
+	words := []string{"ssh"}
+	for i, option := range sshOptions {
+		switch {
+		case i > 0 && sshOptions[i-1] == "-i":
+			option = hostForm(option)
+		case IsWindows() && option == "UserKnownHostsFile="+NullDevice():
+			// Both builds of ssh run rsync's transport in a Unix-like environment
+			option = "UserKnownHostsFile=/dev/null"
+		}
+		// rsync splits the command on spaces, and a doubled quote stands for itself in quotes
+		if strings.ContainsAny(option, " '\"") {
+			option = "'" + strings.ReplaceAll(option, "'", "''") + "'"
+		}
+		words = append(words, option)
+	}
+
+	rsyncArgs := append(append([]string{}, args...), "-e", strings.Join(words, " "))
+	if upload {
+		rsyncArgs = append(rsyncArgs, hostForm(hostPath), remote)
+	} else {
+		rsyncArgs = append(rsyncArgs, remote, hostForm(hostPath))
+	}
+	if tool == ToolWSL {
+		return "wsl", append([]string{"-e", "rsync"}, rsyncArgs...)
+	}
+	return "rsync", rsyncArgs
+}
+
+// RsyncOverSSH copies the host path hostPath to remote, a user@host:path on a machine ssh
+// reaches with sshOptions, when upload is set, and remote to hostPath otherwise. args are the
+// rsync options. It returns the output of rsync, which lists the changes it made when args
+// include --itemize-changes, and ErrNoRsync on Windows hosts with neither rsync nor rsync in WSL.
+func RsyncOverSSH(ctx context.Context, sshOptions []string, hostPath, remote string, upload bool, args ...string) ([]byte, error) {
+	return RsyncOverSSHWithProgress(ctx, sshOptions, hostPath, remote, upload, nil, args...)
+}
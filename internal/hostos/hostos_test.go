@@ -0,0 +1,145 @@
+package hostos
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// onHost runs a test as if the server ran on goos, with the given commands in PATH and rsync in
+// WSL when wslRsync is set
+func onHost(t *testing.T, host string, commands []string, wslRsync bool) {
+	originalGOOS, originalLookPath, originalWSL := goos, lookPath, hasWSLRsync
+	t.Cleanup(func() { goos, lookPath, hasWSLRsync = originalGOOS, originalLookPath, originalWSL })
+	goos = host
+	lookPath = func(file string) (string, error) {
+		for _, command := range commands {
+			if command == file {
+				return file, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	hasWSLRsync = func() bool { return wslRsync }
+}
+
+func TestGuestPath(t *testing.T) {
+	testCases := []struct {
+		host     string
+		elem     []string
+		expected string
+	}{
+		{"linux", []string{"/vagrant", "src/app.js"}, "/vagrant/src/app.js"},
+		{"windows", []string{"/vagrant", `src\app.js`}, "/vagrant/src/app.js"},
+		{"windows", []string{"/vagrant", "app.js"}, "/vagrant/app.js"},
+		// Backslashes are valid in Unix file names
+		{"linux", []string{"/vagrant", `odd\name`}, `/vagrant/odd\name`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.host+" "+tc.expected, func(t *testing.T) {
+			onHost(t, tc.host, nil, false)
+			if got := GuestPath(tc.elem...); got != tc.expected {
+				t.Errorf("Expected %s but got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestHostPaths(t *testing.T) {
+	testCases := []struct {
+		path   string
+		cygwin string
+		wsl    string
+	}{
+		{`C:\Users\dev\project`, "/cygdrive/c/Users/dev/project", "/mnt/c/Users/dev/project"},
+		{"D:/src/app", "/cygdrive/d/src/app", "/mnt/d/src/app"},
+		{`relative\dir`, "relative/dir", "relative/dir"},
+	}
+	for _, tc := range testCases {
+		if got := CygwinPath(tc.path); got != tc.cygwin {
+			t.Errorf("Expected Cygwin path %s for %s but got %s", tc.cygwin, tc.path, got)
+		}
+		if got := WSLPath(tc.path); got != tc.wsl {
+			t.Errorf("Expected WSL path %s for %s but got %s", tc.wsl, tc.path, got)
+		}
+	}
+}
+
+func TestNullDevice(t *testing.T) {
+	onHost(t, "windows", nil, false)
+	if got := NullDevice(); got != "NUL" {
+		t.Errorf("Expected NUL on Windows but got %s", got)
+	}
+	goos = "darwin"
+	if got := NullDevice(); got != "/dev/null" {
+		t.Errorf("Expected /dev/null on macOS but got %s", got)
+	}
+}
+
+func TestMirrorCommand(t *testing.T) {
+	testCases := []struct {
+		name         string
+		host         string
+		commands     []string
+		wslRsync     bool
+		source       string
+		target       string
+		expectedTool string
+		expectedName string
+		expectedArgs []string
+	}{
+		{"unix rsync", "linux", nil, false, "/src", "/vms/dev/vagrant", ToolRsync, "rsync", []string{"-az", "--delete", "--itemize-changes", "/src/", "/vms/dev/vagrant/"}},
+		{"windows rsync", "windows", []string{"rsync"}, true, `C:\src`, `C:\vms\dev\vagrant`, ToolRsync, "rsync", []string{"-az", "--delete", "--itemize-changes", "/cygdrive/c/src/", "/cygdrive/c/vms/dev/vagrant/"}},
+		{"windows wsl", "windows", nil, true, `C:\src`, `C:\vms\dev\vagrant`, ToolWSL, "wsl", []string{"-e", "rsync", "-az", "--delete", "--itemize-changes", "/mnt/c/src/", "/mnt/c/vms/dev/vagrant/"}},
+		{"windows robocopy", "windows", nil, false, "src", "dst", ToolRobocopy, "robocopy", []string{"src", "dst", "/MIR", "/NFL", "/NDL", "/NJH", "/NJS", "/NP"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			onHost(t, tc.host, tc.commands, tc.wslRsync)
+			tool := MirrorTool()
+			if tool != tc.expectedTool {
+				t.Fatalf("Expected tool %s but got %s", tc.expectedTool, tool)
+			}
+			name, args := mirrorCommand(tool, tc.source, tc.target)
+			if name != tc.expectedName || !reflect.DeepEqual(args, tc.expectedArgs) {
+				t.Errorf("Expected %s %v but got %s %v", tc.expectedName, tc.expectedArgs, name, args)
+			}
+		})
+	}
+}
+
+func TestRsyncOverSSHCommand(t *testing.T) {
+	options := []string{"-p", "2222", "-i", `C:\Users\dev one\key`, "-o", "UserKnownHostsFile=NUL"}
+	args := []string{"-az", "--delete"}
+	testCases := []struct {
+		name         string
+		host         string
+		tool         string
+		upload       bool
+		expectedName string
+		expectedArgs []string
+	}{
+		{"windows rsync upload", "windows", ToolRsync, true, "rsync", []string{"-az", "--delete", "-e", "ssh -p 2222 -i '/cygdrive/c/Users/dev one/key' -o UserKnownHostsFile=/dev/null", "/cygdrive/c/src/", "vagrant@127.0.0.1:/vagrant/"}},
+		{"windows wsl download", "windows", ToolWSL, false, "wsl", []string{"-e", "rsync", "-az", "--delete", "-e", "ssh -p 2222 -i '/mnt/c/Users/dev one/key' -o UserKnownHostsFile=/dev/null", "vagrant@127.0.0.1:/vagrant/", "/mnt/c/src/"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			onHost(t, tc.host, nil, false)
+			name, got := rsyncOverSSHCommand(tc.tool, options, `C:\src\`, "vagrant@127.0.0.1:/vagrant/", tc.upload, args)
+			if name != tc.expectedName || !reflect.DeepEqual(got, tc.expectedArgs) {
+				t.Errorf("Expected %s %q but got %s %q", tc.expectedName, tc.expectedArgs, name, got)
+			}
+		})
+	}
+
+	onHost(t, "linux", nil, false)
+	_, got := rsyncOverSSHCommand(ToolRsync, []string{"-i", "/home/dev/it's key"}, "/src/", "vagrant@127.0.0.1:/vagrant/", true, nil)
+	if expected := "ssh -i '/home/dev/it''s key'"; got[1] != expected {
+		t.Errorf("Expected the ssh command %q but got %q", expected, got[1])
+	}
+
+	onHost(t, "windows", nil, false)
+	if _, err := RsyncOverSSH(t.Context(), nil, "src", "vagrant@127.0.0.1:/vagrant", true); !errors.Is(err, ErrNoRsync) {
+		t.Errorf("Expected ErrNoRsync without rsync but got %v", err)
+	}
+}
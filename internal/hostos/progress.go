@@ -0,0 +1,164 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package hostos
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RsyncProgress is a progress report of a whole rsync transfer, as --info=progress2 prints it
+type RsyncProgress struct {
+	BytesTransferred int64
+	Percent          int
+	// Rate is the transfer rate as rsync prints it, e.g. 12.34MB/s
+	Rate string
+	// ETA is the estimated time left; rsync reports the time taken instead once it finished
+	ETA time.Duration
+	// FilesTransferred counts the files copied so far. FilesToCheck of TotalFiles are still to
+	// be compared; both are 0 until rsync reports them.
+	FilesTransferred int
+	FilesToCheck     int
+	TotalFiles       int
+}
+
+// progressPattern matches an --info=progress2 line, e.g.
+// '  1,238,099  42%   12.34MB/s    0:01:23 (xfr#57, to-chk=812/1024)'
+var progressPattern = regexp.MustCompile(`^\s*([\d,]+)\s+(\d+)%\s+(\S+/s)\s+(\d+):(\d{2}):(\d{2})(?:\s+\(xfr#(\d+), (?:to|ir)-chk=(\d+)/(\d+)\))?\s*$`)
+
+// rsyncVersionPattern matches the version in 'rsync --version'; openrsync prints a protocol
+// version without a dot first
+var rsyncVersionPattern = regexp.MustCompile(`version v?(\d+)\.(\d+)`)
+
+// parseRsyncProgress parses an --info=progress2 line
+func parseRsyncProgress(line string) (RsyncProgress, bool) {
+	match := progressPattern.FindStringSubmatch(line)
+	if match == nil {
+		return RsyncProgress{}, false
+	}
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	bytesTransferred, _ := strconv.ParseInt(strings.ReplaceAll(match[1], ",", ""), 10, 64)
+	eta := time.Duration(number(match[4]))*time.Hour + time.Duration(number(match[5]))*time.Minute + time.Duration(number(match[6]))*time.Second
+	return RsyncProgress{
+		BytesTransferred: bytesTransferred,
+		Percent:          number(match[2]),
+		Rate:             match[3],
+		ETA:              eta,
+		FilesTransferred: number(match[7]),
+		FilesToCheck:     number(match[8]),
+		TotalFiles:       number(match[9]),
+	}, true
+}
+
+// versionReportsProgress reports whether the output of 'rsync --version' is of rsync 3.1 or
+// later, which added --info=progress2. The rsync 2.6.9 and openrsync of macOS lack it.
+func versionReportsProgress(output string) bool {
+	match := rsyncVersionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major > 3 || major == 3 && minor >= 1
+}
+
+var (
+	progressSupportMu sync.Mutex
+	progressSupport   = map[string]bool{}
+)
+
+// reportsProgress reports whether the rsync of a mirror tool supports --info=progress2; it is
+// replaced in tests
+var reportsProgress = func(tool string) bool {
+	progressSupportMu.Lock()
+	defer progressSupportMu.Unlock()
+	if supported, ok := progressSupport[tool]; ok {
+		return supported
+	}
+	cmd := exec.Command("rsync", "--version")
+	if tool == ToolWSL {
+		cmd = exec.Command("wsl", "-e", "rsync", "--version")
+	}
+	output, err := cmd.Output()
+	progressSupport[tool] = err == nil && versionReportsProgress(string(output))
+	return progressSupport[tool]
+}
+
+// scanProgressLines splits rsync output into lines ending in a newline or, as progress
+// updates do, a carriage return
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// RsyncOverSSHWithProgress is RsyncOverSSH reporting the progress of the whole transfer to
+// progress while rsync runs. The progress lines are left out of the output. rsync older than
+// 3.1 copies without reporting progress.
+func RsyncOverSSHWithProgress(ctx context.Context, sshOptions []string, hostPath, remote string, upload bool, progress func(RsyncProgress), args ...string) ([]byte, error) {
+	tool := MirrorTool()
+	if tool == ToolRobocopy {
+		return nil, ErrNoRsync
+	}
+	if progress == nil || !reportsProgress(tool) {
+		name, cmdArgs := rsyncOverSSHCommand(tool, sshOptions, hostPath, remote, upload, args)
+		output, err := exec.CommandContext(ctx, name, cmdArgs...).CombinedOutput()
+		if err != nil {
+			return output, fmt.Errorf("%s failed: %w", tool, err)
+		}
+		return output, nil
+	}
+
+	// Without incremental recursion rsync lists every file before copying, so the percentage
+	// covers the whole transfer rather than the files found so far
+	args = append(append([]string{}, args...), "--info=progress2", "--no-inc-recursive")
+	name, cmdArgs := rsyncOverSSHCommand(tool, sshOptions, hostPath, remote, upload, args)
+	cmd := exec.CommandContext(ctx, name, cmdArgs...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", tool, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w", tool, err)
+	}
+
+	var output bytes.Buffer
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if report, ok := parseRsyncProgress(line); ok {
+			progress(report)
+		} else if line != "" {
+			output.WriteString(line + "\n")
+		}
+	}
+	// Keep rsync from blocking on a full pipe when a line was too long to scan
+	_, _ = io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+	output.Write(stderr.Bytes())
+	if err != nil {
+		return output.Bytes(), fmt.Errorf("%s failed: %w", tool, err)
+	}
+	return output.Bytes(), nil
+}
@@ -0,0 +1,83 @@
+package hostos
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRsyncProgress(t *testing.T) {
+	report, ok := parseRsyncProgress("  1,238,099  42%   12.34MB/s    0:01:23 (xfr#57, to-chk=812/1024)")
+	if !ok {
+		t.Fatal("Expected a progress line")
+	}
+	expected := RsyncProgress{BytesTransferred: 1238099, Percent: 42, Rate: "12.34MB/s", ETA: 83 * time.Second, FilesTransferred: 57, FilesToCheck: 812, TotalFiles: 1024}
+	if report != expected {
+		t.Errorf("Expected %+v but got %+v", expected, report)
+	}
+	if report, ok := parseRsyncProgress("         32,768   0%    0.00kB/s    0:00:00"); !ok || report.Percent != 0 || report.TotalFiles != 0 {
+		t.Errorf("Expected a progress line without file counts but got %+v, %v", report, ok)
+	}
+	for _, line := range []string{">f+++++++++ src/main.go", "sending incremental file list", ""} {
+		if _, ok := parseRsyncProgress(line); ok {
+			t.Errorf("Expected %q not to be a progress line", line)
+		}
+	}
+}
+
+func TestVersionReportsProgress(t *testing.T) {
+	testCases := []struct {
+		output   string
+		expected bool
+	}{
+		{"rsync  version 3.2.7  protocol version 31", true},
+		{"rsync  version v3.1.3  protocol version 31", true},
+		{"rsync  version 3.0.9  protocol version 30", false},
+		{"openrsync: protocol version 29\nrsync version 2.6.9 compatible", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		if got := versionReportsProgress(tc.output); got != tc.expected {
+			t.Errorf("Expected %v for %q but got %v", tc.expected, tc.output, got)
+		}
+	}
+}
+
+func TestRsyncOverSSHWithProgress(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as rsync")
+	}
+	onHost(t, "linux", nil, false)
+	originalReportsProgress := reportsProgress
+	t.Cleanup(func() { reportsProgress = originalReportsProgress })
+	reportsProgress = func(string) bool { return true }
+
+	// A fake rsync printing progress updates on one line, as rsync does, between changes
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"case \"$*\" in *--info=progress2*--no-inc-recursive*) ;; *) echo missing progress options >&2; exit 1;; esac\n" +
+		"printf '      1,024  10%%    1.00MB/s    0:00:09\\r'\n" +
+		"printf '>f+++++++++ a.txt\\n'\n" +
+		"printf '     10,240 100%%    1.00MB/s    0:00:10 (xfr#1, to-chk=0/2)\\n'\n"
+	if err := os.WriteFile(filepath.Join(dir, "rsync"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake rsync: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var reports []RsyncProgress
+	output, err := RsyncOverSSHWithProgress(t.Context(), nil, "/src/", "vagrant@127.0.0.1:/vagrant/", true, func(report RsyncProgress) {
+		reports = append(reports, report)
+	}, "-az")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v: %s", err, output)
+	}
+	if strings.TrimSpace(string(output)) != ">f+++++++++ a.txt" {
+		t.Errorf("Expected only the changes in the output but got %q", output)
+	}
+	if len(reports) != 2 || reports[0].Percent != 10 || reports[1].Percent != 100 || reports[1].TotalFiles != 2 {
+		t.Errorf("Expected progress at 10%% and 100%% but got %+v", reports)
+	}
+}
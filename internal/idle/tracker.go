@@ -0,0 +1,311 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package idle suspends or halts VMs that no tool has used for a while
+package idle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// Actions taken on idle VMs
+const (
+	// ActionSuspend saves the VM's memory to disk, so it resumes where it left off
+	ActionSuspend = "suspend"
+	// ActionHalt shuts the VM down
+	ActionHalt = "halt"
+)
+
+// DefaultCheckInterval is how often idle VMs are looked for
+const DefaultCheckInterval = time.Minute
+
+// GlobalTracker is the idle tracker shared by the server
+var GlobalTracker = NewTrackerFromEnv()
+
+// VMController is the part of the VM manager the tracker needs to stop idle VMs
+type VMController interface {
+	GetVMState(ctx context.Context, name string) (core.VMState, error)
+	StopVM(ctx context.Context, name string) error
+}
+
+// BusyFunc returns the VMs kept busy by something other than tool calls, such as open shell
+// sessions, background processes and tunnels
+type BusyFunc func() []string
+
+// AutoStop records a VM the tracker suspended or halted
+type AutoStop struct {
+	Action       string    `json:"action"`
+	At           time.Time `json:"at"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// Tracker records the last tool activity of each VM and stops the VMs left idle
+type Tracker struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	action   string
+	activity map[string]time.Time
+	stopped  map[string]AutoStop
+	busy     []BusyFunc
+	now      func() time.Time
+}
+
+// NewTracker creates a tracker that applies action to VMs idle for timeout. A zero timeout
+// disables automatic stops.
+func NewTracker(timeout time.Duration, action string) *Tracker {
+	if action != ActionHalt {
+		action = ActionSuspend
+	}
+	if timeout < 0 {
+		timeout = 0
+	}
+	return &Tracker{
+		timeout:  timeout,
+		action:   action,
+		activity: make(map[string]time.Time),
+		stopped:  make(map[string]AutoStop),
+		now:      time.Now,
+	}
+}
+
+// NewTrackerFromEnv creates a tracker configured from MCP_IDLE_TIMEOUT, a duration such as
+// "30m", and MCP_IDLE_ACTION, "suspend" or "halt"
+func NewTrackerFromEnv() *Tracker {
+	var timeout time.Duration
+	if value := os.Getenv("MCP_IDLE_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Warn().Str("value", value).Msg("Ignoring invalid MCP_IDLE_TIMEOUT")
+		} else {
+			timeout = parsed
+		}
+	}
+	return NewTracker(timeout, os.Getenv("MCP_IDLE_ACTION"))
+}
+
+// Enabled reports whether idle VMs are stopped automatically
+func (t *Tracker) Enabled() bool {
+	return t.timeout > 0
+}
+
+// Timeout returns how long a VM may stay idle
+func (t *Tracker) Timeout() time.Duration {
+	return t.timeout
+}
+
+// Action returns what is done to idle VMs
+func (t *Tracker) Action() string {
+	return t.action
+}
+
+// Touch records activity on a VM
+func (t *Tracker) Touch(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.activity[name] = t.now()
+}
+
+// AddBusy counts the VMs a source reports busy as active at every check
+func (t *Tracker) AddBusy(source BusyFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.busy = append(t.busy, source)
+}
+
+// Seed tracks the VMs that exist when the server starts, as active now, so a VM left running
+// by an earlier server is stopped once idle even if no tool uses it. VMs already tracked keep
+// their activity.
+func (t *Tracker) Seed(ctx context.Context, vms VMController) {
+	lister, ok := vms.(interface {
+		ListVMs(ctx context.Context) ([]string, error)
+	})
+	if !ok {
+		return
+	}
+	names, err := lister.ListVMs(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list VMs to track for idleness")
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, name := range names {
+		if _, tracked := t.activity[name]; !tracked {
+			t.activity[name] = t.now()
+		}
+	}
+}
+
+// touchBusy records activity on the VMs the busy sources report
+func (t *Tracker) touchBusy() {
+	t.mu.Lock()
+	sources := append([]BusyFunc(nil), t.busy...)
+	t.mu.Unlock()
+	for _, source := range sources {
+		for _, name := range source() {
+			t.Touch(name)
+		}
+	}
+}
+
+// LastActivity returns the last recorded activity on a VM
+func (t *Tracker) LastActivity(name string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.activity[name]
+	return last, ok
+}
+
+// TakeAutoStop returns and clears the record of the tracker stopping a VM
+func (t *Tracker) TakeAutoStop(name string) (AutoStop, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stop, ok := t.stopped[name]
+	delete(t.stopped, name)
+	return stop, ok
+}
+
+// Idle returns the tracked VMs without activity for at least the timeout, sorted
+func (t *Tracker) Idle() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.Enabled() {
+		return nil
+	}
+	now := t.now()
+	var names []string
+	for name, last := range t.activity {
+		if now.Sub(last) >= t.timeout {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check stops the running VMs that are idle and returns their names. VMs the busy sources report
+// are not idle. Idle entries for VMs that are not running, or are not VMs at all, are dropped;
+// the next tool call tracks them again.
+func (t *Tracker) Check(ctx context.Context, vms VMController) []string {
+	t.touchBusy()
+	var stopped []string
+	for _, name := range t.Idle() {
+		state, err := vms.GetVMState(ctx, name)
+		if err != nil || state != core.Running {
+			t.claimIfIdle(name)
+			continue
+		}
+		last, ok := t.claimIfIdle(name)
+		if !ok {
+			// A tool used the VM while its state was read
+			continue
+		}
+		if err := t.stop(ctx, vms, name); err != nil {
+			log.Warn().Err(err).Str("name", name).Str("action", t.action).Msg("Failed to stop idle VM")
+			continue
+		}
+		t.mu.Lock()
+		t.stopped[name] = AutoStop{Action: t.action, At: t.now(), LastActivity: last}
+		t.mu.Unlock()
+		log.Info().Str("name", name).Str("action", t.action).Time("last_activity", last).Msg("Stopped idle VM")
+		stopped = append(stopped, name)
+	}
+	return stopped
+}
+
+// claimIfIdle stops tracking a VM that is still idle and returns its last activity
+func (t *Tracker) claimIfIdle(name string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.activity[name]
+	if !ok || t.now().Sub(last) < t.timeout {
+		return time.Time{}, false
+	}
+	delete(t.activity, name)
+	return last, true
+}
+
+// stop applies the tracker's action to a VM, halting it when the manager cannot suspend
+func (t *Tracker) stop(ctx context.Context, vms VMController, name string) error {
+	if t.action == ActionSuspend {
+		if suspender, ok := vms.(interface {
+			SuspendVM(ctx context.Context, name string) error
+		}); ok {
+			return suspender.SuspendVM(ctx, name)
+		}
+	}
+	return vms.StopVM(ctx, name)
+}
+
+// Run checks for idle VMs every interval until ctx is done. It returns at once when the
+// tracker is disabled.
+func (t *Tracker) Run(ctx context.Context, vms VMController, interval time.Duration) {
+	if !t.Enabled() {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	log.Info().Dur("timeout", t.timeout).Str("action", t.action).Msg("Stopping idle VMs automatically")
+	t.Seed(ctx, vms)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Check(ctx, vms)
+		}
+	}
+}
+
+// Middleware records activity on the VMs named in the arguments of every tool call
+func (t *Tracker) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			for _, name := range vmNames(request.GetArguments()) {
+				t.Touch(name)
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// vmNames returns the VM names in tool arguments. VM tools call the VM "name"; others use
+// "vm_name" or "vm_names". A "name" that is not a VM is dropped by the next check.
+func vmNames(arguments map[string]any) []string {
+	var names []string
+	for _, key := range []string{"vm_name", "name"} {
+		if name, ok := arguments[key].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	if list, ok := arguments["vm_names"].([]any); ok {
+		for _, item := range list {
+			if name, ok := item.(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Describe returns a sentence explaining an automatic stop, for tool responses
+func (s AutoStop) Describe() string {
+	verb := "suspended"
+	if s.Action == ActionHalt {
+		verb = "halted"
+	}
+	return fmt.Sprintf("it was %s at %s after being idle since %s", verb, s.At.Format(time.RFC3339), s.LastActivity.Format(time.RFC3339))
+}
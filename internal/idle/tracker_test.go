@@ -0,0 +1,152 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// fakeVMs records the VMs stopped by the tracker
+type fakeVMs struct {
+	states    map[string]core.VMState
+	halted    []string
+	suspended []string
+}
+
+func (f *fakeVMs) GetVMState(ctx context.Context, name string) (core.VMState, error) {
+	state, ok := f.states[name]
+	if !ok {
+		return core.Unknown, fmt.Errorf("VM '%s' not found", name)
+	}
+	return state, nil
+}
+
+func (f *fakeVMs) StopVM(ctx context.Context, name string) error {
+	f.halted = append(f.halted, name)
+	return nil
+}
+
+func (f *fakeVMs) SuspendVM(ctx context.Context, name string) error {
+	f.suspended = append(f.suspended, name)
+	return nil
+}
+
+// newTestTracker returns a tracker with a clock the test moves
+func newTestTracker(timeout time.Duration, action string) (*Tracker, *time.Time) {
+	tracker := NewTracker(timeout, action)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	return tracker, &now
+}
+
+func TestTracker_Idle(t *testing.T) {
+	tracker, now := newTestTracker(30*time.Minute, ActionSuspend)
+	tracker.Touch("web")
+	*now = now.Add(20 * time.Minute)
+	tracker.Touch("db")
+	*now = now.Add(15 * time.Minute)
+
+	if idle := tracker.Idle(); !reflect.DeepEqual(idle, []string{"web"}) {
+		t.Errorf("Expected only web to be idle but got %v", idle)
+	}
+
+	disabled, _ := newTestTracker(0, ActionSuspend)
+	disabled.Touch("web")
+	if disabled.Enabled() || disabled.Idle() != nil {
+		t.Errorf("Expected a zero timeout to disable the tracker")
+	}
+}
+
+func TestTracker_Check(t *testing.T) {
+	testCases := []struct {
+		name              string
+		action            string
+		expectedHalted    []string
+		expectedSuspended []string
+	}{
+		{"suspend", ActionSuspend, nil, []string{"web"}},
+		{"halt", ActionHalt, []string{"web"}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker, now := newTestTracker(time.Hour, tc.action)
+			vms := &fakeVMs{states: map[string]core.VMState{"web": core.Running, "db": core.Stopped}}
+			for _, name := range []string{"web", "db", "dev-server"} {
+				tracker.Touch(name)
+			}
+			*now = now.Add(2 * time.Hour)
+
+			stopped := tracker.Check(context.Background(), vms)
+			if !reflect.DeepEqual(stopped, []string{"web"}) {
+				t.Errorf("Expected web to be stopped but got %v", stopped)
+			}
+			if !reflect.DeepEqual(vms.halted, tc.expectedHalted) || !reflect.DeepEqual(vms.suspended, tc.expectedSuspended) {
+				t.Errorf("Expected halted %v and suspended %v but got %v and %v", tc.expectedHalted, tc.expectedSuspended, vms.halted, vms.suspended)
+			}
+			if idle := tracker.Idle(); len(idle) != 0 {
+				t.Errorf("Expected no tracked VMs after the check but got %v", idle)
+			}
+
+			stop, ok := tracker.TakeAutoStop("web")
+			if !ok || stop.Action != tc.action {
+				t.Errorf("Expected a %s record but got %+v", tc.action, stop)
+			}
+			if _, ok := tracker.TakeAutoStop("web"); ok {
+				t.Errorf("Expected the record to be cleared")
+			}
+		})
+	}
+}
+
+func TestTracker_Middleware(t *testing.T) {
+	tracker, _ := newTestTracker(time.Hour, ActionSuspend)
+	handler := tracker.Middleware()(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"vm_name": "web", "vm_names": []any{"db", "cache"}}
+	if _, err := handler(context.Background(), request); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	for _, name := range []string{"web", "db", "cache"} {
+		if _, ok := tracker.LastActivity(name); !ok {
+			t.Errorf("Expected activity on %s", name)
+		}
+	}
+}
+
+// listedVMs is a VM manager that also lists its VMs
+type listedVMs struct {
+	fakeVMs
+}
+
+func (l *listedVMs) ListVMs(ctx context.Context) ([]string, error) {
+	return []string{"web", "db"}, nil
+}
+
+func TestTracker_SeedAndBusy(t *testing.T) {
+	tracker, now := newTestTracker(time.Hour, ActionHalt)
+	vms := &listedVMs{fakeVMs{states: map[string]core.VMState{"web": core.Running, "db": core.Running}}}
+	tracker.Seed(context.Background(), vms)
+	if _, ok := tracker.LastActivity("web"); !ok {
+		t.Fatal("Expected the existing VMs to be tracked after seeding")
+	}
+	// A background process keeps db busy although no tool uses it
+	tracker.AddBusy(func() []string { return []string{"db"} })
+	*now = now.Add(2 * time.Hour)
+
+	stopped := tracker.Check(context.Background(), vms)
+	if !reflect.DeepEqual(stopped, []string{"web"}) {
+		t.Errorf("Expected only the idle seeded VM to be stopped but got %v", stopped)
+	}
+	if last, _ := tracker.LastActivity("db"); !last.Equal(*now) {
+		t.Errorf("Expected the busy VM to be active at %v but got %v", *now, last)
+	}
+}
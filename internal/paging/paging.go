@@ -0,0 +1,280 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package paging sorts, pages and trims the lists tools return, so results fit in a client's
+// context
+package paging
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxLimit is the largest page a list returns
+const MaxLimit = 1000
+
+// Params select a page of a list
+type Params struct {
+	// Limit is the number of items in a page
+	Limit int
+	// Cursor continues a list after the page that returned it as its next cursor
+	Cursor string
+	// Sort is the JSON field the items are sorted by, prefixed with '-' for descending order;
+	// empty keeps their order
+	Sort string
+	// Fields are the JSON fields kept in each item; empty keeps them all
+	Fields []string
+}
+
+// Page describes the page of a list a result holds
+type Page struct {
+	// Total is the number of items in the list, after filtering
+	Total int `json:"total"`
+	// Returned is the number of items in the page
+	Returned int `json:"returned"`
+	// NextCursor continues the list after the page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Apply sorts items and returns the page params select. Scope identifies the query the list
+// comes from, e.g. its filters, so that a cursor is only accepted by the query that returned it.
+func Apply[T any](items []T, params Params, scope string) ([]T, Page, error) {
+	if params.Limit <= 0 || params.Limit > MaxLimit {
+		return nil, Page{}, fmt.Errorf("'limit' must be between 1 and %d", MaxLimit)
+	}
+	var zero T
+	if err := CheckFields(zero, params.Fields); err != nil {
+		return nil, Page{}, err
+	}
+	if params.Sort != "" {
+		if err := Sort(items, params.Sort); err != nil {
+			return nil, Page{}, err
+		}
+	}
+
+	scope += "\x00" + params.Sort
+	offset, err := decodeCursor(params.Cursor, scope)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	page := Page{Total: len(items)}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := min(offset+params.Limit, len(items))
+	if end < len(items) {
+		page.NextCursor = encodeCursor(end, scope)
+	}
+	items = items[offset:end]
+	page.Returned = len(items)
+	return items, page, nil
+}
+
+// encodeCursor returns an opaque cursor for an offset in the list of a scope
+func encodeCursor(offset int, scope string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%08x", offset, scopeHash(scope))))
+}
+
+// decodeCursor returns the offset a cursor continues from; an empty cursor starts the list
+func decodeCursor(cursor, scope string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid 'cursor' parameter")
+	}
+	offsetText, hash, found := strings.Cut(string(data), ":")
+	offset, err := strconv.Atoi(offsetText)
+	if !found || err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid 'cursor' parameter")
+	}
+	if hash != fmt.Sprintf("%08x", scopeHash(scope)) {
+		return 0, fmt.Errorf("the cursor belongs to a query with other filters or sorting; repeat the query without a cursor")
+	}
+	return offset, nil
+}
+
+// scopeHash returns a short hash identifying the query of a cursor
+func scopeHash(scope string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(scope))
+	return h.Sum32()
+}
+
+// jsonFields returns the struct fields of t by JSON name, leaving out unexported and ignored
+// fields
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// fieldNames returns the sorted JSON names of a struct's fields
+func fieldNames(fields map[string]reflect.StructField) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// CheckFields checks that fields are JSON fields of v, a struct
+func CheckFields(v interface{}, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("'fields' cannot be selected in this list")
+	}
+	known := jsonFields(t)
+	for _, field := range fields {
+		if _, ok := known[field]; !ok {
+			return fmt.Errorf("unknown field '%s' (available: %s)", field, fieldNames(known))
+		}
+	}
+	return nil
+}
+
+// Sort sorts items, which must be structs, by a JSON field; a '-' prefix sorts in descending
+// order. Missing values sort first and equal items keep their order.
+func Sort[T any](items []T, by string) error {
+	name, descending := strings.CutPrefix(by, "-")
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("this list cannot be sorted")
+	}
+	known := jsonFields(t)
+	field, ok := known[name]
+	if !ok {
+		return fmt.Errorf("unknown sort field '%s' (available: %s)", name, fieldNames(known))
+	}
+	if !sortable(field.Type) {
+		return fmt.Errorf("cannot sort by '%s'", name)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a := reflect.ValueOf(items[i]).FieldByIndex(field.Index)
+		b := reflect.ValueOf(items[j]).FieldByIndex(field.Index)
+		result := compare(a, b)
+		if descending {
+			return result > 0
+		}
+		return result < 0
+	})
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// sortable reports whether values of t have an order: strings, numbers, booleans and times, or
+// pointers to them
+func sortable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// compare orders two values of a sortable type; nil pointers come first
+func compare(a, b reflect.Value) int {
+	if a.Kind() == reflect.Ptr {
+		if a.IsNil() || b.IsNil() {
+			return boolCompare(!a.IsNil(), !b.IsNil())
+		}
+		return compare(a.Elem(), b.Elem())
+	}
+	if a.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+	}
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	}
+	return boolCompare(a.Bool(), b.Bool())
+}
+
+// boolCompare orders false before true
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	}
+	return 1
+}
+
+// Marshal returns the JSON encoding of v keeping only fields in each item of its list, the
+// property named list. An empty list selects fields of v itself.
+func Marshal(v interface{}, list string, fields []string) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(fields) == 0 {
+		return data, err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	if list == "" {
+		return json.Marshal(selectFields(object, fields))
+	}
+
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(object[list], &items); err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		items[i] = selectFields(item, fields)
+	}
+	if object[list], err = json.Marshal(items); err != nil {
+		return nil, err
+	}
+	return json.Marshal(object)
+}
+
+// selectFields returns the properties of object named in fields
+func selectFields(object map[string]json.RawMessage, fields []string) map[string]json.RawMessage {
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := object[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
@@ -0,0 +1,147 @@
+package paging
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type item struct {
+	Name    string     `json:"name"`
+	Size    int        `json:"size"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Tags    []string   `json:"tags"`
+}
+
+func names(items []item) string {
+	var result []string
+	for _, i := range items {
+		result = append(result, i.Name)
+	}
+	return strings.Join(result, ",")
+}
+
+func TestApplyPages(t *testing.T) {
+	items := []item{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+	var pages []string
+	cursor := ""
+	for {
+		page, info, err := Apply(append([]item{}, items...), Params{Limit: 2, Cursor: cursor}, "scope")
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		if info.Total != 5 || info.Returned != len(page) {
+			t.Errorf("Expected a page of 5 items but got %+v", info)
+		}
+		pages = append(pages, names(page))
+		if info.NextCursor == "" {
+			break
+		}
+		cursor = info.NextCursor
+	}
+	expected := "a,b|c,d|e"
+	if strings.Join(pages, "|") != expected {
+		t.Errorf("Expected pages %s but got %s", expected, strings.Join(pages, "|"))
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	items := []item{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	_, first, err := Apply(items, Params{Limit: 1, Sort: "name"}, "scope")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		params   Params
+		scope    string
+		expected string
+	}{
+		{"zero limit", Params{}, "scope", "'limit' must be between 1"},
+		{"limit too large", Params{Limit: MaxLimit + 1}, "scope", "'limit' must be between 1"},
+		{"invalid cursor", Params{Limit: 1, Cursor: "not a cursor!"}, "scope", "invalid 'cursor' parameter"},
+		{"other scope", Params{Limit: 1, Cursor: first.NextCursor, Sort: "name"}, "other", "belongs to a query with other filters"},
+		{"other sort", Params{Limit: 1, Cursor: first.NextCursor, Sort: "-name"}, "scope", "belongs to a query with other filters"},
+		{"unknown field", Params{Limit: 1, Fields: []string{"name", "color"}}, "scope", "unknown field 'color' (available: expires, name, size, tags)"},
+		{"unknown sort field", Params{Limit: 1, Sort: "color"}, "scope", "unknown sort field 'color'"},
+		{"unsortable field", Params{Limit: 1, Sort: "tags"}, "scope", "cannot sort by 'tags'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Apply(items, tc.params, tc.scope)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q but got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestSort(t *testing.T) {
+	soon := time.Now()
+	later := soon.Add(time.Hour)
+	testCases := []struct {
+		by       string
+		expected string
+	}{
+		{"name", "a,b,c,d"},
+		{"-name", "d,c,b,a"},
+		{"size", "b,d,a,c"},
+		{"-size", "c,a,b,d"},
+		{"expires", "b,d,c,a"},
+		{"-expires", "a,c,b,d"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.by, func(t *testing.T) {
+			items := []item{
+				{Name: "a", Size: 2, Expires: &later},
+				{Name: "b", Size: 1},
+				{Name: "c", Size: 3, Expires: &soon},
+				{Name: "d", Size: 1},
+			}
+			if err := Sort(items, tc.by); err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if names(items) != tc.expected {
+				t.Errorf("Expected order %s but got %s", tc.expected, names(items))
+			}
+		})
+	}
+
+	if err := Sort([]string{"b", "a"}, "name"); err == nil {
+		t.Error("Expected sorting a list of strings to fail")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	response := struct {
+		Items []item `json:"items"`
+		Count int    `json:"count"`
+	}{Items: []item{{Name: "a", Size: 1}, {Name: "b", Size: 2}}, Count: 2}
+
+	testCases := []struct {
+		name     string
+		list     string
+		fields   []string
+		expected string
+	}{
+		{"all fields", "items", nil, `{"items":[{"name":"a","size":1,"tags":null},{"name":"b","size":2,"tags":null}],"count":2}`},
+		{"item fields", "items", []string{"name", "expires"}, `{"count":2,"items":[{"name":"a"},{"name":"b"}]}`},
+		{"top-level fields", "", []string{"count"}, `{"count":2}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Marshal(response, tc.list, tc.fields)
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if string(data) != tc.expected {
+				t.Errorf("Expected %s but got %s", tc.expected, data)
+			}
+			if !json.Valid(data) {
+				t.Errorf("Expected valid JSON but got %s", data)
+			}
+		})
+	}
+}
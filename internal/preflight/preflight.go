@@ -0,0 +1,308 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package preflight checks the host and guest prerequisites of synced folder types before
+// they are configured, so problems are reported with a remedy instead of failing in 'vagrant up'
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// Check statuses
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// GuestProbeCommand prints the guest facts CheckGuest needs, one key=value per line
+const GuestProbeCommand = `printf 'vboxsf=%s\n' "$(/sbin/modinfo -F version vboxsf 2>/dev/null)"; ` +
+	`printf 'mount.nfs=%s\n' "$(command -v mount.nfs)"; ` +
+	`printf 'mount.cifs=%s\n' "$(command -v mount.cifs)"; ` +
+	`printf 'virtiofs=%s\n' "$(/sbin/modinfo -F name virtiofs 2>/dev/null || grep -ow virtiofs /proc/filesystems)"; ` +
+	`printf '9p=%s\n' "$(/sbin/modinfo -F name 9p 2>/dev/null || grep -ow 9p /proc/filesystems)"`
+
+// versionPattern finds a dotted version number such as 7.0.14
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// Check is the outcome of one prerequisite check
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Remedy  string `json:"remedy,omitempty"`
+}
+
+// Report is the outcome of all checks for a sync type
+type Report struct {
+	SyncType string  `json:"sync_type"`
+	OK       bool    `json:"ok"`
+	Checks   []Check `json:"checks"`
+}
+
+// Checker runs the host checks; its fields are replaced in tests
+type Checker struct {
+	GOOS     string
+	LookPath func(file string) (string, error)
+	Run      func(name string, args ...string) (string, error)
+	Exists   func(path string) bool
+}
+
+// NewChecker creates a checker for the current host
+func NewChecker() *Checker {
+	return &Checker{
+		GOOS:     runtime.GOOS,
+		LookPath: exec.LookPath,
+		Run: func(name string, args ...string) (string, error) {
+			output, err := exec.Command(name, args...).CombinedOutput()
+			return string(output), err
+		},
+		Exists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+	}
+}
+
+// add appends a check and clears OK when it failed
+func (r *Report) add(check Check) {
+	r.Checks = append(r.Checks, check)
+	if check.Status == StatusFail {
+		r.OK = false
+	}
+}
+
+// CheckHost checks the host prerequisites of a sync type. config, when given, is the VM the
+// sync type is configured for.
+func (c *Checker) CheckHost(syncType string, config *core.VMConfig) Report {
+	report := Report{SyncType: syncType, OK: true}
+	if _, err := c.LookPath("vagrant"); err != nil {
+		report.add(Check{Name: "vagrant", Status: StatusFail, Message: "Vagrant CLI not found in PATH", Remedy: "Install Vagrant from https://developer.hashicorp.com/vagrant/downloads"})
+	} else {
+		report.add(Check{Name: "vagrant", Status: StatusPass, Message: "Vagrant CLI found"})
+	}
+
+	switch syncType {
+	case "rsync":
+		c.checkRsync(&report)
+	case "nfs":
+		c.checkNFS(&report, config)
+	case "smb":
+		c.checkSMB(&report)
+	case "virtualbox", "":
+		c.checkVirtualBox(&report)
+	case "virtiofs", "9p":
+		c.checkLibvirtShare(&report, syncType, config)
+	default:
+		report.add(Check{Name: "sync_type", Status: StatusFail, Message: fmt.Sprintf("Unknown sync type '%s'", syncType), Remedy: "Use rsync, nfs, smb, virtualbox, virtiofs or 9p"})
+	}
+	return report
+}
+
+// virtiofsdPaths are where distributions install virtiofsd outside PATH
+var virtiofsdPaths = []string{"/usr/libexec/virtiofsd", "/usr/lib/qemu/virtiofsd", "/usr/lib/virtiofsd"}
+
+// checkLibvirtShare checks the prerequisites of the virtiofs and 9p shared folders of the libvirt
+// provider
+func (c *Checker) checkLibvirtShare(report *Report, syncType string, config *core.VMConfig) {
+	if c.GOOS != "linux" {
+		report.add(Check{Name: "libvirt", Status: StatusFail, Message: fmt.Sprintf("%s synced folders need the libvirt provider, which only runs on Linux hosts", syncType), Remedy: "Use nfs or rsync"})
+		return
+	}
+	if config != nil && config.Provider != "libvirt" {
+		report.add(Check{Name: "provider", Status: StatusFail, Message: fmt.Sprintf("%s synced folders need the libvirt provider, but the VM uses %s", syncType, providerName(config.Provider)), Remedy: "Create the VM with provider libvirt, or use nfs or rsync"})
+	} else if output, err := c.Run("vagrant", "plugin", "list"); err == nil {
+		if !strings.Contains(output, "vagrant-libvirt") {
+			report.add(Check{Name: "vagrant_libvirt", Status: StatusFail, Message: "The vagrant-libvirt plugin is not installed", Remedy: "Run 'vagrant plugin install vagrant-libvirt'"})
+		} else {
+			report.add(Check{Name: "vagrant_libvirt", Status: StatusPass, Message: "vagrant-libvirt plugin installed"})
+		}
+	}
+	if syncType != "virtiofs" {
+		return
+	}
+	if _, err := c.LookPath("virtiofsd"); err == nil {
+		report.add(Check{Name: "virtiofsd", Status: StatusPass, Message: "virtiofsd found"})
+		return
+	}
+	for _, path := range virtiofsdPaths {
+		if c.Exists(path) {
+			report.add(Check{Name: "virtiofsd", Status: StatusPass, Message: fmt.Sprintf("virtiofsd found at %s", path)})
+			return
+		}
+	}
+	report.add(Check{Name: "virtiofsd", Status: StatusFail, Message: "virtiofsd not found", Remedy: "Install virtiofsd (Debian/Ubuntu, Fedora) or qemu-system (older releases), or use sync_type 9p"})
+}
+
+// providerName names a VM's provider; VMs without one use VirtualBox
+func providerName(provider string) string {
+	if provider == "" {
+		return "virtualbox"
+	}
+	return provider
+}
+
+// VirtualBoxVersion returns the host's VirtualBox version, or "" when VBoxManage is missing
+func (c *Checker) VirtualBoxVersion() string {
+	if _, err := c.LookPath("VBoxManage"); err != nil {
+		return ""
+	}
+	output, err := c.Run("VBoxManage", "--version")
+	if err != nil {
+		return ""
+	}
+	return versionPattern.FindString(output)
+}
+
+func (c *Checker) checkRsync(report *Report) {
+	if _, err := c.LookPath("rsync"); err != nil {
+		remedy := "Install rsync with your package manager"
+		message := "rsync not found in PATH"
+		if c.GOOS == "windows" {
+			// The server's own syncs fall back to rsync in WSL or robocopy, but Vagrant's rsync
+			// synced folders need rsync.exe
+			remedy = "Install cwRsync, or rsync from Cygwin or MSYS2, and add it to PATH, or use sync_type smb"
+			if _, err := c.Run("wsl", "-e", "rsync", "--version"); err == nil {
+				message += "; sync_to_vm and sync_from_vm use rsync in WSL, but Vagrant's rsync synced folders need rsync.exe"
+			} else {
+				message += "; sync_to_vm and sync_from_vm fall back to robocopy, but Vagrant's rsync synced folders need rsync.exe"
+			}
+		}
+		report.add(Check{Name: "rsync", Status: StatusFail, Message: message, Remedy: remedy})
+		return
+	}
+	report.add(Check{Name: "rsync", Status: StatusPass, Message: "rsync found"})
+}
+
+func (c *Checker) checkNFS(report *Report, config *core.VMConfig) {
+	switch c.GOOS {
+	case "windows":
+		report.add(Check{Name: "nfs_server", Status: StatusFail, Message: "Vagrant does not support NFS synced folders on Windows hosts", Remedy: "Use smb or rsync"})
+		return
+	case "darwin":
+		if !c.Exists("/sbin/nfsd") {
+			report.add(Check{Name: "nfs_server", Status: StatusFail, Message: "nfsd not found", Remedy: "NFS server support ships with macOS; check that /sbin/nfsd exists"})
+		} else {
+			report.add(Check{Name: "nfs_server", Status: StatusPass, Message: "nfsd found"})
+		}
+	default:
+		_, err := c.LookPath("exportfs")
+		if err != nil && !c.Exists("/usr/sbin/exportfs") {
+			report.add(Check{Name: "nfs_server", Status: StatusFail, Message: "NFS server not installed (exportfs not found)", Remedy: "Install nfs-kernel-server (Debian/Ubuntu) or nfs-utils (Fedora/RHEL) and start it"})
+		} else if output, err := c.Run("systemctl", "is-active", "nfs-server"); err != nil {
+			report.add(Check{Name: "nfs_server", Status: StatusWarn, Message: fmt.Sprintf("NFS server is not active: %s", strings.TrimSpace(output)), Remedy: "Run 'sudo systemctl enable --now nfs-server'"})
+		} else {
+			report.add(Check{Name: "nfs_server", Status: StatusPass, Message: "NFS server is active"})
+		}
+	}
+	c.checkSudo(report, "/etc/exports")
+
+	if config != nil && config.Network == "" {
+		report.add(Check{Name: "private_network", Status: StatusFail, Message: "NFS synced folders need a private network between host and VM", Remedy: "Create the VM with 'network' or add it to one with connect_vms"})
+	}
+}
+
+func (c *Checker) checkSMB(report *Report) {
+	switch c.GOOS {
+	case "windows":
+		if _, err := c.Run("net", "session"); err != nil {
+			report.add(Check{Name: "administrator", Status: StatusFail, Message: "The server is not running as Administrator, which Vagrant needs to create SMB shares", Remedy: "Start the MCP server from an elevated prompt"})
+		} else {
+			report.add(Check{Name: "administrator", Status: StatusPass, Message: "Running as Administrator"})
+		}
+	case "darwin":
+		if !c.Exists("/usr/sbin/smbd") {
+			report.add(Check{Name: "smb_server", Status: StatusFail, Message: "smbd not found", Remedy: "Enable File Sharing with SMB in System Settings > General > Sharing"})
+		} else {
+			report.add(Check{Name: "smb_server", Status: StatusPass, Message: "smbd found"})
+		}
+		c.checkSudo(report, "SMB shares")
+	default:
+		report.add(Check{Name: "smb_server", Status: StatusFail, Message: "Vagrant only supports SMB synced folders on Windows and macOS hosts", Remedy: "Use nfs or rsync"})
+	}
+}
+
+func (c *Checker) checkVirtualBox(report *Report) {
+	version := c.VirtualBoxVersion()
+	if version == "" {
+		report.add(Check{Name: "virtualbox", Status: StatusFail, Message: "VBoxManage not found in PATH", Remedy: "Install VirtualBox and add VBoxManage to PATH"})
+		return
+	}
+	report.add(Check{Name: "virtualbox", Status: StatusPass, Message: fmt.Sprintf("VirtualBox %s found", version)})
+
+	if output, err := c.Run("vagrant", "plugin", "list"); err == nil && strings.Contains(output, "vagrant-vbguest") {
+		report.add(Check{Name: "vbguest_plugin", Status: StatusPass, Message: "vagrant-vbguest keeps guest additions in line with VirtualBox"})
+	} else {
+		report.add(Check{Name: "vbguest_plugin", Status: StatusWarn, Message: "vagrant-vbguest is not installed; the box's guest additions must match VirtualBox", Remedy: "Run 'vagrant plugin install vagrant-vbguest' or use a box with current guest additions"})
+	}
+}
+
+// checkSudo warns when sudo needs a password: Vagrant edits what with sudo during
+// 'vagrant up' and nobody can answer the prompt when it runs from the MCP server
+func (c *Checker) checkSudo(report *Report, what string) {
+	if _, err := c.Run("sudo", "-n", "true"); err != nil {
+		report.add(Check{Name: "sudo", Status: StatusWarn, Message: fmt.Sprintf("sudo needs a password, which Vagrant asks for to update %s during 'vagrant up'", what), Remedy: "Add passwordless sudoers rules for Vagrant's NFS/SMB commands, see https://developer.hashicorp.com/vagrant/docs/synced-folders/nfs#root-privilege-requirement"})
+		return
+	}
+	report.add(Check{Name: "sudo", Status: StatusPass, Message: "Passwordless sudo is available"})
+}
+
+// CheckGuest adds the checks of a running VM to a report, given the output of
+// GuestProbeCommand and the host's VirtualBox version
+func CheckGuest(report *Report, probeOutput, hostVirtualBox string) {
+	facts := make(map[string]string)
+	for _, line := range strings.Split(probeOutput, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			facts[key] = value
+		}
+	}
+
+	switch report.SyncType {
+	case "virtualbox", "":
+		guest := versionPattern.FindString(facts["vboxsf"])
+		switch {
+		case guest == "":
+			report.add(Check{Name: "guest_additions", Status: StatusFail, Message: "VirtualBox guest additions are not installed in the VM", Remedy: "Install the vagrant-vbguest plugin and reload the VM, or use a box with guest additions"})
+		case hostVirtualBox != "" && majorMinor(guest) != majorMinor(hostVirtualBox):
+			report.add(Check{Name: "guest_additions", Status: StatusWarn, Message: fmt.Sprintf("Guest additions %s do not match VirtualBox %s; shared folders may fail to mount", guest, hostVirtualBox), Remedy: "Install the vagrant-vbguest plugin and reload the VM"})
+		default:
+			report.add(Check{Name: "guest_additions", Status: StatusPass, Message: fmt.Sprintf("Guest additions %s installed", guest)})
+		}
+	case "nfs":
+		if facts["mount.nfs"] == "" {
+			report.add(Check{Name: "nfs_client", Status: StatusWarn, Message: "The VM has no NFS client; Vagrant installs one on supported guests during 'vagrant up'", Remedy: "Install nfs-common in the VM if mounting fails"})
+		} else {
+			report.add(Check{Name: "nfs_client", Status: StatusPass, Message: "NFS client installed in the VM"})
+		}
+	case "smb":
+		if facts["mount.cifs"] == "" {
+			report.add(Check{Name: "smb_client", Status: StatusWarn, Message: "The VM has no CIFS client", Remedy: "Install cifs-utils in the VM"})
+		} else {
+			report.add(Check{Name: "smb_client", Status: StatusPass, Message: "CIFS client installed in the VM"})
+		}
+	case "virtiofs", "9p":
+		name := report.SyncType + "_client"
+		if facts[report.SyncType] == "" {
+			report.add(Check{Name: name, Status: StatusFail, Message: fmt.Sprintf("The VM kernel has no %s support", report.SyncType), Remedy: "Use a box with Linux 5.4 or later for virtiofs, or a kernel with the 9p and 9pnet_virtio modules"})
+		} else {
+			report.add(Check{Name: name, Status: StatusPass, Message: fmt.Sprintf("%s supported by the VM kernel", report.SyncType)})
+		}
+	}
+}
+
+// majorMinor returns the major.minor part of a version
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
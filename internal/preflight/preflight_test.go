@@ -0,0 +1,141 @@
+package preflight
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// fakeChecker returns a checker for goos where only the given commands and paths exist and
+// the given commands fail when run
+func fakeChecker(goos string, commands []string, paths []string, failing []string) *Checker {
+	has := func(list []string, item string) bool {
+		for _, entry := range list {
+			if entry == item {
+				return true
+			}
+		}
+		return false
+	}
+	return &Checker{
+		GOOS: goos,
+		LookPath: func(file string) (string, error) {
+			if has(commands, file) {
+				return "/usr/bin/" + file, nil
+			}
+			return "", errors.New("not found")
+		},
+		Run: func(name string, args ...string) (string, error) {
+			if has(failing, name) {
+				return "inactive", errors.New("exit status 1")
+			}
+			if name == "VBoxManage" {
+				return "7.0.14r161095\n", nil
+			}
+			if name == "vagrant" && has(commands, "vagrant-libvirt") {
+				return "vagrant-libvirt (0.12.2, global)\n", nil
+			}
+			return "", nil
+		},
+		Exists: func(path string) bool { return has(paths, path) },
+	}
+}
+
+// statuses returns the status of each check by name
+func statuses(report Report) map[string]string {
+	result := make(map[string]string)
+	for _, check := range report.Checks {
+		result[check.Name] = check.Status
+	}
+	return result
+}
+
+func TestCheckHost(t *testing.T) {
+	testCases := []struct {
+		name     string
+		checker  *Checker
+		syncType string
+		config   *core.VMConfig
+		ok       bool
+		expected map[string]string
+	}{
+		{"rsync available", fakeChecker("linux", []string{"vagrant", "rsync"}, nil, nil), "rsync", nil, true,
+			map[string]string{"vagrant": StatusPass, "rsync": StatusPass}},
+		{"rsync missing", fakeChecker("linux", []string{"vagrant"}, nil, nil), "rsync", nil, false,
+			map[string]string{"rsync": StatusFail}},
+		{"rsync missing on windows", fakeChecker("windows", []string{"vagrant"}, nil, nil), "rsync", nil, false,
+			map[string]string{"rsync": StatusFail}},
+		{"nfs on linux", fakeChecker("linux", []string{"vagrant", "exportfs"}, nil, nil), "nfs", &core.VMConfig{Network: "shop"}, true,
+			map[string]string{"nfs_server": StatusPass, "sudo": StatusPass}},
+		{"nfs server inactive and sudo password", fakeChecker("linux", []string{"vagrant"}, []string{"/usr/sbin/exportfs"}, []string{"systemctl", "sudo"}), "nfs", nil, true,
+			map[string]string{"nfs_server": StatusWarn, "sudo": StatusWarn}},
+		{"nfs without private network", fakeChecker("linux", []string{"vagrant", "exportfs"}, nil, nil), "nfs", &core.VMConfig{}, false,
+			map[string]string{"private_network": StatusFail}},
+		{"nfs on windows", fakeChecker("windows", []string{"vagrant"}, nil, nil), "nfs", nil, false,
+			map[string]string{"nfs_server": StatusFail}},
+		{"smb on linux", fakeChecker("linux", []string{"vagrant"}, nil, nil), "smb", nil, false,
+			map[string]string{"smb_server": StatusFail}},
+		{"smb on macOS", fakeChecker("darwin", []string{"vagrant"}, []string{"/usr/sbin/smbd"}, nil), "smb", nil, true,
+			map[string]string{"smb_server": StatusPass}},
+		{"virtualbox", fakeChecker("linux", []string{"vagrant", "VBoxManage"}, nil, nil), "virtualbox", nil, true,
+			map[string]string{"virtualbox": StatusPass, "vbguest_plugin": StatusWarn}},
+		{"virtualbox missing", fakeChecker("linux", []string{"vagrant"}, nil, nil), "virtualbox", nil, false,
+			map[string]string{"virtualbox": StatusFail}},
+		{"virtiofs on libvirt", fakeChecker("linux", []string{"vagrant", "vagrant-libvirt"}, []string{"/usr/libexec/virtiofsd"}, nil), "virtiofs", &core.VMConfig{Provider: "libvirt"}, true,
+			map[string]string{"vagrant_libvirt": StatusPass, "virtiofsd": StatusPass}},
+		{"virtiofs without virtiofsd", fakeChecker("linux", []string{"vagrant", "vagrant-libvirt"}, nil, nil), "virtiofs", nil, false,
+			map[string]string{"vagrant_libvirt": StatusPass, "virtiofsd": StatusFail}},
+		{"9p without vagrant-libvirt", fakeChecker("linux", []string{"vagrant"}, nil, nil), "9p", nil, false,
+			map[string]string{"vagrant_libvirt": StatusFail}},
+		{"9p on virtualbox VM", fakeChecker("linux", []string{"vagrant", "vagrant-libvirt"}, nil, nil), "9p", &core.VMConfig{Provider: "virtualbox"}, false,
+			map[string]string{"provider": StatusFail}},
+		{"virtiofs on macOS", fakeChecker("darwin", []string{"vagrant"}, nil, nil), "virtiofs", nil, false,
+			map[string]string{"libvirt": StatusFail}},
+		{"unknown sync type", fakeChecker("linux", []string{"vagrant"}, nil, nil), "ftp", nil, false,
+			map[string]string{"sync_type": StatusFail}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := tc.checker.CheckHost(tc.syncType, tc.config)
+			if report.OK != tc.ok {
+				t.Errorf("Expected ok %v but got %+v", tc.ok, report)
+			}
+			got := statuses(report)
+			for name, status := range tc.expected {
+				if got[name] != status {
+					t.Errorf("Expected check %s to be %s but got %q in %+v", name, status, got[name], report.Checks)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckGuest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		syncType string
+		probe    string
+		check    string
+		status   string
+	}{
+		{"matching guest additions", "virtualbox", "vboxsf=7.0.14 r161095\nmount.nfs=\n", "guest_additions", StatusPass},
+		{"outdated guest additions", "virtualbox", "vboxsf=6.1.38\n", "guest_additions", StatusWarn},
+		{"missing guest additions", "virtualbox", "vboxsf=\n", "guest_additions", StatusFail},
+		{"nfs client", "nfs", "mount.nfs=/usr/sbin/mount.nfs\n", "nfs_client", StatusPass},
+		{"missing cifs client", "smb", "mount.cifs=\n", "smb_client", StatusWarn},
+		{"virtiofs client", "virtiofs", "virtiofs=virtiofs\n", "virtiofs_client", StatusPass},
+		{"missing 9p client", "9p", "9p=\n", "9p_client", StatusFail},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := Report{SyncType: tc.syncType, OK: true}
+			CheckGuest(&report, tc.probe, "7.0.14")
+			if status := statuses(report)[tc.check]; status != tc.status {
+				t.Errorf("Expected %s to be %s but got %q in %+v", tc.check, tc.status, status, report.Checks)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package process tracks long-running background processes started in VMs
+package process
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Launch modes for background processes
+const (
+	// ModeNohup runs the process detached in its own session with nohup
+	ModeNohup = "nohup"
+	// ModeSystemd runs the process as a transient systemd unit
+	ModeSystemd = "systemd"
+	// ModeTmux runs the process in a detached tmux session that can be attached to
+	ModeTmux = "tmux"
+)
+
+// Modes lists the supported launch modes
+var Modes = []string{ModeNohup, ModeSystemd, ModeTmux}
+
+// Process states reported by status checks
+const (
+	StateRunning = "running"
+	StateExited  = "exited"
+	StateUnknown = "unknown"
+)
+
+// LogDir is the directory in the VM holding background process logs
+const LogDir = "/tmp/vagrant-mcp"
+
+// ErrProcessNotFound is returned for unknown process IDs
+var ErrProcessNotFound = errors.New("background process not found")
+
+// Process is a background process started in a VM
+type Process struct {
+	ID         string    `json:"id"`
+	VMName     string    `json:"vm_name"`
+	Name       string    `json:"name"`
+	Command    string    `json:"command"`
+	WorkingDir string    `json:"working_dir"`
+	Mode       string    `json:"mode"`
+	PID        int       `json:"pid,omitempty"`
+	LogFile    string    `json:"log_file,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// Unit returns the systemd unit or tmux session name of the process
+func (p Process) Unit() string {
+	return "mcp-" + p.ID
+}
+
+// Registry tracks background processes
+type Registry struct {
+	mu        sync.RWMutex
+	processes map[string]Process
+}
+
+// Global background process registry instance
+var GlobalRegistry = NewRegistry()
+
+// NewRegistry creates an empty background process registry
+func NewRegistry() *Registry {
+	return &Registry{
+		processes: make(map[string]Process),
+	}
+}
+
+// New returns a process with a fresh ID and log file; it is not tracked until Add is called
+func (r *Registry) New(vmName, name, command, workingDir, mode string) Process {
+	id := newProcessID()
+	if name == "" {
+		name = id
+	}
+	return Process{
+		ID:         id,
+		VMName:     vmName,
+		Name:       name,
+		Command:    command,
+		WorkingDir: workingDir,
+		Mode:       mode,
+		LogFile:    fmt.Sprintf("%s/%s.log", LogDir, id),
+		StartedAt:  time.Now(),
+	}
+}
+
+// Add tracks a started process
+func (r *Registry) Add(p Process) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.processes[p.ID] = p
+}
+
+// Get returns a tracked process by ID or name within a VM; an empty vmName matches any VM
+func (r *Registry) Get(vmName, idOrName string) (Process, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if p, exists := r.processes[idOrName]; exists && (vmName == "" || p.VMName == vmName) {
+		return p, nil
+	}
+	for _, p := range r.processes {
+		if p.Name == idOrName && (vmName == "" || p.VMName == vmName) {
+			return p, nil
+		}
+	}
+	return Process{}, ErrProcessNotFound
+}
+
+// List returns the processes tracked for a VM, or for all VMs when vmName is empty, oldest first
+func (r *Registry) List(vmName string) []Process {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	processes := make([]Process, 0, len(r.processes))
+	for _, p := range r.processes {
+		if vmName == "" || p.VMName == vmName {
+			processes = append(processes, p)
+		}
+	}
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].StartedAt.Before(processes[j].StartedAt)
+	})
+	return processes
+}
+
+// Remove stops tracking a process
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.processes, id)
+}
+
+// RemoveVM stops tracking all processes of a VM, e.g. after it was halted or destroyed
+func (r *Registry) RemoveVM(vmName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, p := range r.processes {
+		if p.VMName == vmName {
+			delete(r.processes, id)
+		}
+	}
+}
+
+// newProcessID returns a short random process identifier
+func newProcessID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,40 @@
+package process
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	web := registry.New("dev", "web", "npm run dev", "/vagrant", ModeNohup)
+	registry.Add(web)
+	watcher := registry.New("dev", "", "npm run watch", "/vagrant", ModeTmux)
+	registry.Add(watcher)
+	registry.Add(registry.New("other", "web", "python -m http.server", "/vagrant", ModeSystemd))
+
+	if watcher.Name != watcher.ID {
+		t.Errorf("Expected an unnamed process to be named after its ID but got %s", watcher.Name)
+	}
+	if web.LogFile != LogDir+"/"+web.ID+".log" {
+		t.Errorf("Expected log file under %s but got %s", LogDir, web.LogFile)
+	}
+
+	if p, err := registry.Get("dev", "web"); err != nil || p.ID != web.ID {
+		t.Errorf("Expected to find process by name but got %+v, %v", p, err)
+	}
+	if p, err := registry.Get("", watcher.ID); err != nil || p.ID != watcher.ID {
+		t.Errorf("Expected to find process by ID but got %+v, %v", p, err)
+	}
+	if _, err := registry.Get("other", watcher.ID); !errors.Is(err, ErrProcessNotFound) {
+		t.Errorf("Expected ErrProcessNotFound for a process in another VM but got %v", err)
+	}
+	if count := len(registry.List("dev")); count != 2 {
+		t.Errorf("Expected 2 processes in dev but got %d", count)
+	}
+
+	registry.RemoveVM("dev")
+	if count := len(registry.List("")); count != 1 {
+		t.Errorf("Expected 1 process after removing dev but got %d", count)
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package project inspects a host project to recommend the VM, runtimes and ports it needs
+package project
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/config"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// Stack is a technology found in the project
+type Stack struct {
+	// Marker is the file that revealed the stack
+	Marker    string `json:"marker"`
+	Runtime   string `json:"runtime,omitempty"`
+	Framework string `json:"framework,omitempty"`
+}
+
+// Detection is the outcome of inspecting a project
+type Detection struct {
+	ProjectPath     string               `json:"project_path"`
+	Stacks          []Stack              `json:"stacks"`
+	Runtimes        []string             `json:"runtimes"`
+	Tools           []string             `json:"tools"`
+	Ports           []config.ProfilePort `json:"ports"`
+	ExcludePatterns []string             `json:"exclude_patterns"`
+	// Config is the recommended VM configuration
+	Config core.VMConfig `json:"config"`
+}
+
+// frameworkPorts maps frameworks to the port their dev server listens on by default
+var frameworkPorts = map[string]int{
+	"next":    3000,
+	"react":   3000,
+	"express": 3000,
+	"vite":    5173,
+	"angular": 4200,
+	"nuxt":    3000,
+	"flask":   5000,
+	"django":  8000,
+	"fastapi": 8000,
+	"rails":   3000,
+	"sinatra": 4567,
+	"go":      8080,
+}
+
+// runtimeExcludes are the sync exclude patterns of each runtime's build output and caches
+var runtimeExcludes = map[string][]string{
+	"node":   {"node_modules", "dist", "build", ".next", "coverage"},
+	"python": {"__pycache__", "*.pyc", "venv", ".venv", ".pytest_cache", ".tox"},
+	"go":     {"bin"},
+	"ruby":   {"vendor/bundle", "log", "tmp"},
+}
+
+var (
+	// exposePattern matches EXPOSE instructions in a Dockerfile
+	exposePattern = regexp.MustCompile(`(?i)^\s*EXPOSE\s+(.+)$`)
+	// composePortPattern matches "host:container" port mappings in a compose file
+	composePortPattern = regexp.MustCompile(`^\s*-\s*["']?(?:[\d.]+:)?(\d+):(\d+)`)
+)
+
+// Detect inspects the project in dir
+func Detect(dir string) (Detection, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Detection{}, fmt.Errorf("failed to read project: %w", err)
+	}
+	if !info.IsDir() {
+		return Detection{}, fmt.Errorf("project path '%s' is not a directory", dir)
+	}
+
+	d := &detector{dir: dir, ports: make(map[int]string)}
+	d.detectNode()
+	d.detectGo()
+	d.detectPython()
+	d.detectRuby()
+	d.detectDocker()
+	return d.result(), nil
+}
+
+// detector accumulates what Detect finds
+type detector struct {
+	dir      string
+	stacks   []Stack
+	runtimes []string
+	tools    []string
+	// ports maps guest ports to the service using them
+	ports map[int]string
+}
+
+// read returns the content of a project file, or an empty string when it is missing
+func (d *detector) read(name string) string {
+	data, err := os.ReadFile(filepath.Join(d.dir, name))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// exists reports whether the project holds a file
+func (d *detector) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(d.dir, name))
+	return err == nil
+}
+
+// add records a stack and the port of its framework
+func (d *detector) add(stack Stack) {
+	d.stacks = append(d.stacks, stack)
+	if stack.Runtime != "" && !contains(d.runtimes, stack.Runtime) {
+		d.runtimes = append(d.runtimes, stack.Runtime)
+	}
+	service := stack.Framework
+	if service == "" {
+		service = stack.Runtime
+	}
+	if port, ok := frameworkPorts[service]; ok {
+		d.addPort(port, service)
+	}
+}
+
+// addPort records a guest port unless another service already uses it
+func (d *detector) addPort(port int, service string) {
+	if _, exists := d.ports[port]; !exists {
+		d.ports[port] = service
+	}
+}
+
+func (d *detector) detectNode() {
+	content := d.read("package.json")
+	if content == "" {
+		return
+	}
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	_ = json.Unmarshal([]byte(content), &pkg)
+	has := func(name string) bool {
+		_, inDeps := pkg.Dependencies[name]
+		_, inDevDeps := pkg.DevDependencies[name]
+		return inDeps || inDevDeps
+	}
+
+	stack := Stack{Marker: "package.json", Runtime: "node"}
+	switch {
+	case has("next"):
+		stack.Framework = "next"
+	case has("nuxt"):
+		stack.Framework = "nuxt"
+	case has("@angular/core"):
+		stack.Framework = "angular"
+	case has("vite"):
+		stack.Framework = "vite"
+	case has("react-scripts"):
+		stack.Framework = "react"
+	case has("express"):
+		stack.Framework = "express"
+	}
+	if stack.Framework == "" {
+		d.addPort(3000, "node")
+	}
+	d.add(stack)
+}
+
+func (d *detector) detectGo() {
+	if d.exists("go.mod") {
+		d.add(Stack{Marker: "go.mod", Runtime: "go"})
+	}
+}
+
+func (d *detector) detectPython() {
+	var marker, content string
+	for _, name := range []string{"requirements.txt", "pyproject.toml", "Pipfile", "setup.py"} {
+		if d.exists(name) {
+			if marker == "" {
+				marker = name
+			}
+			content += strings.ToLower(d.read(name)) + "\n"
+		}
+	}
+	if marker == "" {
+		return
+	}
+	stack := Stack{Marker: marker, Runtime: "python"}
+	switch {
+	case strings.Contains(content, "django"):
+		stack.Framework = "django"
+	case strings.Contains(content, "fastapi"):
+		stack.Framework = "fastapi"
+	case strings.Contains(content, "flask"):
+		stack.Framework = "flask"
+	}
+	d.add(stack)
+}
+
+func (d *detector) detectRuby() {
+	content := d.read("Gemfile")
+	if content == "" {
+		return
+	}
+	stack := Stack{Marker: "Gemfile", Runtime: "ruby"}
+	switch {
+	case strings.Contains(content, "'rails'") || strings.Contains(content, `"rails"`):
+		stack.Framework = "rails"
+	case strings.Contains(content, "sinatra"):
+		stack.Framework = "sinatra"
+	}
+	d.add(stack)
+}
+
+func (d *detector) detectDocker() {
+	if content := d.read("Dockerfile"); content != "" {
+		d.stacks = append(d.stacks, Stack{Marker: "Dockerfile"})
+		d.tools = appendUnique(d.tools, "docker")
+		scanner := bufio.NewScanner(strings.NewReader(content))
+		for scanner.Scan() {
+			match := exposePattern.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+			for _, field := range strings.Fields(match[1]) {
+				if port, err := strconv.Atoi(strings.SplitN(field, "/", 2)[0]); err == nil {
+					d.addPort(port, "docker")
+				}
+			}
+		}
+	}
+
+	for _, name := range []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"} {
+		content := d.read(name)
+		if content == "" {
+			continue
+		}
+		d.stacks = append(d.stacks, Stack{Marker: name})
+		d.tools = appendUnique(d.tools, "docker")
+		d.tools = appendUnique(d.tools, "docker-compose")
+		scanner := bufio.NewScanner(strings.NewReader(content))
+		for scanner.Scan() {
+			// Compose publishes the host side, which is what the VM must forward
+			if match := composePortPattern.FindStringSubmatch(scanner.Text()); match != nil {
+				if port, err := strconv.Atoi(match[1]); err == nil {
+					d.addPort(port, "compose")
+				}
+			}
+		}
+		break
+	}
+}
+
+// result builds the detection and the recommended VM configuration
+func (d *detector) result() Detection {
+	detection := Detection{
+		ProjectPath:     d.dir,
+		Stacks:          d.stacks,
+		Runtimes:        d.runtimes,
+		Tools:           d.tools,
+		Ports:           []config.ProfilePort{},
+		ExcludePatterns: []string{".git", "*.log"},
+	}
+	if detection.Stacks == nil {
+		detection.Stacks = []Stack{}
+	}
+	if detection.Runtimes == nil {
+		detection.Runtimes = []string{}
+	}
+	if detection.Tools == nil {
+		detection.Tools = []string{}
+	}
+
+	guestPorts := make([]int, 0, len(d.ports))
+	for port := range d.ports {
+		guestPorts = append(guestPorts, port)
+	}
+	sort.Ints(guestPorts)
+	for _, port := range guestPorts {
+		detection.Ports = append(detection.Ports, config.ProfilePort{Service: d.ports[port], Guest: port, Host: port})
+	}
+	for _, runtime := range d.runtimes {
+		for _, pattern := range runtimeExcludes[runtime] {
+			detection.ExcludePatterns = appendUnique(detection.ExcludePatterns, pattern)
+		}
+	}
+
+	// Each runtime beyond the first and a container engine need more room
+	memory, cpu := 2048, 2
+	if len(d.runtimes) > 1 {
+		memory += 1024 * (len(d.runtimes) - 1)
+	}
+	if contains(d.tools, "docker") {
+		memory += 2048
+		cpu = 4
+	}
+	detection.Config = core.VMConfig{
+		CPU:                 cpu,
+		Memory:              memory,
+		SyncType:            "rsync",
+		SyncExcludePatterns: detection.ExcludePatterns,
+	}
+	for _, port := range detection.Ports {
+		detection.Config.Ports = append(detection.Config.Ports, core.Port{Guest: port.Guest, Host: port.Host})
+	}
+	return detection
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique appends s unless list already holds it
+func appendUnique(list []string, s string) []string {
+	if contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
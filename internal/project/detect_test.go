@@ -0,0 +1,137 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantRuntimes []string
+		wantTools    []string
+		wantPorts    []int
+		wantMemory   int
+		wantCPU      int
+	}{
+		{
+			name:         "empty project",
+			files:        map[string]string{},
+			wantRuntimes: []string{},
+			wantTools:    []string{},
+			wantPorts:    []int{},
+			wantMemory:   2048,
+			wantCPU:      2,
+		},
+		{
+			name:         "vite app",
+			files:        map[string]string{"package.json": `{"devDependencies": {"vite": "^5.0.0"}}`},
+			wantRuntimes: []string{"node"},
+			wantTools:    []string{},
+			wantPorts:    []int{5173},
+			wantMemory:   2048,
+			wantCPU:      2,
+		},
+		{
+			name: "go service with django admin",
+			files: map[string]string{
+				"go.mod":           "module example.com/app\n",
+				"requirements.txt": "Django==5.0\n",
+			},
+			wantRuntimes: []string{"go", "python"},
+			wantTools:    []string{},
+			wantPorts:    []int{8000, 8080},
+			wantMemory:   3072,
+			wantCPU:      2,
+		},
+		{
+			name: "rails app with dockerfile",
+			files: map[string]string{
+				"Gemfile":    "source 'https://rubygems.org'\ngem 'rails', '~> 7.1'\n",
+				"Dockerfile": "FROM ruby:3.3\nEXPOSE 3000 9292/tcp\n",
+			},
+			wantRuntimes: []string{"ruby"},
+			wantTools:    []string{"docker"},
+			wantPorts:    []int{3000, 9292},
+			wantMemory:   4096,
+			wantCPU:      4,
+		},
+		{
+			name: "compose project",
+			files: map[string]string{
+				"docker-compose.yml": "services:\n  db:\n    ports:\n      - \"5432:5432\"\n      - 127.0.0.1:6380:6379\n",
+			},
+			wantRuntimes: []string{},
+			wantTools:    []string{"docker", "docker-compose"},
+			wantPorts:    []int{5432, 6380},
+			wantMemory:   4096,
+			wantCPU:      4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", name, err)
+				}
+			}
+
+			detection, err := Detect(dir)
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if !reflect.DeepEqual(detection.Runtimes, tc.wantRuntimes) {
+				t.Errorf("Expected runtimes %v but got %v", tc.wantRuntimes, detection.Runtimes)
+			}
+			if !reflect.DeepEqual(detection.Tools, tc.wantTools) {
+				t.Errorf("Expected tools %v but got %v", tc.wantTools, detection.Tools)
+			}
+			ports := []int{}
+			for _, port := range detection.Ports {
+				ports = append(ports, port.Guest)
+			}
+			if !reflect.DeepEqual(ports, tc.wantPorts) {
+				t.Errorf("Expected ports %v but got %v", tc.wantPorts, ports)
+			}
+			if detection.Config.Memory != tc.wantMemory {
+				t.Errorf("Expected memory %d but got %d", tc.wantMemory, detection.Config.Memory)
+			}
+			if detection.Config.CPU != tc.wantCPU {
+				t.Errorf("Expected %d CPUs but got %d", tc.wantCPU, detection.Config.CPU)
+			}
+		})
+	}
+}
+
+func TestDetectExcludePatterns(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	detection, err := Detect(dir)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !contains(detection.ExcludePatterns, "node_modules") {
+		t.Errorf("Expected node_modules to be excluded but got %v", detection.ExcludePatterns)
+	}
+	if !reflect.DeepEqual(detection.Config.SyncExcludePatterns, detection.ExcludePatterns) {
+		t.Errorf("Expected the recommended config to use the exclude patterns but got %v", detection.Config.SyncExcludePatterns)
+	}
+}
+
+func TestDetectNotADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Detect(file); err == nil {
+		t.Error("Expected an error for a file but got none")
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package project
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the project manifest read by ensure_dev_vm and written by bootstrap_project
+const ManifestFile = ".vagrant-mcp.yaml"
+
+// manifestNamePattern matches the VM names a manifest may declare
+var manifestNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Manifest declares the development VM of a project so every checkout gets the same one
+type Manifest struct {
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Box      string `json:"box,omitempty" yaml:"box,omitempty"`
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	CPU      int    `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	// Memory is in MB
+	Memory     int `json:"memory,omitempty" yaml:"memory,omitempty"`
+	DiskSizeGB int `json:"disk_size_gb,omitempty" yaml:"disk_size_gb,omitempty"`
+	// Runtimes may be pinned to a version, such as node@20.11.1, to install them with their
+	// version manager; check_environment reports a different active version as drift
+	Runtimes []string     `json:"runtimes,omitempty" yaml:"runtimes,omitempty"`
+	Tools    []string     `json:"tools,omitempty" yaml:"tools,omitempty"`
+	Ports    []core.Port  `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Sync     ManifestSync `json:"sync" yaml:"sync"`
+	// Startup commands run in /vagrant as the vagrant user every time the VM boots
+	Startup []string `json:"startup,omitempty" yaml:"startup,omitempty"`
+	// Provision steps run in order after the runtimes and tools are installed
+	Provision []core.ProvisionStep `json:"provision,omitempty" yaml:"provision,omitempty"`
+	Tags      []string             `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// ManifestSync is the sync section of a manifest
+type ManifestSync struct {
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	// LineEndings documents the line endings of synced files; bootstrap_project writes it
+	LineEndings             string                        `json:"line_endings,omitempty" yaml:"line_endings,omitempty"`
+	Exclude                 []string                      `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	ConflictPolicy          string                        `json:"conflict_policy,omitempty" yaml:"conflict_policy,omitempty"`
+	ConflictPolicyOverrides []core.ConflictPolicyOverride `json:"conflict_policy_overrides,omitempty" yaml:"conflict_policy_overrides,omitempty"`
+}
+
+// LoadManifest reads the manifest of the project in dir. It returns nil without an error when
+// the project has none.
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ManifestFile, err)
+	}
+	manifest, err := ParseManifest(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	return manifest, nil
+}
+
+// ParseManifest parses and validates a manifest
+func ParseManifest(data string) (*Manifest, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &document); err != nil {
+		return nil, fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(document.Content) > 0 && document.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the manifest must be a mapping of settings")
+	}
+	var manifest Manifest
+	decoder := yaml.NewDecoder(strings.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Validate checks the values of a manifest
+func (m *Manifest) Validate() error {
+	if m.Name != "" && !manifestNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid VM name '%s'", m.Name)
+	}
+	if m.CPU < 0 || m.Memory < 0 || m.DiskSizeGB < 0 {
+		return fmt.Errorf("cpu, memory and disk_size_gb must not be negative")
+	}
+	for _, port := range m.Ports {
+		if port.Guest < 1 || port.Guest > 65535 || port.Host < 1 || port.Host > 65535 {
+			return fmt.Errorf("invalid port mapping %d:%d; guest and host ports must be between 1 and 65535", port.Guest, port.Host)
+		}
+	}
+	switch core.SyncMethod(m.Sync.Method) {
+	case "", core.SyncMethodRsync, core.SyncMethodNFS, core.SyncMethodSMB, core.SyncMethodVirtualBox, core.SyncMethodVirtioFS, core.SyncMethod9P:
+	default:
+		return fmt.Errorf("unsupported sync method '%s'", m.Sync.Method)
+	}
+	for _, command := range m.Startup {
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("startup commands must not be empty")
+		}
+	}
+	for _, step := range m.Provision {
+		if step.Name == "" {
+			return fmt.Errorf("provision steps need a name")
+		}
+	}
+	return nil
+}
+
+// SplitRuntime splits a manifest runtime such as node@20 into its name and the version it is
+// pinned to, which is empty when it is not pinned
+func SplitRuntime(runtime string) (string, string) {
+	name, version, _ := strings.Cut(runtime, "@")
+	return name, version
+}
+
+// Apply overrides the settings of config that the manifest declares
+func (m *Manifest) Apply(config *core.VMConfig) {
+	if m.Box != "" {
+		config.Box = m.Box
+	}
+	if m.Provider != "" {
+		config.Provider = m.Provider
+	}
+	if m.CPU > 0 {
+		config.CPU = m.CPU
+	}
+	if m.Memory > 0 {
+		config.Memory = m.Memory
+	}
+	if m.DiskSizeGB > 0 {
+		config.DiskSizeGB = m.DiskSizeGB
+	}
+	if m.Ports != nil {
+		config.Ports = m.Ports
+	}
+	if m.Sync.Method != "" {
+		config.SyncType = m.Sync.Method
+	}
+	if m.Sync.Exclude != nil {
+		config.SyncExcludePatterns = m.Sync.Exclude
+	}
+	if len(m.Startup) > 0 {
+		config.StartupCommands = m.Startup
+	}
+	if len(m.Provision) > 0 {
+		config.ProvisionSteps = m.Provision
+	}
+	if len(m.Tags) > 0 {
+		config.Tags = m.Tags
+	}
+}
@@ -0,0 +1,146 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestParseManifest(t *testing.T) {
+	data := `# Development VM for the web app
+name: "web-dev"
+box: ubuntu/jammy64
+cpu: 4
+memory: 4096  # MB
+runtimes: [node, "python"]
+ports:
+  - guest: 3000
+    host: 3000
+  - guest: 5432
+    host: 15432
+sync:
+  method: rsync
+  line_endings: lf
+  exclude:
+  - node_modules
+  - '*.log'
+  conflict_policy_overrides:
+    - pattern: "dist/**"
+      policy: prefer_vm
+startup:
+  - docker compose up -d
+  - "echo 'ready: #1'"
+provision:
+  - name: migrate
+    path: scripts/migrate.sh
+    privileged: false
+`
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	unprivileged := false
+	expected := &Manifest{
+		Name:     "web-dev",
+		Box:      "ubuntu/jammy64",
+		CPU:      4,
+		Memory:   4096,
+		Runtimes: []string{"node", "python"},
+		Ports:    []core.Port{{Guest: 3000, Host: 3000}, {Guest: 5432, Host: 15432}},
+		Sync: ManifestSync{
+			Method:                  "rsync",
+			LineEndings:             "lf",
+			Exclude:                 []string{"node_modules", "*.log"},
+			ConflictPolicyOverrides: []core.ConflictPolicyOverride{{Pattern: "dist/**", Policy: "prefer_vm"}},
+		},
+		Startup:   []string{"docker compose up -d", "echo 'ready: #1'"},
+		Provision: []core.ProvisionStep{{Name: "migrate", Path: "scripts/migrate.sh", Privileged: &unprivileged}},
+	}
+	if !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("Expected manifest %+v but got %+v", expected, manifest)
+	}
+}
+
+func TestParseManifestBlockScalars(t *testing.T) {
+	data := `name: api
+sync: {method: rsync, exclude: [target]}
+startup:
+  - >-
+    docker compose up
+    -d
+provision:
+  - name: seed
+    run: always
+    inline: |
+      set -e
+      psql -c 'select 1'
+      echo "seeded: #1"
+`
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if manifest.Sync.Method != "rsync" || !reflect.DeepEqual(manifest.Sync.Exclude, []string{"target"}) {
+		t.Errorf("Expected the flow mapping sync settings but got %+v", manifest.Sync)
+	}
+	if !reflect.DeepEqual(manifest.Startup, []string{"docker compose up -d"}) {
+		t.Errorf("Expected the folded startup command but got %q", manifest.Startup)
+	}
+	expected := []core.ProvisionStep{{Name: "seed", Run: "always", Inline: "set -e\npsql -c 'select 1'\necho \"seeded: #1\"\n"}}
+	if !reflect.DeepEqual(manifest.Provision, expected) {
+		t.Errorf("Expected the literal inline script %+v but got %+v", expected, manifest.Provision)
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"unknown key", "name: web\nram: 2048\n", "field ram not found"},
+		{"wrong type", "cpu: two\n", "cannot unmarshal"},
+		{"not a mapping", "- web\n", "must be a mapping"},
+		{"duplicate key", "name: a\nname: b\n", `key "name" already defined`},
+		{"tab indentation", "sync:\n\tmethod: rsync\n", "line 2"},
+		{"bad indentation", "name: web\n  box: x\n", "line 2"},
+		{"unknown nested key", "sync: {method: rsync, mode: fast}\n", "field mode not found"},
+		{"invalid name", "name: ../web\n", "invalid VM name"},
+		{"invalid port", "ports:\n  - guest: 70000\n    host: 1\n", "invalid port mapping"},
+		{"invalid sync method", "sync:\n  method: ftp\n", "unsupported sync method"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseManifest(tc.data)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q but got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := LoadManifest(dir)
+	if err != nil || manifest != nil {
+		t.Errorf("Expected no manifest but got %+v (%v)", manifest, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), []byte("name: web\nstartup: [make dev]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	manifest, err = LoadManifest(dir)
+	if err != nil || manifest == nil || manifest.Name != "web" {
+		t.Fatalf("Expected the manifest of web but got %+v (%v)", manifest, err)
+	}
+
+	config := core.VMConfig{CPU: 2, Memory: 2048, SyncType: "rsync"}
+	manifest.Apply(&config)
+	if config.CPU != 2 || config.SyncType != "rsync" || !reflect.DeepEqual(config.StartupCommands, []string{"make dev"}) {
+		t.Errorf("Expected only the startup commands to change but got %+v", config)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/approval"
+)
+
+// ApprovalsResourceURI is the URI of the pending approvals resource
+const ApprovalsResourceURI = "devvm://approvals"
+
+// registerApprovalsResource registers the pending approvals resource
+func registerApprovalsResource(srv *server.MCPServer, gate *approval.Gate) {
+	approvalsResource := mcp.NewResource(
+		ApprovalsResourceURI,
+		"Pending Approvals",
+		mcp.WithResourceDescription("Destructive operations waiting for human approval via approve_operation"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	srv.AddResource(approvalsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		result := map[string]interface{}{
+			"pending": gate.Pending(),
+		}
+
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal pending approvals: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
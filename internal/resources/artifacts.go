@@ -0,0 +1,100 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/artifacts"
+)
+
+// ArtifactsResourceURI is the URI of the artifact list resource
+const ArtifactsResourceURI = "devvm://artifacts"
+
+// registerArtifactsResource registers the artifact list resource
+func registerArtifactsResource(srv *server.MCPServer, store *artifacts.Store) {
+	artifactsResource := mcp.NewResource(
+		ArtifactsResourceURI,
+		"Artifacts",
+		mcp.WithResourceDescription("Stored artifacts (full command output, coverage reports, sync reports), newest first. Supports ?vm=<name>&kind=<kind>"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	srv.AddResource(artifactsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		vmName, kind := "", ""
+
+		// Parse optional query parameters
+		if parsed, err := url.Parse(request.Params.URI); err == nil {
+			query := parsed.Query()
+			vmName = query.Get("vm")
+			kind = query.Get("kind")
+		}
+
+		list, err := store.List(vmName)
+		if err != nil {
+			return nil, err
+		}
+		filtered := make([]artifacts.Artifact, 0, len(list))
+		for _, artifact := range list {
+			if kind == "" || artifact.Kind == kind {
+				filtered = append(filtered, artifact)
+			}
+		}
+
+		jsonData, err := json.Marshal(map[string]interface{}{
+			"artifacts": filtered,
+			"count":     len(filtered),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal artifacts: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
+
+// registerArtifactResource registers the artifact resource
+func registerArtifactResource(srv *server.MCPServer, store *artifacts.Store) {
+	artifactResource := mcp.NewResourceTemplate(
+		ArtifactTemplateURI,
+		"Artifact",
+		mcp.WithTemplateDescription("Content of an artifact, such as the full output of a command whose output was truncated. Use the get_artifact tool to read large artifacts in ranges"),
+		mcp.WithTemplateMIMEType("text/plain"),
+	)
+
+	srv.AddResourceTemplate(artifactResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		// Parse the artifact ID from URI (format: devvm://artifacts/{id})
+		id := strings.TrimPrefix(request.Params.URI, artifacts.URIPrefix)
+		_, file, err := store.Open(id)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read artifact: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "text/plain",
+				Text:     string(content),
+			},
+		}, nil
+	})
+}
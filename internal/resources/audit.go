@@ -0,0 +1,58 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/audit"
+)
+
+// AuditResourceURI is the URI of the audit log resource
+const AuditResourceURI = "devvm://audit"
+
+// auditResourceEntries is how many of the newest audit entries the resource returns
+const auditResourceEntries = 200
+
+// registerAuditResource registers the audit log resource
+func registerAuditResource(srv *server.MCPServer, auditLog *audit.Log) {
+	auditResource := mcp.NewResource(
+		AuditResourceURI,
+		"Audit Log",
+		mcp.WithResourceDescription("The newest tool calls, with secrets removed, and commands run in VMs with their exit codes"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	srv.AddResource(auditResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		entries, err := auditLog.Recent(auditResourceEntries)
+		if err != nil {
+			return nil, err
+		}
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		result := map[string]interface{}{
+			"enabled": auditLog.Enabled(),
+			"path":    auditLog.Path(),
+			"entries": entries,
+		}
+
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal audit log: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
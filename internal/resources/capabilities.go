@@ -0,0 +1,42 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/vm"
+)
+
+// CapabilitiesResourceURI is the URI of the Vagrant capabilities resource
+const CapabilitiesResourceURI = "devvm://capabilities"
+
+// registerCapabilitiesResource registers the Vagrant capabilities resource
+func registerCapabilitiesResource(srv *server.MCPServer, detector *vm.CapabilityDetector) {
+	capabilitiesResource := mcp.NewResource(
+		CapabilitiesResourceURI,
+		"Vagrant Capabilities",
+		mcp.WithResourceDescription("Installed Vagrant and provider versions, plugins, and the features they support"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	srv.AddResource(capabilitiesResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		jsonData, err := json.Marshal(detector.Get(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal capabilities: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
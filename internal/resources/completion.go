@@ -0,0 +1,115 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// Resource template URIs
+const (
+	ConfigTemplateURI    = "devvm://config/{vmName}"
+	FilesTemplateURI     = "devvm://files/{+path}{?offset,limit}"
+	LogsTemplateURI      = "devvm://logs/{vmName}/{logType}{?lines,unit}"
+	EnvTemplateURI       = "devvm://env/{vmName}"
+	ToolsTemplateURI     = "devvm://tools/{vmName}"
+	SSHTemplateURI       = "devvm://ssh/{vmName}"
+	ConflictsTemplateURI = "devvm://conflicts/{vmName}"
+	ArtifactTemplateURI  = "devvm://artifacts/{id}"
+)
+
+// maxCompletionValues is the most values a completion response may carry
+const maxCompletionValues = 100
+
+// KnownLogTypes lists the log types offered for devvm://logs completion
+var KnownLogTypes = []string{
+	LogTypeVagrant,
+	LogTypeProvision,
+	core.OperationLogUp,
+	core.OperationLogHalt,
+	core.OperationLogDestroy,
+	LogTypeSyslog,
+	LogTypeJournal,
+	"auth.log",
+	"kern.log",
+	"dpkg.log",
+	"cloud-init.log",
+	"cloud-init-output.log",
+}
+
+// Completer provides argument completion for devvm:// resource templates
+type Completer struct {
+	vmManager core.VMManager
+}
+
+// NewCompleter creates a new resource template completer
+func NewCompleter(vmManager core.VMManager) *Completer {
+	return &Completer{vmManager: vmManager}
+}
+
+// Complete returns completion values for a resource template argument
+func (c *Completer) Complete(ctx context.Context, params mcp.CompleteParams) (*mcp.CompleteResult, error) {
+	uri := templateURIFromRef(params.Ref)
+	value := params.Argument.Value
+
+	var candidates []string
+	switch {
+	case uri == LogsTemplateURI && params.Argument.Name == "logType":
+		candidates = KnownLogTypes
+	case (uri == ConfigTemplateURI || uri == LogsTemplateURI || uri == EnvTemplateURI || uri == ToolsTemplateURI || uri == SSHTemplateURI || uri == ConflictsTemplateURI) && params.Argument.Name == "vmName":
+		candidates = listVMDirectories(c.vmManager.GetBaseDir())
+	case uri == FilesTemplateURI && params.Argument.Name == "path":
+		// Complete the VM name segment of vmName/path
+		if !strings.Contains(value, "/") {
+			for _, vmName := range listVMDirectories(c.vmManager.GetBaseDir()) {
+				candidates = append(candidates, vmName+"/")
+			}
+		}
+	}
+
+	result := completeValues(candidates, value)
+	return &result, nil
+}
+
+// templateURIFromRef extracts the template URI from a completion reference
+func templateURIFromRef(ref any) string {
+	switch r := ref.(type) {
+	case mcp.ResourceReference:
+		return r.URI
+	case *mcp.ResourceReference:
+		return r.URI
+	case map[string]interface{}:
+		if refType, _ := r["type"].(string); refType != "ref/resource" {
+			return ""
+		}
+		uri, _ := r["uri"].(string)
+		return uri
+	}
+	return ""
+}
+
+// completeValues filters candidates by prefix and caps the result size
+func completeValues(candidates []string, prefix string) mcp.CompleteResult {
+	matches := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+
+	var result mcp.CompleteResult
+	result.Completion.Total = len(matches)
+	if len(matches) > maxCompletionValues {
+		matches = matches[:maxCompletionValues]
+		result.Completion.HasMore = true
+	}
+	result.Completion.Values = matches
+	return result
+}
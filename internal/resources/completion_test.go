@@ -0,0 +1,68 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestTemplateURIFromRef(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ref      any
+		expected string
+	}{
+		{
+			name:     "decoded resource reference",
+			ref:      map[string]interface{}{"type": "ref/resource", "uri": ConfigTemplateURI},
+			expected: ConfigTemplateURI,
+		},
+		{
+			name:     "prompt reference",
+			ref:      map[string]interface{}{"type": "ref/prompt", "name": "example"},
+			expected: "",
+		},
+		{
+			name:     "typed resource reference",
+			ref:      mcp.ResourceReference{Type: "ref/resource", URI: LogsTemplateURI},
+			expected: LogsTemplateURI,
+		},
+		{
+			name:     "missing reference",
+			ref:      nil,
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := templateURIFromRef(tc.ref); actual != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCompleteValues(t *testing.T) {
+	candidates := []string{"web-dev", "api-dev", "web-test"}
+
+	result := completeValues(candidates, "web")
+	if len(result.Completion.Values) != 2 {
+		t.Fatalf("Expected 2 values but got %v", result.Completion.Values)
+	}
+	if result.Completion.Values[0] != "web-dev" || result.Completion.Values[1] != "web-test" {
+		t.Errorf("Expected sorted matches but got %v", result.Completion.Values)
+	}
+
+	many := make([]string, maxCompletionValues+5)
+	for i := range many {
+		many[i] = "vm"
+	}
+	result = completeValues(many, "")
+	if len(result.Completion.Values) != maxCompletionValues || !result.Completion.HasMore {
+		t.Errorf("Expected %d values with more available but got %d (has_more=%v)", maxCompletionValues, len(result.Completion.Values), result.Completion.HasMore)
+	}
+	if result.Completion.Total != maxCompletionValues+5 {
+		t.Errorf("Expected total %d but got %d", maxCompletionValues+5, result.Completion.Total)
+	}
+}
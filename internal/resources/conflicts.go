@@ -0,0 +1,61 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// registerConflictsResource registers the sync conflict queue resource
+func registerConflictsResource(srv *server.MCPServer, syncEngine core.SyncEngine) {
+	conflictsResource := mcp.NewResourceTemplate(
+		ConflictsTemplateURI,
+		"VM Sync Conflicts",
+		mcp.WithTemplateDescription("Queued sync conflicts of a VM with the host and VM versions of each file and a unified diff from host to VM. Resolve them with resolve_sync_conflicts, passing edited content with the 'use_merged' resolution"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+
+	srv.AddResourceTemplate(conflictsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		// Parse VM name from URI (format: devvm://conflicts/{vmName})
+		vmName := strings.TrimPrefix(request.Params.URI, "devvm://conflicts/")
+		if vmName == "" || strings.Contains(vmName, "/") {
+			return nil, fmt.Errorf("VM name not specified")
+		}
+
+		provider, ok := syncEngine.(interface {
+			GetConflictDetails(ctx context.Context, vmName string) ([]core.ConflictDetail, error)
+		})
+		if !ok {
+			return nil, fmt.Errorf("conflict details are not available for this sync engine")
+		}
+		details, err := provider.GetConflictDetails(ctx, vmName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sync conflicts: %w", err)
+		}
+
+		jsonData, err := json.Marshal(map[string]interface{}{
+			"vm_name":   vmName,
+			"count":     len(details),
+			"conflicts": details,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal sync conflicts: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
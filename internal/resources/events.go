@@ -0,0 +1,78 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+const (
+	// EventsResourceURI is the URI of the server events resource
+	EventsResourceURI = "devvm://events"
+	// StatusResourceURI is the URI of the VM status resource
+	StatusResourceURI = "devvm://status"
+)
+
+// registerEventsResource registers the server events resource
+func registerEventsResource(srv *server.MCPServer, bus *events.Bus) {
+	eventsResource := mcp.NewResource(
+		EventsResourceURI,
+		"Server Events",
+		mcp.WithResourceDescription("Recent server events (VM state changes, sync completions, conflicts, watcher errors). Supports ?since=<id>&vm=<name>"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	srv.AddResource(eventsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		var sinceID uint64
+		vmName := ""
+
+		// Parse optional query parameters
+		if parsed, err := url.Parse(request.Params.URI); err == nil {
+			query := parsed.Query()
+			if since := query.Get("since"); since != "" {
+				sinceID, err = strconv.ParseUint(since, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid 'since' parameter: %w", err)
+				}
+			}
+			vmName = query.Get("vm")
+		}
+
+		result := map[string]interface{}{
+			"events": bus.Recent(sinceID, vmName),
+		}
+
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal events: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
+
+// forwardEventNotifications forwards bus events to subscribed clients as resource update notifications
+func forwardEventNotifications(srv *server.MCPServer, bus *events.Bus, subscriptions *Subscriptions) {
+	bus.Subscribe(func(event events.Event) {
+		subscriptions.Notify(srv, EventsResourceURI)
+
+		if event.Type == events.ApprovalRequested || event.Type == events.ApprovalResolved {
+			subscriptions.Notify(srv, ApprovalsResourceURI)
+		}
+	})
+}
@@ -0,0 +1,287 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// Page limits for the files resource: bytes for files, entries for directories
+const (
+	defaultFileBytes  = 1024 * 1024
+	maxFileBytes      = 4 * 1024 * 1024
+	defaultDirEntries = 1000
+	maxDirEntries     = 10000
+)
+
+// Markers of the first line guestFilesCommand prints
+const (
+	directoryMarker    = "D"
+	regularFileMarker  = "F"
+	fileNotFoundOutput = "ERROR: not found"
+)
+
+// filesWorkingDir is the guest directory relative paths start from
+const filesWorkingDir = "/vagrant"
+
+// filesRequest holds the parsed parts of a devvm://files URI
+type filesRequest struct {
+	VMName string
+	Path   string
+	Offset int
+	// Limit is 0 when the URI does not set it; the default depends on whether Path is a file
+	Limit int
+}
+
+// FileEntry describes an entry of a guest directory
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// filePage describes the part of a file a read returned, when it is not the whole file
+type filePage struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Offset     int    `json:"offset"`
+	Length     int    `json:"length"`
+	NextOffset int    `json:"next_offset,omitempty"`
+}
+
+// parseFilesURI parses devvm://files/{vmName}/{path}?offset=N&limit=N. Relative paths are
+// relative to /vagrant; a double slash after the VM name starts an absolute path.
+func parseFilesURI(uri string) (filesRequest, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return filesRequest{}, fmt.Errorf("invalid files URI: %w", err)
+	}
+	if parsed.Scheme != "devvm" || parsed.Host != "files" {
+		return filesRequest{}, fmt.Errorf("invalid files URI: %s", uri)
+	}
+
+	var request filesRequest
+	var found bool
+	request.VMName, request.Path, found = strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/")
+	if request.VMName == "" || !found || request.Path == "" {
+		return filesRequest{}, fmt.Errorf("invalid path format: expected 'vmName/path'")
+	}
+
+	query := parsed.Query()
+	if offset := query.Get("offset"); offset != "" {
+		request.Offset, err = strconv.Atoi(offset)
+		if err != nil || request.Offset < 0 {
+			return filesRequest{}, fmt.Errorf("invalid 'offset' parameter: %s", offset)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		request.Limit, err = strconv.Atoi(limit)
+		if err != nil || request.Limit <= 0 {
+			return filesRequest{}, fmt.Errorf("invalid 'limit' parameter: %s", limit)
+		}
+	}
+	return request, nil
+}
+
+// pageLimits returns the byte limit for a file read and the entry limit for a directory listing
+func (r filesRequest) pageLimits() (int, int) {
+	if r.Limit == 0 {
+		return defaultFileBytes, defaultDirEntries
+	}
+	return min(r.Limit, maxFileBytes), min(r.Limit, maxDirEntries)
+}
+
+// guestFilesCommand returns the command that lists a guest directory or reads a page of a
+// guest file. Its first line is the directory marker, or the file marker with the size, mode and
+// modification time; a directory's entries or the base64 file content follow.
+func guestFilesCommand(request filesRequest) string {
+	fileBytes, dirEntries := request.pageLimits()
+	// One more entry than the page tells whether the listing continues
+	return fmt.Sprintf(`p=%s; if [ -d "$p" ]; then echo %s; find "$p" -mindepth 1 -maxdepth 1 -printf '%%f\t%%y\t%%s\t%%m\t%%T@\n' | LC_ALL=C sort | tail -n +%d | head -n %d; `+
+		`elif [ -f "$p" ]; then stat --printf '%s\t%%s\t%%a\t%%Y\n' -- "$p"; tail -c +%d -- "$p" | head -c %d | base64 -w 0; `+
+		`else echo '%s'; fi`,
+		shellquote.Quote(request.Path), directoryMarker, request.Offset+1, dirEntries+1,
+		regularFileMarker, request.Offset+1, fileBytes, fileNotFoundOutput)
+}
+
+// parseDirectoryListing parses the entries printed by guestFilesCommand for a directory and
+// reports whether more than limit entries followed
+func parseDirectoryListing(output string, limit int) ([]FileEntry, bool) {
+	entries := []FileEntry{}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[2], 10, 64)
+		seconds, _ := strconv.ParseFloat(fields[4], 64)
+		entries = append(entries, FileEntry{
+			Name:    fields[0],
+			Type:    fileType(fields[1]),
+			Size:    size,
+			Mode:    fields[3],
+			ModTime: time.Unix(int64(seconds), 0).UTC(),
+		})
+	}
+	if len(entries) > limit {
+		return entries[:limit], true
+	}
+	return entries, false
+}
+
+// fileType names the file types printed by find's %y
+func fileType(code string) string {
+	switch code {
+	case "f":
+		return "file"
+	case "d":
+		return "directory"
+	case "l":
+		return "symlink"
+	default:
+		return "other"
+	}
+}
+
+// fileMIMEType returns the MIME type of a guest file from its extension
+func fileMIMEType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md":
+		return "text/markdown"
+	case ".go", ".py", ".rb", ".sh", ".ts", ".yaml", ".yml", ".toml", ".txt", "":
+		return "text/plain"
+	}
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// isText reports whether file content can be returned as text
+func isText(content []byte) bool {
+	return utf8.Valid(content) && !bytes.ContainsRune(content, 0)
+}
+
+// registerVMFilesResource registers the VM files resource
+func registerVMFilesResource(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	filesResource := mcp.NewResourceTemplate(
+		FilesTemplateURI,
+		"VM Files",
+		mcp.WithTemplateDescription("Access to VM file system (read-only), addressed as vmName/path. Directories return a JSON listing; binary files are returned as base64 blobs. Use ?offset=N&limit=N to page through large files (bytes) or directories (entries)"),
+	)
+
+	srv.AddResourceTemplate(filesResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		filesReq, err := parseFilesURI(request.Params.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		// Check VM state
+		state, err := vmManager.GetVMState(ctx, filesReq.VMName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VM state: %w", err)
+		}
+		if state != core.Running {
+			return nil, fmt.Errorf("VM is not running (current state: %s)", state)
+		}
+
+		execCtx := exec.ExecutionContext{
+			VMName:     filesReq.VMName,
+			WorkingDir: filesWorkingDir,
+			SyncBefore: false,
+			SyncAfter:  false,
+		}
+		result, err := executor.ExecuteCommand(ctx, guestFilesCommand(filesReq), execCtx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		header, body, _ := strings.Cut(result.Stdout, "\n")
+		fields := strings.Split(header, "\t")
+		fileBytes, dirEntries := filesReq.pageLimits()
+		switch fields[0] {
+		case directoryMarker:
+			entries, truncated := parseDirectoryListing(body, dirEntries)
+			listing := map[string]interface{}{
+				"path":      filesReq.Path,
+				"type":      "directory",
+				"entries":   entries,
+				"offset":    filesReq.Offset,
+				"truncated": truncated,
+			}
+			if truncated {
+				listing["next_offset"] = filesReq.Offset + len(entries)
+			}
+			jsonData, err := json.Marshal(listing)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal directory listing: %w", err)
+			}
+			return []mcp.ResourceContents{
+				mcp.TextResourceContents{
+					URI:      request.Params.URI,
+					MIMEType: "application/json",
+					Text:     string(jsonData),
+				},
+			}, nil
+		case regularFileMarker:
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("failed to read file: unexpected output %q", header)
+			}
+			size, _ := strconv.ParseInt(fields[1], 10, 64)
+			encoded := strings.TrimSpace(body)
+			content, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode file content: %w", err)
+			}
+
+			mimeType := fileMIMEType(filesReq.Path)
+			var contents []mcp.ResourceContents
+			if isText(content) {
+				contents = append(contents, mcp.TextResourceContents{URI: request.Params.URI, MIMEType: mimeType, Text: string(content)})
+			} else {
+				if strings.HasPrefix(mimeType, "text/") {
+					mimeType = "application/octet-stream"
+				}
+				contents = append(contents, mcp.BlobResourceContents{URI: request.Params.URI, MIMEType: mimeType, Blob: encoded})
+			}
+
+			// A partial read is followed by where it sits in the file, under the URI of the next page
+			if filesReq.Offset > 0 || int64(filesReq.Offset+len(content)) < size {
+				page := filePage{Path: filesReq.Path, Size: size, Offset: filesReq.Offset, Length: len(content)}
+				nextURI := request.Params.URI
+				if end := filesReq.Offset + len(content); int64(end) < size {
+					page.NextOffset = end
+					nextURI = fmt.Sprintf("devvm://files/%s/%s?offset=%d&limit=%d", filesReq.VMName, filesReq.Path, end, fileBytes)
+				}
+				jsonData, err := json.Marshal(page)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal file page: %w", err)
+				}
+				contents = append(contents, mcp.TextResourceContents{URI: nextURI, MIMEType: "application/json", Text: string(jsonData)})
+			}
+			return contents, nil
+		default:
+			return nil, fmt.Errorf("file not found: %s", filesReq.Path)
+		}
+	})
+}
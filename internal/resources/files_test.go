@@ -0,0 +1,122 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFilesURI(t *testing.T) {
+	testCases := []struct {
+		name        string
+		uri         string
+		expected    filesRequest
+		expectError bool
+	}{
+		{
+			name:     "relative path",
+			uri:      "devvm://files/my-vm/src/main.go",
+			expected: filesRequest{VMName: "my-vm", Path: "src/main.go"},
+		},
+		{
+			name:     "absolute path",
+			uri:      "devvm://files/my-vm//etc/hosts",
+			expected: filesRequest{VMName: "my-vm", Path: "/etc/hosts"},
+		},
+		{
+			name:     "page",
+			uri:      "devvm://files/my-vm/big.log?offset=1048576&limit=4096",
+			expected: filesRequest{VMName: "my-vm", Path: "big.log", Offset: 1048576, Limit: 4096},
+		},
+		{
+			name:        "missing path",
+			uri:         "devvm://files/my-vm",
+			expectError: true,
+		},
+		{
+			name:        "invalid offset",
+			uri:         "devvm://files/my-vm/a.txt?offset=-1",
+			expectError: true,
+		},
+		{
+			name:        "invalid limit",
+			uri:         "devvm://files/my-vm/a.txt?limit=0",
+			expectError: true,
+		},
+		{
+			name:        "wrong resource",
+			uri:         "devvm://logs/my-vm/syslog",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseFilesURI(tc.uri)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error but got %+v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %+v but got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGuestFilesCommandQuotesPath(t *testing.T) {
+	command := guestFilesCommand(filesRequest{Path: "it's; rm -rf /", Offset: 10, Limit: 20})
+	if !strings.HasPrefix(command, `p='it'\''s; rm -rf /';`) {
+		t.Errorf("Expected the path to be quoted but got %s", command)
+	}
+	for _, expected := range []string{"tail -n +11 | head -n 21", `tail -c +11 -- "$p" | head -c 20`} {
+		if !strings.Contains(command, expected) {
+			t.Errorf("Expected %q in %s", expected, command)
+		}
+	}
+}
+
+func TestParseDirectoryListing(t *testing.T) {
+	output := "a.txt\tf\t12\t644\t1700000000.5\nsub\td\t4096\t755\t1700000001.0\nlink\tl\t5\t777\t1700000002.0\n"
+	entries, truncated := parseDirectoryListing(output, 2)
+	if !truncated {
+		t.Errorf("Expected the listing to be truncated")
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries but got %d", len(entries))
+	}
+	expected := FileEntry{Name: "a.txt", Type: "file", Size: 12, Mode: "644", ModTime: time.Unix(1700000000, 0).UTC()}
+	if entries[0] != expected {
+		t.Errorf("Expected %+v but got %+v", expected, entries[0])
+	}
+	if entries[1].Type != "directory" {
+		t.Errorf("Expected directory but got %s", entries[1].Type)
+	}
+
+	if entries, truncated := parseDirectoryListing("", 10); truncated || len(entries) != 0 {
+		t.Errorf("Expected an empty listing but got %+v", entries)
+	}
+}
+
+func TestFileContentType(t *testing.T) {
+	if got := fileMIMEType("README.md"); got != "text/markdown" {
+		t.Errorf("Expected text/markdown but got %s", got)
+	}
+	if got := fileMIMEType("logo.png"); got != "image/png" {
+		t.Errorf("Expected image/png but got %s", got)
+	}
+	if got := fileMIMEType("data.unknown-ext"); got != "application/octet-stream" {
+		t.Errorf("Expected application/octet-stream but got %s", got)
+	}
+	if !isText([]byte("héllo\n")) {
+		t.Errorf("Expected UTF-8 text to be text")
+	}
+	if isText([]byte{0x89, 'P', 'N', 'G', 0}) {
+		t.Errorf("Expected binary content not to be text")
+	}
+}
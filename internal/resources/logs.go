@@ -0,0 +1,210 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/exec"
+)
+
+// Log types with special handling
+const (
+	// LogTypeVagrant is the host-side output of the last vagrant up
+	LogTypeVagrant = "vagrant"
+	// LogTypeProvision is the provisioner section of the last vagrant up
+	LogTypeProvision = core.OperationLogProvision
+	// LogTypeSyslog is the guest system log
+	LogTypeSyslog = "syslog"
+	// LogTypeJournal is the guest journal, optionally filtered by ?unit=
+	LogTypeJournal = "journal"
+)
+
+// Tail length limits for the logs resource
+const (
+	defaultLogLines = 200
+	maxLogLines     = 5000
+)
+
+var (
+	// logFilePattern restricts other log types to plain file names under /var/log
+	logFilePattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+	// unitPattern restricts journal unit names
+	unitPattern = regexp.MustCompile(`^[A-Za-z0-9@._:-]+$`)
+)
+
+// logsRequest holds the parsed parts of a devvm://logs URI
+type logsRequest struct {
+	VMName  string
+	LogType string
+	Lines   int
+	Unit    string
+}
+
+// parseLogsURI parses devvm://logs/{vmName}/{logType}?lines=N&unit=U
+func parseLogsURI(uri string) (logsRequest, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return logsRequest{}, fmt.Errorf("invalid logs URI: %w", err)
+	}
+
+	if parsed.Scheme != "devvm" || parsed.Host != "logs" {
+		return logsRequest{}, fmt.Errorf("invalid logs URI: %s", uri)
+	}
+
+	// The path is /{vmName}/{logType}; log types may name nested files under /var/log
+	request := logsRequest{
+		Lines: defaultLogLines,
+		Unit:  parsed.Query().Get("unit"),
+	}
+	request.VMName, request.LogType, _ = strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/")
+
+	if request.VMName == "" {
+		return logsRequest{}, fmt.Errorf("missing vmName in logs URI (expected devvm://logs/{vmName}/{logType})")
+	}
+	if request.LogType == "" {
+		return logsRequest{}, fmt.Errorf("missing logType in logs URI (expected devvm://logs/{vmName}/{logType})")
+	}
+
+	if lines := parsed.Query().Get("lines"); lines != "" {
+		request.Lines, err = strconv.Atoi(lines)
+		if err != nil || request.Lines <= 0 {
+			return logsRequest{}, fmt.Errorf("invalid 'lines' parameter: %s", lines)
+		}
+		if request.Lines > maxLogLines {
+			request.Lines = maxLogLines
+		}
+	}
+
+	if request.Unit != "" && !unitPattern.MatchString(request.Unit) {
+		return logsRequest{}, fmt.Errorf("invalid 'unit' parameter: %s", request.Unit)
+	}
+	return request, nil
+}
+
+// guestLogCommand returns the command that reads a guest-side log
+func guestLogCommand(request logsRequest) (string, error) {
+	switch request.LogType {
+	case LogTypeSyslog:
+		// Fall back to the journal on distributions without /var/log/syslog
+		return fmt.Sprintf("tail -n %d /var/log/syslog 2>/dev/null || sudo -n journalctl --no-pager -n %d", request.Lines, request.Lines), nil
+	case LogTypeJournal:
+		command := fmt.Sprintf("sudo -n journalctl --no-pager -n %d", request.Lines)
+		if request.Unit != "" {
+			command += fmt.Sprintf(" -u '%s'", request.Unit)
+		}
+		return command, nil
+	default:
+		if !logFilePattern.MatchString(request.LogType) || strings.Contains(request.LogType, "..") {
+			return "", fmt.Errorf("invalid log type: %s", request.LogType)
+		}
+		return fmt.Sprintf("tail -n %d '/var/log/%s' 2>/dev/null || echo 'ERROR: log not found'", request.Lines, request.LogType), nil
+	}
+}
+
+// hostLogOperation returns the operation log backing a host-side log type, or "" for guest logs
+func hostLogOperation(logType string) string {
+	if logType == LogTypeVagrant {
+		return core.OperationLogUp
+	}
+	for _, operation := range core.OperationLogs {
+		if logType == operation {
+			return operation
+		}
+	}
+	return ""
+}
+
+// tailLines returns the last n lines of text
+func tailLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readHostLog reads a host-side operation log for a VM
+func readHostLog(vmManager core.VMManager, request logsRequest, operation string) (string, error) {
+	data, err := os.ReadFile(core.OperationLogPath(vmManager.GetBaseDir(), request.VMName, operation))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no %s log recorded for VM '%s' yet", operation, request.VMName)
+		}
+		return "", fmt.Errorf("failed to read %s log: %w", operation, err)
+	}
+	return tailLines(string(data), request.Lines), nil
+}
+
+// registerVMLogsResource registers the VM logs resource
+func registerVMLogsResource(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+	logsResource := mcp.NewResourceTemplate(
+		LogsTemplateURI,
+		"VM Logs",
+		mcp.WithTemplateDescription("VM logs: vagrant or up (host-side vagrant up output), provision, halt, destroy, syslog, journal (?unit=), or any file under /var/log. Use ?lines=N to set the tail length"),
+		mcp.WithTemplateMIMEType("text/plain"),
+	)
+
+	srv.AddResourceTemplate(logsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		logsReq, err := parseLogsURI(request.Params.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		var text string
+		if operation := hostLogOperation(logsReq.LogType); operation != "" {
+			// Host-side logs are available even when the VM is not running
+			text, err = readHostLog(vmManager, logsReq, operation)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			command, err := guestLogCommand(logsReq)
+			if err != nil {
+				return nil, err
+			}
+
+			// Check VM state
+			state, err := vmManager.GetVMState(ctx, logsReq.VMName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get VM state: %w", err)
+			}
+			if state != core.Running {
+				return nil, fmt.Errorf("VM is not running (current state: %s)", state)
+			}
+
+			execCtx := exec.ExecutionContext{
+				VMName:     logsReq.VMName,
+				WorkingDir: "/",
+				SyncBefore: false,
+				SyncAfter:  false,
+			}
+			result, err := executor.ExecuteCommand(ctx, command, execCtx, nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read log: %w", err)
+			}
+			if strings.TrimSpace(result.Stdout) == "ERROR: log not found" {
+				return nil, fmt.Errorf("log not found: %s", logsReq.LogType)
+			}
+			text = result.Stdout
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "text/plain",
+				Text:     text,
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,147 @@
+package resources
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLogsURI(t *testing.T) {
+	testCases := []struct {
+		name        string
+		uri         string
+		expected    logsRequest
+		expectError bool
+	}{
+		{
+			name:     "defaults",
+			uri:      "devvm://logs/my-vm/syslog",
+			expected: logsRequest{VMName: "my-vm", LogType: "syslog", Lines: defaultLogLines},
+		},
+		{
+			name:     "lines and unit in any order",
+			uri:      "devvm://logs/my-vm/journal?unit=docker.service&lines=50",
+			expected: logsRequest{VMName: "my-vm", LogType: "journal", Lines: 50, Unit: "docker.service"},
+		},
+		{
+			name:     "lines capped",
+			uri:      "devvm://logs/my-vm/vagrant?lines=999999",
+			expected: logsRequest{VMName: "my-vm", LogType: "vagrant", Lines: maxLogLines},
+		},
+		{
+			name:     "nested log file",
+			uri:      "devvm://logs/my-vm/nginx/error.log",
+			expected: logsRequest{VMName: "my-vm", LogType: "nginx/error.log", Lines: defaultLogLines},
+		},
+		{
+			name:        "missing log type",
+			uri:         "devvm://logs/my-vm",
+			expectError: true,
+		},
+		{
+			name:        "invalid lines",
+			uri:         "devvm://logs/my-vm/syslog?lines=-5",
+			expectError: true,
+		},
+		{
+			name:        "invalid unit",
+			uri:         "devvm://logs/my-vm/journal?unit=x%27%3Brm",
+			expectError: true,
+		},
+		{
+			name:        "wrong resource",
+			uri:         "devvm://files/my-vm/syslog",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseLogsURI(tc.uri)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error but got %+v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %+v but got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGuestLogCommand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		request     logsRequest
+		contains    string
+		expectError bool
+	}{
+		{
+			name:     "journal with unit",
+			request:  logsRequest{LogType: LogTypeJournal, Lines: 10, Unit: "nginx"},
+			contains: "journalctl --no-pager -n 10 -u 'nginx'",
+		},
+		{
+			name:     "syslog falls back to journal",
+			request:  logsRequest{LogType: LogTypeSyslog, Lines: 10},
+			contains: "|| sudo -n journalctl",
+		},
+		{
+			name:     "plain log file",
+			request:  logsRequest{LogType: "auth.log", Lines: 10},
+			contains: "tail -n 10 '/var/log/auth.log'",
+		},
+		{
+			name:        "path traversal",
+			request:     logsRequest{LogType: "../etc/shadow", Lines: 10},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			command, err := guestLogCommand(tc.request)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error but got command %q", command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if !strings.Contains(command, tc.contains) {
+				t.Errorf("Expected command to contain %q but got %q", tc.contains, command)
+			}
+		})
+	}
+}
+
+func TestHostLogOperation(t *testing.T) {
+	testCases := map[string]string{
+		LogTypeVagrant:   "up",
+		"up":             "up",
+		LogTypeProvision: "provision",
+		"destroy":        "destroy",
+		LogTypeSyslog:    "",
+		"auth.log":       "",
+	}
+	for logType, expected := range testCases {
+		if actual := hostLogOperation(logType); actual != expected {
+			t.Errorf("Expected %q for %s but got %q", expected, logType, actual)
+		}
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	if actual := tailLines("a\nb\nc\n", 2); actual != "b\nc" {
+		t.Errorf("Expected %q but got %q", "b\nc", actual)
+	}
+	if actual := tailLines("a\nb\n", 5); actual != "a\nb" {
+		t.Errorf("Expected %q but got %q", "a\nb", actual)
+	}
+}
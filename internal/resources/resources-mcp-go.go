@@ -4,20 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"path/filepath"
 	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/approval"
+	"github.com/vagrant-mcp/server/internal/artifacts"
+	"github.com/vagrant-mcp/server/internal/audit"
 	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/events"
 	"github.com/vagrant-mcp/server/internal/exec"
+	"github.com/vagrant-mcp/server/internal/schema"
+	"github.com/vagrant-mcp/server/internal/vm"
 )
 
 // RegisterMCPResources registers all resources with the MCP server
-func RegisterMCPResources(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
+func RegisterMCPResources(srv *server.MCPServer, vmManager core.VMManager, syncEngine core.SyncEngine, executor *exec.Executor) {
 	// Register VM status resource
-	registerVMStatusResource(srv, vmManager)
+	registerVMStatusResource(srv, vmManager, syncEngine, events.GlobalBus, GlobalSubscriptions)
 
 	// Register VM config resource
 	registerVMConfigResource(srv, vmManager)
@@ -34,69 +39,46 @@ func RegisterMCPResources(srv *server.MCPServer, vmManager core.VMManager, execu
 	// Register VM installed tools resource
 	registerVMInstalledToolsResource(srv, vmManager, executor)
 
-	log.Info().Msg("All resources registered with MCP server")
-}
+	// Register VM SSH connection resource
+	registerVMSSHResource(srv, vmManager)
 
-// registerVMStatusResource registers the VM status resource
-func registerVMStatusResource(srv *server.MCPServer, vmManager core.VMManager) {
-	statusResource := mcp.NewResource(
-		"devvm://status",
-		"VM Status",
-		mcp.WithResourceDescription("Current development VM status and health"),
-	)
+	// Register sync conflict queue resource
+	registerConflictsResource(srv, syncEngine)
 
-	srv.AddResource(statusResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		// Format result
-		result := make(map[string]interface{})
+	// Register artifact resources
+	artifactStore := artifacts.NewStore(vmManager.GetBaseDir())
+	registerArtifactsResource(srv, artifactStore)
+	registerArtifactResource(srv, artifactStore)
 
-		// List VM directories using the accessor
-		baseDir := vmManager.GetBaseDir()
-		vmDirs, dirErr := filepath.Glob(filepath.Join(baseDir, "*"))
-		if dirErr != nil {
-			return nil, fmt.Errorf("failed to list VM directories: %w", dirErr)
-		}
+	// Register server events resource and forward events as update notifications
+	registerEventsResource(srv, events.GlobalBus)
+	forwardEventNotifications(srv, events.GlobalBus, GlobalSubscriptions)
 
-		for _, vmDir := range vmDirs {
-			vmName := filepath.Base(vmDir)
-			state, err := vmManager.GetVMState(context.Background(), vmName)
-			if err != nil {
-				result[vmName] = map[string]interface{}{
-					"state": "error",
-					"error": err.Error(),
-				}
-				continue
-			}
-
-			result[vmName] = map[string]interface{}{
-				"state": state,
-			}
-		}
+	// Register pending approvals resource
+	registerApprovalsResource(srv, approval.GlobalGate)
 
-		// Marshal to JSON
-		jsonData, err := json.Marshal(result)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal status: %w", err)
-		}
+	// Register audit log resource
+	registerAuditResource(srv, audit.GlobalLog)
 
-		return []mcp.ResourceContents{
-			mcp.TextResourceContents{
-				URI:      request.Params.URI,
-				MIMEType: "application/json",
-				Text:     string(jsonData),
-			},
-		}, nil
-	})
+	// Register Vagrant capabilities resource
+	registerCapabilitiesResource(srv, vm.GlobalCapabilities)
+
+	// Register tool outputs resource
+	registerToolOutputsResource(srv, schema.GlobalRegistry)
+
+	log.Info().Msg("All resources registered with MCP server")
 }
 
 // registerVMConfigResource registers the VM config resource
 func registerVMConfigResource(srv *server.MCPServer, vmManager core.VMManager) {
-	configResource := mcp.NewResource(
-		"devvm://config/{vmName}",
+	configResource := mcp.NewResourceTemplate(
+		ConfigTemplateURI,
 		"VM Configuration",
-		mcp.WithResourceDescription("Current VM configuration and sync settings"),
+		mcp.WithTemplateDescription("Current VM configuration and sync settings"),
+		mcp.WithTemplateMIMEType("application/json"),
 	)
 
-	srv.AddResource(configResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	srv.AddResourceTemplate(configResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 		// Extract VM name from URI
 		uri := request.Params.URI
 		vmName := ""
@@ -133,160 +115,16 @@ func registerVMConfigResource(srv *server.MCPServer, vmManager core.VMManager) {
 	})
 }
 
-// registerVMFilesResource registers the VM files resource
-func registerVMFilesResource(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
-	filesResource := mcp.NewResource(
-		"devvm://files/{path*}",
-		"VM Files",
-		mcp.WithResourceDescription("Access to VM file system (read-only)"),
-	)
-
-	srv.AddResource(filesResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		// Extract VM name and path from URI
-		uri := request.Params.URI
-		pathParam := strings.TrimPrefix(uri, "devvm://files/")
-		if pathParam == "" {
-			return nil, fmt.Errorf("missing path parameter")
-		}
-
-		// Split the path to get VM name and file path
-		parts := strings.SplitN(pathParam, "/", 2)
-		if len(parts) < 2 {
-			return nil, fmt.Errorf("invalid path format: expected 'vmName/path'")
-		}
-
-		vmName := parts[0]
-		path := parts[1]
-
-		// Check VM state
-		state, err := vmManager.GetVMState(context.Background(), vmName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get VM state: %w", err)
-		}
-
-		if state != core.Running {
-			return nil, fmt.Errorf("VM is not running (current state: %s)", state)
-		}
-
-		// Setup execution context
-		execCtx := exec.ExecutionContext{
-			VMName:     vmName,
-			WorkingDir: "/vagrant",
-			SyncBefore: false,
-			SyncAfter:  false,
-		}
-
-		// Read file content from VM
-		command := fmt.Sprintf("cat %s", path)
-		result, err := executor.ExecuteCommand(ctx, command, execCtx, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file: %w", err)
-		}
-
-		// Determine MIME type from file extension
-		mimeType := "text/plain"
-		ext := filepath.Ext(path)
-		switch strings.ToLower(ext) {
-		case ".json":
-			mimeType = "application/json"
-		case ".html":
-			mimeType = "text/html"
-		case ".js":
-			mimeType = "application/javascript"
-		case ".css":
-			mimeType = "text/css"
-		case ".png":
-			mimeType = "image/png"
-		case ".jpg", ".jpeg":
-			mimeType = "image/jpeg"
-		case ".gif":
-			mimeType = "image/gif"
-		case ".md":
-			mimeType = "text/markdown"
-		}
-
-		return []mcp.ResourceContents{
-			mcp.TextResourceContents{
-				URI:      request.Params.URI,
-				MIMEType: mimeType,
-				Text:     result.Stdout,
-			},
-		}, nil
-	})
-}
-
-// registerVMLogsResource registers the VM logs resource
-func registerVMLogsResource(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
-	logsResource := mcp.NewResource(
-		"devvm://logs/{logType}",
-		"VM Logs",
-		mcp.WithResourceDescription("VM logs for sync and provisioning"),
-	)
-
-	srv.AddResource(logsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-		// Extract log type from URI
-		uri := request.Params.URI
-		logType := strings.TrimPrefix(uri, "devvm://logs/")
-		if logType == "" {
-			return nil, fmt.Errorf("missing logType parameter")
-		}
-
-		// Get VM name from URI segment
-		// In the real implementation, this would parse VM name from query params
-		// For now, let's extract it from the URI or use a default
-		vmName := "default"
-		if vmName == "" {
-			return nil, fmt.Errorf("missing required query parameter: vm")
-		}
-
-		// Check VM state
-		state, err := vmManager.GetVMState(context.Background(), vmName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get VM state: %w", err)
-		}
-
-		if state != core.Running {
-			return nil, fmt.Errorf("VM is not running (current state: %s)", state)
-		}
-
-		// Setup execution context
-		execCtx := exec.ExecutionContext{
-			VMName:     vmName,
-			WorkingDir: "/",
-			SyncBefore: false,
-			SyncAfter:  false,
-		}
-
-		// Get log contents with tail to avoid massive output
-		tailCmd := fmt.Sprintf("tail -n 200 '/var/log/%s' 2>/dev/null || echo 'ERROR: log not found'", logType)
-		result, err := executor.ExecuteCommand(ctx, tailCmd, execCtx, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read log: %w", err)
-		}
-
-		if result.Stdout == "ERROR: log not found" {
-			return nil, fmt.Errorf("log not found: %s", logType)
-		}
-
-		return []mcp.ResourceContents{
-			mcp.TextResourceContents{
-				URI:      request.Params.URI,
-				MIMEType: "text/plain",
-				Text:     result.Stdout,
-			},
-		}, nil
-	})
-}
-
 // registerVMEnvironmentResource registers the VM environment resource
 func registerVMEnvironmentResource(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
-	envResource := mcp.NewResource(
-		"devvm://env/{vmName}",
+	envResource := mcp.NewResourceTemplate(
+		EnvTemplateURI,
 		"VM Environment",
-		mcp.WithResourceDescription("Environment configuration for development VMs"),
+		mcp.WithTemplateDescription("Environment configuration for development VMs"),
+		mcp.WithTemplateMIMEType("application/json"),
 	)
 
-	srv.AddResource(envResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	srv.AddResourceTemplate(envResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 		// Extract VM name from URI
 		uri := request.Params.URI
 		vmName := ""
@@ -343,13 +181,14 @@ func registerVMEnvironmentResource(srv *server.MCPServer, vmManager core.VMManag
 
 // registerVMInstalledToolsResource registers the VM installed tools resource
 func registerVMInstalledToolsResource(srv *server.MCPServer, vmManager core.VMManager, executor *exec.Executor) {
-	toolsResource := mcp.NewResource(
-		"devvm://tools/{vmName}",
+	toolsResource := mcp.NewResourceTemplate(
+		ToolsTemplateURI,
 		"VM Installed Tools",
-		mcp.WithResourceDescription("Information about tools installed in the VM"),
+		mcp.WithTemplateDescription("Information about tools installed in the VM"),
+		mcp.WithTemplateMIMEType("application/json"),
 	)
 
-	srv.AddResource(toolsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	srv.AddResourceTemplate(toolsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
 		// Extract VM name from URI
 		uri := request.Params.URI
 		vmName := ""
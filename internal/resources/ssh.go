@@ -0,0 +1,66 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/vm"
+)
+
+// registerVMSSHResource registers the VM SSH connection resource
+func registerVMSSHResource(srv *server.MCPServer, vmManager core.VMManager) {
+	sshResource := mcp.NewResourceTemplate(
+		SSHTemplateURI,
+		"VM SSH Connection",
+		mcp.WithTemplateDescription("Host, port, user, identity file and ready-to-paste ssh command for a running VM"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+
+	srv.AddResourceTemplate(sshResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		// Parse VM name from URI (format: devvm://ssh/{vmName})
+		vmName := strings.TrimPrefix(request.Params.URI, "devvm://ssh/")
+		if vmName == "" || strings.Contains(vmName, "/") {
+			return nil, fmt.Errorf("VM name not specified")
+		}
+
+		state, err := vmManager.GetVMState(ctx, vmName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VM state: %w", err)
+		}
+		if state != core.Running {
+			return nil, fmt.Errorf("VM is not running (current state: %s)", state)
+		}
+
+		provider, ok := vmManager.(interface {
+			GetSSHConfig(context.Context, string) (map[string]string, error)
+		})
+		if !ok {
+			return nil, fmt.Errorf("SSH configuration is not available for this VM manager")
+		}
+		sshConfig, err := provider.GetSSHConfig(ctx, vmName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get SSH configuration: %w", err)
+		}
+
+		jsonData, err := json.Marshal(vm.NewSSHInfo(vmName, sshConfig))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal SSH connection: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,273 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/events"
+	"github.com/vagrant-mcp/server/internal/vm"
+)
+
+// Status collection defaults
+const (
+	DefaultStatusCacheTTL = 10 * time.Second
+	statusWorkers         = 8
+	statusGuestTimeout    = 10 * time.Second
+)
+
+// VMStatusEntry is the status payload reported for a single VM
+type VMStatusEntry struct {
+	State          core.VMState `json:"state"`
+	Error          string       `json:"error,omitempty"`
+	Provider       string       `json:"provider,omitempty"`
+	Box            string       `json:"box,omitempty"`
+	CPU            int          `json:"cpu,omitempty"`
+	Memory         int          `json:"memory,omitempty"`
+	ProjectPath    string       `json:"project_path,omitempty"`
+	UptimeSeconds  float64      `json:"uptime_seconds,omitempty"`
+	IPAddresses    []string     `json:"ip_addresses,omitempty"`
+	ForwardedPorts []core.Port  `json:"forwarded_ports"`
+	LastSyncTime   *time.Time   `json:"last_sync_time,omitempty"`
+	CollectedAt    time.Time    `json:"collected_at"`
+}
+
+// statusCache collects VM status in parallel and caches it for a TTL
+type statusCache struct {
+	vmManager  core.VMManager
+	syncEngine core.SyncEngine
+	ttl        time.Duration
+
+	collectMu   sync.Mutex
+	mu          sync.Mutex
+	snapshot    map[string]VMStatusEntry
+	collectedAt time.Time
+}
+
+// newStatusCache creates a new status cache
+func newStatusCache(vmManager core.VMManager, syncEngine core.SyncEngine, ttl time.Duration) *statusCache {
+	if ttl <= 0 {
+		ttl = DefaultStatusCacheTTL
+	}
+	return &statusCache{
+		vmManager:  vmManager,
+		syncEngine: syncEngine,
+		ttl:        ttl,
+	}
+}
+
+// Get returns the cached status, collecting it again once the TTL has passed
+func (c *statusCache) Get(ctx context.Context) map[string]VMStatusEntry {
+	if snapshot := c.fresh(); snapshot != nil {
+		return snapshot
+	}
+
+	// Only one caller collects at a time; the others reuse its result
+	c.collectMu.Lock()
+	defer c.collectMu.Unlock()
+	if snapshot := c.fresh(); snapshot != nil {
+		return snapshot
+	}
+
+	snapshot := c.collect(ctx)
+	c.mu.Lock()
+	c.snapshot = snapshot
+	c.collectedAt = time.Now()
+	c.mu.Unlock()
+	return snapshot
+}
+
+// Invalidate drops the cached status so the next read collects it again
+func (c *statusCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshot = nil
+}
+
+// fresh returns the cached snapshot if it is still within the TTL
+func (c *statusCache) fresh() map[string]VMStatusEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.snapshot == nil || time.Since(c.collectedAt) >= c.ttl {
+		return nil
+	}
+	return c.snapshot
+}
+
+// collect gathers the status of every VM using a bounded worker pool
+func (c *statusCache) collect(ctx context.Context) map[string]VMStatusEntry {
+	vmNames := listVMDirectories(c.vmManager.GetBaseDir())
+
+	result := make(map[string]VMStatusEntry, len(vmNames))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statusWorkers)
+
+	for _, vmName := range vmNames {
+		wg.Add(1)
+		go func(vmName string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			entry := c.collectVM(ctx, vmName)
+			mu.Lock()
+			result[vmName] = entry
+			mu.Unlock()
+		}(vmName)
+	}
+	wg.Wait()
+
+	return result
+}
+
+// collectVM gathers the status of a single VM
+func (c *statusCache) collectVM(ctx context.Context, vmName string) VMStatusEntry {
+	entry := VMStatusEntry{
+		ForwardedPorts: []core.Port{},
+		CollectedAt:    time.Now(),
+		Provider:       detectProvider(vm.VMDir(c.vmManager.GetBaseDir(), vmName), vm.MachineName(c.vmManager.GetBaseDir(), vmName)),
+	}
+
+	state, err := c.vmManager.GetVMState(ctx, vmName)
+	if err != nil {
+		entry.State = core.Error
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.State = state
+
+	if config, err := c.vmManager.GetVMConfig(ctx, vmName); err == nil {
+		entry.Box = config.Box
+		entry.CPU = config.CPU
+		entry.Memory = config.Memory
+		entry.ProjectPath = config.ProjectPath
+		if config.Ports != nil {
+			entry.ForwardedPorts = config.Ports
+		}
+	}
+
+	if c.syncEngine != nil {
+		if status, err := c.syncEngine.GetSyncStatus(ctx, vmName); err == nil && !status.LastSyncTime.IsZero() {
+			lastSync := status.LastSyncTime
+			entry.LastSyncTime = &lastSync
+		}
+	}
+
+	if state == core.Running {
+		guestCtx, cancel := context.WithTimeout(ctx, statusGuestTimeout)
+		defer cancel()
+		stdout, _, exitCode, err := c.vmManager.ExecuteCommand(guestCtx, vmName, "cat /proc/uptime; hostname -I", nil, "")
+		if err == nil && exitCode == 0 {
+			entry.UptimeSeconds, entry.IPAddresses = parseGuestStatus(stdout)
+		}
+	}
+
+	return entry
+}
+
+// listVMDirectories returns the names of the VM directories under baseDir
+func listVMDirectories(baseDir string) []string {
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return nil
+	}
+
+	var vmNames []string
+	for _, entry := range entries {
+		// Hidden directories such as the destroyed VM logs are not VMs
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			vmNames = append(vmNames, entry.Name())
+		}
+	}
+	return vmNames
+}
+
+// detectProvider returns the provider Vagrant created the machine with, if any
+func detectProvider(vmDir, machine string) string {
+	providers, err := os.ReadDir(filepath.Join(vmDir, ".vagrant", "machines", machine))
+	if err != nil {
+		return ""
+	}
+	for _, provider := range providers {
+		if _, err := os.Stat(filepath.Join(vmDir, ".vagrant", "machines", machine, provider.Name(), "id")); err == nil {
+			return provider.Name()
+		}
+	}
+	return ""
+}
+
+// parseGuestStatus parses the output of "cat /proc/uptime; hostname -I"
+func parseGuestStatus(output string) (float64, []string) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 {
+		return 0, nil
+	}
+
+	var uptime float64
+	if fields := strings.Fields(lines[0]); len(fields) > 0 {
+		uptime, _ = strconv.ParseFloat(fields[0], 64)
+	}
+
+	var addresses []string
+	if len(lines) > 1 {
+		addresses = strings.Fields(lines[1])
+	}
+	return uptime, addresses
+}
+
+// registerVMStatusResource registers the VM status resource
+func registerVMStatusResource(srv *server.MCPServer, vmManager core.VMManager, syncEngine core.SyncEngine, bus *events.Bus, subscriptions *Subscriptions) {
+	statusResource := mcp.NewResource(
+		StatusResourceURI,
+		"VM Status",
+		mcp.WithResourceDescription("Current development VM status and health, including provider, uptime, IP addresses, forwarded ports and last sync time"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	ttl := DefaultStatusCacheTTL
+	if value := os.Getenv("MCP_STATUS_CACHE_TTL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			ttl = parsed
+		}
+	}
+	cache := newStatusCache(vmManager, syncEngine, ttl)
+
+	// Drop cached status before telling subscribed clients it changed
+	bus.Subscribe(func(event events.Event) {
+		if event.Type == events.VMStateChanged || event.Type == events.SyncCompleted {
+			cache.Invalidate()
+			subscriptions.Notify(srv, StatusResourceURI)
+		}
+	})
+
+	srv.AddResource(statusResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		result := cache.Get(ctx)
+
+		// Marshal to JSON
+		jsonData, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal status: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,66 @@
+package resources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGuestStatus(t *testing.T) {
+	testCases := []struct {
+		name              string
+		output            string
+		expectedUptime    float64
+		expectedAddresses int
+	}{
+		{
+			name:              "uptime and addresses",
+			output:            "3600.52 7000.10\n10.0.2.15 192.168.56.10 \n",
+			expectedUptime:    3600.52,
+			expectedAddresses: 2,
+		},
+		{
+			name:              "uptime only",
+			output:            "12.00 20.00\n",
+			expectedUptime:    12,
+			expectedAddresses: 0,
+		},
+		{
+			name:              "empty output",
+			output:            "",
+			expectedUptime:    0,
+			expectedAddresses: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uptime, addresses := parseGuestStatus(tc.output)
+			if uptime != tc.expectedUptime {
+				t.Errorf("Expected uptime %v but got %v", tc.expectedUptime, uptime)
+			}
+			if len(addresses) != tc.expectedAddresses {
+				t.Errorf("Expected %d addresses but got %v", tc.expectedAddresses, addresses)
+			}
+		})
+	}
+}
+
+func TestDetectProvider(t *testing.T) {
+	vmDir := t.TempDir()
+	if provider := detectProvider(vmDir, "default"); provider != "" {
+		t.Errorf("Expected no provider for uncreated VM but got %q", provider)
+	}
+
+	machineDir := filepath.Join(vmDir, ".vagrant", "machines", "default", "virtualbox")
+	if err := os.MkdirAll(machineDir, 0755); err != nil {
+		t.Fatalf("Failed to create machine directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(machineDir, "id"), []byte("abc"), 0644); err != nil {
+		t.Fatalf("Failed to write machine id: %v", err)
+	}
+
+	if provider := detectProvider(vmDir, "default"); provider != "virtualbox" {
+		t.Errorf("Expected provider virtualbox but got %q", provider)
+	}
+}
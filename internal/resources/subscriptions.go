@@ -0,0 +1,79 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Subscriptions keeps the resources each client session subscribed to with resources/subscribe
+type Subscriptions struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]bool
+}
+
+// GlobalSubscriptions holds the resource subscriptions of the server's client sessions
+var GlobalSubscriptions = NewSubscriptions()
+
+// NewSubscriptions creates an empty subscription registry
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{sessions: make(map[string]map[string]bool)}
+}
+
+// Subscribe subscribes a session to update notifications for a resource URI
+func (s *Subscriptions) Subscribe(sessionID, uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessions[sessionID] == nil {
+		s.sessions[sessionID] = make(map[string]bool)
+	}
+	s.sessions[sessionID][uri] = true
+}
+
+// Unsubscribe removes a session's subscription to a resource URI
+func (s *Subscriptions) Unsubscribe(sessionID, uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions[sessionID], uri)
+	if len(s.sessions[sessionID]) == 0 {
+		delete(s.sessions, sessionID)
+	}
+}
+
+// Subscribers returns the IDs of the sessions subscribed to a resource URI, sorted
+func (s *Subscriptions) Subscribers(uri string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessionIDs []string
+	for sessionID, uris := range s.sessions {
+		if uris[uri] {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+	sort.Strings(sessionIDs)
+	return sessionIDs
+}
+
+// Notify sends a resource updated notification for uri to the sessions subscribed to it.
+// Subscriptions of sessions that have disconnected are dropped.
+func (s *Subscriptions) Notify(srv *server.MCPServer, uri string) {
+	for _, sessionID := range s.Subscribers(uri) {
+		err := srv.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{
+			"uri": uri,
+		})
+		if errors.Is(err, server.ErrSessionNotFound) {
+			s.mu.Lock()
+			delete(s.sessions, sessionID)
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/schema"
+)
+
+// ToolOutputsResourceURI is the URI of the tool outputs resource
+const ToolOutputsResourceURI = "devvm://tool-outputs"
+
+// registerToolOutputsResource registers the resource describing what every tool returns
+func registerToolOutputsResource(srv *server.MCPServer, registry *schema.Registry) {
+	toolOutputsResource := mcp.NewResource(
+		ToolOutputsResourceURI,
+		"Tool Outputs",
+		mcp.WithResourceDescription("JSON Schemas of the results of every tool, or descriptions of their plain-text results"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	srv.AddResource(toolOutputsResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		outputs := registry.All()
+		jsonData, err := json.Marshal(map[string]interface{}{
+			"schema_version": schema.Version,
+			"tools":          outputs,
+			"count":          len(outputs),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tool outputs: %w", err)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "application/json",
+				Text:     string(jsonData),
+			},
+		}, nil
+	})
+}
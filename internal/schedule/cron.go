@@ -0,0 +1,165 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch bounds the search for the next time a cron expression matches, so expressions
+// that never match, e.g. February 30, fail instead of searching forever
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// macros are the named schedules accepted in place of five fields
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// Spec is a parsed schedule: a five-field cron expression in the server's local time, or a
+// fixed interval
+type Spec struct {
+	minute, hour, dom, month, dow fieldSet
+	// domAny and dowAny record unrestricted day fields; when both day fields are restricted a
+	// day matching either runs, as in cron
+	domAny, dowAny bool
+	every          time.Duration
+}
+
+// fieldSet holds the values a cron field matches
+type fieldSet map[int]bool
+
+// Parse parses a schedule: "minute hour day-of-month month day-of-week" with *, lists, ranges
+// and steps, one of @hourly, @daily, @weekly, @monthly and @yearly, or "@every <duration>"
+// such as "@every 6h"
+func Parse(expression string) (Spec, error) {
+	expression = strings.TrimSpace(expression)
+	if rest, ok := strings.CutPrefix(expression, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return Spec{}, fmt.Errorf("invalid interval %q: %w", rest, err)
+		}
+		if every < time.Minute {
+			return Spec{}, fmt.Errorf("interval %s is shorter than a minute", every)
+		}
+		return Spec{every: every}, nil
+	}
+	if macro, ok := macros[expression]; ok {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return Spec{}, fmt.Errorf("schedule %q must have five fields: minute hour day-of-month month day-of-week", expression)
+	}
+	var spec Spec
+	var err error
+	bounds := []struct {
+		name     string
+		set      *fieldSet
+		min, max int
+	}{
+		{"minute", &spec.minute, 0, 59},
+		{"hour", &spec.hour, 0, 23},
+		{"day-of-month", &spec.dom, 1, 31},
+		{"month", &spec.month, 1, 12},
+		{"day-of-week", &spec.dow, 0, 7},
+	}
+	for i, bound := range bounds {
+		if *bound.set, err = parseField(fields[i], bound.min, bound.max); err != nil {
+			return Spec{}, fmt.Errorf("invalid %s field %q: %w", bound.name, fields[i], err)
+		}
+	}
+	// Sunday is 0 or 7
+	if spec.dow[7] {
+		spec.dow[0] = true
+	}
+	spec.domAny = fields[2] == "*"
+	spec.dowAny = fields[4] == "*"
+	return spec, nil
+}
+
+// parseField parses one comma-separated cron field
+func parseField(field string, min, max int) (fieldSet, error) {
+	set := make(fieldSet)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+		low, high := min, max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return nil, fmt.Errorf("invalid value %q", lowPart)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return nil, fmt.Errorf("invalid value %q", highPart)
+				}
+			} else if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("values must be between %d and %d", min, max)
+		}
+		for value := low; value <= high; value += step {
+			set[value] = true
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t the schedule runs, or the zero time when it never does
+func (s Spec) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every).Truncate(time.Minute)
+	}
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for next.Before(limit) {
+		if !s.month[int(next.Month())] || !s.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !s.hour[next.Hour()] {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+			continue
+		}
+		if !s.minute[next.Minute()] {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day fields match t's day
+func (s Spec) matchesDay(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
@@ -0,0 +1,46 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAndNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2025, 6, 11, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"0 2 * * *", time.Date(2025, 6, 12, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 6, 11, 11, 0, 0, 0, time.UTC)},
+		{"0 6 * * 1", time.Date(2025, 6, 16, 6, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 6, 11, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2025, 6, 11, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1,20 * *", time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 20 * 5", time.Date(2025, 6, 13, 0, 0, 0, 0, time.UTC)},
+		{"@every 6h", time.Date(2025, 6, 11, 16, 30, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		spec, err := Parse(tt.expression)
+		if err != nil {
+			t.Errorf("Expected %q to parse but got %v", tt.expression, err)
+			continue
+		}
+		if next := spec.Next(from); !next.Equal(tt.expected) {
+			t.Errorf("Expected %q to run next at %v but got %v", tt.expression, tt.expected, next)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 30s", "@every soon", "@often"} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Expected %q to be rejected but it parsed", expression)
+		}
+	}
+}
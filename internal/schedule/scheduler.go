@@ -0,0 +1,351 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package schedule runs recurring actions on managed VMs, such as a nightly sync of build
+// artifacts or a weekly package upgrade
+package schedule
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+// Actions a scheduled task runs
+const (
+	// ActionExec runs the task's command in the VM
+	ActionExec = "exec"
+	// ActionAptUpgrade upgrades the VM's packages with apt-get
+	ActionAptUpgrade = "apt_upgrade"
+	// ActionSyncFromVM syncs the VM's project directory to the host
+	ActionSyncFromVM = "sync_from_vm"
+	// ActionBackup backs up guest paths as backup_vm_data does
+	ActionBackup = "backup"
+)
+
+// Actions lists the actions of scheduled tasks
+var Actions = []string{ActionExec, ActionAptUpgrade, ActionSyncFromVM, ActionBackup}
+
+// StateFile is the file under the VM base directory the server keeps scheduled tasks in
+const StateFile = ".scheduled-tasks.json"
+
+// DefaultCheckInterval is how often due tasks are looked for
+const DefaultCheckInterval = time.Minute
+
+// maxOutputBytes bounds the output of a run kept with its task
+const maxOutputBytes = 4 * 1024
+
+// GlobalScheduler is the scheduler shared by the server; it holds no tasks until Open loads
+// its state file
+var GlobalScheduler = NewScheduler(events.GlobalBus)
+
+// Task is an action run on a VM on a schedule
+type Task struct {
+	ID     string `json:"id"`
+	VMName string `json:"vm_name"`
+	Action string `json:"action"`
+	// Schedule is a cron expression in the server's local time, or "@every <duration>"
+	Schedule string `json:"schedule"`
+	// Command is the guest command of exec tasks
+	Command string `json:"command,omitempty"`
+	// Paths are the presets or guest paths of backup tasks
+	Paths []string `json:"paths,omitempty"`
+	// Keep is how many backups backup tasks keep
+	Keep      int       `json:"keep,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	NextRun   time.Time `json:"next_run"`
+	LastRun   *Run      `json:"last_run,omitempty"`
+}
+
+// Run is the outcome of a scheduled task's run
+type Run struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	// Skipped is why the task did not run, e.g. the VM was not running
+	Skipped string `json:"skipped,omitempty"`
+	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Failed reports whether the run failed
+func (r Run) Failed() bool {
+	return r.Error != ""
+}
+
+// ErrSkipped is returned by a RunFunc, wrapped with the reason, when a task cannot run now,
+// e.g. because its VM is halted
+var ErrSkipped = errors.New("skipped")
+
+// RunFunc performs a task's action and returns its output
+type RunFunc func(ctx context.Context, task Task) (string, error)
+
+// Scheduler keeps the scheduled tasks and runs those that are due
+type Scheduler struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+	// path is the state file tasks are persisted in; tasks are kept in memory only without it
+	path string
+	bus  *events.Bus
+	now  func() time.Time
+}
+
+// NewScheduler creates a scheduler without tasks that publishes task runs on bus
+func NewScheduler(bus *events.Bus) *Scheduler {
+	return &Scheduler{
+		tasks: make(map[string]*Task),
+		bus:   bus,
+		now:   time.Now,
+	}
+}
+
+// Open loads the tasks persisted in a state file and persists changes there. A missing file
+// holds no tasks.
+func (s *Scheduler) Open(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var tasks []Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return fmt.Errorf("failed to parse scheduled tasks: %w", err)
+	}
+	s.tasks = make(map[string]*Task, len(tasks))
+	for i := range tasks {
+		s.tasks[tasks[i].ID] = &tasks[i]
+	}
+	log.Info().Str("file", path).Int("tasks", len(tasks)).Msg("Loaded scheduled tasks")
+	return nil
+}
+
+// Validate checks the action, schedule and arguments of a task
+func (t Task) Validate() error {
+	if t.VMName == "" {
+		return fmt.Errorf("a task needs a VM")
+	}
+	known := false
+	for _, action := range Actions {
+		known = known || t.Action == action
+	}
+	if !known {
+		return fmt.Errorf("invalid action %q: use %s", t.Action, strings.Join(Actions, ", "))
+	}
+	if t.Action == ActionExec && strings.TrimSpace(t.Command) == "" {
+		return fmt.Errorf("exec tasks need a command")
+	}
+	if t.Action != ActionExec && t.Command != "" {
+		return fmt.Errorf("only exec tasks take a command")
+	}
+	if t.Action != ActionBackup && (len(t.Paths) > 0 || t.Keep != 0) {
+		return fmt.Errorf("only backup tasks take paths and keep")
+	}
+	if t.Keep < 0 {
+		return fmt.Errorf("keep must be positive")
+	}
+	spec, err := Parse(t.Schedule)
+	if err != nil {
+		return err
+	}
+	if spec.Next(time.Now()).IsZero() {
+		return fmt.Errorf("schedule %q never runs", t.Schedule)
+	}
+	return nil
+}
+
+// Add schedules a task, giving it an ID and its first run, and returns it
+func (s *Scheduler) Add(task Task) (Task, error) {
+	if err := task.Validate(); err != nil {
+		return Task{}, err
+	}
+	spec, _ := Parse(task.Schedule)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task.ID = newTaskID()
+	task.CreatedAt = s.now()
+	task.NextRun = spec.Next(task.CreatedAt)
+	task.LastRun = nil
+	s.tasks[task.ID] = &task
+	if err := s.save(); err != nil {
+		delete(s.tasks, task.ID)
+		return Task{}, err
+	}
+	log.Info().Str("id", task.ID).Str("name", task.VMName).Str("action", task.Action).Str("schedule", task.Schedule).Msg("Task scheduled")
+	return task, nil
+}
+
+// Remove unschedules a task and returns it
+func (s *Scheduler) Remove(id string) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return Task{}, fmt.Errorf("no scheduled task %q", id)
+	}
+	delete(s.tasks, id)
+	if err := s.save(); err != nil {
+		s.tasks[id] = task
+		return Task{}, err
+	}
+	return *task, nil
+}
+
+// Tasks returns the scheduled tasks, of one VM when vmName is set, by next run
+func (s *Scheduler) Tasks(vmName string) []Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := []Task{}
+	for _, task := range s.tasks {
+		if vmName == "" || task.VMName == vmName {
+			tasks = append(tasks, *task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].NextRun.Equal(tasks[j].NextRun) {
+			return tasks[i].NextRun.Before(tasks[j].NextRun)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// RunDue runs the tasks whose next run has come, one after the other, and returns their IDs.
+// A task that was due several times while the server was down runs once.
+func (s *Scheduler) RunDue(ctx context.Context, run RunFunc) []string {
+	now := s.now()
+	var ran []string
+	for _, task := range s.Tasks("") {
+		if task.NextRun.After(now) {
+			break
+		}
+		result := s.runTask(ctx, run, task)
+		spec, err := Parse(task.Schedule)
+		s.mu.Lock()
+		if current, ok := s.tasks[task.ID]; ok {
+			current.LastRun = &result
+			if err == nil {
+				current.NextRun = spec.Next(s.now())
+			}
+			if err := s.save(); err != nil {
+				log.Warn().Err(err).Msg("Failed to save scheduled tasks")
+			}
+		}
+		s.mu.Unlock()
+		ran = append(ran, task.ID)
+	}
+	return ran
+}
+
+// runTask runs a task and records the outcome
+func (s *Scheduler) runTask(ctx context.Context, run RunFunc, task Task) Run {
+	result := Run{StartedAt: s.now()}
+	output, err := run(ctx, task)
+	result.DurationMs = s.now().Sub(result.StartedAt).Milliseconds()
+	if len(output) > maxOutputBytes {
+		output = output[len(output)-maxOutputBytes:]
+	}
+	result.Output = output
+	switch {
+	case err == nil:
+		log.Info().Str("id", task.ID).Str("name", task.VMName).Str("action", task.Action).Msg("Scheduled task ran")
+	case errors.Is(err, ErrSkipped):
+		result.Skipped = err.Error()
+		log.Info().Str("id", task.ID).Str("name", task.VMName).Str("reason", result.Skipped).Msg("Scheduled task skipped")
+	default:
+		result.Error = err.Error()
+		log.Warn().Err(err).Str("id", task.ID).Str("name", task.VMName).Str("action", task.Action).Msg("Scheduled task failed")
+	}
+	s.publish(task, result)
+	return result
+}
+
+// publish emits the outcome of a task's run if the scheduler has a bus
+func (s *Scheduler) publish(task Task, result Run) {
+	if s.bus == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"task_id":     task.ID,
+		"action":      task.Action,
+		"schedule":    task.Schedule,
+		"duration_ms": result.DurationMs,
+	}
+	if result.Skipped != "" {
+		data["skipped"] = result.Skipped
+	}
+	if result.Error != "" {
+		data["error"] = result.Error
+	}
+	s.bus.Publish(events.TaskRan, task.VMName, data)
+}
+
+// save writes the tasks to the state file through a temporary file renamed over it; the
+// caller holds the lock
+func (s *Scheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+	tasks := make([]Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, *task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled tasks: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	partial := s.path + ".partial"
+	if err := os.WriteFile(partial, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scheduled tasks: %w", err)
+	}
+	if err := os.Rename(partial, s.path); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to save scheduled tasks: %w", err)
+	}
+	return nil
+}
+
+// Run runs the due tasks with run every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context, run RunFunc, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunDue(ctx, run)
+		}
+	}
+}
+
+// newTaskID returns a short random task identifier
+func newTaskID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
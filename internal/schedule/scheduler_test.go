@@ -0,0 +1,140 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []Task{
+		{VMName: "web", Action: ActionExec, Schedule: "@daily", Command: "make clean"},
+		{VMName: "web", Action: ActionAptUpgrade, Schedule: "0 6 * * 1"},
+		{VMName: "web", Action: ActionBackup, Schedule: "@every 12h", Paths: []string{"project"}, Keep: 3},
+	}
+	for _, task := range valid {
+		if err := task.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid but got %v", task, err)
+		}
+	}
+	invalid := []Task{
+		{Action: ActionSyncFromVM, Schedule: "@daily"},
+		{VMName: "web", Action: "reboot", Schedule: "@daily"},
+		{VMName: "web", Action: ActionExec, Schedule: "@daily"},
+		{VMName: "web", Action: ActionSyncFromVM, Schedule: "@daily", Command: "ls"},
+		{VMName: "web", Action: ActionSyncFromVM, Schedule: "@daily", Keep: 2},
+		{VMName: "web", Action: ActionSyncFromVM, Schedule: "every night"},
+		{VMName: "web", Action: ActionSyncFromVM, Schedule: "0 0 31 2 *"},
+	}
+	for _, task := range invalid {
+		if err := task.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid but it was accepted", task)
+		}
+	}
+}
+
+func TestAddRemovePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StateFile)
+	s := NewScheduler(nil)
+	if err := s.Open(path); err != nil {
+		t.Fatalf("Failed to open state: %v", err)
+	}
+	sync, err := s.Add(Task{VMName: "web", Action: ActionSyncFromVM, Schedule: "@daily"})
+	if err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	upgrade, err := s.Add(Task{VMName: "db", Action: ActionAptUpgrade, Schedule: "@weekly"})
+	if err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	if sync.ID == "" || sync.NextRun.IsZero() {
+		t.Errorf("Expected the task to get an ID and a next run but got %+v", sync)
+	}
+
+	reopened := NewScheduler(nil)
+	if err := reopened.Open(path); err != nil {
+		t.Fatalf("Failed to reopen state: %v", err)
+	}
+	if tasks := reopened.Tasks("db"); len(tasks) != 1 || tasks[0].ID != upgrade.ID {
+		t.Errorf("Expected the db task to be reloaded but got %+v", tasks)
+	}
+	if _, err := reopened.Remove(sync.ID); err != nil {
+		t.Fatalf("Failed to remove task: %v", err)
+	}
+	if _, err := reopened.Remove(sync.ID); err == nil {
+		t.Errorf("Expected removing a removed task to fail")
+	}
+
+	again := NewScheduler(nil)
+	if err := again.Open(path); err != nil {
+		t.Fatalf("Failed to reopen state: %v", err)
+	}
+	if tasks := again.Tasks(""); len(tasks) != 1 || tasks[0].ID != upgrade.ID {
+		t.Errorf("Expected only the db task to remain but got %+v", tasks)
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	now := time.Date(2025, 6, 11, 10, 30, 0, 0, time.Local)
+	bus := events.NewBus(10)
+	s := NewScheduler(bus)
+	s.now = func() time.Time { return now }
+	hourly, _ := s.Add(Task{VMName: "web", Action: ActionExec, Schedule: "@hourly", Command: "make"})
+	daily, _ := s.Add(Task{VMName: "db", Action: ActionSyncFromVM, Schedule: "@daily"})
+	halted, _ := s.Add(Task{VMName: "halted", Action: ActionAptUpgrade, Schedule: "@hourly"})
+
+	var ran []string
+	run := func(ctx context.Context, task Task) (string, error) {
+		ran = append(ran, task.ID)
+		switch task.VMName {
+		case "halted":
+			return "", fmt.Errorf("%w: VM 'halted' is stopped", ErrSkipped)
+		case "web":
+			return "build failed", errors.New("command exited with code 2")
+		}
+		return "ok", nil
+	}
+
+	if due := s.RunDue(context.Background(), run); len(due) != 0 {
+		t.Errorf("Expected no task to be due but got %v", due)
+	}
+
+	// Three hours pass while the server is down; the hourly tasks run once
+	now = now.Add(3 * time.Hour)
+	due := s.RunDue(context.Background(), run)
+	sort.Strings(due)
+	expected := []string{hourly.ID, halted.ID}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(due, expected) || len(ran) != 2 {
+		t.Errorf("Expected the hourly tasks %v to run once but got %v", expected, ran)
+	}
+	for _, task := range s.Tasks("") {
+		switch task.ID {
+		case hourly.ID:
+			if task.LastRun == nil || !task.LastRun.Failed() || task.LastRun.Output != "build failed" {
+				t.Errorf("Expected a failed run with output but got %+v", task.LastRun)
+			}
+			if want := time.Date(2025, 6, 11, 14, 0, 0, 0, time.Local); !task.NextRun.Equal(want) {
+				t.Errorf("Expected the next run at %v but got %v", want, task.NextRun)
+			}
+		case halted.ID:
+			if task.LastRun == nil || task.LastRun.Skipped == "" || task.LastRun.Failed() {
+				t.Errorf("Expected a skipped run but got %+v", task.LastRun)
+			}
+		case daily.ID:
+			if task.LastRun != nil {
+				t.Errorf("Expected the daily task not to run but got %+v", task.LastRun)
+			}
+		}
+	}
+	if published := bus.Recent(0, ""); len(published) != 2 || published[0].Type != events.TaskRan {
+		t.Errorf("Expected two %s events but got %+v", events.TaskRan, published)
+	}
+}
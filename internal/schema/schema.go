@@ -0,0 +1,337 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package schema derives JSON Schemas from the Go types tools return and checks results
+// against them
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the JSON Schema dialect of the generated schemas
+const Version = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema the generated schemas use
+type Schema struct {
+	// Type is a JSON type name, or a list of them for values that may be null
+	Type                 interface{}        `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	// OneOf lists the alternative shapes of a tool returning different results, e.g. when an
+	// operation waits for approval
+	OneOf []*Schema `json:"oneOf,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// For returns the schema of the JSON encoding of v's type. Fields tagged omitempty are
+// optional; slices, maps and pointers may also be null.
+func For(v interface{}) *Schema {
+	return forType(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+// forType returns the schema of a type; seen guards against recursive types
+func forType(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(forType(t.Elem(), seen))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return &Schema{Type: "string"}
+		}
+		s := &Schema{Type: "array", Items: forType(t.Elem(), seen)}
+		if t.Kind() == reflect.Slice {
+			return nullable(s)
+		}
+		return s
+	case reflect.Map:
+		return nullable(&Schema{Type: "object", AdditionalProperties: forType(t.Elem(), seen)})
+	case reflect.Struct:
+		if seen[t] {
+			return &Schema{Type: "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(s, t, seen)
+		sort.Strings(s.Required)
+		return s
+	}
+	// Interfaces hold any value
+	return &Schema{}
+}
+
+// addFields adds the properties of a struct's fields, flattening embedded structs as
+// encoding/json does
+func addFields(s *Schema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(s, embedded, seen)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := forType(field.Type, seen)
+		if strings.Contains(options, "string") {
+			property = &Schema{Type: "string"}
+		}
+		s.Properties[name] = property
+		if !strings.Contains(options, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// nullable allows null in place of a value of the schema
+func nullable(s *Schema) *Schema {
+	if name, ok := s.Type.(string); ok {
+		s.Type = []string{name, "null"}
+	}
+	return s
+}
+
+// types returns the JSON types a schema allows; none means any
+func (s *Schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+// Validate checks that data, a JSON document, matches the schema
+func (s *Schema) Validate(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return s.validate("$", value)
+}
+
+func (s *Schema) validate(path string, value interface{}) error {
+	if len(s.OneOf) > 0 {
+		var errs []string
+		for _, alternative := range s.OneOf {
+			err := alternative.validate(path, value)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("%s: matches none of the alternatives (%s)", path, strings.Join(errs, "; "))
+	}
+	if types := s.types(); len(types) > 0 {
+		actual := jsonType(value)
+		matched := false
+		for _, t := range types {
+			matched = matched || t == actual || (t == "number" && actual == "integer")
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s but got %s", path, strings.Join(types, " or "), actual)
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				property = s.AdditionalProperties
+			}
+			if property == nil {
+				if s.Properties != nil {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := property.validate(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonType returns the JSON type name of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// Output describes what a tool returns. Tools returning JSON have a Schema; the others
+// return plain text described by Text.
+type Output struct {
+	Tool   string  `json:"tool"`
+	Schema *Schema `json:"schema,omitempty"`
+	// Text describes the plain-text result of a tool that does not return JSON
+	Text string `json:"text,omitempty"`
+	// Attachments are the schemas of further content items some results carry, such as the
+	// results of lifecycle hooks
+	Attachments []*Schema `json:"attachments,omitempty"`
+}
+
+// Registry holds the outputs of the registered tools
+type Registry struct {
+	mu      sync.RWMutex
+	outputs map[string]Output
+}
+
+// GlobalRegistry is the registry of the server's tools
+var GlobalRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{outputs: make(map[string]Output)}
+}
+
+// Register records that a tool returns the JSON encoding of one of the types of values
+func (r *Registry) Register(tool string, values ...interface{}) {
+	var s *Schema
+	if len(values) == 1 {
+		s = For(values[0])
+	} else {
+		s = &Schema{}
+		for _, v := range values {
+			s.OneOf = append(s.OneOf, For(v))
+		}
+	}
+	s.Description = fmt.Sprintf("Result of %s", tool)
+	r.set(Output{Tool: tool, Schema: s})
+}
+
+// RegisterTextOr records that a tool returns plain text or, in some cases, the JSON encoding
+// of one of the types of values
+func (r *Registry) RegisterTextOr(tool, description string, values ...interface{}) {
+	r.Register(tool, values...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	output := r.outputs[tool]
+	output.Text = description
+	r.outputs[tool] = output
+}
+
+// RegisterText records that a tool returns plain text
+func (r *Registry) RegisterText(tool, description string) {
+	r.set(Output{Tool: tool, Text: description})
+}
+
+// Attach records that results of a tool may carry a further content item holding the JSON
+// encoding of v's type. Registering the tool again drops its attachments.
+func (r *Registry) Attach(tool string, v interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	output := r.outputs[tool]
+	output.Tool = tool
+	output.Attachments = append(output.Attachments, For(v))
+	r.outputs[tool] = output
+}
+
+func (r *Registry) set(output Output) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outputs[output.Tool] = output
+}
+
+// Lookup returns the output of a tool
+func (r *Registry) Lookup(tool string) (Output, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	output, ok := r.outputs[tool]
+	return output, ok
+}
+
+// All returns the outputs of every registered tool, sorted by tool name
+func (r *Registry) All() []Output {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	outputs := make([]Output, 0, len(r.outputs))
+	for _, output := range r.outputs {
+		outputs = append(outputs, output)
+	}
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].Tool < outputs[j].Tool })
+	return outputs
+}
@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type sampleItem struct {
+	Name string `json:"name"`
+}
+
+type sample struct {
+	ID        string            `json:"id"`
+	Count     int               `json:"count"`
+	Ratio     float64           `json:"ratio,omitempty"`
+	Items     []sampleItem      `json:"items"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Parent    *sample           `json:"parent,omitempty"`
+	internal  string
+	Skipped   string `json:"-"`
+}
+
+func TestFor(t *testing.T) {
+	s := For(sample{})
+	if s.Type != "object" {
+		t.Fatalf("Expected an object schema but got %v", s.Type)
+	}
+	expectedRequired := "count,created_at,id,items"
+	if strings.Join(s.Required, ",") != expectedRequired {
+		t.Errorf("Expected required properties %s but got %v", expectedRequired, s.Required)
+	}
+	for _, name := range []string{"internal", "Skipped"} {
+		if _, ok := s.Properties[name]; ok {
+			t.Errorf("Expected property %s to be left out", name)
+		}
+	}
+	if created := s.Properties["created_at"]; created.Type != "string" || created.Format != "date-time" {
+		t.Errorf("Expected created_at to be a date-time string but got %+v", created)
+	}
+	items := s.Properties["items"]
+	if types := items.types(); strings.Join(types, ",") != "array,null" {
+		t.Errorf("Expected items to be a nullable array but got %v", types)
+	}
+	if items.Items.Properties["name"] == nil {
+		t.Errorf("Expected the item schema to have a name property but got %+v", items.Items)
+	}
+	if parent := s.Properties["parent"]; strings.Join(parent.types(), ",") != "object,null" || parent.Properties != nil {
+		t.Errorf("Expected the recursive parent to stop at a nullable object schema but got %+v", parent)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := For(sample{})
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"valid", `{"id":"a","count":1,"items":[{"name":"x"}],"created_at":"2025-01-01T00:00:00Z"}`, ""},
+		{"null slice", `{"id":"a","count":1,"items":null,"created_at":"2025-01-01T00:00:00Z","ratio":0.5}`, ""},
+		{"missing required", `{"id":"a","items":[],"created_at":"2025-01-01T00:00:00Z"}`, `$: missing required property "count"`},
+		{"wrong type", `{"id":"a","count":1.5,"items":[],"created_at":"2025-01-01T00:00:00Z"}`, "$.count: expected integer but got number"},
+		{"nested", `{"id":"a","count":1,"items":[{"name":3}],"created_at":"2025-01-01T00:00:00Z"}`, "$.items[0].name: expected string but got integer"},
+		{"unexpected property", `{"id":"a","count":1,"items":[],"created_at":"2025-01-01T00:00:00Z","extra":true}`, `$: unexpected property "extra"`},
+		{"map values", `{"id":"a","count":1,"items":[],"created_at":"2025-01-01T00:00:00Z","labels":{"env":1}}`, "$.labels.env: expected string but got integer"},
+		{"invalid JSON", `{`, "invalid JSON"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.Validate([]byte(tc.data))
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("Expected no error but got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q but got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("get_item", sampleItem{})
+	registry.Register("get_either", sampleItem{}, sample{})
+	registry.RegisterText("say_hello", "A greeting")
+	registry.RegisterTextOr("maybe_item", "A message", sampleItem{})
+	registry.Attach("say_hello", sampleItem{})
+
+	item, ok := registry.Lookup("get_item")
+	if !ok || item.Schema.Description != "Result of get_item" {
+		t.Fatalf("Expected get_item to be described but got %+v", item)
+	}
+	either, _ := registry.Lookup("get_either")
+	if len(either.Schema.OneOf) != 2 {
+		t.Fatalf("Expected get_either to have 2 alternatives but got %d", len(either.Schema.OneOf))
+	}
+	if err := either.Schema.Validate([]byte(`{"name":"x"}`)); err != nil {
+		t.Errorf("Expected the first alternative to match but got %v", err)
+	}
+	if err := either.Schema.Validate([]byte(`{"other":"x"}`)); err == nil || !strings.Contains(err.Error(), "matches none of the alternatives") {
+		t.Errorf("Expected no alternative to match but got %v", err)
+	}
+	hello, _ := registry.Lookup("say_hello")
+	if hello.Schema != nil || hello.Text != "A greeting" || len(hello.Attachments) != 1 {
+		t.Errorf("Expected say_hello to return text with an attachment but got %+v", hello)
+	}
+	maybe, _ := registry.Lookup("maybe_item")
+	if maybe.Schema == nil || maybe.Text != "A message" {
+		t.Errorf("Expected maybe_item to return text or JSON but got %+v", maybe)
+	}
+
+	registry.RegisterText("say_hello", "Another greeting")
+	if hello, _ = registry.Lookup("say_hello"); len(hello.Attachments) != 0 {
+		t.Errorf("Expected registering again to drop attachments but got %d", len(hello.Attachments))
+	}
+	if _, ok := registry.Lookup("unknown"); ok {
+		t.Error("Expected unknown tools not to be found")
+	}
+
+	var tools []string
+	for _, output := range registry.All() {
+		tools = append(tools, output.Tool)
+	}
+	expected := "get_either,get_item,maybe_item,say_hello"
+	if strings.Join(tools, ",") != expected {
+		t.Errorf("Expected outputs %s but got %v", expected, tools)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package secrets keeps secrets for VMs in memory and installs them into the guest's tmpfs
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// Secret kinds
+const (
+	// KindEnv is exported as an environment variable to commands run in the VM
+	KindEnv = "env"
+	// KindFile is written to a file in the VM
+	KindFile = "file"
+)
+
+// Guest locations; /dev/shm is a tmpfs so secrets never reach the VM's disk
+const (
+	// GuestDir holds every secret installed in a VM
+	GuestDir = "/dev/shm/vagrant-mcp"
+	// GuestEnvFile holds the environment variable secrets as shell assignments
+	GuestEnvFile = GuestDir + "/secrets.env"
+	// GuestFilesDir holds the file secrets
+	GuestFilesDir = GuestDir + "/files"
+)
+
+var (
+	// envNamePattern restricts environment variable names
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// fileNamePattern restricts file secret names, which are also their tmpfs file names
+	fileNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// Secret is a value injected into a VM
+type Secret struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Value string `json:"-"`
+	// Path is where a file secret is linked in the guest; empty keeps it in GuestFilesDir only
+	Path      string    `json:"path,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GuestPath returns where the secret is readable in the guest
+func (s Secret) GuestPath() string {
+	if s.Kind == KindEnv {
+		return GuestEnvFile
+	}
+	if s.Path != "" {
+		return s.Path
+	}
+	return GuestFilesDir + "/" + s.Name
+}
+
+// Validate checks a secret's kind, name and path
+func (s Secret) Validate() error {
+	switch s.Kind {
+	case KindEnv:
+		if !envNamePattern.MatchString(s.Name) {
+			return fmt.Errorf("invalid environment variable name '%s'", s.Name)
+		}
+		if s.Path != "" {
+			return fmt.Errorf("path is only supported for file secrets")
+		}
+	case KindFile:
+		if !fileNamePattern.MatchString(s.Name) || s.Name == "." || s.Name == ".." {
+			return fmt.Errorf("invalid file secret name '%s' (use letters, digits, '.', '_' and '-')", s.Name)
+		}
+		if s.Path != "" && (!path.IsAbs(s.Path) || path.Clean(s.Path) != s.Path || s.Path == "/") {
+			return fmt.Errorf("invalid path '%s' (must be a clean absolute guest path)", s.Path)
+		}
+	default:
+		return fmt.Errorf("invalid secret kind '%s' (must be %s or %s)", s.Kind, KindEnv, KindFile)
+	}
+	return nil
+}
+
+// Store keeps secrets per VM in memory only
+type Store struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]Secret
+}
+
+// Global secret store instance
+var GlobalStore = NewStore()
+
+// NewStore creates an empty secret store
+func NewStore() *Store {
+	return &Store{secrets: make(map[string]map[string]Secret)}
+}
+
+// key identifies a secret within a VM; env and file secrets may share a name
+func key(kind, name string) string {
+	return kind + ":" + name
+}
+
+// Set adds or replaces a secret for a VM
+func (s *Store) Set(vmName string, secret Secret) (Secret, error) {
+	if err := secret.Validate(); err != nil {
+		return Secret{}, err
+	}
+	secret.UpdatedAt = time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.secrets[vmName] == nil {
+		s.secrets[vmName] = make(map[string]Secret)
+	}
+	s.secrets[vmName][key(secret.Kind, secret.Name)] = secret
+	return secret, nil
+}
+
+// Delete removes a secret and returns it
+func (s *Store) Delete(vmName, kind, name string) (Secret, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret, exists := s.secrets[vmName][key(kind, name)]
+	if !exists {
+		return Secret{}, false
+	}
+	delete(s.secrets[vmName], key(kind, name))
+	if len(s.secrets[vmName]) == 0 {
+		delete(s.secrets, vmName)
+	}
+	return secret, true
+}
+
+// DeleteVM removes every secret of a VM
+func (s *Store) DeleteVM(vmName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, vmName)
+}
+
+// Get returns a VM's secrets, sorted by kind and name
+func (s *Store) Get(vmName string) []Secret {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets := make([]Secret, 0, len(s.secrets[vmName]))
+	for _, secret := range s.secrets[vmName] {
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return key(secrets[i].Kind, secrets[i].Name) < key(secrets[j].Kind, secrets[j].Name)
+	})
+	return secrets
+}
+
+// RenderEnvFile renders the environment variable secrets as shell assignments
+func RenderEnvFile(secrets []Secret) string {
+	var b strings.Builder
+	for _, secret := range secrets {
+		if secret.Kind == KindEnv {
+			fmt.Fprintf(&b, "%s=%s\n", secret.Name, shellquote.Quote(secret.Value))
+		}
+	}
+	return b.String()
+}
+
+// InstallScript returns the shell script that replaces the secrets in the guest with secrets and
+// removes the links of removedPaths. The script carries the values, so it must be sent over
+// stdin rather than as a command line argument.
+func InstallScript(secrets []Secret, removedPaths []string) string {
+	var b strings.Builder
+	b.WriteString("set -e\numask 077\n")
+	for _, p := range removedPaths {
+		fmt.Fprintf(&b, "if [ -L %[1]s ]; then rm -f %[1]s; fi\n", shellquote.Quote(p))
+	}
+	fmt.Fprintf(&b, "rm -rf %s\nmkdir -p %s\n", GuestDir, GuestFilesDir)
+	writeFile(&b, GuestEnvFile, RenderEnvFile(secrets))
+	for _, secret := range secrets {
+		if secret.Kind != KindFile {
+			continue
+		}
+		file := GuestFilesDir + "/" + secret.Name
+		writeFile(&b, file, secret.Value)
+		if secret.Path != "" {
+			fmt.Fprintf(&b, "mkdir -p %s\nln -sfn %s %s\n", shellquote.Quote(path.Dir(secret.Path)), file, shellquote.Quote(secret.Path))
+		}
+	}
+	return b.String()
+}
+
+// SourceCommand prefixes command so that it runs with the environment variable secrets exported
+func SourceCommand(command string) string {
+	return fmt.Sprintf("if [ -r %[1]s ]; then set -a; . %[1]s; set +a; fi; %[2]s", GuestEnvFile, command)
+}
+
+// Install writes secrets into the guest's tmpfs over ssh; sshArgs connect to the VM and end
+// with user@host
+func Install(ctx context.Context, vmName string, sshArgs []string, secrets []Secret, removedPaths []string) error {
+	var stderr bytes.Buffer
+	install := cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: "sh -s", Stdin: strings.NewReader(InstallScript(secrets, removedPaths)), Stderr: &stderr}
+	if err := cmdexec.RunSSH(ctx, install); err != nil {
+		return fmt.Errorf("failed to install secrets: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	log.Info().Str("vm", vmName).Int("count", len(secrets)).Msg("Secrets installed")
+	return nil
+}
+
+// writeFile appends script lines that write content to file without it appearing in a process list
+func writeFile(b *strings.Builder, file, content string) {
+	fmt.Fprintf(b, "printf '%%s' '%s' | base64 -d > %s\n", base64.StdEncoding.EncodeToString([]byte(content)), file)
+}
@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSecretValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		secret    Secret
+		expectErr bool
+	}{
+		{"env variable", Secret{Name: "API_TOKEN", Kind: KindEnv}, false},
+		{"file with path", Secret{Name: "credentials", Kind: KindFile, Path: "/home/vagrant/.aws/credentials"}, false},
+		{"file without path", Secret{Name: "app.env", Kind: KindFile}, false},
+		{"invalid env name", Secret{Name: "API-TOKEN", Kind: KindEnv}, true},
+		{"env with path", Secret{Name: "TOKEN", Kind: KindEnv, Path: "/tmp/token"}, true},
+		{"file name with slash", Secret{Name: "../passwd", Kind: KindFile}, true},
+		{"relative path", Secret{Name: "env", Kind: KindFile, Path: ".env"}, true},
+		{"unclean path", Secret{Name: "env", Kind: KindFile, Path: "/vagrant/../etc/env"}, true},
+		{"unknown kind", Secret{Name: "TOKEN", Kind: "registry"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.secret.Validate(); tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore()
+	if _, err := store.Set("dev", Secret{Name: "TOKEN", Kind: KindEnv, Value: "first"}); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, err := store.Set("dev", Secret{Name: "TOKEN", Kind: KindEnv, Value: "second"}); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, err := store.Set("dev", Secret{Name: "TOKEN", Kind: KindFile, Value: "file"}); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, err := store.Set("dev", Secret{Name: "bad name", Kind: KindEnv}); err == nil {
+		t.Errorf("Expected an error for an invalid secret")
+	}
+
+	list := store.Get("dev")
+	if len(list) != 2 || list[0].Value != "second" || list[1].Kind != KindFile {
+		t.Errorf("Expected the replaced env secret and the file secret but got %+v", list)
+	}
+	if len(store.Get("other")) != 0 {
+		t.Errorf("Expected no secrets for another VM")
+	}
+
+	// Values never appear in JSON responses
+	data, _ := json.Marshal(list)
+	if strings.Contains(string(data), "second") {
+		t.Errorf("Expected the value to be omitted from JSON but got %s", data)
+	}
+
+	if _, ok := store.Delete("dev", KindEnv, "TOKEN"); !ok {
+		t.Errorf("Expected the env secret to be deleted")
+	}
+	if _, ok := store.Delete("dev", KindEnv, "TOKEN"); ok {
+		t.Errorf("Expected deleting a missing secret to fail")
+	}
+	store.DeleteVM("dev")
+	if len(store.Get("dev")) != 0 {
+		t.Errorf("Expected no secrets after DeleteVM")
+	}
+}
+
+func TestInstallScript(t *testing.T) {
+	list := []Secret{
+		{Name: "TOKEN", Kind: KindEnv, Value: "it's secret"},
+		{Name: "dotenv", Kind: KindFile, Value: "DB_PASSWORD=hunter2\n", Path: "/vagrant/.env"},
+	}
+	if env := RenderEnvFile(list); env != "TOKEN='it'\\''s secret'\n" {
+		t.Errorf("Expected a quoted assignment but got %q", env)
+	}
+
+	script := InstallScript(list, []string{"/vagrant/old.env"})
+	expected := []string{
+		"umask 077",
+		"if [ -L '/vagrant/old.env' ]; then rm -f '/vagrant/old.env'; fi",
+		"| base64 -d > " + GuestEnvFile,
+		"| base64 -d > " + GuestFilesDir + "/dotenv",
+		"ln -sfn " + GuestFilesDir + "/dotenv '/vagrant/.env'",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(script, fragment) {
+			t.Errorf("Expected install script to contain %q but got:\n%s", fragment, script)
+		}
+	}
+	if strings.Contains(script, "hunter2") || strings.Contains(script, "it's secret") {
+		t.Errorf("Expected values to be encoded in the install script but got:\n%s", script)
+	}
+}
+
+func TestSourceCommand(t *testing.T) {
+	command := SourceCommand("make test")
+	if !strings.HasPrefix(command, "if [ -r "+GuestEnvFile+" ]; then set -a; . "+GuestEnvFile+"; set +a; fi; ") || !strings.HasSuffix(command, "; make test") {
+		t.Errorf("Expected the env file to be sourced before the command but got %q", command)
+	}
+}
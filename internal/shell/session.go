@@ -0,0 +1,378 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package shell keeps persistent interactive shell sessions in VMs
+package shell
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Session limits
+const (
+	// DefaultMaxSessions is the most sessions that may be open at once
+	DefaultMaxSessions = 10
+	// DefaultIdleTimeout closes sessions that have not been used for this long
+	DefaultIdleTimeout = 30 * time.Minute
+	// maxBufferedOutput bounds the unread output kept per session
+	maxBufferedOutput = 1 << 20
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown or closed session IDs
+	ErrSessionNotFound = errors.New("shell session not found")
+	// ErrTooManySessions is returned when the session limit is reached
+	ErrTooManySessions = errors.New("too many open shell sessions")
+	// ErrSessionClosed is returned when writing to a session whose shell has exited
+	ErrSessionClosed = errors.New("shell session has exited")
+)
+
+// ansiPattern matches terminal escape sequences
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07]*\x07|\x1b[()][A-Za-z0-9]|\x1b[=>]`)
+
+// SessionInfo describes an open session
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	VMName     string    `json:"vm_name"`
+	OpenedAt   time.Time `json:"opened_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Exited     bool      `json:"exited"`
+}
+
+// Output is the result of reading a session
+type Output struct {
+	Output string `json:"output"`
+	// Dropped is the number of bytes discarded because the unread output exceeded the buffer
+	Dropped  int  `json:"dropped_bytes,omitempty"`
+	Exited   bool `json:"exited"`
+	ExitCode int  `json:"exit_code,omitempty"`
+}
+
+// Session is a persistent shell process
+type Session struct {
+	info    SessionInfo
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	mu      sync.Mutex
+	buffer  []byte
+	dropped int
+	exitErr error
+	notify  chan struct{}
+	done    chan struct{}
+}
+
+// Manager tracks open shell sessions
+type Manager struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	maxSessions int
+	idleTimeout time.Duration
+}
+
+// Global shell session manager instance
+var GlobalManager = NewManager(DefaultMaxSessions, DefaultIdleTimeout)
+
+// NewManager creates a shell session manager
+func NewManager(maxSessions int, idleTimeout time.Duration) *Manager {
+	return &Manager{
+		sessions:    make(map[string]*Session),
+		maxSessions: maxSessions,
+		idleTimeout: idleTimeout,
+	}
+}
+
+// Open starts a shell over ssh with a remote PTY; sshArgs connect to the VM and end with user@host
+func (m *Manager) Open(vmName string, sshArgs []string) (SessionInfo, error) {
+	args := append([]string{"-tt", "-o", "ServerAliveInterval=30"}, sshArgs...)
+	cmd := exec.Command("ssh", args...)
+	// A dumb terminal keeps prompts and editing free of most escape sequences
+	cmd.Env = append(os.Environ(), "TERM=dumb")
+	return m.start(vmName, cmd)
+}
+
+// start runs cmd as a new session
+func (m *Manager) start(vmName string, cmd *exec.Cmd) (SessionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closeIdleLocked()
+	if len(m.sessions) >= m.maxSessions {
+		return SessionInfo{}, ErrTooManySessions
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return SessionInfo{}, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return SessionInfo{}, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	now := time.Now()
+	session := &Session{
+		info: SessionInfo{
+			ID:         newSessionID(),
+			VMName:     vmName,
+			OpenedAt:   now,
+			LastUsedAt: now,
+		},
+		cmd:    cmd,
+		stdin:  stdin,
+		notify: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go session.collect(reader)
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		session.mu.Lock()
+		session.exitErr = err
+		session.info.Exited = true
+		session.mu.Unlock()
+		close(session.done)
+		session.signal()
+	}()
+
+	m.sessions[session.info.ID] = session
+	log.Info().Str("session", session.info.ID).Str("vm", vmName).Msg("Shell session opened")
+	return session.info, nil
+}
+
+// Send writes input to a session's shell
+func (m *Manager) Send(id string, input string) error {
+	session, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-session.done:
+		return ErrSessionClosed
+	default:
+	}
+	if _, err := io.WriteString(session.stdin, input); err != nil {
+		return fmt.Errorf("failed to write to shell: %w", err)
+	}
+	return nil
+}
+
+// Read returns the output produced since the last read, waiting up to wait for some to arrive
+func (m *Manager) Read(ctx context.Context, id string, wait time.Duration, stripANSI bool) (Output, error) {
+	session, err := m.get(id)
+	if err != nil {
+		return Output{}, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		session.mu.Lock()
+		ready := len(session.buffer) > 0 || session.info.Exited
+		notify := session.notify
+		session.mu.Unlock()
+		if ready || wait <= 0 {
+			break
+		}
+		select {
+		case <-notify:
+			continue
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		break
+	}
+
+	// Give output that is still arriving a moment to settle, so commands are not split mid-line
+	settle := time.NewTimer(100 * time.Millisecond)
+	defer settle.Stop()
+	for {
+		session.mu.Lock()
+		notify := session.notify
+		exited := session.info.Exited
+		session.mu.Unlock()
+		if exited {
+			break
+		}
+		select {
+		case <-notify:
+			settle.Reset(100 * time.Millisecond)
+			continue
+		case <-settle.C:
+		case <-ctx.Done():
+		}
+		break
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	output := Output{
+		Output:  string(session.buffer),
+		Dropped: session.dropped,
+		Exited:  session.info.Exited,
+	}
+	if output.Exited {
+		output.ExitCode = exitCode(session.exitErr)
+	}
+	session.buffer = nil
+	session.dropped = 0
+	if stripANSI {
+		output.Output = cleanTerminalOutput(output.Output)
+	}
+	return output, nil
+}
+
+// Close ends a session
+func (m *Manager) Close(id string) error {
+	m.mu.Lock()
+	session, exists := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	if !exists {
+		return ErrSessionNotFound
+	}
+	session.terminate()
+	log.Info().Str("session", id).Str("vm", session.info.VMName).Msg("Shell session closed")
+	return nil
+}
+
+// Info returns the description of an open session
+func (m *Manager) Info(id string) (SessionInfo, error) {
+	session, err := m.get(id)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.info, nil
+}
+
+// List returns the open sessions
+func (m *Manager) List() []SessionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closeIdleLocked()
+
+	infos := make([]SessionInfo, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		session.mu.Lock()
+		infos = append(infos, session.info)
+		session.mu.Unlock()
+	}
+	return infos
+}
+
+// get returns a session and marks it as used
+func (m *Manager) get(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closeIdleLocked()
+
+	session, exists := m.sessions[id]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	session.mu.Lock()
+	session.info.LastUsedAt = time.Now()
+	session.mu.Unlock()
+	return session, nil
+}
+
+// closeIdleLocked closes sessions idle for longer than the idle timeout; the caller must hold m.mu
+func (m *Manager) closeIdleLocked() {
+	if m.idleTimeout <= 0 {
+		return
+	}
+	for id, session := range m.sessions {
+		session.mu.Lock()
+		idle := time.Since(session.info.LastUsedAt) > m.idleTimeout
+		session.mu.Unlock()
+		if idle {
+			delete(m.sessions, id)
+			go session.terminate()
+			log.Info().Str("session", id).Msg("Closed idle shell session")
+		}
+	}
+}
+
+// collect appends shell output to the session buffer
+func (s *Session) collect(r io.Reader) {
+	chunk := make([]byte, 4096)
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			s.mu.Lock()
+			s.buffer = append(s.buffer, chunk[:n]...)
+			if excess := len(s.buffer) - maxBufferedOutput; excess > 0 {
+				s.buffer = s.buffer[excess:]
+				s.dropped += excess
+			}
+			s.mu.Unlock()
+			s.signal()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// signal wakes up readers waiting for output
+func (s *Session) signal() {
+	s.mu.Lock()
+	close(s.notify)
+	s.notify = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// terminate asks the shell to exit and kills it if it does not
+func (s *Session) terminate() {
+	s.stdin.Close()
+	select {
+	case <-s.done:
+	case <-time.After(2 * time.Second):
+		if s.cmd.Process != nil {
+			_ = s.cmd.Process.Kill()
+		}
+	}
+}
+
+// cleanTerminalOutput removes escape sequences and carriage returns from PTY output
+func cleanTerminalOutput(output string) string {
+	output = ansiPattern.ReplaceAllString(output, "")
+	return strings.ReplaceAll(output, "\r", "")
+}
+
+// exitCode extracts a process exit code from a Wait error
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// newSessionID returns a random session identifier
+func newSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
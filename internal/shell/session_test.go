@@ -0,0 +1,86 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionSendAndRead(t *testing.T) {
+	manager := NewManager(2, time.Minute)
+	info, err := manager.start("dev", exec.Command("sh"))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if err := manager.Send(info.ID, "cd /tmp && export GREETING=hi\n"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if err := manager.Send(info.ID, "echo \"$GREETING from $(pwd)\"\n"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	output, err := manager.Read(context.Background(), info.ID, 2*time.Second, true)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !strings.Contains(output.Output, "hi from /tmp") {
+		t.Errorf("Expected shell state to persist between inputs but got %q", output.Output)
+	}
+
+	if err := manager.Send(info.ID, "exit 3\n"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	output, err = manager.Read(context.Background(), info.ID, 2*time.Second, true)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !output.Exited || output.ExitCode != 3 {
+		t.Errorf("Expected the shell to exit with code 3 but got %+v", output)
+	}
+	if err := manager.Send(info.ID, "echo again\n"); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Expected ErrSessionClosed but got %v", err)
+	}
+
+	if err := manager.Close(info.ID); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+	if _, err := manager.Read(context.Background(), info.ID, 0, true); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound but got %v", err)
+	}
+}
+
+func TestSessionLimit(t *testing.T) {
+	manager := NewManager(1, time.Minute)
+	info, err := manager.start("dev", exec.Command("sh"))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	if _, err := manager.start("dev", exec.Command("sh")); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("Expected ErrTooManySessions but got %v", err)
+	}
+}
+
+func TestCleanTerminalOutput(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "colors", input: "\x1b[01;32mvagrant@dev\x1b[00m:~$ ", expected: "vagrant@dev:~$ "},
+		{name: "carriage returns", input: "line one\r\nline two\r\n", expected: "line one\nline two\n"},
+		{name: "window title", input: "\x1b]0;vagrant@dev: ~\x07$ ", expected: "$ "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cleanTerminalOutput(tc.input); got != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, got)
+			}
+		})
+	}
+}
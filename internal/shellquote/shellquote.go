@@ -0,0 +1,12 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package shellquote quotes values for the POSIX shell commands run in VMs
+package shellquote
+
+import "strings"
+
+// Quote quotes s as a single shell word, so the shell passes it on unchanged
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,32 @@
+package shellquote
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{"", "''"},
+		{"plain", "'plain'"},
+		{"two words", "'two words'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME `id` \"x\" \\", "'$HOME `id` \"x\" \\'"},
+	}
+	for _, tc := range testCases {
+		if quoted := Quote(tc.value); quoted != tc.expected {
+			t.Errorf("Expected %s but got %s", tc.expected, quoted)
+		}
+		// The shell passes the quoted value on unchanged
+		output, err := exec.Command("sh", "-c", "printf %s "+Quote(tc.value)).Output()
+		if err != nil {
+			t.Fatalf("Failed to run sh: %v", err)
+		}
+		if string(output) != tc.value {
+			t.Errorf("Expected the shell to print %q but got %q", tc.value, output)
+		}
+	}
+}
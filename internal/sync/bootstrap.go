@@ -0,0 +1,206 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/project"
+)
+
+// Project helper files written by BootstrapProjectFiles
+const (
+	ProjectFileGitAttributes = ".gitattributes"
+	ProjectFileEditorConfig  = ".editorconfig"
+	ProjectFileConfig        = project.ManifestFile
+)
+
+// ProjectFiles lists the project helper files in the order they are written
+var ProjectFiles = []string{ProjectFileGitAttributes, ProjectFileEditorConfig, ProjectFileConfig}
+
+// Project file write outcomes
+const (
+	ProjectFileWritten     = "written"
+	ProjectFileOverwritten = "overwritten"
+	ProjectFileSkipped     = "skipped"
+)
+
+// ProjectFileResult reports what happened to one project helper file
+type ProjectFileResult struct {
+	File    string `json:"file"`
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Content string `json:"content,omitempty"`
+}
+
+// binaryPatterns are marked binary in .gitattributes so line endings are never converted
+var binaryPatterns = []string{
+	"*.png", "*.jpg", "*.jpeg", "*.gif", "*.ico", "*.webp", "*.pdf",
+	"*.zip", "*.gz", "*.tgz", "*.jar", "*.woff", "*.woff2", "*.ttf", "*.eot",
+	"*.so", "*.dylib", "*.dll", "*.exe",
+}
+
+// BootstrapProjectFiles writes the requested helper files into projectPath. Existing
+// files are left alone unless overwrite is set; with dryRun nothing is written and the
+// generated content is returned instead.
+func BootstrapProjectFiles(projectPath string, files []string, vmConfig core.VMConfig, syncConfig core.SyncConfig, overwrite, dryRun bool) ([]ProjectFileResult, error) {
+	info, err := os.Stat(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, ErrInvalidProjectPath
+	}
+	if len(files) == 0 {
+		files = ProjectFiles
+	}
+
+	results := make([]ProjectFileResult, 0, len(files))
+	for _, file := range files {
+		var content string
+		switch file {
+		case ProjectFileGitAttributes:
+			content = GenerateGitAttributes(syncConfig)
+		case ProjectFileEditorConfig:
+			content = GenerateEditorConfig()
+		case ProjectFileConfig:
+			content = GenerateProjectConfig(vmConfig, syncConfig)
+		default:
+			return nil, fmt.Errorf("unknown project file '%s' (must be one of %s)", file, strings.Join(ProjectFiles, ", "))
+		}
+
+		result := ProjectFileResult{File: file, Path: filepath.Join(projectPath, file), Status: ProjectFileWritten}
+		if _, err := os.Stat(result.Path); err == nil {
+			if !overwrite {
+				result.Status = ProjectFileSkipped
+				results = append(results, result)
+				continue
+			}
+			result.Status = ProjectFileOverwritten
+		}
+		if dryRun {
+			result.Content = content
+		} else if err := os.WriteFile(result.Path, []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// GenerateGitAttributes returns a .gitattributes that checks text files out with LF endings.
+// The sync engine copies files byte for byte into a Linux guest, so CRLF files checked out
+// on a Windows host would otherwise break shell scripts and show up as changes in the VM.
+func GenerateGitAttributes(syncConfig core.SyncConfig) string {
+	var b strings.Builder
+	b.WriteString("# Generated by Vagrant MCP Server\n")
+	fmt.Fprintf(&b, "# Files are synced to the VM unchanged (%s), so text files use LF on every host\n", syncMethodName(syncConfig.Method))
+	b.WriteString("* text=auto eol=lf\n\n")
+	b.WriteString("# Windows-only scripts keep CRLF\n")
+	for _, pattern := range []string{"*.bat", "*.cmd", "*.ps1"} {
+		fmt.Fprintf(&b, "%s text eol=crlf\n", pattern)
+	}
+	b.WriteString("\n# Binary files are never converted\n")
+	for _, pattern := range binaryPatterns {
+		fmt.Fprintf(&b, "%s binary\n", pattern)
+	}
+	return b.String()
+}
+
+// GenerateEditorConfig returns an .editorconfig matching the LF line ending policy
+func GenerateEditorConfig() string {
+	return `# Generated by Vagrant MCP Server
+root = true
+
+[*]
+charset = utf-8
+end_of_line = lf
+insert_final_newline = true
+trim_trailing_whitespace = true
+indent_style = space
+indent_size = 2
+
+[*.{bat,cmd,ps1}]
+end_of_line = crlf
+
+[{Makefile,*.mk,*.go}]
+indent_style = tab
+
+[*.py]
+indent_size = 4
+
+[*.md]
+trim_trailing_whitespace = false
+`
+}
+
+// GenerateProjectConfig returns a .vagrant-mcp.yaml skeleton describing the VM and its sync settings
+func GenerateProjectConfig(vmConfig core.VMConfig, syncConfig core.SyncConfig) string {
+	var b strings.Builder
+	b.WriteString("# Generated by Vagrant MCP Server\n")
+	b.WriteString("# Describes the development VM for this project\n")
+	fmt.Fprintf(&b, "name: %s\n", yamlString(vmConfig.Name))
+	fmt.Fprintf(&b, "box: %s\n", yamlString(vmConfig.Box))
+	fmt.Fprintf(&b, "cpu: %d\n", vmConfig.CPU)
+	fmt.Fprintf(&b, "memory: %d\n", vmConfig.Memory)
+
+	b.WriteString("ports:")
+	if len(vmConfig.Ports) == 0 {
+		b.WriteString(" []\n")
+	} else {
+		b.WriteString("\n")
+		for _, port := range vmConfig.Ports {
+			fmt.Fprintf(&b, "  - guest: %d\n    host: %d\n", port.Guest, port.Host)
+		}
+	}
+
+	excludes := syncConfig.ExcludePatterns
+	if len(excludes) == 0 {
+		excludes = vmConfig.SyncExcludePatterns
+	}
+	b.WriteString("sync:\n")
+	fmt.Fprintf(&b, "  method: %s\n", yamlString(syncMethodName(syncConfig.Method)))
+	b.WriteString("  line_endings: lf\n")
+	if syncConfig.ConflictPolicy != "" {
+		fmt.Fprintf(&b, "  conflict_policy: %s\n", yamlString(syncConfig.ConflictPolicy))
+	}
+	writeYAMLList(&b, "  ", "exclude", excludes)
+	if len(syncConfig.ConflictPolicyOverrides) > 0 {
+		b.WriteString("  conflict_policy_overrides:\n")
+		for _, override := range syncConfig.ConflictPolicyOverrides {
+			fmt.Fprintf(&b, "    - pattern: %s\n      policy: %s\n", yamlString(override.Pattern), yamlString(override.Policy))
+		}
+	}
+	return b.String()
+}
+
+// syncMethodName returns the sync method, defaulting to rsync like the generated Vagrantfile
+func syncMethodName(method core.SyncMethod) string {
+	if method == "" {
+		return string(core.SyncMethodRsync)
+	}
+	return string(method)
+}
+
+// writeYAMLList writes a YAML list of strings under key
+func writeYAMLList(b *strings.Builder, indent, key string, values []string) {
+	if len(values) == 0 {
+		fmt.Fprintf(b, "%s%s: []\n", indent, key)
+		return
+	}
+	fmt.Fprintf(b, "%s%s:\n", indent, key)
+	for _, value := range values {
+		fmt.Fprintf(b, "%s  - %s\n", indent, yamlString(value))
+	}
+}
+
+// yamlString returns s as a double-quoted YAML scalar
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/project"
+)
+
+func TestBootstrapProjectFiles(t *testing.T) {
+	projectPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectPath, ProjectFileEditorConfig), []byte("root = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vmConfig := core.VMConfig{
+		Name:   "web",
+		Box:    "ubuntu/focal64",
+		CPU:    2,
+		Memory: 2048,
+		Ports:  []core.Port{{Guest: 3000, Host: 3000}},
+	}
+	syncConfig := core.SyncConfig{
+		Method:          core.SyncMethodNFS,
+		ExcludePatterns: []string{"node_modules", "*.log"},
+		ConflictPolicy:  "prefer_host",
+	}
+
+	results, err := BootstrapProjectFiles(projectPath, nil, vmConfig, syncConfig, false, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, result := range results {
+		statuses[result.File] = result.Status
+	}
+	expected := map[string]string{
+		ProjectFileGitAttributes: ProjectFileWritten,
+		ProjectFileEditorConfig:  ProjectFileSkipped,
+		ProjectFileConfig:        ProjectFileWritten,
+	}
+	for file, status := range expected {
+		if statuses[file] != status {
+			t.Errorf("Expected %s to be %s but got %s", file, status, statuses[file])
+		}
+	}
+
+	attributes, err := os.ReadFile(filepath.Join(projectPath, ProjectFileGitAttributes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(attributes), "* text=auto eol=lf") || !strings.Contains(string(attributes), "(nfs)") {
+		t.Errorf("Unexpected .gitattributes:\n%s", attributes)
+	}
+	config, err := os.ReadFile(filepath.Join(projectPath, ProjectFileConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range []string{`box: "ubuntu/focal64"`, "  - guest: 3000\n    host: 3000", `method: "nfs"`, `    - "node_modules"`, `conflict_policy: "prefer_host"`} {
+		if !strings.Contains(string(config), fragment) {
+			t.Errorf("Expected .vagrant-mcp.yaml to contain %q but got:\n%s", fragment, config)
+		}
+	}
+	// ensure_dev_vm reads the skeleton back as the project manifest
+	manifest, err := project.LoadManifest(projectPath)
+	if err != nil || manifest == nil || manifest.Name != "web" || manifest.Sync.Method != "nfs" || len(manifest.Sync.Exclude) != 2 {
+		t.Errorf("Expected the skeleton to load as a manifest but got %+v (%v)", manifest, err)
+	}
+
+	results, err = BootstrapProjectFiles(projectPath, []string{ProjectFileEditorConfig}, vmConfig, syncConfig, true, true)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if len(results) != 1 || results[0].Status != ProjectFileOverwritten || !strings.Contains(results[0].Content, "end_of_line = lf") {
+		t.Errorf("Expected a dry-run overwrite of .editorconfig but got %+v", results)
+	}
+	if content, _ := os.ReadFile(filepath.Join(projectPath, ProjectFileEditorConfig)); string(content) != "root = true\n" {
+		t.Errorf("Expected dry run to leave .editorconfig unchanged but got %q", content)
+	}
+
+	if _, err := BootstrapProjectFiles(projectPath, []string{"README.md"}, vmConfig, syncConfig, false, false); err == nil {
+		t.Error("Expected an error for an unknown project file")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ResolutionUseMerged resolves a conflict with merged content supplied by the caller
+const ResolutionUseMerged = "use_merged"
+
+// maxConflictDiffBytes bounds the diff returned for a conflict
+const maxConflictDiffBytes = 64 << 10
+
+// ConflictDetail is a queued conflict with the differences between its host and VM versions,
+// for reviewing it before choosing a resolution
+type ConflictDetail struct {
+	SyncConflict
+	// RelativePath is the path of the file relative to the project
+	RelativePath string `json:"relative_path"`
+	// Policy is the conflict policy that applies to the file
+	Policy ConflictPolicy `json:"policy"`
+	// HasBase is set when the version of the last sync is kept, so a merge has a common base
+	HasBase bool `json:"has_base"`
+	// DeletedInVM is set when the VM no longer holds the file; the diff then removes it all
+	DeletedInVM bool `json:"deleted_in_vm,omitempty"`
+	// Diff is a unified diff from the host version to the VM version
+	Diff          string `json:"diff,omitempty"`
+	DiffTruncated bool   `json:"diff_truncated,omitempty"`
+	// Error explains why a version or the diff is missing
+	Error string `json:"error,omitempty"`
+}
+
+// GetConflictDetails returns the queued conflicts of a VM with both versions of each file and
+// their unified diff. Versions the conflicts do not carry are read from the host and the VM.
+func (e *Engine) GetConflictDetails(vmName string) ([]ConflictDetail, error) {
+	e.mu.RLock()
+	target, err := e.syncTargetLocked(vmName)
+	var conflicts []SyncConflict
+	if err == nil {
+		conflicts = append(conflicts, e.statuses[vmName].Conflicts...)
+	}
+	e.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]ConflictDetail, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		detail := ConflictDetail{
+			SyncConflict: conflict,
+			RelativePath: conflict.Path,
+			Policy:       target.config.PolicyForPath(conflict.Path),
+		}
+		if rel, err := filepath.Rel(target.config.ProjectPath, conflict.Path); err == nil {
+			detail.RelativePath = filepath.ToSlash(rel)
+		}
+		if target.shadow != nil {
+			_, detail.HasBase = target.shadow.base(target.config.ProjectPath, conflict.Path)
+		}
+
+		var inVM bool
+		if detail.HostContent, err = conflictHostContent(conflict); err != nil {
+			detail.Error = err.Error()
+		} else if detail.VMContent, inVM, err = conflictVMContent(target, conflict); err != nil {
+			detail.Error = err.Error()
+		} else {
+			detail.DeletedInVM = !inVM
+			if detail.Diff, err = unifiedDiff([]byte(detail.HostContent), []byte(detail.VMContent)); err != nil {
+				detail.Error = err.Error()
+			}
+		}
+		if len(detail.Diff) > maxConflictDiffBytes {
+			detail.Diff = detail.Diff[:maxConflictDiffBytes]
+			detail.DiffTruncated = true
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
+
+// unifiedDiff returns the unified diff from the host version of a file to its VM version, made
+// with diff, or git diff when diff is not installed. Identical versions give an empty diff.
+func unifiedDiff(host, vm []byte) (string, error) {
+	dir, err := os.MkdirTemp("", "vagrant-mcp-diff-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	hostFile, vmFile := filepath.Join(dir, "host"), filepath.Join(dir, "vm")
+	if err := os.WriteFile(hostFile, host, 0600); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(vmFile, vm, 0600); err != nil {
+		return "", err
+	}
+
+	var cmd *exec.Cmd
+	diff, err := exec.LookPath("diff")
+	if err == nil {
+		cmd = exec.Command(diff, "-u", "--label", "host", "--label", "vm", hostFile, vmFile)
+	} else {
+		cmd = exec.Command("git", "diff", "--no-index", "--no-color", "--no-ext-diff", hostFile, vmFile)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	// Both exit with 1 when the files differ
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w: %s", filepath.Base(cmd.Path), err, strings.TrimSpace(stderr.String()))
+	}
+	if diff != "" {
+		return string(output), nil
+	}
+
+	// git names the temporary files in its headers; keep only the hunks under the host and vm
+	// labels diff uses
+	_, hunks, found := strings.Cut(string(output), "\n@@")
+	if !found {
+		return "", nil
+	}
+	return "--- host\n+++ vm\n@@" + hunks, nil
+}
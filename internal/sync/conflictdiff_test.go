@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"context"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	if _, err := osexec.LookPath("diff"); err != nil {
+		if _, err := osexec.LookPath("git"); err != nil {
+			t.Skip("neither diff nor git is available")
+		}
+	}
+	diff, err := unifiedDiff([]byte("one\ntwo\nthree\n"), []byte("one\nTWO\nthree\n"))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !strings.HasPrefix(diff, "--- host\n+++ vm\n@@") || !strings.Contains(diff, "\n-two\n+TWO\n") {
+		t.Errorf("Expected a host to vm diff changing two but got %q", diff)
+	}
+
+	if diff, err := unifiedDiff([]byte("same\n"), []byte("same\n")); err != nil || diff != "" {
+		t.Errorf("Expected an empty diff for identical versions but got %q, %v", diff, err)
+	}
+}
+
+func TestSyncEngine_ResolveWithMergedContent(t *testing.T) {
+	if _, err := osexec.LookPath("diff"); err != nil {
+		t.Skip("diff is not available")
+	}
+	root := t.TempDir()
+	path := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(path, []byte("host\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	vmManager := &recordingVMManager{}
+	engine, _ := NewEngine()
+	engine.SetVMManager(vmManager)
+	if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: root}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+	if _, err := engine.RecordConflict("test-vm", SyncConflict{Path: path, VMContent: "vm\n", ConflictType: "modification"}); err != nil {
+		t.Fatalf("Failed to record conflict: %v", err)
+	}
+
+	details, err := engine.GetConflictDetails("test-vm")
+	if err != nil || len(details) != 1 {
+		t.Fatalf("Expected one conflict but got %+v, %v", details, err)
+	}
+	detail := details[0]
+	if detail.RelativePath != "notes.txt" || detail.HostContent != "host\n" || !strings.Contains(detail.Diff, "-host\n+vm\n") || detail.Error != "" {
+		t.Errorf("Expected the host content read from disk and a diff but got %+v", detail)
+	}
+
+	if err := engine.ResolveSyncConflict(context.Background(), "test-vm", path, ResolutionUseMerged); err == nil {
+		t.Error("Expected an error for use_merged without content")
+	}
+	if err := engine.ResolveSyncConflictWithContent(context.Background(), "test-vm", path, "merged\n"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "merged\n" {
+		t.Errorf("Expected the merged content on the host but got %q", content)
+	}
+	if len(vmManager.toVM) != 1 {
+		t.Errorf("Expected the merged file to be synced to the VM but got %v", vmManager.toVM)
+	}
+	if details, _ := engine.GetConflictDetails("test-vm"); len(details) != 0 {
+		t.Errorf("Expected the conflict to be resolved but got %+v", details)
+	}
+}
+
+func TestSyncEngine_ConflictVMContentFromGuest(t *testing.T) {
+	if _, err := osexec.LookPath("diff"); err != nil {
+		t.Skip("diff is not available")
+	}
+	root := t.TempDir()
+	// The name would break out of an unquoted shell word
+	edited, deleted := filepath.Join(root, "it's $(id).txt"), filepath.Join(root, "gone.txt")
+	for _, path := range []string{edited, deleted} {
+		if err := os.WriteFile(path, []byte("host\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	manager := &guestVMManager{
+		recordingVMManager: recordingVMManager{baseDir: t.TempDir()},
+		guest:              map[string]GuestFileVersion{"/vagrant/it's $(id).txt": {Content: []byte("vm\n")}},
+	}
+	engine, _ := NewEngine()
+	engine.SetVMManager(manager)
+	if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: root}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+	// Neither conflict carries its VM version, so it is read from the guest path of the file
+	for _, conflict := range []SyncConflict{
+		{Path: edited, ConflictType: "modification"},
+		{Path: deleted, ConflictType: "deletion"},
+	} {
+		if _, err := engine.RecordConflict("test-vm", conflict); err != nil {
+			t.Fatalf("Failed to record conflict: %v", err)
+		}
+	}
+
+	details, err := engine.GetConflictDetails("test-vm")
+	if err != nil || len(details) != 2 {
+		t.Fatalf("Expected two conflicts but got %+v, %v", details, err)
+	}
+	for _, detail := range details {
+		switch detail.Path {
+		case edited:
+			if detail.VMContent != "vm\n" || detail.DeletedInVM || !strings.Contains(detail.Diff, "-host\n+vm\n") {
+				t.Errorf("Expected the VM version read from the guest but got %+v", detail)
+			}
+		case deleted:
+			if detail.VMContent != "" || !detail.DeletedInVM || !strings.Contains(detail.Diff, "-host\n") || detail.Error != "" {
+				t.Errorf("Expected the file to be reported deleted in the VM but got %+v", detail)
+			}
+		}
+	}
+
+	// A deletion has nothing to merge or keep
+	for _, resolution := range []string{"merge", "keep_both"} {
+		if err := engine.ResolveSyncConflict(context.Background(), "test-vm", deleted, resolution); err == nil || !strings.Contains(err.Error(), "deleted in the VM") {
+			t.Errorf("Expected %s to fail for a file deleted in the VM but got %v", resolution, err)
+		}
+	}
+	if _, err := os.Stat(deleted + ".vm"); !os.IsNotExist(err) {
+		t.Errorf("Expected no empty VM version to be written but got %v", err)
+	}
+	if err := engine.ResolveSyncConflict(context.Background(), "test-vm", edited, "keep_both"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if content, _ := os.ReadFile(edited + ".vm"); string(content) != "vm\n" {
+		t.Errorf("Expected the VM version to be kept beside the host one but got %q", content)
+	}
+}
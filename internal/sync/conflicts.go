@@ -0,0 +1,362 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/events"
+	"github.com/vagrant-mcp/server/internal/hostos"
+)
+
+// ConflictPolicy decides how conflicts detected during sync are resolved
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyPreferHost resolves conflicts with the host version
+	ConflictPolicyPreferHost ConflictPolicy = "prefer_host"
+	// ConflictPolicyPreferVM resolves conflicts with the VM version
+	ConflictPolicyPreferVM ConflictPolicy = "prefer_vm"
+	// ConflictPolicyPreferNewest resolves conflicts with the most recently modified version
+	ConflictPolicyPreferNewest ConflictPolicy = "prefer_newest"
+	// ConflictPolicyAlwaysManual leaves conflicts for manual resolution
+	ConflictPolicyAlwaysManual ConflictPolicy = "always_manual"
+)
+
+// ConflictPolicyOverride applies a different policy to paths matching a pattern
+type ConflictPolicyOverride struct {
+	Pattern string         `json:"pattern"`
+	Policy  ConflictPolicy `json:"policy"`
+}
+
+// Journal operations
+const (
+	JournalSyncToVM                  = "sync_to_vm"
+	JournalSyncFromVM                = "sync_from_vm"
+	JournalConflictDetected          = "conflict_detected"
+	JournalConflictAutoResolved      = "conflict_auto_resolved"
+	JournalConflictResolved          = "conflict_resolved"
+	JournalConflictAutoResolveFailed = "conflict_auto_resolve_failed"
+)
+
+// maxConflictChecks bounds the changed host files a sync compares with their VM versions
+const maxConflictChecks = 500
+
+// GuestFileVersion is the content of a guest file and when it was last modified
+type GuestFileVersion struct {
+	Content []byte
+	ModTime time.Time
+}
+
+// GuestFileReader is implemented by VM managers that can read the files of a running VM. Syncs
+// of VMs whose manager implements it look for files changed on both sides since the last sync.
+type GuestFileReader interface {
+	// ReadGuestFiles returns the regular files among the guest paths, leaving out those that
+	// do not exist
+	ReadGuestFiles(name string, paths []string) (map[string]GuestFileVersion, error)
+}
+
+// maxJournalEntries bounds the per-VM sync journal
+const maxJournalEntries = 200
+
+// JournalEntry records a sync operation or conflict resolution
+type JournalEntry struct {
+	Time       time.Time      `json:"time"`
+	Operation  string         `json:"operation"`
+	Path       string         `json:"path,omitempty"`
+	Policy     ConflictPolicy `json:"policy,omitempty"`
+	Resolution string         `json:"resolution,omitempty"`
+	FileCount  int            `json:"file_count,omitempty"`
+	Message    string         `json:"message,omitempty"`
+}
+
+// ValidateConflictPolicy checks that a policy name is known
+func ValidateConflictPolicy(policy ConflictPolicy) error {
+	switch policy {
+	case ConflictPolicyPreferHost, ConflictPolicyPreferVM, ConflictPolicyPreferNewest, ConflictPolicyAlwaysManual:
+		return nil
+	default:
+		return fmt.Errorf("invalid conflict policy: %s (must be 'prefer_host', 'prefer_vm', 'prefer_newest', or 'always_manual')", policy)
+	}
+}
+
+// PolicyForPath returns the conflict policy that applies to path
+func (c SyncConfig) PolicyForPath(path string) ConflictPolicy {
+	rel := path
+	if c.ProjectPath != "" {
+		if r, err := filepath.Rel(c.ProjectPath, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+		}
+	}
+	rel = filepath.ToSlash(rel)
+
+	// The first matching override wins
+	for _, override := range c.ConflictPolicyOverrides {
+		pattern := strings.TrimSuffix(override.Pattern, "/")
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return override.Policy
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(rel)); matched {
+			return override.Policy
+		}
+		// A directory pattern covers everything below it
+		if strings.HasPrefix(rel, pattern+"/") {
+			return override.Policy
+		}
+	}
+
+	if c.ConflictPolicy == "" {
+		return ConflictPolicyAlwaysManual
+	}
+	return c.ConflictPolicy
+}
+
+// resolutionForPolicy maps a policy to a ResolveSyncConflict resolution, or "" for manual handling
+func resolutionForPolicy(policy ConflictPolicy, conflict SyncConflict) string {
+	switch policy {
+	case ConflictPolicyPreferHost:
+		return "use_host"
+	case ConflictPolicyPreferVM:
+		return "use_vm"
+	case ConflictPolicyPreferNewest:
+		if conflict.VMModTime.After(conflict.HostModTime) {
+			return "use_vm"
+		}
+		return "use_host"
+	default:
+		return ""
+	}
+}
+
+// RecordConflict registers a conflict detected during sync and applies the VM's conflict policy.
+// It returns the resolution applied, or "" when the conflict was queued for manual resolution.
+// The resolution is queued behind the VM's syncs, so it must not be called from one of them.
+func (e *Engine) RecordConflict(vmName string, conflict SyncConflict) (string, error) {
+	if vmName == "" {
+		return "", ErrInvalidVMName
+	}
+
+	e.mu.Lock()
+	config, exists := e.configs[vmName]
+	if !exists {
+		e.mu.Unlock()
+		return "", ErrVMNotRegistered
+	}
+
+	// Replace any earlier conflict for the same path
+	status := e.statuses[vmName]
+	for i, existing := range status.Conflicts {
+		if existing.Path == conflict.Path {
+			status.Conflicts = append(status.Conflicts[:i], status.Conflicts[i+1:]...)
+			break
+		}
+	}
+	status.Conflicts = append(status.Conflicts, conflict)
+	e.statuses[vmName] = status
+
+	policy := config.PolicyForPath(conflict.Path)
+	e.appendJournalLocked(vmName, JournalEntry{
+		Operation: JournalConflictDetected,
+		Path:      conflict.Path,
+		Policy:    policy,
+		Message:   conflict.ConflictType,
+	})
+	e.mu.Unlock()
+
+	resolution := resolutionForPolicy(policy, conflict)
+	if resolution == "" {
+		events.GlobalBus.Publish(events.ConflictDetected, vmName, map[string]interface{}{
+			"path":          conflict.Path,
+			"conflict_type": conflict.ConflictType,
+			"policy":        string(policy),
+		})
+		return "", nil
+	}
+
+	if err := e.runQueued(context.Background(), vmName, func() error { return e.resolveConflict(vmName, conflict.Path, resolution, "") }); err != nil {
+		// Leave the conflict queued so it can still be resolved manually
+		e.appendJournal(vmName, JournalEntry{
+			Operation:  JournalConflictAutoResolveFailed,
+			Path:       conflict.Path,
+			Policy:     policy,
+			Resolution: resolution,
+			Message:    err.Error(),
+		})
+		events.GlobalBus.Publish(events.ConflictDetected, vmName, map[string]interface{}{
+			"path":          conflict.Path,
+			"conflict_type": conflict.ConflictType,
+			"policy":        string(policy),
+			"error":         err.Error(),
+		})
+		return "", err
+	}
+
+	e.appendJournal(vmName, JournalEntry{
+		Operation:  JournalConflictAutoResolved,
+		Path:       conflict.Path,
+		Policy:     policy,
+		Resolution: resolution,
+	})
+	log.Info().Str("vm", vmName).Str("path", conflict.Path).Str("policy", string(policy)).Str("resolution", resolution).Msg("Sync conflict resolved automatically")
+	return resolution, nil
+}
+
+// detectsConflicts reports whether syncs of the target can look for conflicts: its VM manager
+// reads guest files and the shadow copy holds the versions of the last sync to compare with
+func (t syncTarget) detectsConflicts() bool {
+	_, reads := t.vmManager.(GuestFileReader)
+	return reads && t.shadow != nil && !t.config.Method.Mounted()
+}
+
+// detectConflicts compares the VM versions of host files changed since the last sync with the
+// versions of the last sync, kept in the shadow copy, and returns the files the VM changed too,
+// to something else. Files the shadow copy does not hold cannot be compared. The conflicts are
+// added to the target's conflicted paths, so its sync leaves them alone. Failing to read the VM
+// versions only skips the comparison.
+func (e *Engine) detectConflicts(target *syncTarget, changed []string) []SyncConflict {
+	reader, ok := target.vmManager.(GuestFileReader)
+	if !ok || target.shadow == nil {
+		return nil
+	}
+	root := target.config.ProjectPath
+	var paths, guestPaths []string
+	for _, path := range changed {
+		if slices.Contains(target.conflicted, path) {
+			continue
+		}
+		if _, ok := target.shadow.base(root, path); !ok {
+			continue
+		}
+		if len(paths) == maxConflictChecks {
+			log.Warn().Str("vm", target.vmName).Int("changed", len(changed)).Msg("Too many changed files to look for conflicts in all of them")
+			break
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		paths = append(paths, path)
+		guestPaths = append(guestPaths, hostos.GuestPath("/vagrant", rel))
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	guestFiles, err := reader.ReadGuestFiles(target.vmName, guestPaths)
+	if err != nil {
+		log.Warn().Err(err).Str("vm", target.vmName).Msg("Failed to read the VM versions of changed files, syncing without looking for conflicts")
+		return nil
+	}
+
+	var conflicts []SyncConflict
+	for i, path := range paths {
+		base, _ := target.shadow.base(root, path)
+		guest, inGuest := guestFiles[guestPaths[i]]
+		if inGuest && bytes.Equal(guest.Content, base) {
+			// Only the host changed the file
+			continue
+		}
+		host, err := os.ReadFile(path)
+		inHost := err == nil
+		if !inHost && !inGuest || inHost && inGuest && bytes.Equal(host, guest.Content) {
+			// Both sides made the same change
+			continue
+		}
+		conflict := SyncConflict{
+			Path:         path,
+			VMModTime:    guest.ModTime,
+			HostContent:  string(host),
+			VMContent:    string(guest.Content),
+			ConflictType: "modification",
+		}
+		if info, err := os.Stat(path); err == nil {
+			conflict.HostModTime = info.ModTime()
+		}
+		if !inHost || !inGuest {
+			conflict.ConflictType = "deletion"
+		}
+		conflicts = append(conflicts, conflict)
+		target.conflicted = append(target.conflicted, path)
+	}
+	return conflicts
+}
+
+// excludesFrom returns the rsync exclude patterns of a sync of the host directory dir: those of
+// syncExcludes and, anchored to dir, the files with unresolved conflicts, which no sync may
+// overwrite before they are resolved
+func (t syncTarget) excludesFrom(dir string) []string {
+	excludes := syncExcludes(t.config)
+	for _, path := range t.conflicted {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		excludes = append(excludes, "/"+filepath.ToSlash(rel))
+	}
+	return excludes
+}
+
+// conflictPaths returns the paths of conflicts
+func conflictPaths(conflicts []SyncConflict) []string {
+	var paths []string
+	for _, conflict := range conflicts {
+		paths = append(paths, conflict.Path)
+	}
+	return paths
+}
+
+// recordConflicts records the conflicts a sync detected, once it is off the VM's sync worker so
+// the resolutions of the conflict policy can queue behind it
+func (e *Engine) recordConflicts(vmName string, conflicts []SyncConflict) {
+	for _, conflict := range conflicts {
+		if _, err := e.RecordConflict(vmName, conflict); err != nil {
+			log.Warn().Err(err).Str("vm", vmName).Str("path", conflict.Path).Msg("Failed to resolve sync conflict automatically")
+		}
+	}
+}
+
+// GetSyncJournal returns the sync journal for a VM, oldest entry first
+func (e *Engine) GetSyncJournal(vmName string) ([]JournalEntry, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if vmName == "" {
+		return nil, ErrInvalidVMName
+	}
+	if _, exists := e.configs[vmName]; !exists {
+		return nil, ErrVMNotRegistered
+	}
+
+	journal := make([]JournalEntry, len(e.journals[vmName]))
+	copy(journal, e.journals[vmName])
+	return journal, nil
+}
+
+// appendJournal adds a journal entry for a VM, unless it was unregistered
+func (e *Engine) appendJournal(vmName string, entry JournalEntry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.configs[vmName]; exists {
+		e.appendJournalLocked(vmName, entry)
+	}
+}
+
+// appendJournalLocked adds a journal entry; the caller must hold e.mu
+func (e *Engine) appendJournalLocked(vmName string, entry JournalEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	journal := append(e.journals[vmName], entry)
+	if len(journal) > maxJournalEntries {
+		journal = journal[len(journal)-maxJournalEntries:]
+	}
+	e.journals[vmName] = journal
+}
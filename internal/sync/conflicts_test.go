@@ -0,0 +1,244 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingVMManager records sync calls made by the engine: the host sources synced to the VM,
+// the host targets synced from it and the exclude patterns of the last sync
+type recordingVMManager struct {
+	mu       sync.Mutex
+	toVM     []string
+	fromVM   []string
+	excludes []string
+	baseDir  string
+}
+
+func (m *recordingVMManager) GetBaseDir() string { return m.baseDir }
+
+func (m *recordingVMManager) SyncToVM(name, source, target string, excludes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.toVM = append(m.toVM, source)
+	m.excludes = excludes
+	return nil
+}
+
+func (m *recordingVMManager) SyncFromVM(name, source, target string, excludes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fromVM = append(m.fromVM, target)
+	m.excludes = excludes
+	return nil
+}
+
+// syncedToVM returns the sources synced to the VM so far
+func (m *recordingVMManager) syncedToVM() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.toVM...)
+}
+
+func TestSyncConfig_PolicyForPath(t *testing.T) {
+	config := SyncConfig{
+		ProjectPath:    "/project",
+		ConflictPolicy: ConflictPolicyPreferHost,
+		ConflictPolicyOverrides: []ConflictPolicyOverride{
+			{Pattern: "*.lock", Policy: ConflictPolicyPreferVM},
+			{Pattern: "generated/", Policy: ConflictPolicyPreferNewest},
+			{Pattern: "docs/*.md", Policy: ConflictPolicyAlwaysManual},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		expected ConflictPolicy
+	}{
+		{
+			name:     "default policy",
+			path:     "/project/main.go",
+			expected: ConflictPolicyPreferHost,
+		},
+		{
+			name:     "base name pattern",
+			path:     "/project/web/package.lock",
+			expected: ConflictPolicyPreferVM,
+		},
+		{
+			name:     "directory pattern",
+			path:     "/project/generated/api/client.go",
+			expected: ConflictPolicyPreferNewest,
+		},
+		{
+			name:     "relative path pattern",
+			path:     "/project/docs/README.md",
+			expected: ConflictPolicyAlwaysManual,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := config.PolicyForPath(tc.path); actual != tc.expected {
+				t.Errorf("Expected policy %s but got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSyncEngine_RecordConflict(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name               string
+		policy             ConflictPolicy
+		conflict           SyncConflict
+		expectedResolution string
+		expectedQueued     int
+	}{
+		{
+			name:               "manual policy queues conflict",
+			policy:             ConflictPolicyAlwaysManual,
+			conflict:           SyncConflict{Path: "/project/a.go", ConflictType: "modification"},
+			expectedResolution: "",
+			expectedQueued:     1,
+		},
+		{
+			name:               "prefer host resolves with host version",
+			policy:             ConflictPolicyPreferHost,
+			conflict:           SyncConflict{Path: "/project/a.go", ConflictType: "modification"},
+			expectedResolution: "use_host",
+			expectedQueued:     0,
+		},
+		{
+			name:   "prefer newest picks the VM when it is newer",
+			policy: ConflictPolicyPreferNewest,
+			conflict: SyncConflict{
+				Path:         "/project/a.go",
+				HostModTime:  now.Add(-time.Minute),
+				VMModTime:    now,
+				ConflictType: "modification",
+			},
+			expectedResolution: "use_vm",
+			expectedQueued:     0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine, _ := NewEngine()
+			engine.SetVMManager(&recordingVMManager{})
+			if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: "/project", ConflictPolicy: tc.policy}); err != nil {
+				t.Fatalf("Failed to register VM: %v", err)
+			}
+
+			resolution, err := engine.RecordConflict("test-vm", tc.conflict)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if resolution != tc.expectedResolution {
+				t.Errorf("Expected resolution %q but got %q", tc.expectedResolution, resolution)
+			}
+
+			status, _ := engine.GetSyncStatus("test-vm")
+			if len(status.Conflicts) != tc.expectedQueued {
+				t.Errorf("Expected %d queued conflicts but got %d", tc.expectedQueued, len(status.Conflicts))
+			}
+
+			journal, _ := engine.GetSyncJournal("test-vm")
+			last := journal[len(journal)-1]
+			if tc.expectedResolution == "" && last.Operation != JournalConflictDetected {
+				t.Errorf("Expected last journal entry %s but got %s", JournalConflictDetected, last.Operation)
+			}
+			if tc.expectedResolution != "" && (last.Operation != JournalConflictAutoResolved || last.Resolution != tc.expectedResolution) {
+				t.Errorf("Expected auto-resolution %s in journal but got %+v", tc.expectedResolution, last)
+			}
+		})
+	}
+}
+
+// guestVMManager is a recordingVMManager whose VM holds the files of guest
+type guestVMManager struct {
+	recordingVMManager
+	guest map[string]GuestFileVersion
+}
+
+func (m *guestVMManager) ReadGuestFiles(name string, paths []string) (map[string]GuestFileVersion, error) {
+	files := make(map[string]GuestFileVersion)
+	for _, path := range paths {
+		if file, ok := m.guest[path]; ok {
+			files[path] = file
+		}
+	}
+	return files, nil
+}
+
+func TestSyncEngine_SyncDetectsConflicts(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"main.go": "package main\n", "go.sum": "sum\n", "README.md": "readme\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	manager := &guestVMManager{recordingVMManager: recordingVMManager{baseDir: t.TempDir()}}
+	engine, _ := NewEngine()
+	engine.SetVMManager(manager)
+	config := SyncConfig{
+		ProjectPath:             root,
+		ConflictPolicy:          ConflictPolicyAlwaysManual,
+		ConflictPolicyOverrides: []ConflictPolicyOverride{{Pattern: "go.sum", Policy: ConflictPolicyPreferHost}},
+	}
+	if err := engine.RegisterVM("test-vm", config); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+	if _, err := engine.SyncToVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	// main.go and go.sum change on both sides, README.md only on the host
+	for name, content := range map[string]string{"main.go": "package main // host\n", "go.sum": "sum host\n", "README.md": "readme host\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	manager.guest = map[string]GuestFileVersion{
+		"/vagrant/main.go":   {Content: []byte("package main // vm\n")},
+		"/vagrant/go.sum":    {Content: []byte("sum vm\n")},
+		"/vagrant/README.md": {Content: []byte("readme\n")},
+	}
+	result, err := engine.SyncToVM(context.Background(), "test-vm", "")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	mainPath, sumPath := filepath.Join(root, "main.go"), filepath.Join(root, "go.sum")
+	slices.Sort(result.Conflicts)
+	if !slices.Equal(result.Conflicts, []string{sumPath, mainPath}) {
+		t.Errorf("Expected conflicts in go.sum and main.go but got %v", result.Conflicts)
+	}
+
+	// The manual conflict is queued, the prefer_host one synced to the VM
+	status, _ := engine.GetSyncStatus("test-vm")
+	if len(status.Conflicts) != 1 || status.Conflicts[0].Path != mainPath || status.Conflicts[0].VMContent != "package main // vm\n" {
+		t.Errorf("Expected main.go to be queued with its VM version but got %+v", status.Conflicts)
+	}
+	if synced := manager.syncedToVM(); synced[len(synced)-1] != sumPath {
+		t.Errorf("Expected go.sum to be synced to the VM last but got %v", synced)
+	}
+
+	// Later syncs in either direction leave the queued conflict alone
+	if _, err := engine.SyncFromVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !slices.Contains(manager.excludes, "/main.go") {
+		t.Errorf("Expected main.go to be excluded from the sync but got %v", manager.excludes)
+	}
+	if base, ok := newShadowCopy(filepath.Join(manager.baseDir, "test-vm", shadowDirName)).base(root, mainPath); !ok || string(base) != "package main\n" {
+		t.Errorf("Expected the shadow copy to keep the base of main.go but got %q", base)
+	}
+}
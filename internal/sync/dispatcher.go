@@ -17,17 +17,15 @@ func NewSyncMethodDispatcher(engine *Engine) *SyncMethodDispatcher {
 	}
 }
 
-// DispatchSyncMethod dispatches sync operation based on method and direction
-func (d *SyncMethodDispatcher) DispatchSyncMethod(method SyncMethod, vmName, sourcePath string, toVM bool) ([]string, error) {
-	switch method {
+// DispatchSyncMethod dispatches sync operation based on the target's method and direction
+func (d *SyncMethodDispatcher) DispatchSyncMethod(target syncTarget, sourcePath string, toVM bool) ([]string, error) {
+	switch target.config.Method {
 	case SyncMethodRsync:
-		return d.engine.syncWithRsync(vmName, sourcePath, toVM)
-	case SyncMethodNFS:
-		return d.engine.syncWithNFS(vmName, sourcePath, toVM)
-	case SyncMethodSMB:
-		return d.engine.syncWithSMB(vmName, sourcePath, toVM)
+		return d.engine.syncWithRsync(target, sourcePath, toVM)
+	case SyncMethodNFS, SyncMethodSMB, SyncMethodVirtioFS, SyncMethod9P:
+		return d.engine.syncWithMount(target)
 	default:
-		return nil, fmt.Errorf("unsupported sync method: %s", method)
+		return nil, fmt.Errorf("unsupported sync method: %s", target.config.Method)
 	}
 }
 
@@ -38,6 +36,8 @@ func (d *SyncMethodDispatcher) GetSupportedMethods() []SyncMethod {
 		SyncMethodNFS,
 		SyncMethodSMB,
 		SyncMethodVirtualBox,
+		SyncMethodVirtioFS,
+		SyncMethod9P,
 	}
 }
 
@@ -4,17 +4,21 @@
 package sync
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/artifacts"
 	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/events"
+	"github.com/vagrant-mcp/server/internal/hostos"
 )
 
 // SyncDirection represents the direction of synchronization
@@ -41,8 +45,38 @@ const (
 	SyncMethodSMB SyncMethod = "smb"
 	// SyncMethodVirtualBox uses VirtualBox shared folders
 	SyncMethodVirtualBox SyncMethod = "virtualbox"
+	// SyncMethodVirtioFS uses virtiofs shared folders of the libvirt provider
+	SyncMethodVirtioFS SyncMethod = "virtiofs"
+	// SyncMethod9P uses virtio 9p shared folders of the libvirt provider
+	SyncMethod9P SyncMethod = "9p"
 )
 
+// Mounted reports whether the method shares the project with the VM through a mount, so syncs
+// have nothing to copy
+func (m SyncMethod) Mounted() bool {
+	return m == SyncMethodNFS || m == SyncMethodSMB || m == SyncMethodVirtioFS || m == SyncMethod9P
+}
+
+// PerformanceNote describes how a sync method performs, for status reports
+func (m SyncMethod) PerformanceNote() string {
+	switch m {
+	case SyncMethodRsync, "":
+		return "Files are copied on each sync, so the VM works at native disk speed but only sees host changes once they are synced"
+	case SyncMethodNFS:
+		return "Host and VM share the files over NFS; every file access crosses the network, which slows builds touching many small files"
+	case SyncMethodSMB:
+		return "Host and VM share the files over SMB, which is slower than NFS for many small files and emulates file modes and symlinks"
+	case SyncMethodVirtualBox:
+		return "VirtualBox shared folders are the slowest option for many small files; keep dependency and build directories on the guest disk"
+	case SyncMethodVirtioFS:
+		return "Host and VM share the files through virtiofs at near-native speed, with no copies or sync loops"
+	case SyncMethod9P:
+		return "Host and VM share the files through virtio 9p, with no copies but slower metadata operations than virtiofs; use virtiofs when the guest kernel and host support it"
+	default:
+		return ""
+	}
+}
+
 // SyncConfig represents the configuration for file synchronization
 type SyncConfig struct {
 	VMName          string        `json:"vm_name"`
@@ -52,12 +86,30 @@ type SyncConfig struct {
 	ExcludePatterns []string      `json:"exclude_patterns"`
 	WatchEnabled    bool          `json:"watch_enabled"`
 	WatchInterval   time.Duration `json:"watch_interval"`
+	// WatchMode selects how the watcher detects changes; empty means auto
+	WatchMode WatchMode `json:"watch_mode,omitempty"`
+	// WatchDepth limits how many directory levels are watched with fsnotify; deeper
+	// directories are polled. 0 watches every level.
+	WatchDepth int `json:"watch_depth,omitempty"`
+	// ConflictPolicy is applied to conflicts detected during sync
+	ConflictPolicy ConflictPolicy `json:"conflict_policy"`
+	// ConflictPolicyOverrides apply different policies to matching paths
+	ConflictPolicyOverrides []ConflictPolicyOverride `json:"conflict_policy_overrides,omitempty"`
+	// ConflictStyle selects the markers merges leave in conflicting hunks; empty means merge
+	ConflictStyle ConflictStyle `json:"conflict_style,omitempty"`
 }
 
 // SyncResult represents the result of a synchronization operation
 type SyncResult struct {
 	SyncedFiles []string `json:"synced_files"`
 	SyncTimeMs  int      `json:"sync_time_ms"`
+	// ReportArtifact is the URI of the changes the sync made, when the sync tool listed them
+	ReportArtifact string `json:"report_artifact,omitempty"`
+	// Conflicts are the files changed on both sides since the last sync, which the sync left
+	// alone and handed to the conflict policy
+	Conflicts []string `json:"conflicts,omitempty"`
+	// detected are the conflicts to record once the sync is off the VM's sync worker
+	detected []SyncConflict
 }
 
 // SyncStatus represents the status of a synchronization operation
@@ -74,6 +126,18 @@ type SyncStatus struct {
 	TotalSyncs           int            `json:"total_syncs"`
 	TotalFilesSynced     int            `json:"total_files_synced"`
 	TotalSyncTimeMs      int            `json:"total_sync_time_ms"`
+	// QueuedSyncs is how many syncs are waiting for the one in progress
+	QueuedSyncs int `json:"queued_syncs"`
+	// PendingUploadTruncated is set when more host files changed than FilesPendingUpload lists,
+	// so only a sync of the whole project brings the VM up to date
+	PendingUploadTruncated bool `json:"pending_upload_truncated"`
+	// PendingDownloadTruncated is set when the last guest scan found more changed files than
+	// FilesPendingDownload lists
+	PendingDownloadTruncated bool `json:"pending_download_truncated"`
+	// LastGuestScan is when the VM was last scanned for files changed since the last sync
+	LastGuestScan time.Time `json:"last_guest_scan,omitempty"`
+	// Progress is the progress of the copy of the sync in progress, once rsync reported it
+	Progress *SyncProgress `json:"progress,omitempty"`
 }
 
 // SyncConflict represents a file conflict during synchronization
@@ -94,32 +158,41 @@ type SearchResult struct {
 	MatchType string `json:"match_type"` // "exact", "fuzzy", "semantic"
 }
 
-// Engine handles file synchronization between host and VM
+// Engine handles file synchronization between host and VM. Syncs run on a worker per VM
+// without holding mu, which only guards the maps below.
 type Engine struct {
-	configs       map[string]SyncConfig
-	statuses      map[string]SyncStatus
-	watchers      map[string]*fsnotify.Watcher
-	watcherStopCh map[string]chan struct{}
-	mu            sync.RWMutex
-	running       bool
-	vmManager     VMManager             // Reference to the VM Manager for Vagrant commands
-	dispatcher    *SyncMethodDispatcher // Method dispatcher
+	configs    map[string]SyncConfig
+	statuses   map[string]SyncStatus
+	watchers   map[string]*watchWorker
+	journals   map[string][]JournalEntry
+	workers    map[string]*syncWorker
+	pending    map[string]*pendingChanges
+	mu         sync.RWMutex
+	running    bool
+	vmManager  VMManager             // Reference to the VM Manager for Vagrant commands
+	dispatcher *SyncMethodDispatcher // Method dispatcher
+	// progressListeners receive the progress reports of the syncs of each VM
+	progressListeners map[string]map[int]func(SyncProgress)
+	nextListener      int
 }
 
 // VMManager interface defines the methods required from a VM Manager
 type VMManager interface {
 	GetBaseDir() string
-	SyncToVM(name, source, target string) error
-	SyncFromVM(name, source, target string) error
+	// SyncToVM and SyncFromVM copy source to target, leaving out the files matching excludes
+	SyncToVM(name, source, target string, excludes []string) error
+	SyncFromVM(name, source, target string, excludes []string) error
 }
 
 // NewEngine creates a new synchronization engine
 func NewEngine() (*Engine, error) {
 	engine := &Engine{
-		configs:       make(map[string]SyncConfig),
-		statuses:      make(map[string]SyncStatus),
-		watchers:      make(map[string]*fsnotify.Watcher),
-		watcherStopCh: make(map[string]chan struct{}),
+		configs:  make(map[string]SyncConfig),
+		statuses: make(map[string]SyncStatus),
+		watchers: make(map[string]*watchWorker),
+		journals: make(map[string][]JournalEntry),
+		workers:  make(map[string]*syncWorker),
+		pending:  make(map[string]*pendingChanges),
 	}
 
 	// Initialize the dispatcher
@@ -161,6 +234,9 @@ func (e *Engine) RegisterVM(vmName string, config SyncConfig) error {
 	if config.WatchInterval == 0 {
 		config.WatchInterval = 5 * time.Second
 	}
+	if config.ConflictPolicy == "" {
+		config.ConflictPolicy = ConflictPolicyAlwaysManual
+	}
 
 	// Store config
 	config.VMName = vmName
@@ -172,6 +248,8 @@ func (e *Engine) RegisterVM(vmName string, config SyncConfig) error {
 		InProgress:   false,
 		Conflicts:    []SyncConflict{},
 	}
+	e.workers[vmName] = newSyncWorker()
+	e.pending[vmName] = newPendingChanges()
 
 	// Start file watcher if enabled
 	if config.WatchEnabled {
@@ -200,153 +278,162 @@ func (e *Engine) UnregisterVM(vmName string) error {
 	}
 
 	// Stop watcher if running
-	if watcher, exists := e.watchers[vmName]; exists {
-		stopCh := e.watcherStopCh[vmName]
-		close(stopCh)
-		if err := watcher.Close(); err != nil {
-			log.Warn().Err(err).Msg("Failed to close watcher")
-		}
-		delete(e.watchers, vmName)
-		delete(e.watcherStopCh, vmName)
-	}
+	e.stopWatcherLocked(vmName)
+
+	// Drop queued syncs; one in progress finishes without updating the status
+	e.workers[vmName].close()
+	delete(e.workers, vmName)
+	delete(e.pending, vmName)
 
 	// Remove config and status
 	delete(e.configs, vmName)
 	delete(e.statuses, vmName)
+	delete(e.journals, vmName)
 
 	log.Info().Str("vm", vmName).Msg("VM unregistered from sync engine")
 	return nil
 }
 
-// SyncToVM synchronizes files from host to VM
-func (e *Engine) SyncToVM(vmName string, sourcePath string) (*SyncResult, error) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	// Validate VM name
-	if vmName == "" {
-		return nil, ErrInvalidVMName
+// SyncToVM synchronizes files from host to VM. The sync waits for the VM's earlier syncs and
+// does not block status queries or the syncs of other VMs.
+func (e *Engine) SyncToVM(ctx context.Context, vmName string, sourcePath string) (*SyncResult, error) {
+	var result *SyncResult
+	err := e.runQueued(ctx, vmName, func() error {
+		var err error
+		result, err = e.syncProject(vmName, sourcePath, true)
+		return err
+	})
+	if result != nil {
+		e.recordConflicts(vmName, result.detected)
 	}
+	return result, err
+}
 
-	// Check if registered
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
+// SyncFromVM synchronizes files from VM to host, into sourcePath or else the project path. The
+// sync waits for the VM's earlier syncs and does not block status queries or the syncs of other
+// VMs.
+func (e *Engine) SyncFromVM(ctx context.Context, vmName string, sourcePath string) (*SyncResult, error) {
+	var result *SyncResult
+	err := e.runQueued(ctx, vmName, func() error {
+		var err error
+		result, err = e.syncProject(vmName, sourcePath, false)
+		return err
+	})
+	if result != nil {
+		e.recordConflicts(vmName, result.detected)
 	}
+	return result, err
+}
 
-	// Update status
-	status := e.statuses[vmName]
-	status.InProgress = true
-	e.statuses[vmName] = status
+// syncProject synchronizes a VM in one direction; it runs on the VM's sync worker
+func (e *Engine) syncProject(vmName string, sourcePath string, toVM bool) (*SyncResult, error) {
+	target, err := e.beginSync(vmName)
+	if err != nil {
+		return nil, err
+	}
 
-	// Determine source path
+	// Determine the host path, the source of a sync to the VM and the destination of one from it
+	operation := "sync from VM"
+	if toVM {
+		operation = "sync to VM"
+	}
 	if sourcePath == "" {
-		sourcePath = config.ProjectPath
+		sourcePath = target.config.ProjectPath
 	}
 
 	// Ensure source path exists
-	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
-		status.InProgress = false
-		errMsg := fmt.Sprintf("Source path does not exist: %s", sourcePath)
-		status.Error = errMsg
-		e.statuses[vmName] = status
-		return nil, errors.OperationFailed("sync operation", fmt.Errorf("%s", errMsg))
+	if toVM {
+		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+			err := fmt.Errorf("Source path does not exist: %s", sourcePath)
+			e.finishSync(vmName, toVM, 0, 0, err, "")
+			return nil, errors.OperationFailed("sync operation", err)
+		}
 	}
 
-	// Start timer
-	startTime := time.Now()
+	// Files changed on both sides since the last sync are left out and handed to the conflict
+	// policy once the sync finished
+	var conflicts []SyncConflict
+	if sourcePath == target.config.ProjectPath && target.detectsConflicts() {
+		conflicts = e.detectConflicts(&target, target.shadow.changed(sourcePath, []string{sourcePath}))
+	}
 
-	// Perform sync based on method
-	syncedFiles, err := e.dispatcher.DispatchSyncMethod(config.Method, vmName, sourcePath, true)
+	// Perform sync based on method using dispatcher
+	startTime := time.Now()
+	syncedFiles, err := e.dispatcher.DispatchSyncMethod(target, sourcePath, toVM)
+	syncTimeMs := int(time.Since(startTime).Milliseconds())
 	if err != nil {
-		return nil, errors.OperationFailed("sync to VM", err)
+		e.finishSync(vmName, toVM, 0, syncTimeMs, err, "")
+		return nil, errors.OperationFailed(operation, err)
+	}
+	e.finishSync(vmName, toVM, len(syncedFiles), syncTimeMs, nil, "")
+	e.updateShadow(target, []string{sourcePath})
+	if toVM {
+		target.pending.uploaded(target.config.ProjectPath, []string{sourcePath}, startTime)
+	} else {
+		target.pending.downloaded(startTime)
 	}
-
-	// Calculate sync time
-	syncTime := time.Since(startTime)
-	syncTimeMs := int(syncTime.Milliseconds())
-
-	// Update status
-	status = e.statuses[vmName]
-	status.InProgress = false
-	status.LastSyncTime = time.Now()
-	status.LastSyncToVM = time.Now()
-	status.TotalSyncs++
-	status.TotalSyncTimeMs += syncTimeMs
-
-	status.SynchronizedFiles = len(syncedFiles)
-	status.TotalFilesSynced += len(syncedFiles)
-	status.Error = ""
-	e.statuses[vmName] = status
 
 	// Return result
 	return &SyncResult{
-		SyncedFiles: syncedFiles,
-		SyncTimeMs:  syncTimeMs,
+		SyncedFiles:    syncedFiles,
+		SyncTimeMs:     syncTimeMs,
+		ReportArtifact: e.syncReport(target, startTime),
+		Conflicts:      conflictPaths(conflicts),
+		detected:       conflicts,
 	}, nil
 }
 
-// SyncFromVM synchronizes files from VM to host
-func (e *Engine) SyncFromVM(vmName string, sourcePath string) (*SyncResult, error) {
+// beginSync marks a sync of a VM as in progress and returns its sync target
+func (e *Engine) beginSync(vmName string) (syncTarget, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	// Validate VM name
-	if vmName == "" {
-		return nil, ErrInvalidVMName
-	}
-
-	// Check if registered
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
+	target, err := e.syncTargetLocked(vmName)
+	if err != nil {
+		return syncTarget{}, err
 	}
-
-	// Update status
 	status := e.statuses[vmName]
 	status.InProgress = true
+	status.Progress = nil
 	e.statuses[vmName] = status
+	return target, nil
+}
 
-	// Determine source path
-	if sourcePath == "" {
-		sourcePath = "/vagrant"
-	}
-
-	// Start timer
-	startTime := time.Now()
-
-	// Perform sync based on method
-	var err error
-	var syncedFiles []string
+// finishSync records the outcome of a sync started with beginSync, unless the VM was
+// unregistered while it ran
+func (e *Engine) finishSync(vmName string, toVM bool, fileCount int, syncTimeMs int, syncErr error, message string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	// Perform sync based on method using dispatcher
-	syncedFiles, err = e.dispatcher.DispatchSyncMethod(config.Method, vmName, sourcePath, false)
-	if err != nil {
-		return nil, errors.OperationFailed("sync from VM", err)
+	status, exists := e.statuses[vmName]
+	if !exists {
+		return
 	}
-
-	// Calculate sync time
-	syncTime := time.Since(startTime)
-	syncTimeMs := int(syncTime.Milliseconds())
-
-	// Update status
-	status = e.statuses[vmName]
 	status.InProgress = false
+	status.Progress = nil
+	if syncErr != nil {
+		status.Error = syncErr.Error()
+		e.statuses[vmName] = status
+		return
+	}
+
+	operation, direction := JournalSyncFromVM, "from_vm"
 	status.LastSyncTime = time.Now()
-	status.LastSyncFromVM = time.Now()
+	if toVM {
+		operation, direction = JournalSyncToVM, "to_vm"
+		status.LastSyncToVM = status.LastSyncTime
+	} else {
+		status.LastSyncFromVM = status.LastSyncTime
+	}
 	status.TotalSyncs++
 	status.TotalSyncTimeMs += syncTimeMs
-
-	status.SynchronizedFiles = len(syncedFiles)
-	status.TotalFilesSynced += len(syncedFiles)
+	status.SynchronizedFiles = fileCount
+	status.TotalFilesSynced += fileCount
 	status.Error = ""
 	e.statuses[vmName] = status
 
-	// Return result
-	return &SyncResult{
-		SyncedFiles: syncedFiles,
-		SyncTimeMs:  syncTimeMs,
-	}, nil
+	e.appendJournalLocked(vmName, JournalEntry{Operation: operation, FileCount: fileCount, Message: message})
+	publishSyncCompleted(vmName, direction, fileCount, syncTimeMs)
 }
 
 // GetSyncStatus returns the sync status for a VM
@@ -364,6 +451,8 @@ func (e *Engine) GetSyncStatus(vmName string) (SyncStatus, error) {
 	if !exists {
 		return SyncStatus{}, ErrVMNotRegistered
 	}
+	status.QueuedSyncs = e.workers[vmName].pending()
+	e.pending[vmName].fill(&status)
 
 	return status, nil
 }
@@ -420,23 +509,30 @@ func (e *Engine) UpdateSyncConfig(vmName string, config SyncConfig) error {
 	if len(config.ExcludePatterns) == 0 {
 		config.ExcludePatterns = oldConfig.ExcludePatterns
 	}
+	if config.WatchMode == "" {
+		config.WatchMode = oldConfig.WatchMode
+	}
+	if config.ConflictPolicy == "" {
+		config.ConflictPolicy = oldConfig.ConflictPolicy
+	}
+	if config.ConflictPolicyOverrides == nil {
+		config.ConflictPolicyOverrides = oldConfig.ConflictPolicyOverrides
+	}
+	if config.ConflictStyle == "" {
+		config.ConflictStyle = oldConfig.ConflictStyle
+	}
 
 	e.configs[vmName] = config
+	if config.ProjectPath != oldConfig.ProjectPath {
+		e.pending[vmName] = newPendingChanges()
+	}
 
-	// Restart watcher if watching enabled/disabled
-	if oldConfig.WatchEnabled != config.WatchEnabled {
-		if config.WatchEnabled {
-			if err := e.startWatcher(vmName); err != nil {
-				log.Error().Err(err).Str("vm", vmName).Msg("Failed to start file watcher")
-			}
-		} else if watcher, exists := e.watchers[vmName]; exists {
-			stopCh := e.watcherStopCh[vmName]
-			close(stopCh)
-			if err := watcher.Close(); err != nil {
-				log.Warn().Err(err).Msg("Failed to close watcher")
-			}
-			delete(e.watchers, vmName)
-			delete(e.watcherStopCh, vmName)
+	// Restart the watcher so it picks up the new path, interval and exclude patterns
+	e.stopWatcherLocked(vmName)
+	if config.WatchEnabled {
+		if err := e.startWatcher(vmName); err != nil {
+			log.Error().Err(err).Str("vm", vmName).Msg("Failed to start file watcher")
+			return fmt.Errorf("sync configuration updated but the file watcher failed to start: %w", err)
 		}
 	}
 
@@ -444,85 +540,126 @@ func (e *Engine) UpdateSyncConfig(vmName string, config SyncConfig) error {
 	return nil
 }
 
-// ResolveSyncConflict resolves a sync conflict
-func (e *Engine) ResolveSyncConflict(vmName string, path string, resolution string) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	// Validate VM name
-	if vmName == "" {
-		return ErrInvalidVMName
+// ResolveSyncConflict resolves a sync conflict. The resolution is queued behind the VM's
+// syncs.
+func (e *Engine) ResolveSyncConflict(ctx context.Context, vmName string, path string, resolution string) error {
+	if resolution == ResolutionUseMerged {
+		return errors.InvalidInput("the use_merged resolution needs the merged content")
 	}
+	return e.resolveQueued(ctx, vmName, path, resolution, "")
+}
 
-	// Check if registered
-	status, exists := e.statuses[vmName]
-	if !exists {
-		return ErrVMNotRegistered
-	}
+// ResolveSyncConflictWithContent resolves a sync conflict with merged content supplied by the
+// caller, e.g. after reviewing the differences of both versions. The content replaces the host
+// file and is synced to the VM.
+func (e *Engine) ResolveSyncConflictWithContent(ctx context.Context, vmName string, path string, content string) error {
+	return e.resolveQueued(ctx, vmName, path, ResolutionUseMerged, content)
+}
+
+// resolveQueued queues a resolution behind the VM's syncs and journals it once applied
+func (e *Engine) resolveQueued(ctx context.Context, vmName string, path string, resolution string, merged string) error {
+	return e.runQueued(ctx, vmName, func() error {
+		if err := e.resolveConflict(vmName, path, resolution, merged); err != nil {
+			return err
+		}
+		e.appendJournal(vmName, JournalEntry{
+			Operation:  JournalConflictResolved,
+			Path:       path,
+			Resolution: resolution,
+		})
+		return nil
+	})
+}
 
-	// Find conflict
-	var foundIndex = -1
-	for i, conflict := range status.Conflicts {
-		if conflict.Path == path {
-			foundIndex = i
+// resolveConflict applies a resolution to a queued conflict; it runs on the VM's sync worker
+// and only holds e.mu to find the conflict and to remove it once resolved. merged is the
+// content of the use_merged resolution.
+func (e *Engine) resolveConflict(vmName string, path string, resolution string, merged string) error {
+	e.mu.RLock()
+	target, err := e.syncTargetLocked(vmName)
+	var conflict SyncConflict
+	found := false
+	for _, queued := range e.statuses[vmName].Conflicts {
+		if queued.Path == path {
+			conflict = queued
+			found = true
 			break
 		}
 	}
-
-	if foundIndex == -1 {
+	e.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if !found {
 		return errors.NotFound("conflict", path)
 	}
-
-	conflict := status.Conflicts[foundIndex]
+	// The resolution syncs the conflicted file and records it in the shadow copy
+	target.conflicted = slices.DeleteFunc(target.conflicted, func(conflicted string) bool { return conflicted == path })
 
 	// Resolve conflict based on resolution
 	switch resolution {
 	case "use_host":
 		// Sync file from host to VM
-		if _, err := e.syncFilesToVM(vmName, []string{path}); err != nil {
+		if _, err := e.syncFilesToVM(target, []string{path}); err != nil {
 			return errors.OperationFailed("sync file to VM", err)
 		}
+		e.updateShadow(target, []string{path})
 	case "use_vm":
 		// Sync file from VM to host
-		if _, err := e.syncFilesFromVM(vmName, []string{path}); err != nil {
+		if _, err := e.syncFilesFromVM(target, []string{path}); err != nil {
 			return errors.OperationFailed("sync file from VM", err)
 		}
+		e.updateShadow(target, []string{path})
 	case "merge":
 		// Attempt to merge changes
-		if err := e.mergeConflict(vmName, conflict); err != nil {
+		if err := e.mergeConflict(target, conflict); err != nil {
 			return errors.OperationFailed("merge conflict", err)
 		}
 	case "keep_both":
 		// Keep both versions with different names
-		if err := e.keepBothVersions(vmName, conflict); err != nil {
+		if err := e.keepBothVersions(target, conflict); err != nil {
 			return errors.OperationFailed("keep both versions", err)
 		}
+	case ResolutionUseMerged:
+		// Replace both versions with the supplied content
+		if err := os.WriteFile(path, []byte(merged), 0644); err != nil {
+			return errors.OperationFailed("write merged content", err)
+		}
+		if _, err := e.syncFilesToVM(target, []string{path}); err != nil {
+			return errors.OperationFailed("sync file to VM", err)
+		}
+		e.updateShadow(target, []string{path})
 	default:
-		return errors.InvalidInput(fmt.Sprintf("invalid resolution: %s (must be 'use_host', 'use_vm', 'merge', or 'keep_both')", resolution))
+		return errors.InvalidInput(fmt.Sprintf("invalid resolution: %s (must be 'use_host', 'use_vm', 'merge', 'keep_both', or 'use_merged')", resolution))
 	}
 
-	// Remove conflict from list
-	status.Conflicts = append(status.Conflicts[:foundIndex], status.Conflicts[foundIndex+1:]...)
-	e.statuses[vmName] = status
+	// Remove conflict from list, unless the VM was unregistered or the conflict resolved meanwhile
+	e.mu.Lock()
+	if status, exists := e.statuses[vmName]; exists {
+		for i, queued := range status.Conflicts {
+			if queued.Path == path {
+				status.Conflicts = append(status.Conflicts[:i], status.Conflicts[i+1:]...)
+				e.statuses[vmName] = status
+				break
+			}
+		}
+	}
+	e.mu.Unlock()
 
 	log.Info().Str("vm", vmName).Str("path", path).Str("resolution", resolution).Msg("Sync conflict resolved")
+	events.GlobalBus.Publish(events.ConflictResolved, vmName, map[string]interface{}{
+		"path":       path,
+		"resolution": resolution,
+	})
 	return nil
 }
 
 // SemanticSearch performs a semantic search across synchronized files
 func (e *Engine) SemanticSearch(vmName string, query string, maxResults int) ([]SearchResult, error) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	// Validate VM name
-	if vmName == "" {
-		return nil, ErrInvalidVMName
-	}
-
-	// Check if registered
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
+	// The search runs without the engine lock so it does not hold up syncs
+	config, err := e.GetSyncConfig(vmName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Define search paths
@@ -598,18 +735,10 @@ func (e *Engine) ExactSearch(vmName string, query string, caseSensitive bool, ma
 
 	// This is a simplified implementation that could be enhanced
 	// with better search algorithms in a real-world scenario
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	// Validate VM name
-	if vmName == "" {
-		return nil, ErrInvalidVMName
-	}
-
-	// Check if registered
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
+	// The search runs without the engine lock so it does not hold up syncs
+	config, err := e.GetSyncConfig(vmName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Define search paths
@@ -672,18 +801,10 @@ func (e *Engine) FuzzySearch(vmName string, query string, maxResults int) ([]Sea
 	// This would implement a fuzzy search algorithm
 	// For now, we'll use a basic approximation with grep
 
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-
-	// Validate VM name
-	if vmName == "" {
-		return nil, ErrInvalidVMName
-	}
-
-	// Check if registered
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
+	// The search runs without the engine lock so it does not hold up syncs
+	config, err := e.GetSyncConfig(vmName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Define search paths
@@ -760,35 +881,61 @@ func (e *Engine) FuzzySearch(vmName string, query string, maxResults int) ([]Sea
 
 // Helper methods
 
-// syncWithRsync synchronizes files using rsync
-func (e *Engine) syncWithRsync(vmName string, sourcePath string, toVM bool) ([]string, error) {
-	// Get VM config
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
+// publishSyncCompleted publishes a sync completion event on the global event bus
+func publishSyncCompleted(vmName string, direction string, fileCount int, syncTimeMs int) {
+	events.GlobalBus.Publish(events.SyncCompleted, vmName, map[string]interface{}{
+		"direction":    direction,
+		"file_count":   fileCount,
+		"sync_time_ms": syncTimeMs,
+	})
+}
+
+// syncReport returns the URI of the sync report the VM manager stored for a sync of a VM
+// that started at start, or an empty string when it stored none
+func (e *Engine) syncReport(target syncTarget, start time.Time) string {
+	if target.vmManager == nil || target.vmManager.GetBaseDir() == "" {
+		return ""
+	}
+	if artifact, ok := artifacts.NewStore(target.vmManager.GetBaseDir()).Latest(target.vmName, artifacts.KindSyncReport, start); ok {
+		return artifact.URI
 	}
+	return ""
+}
+
+// syncExcludes returns the patterns of the files syncs leave out: Vagrant's state directory and
+// the configured exclude patterns. Excluded files are neither copied nor deleted.
+func syncExcludes(config SyncConfig) []string {
+	return append(append([]string{}, DefaultVerifyExcludes...), config.ExcludePatterns...)
+}
 
-	// Get exclude patterns - these are used by the VM manager internally
-	// Just logging them for reference
+// syncWithRsync synchronizes files using rsync
+func (e *Engine) syncWithRsync(target syncTarget, sourcePath string, toVM bool) ([]string, error) {
+	vmName, config := target.vmName, target.config
 	if len(config.ExcludePatterns) > 0 {
 		log.Debug().Str("vm", vmName).Strs("exclude_patterns", config.ExcludePatterns).Msg("Using exclude patterns for sync")
 	}
 
-	// We'll use the VM manager directly instead of creating temporary files and running commands
-
 	// Check if VM manager is set
-	if e.vmManager == nil {
+	if target.vmManager == nil {
 		return nil, errors.OperationFailed("VM manager not set before sync operations", nil)
 	}
 
-	// Use the VM manager to perform the sync
+	// Use the VM manager to perform the sync, reporting the progress of the copy when it can
 	var syncErr error
-	if toVM {
+	progressManager, reportsProgress := target.vmManager.(ProgressVMManager)
+	progress := func(report hostos.RsyncProgress) { e.reportProgress(vmName, report) }
+	excludes := target.excludesFrom(sourcePath)
+	switch {
+	case toVM && reportsProgress:
+		syncErr = progressManager.SyncToVMWithProgress(vmName, sourcePath, "/vagrant", excludes, progress)
+	case toVM:
 		// Sync from host to VM using the VM manager
-		syncErr = e.vmManager.SyncToVM(vmName, sourcePath, "/vagrant")
-	} else {
+		syncErr = target.vmManager.SyncToVM(vmName, sourcePath, "/vagrant", excludes)
+	case reportsProgress:
+		syncErr = progressManager.SyncFromVMWithProgress(vmName, "/vagrant", sourcePath, excludes, progress)
+	default:
 		// Sync from VM to host using the VM manager
-		syncErr = e.vmManager.SyncFromVM(vmName, "/vagrant", sourcePath)
+		syncErr = target.vmManager.SyncFromVM(vmName, "/vagrant", sourcePath, excludes)
 	}
 
 	if syncErr != nil {
@@ -806,76 +953,29 @@ func (e *Engine) syncWithRsync(vmName string, sourcePath string, toVM bool) ([]s
 	return syncedFiles, nil
 }
 
-// syncWithNFS synchronizes files using NFS
-func (e *Engine) syncWithNFS(vmName string, sourcePath string, toVM bool) ([]string, error) {
-	// NFS is typically set up as a mount, so individual sync operations are not needed
-	// Check if VM manager is set
-	if e.vmManager == nil {
-		return nil, errors.OperationFailed("VM manager not set before sync operations", nil)
-	}
-
-	// For NFS, we need to ensure the VM is running for the mount to be accessible
-	// Use the VM manager to perform the sync
-	var syncErr error
-	if toVM {
-		// Sync from host to VM using the VM manager
-		syncErr = e.vmManager.SyncToVM(vmName, sourcePath, "/vagrant")
-	} else {
-		// Sync from VM to host using the VM manager
-		syncErr = e.vmManager.SyncFromVM(vmName, "/vagrant", sourcePath)
-	}
-
-	if syncErr != nil {
-		return nil, errors.OperationFailed("sync operation", syncErr)
-	}
-
-	// Return a placeholder to indicate successful sync
-	return []string{
-		fmt.Sprintf("sync_completed_%s_%s", vmName, time.Now().Format(time.RFC3339)),
-	}, nil
-}
-
-// syncWithSMB synchronizes files using SMB
-func (e *Engine) syncWithSMB(vmName string, sourcePath string, toVM bool) ([]string, error) {
-	// SMB is typically set up as a mount, so individual sync operations are not needed
-	// Check if VM manager is set
-	if e.vmManager == nil {
+// syncWithMount "syncs" a project shared with the VM through a mount, e.g. NFS or virtiofs,
+// where both sides see the same files. Nothing is copied; the mount is checked instead, when the VM manager
+// can check it.
+func (e *Engine) syncWithMount(target syncTarget) ([]string, error) {
+	if target.vmManager == nil {
 		return nil, errors.OperationFailed("VM manager not set before sync operations", nil)
 	}
-
-	// For SMB, we need to ensure the VM is running for the mount to be accessible
-	// Use the VM manager to perform the sync
-	var syncErr error
-	if toVM {
-		// Sync from host to VM using the VM manager
-		syncErr = e.vmManager.SyncToVM(vmName, sourcePath, "/vagrant")
-	} else {
-		// Sync from VM to host using the VM manager
-		syncErr = e.vmManager.SyncFromVM(vmName, "/vagrant", sourcePath)
-	}
-
-	if syncErr != nil {
-		return nil, errors.OperationFailed("sync operation", syncErr)
+	if checker, ok := target.vmManager.(interface{ CheckSyncMount(name string) error }); ok {
+		if err := checker.CheckSyncMount(target.vmName); err != nil {
+			return nil, errors.OperationFailed("check synced folder mount", err)
+		}
 	}
-
-	// Return a placeholder to indicate successful sync
-	return []string{
-		fmt.Sprintf("sync_completed_%s_%s", vmName, time.Now().Format(time.RFC3339)),
-	}, nil
+	return []string{}, nil
 }
 
 // syncFilesToVM synchronizes specific files to the VM
-func (e *Engine) syncFilesToVM(vmName string, files []string) ([]string, error) {
+func (e *Engine) syncFilesToVM(target syncTarget, files []string) ([]string, error) {
 	// Check if VM manager is set
-	if e.vmManager == nil {
+	if target.vmManager == nil {
 		return nil, errors.OperationFailed("VM manager not set before sync operations", nil)
 	}
 
-	// Get VM config
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
-	}
+	vmName, config := target.vmName, target.config
 
 	// For selective file sync, we need to iterate through each file and sync individually
 	syncedFiles := []string{}
@@ -887,8 +987,8 @@ func (e *Engine) syncFilesToVM(vmName string, files []string) ([]string, error)
 		}
 
 		// Use the VM manager to sync this specific file
-		guestPath := filepath.Join("/vagrant", relPath)
-		if err := e.vmManager.SyncToVM(vmName, file, guestPath); err != nil {
+		guestPath := hostos.GuestPath("/vagrant", relPath)
+		if err := target.vmManager.SyncToVM(vmName, file, guestPath, syncExcludes(config)); err != nil {
 			return syncedFiles, errors.OperationFailed("failed to sync file to VM", err)
 		}
 
@@ -899,28 +999,27 @@ func (e *Engine) syncFilesToVM(vmName string, files []string) ([]string, error)
 }
 
 // syncFilesFromVM synchronizes specific files from the VM
-func (e *Engine) syncFilesFromVM(vmName string, files []string) ([]string, error) {
+func (e *Engine) syncFilesFromVM(target syncTarget, files []string) ([]string, error) {
 	// Check if VM manager is set
-	if e.vmManager == nil {
+	if target.vmManager == nil {
 		return nil, errors.OperationFailed("VM manager not set before sync operations", nil)
 	}
 
-	// Get VM config
-	config, exists := e.configs[vmName]
-	if !exists {
-		return nil, ErrVMNotRegistered
-	}
+	vmName, config := target.vmName, target.config
 
 	// For selective file sync, we need to iterate through each file and sync individually
 	syncedFiles := []string{}
 	for _, file := range files {
-		// Determine the paths for source and destination
-		// Convert the path to be relative to /vagrant on the VM
-		vmPath := filepath.Join("/vagrant", filepath.Base(file))
-		hostPath := filepath.Join(config.ProjectPath, filepath.Base(file))
+		// Get the relative path within the project
+		relPath, err := filepath.Rel(config.ProjectPath, file)
+		if err != nil {
+			continue // Skip files outside the project
+		}
+		vmPath := hostos.GuestPath("/vagrant", relPath)
+		hostPath := filepath.Join(config.ProjectPath, relPath)
 
 		// Use the VM manager to sync this specific file
-		if err := e.vmManager.SyncFromVM(vmName, vmPath, hostPath); err != nil {
+		if err := target.vmManager.SyncFromVM(vmName, vmPath, hostPath, syncExcludes(config)); err != nil {
 			return syncedFiles, errors.OperationFailed("failed to sync file from VM", err)
 		}
 
@@ -930,243 +1029,103 @@ func (e *Engine) syncFilesFromVM(vmName string, files []string) ([]string, error
 	return syncedFiles, nil
 }
 
-// startWatcher starts a file watcher for a VM
-func (e *Engine) startWatcher(vmName string) error {
-	// Get VM config
-	config, exists := e.configs[vmName]
-	if !exists {
-		return ErrVMNotRegistered
-	}
+// mergeConflict merges the host and VM versions of a file against its version at the last sync,
+// kept in the shadow copy, and syncs the result to the VM. A file the shadow copy does not hold
+// is merged against an empty base. Hunks that cannot be merged are left between conflict
+// markers in the configured style and an error is returned.
+func (e *Engine) mergeConflict(target syncTarget, conflict SyncConflict) error {
+	vmName, config := target.vmName, target.config
 
-	// Create watcher
-	watcher, err := fsnotify.NewWatcher()
+	vmContent, inVM, err := conflictVMContent(target, conflict)
 	if err != nil {
-		return fmt.Errorf("failed to create file watcher: %w", err)
+		return err
 	}
-
-	// Add project directory to watcher
-	err = filepath.Walk(config.ProjectPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			// Skip excluded directories
-			for _, pattern := range config.ExcludePatterns {
-				if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-					return filepath.SkipDir
-				}
-			}
-			return watcher.Add(path)
-		}
-		return nil
-	})
-	if err != nil {
-		if cerr := watcher.Close(); cerr != nil {
-			log.Warn().Err(cerr).Msg("Failed to close watcher after error")
-		}
-		return fmt.Errorf("failed to add directories to watcher: %w", err)
+	if !inVM {
+		return fmt.Errorf("the file was deleted in the VM, so there is nothing to merge; resolve the conflict with use_host or use_vm")
 	}
 
-	// Create stop channel
-	stopCh := make(chan struct{})
-	e.watchers[vmName] = watcher
-	e.watcherStopCh[vmName] = stopCh
-
-	// Start watcher goroutine
-	go func() {
-		defer func() {
-			if err := watcher.Close(); err != nil {
-				log.Warn().Err(err).Msg("Failed to close watcher in goroutine")
-			}
-		}()
-
-		// Create a timer for batching changes
-		var timer *time.Timer
-		var pendingChanges = make(map[string]bool)
-
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-					// Check exclude patterns
-					isExcluded := false
-					for _, pattern := range config.ExcludePatterns {
-						if matched, _ := filepath.Match(pattern, filepath.Base(event.Name)); matched {
-							isExcluded = true
-							break
-						}
-					}
-					if !isExcluded {
-						pendingChanges[event.Name] = true
-						if timer == nil {
-							timer = time.AfterFunc(config.WatchInterval, func() {
-								e.mu.Lock()
-								defer e.mu.Unlock()
-
-								// Sync changed files
-								files := make([]string, 0, len(pendingChanges))
-								for file := range pendingChanges {
-									files = append(files, file)
-								}
-
-								if len(files) > 0 {
-									log.Info().Str("vm", vmName).Int("count", len(files)).Msg("File changes detected, syncing to VM")
-									if _, err := e.syncFilesToVM(vmName, files); err != nil {
-										log.Error().Err(err).Str("vm", vmName).Msg("Failed to sync changes to VM")
-									}
-								}
-
-								// Reset pending changes
-								pendingChanges = make(map[string]bool)
-								timer = nil
-							})
-						}
-					}
-				}
-
-				// Add new directories to watch
-				if event.Op&fsnotify.Create != 0 {
-					info, err := os.Stat(event.Name)
-					if err == nil && info.IsDir() {
-						if err := watcher.Add(event.Name); err != nil {
-							log.Warn().Err(err).Msg("Failed to add new directory to watcher")
-						}
-					}
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Error().Err(err).Str("vm", vmName).Msg("File watcher error")
-			case <-stopCh:
-				if timer != nil {
-					timer.Stop()
-				}
-				return
-			}
-		}
-	}()
-
-	log.Info().Str("vm", vmName).Str("path", config.ProjectPath).Msg("File watcher started")
-	return nil
-}
-
-// mergeConflict attempts to merge changes from both versions of a file
-func (e *Engine) mergeConflict(vmName string, conflict SyncConflict) error {
-	config, exists := e.configs[vmName]
-	if !exists {
-		return ErrVMNotRegistered
+	hostContent, err := conflictHostContent(conflict)
+	if err != nil {
+		return err
 	}
 
-	// Create temporary files for diff3 merge
-	hostFile := fmt.Sprintf("%s.host", conflict.Path)
-	vmFile := fmt.Sprintf("%s.vm", conflict.Path)
-	baseFile := fmt.Sprintf("%s.base", conflict.Path)
-
-	// Get file content from VM if not already in the conflict
-	vmContent := conflict.VMContent
-	if vmContent == "" {
-		// Command to get content from VM
-		cmd := exec.Command("vagrant", "ssh", vmName, "-c", fmt.Sprintf("cat %s", conflict.Path))
-		cmd.Dir = config.ProjectPath
-		output, err := cmd.Output()
-		if err != nil {
-			return fmt.Errorf("failed to get VM file content: %w", err)
+	var baseContent []byte
+	if target.shadow != nil {
+		var found bool
+		if baseContent, found = target.shadow.base(config.ProjectPath, conflict.Path); !found {
+			log.Warn().Str("vm", vmName).Str("path", conflict.Path).Msg("No synced version of the file to merge against; merging against an empty file")
 		}
-		vmContent = string(output)
 	}
-
-	// Get host content if not already in the conflict
-	hostContent := conflict.HostContent
-	if hostContent == "" {
-		content, err := os.ReadFile(conflict.Path)
-		if err != nil {
-			return fmt.Errorf("failed to read host file: %w", err)
-		}
-		hostContent = string(content)
+	merged, conflicted, err := mergeFiles([]byte(hostContent), baseContent, []byte(vmContent), config.ConflictStyle)
+	if err != nil {
+		return err
 	}
 
-	// Try to find a common base version (could be enhanced with git or other VCS)
-	// For now, we'll create a simplified base file
-	baseContent := e.createBaseContent(hostContent, vmContent)
-
-	// Write files for merge tool
-	if err := os.WriteFile(hostFile, []byte(hostContent), 0644); err != nil {
+	// The merged file is synced to the VM even with conflict markers, so both sides match
+	if err := os.WriteFile(conflict.Path, merged, 0644); err != nil {
 		return err
 	}
-	if err := os.WriteFile(vmFile, []byte(vmContent), 0644); err != nil {
+	if _, err := e.syncFilesToVM(target, []string{conflict.Path}); err != nil {
 		return err
 	}
-	if err := os.WriteFile(baseFile, []byte(baseContent), 0644); err != nil {
-		return err
+	if conflicted {
+		return fmt.Errorf("automatic merge had conflicts, file saved with conflict markers")
 	}
+	e.updateShadow(target, []string{conflict.Path})
+	return nil
+}
 
-	// Perform merge using diff3
-	cmd := exec.Command("diff3", "-m", hostFile, baseFile, vmFile)
-	output, err := cmd.CombinedOutput()
+// conflictHostContent returns the host version of a conflicted file, read from the host when the
+// conflict does not carry it
+func conflictHostContent(conflict SyncConflict) (string, error) {
+	if conflict.HostContent != "" {
+		return conflict.HostContent, nil
+	}
+	content, err := os.ReadFile(conflict.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read host file: %w", err)
+	}
+	return string(content), nil
+}
 
-	// Clean up temp files
-	if err := os.Remove(hostFile); err != nil {
-		log.Warn().Err(err).Msg("Failed to remove hostFile")
+// conflictVMContent returns the VM version of a conflicted file, read from the VM when the
+// conflict does not carry it. inVM is false when the VM no longer holds the file.
+func conflictVMContent(target syncTarget, conflict SyncConflict) (content string, inVM bool, err error) {
+	if conflict.VMContent != "" {
+		return conflict.VMContent, true, nil
 	}
-	if err := os.Remove(vmFile); err != nil {
-		log.Warn().Err(err).Msg("Failed to remove vmFile")
+	reader, ok := target.vmManager.(GuestFileReader)
+	if !ok {
+		return "", false, fmt.Errorf("the VM manager cannot read files in the VM")
 	}
-	if err := os.Remove(baseFile); err != nil {
-		log.Warn().Err(err).Msg("Failed to remove baseFile")
+	rel, err := filepath.Rel(target.config.ProjectPath, conflict.Path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("%s is outside the project", conflict.Path)
 	}
-
+	guestPath := hostos.GuestPath("/vagrant", rel)
+	files, err := reader.ReadGuestFiles(target.vmName, []string{guestPath})
 	if err != nil {
-		// If automatic merge failed, return conflict markers
-		if err := os.WriteFile(conflict.Path, output, 0644); err != nil {
-			return err
-		}
-
-		// Also sync the conflict-marked file to the VM
-		if _, err := e.syncFilesToVM(vmName, []string{conflict.Path}); err != nil {
-			return err
-		}
-
-		return fmt.Errorf("automatic merge had conflicts, file saved with conflict markers")
+		return "", false, fmt.Errorf("failed to get VM file content: %w", err)
 	}
-
-	// Write merged content and sync to VM
-	if err := os.WriteFile(conflict.Path, output, 0644); err != nil {
-		return err
-	}
-
-	if _, err := e.syncFilesToVM(vmName, []string{conflict.Path}); err != nil {
-		return err
+	file, ok := files[guestPath]
+	if !ok {
+		return "", false, nil
 	}
-
-	return nil
+	return string(file.Content), true, nil
 }
 
 // keepBothVersions keeps both versions of a conflicted file with different names
-func (e *Engine) keepBothVersions(vmName string, conflict SyncConflict) error {
-	config, exists := e.configs[vmName]
-	if !exists {
-		return ErrVMNotRegistered
-	}
-
+func (e *Engine) keepBothVersions(target syncTarget, conflict SyncConflict) error {
 	// Generate filenames
 	// Using the conflict path directly in the code below
 	vmFile := fmt.Sprintf("%s.vm", conflict.Path)
 
-	// Get file content from VM if not already in the conflict
-	vmContent := conflict.VMContent
-	if vmContent == "" {
-		// Command to get content from VM
-		cmd := exec.Command("vagrant", "ssh", vmName, "-c", fmt.Sprintf("cat %s", conflict.Path))
-		cmd.Dir = config.ProjectPath
-		output, err := cmd.Output()
-		if err != nil {
-			return fmt.Errorf("failed to get VM file content: %w", err)
-		}
-		vmContent = string(output)
+	vmContent, inVM, err := conflictVMContent(target, conflict)
+	if err != nil {
+		return err
+	}
+	if !inVM {
+		return fmt.Errorf("the file was deleted in the VM, so there is no VM version to keep; resolve the conflict with use_host or use_vm")
 	}
 
 	// Write VM version to host
@@ -1175,56 +1134,13 @@ func (e *Engine) keepBothVersions(vmName string, conflict SyncConflict) error {
 	}
 
 	// Sync the VM version back to VM with the .vm extension
-	if _, err := e.syncFilesToVM(vmName, []string{vmFile}); err != nil {
+	if _, err := e.syncFilesToVM(target, []string{vmFile}); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// createBaseContent creates a simplified base version for merge operations
-func (e *Engine) createBaseContent(hostContent, vmContent string) string {
-	// This is a very simplified approach - in a real implementation,
-	// you might use a more sophisticated algorithm or store previous versions
-
-	hostLines := strings.Split(hostContent, "\n")
-	vmLines := strings.Split(vmContent, "\n")
-
-	commonLines := []string{}
-
-	// Find common beginning
-	minLen := len(hostLines)
-	if len(vmLines) < minLen {
-		minLen = len(vmLines)
-	}
-
-	for i := 0; i < minLen; i++ {
-		if hostLines[i] == vmLines[i] {
-			commonLines = append(commonLines, hostLines[i])
-		} else {
-			break
-		}
-	}
-
-	// Find common ending
-	hostEndIndex := len(hostLines) - 1
-	vmEndIndex := len(vmLines) - 1
-
-	for hostEndIndex >= 0 && vmEndIndex >= 0 && hostLines[hostEndIndex] == vmLines[vmEndIndex] {
-		hostEndIndex--
-		vmEndIndex--
-	}
-
-	// Add common ending in reverse order
-	endingLines := []string{}
-	for i := hostEndIndex + 1; i < len(hostLines); i++ {
-		endingLines = append(endingLines, hostLines[i])
-	}
-
-	// Combine common beginning and ending
-	return strings.Join(commonLines, "\n") + "\n" + strings.Join(endingLines, "\n")
-}
-
 // IsRunning checks if the sync engine is currently running
 func (e *Engine) IsRunning() bool {
 	return e.running
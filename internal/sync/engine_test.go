@@ -1,9 +1,35 @@
 package sync
 
 import (
+	"context"
+	"fmt"
+	"slices"
 	"testing"
+	"time"
 )
 
+// blockingVMManager holds every sync until it is released, and fails the syncs of VMs in fail
+type blockingVMManager struct {
+	started chan string
+	release chan struct{}
+	fail    map[string]bool
+}
+
+func (m *blockingVMManager) GetBaseDir() string { return "" }
+
+func (m *blockingVMManager) SyncToVM(name, source, target string, excludes []string) error {
+	m.started <- name
+	<-m.release
+	if m.fail[name] {
+		return fmt.Errorf("rsync failed")
+	}
+	return nil
+}
+
+func (m *blockingVMManager) SyncFromVM(name, source, target string, excludes []string) error {
+	return m.SyncToVM(name, source, target, excludes)
+}
+
 func TestSyncEngine_RegisterVM(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -206,3 +232,204 @@ func TestEngine_IsRunning(t *testing.T) {
 		t.Error("Expected engine to not be running after Stop")
 	}
 }
+
+func TestSyncEngine_ConcurrentSyncs(t *testing.T) {
+	manager := &blockingVMManager{
+		started: make(chan string, 3),
+		release: make(chan struct{}),
+		fail:    map[string]bool{"vm-b": true},
+	}
+	engine, _ := NewEngine()
+	engine.SetVMManager(manager)
+	for _, vmName := range []string{"vm-a", "vm-b"} {
+		if err := engine.RegisterVM(vmName, SyncConfig{ProjectPath: t.TempDir()}); err != nil {
+			t.Fatalf("Failed to register %s: %v", vmName, err)
+		}
+	}
+
+	errs := make(chan error, 3)
+	for _, vmName := range []string{"vm-a", "vm-b", "vm-a"} {
+		go func() {
+			_, err := engine.SyncToVM(context.Background(), vmName, "")
+			errs <- err
+		}()
+	}
+
+	// Both VMs sync at the same time while the second sync of vm-a waits for the first
+	running := map[string]int{}
+	for range 2 {
+		select {
+		case vmName := <-manager.started:
+			running[vmName]++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected syncs of both VMs to run at the same time but got %v", running)
+		}
+	}
+	if running["vm-a"] != 1 || running["vm-b"] != 1 {
+		t.Fatalf("Expected one sync of each VM to run but got %v", running)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := engine.GetSyncStatus("vm-a")
+		if err != nil {
+			t.Fatalf("Expected the status while syncing but got %v", err)
+		}
+		if !status.InProgress {
+			t.Fatal("Expected the sync of vm-a to be in progress")
+		}
+		if status.QueuedSyncs == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected one queued sync but got %d", status.QueuedSyncs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(manager.release)
+	failed := 0
+	for range 3 {
+		if err := <-errs; err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Expected only the sync of vm-b to fail but %d failed", failed)
+	}
+
+	statusA, _ := engine.GetSyncStatus("vm-a")
+	if statusA.TotalSyncs != 2 || statusA.InProgress || statusA.QueuedSyncs != 0 {
+		t.Errorf("Expected two finished syncs of vm-a but got %+v", statusA)
+	}
+	// A failed sync is no longer in progress and reports its error
+	statusB, _ := engine.GetSyncStatus("vm-b")
+	if statusB.InProgress || statusB.Error == "" || statusB.TotalSyncs != 0 {
+		t.Errorf("Expected the failed sync of vm-b to be recorded but got %+v", statusB)
+	}
+}
+
+func TestSyncEngine_CancelQueuedSync(t *testing.T) {
+	manager := &blockingVMManager{started: make(chan string, 2), release: make(chan struct{})}
+	engine, _ := NewEngine()
+	engine.SetVMManager(manager)
+	if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: t.TempDir()}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := engine.SyncToVM(context.Background(), "test-vm", "")
+		first <- err
+	}()
+	select {
+	case <-manager.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the first sync to start")
+	}
+
+	// The second sync waits behind the first until its caller gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	second := make(chan error, 1)
+	go func() {
+		_, err := engine.SyncToVM(ctx, "test-vm", "")
+		second <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for status, _ := engine.GetSyncStatus("test-vm"); status.QueuedSyncs != 1; status, _ = engine.GetSyncStatus("test-vm") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected one queued sync but got %d", status.QueuedSyncs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-second:
+		if err != context.Canceled {
+			t.Errorf("Expected the queued sync to be cancelled but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the queued sync to return once cancelled")
+	}
+
+	// The cancelled sync is skipped rather than run after the first
+	close(manager.release)
+	if err := <-first; err != nil {
+		t.Fatalf("Expected the first sync to succeed but got %v", err)
+	}
+	if _, err := engine.SyncToVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected the next sync to succeed but got %v", err)
+	}
+	if len(manager.started) != 1 {
+		t.Errorf("Expected only the next sync to run but %d did", len(manager.started))
+	}
+	if status, _ := engine.GetSyncStatus("test-vm"); status.TotalSyncs != 2 || status.QueuedSyncs != 0 {
+		t.Errorf("Expected two finished syncs and none queued but got %+v", status)
+	}
+}
+
+func TestSyncEngine_ExcludePatterns(t *testing.T) {
+	manager := &recordingVMManager{}
+	engine, _ := NewEngine()
+	engine.SetVMManager(manager)
+	if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: t.TempDir(), ExcludePatterns: []string{"node_modules", "build/"}}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	// Vagrant's state directory is never synced, whichever way the sync goes
+	expected := []string{".vagrant", "node_modules", "build/"}
+	for _, sync := range []func(context.Context, string, string) (*SyncResult, error){engine.SyncToVM, engine.SyncFromVM} {
+		if _, err := sync(context.Background(), "test-vm", ""); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		manager.mu.Lock()
+		excludes := manager.excludes
+		manager.mu.Unlock()
+		if !slices.Equal(excludes, expected) {
+			t.Errorf("Expected the excludes %v but got %v", expected, excludes)
+		}
+	}
+}
+
+// mountCheckingVMManager records mount checks in place of copies for mounted sync methods
+type mountCheckingVMManager struct {
+	recordingVMManager
+	checks   int
+	checkErr error
+}
+
+func (m *mountCheckingVMManager) CheckSyncMount(name string) error {
+	m.checks++
+	return m.checkErr
+}
+
+func TestSyncEngine_MountedMethods(t *testing.T) {
+	for _, method := range []SyncMethod{SyncMethodNFS, SyncMethodSMB, SyncMethodVirtioFS, SyncMethod9P} {
+		manager := &mountCheckingVMManager{}
+		engine, _ := NewEngine()
+		engine.SetVMManager(manager)
+		if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: t.TempDir(), Method: method}); err != nil {
+			t.Fatalf("Failed to register VM: %v", err)
+		}
+
+		// Both sides see the same files, so syncs only check the mount
+		for _, sync := range []func(context.Context, string, string) (*SyncResult, error){engine.SyncToVM, engine.SyncFromVM} {
+			if result, err := sync(context.Background(), "test-vm", ""); err != nil || len(result.SyncedFiles) != 0 {
+				t.Fatalf("Expected an empty %s sync but got %+v, %v", method, result, err)
+			}
+		}
+		if _, err := engine.SyncPendingToVM(context.Background(), "test-vm"); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		if manager.checks != 3 || len(manager.toVM) != 0 || len(manager.fromVM) != 0 {
+			t.Errorf("Expected 3 mount checks and no copies for %s but got %d checks, %v, %v", method, manager.checks, manager.toVM, manager.fromVM)
+		}
+
+		manager.checkErr = fmt.Errorf("not mounted")
+		if _, err := engine.SyncToVM(context.Background(), "test-vm", ""); err == nil {
+			t.Errorf("Expected a failed mount check to fail the %s sync", method)
+		}
+		if method.PerformanceNote() == "" {
+			t.Errorf("Expected a performance note for %s", method)
+		}
+	}
+}
@@ -12,4 +12,6 @@ var (
 	ErrEngineAlreadyRunning = errors.New("sync engine already running")
 	ErrEngineNotRunning     = errors.New("sync engine not running")
 	ErrInvalidVMName        = errors.New("invalid vm name")
+	ErrInvalidProjectPath   = errors.New("project path is not a directory")
+	ErrWatchLimit           = errors.New("file watch limit reached")
 )
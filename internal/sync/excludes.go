@@ -0,0 +1,262 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Exclude suggestion categories
+const (
+	ExcludeCategoryVCS          = "vcs"
+	ExcludeCategoryDependencies = "dependencies"
+	ExcludeCategoryBuildOutput  = "build_output"
+	ExcludeCategoryCache        = "cache"
+	ExcludeCategoryArtifacts    = "artifacts"
+)
+
+// knownExcludeDirs maps well-known directory names to their category
+var knownExcludeDirs = map[string]string{
+	".git":             ExcludeCategoryVCS,
+	".hg":              ExcludeCategoryVCS,
+	".svn":             ExcludeCategoryVCS,
+	".vagrant":         ExcludeCategoryVCS,
+	"node_modules":     ExcludeCategoryDependencies,
+	"bower_components": ExcludeCategoryDependencies,
+	"vendor":           ExcludeCategoryDependencies,
+	"venv":             ExcludeCategoryDependencies,
+	".venv":            ExcludeCategoryDependencies,
+	"dist":             ExcludeCategoryBuildOutput,
+	"build":            ExcludeCategoryBuildOutput,
+	"target":           ExcludeCategoryBuildOutput,
+	"out":              ExcludeCategoryBuildOutput,
+	"obj":              ExcludeCategoryBuildOutput,
+	".next":            ExcludeCategoryBuildOutput,
+	".nuxt":            ExcludeCategoryBuildOutput,
+	"coverage":         ExcludeCategoryBuildOutput,
+	"__pycache__":      ExcludeCategoryCache,
+	".cache":           ExcludeCategoryCache,
+	".pytest_cache":    ExcludeCategoryCache,
+	".mypy_cache":      ExcludeCategoryCache,
+	".tox":             ExcludeCategoryCache,
+	".gradle":          ExcludeCategoryCache,
+	".terraform":       ExcludeCategoryCache,
+	".parcel-cache":    ExcludeCategoryCache,
+}
+
+// knownExcludeExtensions maps file extensions to their category
+var knownExcludeExtensions = map[string]string{
+	".log":   ExcludeCategoryArtifacts,
+	".pyc":   ExcludeCategoryArtifacts,
+	".o":     ExcludeCategoryArtifacts,
+	".out":   ExcludeCategoryArtifacts,
+	".class": ExcludeCategoryArtifacts,
+	".swp":   ExcludeCategoryArtifacts,
+}
+
+// DefaultLargeDirThreshold is the size above which a directory is reported as large
+const DefaultLargeDirThreshold int64 = 50 * 1024 * 1024
+
+// ExcludeSuggestion describes a recommended exclude pattern and what it would skip
+type ExcludeSuggestion struct {
+	Pattern           string   `json:"pattern"`
+	Category          string   `json:"category"`
+	SizeBytes         int64    `json:"size_bytes"`
+	FileCount         int      `json:"file_count"`
+	Paths             []string `json:"paths"`
+	AlreadyConfigured bool     `json:"already_configured"`
+}
+
+// LargeDirectory describes a directory that is large but not a known exclude candidate
+type LargeDirectory struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	FileCount int    `json:"file_count"`
+}
+
+// ExcludeReport is the result of scanning a project for exclude candidates
+type ExcludeReport struct {
+	ProjectPath               string              `json:"project_path"`
+	TotalSizeBytes            int64               `json:"total_size_bytes"`
+	TotalFiles                int                 `json:"total_files"`
+	Suggestions               []ExcludeSuggestion `json:"suggestions"`
+	RecommendedPatterns       []string            `json:"recommended_patterns"`
+	ExcludedSizeBytes         int64               `json:"excluded_size_bytes"`
+	RemainingSizeBytes        int64               `json:"remaining_size_bytes"`
+	EstimatedReductionPercent float64             `json:"estimated_reduction_percent"`
+	LargeDirectories          []LargeDirectory    `json:"large_directories"`
+}
+
+// excludeScanner accumulates scan state for SuggestExcludePatterns
+type excludeScanner struct {
+	root        string
+	threshold   int64
+	suggestions map[string]*ExcludeSuggestion
+	large       []LargeDirectory
+}
+
+// SuggestExcludePatterns scans a project and recommends exclude patterns with size estimates.
+// Patterns in existing are flagged as already configured.
+func SuggestExcludePatterns(projectPath string, existing []string, largeDirThreshold int64) (*ExcludeReport, error) {
+	info, err := os.Stat(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, ErrInvalidProjectPath
+	}
+	if largeDirThreshold <= 0 {
+		largeDirThreshold = DefaultLargeDirThreshold
+	}
+
+	scanner := &excludeScanner{
+		root:        projectPath,
+		threshold:   largeDirThreshold,
+		suggestions: make(map[string]*ExcludeSuggestion),
+	}
+	totalSize, totalFiles := scanner.scan(projectPath, 0)
+
+	report := &ExcludeReport{
+		ProjectPath:         projectPath,
+		TotalSizeBytes:      totalSize,
+		TotalFiles:          totalFiles,
+		Suggestions:         []ExcludeSuggestion{},
+		RecommendedPatterns: []string{},
+		LargeDirectories:    scanner.large,
+	}
+	if report.LargeDirectories == nil {
+		report.LargeDirectories = []LargeDirectory{}
+	}
+
+	configured := make(map[string]bool)
+	for _, pattern := range existing {
+		configured[strings.TrimSuffix(pattern, "/")] = true
+	}
+
+	for _, suggestion := range scanner.suggestions {
+		suggestion.AlreadyConfigured = configured[suggestion.Pattern]
+		report.Suggestions = append(report.Suggestions, *suggestion)
+		report.ExcludedSizeBytes += suggestion.SizeBytes
+	}
+	sort.Slice(report.Suggestions, func(i, j int) bool {
+		if report.Suggestions[i].SizeBytes != report.Suggestions[j].SizeBytes {
+			return report.Suggestions[i].SizeBytes > report.Suggestions[j].SizeBytes
+		}
+		return report.Suggestions[i].Pattern < report.Suggestions[j].Pattern
+	})
+	for _, suggestion := range report.Suggestions {
+		report.RecommendedPatterns = append(report.RecommendedPatterns, suggestion.Pattern)
+	}
+	sort.Slice(report.LargeDirectories, func(i, j int) bool {
+		return report.LargeDirectories[i].SizeBytes > report.LargeDirectories[j].SizeBytes
+	})
+
+	report.RemainingSizeBytes = report.TotalSizeBytes - report.ExcludedSizeBytes
+	if report.TotalSizeBytes > 0 {
+		report.EstimatedReductionPercent = float64(report.ExcludedSizeBytes) * 100 / float64(report.TotalSizeBytes)
+	}
+
+	return report, nil
+}
+
+// scan walks a directory, recording exclude candidates, and returns its total size and file count
+func (s *excludeScanner) scan(dir string, depth int) (int64, int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0
+	}
+
+	var size int64
+	files := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.Type()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		if entry.IsDir() {
+			if category, ok := knownExcludeDirs[entry.Name()]; ok {
+				dirSize, dirFiles := directorySize(path)
+				s.record(entry.Name(), category, path, dirSize, dirFiles)
+				size += dirSize
+				files += dirFiles
+				continue
+			}
+
+			dirSize, dirFiles := s.scan(path, depth+1)
+			size += dirSize
+			files += dirFiles
+			if depth < 2 && dirSize >= s.threshold {
+				s.large = append(s.large, LargeDirectory{
+					Path:      s.relative(path),
+					SizeBytes: dirSize,
+					FileCount: dirFiles,
+				})
+			}
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size += info.Size()
+		files++
+		if category, ok := knownExcludeExtensions[filepath.Ext(entry.Name())]; ok {
+			s.record("*"+filepath.Ext(entry.Name()), category, path, info.Size(), 1)
+		}
+	}
+	return size, files
+}
+
+// record adds a matched path to the suggestion for pattern
+func (s *excludeScanner) record(pattern, category, path string, size int64, files int) {
+	suggestion, ok := s.suggestions[pattern]
+	if !ok {
+		suggestion = &ExcludeSuggestion{
+			Pattern:  pattern,
+			Category: category,
+			Paths:    []string{},
+		}
+		s.suggestions[pattern] = suggestion
+	}
+	suggestion.SizeBytes += size
+	suggestion.FileCount += files
+	// Keep the path list short for patterns that match many files
+	if len(suggestion.Paths) < 10 {
+		suggestion.Paths = append(suggestion.Paths, s.relative(path))
+	}
+}
+
+// relative returns path relative to the scan root
+func (s *excludeScanner) relative(path string) string {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// directorySize returns the total size and file count under a directory
+func directorySize(dir string) (int64, int) {
+	var size int64
+	files := 0
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() || d.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSuggestExcludePatterns(t *testing.T) {
+	testCases := []struct {
+		name             string
+		files            map[string]int
+		existing         []string
+		expectedPatterns []string
+		expectedExcluded int64
+		configured       string
+	}{
+		{
+			name: "detects known directories and artifacts",
+			files: map[string]int{
+				"main.go":                 100,
+				".git/objects/abc":        300,
+				"web/node_modules/a/b.js": 500,
+				"logs/app.log":            50,
+			},
+			expectedPatterns: []string{"node_modules", ".git", "*.log"},
+			expectedExcluded: 850,
+		},
+		{
+			name: "flags already configured patterns",
+			files: map[string]int{
+				"src/app.py":            100,
+				"src/__pycache__/a.pyc": 20,
+			},
+			existing:         []string{"__pycache__/"},
+			expectedPatterns: []string{"__pycache__"},
+			expectedExcluded: 20,
+			configured:       "__pycache__",
+		},
+		{
+			name: "nothing to exclude",
+			files: map[string]int{
+				"README.md": 10,
+			},
+			expectedPatterns: []string{},
+			expectedExcluded: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for path, size := range tc.files {
+				fullPath := filepath.Join(root, path)
+				if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+					t.Fatalf("Failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(fullPath, make([]byte, size), 0644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+			}
+
+			report, err := SuggestExcludePatterns(root, tc.existing, 0)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+
+			if len(report.RecommendedPatterns) != len(tc.expectedPatterns) {
+				t.Fatalf("Expected patterns %v but got %v", tc.expectedPatterns, report.RecommendedPatterns)
+			}
+			for i, pattern := range tc.expectedPatterns {
+				if report.RecommendedPatterns[i] != pattern {
+					t.Errorf("Expected pattern %q at position %d but got %q", pattern, i, report.RecommendedPatterns[i])
+				}
+			}
+			if report.ExcludedSizeBytes != tc.expectedExcluded {
+				t.Errorf("Expected %d excluded bytes but got %d", tc.expectedExcluded, report.ExcludedSizeBytes)
+			}
+
+			for _, suggestion := range report.Suggestions {
+				if suggestion.AlreadyConfigured != (suggestion.Pattern == tc.configured) {
+					t.Errorf("Unexpected already_configured=%v for pattern %q", suggestion.AlreadyConfigured, suggestion.Pattern)
+				}
+			}
+		})
+	}
+}
+
+func TestSuggestExcludePatterns_InvalidPath(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := SuggestExcludePatterns(file, nil, 0); err != ErrInvalidProjectPath {
+		t.Errorf("Expected error %v but got %v", ErrInvalidProjectPath, err)
+	}
+}
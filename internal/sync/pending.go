@@ -0,0 +1,260 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/hostos"
+)
+
+// pendingChanges tracks the files of a project that changed on one side and were not synced to
+// the other yet, keyed by slash separated path relative to the project. The watcher records
+// host changes and guest scans record VM changes.
+type pendingChanges struct {
+	mu sync.Mutex
+	// upload maps changed host paths to when they changed
+	upload map[string]time.Time
+	// uploadOverflow is when more host changes were seen than are tracked; only a sync of the
+	// whole project that starts later clears it
+	uploadOverflow time.Time
+	download       map[string]bool
+	// downloadTruncated is set when a guest scan found more changes than are tracked
+	downloadTruncated bool
+	scannedAt         time.Time
+}
+
+// newPendingChanges creates an empty tracker
+func newPendingChanges() *pendingChanges {
+	return &pendingChanges{upload: make(map[string]time.Time), download: make(map[string]bool)}
+}
+
+// changed records a change of a host path under root seen at the given time
+func (p *pendingChanges) changed(root, path string, at time.Time) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, tracked := p.upload[filepath.ToSlash(rel)]; !tracked && len(p.upload) >= maxPendingChanges {
+		p.uploadOverflow = at
+		return
+	}
+	p.upload[filepath.ToSlash(rel)] = at
+}
+
+// overflowed records that host changes were lost at the given time
+func (p *pendingChanges) overflowed(at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.uploadOverflow = at
+}
+
+// uploaded forgets the host changes at or below the synced paths under root that were made
+// before the sync started
+func (p *pendingChanges) uploaded(root string, synced []string, start time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, path := range synced {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." && !p.uploadOverflow.IsZero() && p.uploadOverflow.Before(start) {
+			p.uploadOverflow = time.Time{}
+		}
+		for changed, at := range p.upload {
+			if at.Before(start) && (rel == "." || changed == rel || strings.HasPrefix(changed, rel+"/")) {
+				delete(p.upload, changed)
+			}
+		}
+	}
+}
+
+// downloaded forgets the VM changes found by scans made before a sync from the VM started
+func (p *pendingChanges) downloaded(start time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.scannedAt.Before(start) {
+		p.download = make(map[string]bool)
+		p.downloadTruncated = false
+	}
+}
+
+// scanned replaces the VM changes with those found by a guest scan made at the given time
+func (p *pendingChanges) scanned(paths []string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.download = make(map[string]bool)
+	p.downloadTruncated = len(paths) > maxPendingChanges
+	for _, path := range paths {
+		if len(p.download) == maxPendingChanges {
+			break
+		}
+		p.download[path] = true
+	}
+	p.scannedAt = at
+}
+
+// uploads returns the sorted host changes, and whether more changed than are tracked
+func (p *pendingChanges) uploads() ([]string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	paths := make([]string, 0, len(p.upload))
+	for path := range p.upload {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, !p.uploadOverflow.IsZero()
+}
+
+// fill sets the pending file fields of a status
+func (p *pendingChanges) fill(status *SyncStatus) {
+	status.FilesPendingUpload, status.PendingUploadTruncated = p.uploads()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status.FilesPendingDownload = make([]string, 0, len(p.download))
+	for path := range p.download {
+		status.FilesPendingDownload = append(status.FilesPendingDownload, path)
+	}
+	sort.Strings(status.FilesPendingDownload)
+	status.PendingDownloadTruncated = p.downloadTruncated
+	status.LastGuestScan = p.scannedAt
+}
+
+// RecordGuestChanges records the files a scan of the VM made at scannedAt found changed since
+// the last sync, as paths relative to the project. A scan older than the last sync from the VM
+// is ignored.
+func (e *Engine) RecordGuestChanges(vmName string, paths []string, scannedAt time.Time) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if vmName == "" {
+		return ErrInvalidVMName
+	}
+	if _, exists := e.configs[vmName]; !exists {
+		return ErrVMNotRegistered
+	}
+	if scannedAt.Before(e.statuses[vmName].LastSyncFromVM) {
+		return nil
+	}
+	e.pending[vmName].scanned(paths, scannedAt)
+	return nil
+}
+
+// SyncPendingToVM syncs the host files changed since they were last synced to the VM, and
+// nothing when none changed. Without a watcher tracking the changes, or when more changed than
+// are tracked, the whole project is synced. Projects mounted in the VM only have their mount
+// checked.
+func (e *Engine) SyncPendingToVM(ctx context.Context, vmName string) (*SyncResult, error) {
+	var result *SyncResult
+	err := e.runQueued(ctx, vmName, func() error {
+		var err error
+		result, err = e.syncPending(vmName)
+		return err
+	})
+	if result != nil {
+		e.recordConflicts(vmName, result.detected)
+	}
+	return result, err
+}
+
+// syncPending syncs the pending host changes of a VM; it runs on the VM's sync worker
+func (e *Engine) syncPending(vmName string) (*SyncResult, error) {
+	e.mu.RLock()
+	_, watched := e.watchers[vmName]
+	pending := e.pending[vmName]
+	mounted := e.configs[vmName].Method.Mounted()
+	e.mu.RUnlock()
+	if pending == nil {
+		return nil, ErrVMNotRegistered
+	}
+	paths, truncated := pending.uploads()
+	if !watched || truncated || mounted {
+		return e.syncProject(vmName, "", true)
+	}
+	if len(paths) == 0 {
+		return &SyncResult{SyncedFiles: []string{}}, nil
+	}
+
+	target, err := e.beginSync(vmName)
+	if err != nil {
+		return nil, err
+	}
+	root := target.config.ProjectPath
+	batch := newChangeBatch()
+	for _, path := range paths {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if _, err := os.Lstat(path); err == nil {
+			batch.changed[path] = true
+		} else {
+			batch.removed[path] = true
+		}
+	}
+	dirs, files := batch.plan(root)
+
+	startTime := time.Now()
+	synced, conflicts, err := e.syncPlan(target, dirs, files)
+	syncTimeMs := int(time.Since(startTime).Milliseconds())
+	if err != nil {
+		e.finishSync(vmName, true, len(synced), syncTimeMs, err, "")
+		return nil, errors.OperationFailed("sync changed files to VM", err)
+	}
+	e.finishSync(vmName, true, len(synced), syncTimeMs, nil, fmt.Sprintf("%d changed paths synced", len(paths)))
+	pending.uploaded(root, synced, startTime)
+	return &SyncResult{SyncedFiles: synced, SyncTimeMs: syncTimeMs, Conflicts: conflictPaths(conflicts), detected: conflicts}, nil
+}
+
+// syncPlan syncs whole directories and then single files under the project to the VM, and
+// returns those synced until an error stopped it. The synced paths are recorded in the shadow
+// copy. Files also changed in the VM since the last sync are left alone and returned as
+// conflicts.
+func (e *Engine) syncPlan(target syncTarget, dirs, files []string) ([]string, []SyncConflict, error) {
+	if target.vmManager == nil {
+		return nil, nil, errors.OperationFailed("VM manager not set before sync operations", nil)
+	}
+	root := target.config.ProjectPath
+	var conflicts []SyncConflict
+	if target.detectsConflicts() {
+		conflicts = e.detectConflicts(&target, target.shadow.changed(root, append(append([]string{}, dirs...), files...)))
+	}
+	var synced []string
+	defer func() { e.updateShadow(target, synced) }()
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			continue
+		}
+		if err := target.vmManager.SyncToVM(target.vmName, dir, hostos.GuestPath("/vagrant", rel), target.excludesFrom(dir)); err != nil {
+			return synced, conflicts, err
+		}
+		synced = append(synced, dir)
+	}
+	var unconflicted []string
+	for _, file := range files {
+		if !slices.Contains(target.conflicted, file) {
+			unconflicted = append(unconflicted, file)
+		}
+	}
+	if len(unconflicted) > 0 {
+		syncedFiles, err := e.syncFilesToVM(target, unconflicted)
+		synced = append(synced, syncedFiles...)
+		if err != nil {
+			return synced, conflicts, err
+		}
+	}
+	return synced, conflicts, nil
+}
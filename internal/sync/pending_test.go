@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPendingChanges(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "project")
+	start := time.Now()
+	pending := newPendingChanges()
+	pending.changed(root, filepath.Join(root, "src", "main.go"), start.Add(-time.Second))
+	pending.changed(root, filepath.Join(root, "src", "util", "util.go"), start.Add(-time.Second))
+	pending.changed(root, filepath.Join(root, "README.md"), start.Add(time.Second))
+	pending.changed(root, filepath.Join(filepath.Dir(root), "other.go"), start)
+
+	var status SyncStatus
+	pending.fill(&status)
+	if !slices.Equal(status.FilesPendingUpload, []string{"README.md", "src/main.go", "src/util/util.go"}) {
+		t.Fatalf("Expected the changes under the project but got %v", status.FilesPendingUpload)
+	}
+
+	// Syncing a directory only clears what changed below it before the sync started
+	pending.uploaded(root, []string{filepath.Join(root, "src")}, start)
+	pending.uploaded(root, []string{filepath.Join(root, "README.md")}, start)
+	pending.fill(&status)
+	if !slices.Equal(status.FilesPendingUpload, []string{"README.md"}) {
+		t.Errorf("Expected README.md to stay pending but got %v", status.FilesPendingUpload)
+	}
+
+	// Past the limit the list is truncated until the whole project is synced
+	for i := range maxPendingChanges {
+		pending.changed(root, filepath.Join(root, fmt.Sprintf("file%d", i)), start)
+	}
+	pending.fill(&status)
+	if !status.PendingUploadTruncated || len(status.FilesPendingUpload) != maxPendingChanges {
+		t.Errorf("Expected %d truncated pending files but got %d, %v", maxPendingChanges, len(status.FilesPendingUpload), status.PendingUploadTruncated)
+	}
+	pending.uploaded(root, []string{root}, start.Add(2*time.Second))
+	pending.fill(&status)
+	if status.PendingUploadTruncated || len(status.FilesPendingUpload) != 0 {
+		t.Errorf("Expected a sync of the project to clear every pending file but got %d, %v", len(status.FilesPendingUpload), status.PendingUploadTruncated)
+	}
+
+	// Guest scans replace the pending downloads, which a later sync from the VM clears
+	pending.scanned([]string{"build/app", "log/dev.log"}, start)
+	pending.fill(&status)
+	if !slices.Equal(status.FilesPendingDownload, []string{"build/app", "log/dev.log"}) || !status.LastGuestScan.Equal(start) {
+		t.Errorf("Expected the scanned files to be pending download but got %v at %v", status.FilesPendingDownload, status.LastGuestScan)
+	}
+	pending.downloaded(start.Add(-time.Second))
+	pending.fill(&status)
+	if len(status.FilesPendingDownload) != 2 {
+		t.Errorf("Expected a sync started before the scan to keep its files but got %v", status.FilesPendingDownload)
+	}
+	pending.downloaded(start.Add(time.Second))
+	pending.fill(&status)
+	if len(status.FilesPendingDownload) != 0 {
+		t.Errorf("Expected no pending downloads but got %v", status.FilesPendingDownload)
+	}
+}
+
+func TestSyncEngine_PendingChanges(t *testing.T) {
+	root := t.TempDir()
+	engine, _ := NewEngine()
+	manager := &recordingVMManager{}
+	engine.SetVMManager(manager)
+	if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: root}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+	engine.pending["test-vm"].changed(root, filepath.Join(root, "main.go"), time.Now().Add(-time.Second))
+	if err := engine.RecordGuestChanges("test-vm", []string{"out.log"}, time.Now()); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	status, _ := engine.GetSyncStatus("test-vm")
+	if !slices.Equal(status.FilesPendingUpload, []string{"main.go"}) || !slices.Equal(status.FilesPendingDownload, []string{"out.log"}) {
+		t.Fatalf("Expected main.go to upload and out.log to download but got %v and %v", status.FilesPendingUpload, status.FilesPendingDownload)
+	}
+
+	if _, err := engine.SyncToVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, err := engine.SyncFromVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	// Without a path, the VM's files are synced into the project
+	manager.mu.Lock()
+	fromVM := manager.fromVM
+	manager.mu.Unlock()
+	if !slices.Equal(fromVM, []string{root}) {
+		t.Errorf("Expected the sync from the VM to target %s but got %v", root, fromVM)
+	}
+	status, _ = engine.GetSyncStatus("test-vm")
+	if len(status.FilesPendingUpload) != 0 || len(status.FilesPendingDownload) != 0 {
+		t.Errorf("Expected nothing pending after syncing both ways but got %v and %v", status.FilesPendingUpload, status.FilesPendingDownload)
+	}
+
+	// A scan made before the last sync from the VM is out of date
+	if err := engine.RecordGuestChanges("test-vm", []string{"old.log"}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if status, _ = engine.GetSyncStatus("test-vm"); len(status.FilesPendingDownload) != 0 {
+		t.Errorf("Expected an old scan to be ignored but got %v", status.FilesPendingDownload)
+	}
+}
+
+func TestSyncEngine_SyncPendingToVM(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	manager := &recordingVMManager{}
+	engine, _ := NewEngine()
+	engine.SetVMManager(manager)
+	// The watcher polls too slowly to sync anything itself
+	config := SyncConfig{ProjectPath: root, WatchEnabled: true, WatchMode: WatchModePoll, WatchInterval: time.Hour}
+	if err := engine.RegisterVM("watched", config); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+	defer func() { _ = engine.UnregisterVM("watched") }()
+	if err := engine.RegisterVM("unwatched", SyncConfig{ProjectPath: root}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	// A clean project is not synced
+	result, err := engine.SyncPendingToVM(context.Background(), "watched")
+	if err != nil || len(result.SyncedFiles) != 0 || len(manager.syncedToVM()) != 0 {
+		t.Fatalf("Expected nothing to be synced but got %v, %v", result, err)
+	}
+
+	// Only the changed file and the parent of the removed one are synced
+	changed := time.Now().Add(-time.Second)
+	engine.pending["watched"].changed(root, filepath.Join(root, "main.go"), changed)
+	engine.pending["watched"].changed(root, filepath.Join(root, "src", "old.go"), changed)
+	result, err = engine.SyncPendingToVM(context.Background(), "watched")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	expected := []string{filepath.Join(root, "src"), filepath.Join(root, "main.go")}
+	if !slices.Equal(result.SyncedFiles, expected) || !slices.Equal(manager.syncedToVM(), expected) {
+		t.Errorf("Expected %v to be synced but got %v", expected, manager.syncedToVM())
+	}
+	if status, _ := engine.GetSyncStatus("watched"); len(status.FilesPendingUpload) != 0 || status.TotalSyncs != 1 {
+		t.Errorf("Expected one sync and nothing pending but got %+v", status)
+	}
+
+	// Without a watcher the changes are unknown, so the whole project is synced
+	if _, err := engine.SyncPendingToVM(context.Background(), "unwatched"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if synced := manager.syncedToVM(); synced[len(synced)-1] != root {
+		t.Errorf("Expected the project to be synced but got %v", synced)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// minPollInterval keeps polling of large trees from running back to back
+const minPollInterval = 2 * time.Second
+
+// pollEntry is what the poller remembers about a path
+type pollEntry struct {
+	modTime time.Time
+	size    int64
+	dir     bool
+}
+
+// pollSnapshot maps the paths under the polled directories to their state
+type pollSnapshot map[string]pollEntry
+
+// scanPollRoots records the files and directories under roots that are not excluded
+func scanPollRoots(projectPath string, roots []string, patterns []string) pollSnapshot {
+	snapshot := make(pollSnapshot)
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// Vanished while scanning; the next scan reports it
+				return nil
+			}
+			if isExcludedPath(projectPath, path, patterns) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+			snapshot[path] = pollEntry{modTime: info.ModTime(), size: info.Size(), dir: entry.IsDir()}
+			return nil
+		})
+	}
+	return snapshot
+}
+
+// changesSince returns the events that turn previous into s. Directories only report being
+// created or removed, since their modification time changes with every file inside them.
+func (s pollSnapshot) changesSince(previous pollSnapshot) []fsnotify.Event {
+	var changes []fsnotify.Event
+	for path, entry := range s {
+		old, existed := previous[path]
+		switch {
+		case !existed:
+			changes = append(changes, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case !entry.dir && (!entry.modTime.Equal(old.modTime) || entry.size != old.size):
+			changes = append(changes, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for path := range previous {
+		if _, exists := s[path]; !exists {
+			changes = append(changes, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// pollInterval returns how often polled directories are scanned
+func pollInterval(watchInterval time.Duration) time.Duration {
+	if watchInterval < minPollInterval {
+		return minPollInterval
+	}
+	return watchInterval
+}
@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestPollSnapshot_ChangesSince(t *testing.T) {
+	now := time.Now()
+	previous := pollSnapshot{
+		"/p/src":       {modTime: now, dir: true},
+		"/p/src/a.go":  {modTime: now, size: 10},
+		"/p/src/b.go":  {modTime: now, size: 10},
+		"/p/src/c.go":  {modTime: now, size: 10},
+		"/p/old":       {modTime: now, dir: true},
+		"/p/old/d.txt": {modTime: now, size: 1},
+	}
+	current := pollSnapshot{
+		"/p/src":      {modTime: now.Add(time.Second), dir: true},
+		"/p/src/a.go": {modTime: now, size: 10},
+		"/p/src/b.go": {modTime: now.Add(time.Second), size: 10},
+		"/p/src/c.go": {modTime: now, size: 12},
+		"/p/src/e.go": {modTime: now, size: 1},
+	}
+
+	expected := []fsnotify.Event{
+		{Name: "/p/old", Op: fsnotify.Remove},
+		{Name: "/p/old/d.txt", Op: fsnotify.Remove},
+		{Name: "/p/src/b.go", Op: fsnotify.Write},
+		{Name: "/p/src/c.go", Op: fsnotify.Write},
+		{Name: "/p/src/e.go", Op: fsnotify.Create},
+	}
+	changes := current.changesSince(previous)
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes but got %d: %v", len(expected), len(changes), changes)
+	}
+	for i, change := range changes {
+		if change != expected[i] {
+			t.Errorf("Expected change %v but got %v", expected[i], change)
+		}
+	}
+}
+
+func TestScanPollRoots(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src", "node_modules/pkg"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	for _, file := range []string{"src/main.go", "debug.log", "node_modules/pkg/index.js"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	snapshot := scanPollRoots(root, []string{root}, []string{"node_modules", "*.log"})
+	for _, path := range []string{root, filepath.Join(root, "src"), filepath.Join(root, "src/main.go")} {
+		if _, exists := snapshot[path]; !exists {
+			t.Errorf("Expected %s in snapshot", path)
+		}
+	}
+	for _, path := range []string{filepath.Join(root, "debug.log"), filepath.Join(root, "node_modules")} {
+		if _, exists := snapshot[path]; exists {
+			t.Errorf("Expected %s to be excluded from snapshot", path)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/hostos"
+)
+
+// SyncProgress is how far the copy of a sync in progress got, as rsync reports it
+type SyncProgress struct {
+	Percent          int    `json:"percent"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+	Rate             string `json:"rate,omitempty"`
+	// ETASeconds is the estimated time left
+	ETASeconds int `json:"eta_seconds"`
+	// FilesToCheck of TotalFiles are still to be compared with the other side
+	FilesTransferred int       `json:"files_transferred"`
+	FilesToCheck     int       `json:"files_to_check"`
+	TotalFiles       int       `json:"total_files"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ProgressVMManager is a VM manager reporting the progress of the copies of syncs, so the
+// first sync of a large project does not look hung
+type ProgressVMManager interface {
+	SyncToVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error
+	SyncFromVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error
+}
+
+// OnSyncProgress calls listener with each progress report of the syncs of a VM until the
+// returned function is called
+func (e *Engine) OnSyncProgress(vmName string, listener func(SyncProgress)) func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.progressListeners == nil {
+		e.progressListeners = make(map[string]map[int]func(SyncProgress))
+	}
+	if e.progressListeners[vmName] == nil {
+		e.progressListeners[vmName] = make(map[int]func(SyncProgress))
+	}
+	e.nextListener++
+	id := e.nextListener
+	e.progressListeners[vmName][id] = listener
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.progressListeners[vmName], id)
+		if len(e.progressListeners[vmName]) == 0 {
+			delete(e.progressListeners, vmName)
+		}
+	}
+}
+
+// reportProgress records a progress report of the sync in progress of a VM in its status and
+// passes it to the progress listeners of the VM
+func (e *Engine) reportProgress(vmName string, report hostos.RsyncProgress) {
+	progress := SyncProgress{
+		Percent:          report.Percent,
+		BytesTransferred: report.BytesTransferred,
+		Rate:             report.Rate,
+		ETASeconds:       int(report.ETA / time.Second),
+		FilesTransferred: report.FilesTransferred,
+		FilesToCheck:     report.FilesToCheck,
+		TotalFiles:       report.TotalFiles,
+		UpdatedAt:        time.Now(),
+	}
+
+	e.mu.Lock()
+	status, exists := e.statuses[vmName]
+	if !exists || !status.InProgress {
+		e.mu.Unlock()
+		return
+	}
+	status.Progress = &progress
+	e.statuses[vmName] = status
+	listeners := make([]func(SyncProgress), 0, len(e.progressListeners[vmName]))
+	for _, listener := range e.progressListeners[vmName] {
+		listeners = append(listeners, listener)
+	}
+	e.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(progress)
+	}
+}
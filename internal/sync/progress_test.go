@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/hostos"
+)
+
+// progressVMManager reports the progress of its copies and records the sync status the engine
+// reported while they ran
+type progressVMManager struct {
+	recordingVMManager
+	engine *Engine
+	status SyncStatus
+}
+
+func (m *progressVMManager) SyncToVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error {
+	progress(hostos.RsyncProgress{BytesTransferred: 4096, Percent: 40, Rate: "1.00MB/s", ETA: 90 * time.Second, FilesToCheck: 6, TotalFiles: 10})
+	m.status, _ = m.engine.GetSyncStatus(name)
+	progress(hostos.RsyncProgress{BytesTransferred: 10240, Percent: 100, Rate: "1.00MB/s", TotalFiles: 10})
+	return m.SyncToVM(name, source, target, excludes)
+}
+
+func (m *progressVMManager) SyncFromVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error {
+	return m.SyncFromVM(name, source, target, excludes)
+}
+
+func TestSyncEngine_Progress(t *testing.T) {
+	engine, _ := NewEngine()
+	manager := &progressVMManager{engine: engine}
+	engine.SetVMManager(manager)
+	if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: t.TempDir(), Method: SyncMethodRsync}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	var percents []int
+	stop := engine.OnSyncProgress("test-vm", func(progress SyncProgress) {
+		percents = append(percents, progress.Percent)
+	})
+	if _, err := engine.SyncToVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	stop()
+
+	progress := manager.status.Progress
+	if !manager.status.InProgress || progress == nil || progress.Percent != 40 || progress.ETASeconds != 90 || progress.FilesToCheck != 6 {
+		t.Errorf("Expected 40%% progress with 90s left in the status of the sync but got %+v", manager.status)
+	}
+	if len(percents) != 2 || percents[1] != 100 {
+		t.Errorf("Expected the listener to get 40%% and 100%% but got %v", percents)
+	}
+
+	// Finished syncs and stopped listeners report nothing
+	status, _ := engine.GetSyncStatus("test-vm")
+	if status.Progress != nil {
+		t.Errorf("Expected no progress after the sync but got %+v", status.Progress)
+	}
+	if _, err := engine.SyncToVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if len(percents) != 2 {
+		t.Errorf("Expected no progress after the listener stopped but got %v", percents)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// maxQueuedSyncs is how many syncs of a VM can wait for the one in progress before callers
+// block on queueing theirs
+const maxQueuedSyncs = 16
+
+// syncTarget is what a sync needs to know about its VM, copied from the engine so the sync
+// can run without holding the engine lock
+type syncTarget struct {
+	vmName    string
+	config    SyncConfig
+	vmManager VMManager
+	pending   *pendingChanges
+	// shadow is nil when the VM manager keeps no VM directories
+	shadow *shadowCopy
+	// conflicted are the host paths of the conflicts not resolved yet, which syncs leave alone
+	conflicted []string
+}
+
+// syncWorker runs the syncs of one VM in the order they were queued, one at a time. Each VM
+// has its own worker, so VMs sync independently of each other.
+type syncWorker struct {
+	jobs chan func()
+	// queued counts the jobs waiting to run
+	queued  atomic.Int32
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// newSyncWorker starts a sync worker
+func newSyncWorker() *syncWorker {
+	w := &syncWorker{
+		jobs:    make(chan func(), maxQueuedSyncs),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// run runs queued jobs until the worker is closed
+func (w *syncWorker) run() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case job := <-w.jobs:
+			w.queued.Add(-1)
+			job()
+		}
+	}
+}
+
+// do queues a job and waits for it to run. It returns ErrVMNotRegistered when the worker is
+// closed before the job runs, and the context's error when ctx is done first; a job still
+// queued then is skipped, while one already running is left to finish.
+func (w *syncWorker) do(ctx context.Context, job func()) error {
+	finished := make(chan struct{})
+	// skipped is set before finished is closed when ctx was done before the job's turn came
+	skipped := false
+	w.queued.Add(1)
+	select {
+	case w.jobs <- func() {
+		defer close(finished)
+		if skipped = ctx.Err() != nil; !skipped {
+			job()
+		}
+	}:
+	case <-w.stop:
+		w.queued.Add(-1)
+		return ErrVMNotRegistered
+	case <-ctx.Done():
+		w.queued.Add(-1)
+		return ctx.Err()
+	}
+
+	select {
+	case <-finished:
+		if skipped {
+			return ctx.Err()
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.stopped:
+		// The worker finishes the job it is running before it stops
+		select {
+		case <-finished:
+			if skipped {
+				return ctx.Err()
+			}
+			return nil
+		default:
+			return ErrVMNotRegistered
+		}
+	}
+}
+
+// pending returns how many jobs are waiting to run
+func (w *syncWorker) pending() int {
+	return int(w.queued.Load())
+}
+
+// close stops the worker once its current job finishes. Jobs still queued are dropped.
+func (w *syncWorker) close() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// runQueued runs job on the sync worker of a VM and waits for it to finish, or until ctx is
+// done
+func (e *Engine) runQueued(ctx context.Context, vmName string, job func() error) error {
+	if vmName == "" {
+		return ErrInvalidVMName
+	}
+	e.mu.RLock()
+	worker, exists := e.workers[vmName]
+	e.mu.RUnlock()
+	if !exists {
+		return ErrVMNotRegistered
+	}
+
+	var jobErr error
+	if err := worker.do(ctx, func() { jobErr = job() }); err != nil {
+		return err
+	}
+	return jobErr
+}
+
+// syncTargetFor returns the sync target of a registered VM
+func (e *Engine) syncTargetFor(vmName string) (syncTarget, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.syncTargetLocked(vmName)
+}
+
+// syncTargetLocked returns the sync target of a registered VM; the caller must hold e.mu
+func (e *Engine) syncTargetLocked(vmName string) (syncTarget, error) {
+	config, exists := e.configs[vmName]
+	if !exists {
+		return syncTarget{}, ErrVMNotRegistered
+	}
+	target := syncTarget{vmName: vmName, config: config, vmManager: e.vmManager, pending: e.pending[vmName]}
+	if e.vmManager != nil && e.vmManager.GetBaseDir() != "" {
+		target.shadow = newShadowCopy(filepath.Join(e.vmManager.GetBaseDir(), vmName, shadowDirName))
+	}
+	for _, conflict := range e.statuses[vmName].Conflicts {
+		target.conflicted = append(target.conflicted, conflict.Path)
+	}
+	return target, nil
+}
@@ -0,0 +1,244 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// shadowDirName is the directory under a VM's directory holding the shadow copy of its project
+const shadowDirName = "sync-shadow"
+
+// maxShadowFileBytes bounds the files kept in the shadow copy; larger files are not merged
+const maxShadowFileBytes = 1 << 20
+
+// ConflictStyle selects the conflict markers a merge leaves in a file it cannot merge cleanly
+type ConflictStyle string
+
+const (
+	// ConflictStyleMerge shows the host and VM versions of each conflicting hunk
+	ConflictStyleMerge ConflictStyle = "merge"
+	// ConflictStyleDiff3 also shows the version of the last sync between them
+	ConflictStyleDiff3 ConflictStyle = "diff3"
+	// ConflictStyleZdiff3 is diff3 with the lines common to both sides moved out of the markers
+	ConflictStyleZdiff3 ConflictStyle = "zdiff3"
+)
+
+// ValidateConflictStyle checks that a conflict marker style is known; "" is the merge style
+func ValidateConflictStyle(style ConflictStyle) error {
+	switch style {
+	case "", ConflictStyleMerge, ConflictStyleDiff3, ConflictStyleZdiff3:
+		return nil
+	default:
+		return fmt.Errorf("invalid conflict style: %s (must be 'merge', 'diff3', or 'zdiff3')", style)
+	}
+}
+
+// shadowCopy keeps the files of a project as they were at the last successful sync, which is
+// the common base of the host and VM versions of a file changed on both sides since
+type shadowCopy struct {
+	dir string
+}
+
+// newShadowCopy returns the shadow copy kept in dir
+func newShadowCopy(dir string) *shadowCopy {
+	return &shadowCopy{dir: dir}
+}
+
+// shadowPath returns where the shadow copy keeps a host path under root, or "" for paths
+// outside root
+func (s *shadowCopy) shadowPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.Join(s.dir, rel)
+}
+
+// update copies the files at or below the synced host paths under root into the shadow copy,
+// and removes the copies of files that are gone, excluded or too large. Files whose size and
+// modification time did not change are not copied again. The copies of the held files are kept
+// as they are: they are the base of the merge of conflicts not resolved yet.
+func (s *shadowCopy) update(root string, synced []string, excludes []string, held []string) error {
+	isHeld := make(map[string]bool, len(held))
+	for _, path := range held {
+		isHeld[path] = true
+	}
+	for _, path := range synced {
+		shadow := s.shadowPath(root, path)
+		if shadow == "" {
+			continue
+		}
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if isExcludedPath(root, file, excludes) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() || isHeld[file] {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil || info.Size() > maxShadowFileBytes {
+				return nil
+			}
+			return s.copyFile(file, s.shadowPath(root, file), info)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		// Drop the copies of files that are no longer kept
+		err = filepath.WalkDir(shadow, func(copied string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(s.dir, copied)
+			file := filepath.Join(root, rel)
+			if isHeld[file] {
+				return nil
+			}
+			if info, statErr := os.Lstat(file); statErr != nil || !info.Mode().IsRegular() || info.Size() > maxShadowFileBytes || isExcludedPath(root, file, excludes) {
+				return os.Remove(copied)
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies a host file into the shadow copy unless its copy is up to date
+func (s *shadowCopy) copyFile(file, shadow string, info fs.FileInfo) error {
+	if copied, err := os.Stat(shadow); err == nil && copied.Size() == info.Size() && copied.ModTime().Equal(info.ModTime()) {
+		return nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(shadow), 0700); err != nil {
+		return err
+	}
+	partial := shadow + ".partial"
+	if err := os.WriteFile(partial, content, 0600); err != nil {
+		return err
+	}
+	if err := os.Chtimes(partial, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, shadow)
+}
+
+// changed returns the host files at or below paths under root whose copies the shadow copy
+// holds and that were modified or deleted since they were copied
+func (s *shadowCopy) changed(root string, paths []string) []string {
+	var changed []string
+	for _, path := range paths {
+		shadow := s.shadowPath(root, path)
+		if shadow == "" {
+			continue
+		}
+		_ = filepath.WalkDir(shadow, func(copied string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || strings.HasSuffix(copied, ".partial") {
+				return err
+			}
+			rel, _ := filepath.Rel(s.dir, copied)
+			file := filepath.Join(root, rel)
+			copiedInfo, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+			// Copies keep the size and modification time of the file they were copied from
+			if info, err := os.Lstat(file); err != nil || info.Size() != copiedInfo.Size() || !info.ModTime().Equal(copiedInfo.ModTime()) {
+				changed = append(changed, file)
+			}
+			return nil
+		})
+	}
+	return changed
+}
+
+// base returns the content a host path under root had at the last sync, and whether the shadow
+// copy holds it
+func (s *shadowCopy) base(root, path string) ([]byte, bool) {
+	shadow := s.shadowPath(root, path)
+	if shadow == "" {
+		return nil, false
+	}
+	content, err := os.ReadFile(shadow)
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+// updateShadow records the synced host paths of a VM in its shadow copy, except the files with
+// unresolved conflicts. Failures are logged; they only leave later merges without a base.
+// Mounted projects cannot conflict and keep none.
+func (e *Engine) updateShadow(target syncTarget, synced []string) {
+	if target.shadow == nil || target.config.Method.Mounted() {
+		return
+	}
+	if err := target.shadow.update(target.config.ProjectPath, synced, syncExcludes(target.config), target.conflicted); err != nil {
+		log.Warn().Err(err).Str("vm", target.vmName).Msg("Failed to update the sync shadow copy")
+	}
+}
+
+// mergeFiles merges the changes between base and each of host and vm with git merge-file, or
+// diff3 when git is not installed, and reports whether conflicts were left marked in the result.
+// diff3 always marks conflicts in the diff3 style.
+func mergeFiles(host, base, vm []byte, style ConflictStyle) ([]byte, bool, error) {
+	dir, err := os.MkdirTemp("", "vagrant-mcp-merge-")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(dir)
+	files := make([]string, 3)
+	for i, content := range [][]byte{host, base, vm} {
+		files[i] = filepath.Join(dir, []string{"host", "base", "vm"}[i])
+		if err := os.WriteFile(files[i], content, 0600); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var cmd *exec.Cmd
+	// git merge-file exits with the number of conflicts up to 127, diff3 with 1 for conflicts
+	maxConflictStatus := 1
+	if git, err := exec.LookPath("git"); err == nil {
+		maxConflictStatus = 127
+		args := []string{"merge-file", "-p", "-L", "host", "-L", "last sync", "-L", "vm"}
+		if style == ConflictStyleDiff3 || style == ConflictStyleZdiff3 {
+			args = append(args, "--"+string(style))
+		}
+		cmd = exec.Command(git, append(args, files...)...)
+	} else {
+		cmd = exec.Command("diff3", "-m", "-L", "host", "-L", "last sync", "-L", "vm", files[0], files[1], files[2])
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 && exitErr.ExitCode() <= maxConflictStatus {
+		return output, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s failed: %w: %s", filepath.Base(cmd.Path), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, false, nil
+}
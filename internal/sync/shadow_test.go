@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShadowCopy_Update(t *testing.T) {
+	root := t.TempDir()
+	shadow := newShadowCopy(t.TempDir())
+	write := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	write("main.go", "package main\n")
+	write("src/app.js", "console.log(1)\n")
+	write("node_modules/dep/index.js", "module.exports = 1\n")
+	write("big.bin", strings.Repeat("x", maxShadowFileBytes+1))
+
+	if err := shadow.update(root, []string{root}, []string{"node_modules"}, nil); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if base, ok := shadow.base(root, filepath.Join(root, "src/app.js")); !ok || string(base) != "console.log(1)\n" {
+		t.Errorf("Expected the synced version of app.js but got %q, %v", base, ok)
+	}
+	for _, rel := range []string{"node_modules/dep/index.js", "big.bin"} {
+		if _, ok := shadow.base(root, filepath.Join(root, rel)); ok {
+			t.Errorf("Expected %s not to be kept", rel)
+		}
+	}
+
+	// Updating a single file leaves the rest, and removed files are dropped
+	write("main.go", "package main\n\nfunc main() {}\n")
+	if err := os.Remove(filepath.Join(root, "src/app.js")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if err := shadow.update(root, []string{filepath.Join(root, "main.go")}, nil, nil); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if base, _ := shadow.base(root, filepath.Join(root, "main.go")); string(base) != "package main\n\nfunc main() {}\n" {
+		t.Errorf("Expected the new version of main.go but got %q", base)
+	}
+	if _, ok := shadow.base(root, filepath.Join(root, "src/app.js")); !ok {
+		t.Error("Expected app.js to be kept until its directory is synced")
+	}
+	if err := shadow.update(root, []string{filepath.Join(root, "src")}, nil, nil); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, ok := shadow.base(root, filepath.Join(root, "src/app.js")); ok {
+		t.Error("Expected app.js to be dropped once its removal was synced")
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	if _, err := osexec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	base := "one\ntwo\nthree\nfour\nfive\n"
+	host := "ONE\ntwo\nthree\nfour\nfive\n"
+	vm := "one\ntwo\nthree\nfour\nFIVE\n"
+	merged, conflicted, err := mergeFiles([]byte(host), []byte(base), []byte(vm), "")
+	if err != nil || conflicted || string(merged) != "ONE\ntwo\nthree\nfour\nFIVE\n" {
+		t.Fatalf("Expected a clean merge of both changes but got %q, %v, %v", merged, conflicted, err)
+	}
+
+	vm = "uno\ntwo\nthree\nfour\nfive\n"
+	for style, expected := range map[ConflictStyle][]string{
+		ConflictStyleMerge: {"<<<<<<< host\nONE\n=======\nuno\n>>>>>>> vm\n"},
+		ConflictStyleDiff3: {"<<<<<<< host\nONE\n||||||| last sync\none\n=======\nuno\n>>>>>>> vm\n"},
+	} {
+		merged, conflicted, err := mergeFiles([]byte(host), []byte(base), []byte(vm), style)
+		if err != nil || !conflicted {
+			t.Fatalf("Expected a conflict with the %s style but got %v, %v", style, conflicted, err)
+		}
+		for _, marker := range expected {
+			if !strings.Contains(string(merged), marker) {
+				t.Errorf("Expected %q in the %s style merge but got:\n%s", marker, style, merged)
+			}
+		}
+	}
+}
+
+func TestSyncEngine_MergeAgainstLastSync(t *testing.T) {
+	if _, err := osexec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	root := t.TempDir()
+	path := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\nfour\nfive\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	engine, _ := NewEngine()
+	engine.SetVMManager(&recordingVMManager{baseDir: t.TempDir()})
+	if err := engine.RegisterVM("test-vm", SyncConfig{ProjectPath: root}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+	if _, err := engine.SyncToVM(context.Background(), "test-vm", ""); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	// Both sides changed different lines since the sync
+	host := "ONE\ntwo\nthree\nfour\nfive\n"
+	if err := os.WriteFile(path, []byte(host), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	conflict := SyncConflict{Path: path, HostContent: host, VMContent: "one\ntwo\nthree\nfour\nFIVE\n", ConflictType: "modification"}
+	if _, err := engine.RecordConflict("test-vm", conflict); err != nil {
+		t.Fatalf("Failed to record conflict: %v", err)
+	}
+	if err := engine.ResolveSyncConflict(context.Background(), "test-vm", path, "merge"); err != nil {
+		t.Fatalf("Expected a clean merge but got %v", err)
+	}
+	if merged, _ := os.ReadFile(path); string(merged) != "ONE\ntwo\nthree\nfour\nFIVE\n" {
+		t.Errorf("Expected both changes in the merged file but got %q", merged)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// DefaultVerifyExcludes are never compared: Vagrant's own state directory is not synced
+var DefaultVerifyExcludes = []string{".vagrant"}
+
+// VerifyReport compares the files of the project on the host with the synced copy in the VM
+type VerifyReport struct {
+	InSync   bool `json:"in_sync"`
+	Matching int  `json:"matching"`
+	// Missing files exist on the host but not in the VM
+	Missing []string `json:"missing"`
+	// Extra files exist in the VM but not on the host
+	Extra []string `json:"extra"`
+	// Differing files exist on both sides with different content
+	Differing []string `json:"differing"`
+}
+
+// HashTree returns the SHA-256 of every regular file under root that is not excluded, keyed
+// by its slash separated path relative to root
+func HashTree(root string, patterns []string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if isExcludedPath(root, path, patterns) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash project files: %w", err)
+	}
+	return hashes, nil
+}
+
+// hashFile returns the hex SHA-256 of a file
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GuestHashCommand returns the shell command that prints the sha256sum of every regular file
+// under guestPath in the VM. Excluded directories are pruned to keep the scan fast;
+// ParseGuestHashes applies the full exclude rules.
+func GuestHashCommand(guestPath string, patterns []string) string {
+	return fmt.Sprintf("cd %s && %s -print0 | xargs -0 -r sha256sum", shellquote.Quote(guestPath), guestFindCommand(patterns))
+}
+
+// GuestChangesCommand returns the shell command that prints the path of every regular file
+// under guestPath in the VM modified after since. Excluded directories are pruned;
+// ParseGuestChanges applies the full exclude rules.
+func GuestChangesCommand(guestPath string, patterns []string, since time.Time) string {
+	return fmt.Sprintf("cd %s && %s -newermt @%d -print", shellquote.Quote(guestPath), guestFindCommand(patterns), since.Unix())
+}
+
+// ParseGuestChanges parses the output of GuestChangesCommand into sorted paths relative to the
+// guest path, leaving out excluded files
+func ParseGuestChanges(output string, patterns []string) []string {
+	paths := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		path := strings.TrimPrefix(line, "./")
+		if path == "" || isExcludedPath("/", "/"+path, patterns) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// guestFindCommand returns a find command, without its action, that selects the regular files
+// of the current directory and prunes the excluded directories
+func guestFindCommand(patterns []string) string {
+	var prune []string
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		if strings.Contains(pattern, "/") {
+			prune = append(prune, "-path "+shellquote.Quote("./"+pattern))
+		} else {
+			prune = append(prune, "-name "+shellquote.Quote(pattern))
+		}
+	}
+	if len(prune) == 0 {
+		return "find . -type f"
+	}
+	return fmt.Sprintf("find . \\( %s \\) -prune -o -type f", strings.Join(prune, " -o "))
+}
+
+// ParseGuestHashes parses the output of GuestHashCommand into hashes keyed by path relative to
+// the guest path, leaving out excluded files
+func ParseGuestHashes(output string, patterns []string) map[string]string {
+	hashes := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		hash, path, ok := strings.Cut(line, "  ")
+		if !ok || len(hash) != sha256.Size*2 {
+			continue
+		}
+		path = strings.TrimPrefix(path, "./")
+		if isExcludedPath("/", "/"+path, patterns) {
+			continue
+		}
+		hashes[path] = hash
+	}
+	return hashes
+}
+
+// CompareHashes compares the host and guest hashes of the project
+func CompareHashes(host, guest map[string]string) VerifyReport {
+	report := VerifyReport{Missing: []string{}, Extra: []string{}, Differing: []string{}}
+	for path, hostHash := range host {
+		guestHash, exists := guest[path]
+		switch {
+		case !exists:
+			report.Missing = append(report.Missing, path)
+		case guestHash != hostHash:
+			report.Differing = append(report.Differing, path)
+		default:
+			report.Matching++
+		}
+	}
+	for path := range guest {
+		if _, exists := host[path]; !exists {
+			report.Extra = append(report.Extra, path)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Differing)
+	report.InSync = len(report.Missing) == 0 && len(report.Extra) == 0 && len(report.Differing) == 0
+	return report
+}
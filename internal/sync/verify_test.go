@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashTree(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src", "node_modules/pkg", ".vagrant"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	files := map[string]string{
+		"src/main.go":               "package main\n",
+		"README.md":                 "hello",
+		"node_modules/pkg/index.js": "x",
+		".vagrant/machine":          "id",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	hashes, err := HashTree(root, append([]string{"node_modules"}, DefaultVerifyExcludes...))
+	if err != nil {
+		t.Fatalf("Failed to hash tree: %v", err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("Expected 2 hashed files but got %d: %v", len(hashes), hashes)
+	}
+	// sha256 of "hello"
+	if hashes["README.md"] != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Expected the SHA-256 of README.md but got %s", hashes["README.md"])
+	}
+	if _, exists := hashes["src/main.go"]; !exists {
+		t.Error("Expected src/main.go to be hashed")
+	}
+}
+
+func TestGuestHashCommand(t *testing.T) {
+	command := GuestHashCommand("/vagrant", []string{"node_modules", "build/out/"})
+	for _, part := range []string{"cd '/vagrant'", "-name 'node_modules'", "-path './build/out'", "-prune", "xargs -0 -r sha256sum"} {
+		if !strings.Contains(command, part) {
+			t.Errorf("Expected command to contain %q but got %s", part, command)
+		}
+	}
+}
+
+func TestGuestChangesCommand(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	command := GuestChangesCommand("/vagrant", []string{"node_modules"}, since)
+	for _, part := range []string{"cd '/vagrant'", "-name 'node_modules'", "-prune", "-type f -newermt @1700000000 -print"} {
+		if !strings.Contains(command, part) {
+			t.Errorf("Expected command to contain %q but got %s", part, command)
+		}
+	}
+
+	paths := ParseGuestChanges("./src/main.go\n./debug.log\n./a.go\n", []string{"*.log"})
+	if !slices.Equal(paths, []string{"a.go", "src/main.go"}) {
+		t.Errorf("Expected [a.go src/main.go] but got %v", paths)
+	}
+}
+
+func TestParseGuestHashes(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+	output := hash + "  ./src/main.go\n" +
+		hash + "  ./debug.log\n" +
+		"sha256sum: ./locked: Permission denied\n"
+
+	hashes := ParseGuestHashes(output, []string{"*.log"})
+	expected := map[string]string{"src/main.go": hash}
+	if !reflect.DeepEqual(hashes, expected) {
+		t.Errorf("Expected %v but got %v", expected, hashes)
+	}
+}
+
+func TestCompareHashes(t *testing.T) {
+	host := map[string]string{"a.go": "1", "b.go": "2", "c.go": "3"}
+	guest := map[string]string{"a.go": "1", "b.go": "changed", "d.go": "4"}
+
+	report := CompareHashes(host, guest)
+	if report.InSync {
+		t.Error("Expected report not to be in sync")
+	}
+	if report.Matching != 1 {
+		t.Errorf("Expected 1 matching file but got %d", report.Matching)
+	}
+	if !reflect.DeepEqual(report.Missing, []string{"c.go"}) {
+		t.Errorf("Expected missing [c.go] but got %v", report.Missing)
+	}
+	if !reflect.DeepEqual(report.Extra, []string{"d.go"}) {
+		t.Errorf("Expected extra [d.go] but got %v", report.Extra)
+	}
+	if !reflect.DeepEqual(report.Differing, []string{"b.go"}) {
+		t.Errorf("Expected differing [b.go] but got %v", report.Differing)
+	}
+
+	if report := CompareHashes(host, host); !report.InSync {
+		t.Error("Expected identical hashes to be in sync")
+	}
+}
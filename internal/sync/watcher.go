@@ -0,0 +1,638 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+const (
+	// maxPendingChanges is the number of changed paths a batch tracks before it gives up on
+	// individual paths and syncs the whole project
+	maxPendingChanges = 1000
+	// maxDebounceIntervals bounds how many watch intervals a steady stream of changes can
+	// postpone a sync
+	maxDebounceIntervals = 4
+)
+
+// WatchMode selects how a watcher detects file changes
+type WatchMode string
+
+const (
+	// WatchModeAuto watches with fsnotify and polls once the system's watch limit is reached
+	WatchModeAuto WatchMode = "auto"
+	// WatchModeNotify only watches with fsnotify and fails when the watch limit is reached
+	WatchModeNotify WatchMode = "notify"
+	// WatchModePoll scans the project for changes every watch interval
+	WatchModePoll WatchMode = "poll"
+)
+
+// ValidateWatchMode checks that a watch mode name is known
+func ValidateWatchMode(mode WatchMode) error {
+	switch mode {
+	case "", WatchModeAuto, WatchModeNotify, WatchModePoll:
+		return nil
+	default:
+		return fmt.Errorf("invalid watch mode: %s (must be 'auto', 'notify', or 'poll')", mode)
+	}
+}
+
+// WatcherStats describes a running file watcher
+type WatcherStats struct {
+	Mode WatchMode `json:"mode"`
+	// Strategy is how changes are detected: notify, poll, or hybrid when directories below
+	// the watch depth are polled
+	Strategy    string `json:"strategy"`
+	WatchedDirs int    `json:"watched_dirs"`
+	PolledRoots int    `json:"polled_roots"`
+	PolledPaths int    `json:"polled_paths"`
+	// WatchLimit is the system's inotify watch limit, when known
+	WatchLimit int       `json:"watch_limit,omitempty"`
+	Events     int       `json:"events"`
+	Batches    int       `json:"batches"`
+	Overflows  int       `json:"overflows"`
+	LastError  string    `json:"last_error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// changeBatch collects the changes seen during one debounce window
+type changeBatch struct {
+	// changed holds paths that were created or written
+	changed map[string]bool
+	// removed holds paths that were deleted or renamed away
+	removed map[string]bool
+	// overflow is set when individual changes were lost or too many were seen
+	overflow bool
+}
+
+// newChangeBatch creates an empty batch
+func newChangeBatch() *changeBatch {
+	return &changeBatch{changed: make(map[string]bool), removed: make(map[string]bool)}
+}
+
+// add records an event and reports whether it changed the batch; attribute changes are ignored
+func (b *changeBatch) add(event fsnotify.Event) bool {
+	switch {
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		delete(b.changed, event.Name)
+		b.removed[event.Name] = true
+	case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+		delete(b.removed, event.Name)
+		b.changed[event.Name] = true
+	default:
+		return false
+	}
+	if len(b.changed)+len(b.removed) > maxPendingChanges {
+		b.overflow = true
+	}
+	return true
+}
+
+// empty reports whether the batch has nothing to sync
+func (b *changeBatch) empty() bool {
+	return !b.overflow && len(b.changed) == 0 && len(b.removed) == 0
+}
+
+// plan returns the directories to sync with deletion and the files to sync individually.
+// Deletions are propagated by syncing the parent directory of each removed path, new
+// directories are synced as a whole, and files inside a synced directory are left to it.
+func (b *changeBatch) plan(root string) (dirs []string, files []string) {
+	if b.overflow {
+		return []string{root}, nil
+	}
+
+	dirSet := make(map[string]bool)
+	for path := range b.removed {
+		dirSet[filepath.Dir(path)] = true
+	}
+	var candidates []string
+	for path := range b.changed {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			// Gone without a remove event; its parent directory picks up the deletion
+			dirSet[filepath.Dir(path)] = true
+		case info.IsDir():
+			dirSet[path] = true
+		default:
+			candidates = append(candidates, path)
+		}
+	}
+
+	for dir := range dirSet {
+		if !isWithin(dir, root) {
+			dir = root
+		}
+		dirs = append(dirs, dir)
+	}
+	dirs = collapseDirs(dirs)
+	for _, file := range candidates {
+		if !coveredBy(file, dirs) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return dirs, files
+}
+
+// collapseDirs sorts dirs and drops the ones inside another dir of the list
+func collapseDirs(dirs []string) []string {
+	sort.Strings(dirs)
+	var collapsed []string
+	for _, dir := range dirs {
+		if !coveredBy(dir, collapsed) {
+			collapsed = append(collapsed, dir)
+		}
+	}
+	return collapsed
+}
+
+// coveredBy reports whether path is one of dirs or inside one of them
+func coveredBy(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isWithin(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isExcludedPath reports whether a path inside root matches an exclude pattern. Patterns
+// without a slash match any path component, e.g. node_modules or *.log; patterns with a slash
+// match the path relative to root.
+func isExcludedPath(root, path string, patterns []string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	components := strings.Split(rel, "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if strings.Contains(pattern, "/") {
+			if matched, _ := filepath.Match(pattern, rel); matched {
+				return true
+			}
+			continue
+		}
+		for _, component := range components {
+			if matched, _ := filepath.Match(pattern, component); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// watchWorker watches a VM's project directory and syncs debounced batches of changes to it.
+// Directories are watched with fsnotify; directories below the configured depth, or the whole
+// project in poll mode or once the watch limit is reached in auto mode, are polled instead.
+type watchWorker struct {
+	engine  *Engine
+	vmName  string
+	config  SyncConfig
+	watcher *fsnotify.Watcher
+	// pending tracks the changes seen until they are synced
+	pending *pendingChanges
+	stop    chan struct{}
+	done    chan struct{}
+
+	// snapshot is the last scan of pollRoots; only used by the worker goroutine
+	snapshot pollSnapshot
+
+	// statsMu guards the fields below, which GetWatcherStats reads
+	statsMu   sync.Mutex
+	notify    bool
+	pollRoots []string
+	stats     WatcherStats
+}
+
+// newWatchWorker creates a worker watching every directory of the project that is not excluded
+func newWatchWorker(engine *Engine, vmName string, config SyncConfig) (*watchWorker, error) {
+	mode := config.WatchMode
+	if mode == "" {
+		mode = WatchModeAuto
+	}
+	w := &watchWorker{
+		engine: engine,
+		vmName: vmName,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		stats:  WatcherStats{Mode: mode, WatchLimit: inotifyWatchLimit(), StartedAt: time.Now()},
+	}
+
+	if mode != WatchModePoll {
+		watcher, err := fsnotify.NewWatcher()
+		switch {
+		case err == nil:
+			w.watcher = watcher
+			w.notify = true
+		case mode == WatchModeNotify:
+			return nil, fmt.Errorf("failed to create file watcher: %w", err)
+		default:
+			log.Warn().Err(err).Str("vm", vmName).Msg("Failed to create file watcher, polling instead")
+		}
+	}
+
+	if w.notify {
+		if err := w.addTree(config.ProjectPath); err != nil {
+			if mode == WatchModeNotify || !errors.Is(err, ErrWatchLimit) {
+				if cerr := w.watcher.Close(); cerr != nil {
+					log.Warn().Err(cerr).Msg("Failed to close watcher after error")
+				}
+				return nil, fmt.Errorf("failed to add directories to watcher: %w", err)
+			}
+			w.fallBackToPolling(err)
+		}
+	} else {
+		w.pollRoots = []string{config.ProjectPath}
+	}
+	w.snapshot = scanPollRoots(config.ProjectPath, w.pollRoots, config.ExcludePatterns)
+	return w, nil
+}
+
+// addTree watches dir and the directories below it that are not excluded. Directories deeper
+// than the watch depth are polled instead.
+func (w *watchWorker) addTree(dir string) error {
+	root := w.config.ProjectPath
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if isExcludedPath(root, path, w.config.ExcludePatterns) {
+			return filepath.SkipDir
+		}
+		if w.config.WatchDepth > 0 && pathDepth(root, path) > w.config.WatchDepth {
+			w.statsMu.Lock()
+			w.pollRoots = append(w.pollRoots, path)
+			w.statsMu.Unlock()
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			if isWatchLimitError(err) {
+				return watchLimitError(len(w.watcher.WatchList()), err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// fallBackToPolling stops watching with fsnotify and polls the whole project instead
+func (w *watchWorker) fallBackToPolling(reason error) {
+	log.Warn().Err(reason).Str("vm", w.vmName).Msg("Falling back to polling for file changes")
+	w.statsMu.Lock()
+	w.notify = false
+	w.pollRoots = []string{w.config.ProjectPath}
+	w.stats.LastError = reason.Error()
+	w.statsMu.Unlock()
+	if err := w.watcher.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close watcher")
+	}
+}
+
+// close stops the worker without waiting for it, since an in-flight sync needs the engine lock
+func (w *watchWorker) close() {
+	close(w.stop)
+}
+
+// run processes watcher events and polls until the worker is closed
+func (w *watchWorker) run() {
+	defer close(w.done)
+	defer func() {
+		if w.watcher == nil || !w.isNotifying() {
+			return
+		}
+		if err := w.watcher.Close(); err != nil {
+			log.Warn().Err(err).Msg("Failed to close watcher in goroutine")
+		}
+	}()
+
+	var eventsC chan fsnotify.Event
+	var errorsC chan error
+	if w.isNotifying() {
+		eventsC = w.watcher.Events
+		errorsC = w.watcher.Errors
+	}
+
+	// The poll ticker is started once there is something to poll
+	var pollTicker *time.Ticker
+	var pollC <-chan time.Time
+	startPolling := func() {
+		if pollTicker == nil && w.hasPollRoots() {
+			pollTicker = time.NewTicker(pollInterval(w.config.WatchInterval))
+			pollC = pollTicker.C
+		}
+	}
+	startPolling()
+	defer func() {
+		if pollTicker != nil {
+			pollTicker.Stop()
+		}
+	}()
+
+	batch := newChangeBatch()
+	timer := time.NewTimer(w.config.WatchInterval)
+	timer.Stop()
+	var firstChange time.Time
+
+	// schedule restarts the debounce timer, but never past maxDebounceIntervals after the
+	// first change of the batch
+	schedule := func() {
+		now := time.Now()
+		if firstChange.IsZero() {
+			firstChange = now
+		}
+		delay := w.config.WatchInterval
+		if remaining := firstChange.Add(maxDebounceIntervals * w.config.WatchInterval).Sub(now); remaining < delay {
+			delay = remaining
+		}
+		timer.Reset(delay)
+	}
+	record := func(event fsnotify.Event) {
+		if batch.add(event) {
+			w.pending.changed(w.config.ProjectPath, event.Name, time.Now())
+			w.statsMu.Lock()
+			w.stats.Events++
+			w.statsMu.Unlock()
+			schedule()
+		}
+	}
+	overflow := func() {
+		batch.overflow = true
+		w.pending.overflowed(time.Now())
+		w.statsMu.Lock()
+		w.stats.Overflows++
+		w.statsMu.Unlock()
+		schedule()
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			timer.Stop()
+			return
+		case event, ok := <-eventsC:
+			if !ok {
+				return
+			}
+			if isExcludedPath(w.config.ProjectPath, event.Name, w.config.ExcludePatterns) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := w.addTree(event.Name); err != nil {
+						if errors.Is(err, ErrWatchLimit) && w.stats.Mode == WatchModeAuto {
+							// Changes made while falling back are only caught by a full sync
+							w.fallBackToPolling(err)
+							eventsC, errorsC = nil, nil
+							w.snapshot = scanPollRoots(w.config.ProjectPath, w.pollRoots, w.config.ExcludePatterns)
+							startPolling()
+							overflow()
+							continue
+						}
+						w.reportError(err)
+					}
+					startPolling()
+				}
+			}
+			record(event)
+		case <-pollC:
+			w.statsMu.Lock()
+			roots := append([]string(nil), w.pollRoots...)
+			w.statsMu.Unlock()
+			snapshot := scanPollRoots(w.config.ProjectPath, roots, w.config.ExcludePatterns)
+			for _, event := range snapshot.changesSince(w.snapshot) {
+				record(event)
+			}
+			w.snapshot = snapshot
+			w.statsMu.Lock()
+			w.stats.PolledPaths = len(snapshot)
+			w.statsMu.Unlock()
+		case <-timer.C:
+			firstChange = time.Time{}
+			if !batch.empty() {
+				w.statsMu.Lock()
+				w.stats.Batches++
+				w.statsMu.Unlock()
+				w.engine.syncWatchedChanges(w, batch)
+			}
+			batch = newChangeBatch()
+		case err, ok := <-errorsC:
+			if !ok {
+				return
+			}
+			if err == fsnotify.ErrEventOverflow {
+				// Changes were lost, so only a full sync is correct
+				log.Warn().Str("vm", w.vmName).Msg("File watcher queue overflowed, syncing the whole project")
+				overflow()
+				continue
+			}
+			w.reportError(err)
+		}
+	}
+}
+
+// reportError logs and publishes a watcher error
+func (w *watchWorker) reportError(err error) {
+	w.statsMu.Lock()
+	w.stats.LastError = err.Error()
+	w.statsMu.Unlock()
+	log.Error().Err(err).Str("vm", w.vmName).Msg("File watcher error")
+	events.GlobalBus.Publish(events.WatcherError, w.vmName, map[string]interface{}{
+		"error": err.Error(),
+	})
+}
+
+// isNotifying reports whether the worker still watches with fsnotify
+func (w *watchWorker) isNotifying() bool {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	return w.notify
+}
+
+// hasPollRoots reports whether any directories are polled
+func (w *watchWorker) hasPollRoots() bool {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	return len(w.pollRoots) > 0
+}
+
+// currentStats returns the worker's statistics
+func (w *watchWorker) currentStats() WatcherStats {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	stats := w.stats
+	switch {
+	case !w.notify:
+		stats.Strategy = "poll"
+	case len(w.pollRoots) > 0:
+		stats.Strategy = "hybrid"
+	default:
+		stats.Strategy = "notify"
+	}
+	if w.notify {
+		stats.WatchedDirs = len(w.watcher.WatchList())
+	}
+	stats.PolledRoots = len(w.pollRoots)
+	return stats
+}
+
+// pathDepth returns how many directory levels path is below root
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
+
+// isWatchLimitError reports whether adding a watch failed because a system limit was reached:
+// ENOSPC for inotify watches, EMFILE for the file descriptors kqueue needs per watched path
+func isWatchLimitError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
+
+// watchLimitError explains how to get past a watch limit
+func watchLimitError(watched int, err error) error {
+	remedy := "exclude large directories, set watch_depth or use watch_mode poll"
+	if runtime.GOOS == "linux" {
+		limit := ""
+		if max := inotifyWatchLimit(); max > 0 {
+			limit = fmt.Sprintf(" of %d", max)
+		}
+		return fmt.Errorf("%w: inotify limit%s reached after watching %d directories; raise it with 'sudo sysctl fs.inotify.max_user_watches=524288', %s: %v", ErrWatchLimit, limit, watched, remedy, err)
+	}
+	return fmt.Errorf("%w: open file limit reached after watching %d directories; raise it with 'ulimit -n', %s: %v", ErrWatchLimit, watched, remedy, err)
+}
+
+// inotifyWatchLimit returns fs.inotify.max_user_watches, or 0 when it is unknown
+func inotifyWatchLimit() int {
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return 0
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// startWatcher starts a watch worker for a VM; the caller holds e.mu
+func (e *Engine) startWatcher(vmName string) error {
+	config, exists := e.configs[vmName]
+	if !exists {
+		return ErrVMNotRegistered
+	}
+	worker, err := newWatchWorker(e, vmName, config)
+	if err != nil {
+		return err
+	}
+	worker.pending = e.pending[vmName]
+	e.watchers[vmName] = worker
+	go worker.run()
+
+	log.Info().Str("vm", vmName).Str("path", config.ProjectPath).Msg("File watcher started")
+	return nil
+}
+
+// GetWatcherStats returns the statistics of a VM's file watcher, or nil when it is not watching
+func (e *Engine) GetWatcherStats(vmName string) (*WatcherStats, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, exists := e.configs[vmName]; !exists {
+		return nil, ErrVMNotRegistered
+	}
+	worker, exists := e.watchers[vmName]
+	if !exists {
+		return nil, nil
+	}
+	stats := worker.currentStats()
+	return &stats, nil
+}
+
+// stopWatcherLocked stops the watch worker of a VM, if any; the caller holds e.mu
+func (e *Engine) stopWatcherLocked(vmName string) {
+	if worker, exists := e.watchers[vmName]; exists {
+		worker.close()
+		delete(e.watchers, vmName)
+	}
+}
+
+// syncWatchedChanges syncs a batch of changes seen by a watch worker to its VM, queued behind
+// the VM's other syncs
+func (e *Engine) syncWatchedChanges(w *watchWorker, batch *changeBatch) {
+	var conflicts []SyncConflict
+	err := e.runQueued(context.Background(), w.vmName, func() error {
+		// The worker may have been replaced or stopped while its sync was queued
+		e.mu.RLock()
+		current, managed := e.watchers[w.vmName] == w, e.vmManager != nil
+		e.mu.RUnlock()
+		if !current {
+			return nil
+		}
+		if !managed {
+			log.Error().Str("vm", w.vmName).Msg("VM manager not set, cannot sync watched changes")
+			return nil
+		}
+		target, err := e.beginSync(w.vmName)
+		if err != nil {
+			return err
+		}
+
+		root := w.config.ProjectPath
+		dirs, files := batch.plan(root)
+		if batch.overflow {
+			log.Warn().Str("vm", w.vmName).Msg("Too many file changes to track, syncing the whole project")
+		} else {
+			log.Info().Str("vm", w.vmName).Int("changed", len(batch.changed)).Int("removed", len(batch.removed)).Msg("File changes detected, syncing to VM")
+		}
+
+		startTime := time.Now()
+		var synced []string
+		var syncErr error
+		synced, conflicts, syncErr = e.syncPlan(target, dirs, files)
+		syncTimeMs := int(time.Since(startTime).Milliseconds())
+
+		if syncErr != nil {
+			log.Error().Err(syncErr).Str("vm", w.vmName).Msg("Failed to sync changes to VM")
+		} else {
+			target.pending.uploaded(root, synced, startTime)
+		}
+		e.finishSync(w.vmName, true, len(synced), syncTimeMs, syncErr,
+			fmt.Sprintf("watcher: %d changed, %d removed, %d directories synced", len(batch.changed), len(batch.removed), len(dirs)))
+		return nil
+	})
+	if err != nil && err != ErrVMNotRegistered {
+		log.Error().Err(err).Str("vm", w.vmName).Msg("Failed to queue the sync of watched changes")
+	}
+	e.recordConflicts(w.vmName, conflicts)
+}
@@ -0,0 +1,268 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestChangeBatch_Add(t *testing.T) {
+	testCases := []struct {
+		name            string
+		events          []fsnotify.Event
+		expectedChanged []string
+		expectedRemoved []string
+	}{
+		{
+			name:            "write",
+			events:          []fsnotify.Event{{Name: "/p/a.go", Op: fsnotify.Write}},
+			expectedChanged: []string{"/p/a.go"},
+		},
+		{
+			name:   "chmod ignored",
+			events: []fsnotify.Event{{Name: "/p/a.go", Op: fsnotify.Chmod}},
+		},
+		{
+			name: "write then remove",
+			events: []fsnotify.Event{
+				{Name: "/p/a.go", Op: fsnotify.Write},
+				{Name: "/p/a.go", Op: fsnotify.Remove},
+			},
+			expectedRemoved: []string{"/p/a.go"},
+		},
+		{
+			name: "rename then create",
+			events: []fsnotify.Event{
+				{Name: "/p/a.go", Op: fsnotify.Rename},
+				{Name: "/p/a.go", Op: fsnotify.Create},
+			},
+			expectedChanged: []string{"/p/a.go"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batch := newChangeBatch()
+			for _, event := range tc.events {
+				batch.add(event)
+			}
+			if changed := sortedKeys(batch.changed); !reflect.DeepEqual(changed, tc.expectedChanged) {
+				t.Errorf("Expected changed %v but got %v", tc.expectedChanged, changed)
+			}
+			if removed := sortedKeys(batch.removed); !reflect.DeepEqual(removed, tc.expectedRemoved) {
+				t.Errorf("Expected removed %v but got %v", tc.expectedRemoved, removed)
+			}
+		})
+	}
+}
+
+func TestChangeBatch_Overflow(t *testing.T) {
+	batch := newChangeBatch()
+	for i := 0; i <= maxPendingChanges; i++ {
+		batch.add(fsnotify.Event{Name: fmt.Sprintf("/p/file%d", i), Op: fsnotify.Write})
+	}
+	if !batch.overflow {
+		t.Fatal("Expected batch to overflow")
+	}
+	dirs, files := batch.plan("/p")
+	if !reflect.DeepEqual(dirs, []string{"/p"}) || len(files) != 0 {
+		t.Errorf("Expected a full project sync but got dirs %v and files %v", dirs, files)
+	}
+}
+
+func TestChangeBatch_Plan(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src", "src/new", "docs"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	for _, file := range []string{"main.go", "src/new/a.go", "docs/readme.md"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	batch := newChangeBatch()
+	batch.changed[filepath.Join(root, "main.go")] = true
+	batch.changed[filepath.Join(root, "src/new")] = true
+	batch.changed[filepath.Join(root, "src/new/a.go")] = true
+	batch.changed[filepath.Join(root, "docs/readme.md")] = true
+	batch.changed[filepath.Join(root, "docs/vanished.md")] = true
+	batch.removed[filepath.Join(root, "src/old.go")] = true
+
+	dirs, files := batch.plan(root)
+	expectedDirs := []string{filepath.Join(root, "docs"), filepath.Join(root, "src")}
+	expectedFiles := []string{filepath.Join(root, "main.go")}
+	if !reflect.DeepEqual(dirs, expectedDirs) {
+		t.Errorf("Expected dirs %v but got %v", expectedDirs, dirs)
+	}
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Errorf("Expected files %v but got %v", expectedFiles, files)
+	}
+}
+
+func TestIsExcludedPath(t *testing.T) {
+	patterns := []string{"node_modules", "*.log", "build/output/"}
+
+	testCases := []struct {
+		path     string
+		expected bool
+	}{
+		{"/p/node_modules", true},
+		{"/p/web/node_modules/pkg/index.js", true},
+		{"/p/logs/app.log", true},
+		{"/p/build/output", true},
+		{"/p/build/other", false},
+		{"/p/src/main.go", false},
+		{"/p", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if excluded := isExcludedPath("/p", tc.path, patterns); excluded != tc.expected {
+				t.Errorf("Expected %v but got %v", tc.expected, excluded)
+			}
+		})
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestValidateWatchMode(t *testing.T) {
+	for _, mode := range []WatchMode{"", WatchModeAuto, WatchModeNotify, WatchModePoll} {
+		if err := ValidateWatchMode(mode); err != nil {
+			t.Errorf("Expected mode '%s' to be valid but got %v", mode, err)
+		}
+	}
+	if err := ValidateWatchMode("inotify"); err == nil {
+		t.Error("Expected an error for an unknown watch mode")
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected int
+	}{
+		{"/p", 0},
+		{"/p/src", 1},
+		{"/p/src/pkg/util", 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if depth := pathDepth("/p", tc.path); depth != tc.expected {
+				t.Errorf("Expected depth %d but got %d", tc.expected, depth)
+			}
+		})
+	}
+}
+
+func TestWatchLimitError(t *testing.T) {
+	err := watchLimitError(100, syscall.ENOSPC)
+	if !errors.Is(err, ErrWatchLimit) {
+		t.Errorf("Expected error to wrap ErrWatchLimit but got %v", err)
+	}
+	if !isWatchLimitError(syscall.ENOSPC) || !isWatchLimitError(syscall.EMFILE) {
+		t.Error("Expected ENOSPC and EMFILE to be watch limit errors")
+	}
+	if isWatchLimitError(syscall.ENOENT) {
+		t.Error("Expected ENOENT not to be a watch limit error")
+	}
+}
+
+func TestNewWatchWorker_Strategy(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src/pkg/util"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	testCases := []struct {
+		name             string
+		mode             WatchMode
+		depth            int
+		expectedStrategy string
+		expectedRoots    int
+	}{
+		{name: "notify", mode: WatchModeNotify, expectedStrategy: "notify"},
+		{name: "depth", mode: WatchModeAuto, depth: 1, expectedStrategy: "hybrid", expectedRoots: 1},
+		{name: "poll", mode: WatchModePoll, expectedStrategy: "poll", expectedRoots: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := SyncConfig{ProjectPath: root, WatchMode: tc.mode, WatchDepth: tc.depth, WatchInterval: time.Second}
+			worker, err := newWatchWorker(&Engine{}, "test-vm", config)
+			if err != nil {
+				t.Fatalf("Failed to create watch worker: %v", err)
+			}
+			defer func() {
+				if worker.watcher != nil {
+					_ = worker.watcher.Close()
+				}
+			}()
+
+			stats := worker.currentStats()
+			if stats.Strategy != tc.expectedStrategy {
+				t.Errorf("Expected strategy %s but got %s", tc.expectedStrategy, stats.Strategy)
+			}
+			if stats.PolledRoots != tc.expectedRoots {
+				t.Errorf("Expected %d polled roots but got %d", tc.expectedRoots, stats.PolledRoots)
+			}
+		})
+	}
+}
+
+func TestWatcher_SyncsRemovals(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	file := filepath.Join(root, "src", "old.go")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	manager := &recordingVMManager{}
+	engine, _ := NewEngine()
+	engine.SetVMManager(manager)
+	config := SyncConfig{ProjectPath: root, WatchEnabled: true, WatchMode: WatchModeNotify, WatchInterval: 50 * time.Millisecond}
+	if err := engine.RegisterVM("test-vm", config); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+	defer func() { _ = engine.UnregisterVM("test-vm") }()
+
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	// The parent directory is synced so the deletion reaches the VM
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		synced := manager.syncedToVM()
+		if len(synced) > 0 {
+			if synced[0] != filepath.Join(root, "src") {
+				t.Errorf("Expected %s to be synced but got %v", filepath.Join(root, "src"), synced)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected the removal to be synced to the VM")
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	osExec "os/exec"
 	"path/filepath"
-	"strings"
 	"testing"
 	"time"
 
@@ -276,23 +275,19 @@ func (f *UnifiedFixture) forceDestroyVM() {
 		return
 	}
 
-	// Parse the output to find our VM
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		parts := strings.Split(line, ",")
-		if len(parts) >= 4 && parts[1] == f.VMName {
-			vmID := parts[0]
-			if vmID != "" {
-				log.Info().Str("vm_id", vmID).Str("vm", f.VMName).Msg("Force destroying VM by ID")
-				destroyCmd := osExec.Command("vagrant", "destroy", vmID, "--force")
-				if err := destroyCmd.Run(); err != nil {
-					log.Warn().Err(err).Str("vm_id", vmID).Msg("Failed to force destroy VM")
-				} else {
-					log.Info().Str("vm_id", vmID).Msg("Successfully force destroyed VM")
-				}
-				break
-			}
+	// Find our VM by machine name
+	for _, environment := range vm.ParseGlobalStatus(string(output)) {
+		if environment.Name != f.VMName || environment.ID == "" {
+			continue
+		}
+		log.Info().Str("vm_id", environment.ID).Str("vm", f.VMName).Msg("Force destroying VM by ID")
+		destroyCmd := osExec.Command("vagrant", "destroy", environment.ID, "--force")
+		if err := destroyCmd.Run(); err != nil {
+			log.Warn().Err(err).Str("vm_id", environment.ID).Msg("Failed to force destroy VM")
+		} else {
+			log.Info().Str("vm_id", environment.ID).Msg("Successfully force destroyed VM")
 		}
+		break
 	}
 }
 
@@ -0,0 +1,96 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package testrun
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// FetchArtifacts copies files or directories from guestDir in the VM to the same relative
+// paths under hostDir and returns the paths that were copied. Paths missing in the guest are
+// skipped.
+func FetchArtifacts(ctx context.Context, vmName string, sshArgs []string, guestDir, hostDir string, paths []string) ([]string, error) {
+	quoted := quoteAll(paths)
+	// Only archive the paths that exist; tar fails on missing ones
+	command := fmt.Sprintf("cd %s && for p in %s; do [ -e \"$p\" ] && printf '%%s\\0' \"$p\"; done | tar --null -T - -czf -",
+		shellquote.Quote(guestDir), strings.Join(quoted, " "))
+
+	var stdout, stderr bytes.Buffer
+	if err := cmdexec.RunSSH(ctx, cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: command, Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return nil, fmt.Errorf("failed to archive artifacts in the VM: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return extractArchive(&stdout, hostDir)
+}
+
+// extractArchive extracts a gzipped tar archive into dir and returns the top-level paths it held
+func extractArchive(r io.Reader, dir string) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read artifact archive: %w", err)
+	}
+	defer gz.Close()
+
+	var fetched []string
+	seen := make(map[string]bool)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fetched, fmt.Errorf("failed to read artifact archive: %w", err)
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fetched, fmt.Errorf("artifact archive contains unsafe path '%s'", header.Name)
+		}
+		target := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fetched, fmt.Errorf("failed to create artifact directory: %w", err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fetched, fmt.Errorf("failed to create artifact directory: %w", err)
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return fetched, fmt.Errorf("failed to write artifact: %w", err)
+			}
+			_, copyErr := io.Copy(file, reader)
+			closeErr := file.Close()
+			if copyErr != nil {
+				return fetched, fmt.Errorf("failed to write artifact: %w", copyErr)
+			}
+			if closeErr != nil {
+				return fetched, fmt.Errorf("failed to write artifact: %w", closeErr)
+			}
+		default:
+			// Links and devices are not needed for reports
+			continue
+		}
+		top := strings.SplitN(filepath.ToSlash(name), "/", 2)[0]
+		if !seen[top] {
+			seen[top] = true
+			fetched = append(fetched, top)
+		}
+	}
+	return fetched, nil
+}
@@ -0,0 +1,64 @@
+package testrun
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// buildArchive returns a gzipped tar archive holding the given files
+func buildArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+	return &buf
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := buildArchive(t, map[string]string{"coverage/lcov.info": "TN:"})
+
+	fetched, err := extractArchive(archive, dir)
+	if err != nil {
+		t.Fatalf("Failed to extract archive: %v", err)
+	}
+	if !reflect.DeepEqual(fetched, []string{"coverage"}) {
+		t.Errorf("Expected [coverage] but got %v", fetched)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "coverage", "lcov.info"))
+	if err != nil || string(data) != "TN:" {
+		t.Errorf("Expected extracted file content 'TN:' but got %q (%v)", data, err)
+	}
+}
+
+func TestExtractArchive_UnsafePath(t *testing.T) {
+	archive := buildArchive(t, map[string]string{"../escape.txt": "x"})
+	if _, err := extractArchive(archive, t.TempDir()); err == nil {
+		t.Error("Expected an error for a path outside the target directory")
+	}
+}
+
+func TestExtractArchive_Empty(t *testing.T) {
+	fetched, err := extractArchive(&bytes.Buffer{}, t.TempDir())
+	if err != nil || len(fetched) != 0 {
+		t.Errorf("Expected nothing fetched from empty output but got %v (%v)", fetched, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package testrun
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Summary is the outcome of a test run
+type Summary struct {
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	// FailedTests names the failing tests, as far as the output reveals them
+	FailedTests []string `json:"failed_tests"`
+}
+
+var (
+	// goResultPattern matches go test -v result lines such as "--- FAIL: TestX/case (0.00s)"
+	goResultPattern = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+)`)
+	// cargoResultPattern matches cargo test lines such as "test tests::it_works ... ok"
+	cargoResultPattern = regexp.MustCompile(`^test (\S+) \.\.\. (ok|FAILED|ignored)`)
+	// pytestFailedPattern matches the short summary lines of pytest -rA
+	pytestFailedPattern = regexp.MustCompile(`^(?:FAILED|ERROR) (\S+)`)
+	// pytestCountPattern matches the counts of the final pytest line, e.g. "3 passed, 1 failed"
+	pytestCountPattern = regexp.MustCompile(`(\d+) (passed|failed|skipped|errors?|xfailed|xpassed)`)
+	// jestTestsPattern matches the Jest summary line, e.g. "Tests: 1 failed, 2 skipped, 5 passed, 8 total"
+	jestTestsPattern = regexp.MustCompile(`^Tests:\s+(.*)$`)
+	// jestCountPattern matches one count of the Jest summary line
+	jestCountPattern = regexp.MustCompile(`(\d+) (passed|failed|skipped|todo)`)
+	// jestFailurePattern matches the heading of a Jest failure, e.g. "● Suite › name"
+	jestFailurePattern = regexp.MustCompile(`^●\s+(.+)$`)
+	// mochaCountPattern matches the Mocha summary lines, e.g. "5 passing (20ms)"
+	mochaCountPattern = regexp.MustCompile(`^(\d+) (passing|failing|pending)`)
+	// mochaFailurePattern matches the numbered Mocha failure headings, e.g. "1) Suite"
+	mochaFailurePattern = regexp.MustCompile(`^\d+\) (.+)$`)
+	// tapPattern matches TAP result lines, e.g. "not ok 2 - name"
+	tapPattern = regexp.MustCompile(`^(ok|not ok) \d+(?: - )?(.*?)(\s+# (?i:skip).*)?$`)
+)
+
+// Parse summarizes the output of a test run
+func Parse(framework, output string) Summary {
+	lines := strings.Split(output, "\n")
+	var summary Summary
+	switch framework {
+	case FrameworkGo:
+		summary = parseGo(lines)
+	case FrameworkCargo:
+		summary = parseCargo(lines)
+	case FrameworkPytest:
+		summary = parsePytest(lines)
+	case FrameworkNPM:
+		summary = parseNPM(lines)
+	}
+	if summary.FailedTests == nil {
+		summary.FailedTests = []string{}
+	}
+	return summary
+}
+
+func parseGo(lines []string) Summary {
+	var summary Summary
+	for _, line := range lines {
+		match := goResultPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		switch match[1] {
+		case "PASS":
+			summary.Passed++
+		case "FAIL":
+			summary.Failed++
+			summary.FailedTests = append(summary.FailedTests, match[2])
+		case "SKIP":
+			summary.Skipped++
+		}
+	}
+	return summary
+}
+
+func parseCargo(lines []string) Summary {
+	var summary Summary
+	for _, line := range lines {
+		match := cargoResultPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		switch match[2] {
+		case "ok":
+			summary.Passed++
+		case "FAILED":
+			summary.Failed++
+			summary.FailedTests = append(summary.FailedTests, match[1])
+		case "ignored":
+			summary.Skipped++
+		}
+	}
+	return summary
+}
+
+func parsePytest(lines []string) Summary {
+	var summary Summary
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if match := pytestFailedPattern.FindStringSubmatch(line); match != nil && !seen[match[1]] {
+			seen[match[1]] = true
+			summary.FailedTests = append(summary.FailedTests, match[1])
+			continue
+		}
+		// The final line looks like "==== 3 passed, 1 failed, 2 skipped in 0.12s ===="
+		if !strings.HasPrefix(line, "=") || !strings.Contains(line, " in ") {
+			continue
+		}
+		counts := pytestCountPattern.FindAllStringSubmatch(line, -1)
+		if counts == nil {
+			continue
+		}
+		summary.Passed, summary.Failed, summary.Skipped = 0, 0, 0
+		for _, count := range counts {
+			n, _ := strconv.Atoi(count[1])
+			switch count[2] {
+			case "passed", "xpassed":
+				summary.Passed += n
+			case "failed", "error", "errors":
+				summary.Failed += n
+			case "skipped", "xfailed":
+				summary.Skipped += n
+			}
+		}
+	}
+	return summary
+}
+
+// parseNPM understands the output of the test runners npm test usually starts: Jest, Mocha
+// and TAP producers such as node --test and tape
+func parseNPM(lines []string) Summary {
+	var jest, mocha, tap Summary
+	foundJest, foundMocha := false, false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if match := jestTestsPattern.FindStringSubmatch(line); match != nil {
+			foundJest = true
+			for _, count := range jestCountPattern.FindAllStringSubmatch(match[1], -1) {
+				n, _ := strconv.Atoi(count[1])
+				switch count[2] {
+				case "passed":
+					jest.Passed = n
+				case "failed":
+					jest.Failed = n
+				case "skipped", "todo":
+					jest.Skipped += n
+				}
+			}
+			continue
+		}
+		if match := jestFailurePattern.FindStringSubmatch(line); match != nil && !strings.HasPrefix(match[1], "Test suite failed") {
+			jest.FailedTests = appendUnique(jest.FailedTests, match[1])
+			continue
+		}
+		if match := mochaCountPattern.FindStringSubmatch(line); match != nil {
+			foundMocha = true
+			n, _ := strconv.Atoi(match[1])
+			switch match[2] {
+			case "passing":
+				mocha.Passed = n
+			case "failing":
+				mocha.Failed = n
+			case "pending":
+				mocha.Skipped = n
+			}
+			continue
+		}
+		if match := mochaFailurePattern.FindStringSubmatch(line); match != nil && foundMocha {
+			mocha.FailedTests = appendUnique(mocha.FailedTests, match[1])
+			continue
+		}
+		if match := tapPattern.FindStringSubmatch(line); match != nil {
+			switch {
+			case match[3] != "":
+				tap.Skipped++
+			case match[1] == "ok":
+				tap.Passed++
+			default:
+				tap.Failed++
+				tap.FailedTests = append(tap.FailedTests, match[2])
+			}
+		}
+	}
+	switch {
+	case foundJest:
+		return jest
+	case foundMocha:
+		return mocha
+	default:
+		return tap
+	}
+}
+
+// appendUnique appends s unless names already holds it
+func appendUnique(names []string, s string) []string {
+	for _, name := range names {
+		if name == s {
+			return names
+		}
+	}
+	return append(names, s)
+}
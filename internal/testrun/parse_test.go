@@ -0,0 +1,90 @@
+package testrun
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name      string
+		framework string
+		output    string
+		expected  Summary
+	}{
+		{
+			name:      "go",
+			framework: FrameworkGo,
+			output: `=== RUN   TestA
+--- PASS: TestA (0.00s)
+=== RUN   TestB
+    --- FAIL: TestB/case_1 (0.00s)
+--- FAIL: TestB (0.00s)
+--- SKIP: TestC (0.00s)
+FAIL`,
+			expected: Summary{Passed: 1, Failed: 2, Skipped: 1, FailedTests: []string{"TestB/case_1", "TestB"}},
+		},
+		{
+			name:      "cargo",
+			framework: FrameworkCargo,
+			output: `running 3 tests
+test tests::adds ... ok
+test tests::parses ... FAILED
+test tests::slow ... ignored
+
+test result: FAILED. 1 passed; 1 failed; 1 ignored`,
+			expected: Summary{Passed: 1, Failed: 1, Skipped: 1, FailedTests: []string{"tests::parses"}},
+		},
+		{
+			name:      "pytest",
+			framework: FrameworkPytest,
+			output: `PASSED tests/test_app.py::test_ok
+FAILED tests/test_app.py::test_bad - AssertionError: boom
+ERROR tests/test_db.py::test_conn - ConnectionError
+==== 3 passed, 1 failed, 1 error, 2 skipped in 0.12s ====`,
+			expected: Summary{Passed: 3, Failed: 2, Skipped: 2, FailedTests: []string{"tests/test_app.py::test_bad", "tests/test_db.py::test_conn"}},
+		},
+		{
+			name:      "jest",
+			framework: FrameworkNPM,
+			output: `  ● Math › divides by zero
+
+Tests:       1 failed, 1 skipped, 5 passed, 7 total`,
+			expected: Summary{Passed: 5, Failed: 1, Skipped: 1, FailedTests: []string{"Math › divides by zero"}},
+		},
+		{
+			name:      "mocha",
+			framework: FrameworkNPM,
+			output: `  5 passing (20ms)
+  1 pending
+  1 failing
+
+  1) Parser
+       rejects bad input:`,
+			expected: Summary{Passed: 5, Failed: 1, Skipped: 1, FailedTests: []string{"Parser"}},
+		},
+		{
+			name:      "tap",
+			framework: FrameworkNPM,
+			output: `ok 1 - adds
+not ok 2 - subtracts
+ok 3 - later # SKIP not ready`,
+			expected: Summary{Passed: 1, Failed: 1, Skipped: 1, FailedTests: []string{"subtracts"}},
+		},
+		{
+			name:      "no results",
+			framework: FrameworkGo,
+			output:    "build failed",
+			expected:  Summary{FailedTests: []string{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			summary := Parse(tc.framework, tc.output)
+			if !reflect.DeepEqual(summary, tc.expected) {
+				t.Errorf("Expected %+v but got %+v", tc.expected, summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package testrun detects a project's test framework, builds the command that runs its suite
+// in the VM and parses the output into a summary
+package testrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// Framework names
+const (
+	FrameworkGo     = "go"
+	FrameworkNPM    = "npm"
+	FrameworkPytest = "pytest"
+	FrameworkCargo  = "cargo"
+)
+
+// Frameworks lists the supported frameworks in detection order
+var Frameworks = []string{FrameworkGo, FrameworkCargo, FrameworkNPM, FrameworkPytest}
+
+// pytestMarkers are files whose presence marks a Python project
+var pytestMarkers = []string{"pytest.ini", "conftest.py", "pyproject.toml", "setup.cfg", "tox.ini", "requirements.txt", "setup.py"}
+
+// Options adjust the test command
+type Options struct {
+	// Filter selects tests by name, in the framework's own syntax
+	Filter string
+	// Coverage writes a coverage report the tool can fetch afterwards
+	Coverage bool
+	// Args are appended to the command
+	Args []string
+}
+
+// Detect returns the framework of the project in dir
+func Detect(dir string) (string, error) {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	switch {
+	case exists("go.mod"):
+		return FrameworkGo, nil
+	case exists("Cargo.toml"):
+		return FrameworkCargo, nil
+	case hasNPMTestScript(filepath.Join(dir, "package.json")):
+		return FrameworkNPM, nil
+	}
+	for _, marker := range pytestMarkers {
+		if exists(marker) {
+			return FrameworkPytest, nil
+		}
+	}
+	return "", fmt.Errorf("no supported test framework found in %s (looked for go.mod, Cargo.toml, a package.json test script and Python project files)", dir)
+}
+
+// hasNPMTestScript reports whether a package.json defines a test script
+func hasNPMTestScript(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return false
+	}
+	return pkg.Scripts["test"] != ""
+}
+
+// Command returns the shell command that runs the suite
+func Command(framework string, opts Options) (string, error) {
+	var parts []string
+	switch framework {
+	case FrameworkGo:
+		parts = []string{"go", "test", "-v"}
+		if opts.Filter != "" {
+			parts = append(parts, "-run", shellquote.Quote(opts.Filter))
+		}
+		if opts.Coverage {
+			parts = append(parts, "-coverprofile="+CoverageArtifact(framework))
+		}
+		parts = append(parts, quoteAll(opts.Args)...)
+		parts = append(parts, "./...")
+	case FrameworkCargo:
+		parts = []string{"cargo", "test"}
+		if opts.Filter != "" {
+			parts = append(parts, shellquote.Quote(opts.Filter))
+		}
+		parts = append(parts, quoteAll(opts.Args)...)
+	case FrameworkNPM:
+		parts = []string{"npm", "test"}
+		var extra []string
+		if opts.Coverage {
+			extra = append(extra, "--coverage")
+		}
+		if opts.Filter != "" {
+			extra = append(extra, shellquote.Quote(opts.Filter))
+		}
+		extra = append(extra, quoteAll(opts.Args)...)
+		if len(extra) > 0 {
+			parts = append(append(parts, "--"), extra...)
+		}
+	case FrameworkPytest:
+		parts = []string{"python3", "-m", "pytest", "-rA"}
+		if opts.Filter != "" {
+			parts = append(parts, "-k", shellquote.Quote(opts.Filter))
+		}
+		if opts.Coverage {
+			parts = append(parts, "--cov", "--cov-report=xml:"+CoverageArtifact(framework))
+		}
+		parts = append(parts, quoteAll(opts.Args)...)
+	default:
+		return "", fmt.Errorf("unsupported test framework '%s' (must be one of %s)", framework, strings.Join(Frameworks, ", "))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// CoverageArtifact returns the path, relative to the working directory, of the coverage
+// report a framework writes, or an empty string when it writes none
+func CoverageArtifact(framework string) string {
+	switch framework {
+	case FrameworkGo:
+		return "coverage.out"
+	case FrameworkNPM:
+		return "coverage"
+	case FrameworkPytest:
+		return "coverage.xml"
+	default:
+		return ""
+	}
+}
+
+// quoteAll quotes each argument for the shell
+func quoteAll(args []string) []string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellquote.Quote(arg))
+	}
+	return quoted
+}
@@ -0,0 +1,78 @@
+package testrun
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{name: "go", files: map[string]string{"go.mod": "module x"}, expected: FrameworkGo},
+		{name: "cargo", files: map[string]string{"Cargo.toml": "[package]"}, expected: FrameworkCargo},
+		{name: "npm", files: map[string]string{"package.json": `{"scripts": {"test": "jest"}}`}, expected: FrameworkNPM},
+		{name: "npm without test script", files: map[string]string{"package.json": `{"scripts": {}}`, "requirements.txt": "pytest"}, expected: FrameworkPytest},
+		{name: "pytest", files: map[string]string{"pyproject.toml": "[project]"}, expected: FrameworkPytest},
+		{name: "none", files: map[string]string{"README.md": "hello"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+			}
+			framework, err := Detect(dir)
+			if tc.expected == "" {
+				if err == nil {
+					t.Errorf("Expected an error but got framework %s", framework)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to detect framework: %v", err)
+			}
+			if framework != tc.expected {
+				t.Errorf("Expected framework %s but got %s", tc.expected, framework)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	testCases := []struct {
+		name      string
+		framework string
+		opts      Options
+		expected  string
+	}{
+		{name: "go", framework: FrameworkGo, expected: "go test -v ./..."},
+		{name: "go filter and coverage", framework: FrameworkGo, opts: Options{Filter: "TestX", Coverage: true}, expected: "go test -v -run 'TestX' -coverprofile=coverage.out ./..."},
+		{name: "cargo filter", framework: FrameworkCargo, opts: Options{Filter: "parser"}, expected: "cargo test 'parser'"},
+		{name: "npm", framework: FrameworkNPM, expected: "npm test"},
+		{name: "npm coverage", framework: FrameworkNPM, opts: Options{Coverage: true, Args: []string{"--ci"}}, expected: "npm test -- --coverage '--ci'"},
+		{name: "pytest", framework: FrameworkPytest, opts: Options{Filter: "not slow", Coverage: true}, expected: "python3 -m pytest -rA -k 'not slow' --cov --cov-report=xml:coverage.xml"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			command, err := Command(tc.framework, tc.opts)
+			if err != nil {
+				t.Fatalf("Failed to build command: %v", err)
+			}
+			if command != tc.expected {
+				t.Errorf("Expected command %q but got %q", tc.expected, command)
+			}
+		})
+	}
+
+	if _, err := Command("maven", Options{}); err == nil {
+		t.Error("Expected an error for an unsupported framework")
+	}
+}
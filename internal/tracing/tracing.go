@@ -0,0 +1,304 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package tracing records spans for tool calls, vagrant commands, guest commands and syncs with
+// the OpenTelemetry SDK and exports them to a collector over OTLP/HTTP. Tracing is off unless
+// an OTLP endpoint is configured in the server config file or the environment.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Export settings
+const (
+	DefaultServiceName = "vagrant-mcp-server"
+	scopeName          = "github.com/vagrant-mcp/server"
+)
+
+// GlobalTracer is the tracer shared by the server
+var GlobalTracer = NewTracerFromEnv()
+
+// Attribute is a key and value recorded on a span
+type Attribute = attribute.KeyValue
+
+// String returns a string attribute
+func String(key, value string) Attribute { return attribute.String(key, value) }
+
+// Int returns an integer attribute
+func Int(key string, value int) Attribute { return attribute.Int(key, value) }
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute { return attribute.Bool(key, value) }
+
+// Span is a timed operation. A nil span, returned while tracing is off, ignores every call.
+type Span struct {
+	span trace.Span
+}
+
+// Config configures a tracer
+type Config struct {
+	// Endpoint is the OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces. An empty
+	// endpoint disables tracing.
+	Endpoint string
+	// Headers are sent to the collector with every export
+	Headers map[string]string
+	// ServiceName is the service reported with the spans; DefaultServiceName when empty
+	ServiceName string
+	// SampleRatio is the share of new traces recorded, from 0 to 1. Spans started under a
+	// recorded span are recorded too.
+	SampleRatio float64
+}
+
+// Tracer creates spans and exports them in batches
+type Tracer struct {
+	// provider is nil while tracing is off
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// NewTracer creates a tracer exporting to an OTLP/HTTP collector
+func NewTracer(config Config) *Tracer {
+	if config.Endpoint == "" {
+		return &Tracer{}
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(config.Endpoint),
+		otlptracehttp.WithHeaders(config.Headers),
+	)
+	if err != nil {
+		log.Warn().Err(err).Str("endpoint", config.Endpoint).Msg("Failed to create trace exporter; tracing is off")
+		return &Tracer{}
+	}
+	return newTracer(config, sdktrace.NewBatchSpanProcessor(exporter))
+}
+
+// newTracer creates a tracer handing its spans to processor
+func newTracer(config Config, processor sdktrace.SpanProcessor) *Tracer {
+	service := config.ServiceName
+	if service == "" {
+		service = DefaultServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	return &Tracer{provider: provider, tracer: provider.Tracer(scopeName)}
+}
+
+// NewTracerFromEnv creates a tracer configured from the tracing section of the server config
+// file. The standard OpenTelemetry variables OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+// OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME and
+// OTEL_TRACES_SAMPLER_ARG override it.
+func NewTracerFromEnv() *Tracer {
+	return NewTracer(resolveConfig(loadSettings(), os.Getenv))
+}
+
+// loadSettings returns the tracing section of the server config file, or no settings without
+// a valid file
+func loadSettings() config.Tracing {
+	file := config.ServerFile()
+	if file == "" {
+		return config.Tracing{}
+	}
+	server, err := config.LoadServerFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("file", file).Msg("Failed to load config file; tracing settings are taken from the environment")
+		}
+		return config.Tracing{}
+	}
+	return server.Tracing
+}
+
+// resolveConfig returns the tracer config of the config file's settings with the environment
+// variables read by getenv applied over them
+func resolveConfig(settings config.Tracing, getenv func(string) string) Config {
+	result := Config{ServiceName: settings.ServiceName, SampleRatio: 1, Headers: make(map[string]string)}
+	if settings.Endpoint != "" {
+		result.Endpoint = strings.TrimSuffix(settings.Endpoint, "/") + "/v1/traces"
+	}
+	if base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+		result.Endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		result.Endpoint = endpoint
+	}
+	for key, value := range settings.Headers {
+		result.Headers[key] = value
+	}
+	for key, value := range parseHeaders(getenv("OTEL_EXPORTER_OTLP_HEADERS")) {
+		result.Headers[key] = value
+	}
+	if service := getenv("OTEL_SERVICE_NAME"); service != "" {
+		result.ServiceName = service
+	}
+	if settings.SampleRatio != nil {
+		result.SampleRatio = *settings.SampleRatio
+	}
+	if value := getenv("OTEL_TRACES_SAMPLER_ARG"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			log.Warn().Str("value", value).Msg("Ignoring invalid OTEL_TRACES_SAMPLER_ARG")
+		} else {
+			result.SampleRatio = ratio
+		}
+	}
+	return result
+}
+
+// parseHeaders parses "key=value,key2=value2"
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(key) != "" {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+	}
+	return headers
+}
+
+// Enabled reports whether spans are recorded
+func (t *Tracer) Enabled() bool {
+	return t.provider != nil
+}
+
+// Start starts a span as a child of the span in ctx and returns a context carrying it
+func (t *Tracer) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	if !t.Enabled() {
+		return ctx, nil
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+	return ctx, &Span{span: span}
+}
+
+// Start starts a span with the global tracer
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return GlobalTracer.Start(ctx, name, attributes...)
+}
+
+// SetAttributes records attributes on the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attributes...)
+}
+
+// End ends the span, marking it failed when err is set, and queues it for export
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	} else {
+		s.span.SetStatus(codes.Ok, "")
+	}
+	s.span.End()
+}
+
+// EndCommand ends a span for a command, recording its exit code
+func (s *Span) EndCommand(err error) {
+	s.SetAttributes(Int("exit_code", ExitCode(err)))
+	s.End(err)
+}
+
+// ExitCode returns the exit code of a command from the error it returned
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Flush exports the spans that have ended
+func (t *Tracer) Flush(ctx context.Context) error {
+	if !t.Enabled() {
+		return nil
+	}
+	return t.provider.ForceFlush(ctx)
+}
+
+// Shutdown exports the spans that have ended and stops the tracer; spans started afterwards
+// are not recorded
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if !t.Enabled() {
+		return nil
+	}
+	return t.provider.Shutdown(ctx)
+}
+
+// Middleware records a span for every tool call
+func (t *Tracer) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !t.Enabled() {
+				return next(ctx, request)
+			}
+			attributes := []Attribute{String("tool.name", request.Params.Name)}
+			arguments := request.GetArguments()
+			for _, key := range []string{"vm_name", "name"} {
+				if vmName, ok := arguments[key].(string); ok && vmName != "" {
+					attributes = append(attributes, String("vm.name", vmName))
+					break
+				}
+			}
+			ctx, span := t.Start(ctx, "tool "+request.Params.Name, attributes...)
+			result, err := next(ctx, request)
+			if err == nil && result != nil && result.IsError {
+				err = errors.New(toolErrorText(result))
+			}
+			span.SetAttributes(Bool("tool.error", err != nil))
+			span.End(err)
+			return result, err
+		}
+	}
+}
+
+// toolErrorText returns the text of an error result
+func toolErrorText(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			return text.Text
+		}
+	}
+	return "tool returned an error"
+}
+
+// CommandName returns the program a shell command runs, without its arguments, so spans do
+// not carry values passed on the command line
+func CommandName(command string) string {
+	fields := strings.Fields(command)
+	for _, field := range fields {
+		// Skip leading environment assignments and sudo
+		if strings.Contains(field, "=") || field == "sudo" || strings.HasPrefix(field, "-") {
+			continue
+		}
+		return field
+	}
+	return ""
+}
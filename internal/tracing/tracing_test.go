@@ -0,0 +1,180 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/config"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDisabledTracer(t *testing.T) {
+	tracer := NewTracer(Config{})
+	if tracer.Enabled() {
+		t.Fatalf("Expected tracer without endpoint to be disabled")
+	}
+	ctx, span := tracer.Start(context.Background(), "noop")
+	if span != nil {
+		t.Errorf("Expected nil span but got %v", span)
+	}
+	// A nil span ignores every call
+	span.SetAttributes(String("key", "value"))
+	span.EndCommand(errors.New("failed"))
+	if err := tracer.Flush(ctx); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := newTracer(Config{ServiceName: "test-service", SampleRatio: 1}, sdktrace.NewSimpleSpanProcessor(exporter))
+	ctx, parent := tracer.Start(context.Background(), "tool create_dev_vm", String("tool.name", "create_dev_vm"))
+	_, child := tracer.Start(ctx, "vagrant up", Int("attempt", 1))
+	child.EndCommand(errors.New("boom"))
+	parent.End(nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans but got %d", len(spans))
+	}
+	childSpan, parentSpan := spans[0], spans[1]
+	if service, _ := parentSpan.Resource.Set().Value("service.name"); service.AsString() != "test-service" {
+		t.Errorf("Expected service test-service but got %v", service.AsString())
+	}
+	if childSpan.SpanContext.TraceID() != parentSpan.SpanContext.TraceID() {
+		t.Errorf("Expected child to share trace %v but got %v", parentSpan.SpanContext.TraceID(), childSpan.SpanContext.TraceID())
+	}
+	if childSpan.Parent.SpanID() != parentSpan.SpanContext.SpanID() {
+		t.Errorf("Expected parent %v but got %v", parentSpan.SpanContext.SpanID(), childSpan.Parent.SpanID())
+	}
+	if parentSpan.Parent.IsValid() {
+		t.Errorf("Expected root span without parent")
+	}
+	if childSpan.Status.Code != codes.Error || childSpan.Status.Description != "boom" {
+		t.Errorf("Expected error status but got %v", childSpan.Status)
+	}
+	if parentSpan.Status.Code != codes.Ok {
+		t.Errorf("Expected ok status but got %v", parentSpan.Status)
+	}
+	exitCode := -2
+	for _, attribute := range childSpan.Attributes {
+		if attribute.Key == "exit_code" {
+			exitCode = int(attribute.Value.AsInt64())
+		}
+	}
+	if exitCode != -1 {
+		t.Errorf("Expected exit code -1 but got %d", exitCode)
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := newTracer(Config{SampleRatio: 0}, sdktrace.NewSimpleSpanProcessor(exporter))
+	ctx, parent := tracer.Start(context.Background(), "tool list_vms")
+	_, child := tracer.Start(ctx, "vagrant status")
+	child.End(nil)
+	parent.End(nil)
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("Expected no spans to be recorded but got %d", len(spans))
+	}
+}
+
+func TestExport(t *testing.T) {
+	var mu sync.Mutex
+	var path, header string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path = r.URL.Path
+		header = r.Header.Get("X-Token")
+	}))
+	defer collector.Close()
+
+	getenv := func(name string) string {
+		return map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "X-Token=secret"}[name]
+	}
+	tracer := NewTracer(resolveConfig(config.Tracing{Endpoint: collector.URL}, getenv))
+	_, span := tracer.Start(context.Background(), "exec")
+	span.End(nil)
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if path != "/v1/traces" {
+		t.Errorf("Expected spans posted to /v1/traces but got %q", path)
+	}
+	if header != "secret" {
+		t.Errorf("Expected header secret but got %q", header)
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	half := 0.5
+	settings := config.Tracing{
+		Endpoint:    "http://collector:4318/",
+		Headers:     map[string]string{"api-key": "file", "x-tenant": "dev"},
+		ServiceName: "from-file",
+		SampleRatio: &half,
+	}
+
+	resolved := resolveConfig(settings, func(string) string { return "" })
+	if resolved.Endpoint != "http://collector:4318/v1/traces" || resolved.ServiceName != "from-file" || resolved.SampleRatio != 0.5 {
+		t.Errorf("Expected the config file settings but got %+v", resolved)
+	}
+
+	env := map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://ignored:4318",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://env:4318/traces",
+		"OTEL_EXPORTER_OTLP_HEADERS":         "api-key=env",
+		"OTEL_SERVICE_NAME":                  "from-env",
+		"OTEL_TRACES_SAMPLER_ARG":            "0.1",
+	}
+	resolved = resolveConfig(settings, func(name string) string { return env[name] })
+	if resolved.Endpoint != "https://env:4318/traces" || resolved.ServiceName != "from-env" || resolved.SampleRatio != 0.1 {
+		t.Errorf("Expected the environment to override the config file but got %+v", resolved)
+	}
+	if resolved.Headers["api-key"] != "env" || resolved.Headers["x-tenant"] != "dev" {
+		t.Errorf("Expected headers merged over the config file's but got %v", resolved.Headers)
+	}
+
+	// Every trace is sampled by default, and an invalid ratio is ignored
+	resolved = resolveConfig(config.Tracing{}, func(name string) string {
+		return map[string]string{"OTEL_TRACES_SAMPLER_ARG": "2"}[name]
+	})
+	if resolved.Endpoint != "" || resolved.SampleRatio != 1 {
+		t.Errorf("Expected tracing off and a ratio of 1 but got %+v", resolved)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers := parseHeaders("api-key=abc, x-tenant = dev,invalid,")
+	if len(headers) != 2 || headers["api-key"] != "abc" || headers["x-tenant"] != "dev" {
+		t.Errorf("Expected 2 parsed headers but got %v", headers)
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	testCases := []struct {
+		command  string
+		expected string
+	}{
+		{"go test ./...", "go"},
+		{"FOO=bar sudo -n apt-get install -y git", "apt-get"},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			if got := CommandName(tc.command); got != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, got)
+			}
+		})
+	}
+}
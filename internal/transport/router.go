@@ -0,0 +1,165 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package transport
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// JSON-RPC methods the mcp-go server does not route itself
+const (
+	// MethodCompletionComplete is the JSON-RPC method for argument completion
+	MethodCompletionComplete = "completion/complete"
+	// MethodResourcesSubscribe is the JSON-RPC method for subscribing to resource updates
+	MethodResourcesSubscribe = "resources/subscribe"
+	// MethodResourcesUnsubscribe is the JSON-RPC method for cancelling a resource subscription
+	MethodResourcesUnsubscribe = "resources/unsubscribe"
+)
+
+// CompletionHandler answers completion/complete requests
+type CompletionHandler interface {
+	Complete(ctx context.Context, params mcp.CompleteParams) (*mcp.CompleteResult, error)
+}
+
+// SubscriptionHandler keeps the resource subscriptions of client sessions
+type SubscriptionHandler interface {
+	Subscribe(sessionID, uri string)
+	Unsubscribe(sessionID, uri string)
+}
+
+// rpcRequest is the subset of a JSON-RPC message needed to route it
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// subscribeParams are the parameters of resources/subscribe and resources/unsubscribe
+type subscribeParams struct {
+	URI string `json:"uri"`
+}
+
+// Router answers the protocol methods the mcp-go server does not route itself and passes
+// every other message to the server. Both transports deliver client messages through it.
+type Router struct {
+	srv           *server.MCPServer
+	completions   CompletionHandler
+	subscriptions SubscriptionHandler
+}
+
+// NewRouter creates a router in front of srv
+func NewRouter(srv *server.MCPServer, completions CompletionHandler, subscriptions SubscriptionHandler) *Router {
+	return &Router{srv: srv, completions: completions, subscriptions: subscriptions}
+}
+
+// HandleMessage handles one JSON-RPC message from a client and returns the response, or nil for
+// notifications. ctx carries the client session.
+func (r *Router) HandleMessage(ctx context.Context, message json.RawMessage) mcp.JSONRPCMessage {
+	var request rpcRequest
+	if err := json.Unmarshal(message, &request); err != nil || len(request.ID) == 0 {
+		return r.srv.HandleMessage(ctx, message)
+	}
+
+	switch request.Method {
+	case string(mcp.MethodInitialize):
+		return withCompletionsCapability(r.srv.HandleMessage(ctx, message))
+	case MethodCompletionComplete:
+		return r.handleCompletion(ctx, request)
+	case MethodResourcesSubscribe, MethodResourcesUnsubscribe:
+		return r.handleSubscription(ctx, request)
+	default:
+		return r.srv.HandleMessage(ctx, message)
+	}
+}
+
+// withCompletionsCapability adds the completions capability, which the mcp-go server does not
+// know about, to an initialize response
+func withCompletionsCapability(response mcp.JSONRPCMessage) mcp.JSONRPCMessage {
+	initialized, ok := response.(mcp.JSONRPCResponse)
+	if !ok {
+		return response
+	}
+	data, err := json.Marshal(initialized.Result)
+	if err != nil {
+		return response
+	}
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return response
+	}
+	capabilities, ok := result["capabilities"].(map[string]any)
+	if !ok {
+		return response
+	}
+	capabilities["completions"] = map[string]any{}
+	initialized.Result = result
+	return initialized
+}
+
+// handleCompletion answers a completion request
+func (r *Router) handleCompletion(ctx context.Context, request rpcRequest) mcp.JSONRPCMessage {
+	var params mcp.CompleteParams
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return errorResponse(request.ID, mcp.INVALID_PARAMS, err.Error())
+	}
+
+	result, err := r.completions.Complete(ctx, params)
+	if err != nil {
+		return errorResponse(request.ID, mcp.INTERNAL_ERROR, err.Error())
+	}
+	return resultResponse(request.ID, result)
+}
+
+// handleSubscription subscribes the client session to a resource or cancels the subscription
+func (r *Router) handleSubscription(ctx context.Context, request rpcRequest) mcp.JSONRPCMessage {
+	var params subscribeParams
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return errorResponse(request.ID, mcp.INVALID_PARAMS, err.Error())
+	}
+	if params.URI == "" {
+		return errorResponse(request.ID, mcp.INVALID_PARAMS, "Missing required parameter: uri")
+	}
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return errorResponse(request.ID, mcp.INVALID_REQUEST, "Subscriptions need a client session")
+	}
+
+	if request.Method == MethodResourcesSubscribe {
+		r.subscriptions.Subscribe(session.SessionID(), params.URI)
+	} else {
+		r.subscriptions.Unsubscribe(session.SessionID(), params.URI)
+	}
+	return resultResponse(request.ID, mcp.EmptyResult{})
+}
+
+// resultResponse builds a JSON-RPC response carrying result
+func resultResponse(id json.RawMessage, result any) mcp.JSONRPCMessage {
+	return mcp.JSONRPCResponse{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      requestID(id),
+		Result:  result,
+	}
+}
+
+// errorResponse builds a JSON-RPC error response
+func errorResponse(id json.RawMessage, code int, message string) mcp.JSONRPCMessage {
+	response := mcp.JSONRPCError{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      requestID(id),
+	}
+	response.Error.Code = code
+	response.Error.Message = message
+	return response
+}
+
+// requestID decodes a raw JSON-RPC request ID
+func requestID(id json.RawMessage) mcp.RequestId {
+	var requestID mcp.RequestId
+	_ = json.Unmarshal(id, &requestID)
+	return requestID
+}
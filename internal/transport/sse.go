@@ -0,0 +1,95 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+)
+
+// SSE serves the MCP server over SSE. The mcp-go SSE server keeps the event streams and their
+// sessions; client messages posted to the message endpoint are handled with the router.
+type SSE struct {
+	sse      *server.SSEServer
+	srv      *server.MCPServer
+	router   *Router
+	sessions sync.Map
+}
+
+// NewSSE creates an SSE transport for srv. hooks must be the hooks srv was created with, so the
+// transport learns about the sessions of new event streams.
+func NewSSE(srv *server.MCPServer, hooks *server.Hooks, router *Router) *SSE {
+	t := &SSE{sse: server.NewSSEServer(srv), srv: srv, router: router}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		t.sessions.Store(session.SessionID(), session)
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		t.sessions.Delete(session.SessionID())
+	})
+	return t
+}
+
+// Start serves SSE connections on addr
+func (t *SSE) Start(addr string) error {
+	return http.ListenAndServe(addr, t.Handler())
+}
+
+// Handler returns the HTTP handler of the SSE and message endpoints
+func (t *SSE) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(t.sse.CompleteSsePath(), t.sse.SSEHandler())
+	mux.HandleFunc(t.sse.CompleteMessagePath(), t.handleMessage)
+	return mux
+}
+
+// handleMessage accepts a JSON-RPC message from a client and sends the response over the
+// client's event stream
+func (t *SSE) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTPError(w, mcp.INVALID_REQUEST, "Method not allowed")
+		return
+	}
+	sessionID := r.URL.Query().Get("sessionId")
+	if sessionID == "" {
+		writeHTTPError(w, mcp.INVALID_PARAMS, "Missing sessionId")
+		return
+	}
+	value, ok := t.sessions.Load(sessionID)
+	if !ok {
+		writeHTTPError(w, mcp.INVALID_PARAMS, "Invalid session ID")
+		return
+	}
+
+	var message json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		writeHTTPError(w, mcp.PARSE_ERROR, "Parse error")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	// The request ends with the 202; the message is handled for as long as it takes
+	ctx := t.srv.WithContext(context.WithoutCancel(r.Context()), value.(server.ClientSession))
+	go func() {
+		if response := t.router.HandleMessage(ctx, message); response != nil {
+			if err := t.sse.SendEventToSession(sessionID, response); err != nil {
+				log.Error().Err(err).Str("session", sessionID).Msg("Failed to send JSON-RPC response")
+			}
+		}
+	}()
+}
+
+// writeHTTPError rejects a posted message with a JSON-RPC error
+func writeHTTPError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(w).Encode(errorResponse(nil, code, message)); err != nil {
+		log.Error().Err(err).Msg("Failed to write JSON-RPC error")
+	}
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/resources"
+)
+
+func TestSSECompletionAndCapabilities(t *testing.T) {
+	hooks := &server.Hooks{}
+	srv := server.NewMCPServer("test", "1.0", server.WithHooks(hooks), server.WithResourceCapabilities(true, true))
+	httpServer := httptest.NewServer(NewSSE(srv, hooks, NewRouter(srv, stubCompleter{}, resources.NewSubscriptions())).Handler())
+	defer httpServer.Close()
+
+	stream, err := http.Get(httpServer.URL + "/sse")
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer stream.Body.Close()
+
+	events := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(stream.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- data
+			}
+		}
+	}()
+	receive := func() string {
+		t.Helper()
+		select {
+		case data := <-events:
+			return data
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected an event but got none")
+			return ""
+		}
+	}
+	endpoint := httpServer.URL + receive()
+	call := func(id int, method string, params map[string]any) map[string]any {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+		response, err := http.Post(endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to post message: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusAccepted {
+			t.Fatalf("Expected status %d but got %d", http.StatusAccepted, response.StatusCode)
+		}
+		var message map[string]any
+		if err := json.Unmarshal([]byte(receive()), &message); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return message
+	}
+
+	response := call(1, "initialize", map[string]any{
+		"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+		"clientInfo":      map[string]any{"name": "test", "version": "1.0"},
+	})
+	result, _ := response["result"].(map[string]any)
+	capabilities, _ := result["capabilities"].(map[string]any)
+	if _, ok := capabilities["completions"]; !ok {
+		t.Errorf("Expected the completions capability but got %v", capabilities)
+	}
+	if resourceCapabilities, _ := capabilities["resources"].(map[string]any); resourceCapabilities["subscribe"] != true {
+		t.Errorf("Expected the resource subscribe capability but got %v", capabilities["resources"])
+	}
+
+	response = call(2, MethodCompletionComplete, map[string]any{
+		"ref":      map[string]any{"type": "ref/resource", "uri": resources.ConfigTemplateURI},
+		"argument": map[string]any{"name": "vmName", "value": "d"},
+	})
+	result, _ = response["result"].(map[string]any)
+	if completion, _ := result["completion"].(map[string]any); completion == nil {
+		t.Errorf("Expected a completion result but got %v", response)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package transport serves the MCP server over stdio and SSE with support for
+// protocol methods the mcp-go server does not route itself
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+)
+
+// stdioSessionID identifies the single client session on stdio
+const stdioSessionID = "stdio"
+
+// stdioSession is the session of the single client on stdio
+type stdioSession struct {
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+// SessionID returns the ID of the stdio session
+func (s *stdioSession) SessionID() string {
+	return stdioSessionID
+}
+
+// NotificationChannel returns the channel notifications to the client are queued on
+func (s *stdioSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// Initialize marks the session ready for notifications
+func (s *stdioSession) Initialize() {
+	s.initialized.Store(true)
+}
+
+// Initialized reports whether the session is ready for notifications
+func (s *stdioSession) Initialized() bool {
+	return s.initialized.Load()
+}
+
+// ServeStdio serves srv over stdin/stdout, handling client messages with router
+func ServeStdio(srv *server.MCPServer, router *Router) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	return listen(ctx, srv, router, os.Stdin, &responseWriter{w: os.Stdout})
+}
+
+// listen reads JSON-RPC messages from in, one per line, and writes the responses and the
+// notifications of the stdio session to out
+func listen(ctx context.Context, srv *server.MCPServer, router *Router, in io.Reader, out io.Writer) error {
+	session := &stdioSession{notifications: make(chan mcp.JSONRPCNotification, 100)}
+	if err := srv.RegisterSession(ctx, session); err != nil {
+		return fmt.Errorf("register session: %w", err)
+	}
+	defer srv.UnregisterSession(ctx, session.SessionID())
+	ctx = srv.WithContext(ctx, session)
+
+	go func() {
+		for {
+			select {
+			case notification := <-session.notifications:
+				writeMessage(out, notification)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	lines := readLines(in)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var message json.RawMessage
+			if err := json.Unmarshal(line, &message); err != nil {
+				writeMessage(out, errorResponse(nil, mcp.PARSE_ERROR, "Parse error"))
+				continue
+			}
+			if response := router.HandleMessage(ctx, message); response != nil {
+				writeMessage(out, response)
+			}
+		}
+	}
+}
+
+// readLines reads lines from in until it ends, closing the returned channel
+func readLines(in io.Reader) <-chan []byte {
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				lines <- line
+			}
+			if err != nil {
+				if err != io.EOF {
+					log.Error().Err(err).Msg("Failed to read from stdin")
+				}
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+// writeMessage writes a JSON-RPC message followed by a newline
+func writeMessage(out io.Writer, message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal JSON-RPC message")
+		return
+	}
+	if _, err := out.Write(append(data, '\n')); err != nil {
+		log.Error().Err(err).Msg("Failed to write JSON-RPC message")
+	}
+}
+
+// responseWriter serialises writes of JSON-RPC messages to stdout
+type responseWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes one JSON-RPC message
+func (r *responseWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.w.Write(p)
+}
@@ -0,0 +1,147 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/vagrant-mcp/server/internal/resources"
+)
+
+// stubCompleter answers every completion request with the same value
+type stubCompleter struct{}
+
+func (stubCompleter) Complete(ctx context.Context, params mcp.CompleteParams) (*mcp.CompleteResult, error) {
+	result := &mcp.CompleteResult{}
+	result.Completion.Values = []string{"dev-vm"}
+	return result, nil
+}
+
+// stdioClient talks to a server served with listen
+type stdioClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	output chan map[string]any
+}
+
+func startStdio(t *testing.T, subscriptions *resources.Subscriptions) (*server.MCPServer, *stdioClient) {
+	t.Helper()
+	srv := server.NewMCPServer("test", "1.0", server.WithResourceCapabilities(true, true))
+	router := NewRouter(srv, stubCompleter{}, subscriptions)
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		inWriter.Close()
+		outReader.Close()
+	})
+	go listen(ctx, srv, router, inReader, &responseWriter{w: outWriter})
+
+	client := &stdioClient{t: t, in: inWriter, output: make(chan map[string]any, 10)}
+	go func() {
+		scanner := bufio.NewScanner(outReader)
+		for scanner.Scan() {
+			var message map[string]any
+			if json.Unmarshal(scanner.Bytes(), &message) == nil {
+				client.output <- message
+			}
+		}
+	}()
+	return srv, client
+}
+
+func (c *stdioClient) send(message map[string]any) {
+	c.t.Helper()
+	data, _ := json.Marshal(message)
+	if _, err := c.in.Write(append(data, '\n')); err != nil {
+		c.t.Fatalf("Failed to write message: %v", err)
+	}
+}
+
+func (c *stdioClient) receive() map[string]any {
+	c.t.Helper()
+	select {
+	case message := <-c.output:
+		return message
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("Expected a message but got none")
+		return nil
+	}
+}
+
+func (c *stdioClient) call(id int, method string, params map[string]any) map[string]any {
+	c.t.Helper()
+	c.send(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	return c.receive()
+}
+
+func TestStdioResourceSubscriptions(t *testing.T) {
+	subscriptions := resources.NewSubscriptions()
+	srv, client := startStdio(t, subscriptions)
+
+	response := client.call(1, "initialize", map[string]any{
+		"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+		"clientInfo":      map[string]any{"name": "test", "version": "1.0"},
+	})
+	result, _ := response["result"].(map[string]any)
+	if capabilities, _ := result["capabilities"].(map[string]any); capabilities["completions"] == nil {
+		t.Errorf("Expected the completions capability but got %v", result["capabilities"])
+	}
+	client.send(map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"})
+
+	response = client.call(2, MethodResourcesSubscribe, map[string]any{"uri": resources.EventsResourceURI})
+	if response["error"] != nil {
+		t.Fatalf("Expected the subscription to succeed but got %v", response["error"])
+	}
+	if subscribers := subscriptions.Subscribers(resources.EventsResourceURI); len(subscribers) != 1 || subscribers[0] != stdioSessionID {
+		t.Fatalf("Expected the stdio session to be subscribed but got %v", subscribers)
+	}
+
+	subscriptions.Notify(srv, resources.StatusResourceURI)
+	subscriptions.Notify(srv, resources.EventsResourceURI)
+	notification := client.receive()
+	if notification["method"] != mcp.MethodNotificationResourceUpdated {
+		t.Fatalf("Expected a resource updated notification but got %v", notification)
+	}
+	if params, _ := notification["params"].(map[string]any); params["uri"] != resources.EventsResourceURI {
+		t.Errorf("Expected an update for %s only but got %v", resources.EventsResourceURI, notification["params"])
+	}
+
+	response = client.call(3, MethodResourcesUnsubscribe, map[string]any{"uri": resources.EventsResourceURI})
+	if response["error"] != nil {
+		t.Fatalf("Expected the unsubscription to succeed but got %v", response["error"])
+	}
+	if subscribers := subscriptions.Subscribers(resources.EventsResourceURI); len(subscribers) != 0 {
+		t.Errorf("Expected no subscribers but got %v", subscribers)
+	}
+
+	response = client.call(4, MethodResourcesSubscribe, map[string]any{})
+	if response["error"] == nil {
+		t.Error("Expected an error for a subscription without a URI")
+	}
+}
+
+func TestStdioCompletion(t *testing.T) {
+	_, client := startStdio(t, resources.NewSubscriptions())
+
+	response := client.call(1, MethodCompletionComplete, map[string]any{
+		"ref":      map[string]any{"type": "ref/resource", "uri": resources.ConfigTemplateURI},
+		"argument": map[string]any{"name": "vmName", "value": "d"},
+	})
+	result, _ := response["result"].(map[string]any)
+	completion, _ := result["completion"].(map[string]any)
+	values, _ := completion["values"].([]any)
+	if len(values) != 1 || values[0] != "dev-vm" {
+		t.Errorf("Expected the completion values [dev-vm] but got %v", response)
+	}
+	if response["id"] != float64(1) {
+		t.Errorf("Expected response ID 1 but got %v", response["id"])
+	}
+}
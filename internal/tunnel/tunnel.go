@@ -0,0 +1,268 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package tunnel keeps SSH port forwards between the host and VMs
+package tunnel
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Forward directions
+const (
+	// DirectionLocal forwards a host port to a port reachable from the guest
+	DirectionLocal = "local"
+	// DirectionRemote forwards a guest port to a port reachable from the host
+	DirectionRemote = "remote"
+)
+
+// startupWait is how long a new tunnel must stay up before it is considered established;
+// ssh exits within it when the forward cannot be set up
+const startupWait = time.Second
+
+var (
+	// ErrTunnelNotFound is returned for unknown or closed tunnel IDs
+	ErrTunnelNotFound = errors.New("tunnel not found")
+	// ErrPortInUse is returned when another tunnel already listens on the port
+	ErrPortInUse = errors.New("port is already forwarded by another tunnel")
+)
+
+// Spec describes a port forward. For local forwards HostPort is the listening port on the host
+// and TargetHost:GuestPort is dialled from the guest; for remote forwards GuestPort is the
+// listening port in the guest and connections go to TargetHost:HostPort on the host.
+type Spec struct {
+	Direction  string `json:"direction"`
+	HostPort   int    `json:"host_port"`
+	GuestPort  int    `json:"guest_port"`
+	TargetHost string `json:"target_host"`
+}
+
+// Info describes an open tunnel
+type Info struct {
+	ID       string    `json:"id"`
+	VMName   string    `json:"vm_name"`
+	OpenedAt time.Time `json:"opened_at"`
+	Spec
+}
+
+// Tunnel is a running ssh port forward
+type Tunnel struct {
+	info   Info
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	done   chan struct{}
+}
+
+// Manager tracks open tunnels
+type Manager struct {
+	mu      sync.Mutex
+	tunnels map[string]*Tunnel
+}
+
+// Global tunnel manager instance
+var GlobalManager = NewManager()
+
+// NewManager creates a tunnel manager
+func NewManager() *Manager {
+	return &Manager{tunnels: make(map[string]*Tunnel)}
+}
+
+// Normalize fills in defaults and validates a spec. A local forward without a host port gets a
+// free one.
+func (s Spec) Normalize() (Spec, error) {
+	if s.Direction == "" {
+		s.Direction = DirectionLocal
+	}
+	if s.Direction != DirectionLocal && s.Direction != DirectionRemote {
+		return s, fmt.Errorf("invalid direction '%s' (must be %s or %s)", s.Direction, DirectionLocal, DirectionRemote)
+	}
+	if s.TargetHost == "" {
+		s.TargetHost = "localhost"
+	}
+	if strings.ContainsAny(s.TargetHost, ": \t") {
+		return s, fmt.Errorf("invalid target host '%s'", s.TargetHost)
+	}
+	if s.Direction == DirectionLocal && s.HostPort == 0 {
+		port, err := freeHostPort()
+		if err != nil {
+			return s, fmt.Errorf("failed to find a free host port: %w", err)
+		}
+		s.HostPort = port
+	}
+	for _, port := range []int{s.HostPort, s.GuestPort} {
+		if port < 1 || port > 65535 {
+			return s, fmt.Errorf("invalid port %d", port)
+		}
+	}
+	return s, nil
+}
+
+// ForwardArgs returns the ssh arguments that set up the forward
+func (s Spec) ForwardArgs() []string {
+	if s.Direction == DirectionRemote {
+		return []string{"-R", fmt.Sprintf("%d:%s:%d", s.GuestPort, s.TargetHost, s.HostPort)}
+	}
+	return []string{"-L", fmt.Sprintf("127.0.0.1:%d:%s:%d", s.HostPort, s.TargetHost, s.GuestPort)}
+}
+
+// Open starts a tunnel over ssh; sshArgs connect to the VM and end with user@host
+func (m *Manager) Open(vmName string, spec Spec, sshArgs []string) (Info, error) {
+	spec, err := spec.Normalize()
+	if err != nil {
+		return Info{}, err
+	}
+	m.mu.Lock()
+	for _, existing := range m.tunnels {
+		if existing.info.Direction != spec.Direction {
+			continue
+		}
+		if (spec.Direction == DirectionLocal && existing.info.HostPort == spec.HostPort) ||
+			(spec.Direction == DirectionRemote && existing.info.VMName == vmName && existing.info.GuestPort == spec.GuestPort) {
+			m.mu.Unlock()
+			return Info{}, ErrPortInUse
+		}
+	}
+	m.mu.Unlock()
+
+	args := []string{"-N", "-o", "ExitOnForwardFailure=yes", "-o", "ServerAliveInterval=30", "-o", "BatchMode=yes"}
+	args = append(args, spec.ForwardArgs()...)
+	args = append(args, sshArgs...)
+	return m.start(vmName, spec, exec.Command("ssh", args...))
+}
+
+// start runs cmd as a new tunnel and waits for it to either fail or become established
+func (m *Manager) start(vmName string, spec Spec, cmd *exec.Cmd) (Info, error) {
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return Info{}, fmt.Errorf("failed to start ssh: %w", err)
+	}
+
+	tunnel := &Tunnel{
+		info: Info{
+			ID:       newTunnelID(),
+			VMName:   vmName,
+			OpenedAt: time.Now(),
+			Spec:     spec,
+		},
+		cmd:    cmd,
+		stderr: stderr,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		_ = cmd.Wait()
+		close(tunnel.done)
+		m.mu.Lock()
+		delete(m.tunnels, tunnel.info.ID)
+		m.mu.Unlock()
+	}()
+
+	select {
+	case <-tunnel.done:
+		return Info{}, fmt.Errorf("ssh exited: %s", strings.TrimSpace(stderr.String()))
+	case <-time.After(startupWait):
+	}
+
+	m.mu.Lock()
+	select {
+	case <-tunnel.done:
+		m.mu.Unlock()
+		return Info{}, fmt.Errorf("ssh exited: %s", strings.TrimSpace(stderr.String()))
+	default:
+	}
+	m.tunnels[tunnel.info.ID] = tunnel
+	m.mu.Unlock()
+	log.Info().Str("tunnel", tunnel.info.ID).Str("vm", vmName).Strs("forward", spec.ForwardArgs()).Msg("Tunnel opened")
+	return tunnel.info, nil
+}
+
+// Close tears down a tunnel
+func (m *Manager) Close(id string) (Info, error) {
+	m.mu.Lock()
+	tunnel, exists := m.tunnels[id]
+	delete(m.tunnels, id)
+	m.mu.Unlock()
+	if !exists {
+		return Info{}, ErrTunnelNotFound
+	}
+	tunnel.terminate()
+	log.Info().Str("tunnel", id).Str("vm", tunnel.info.VMName).Msg("Tunnel closed")
+	return tunnel.info, nil
+}
+
+// CloseVM tears down every tunnel to a VM and returns how many were closed
+func (m *Manager) CloseVM(vmName string) int {
+	m.mu.Lock()
+	var closing []*Tunnel
+	for id, tunnel := range m.tunnels {
+		if tunnel.info.VMName == vmName {
+			closing = append(closing, tunnel)
+			delete(m.tunnels, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, tunnel := range closing {
+		tunnel.terminate()
+	}
+	if len(closing) > 0 {
+		log.Info().Str("vm", vmName).Int("count", len(closing)).Msg("Closed tunnels of VM")
+	}
+	return len(closing)
+}
+
+// List returns the open tunnels, for one VM when vmName is set, oldest first
+func (m *Manager) List(vmName string) []Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := make([]Info, 0, len(m.tunnels))
+	for _, tunnel := range m.tunnels {
+		if vmName == "" || tunnel.info.VMName == vmName {
+			infos = append(infos, tunnel.info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].OpenedAt.Before(infos[j].OpenedAt) })
+	return infos
+}
+
+// terminate kills the ssh process and waits for it to exit
+func (t *Tunnel) terminate() {
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+	}
+}
+
+// freeHostPort returns a port that is currently free on the host loopback interface
+func freeHostPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// newTunnelID returns a random tunnel identifier
+func newTunnelID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
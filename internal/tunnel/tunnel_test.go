@@ -0,0 +1,95 @@
+package tunnel
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSpecNormalize(t *testing.T) {
+	testCases := []struct {
+		name      string
+		spec      Spec
+		expectErr bool
+	}{
+		{"local forward with defaults", Spec{GuestPort: 5432}, false},
+		{"remote forward", Spec{Direction: DirectionRemote, HostPort: 8080, GuestPort: 9000}, false},
+		{"invalid direction", Spec{Direction: "sideways", HostPort: 1, GuestPort: 1}, true},
+		{"missing guest port", Spec{HostPort: 5432}, true},
+		{"remote forward without host port", Spec{Direction: DirectionRemote, GuestPort: 9000}, true},
+		{"port out of range", Spec{HostPort: 70000, GuestPort: 80}, true},
+		{"target host with a port", Spec{GuestPort: 80, TargetHost: "db:5432"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := tc.spec.Normalize()
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("Expected error %v but got %v", tc.expectErr, err)
+			}
+			if err == nil && (spec.Direction == "" || spec.TargetHost == "" || spec.HostPort == 0) {
+				t.Errorf("Expected defaults to be filled in but got %+v", spec)
+			}
+		})
+	}
+}
+
+func TestSpecForwardArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     Spec
+		expected string
+	}{
+		{"local", Spec{Direction: DirectionLocal, HostPort: 15432, GuestPort: 5432, TargetHost: "localhost"}, "-L 127.0.0.1:15432:localhost:5432"},
+		{"remote", Spec{Direction: DirectionRemote, HostPort: 8080, GuestPort: 9000, TargetHost: "localhost"}, "-R 9000:localhost:8080"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if args := strings.Join(tc.spec.ForwardArgs(), " "); args != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, args)
+			}
+		})
+	}
+}
+
+func TestTunnelLifecycle(t *testing.T) {
+	manager := NewManager()
+	spec := Spec{Direction: DirectionLocal, HostPort: 15432, GuestPort: 5432, TargetHost: "localhost"}
+
+	if _, err := manager.start("dev", spec, exec.Command("sh", "-c", "echo 'bind: Address already in use' >&2; exit 255")); err == nil || !strings.Contains(err.Error(), "Address already in use") {
+		t.Errorf("Expected the ssh error for a failed forward but got %v", err)
+	}
+	if tunnels := manager.List(""); len(tunnels) != 0 {
+		t.Errorf("Expected no tunnels after a failed forward but got %v", tunnels)
+	}
+
+	first, err := manager.start("dev", spec, exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, err := manager.Open("dev", spec, nil); !errors.Is(err, ErrPortInUse) {
+		t.Errorf("Expected ErrPortInUse for a forwarded host port but got %v", err)
+	}
+	second, err := manager.start("other", Spec{Direction: DirectionLocal, HostPort: 18080, GuestPort: 80, TargetHost: "localhost"}, exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if tunnels := manager.List("dev"); len(tunnels) != 1 || tunnels[0].ID != first.ID {
+		t.Errorf("Expected only the dev tunnel but got %v", tunnels)
+	}
+
+	if closed := manager.CloseVM("dev"); closed != 1 {
+		t.Errorf("Expected 1 tunnel closed but got %d", closed)
+	}
+	if _, err := manager.Close(first.ID); !errors.Is(err, ErrTunnelNotFound) {
+		t.Errorf("Expected ErrTunnelNotFound for a closed tunnel but got %v", err)
+	}
+	if _, err := manager.Close(second.ID); err != nil {
+		t.Errorf("Expected no error but got %v", err)
+	}
+	if tunnels := manager.List(""); len(tunnels) != 0 {
+		t.Errorf("Expected no tunnels but got %v", tunnels)
+	}
+}
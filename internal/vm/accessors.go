@@ -5,46 +5,98 @@ package vm
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
+	"time"
 
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
 	"github.com/vagrant-mcp/server/internal/errors"
 )
 
+// VMListing is a VM as its saved configuration describes it
+type VMListing struct {
+	Name        string   `json:"name"`
+	ProjectPath string   `json:"project_path,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// CreatedAt is unknown for VMs created before it was recorded
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// LastUsedAt is when a lifecycle operation last ran on the VM
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
 // GetBaseDir returns the base directory for VM storage
 func (m *Manager) GetBaseDir() string {
 	return m.baseDir
 }
 
-// ListVMs returns a list of all configured VMs
+// ListVMs returns the names of all configured VMs
 func (m *Manager) ListVMs(ctx context.Context) ([]string, error) {
-	// Check if base directory exists
-	if _, err := os.Stat(m.baseDir); os.IsNotExist(err) {
-		return nil, nil // No VMs exist yet
+	listings, err := m.ListVMDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vmNames := make([]string, 0, len(listings))
+	for _, listing := range listings {
+		vmNames = append(vmNames, listing.Name)
 	}
+	return vmNames, nil
+}
 
-	// Get all subdirectories in the base directory
-	files, err := os.ReadDir(m.baseDir)
+// ListVMDetails returns the VMs with a saved configuration, sorted by name. VMs whose Vagrant
+// environment no longer exists, e.g. an adopted project that was deleted, are skipped.
+func (m *Manager) ListVMDetails(ctx context.Context) ([]VMListing, error) {
+	configFiles, err := filepath.Glob(filepath.Join(m.baseDir, "*", vmConfigFile))
 	if err != nil {
 		return nil, errors.OperationFailed("list VMs", err)
 	}
 
-	var vmNames []string
-	for _, file := range files {
-		if file.IsDir() {
-			// Check if this directory has a .vagrant-name file which indicates it's a VM directory
-			vmNameFile := filepath.Join(m.baseDir, file.Name(), ".vagrant-name")
-			if _, err := os.Stat(vmNameFile); err == nil {
-				// Read the VM name from the file
-				vmNameBytes, err := os.ReadFile(vmNameFile)
-				if err == nil {
-					vmName := strings.TrimSpace(string(vmNameBytes))
-					vmNames = append(vmNames, vmName)
-				}
-			}
+	listings := []VMListing{}
+	for _, configFile := range configFiles {
+		name := filepath.Base(filepath.Dir(configFile))
+		if _, err := os.Stat(filepath.Join(m.getVMDir(name), "Vagrantfile")); err != nil {
+			log.Debug().Str("name", name).Msg("Skipping VM without a Vagrant environment")
+			continue
+		}
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, errors.OperationFailed("read VM config", err)
+		}
+		var config core.VMConfig
+		if err := json.Unmarshal(data, &config); err != nil {
+			log.Warn().Str("name", name).Err(err).Msg("Skipping VM with an unreadable config")
+			continue
 		}
+		listing := VMListing{
+			Name:        name,
+			ProjectPath: config.ProjectPath,
+			Tags:        config.Tags,
+			CreatedAt:   config.CreatedAt,
+			ExpiresAt:   config.ExpiresAt,
+		}
+		if lastUsed, ok := m.LastUsed(name); ok {
+			listing.LastUsedAt = &lastUsed
+		}
+		listings = append(listings, listing)
 	}
+	sort.Slice(listings, func(i, j int) bool { return listings[i].Name < listings[j].Name })
+	return listings, nil
+}
 
-	return vmNames, nil
+// LastUsed returns when a lifecycle operation last ran on a VM, from its operation logs
+func (m *Manager) LastUsed(name string) (time.Time, bool) {
+	entries, err := os.ReadDir(filepath.Join(m.baseDir, name, core.OperationLogDir))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var lastUsed time.Time
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.ModTime().After(lastUsed) {
+			lastUsed = info.ModTime()
+		}
+	}
+	return lastUsed, !lastUsed.IsZero()
 }
@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestListVMDetails(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "vms")
+	m := &Manager{baseDir: baseDir}
+	createdAt := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	for _, name := range []string{"web", "api", "stale"} {
+		if err := os.MkdirAll(filepath.Join(baseDir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		config := core.VMConfig{Name: name, ProjectPath: "/projects/" + name, Tags: []string{"team-a"}, CreatedAt: &createdAt}
+		if err := m.saveVMConfig(name, &config); err != nil {
+			t.Fatalf("Failed to save VM config: %v", err)
+		}
+	}
+	for _, name := range []string{"web", "api"} {
+		if err := os.WriteFile(filepath.Join(baseDir, name, "Vagrantfile"), []byte("Vagrant.configure(\"2\") do |config|\nend\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Directories without a config, such as the logs of destroyed VMs, are not VMs
+	if err := os.MkdirAll(filepath.Join(baseDir, core.DestroyedLogDir, "old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(baseDir, "web", core.OperationLogDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	usedAt := time.Date(2025, 1, 3, 9, 30, 0, 0, time.UTC)
+	upLog := core.OperationLogPath(baseDir, "web", core.OperationLogUp)
+	if err := os.WriteFile(upLog, []byte("$ vagrant up\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(upLog, usedAt, usedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	listings, err := m.ListVMDetails(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list VMs: %v", err)
+	}
+	if len(listings) != 2 || listings[0].Name != "api" || listings[1].Name != "web" {
+		t.Fatalf("Expected api and web without the stale VM but got %+v", listings)
+	}
+	web := listings[1]
+	if web.ProjectPath != "/projects/web" || !reflect.DeepEqual(web.Tags, []string{"team-a"}) || web.CreatedAt == nil || !web.CreatedAt.Equal(createdAt) {
+		t.Errorf("Expected the saved configuration but got %+v", web)
+	}
+	if web.LastUsedAt == nil || !web.LastUsedAt.Equal(usedAt) || listings[0].LastUsedAt != nil {
+		t.Errorf("Expected web last used at %v and api never but got %v and %v", usedAt, web.LastUsedAt, listings[0].LastUsedAt)
+	}
+	if names, err := m.ListVMs(context.Background()); err != nil || !reflect.DeepEqual(names, []string{"api", "web"}) {
+		t.Errorf("Expected the names of the listed VMs but got %v: %v", names, err)
+	}
+
+	// Replacing the config with one built from scratch keeps the creation time
+	if err := m.replaceVMConfig("web", &core.VMConfig{Name: "web"}); err != nil {
+		t.Fatalf("Failed to replace VM config: %v", err)
+	}
+	if config, err := m.GetVMConfig(context.Background(), "web"); err != nil || config.CreatedAt == nil || !config.CreatedAt.Equal(createdAt) {
+		t.Errorf("Expected the creation time to be kept but got %+v: %v", config, err)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// adoptionFile marks a VM directory whose Vagrantfile lives in an existing project.
+// The VM directory then only holds the server's own files, such as operation logs.
+const adoptionFile = ".adopted.json"
+
+// Adoption records where an adopted Vagrant environment lives
+type Adoption struct {
+	VagrantDir string    `json:"vagrant_dir"`
+	Machine    string    `json:"machine"`
+	AdoptedAt  time.Time `json:"adopted_at"`
+}
+
+var (
+	definePattern           = regexp.MustCompile(`\.vm\.define\s+[:"']?([\w.-]+)["']?`)
+	boxValuePattern         = regexp.MustCompile(`\.vm\.box\s*=\s*["']([^"']+)["']`)
+	memoryPattern           = regexp.MustCompile(`\.memory\s*=\s*["']?(\d+)`)
+	cpusPattern             = regexp.MustCompile(`\.cpus\s*=\s*["']?(\d+)`)
+	guestPortPattern        = regexp.MustCompile(`guest:\s*(\d+)`)
+	hostPortValuePattern    = regexp.MustCompile(`host:\s*(\d+)`)
+	syncedFolderArgsPattern = regexp.MustCompile(`\.vm\.synced_folder\s+["']([^"']+)["']\s*,\s*["']([^"']+)["'](.*)`)
+	syncTypePattern         = regexp.MustCompile(`type:\s*[:"']?(\w+)`)
+	blockOpenPattern        = regexp.MustCompile(`\bdo\b(\s*\|[^|]*\|)?\s*$`)
+)
+
+// VMDir returns the directory vagrant runs in for a VM: the project directory of an
+// adopted VM, or the VM's directory under baseDir
+func VMDir(baseDir, name string) string {
+	dir := filepath.Join(baseDir, name)
+	if adoption, err := loadAdoption(dir); err == nil {
+		return adoption.VagrantDir
+	}
+	return dir
+}
+
+// MachineName returns the Vagrant machine name of a VM: the adopted machine, or "default"
+// for VMs with a generated Vagrantfile
+func MachineName(baseDir, name string) string {
+	if adoption, err := loadAdoption(filepath.Join(baseDir, name)); err == nil && adoption.Machine != "" {
+		return adoption.Machine
+	}
+	return "default"
+}
+
+// loadAdoption reads the adoption record in a VM directory
+func loadAdoption(vmDir string) (*Adoption, error) {
+	data, err := os.ReadFile(filepath.Join(vmDir, adoptionFile))
+	if err != nil {
+		return nil, err
+	}
+	var adoption Adoption
+	if err := json.Unmarshal(data, &adoption); err != nil {
+		return nil, err
+	}
+	return &adoption, nil
+}
+
+// AdoptVM registers an existing Vagrant environment under name without generating a Vagrantfile.
+// machine selects a machine of a multi-machine Vagrantfile and may be empty when there is only one.
+func (m *Manager) AdoptVM(ctx context.Context, name, vagrantDir, machine string) (core.VMConfig, error) {
+	vagrantDir, err := filepath.Abs(vagrantDir)
+	if err != nil {
+		return core.VMConfig{}, errors.InvalidInput(fmt.Sprintf("invalid Vagrant directory: %v", err))
+	}
+	content, err := os.ReadFile(filepath.Join(vagrantDir, "Vagrantfile"))
+	if err != nil {
+		return core.VMConfig{}, errors.NotFound("Vagrantfile", vagrantDir)
+	}
+	release, err := m.operations.acquire(name, OperationAdopt)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	defer release()
+	vmDir := filepath.Join(m.baseDir, name)
+	if _, err := os.Stat(vmDir); err == nil {
+		return core.VMConfig{}, errors.AlreadyExists("VM", name)
+	}
+
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vagrantDir, "status", machineReadableFlag)
+	if err != nil {
+		return core.VMConfig{}, errors.OperationFailed("read Vagrant environment", fmt.Errorf("%w: %s", err, output))
+	}
+	machine, err = selectMachine(parseMachineNames(string(output)), machine)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+
+	config := ParseVagrantfileConfig(string(content), machine)
+	config.Name = name
+	if config.ProjectPath == "" {
+		// Vagrant syncs the project directory to /vagrant unless told otherwise
+		config.ProjectPath = vagrantDir
+	} else if !filepath.IsAbs(config.ProjectPath) {
+		config.ProjectPath = filepath.Join(vagrantDir, config.ProjectPath)
+	}
+	if config.HostPath != "" && !filepath.IsAbs(config.HostPath) {
+		config.HostPath = filepath.Join(vagrantDir, config.HostPath)
+	}
+
+	if err := os.MkdirAll(vmDir, 0755); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("create VM directory", err)
+	}
+	adoption := Adoption{VagrantDir: vagrantDir, Machine: machine, AdoptedAt: time.Now()}
+	data, err := json.MarshalIndent(adoption, "", "  ")
+	if err != nil {
+		return core.VMConfig{}, errors.OperationFailed("marshal adoption record", err)
+	}
+	if err := os.WriteFile(filepath.Join(vmDir, adoptionFile), data, 0644); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("save adoption record", err)
+	}
+	config.CreatedAt = &adoption.AdoptedAt
+	if err := m.replaceVMConfig(name, &config); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("save VM configuration", err)
+	}
+
+	log.Info().Str("name", name).Str("dir", vagrantDir).Str("machine", machine).Msg("Existing Vagrant environment adopted")
+	state, _ := m.parseVagrantStatus(string(output))
+	publishStateChange(name, "adopt", state)
+	return config, nil
+}
+
+// vagrantArgs appends the machine name of an adopted VM to a vagrant subcommand
+func (m *Manager) vagrantArgs(name string, args ...string) []string {
+	if adoption, err := loadAdoption(filepath.Join(m.baseDir, name)); err == nil && adoption.Machine != "" {
+		return append(args, adoption.Machine)
+	}
+	return args
+}
+
+// parseMachineNames returns the machines listed by 'vagrant status --machine-readable'
+func parseMachineNames(output string) []string {
+	var machines []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(line, ",")
+		if len(parts) >= 4 && parts[2] == "state" && parts[1] != "" && !seen[parts[1]] {
+			seen[parts[1]] = true
+			machines = append(machines, parts[1])
+		}
+	}
+	return machines
+}
+
+// selectMachine picks the machine to adopt
+func selectMachine(machines []string, requested string) (string, error) {
+	if requested != "" {
+		for _, machine := range machines {
+			if machine == requested {
+				return machine, nil
+			}
+		}
+		return "", errors.NotFound("machine", requested)
+	}
+	switch len(machines) {
+	case 0:
+		return "", errors.InvalidInput("the Vagrantfile defines no machines")
+	case 1:
+		return machines[0], nil
+	default:
+		return "", errors.InvalidInput(fmt.Sprintf("the Vagrantfile defines several machines, choose one of: %s", strings.Join(machines, ", ")))
+	}
+}
+
+// ParseVagrantfileConfig maps the settings of a Vagrantfile that apply to machine into a VM
+// configuration. Settings outside any define block apply to every machine. The Vagrantfile
+// is Ruby, so only literal settings are recognised.
+func ParseVagrantfileConfig(content, machine string) core.VMConfig {
+	config := core.VMConfig{}
+	syncedFolder := false
+	for _, line := range machineLines(content, machine) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if match := boxValuePattern.FindStringSubmatch(trimmed); match != nil {
+			config.Box = match[1]
+		}
+		if match := memoryPattern.FindStringSubmatch(trimmed); match != nil {
+			config.Memory, _ = strconv.Atoi(match[1])
+		}
+		if match := cpusPattern.FindStringSubmatch(trimmed); match != nil {
+			config.CPU, _ = strconv.Atoi(match[1])
+		}
+		if strings.Contains(trimmed, "forwarded_port") {
+			guest := guestPortPattern.FindStringSubmatch(trimmed)
+			host := hostPortValuePattern.FindStringSubmatch(trimmed)
+			if guest != nil && host != nil {
+				guestPort, _ := strconv.Atoi(guest[1])
+				hostPort, _ := strconv.Atoi(host[1])
+				config.Ports = append(config.Ports, core.Port{Guest: guestPort, Host: hostPort})
+			}
+		}
+		// The first synced folder is the one the sync tools work with
+		if match := syncedFolderArgsPattern.FindStringSubmatch(trimmed); match != nil && !syncedFolder {
+			if strings.Contains(match[3], "disabled: true") {
+				continue
+			}
+			syncedFolder = true
+			config.HostPath = match[1]
+			config.GuestPath = match[2]
+			config.ProjectPath = match[1]
+			config.SyncType = "virtualbox"
+			if syncType := syncTypePattern.FindStringSubmatch(match[3]); syncType != nil {
+				config.SyncType = syncType[1]
+			}
+		}
+	}
+	return config
+}
+
+// machineLines returns the lines of a Vagrantfile that apply to machine: those outside any
+// define block and those inside the machine's own define block
+func machineLines(content, machine string) []string {
+	var lines []string
+	depth := 0
+	defineDepth := 0 // depth at which the current define block was opened, 0 outside one
+	include := true
+	for _, statement := range splitVagrantStatements(content) {
+		line := statement.text
+		code := line
+		if i := strings.Index(code, " #"); i >= 0 {
+			code = strings.TrimSpace(code[:i])
+		}
+
+		if defineDepth == 0 {
+			if match := definePattern.FindStringSubmatch(code); match != nil && blockOpenPattern.MatchString(code) {
+				depth++
+				defineDepth = depth
+				include = match[1] == machine
+				continue
+			}
+		}
+		if include {
+			lines = append(lines, line)
+		}
+		switch {
+		case blockOpenPattern.MatchString(code):
+			depth++
+		case code == "end":
+			if defineDepth == depth {
+				defineDepth = 0
+				include = true
+			}
+			depth--
+		}
+	}
+	return lines
+}
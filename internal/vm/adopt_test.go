@@ -0,0 +1,181 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+const multiMachineVagrantfile = `Vagrant.configure("2") do |config|
+  config.vm.box = "ubuntu/jammy64"
+  config.vm.synced_folder ".", "/vagrant", disabled: true
+
+  config.vm.define "web" do |web|
+    web.vm.network "forwarded_port", guest: 80, host: 8080
+    web.vm.synced_folder "./app", "/srv/app", type: "rsync"
+    web.vm.provider "virtualbox" do |vb|
+      vb.memory = 1024
+      vb.cpus = 2
+    end
+  end
+
+  config.vm.define :db do |db|
+    db.vm.box = "debian/bookworm64"
+    db.vm.network "forwarded_port", guest: 5432, host: 15432
+    db.vm.provider "virtualbox" do |vb|
+      vb.memory = "4096"
+    end
+  end
+end
+`
+
+func TestParseVagrantfileConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		machine  string
+		expected core.VMConfig
+	}{
+		{
+			name: "single machine",
+			content: `Vagrant.configure("2") do |config|
+  config.vm.box = "generic/ubuntu2204"
+  config.vm.network :forwarded_port, guest: 3000, host: 3001
+  # config.vm.network "forwarded_port", guest: 9000, host: 9000
+  config.vm.synced_folder "src", "/home/vagrant/src"
+  config.vm.provider "virtualbox" do |vb|
+    vb.memory = 2048
+    vb.cpus = 4
+  end
+end
+`,
+			machine: "default",
+			expected: core.VMConfig{
+				Box:         "generic/ubuntu2204",
+				CPU:         4,
+				Memory:      2048,
+				Ports:       []core.Port{{Guest: 3000, Host: 3001}},
+				HostPath:    "src",
+				GuestPath:   "/home/vagrant/src",
+				ProjectPath: "src",
+				SyncType:    "virtualbox",
+			},
+		},
+		{
+			name:    "define block with shared settings",
+			content: multiMachineVagrantfile,
+			machine: "web",
+			expected: core.VMConfig{
+				Box:         "ubuntu/jammy64",
+				CPU:         2,
+				Memory:      1024,
+				Ports:       []core.Port{{Guest: 80, Host: 8080}},
+				HostPath:    "./app",
+				GuestPath:   "/srv/app",
+				ProjectPath: "./app",
+				SyncType:    "rsync",
+			},
+		},
+		{
+			name:    "define block overriding the box",
+			content: multiMachineVagrantfile,
+			machine: "db",
+			expected: core.VMConfig{
+				Box:    "debian/bookworm64",
+				Memory: 4096,
+				Ports:  []core.Port{{Guest: 5432, Host: 15432}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := ParseVagrantfileConfig(tc.content, tc.machine)
+			if config.Box != tc.expected.Box || config.CPU != tc.expected.CPU || config.Memory != tc.expected.Memory {
+				t.Errorf("Expected box %q, %d CPUs and %d MB but got %q, %d CPUs and %d MB",
+					tc.expected.Box, tc.expected.CPU, tc.expected.Memory, config.Box, config.CPU, config.Memory)
+			}
+			if len(config.Ports) != len(tc.expected.Ports) {
+				t.Fatalf("Expected ports %v but got %v", tc.expected.Ports, config.Ports)
+			}
+			for i, port := range config.Ports {
+				if port != tc.expected.Ports[i] {
+					t.Errorf("Expected port %v but got %v", tc.expected.Ports[i], port)
+				}
+			}
+			if config.HostPath != tc.expected.HostPath || config.GuestPath != tc.expected.GuestPath ||
+				config.ProjectPath != tc.expected.ProjectPath || config.SyncType != tc.expected.SyncType {
+				t.Errorf("Expected synced folder %q -> %q (%q, %s) but got %q -> %q (%q, %s)",
+					tc.expected.HostPath, tc.expected.GuestPath, tc.expected.ProjectPath, tc.expected.SyncType,
+					config.HostPath, config.GuestPath, config.ProjectPath, config.SyncType)
+			}
+		})
+	}
+}
+
+func TestSelectMachine(t *testing.T) {
+	testCases := []struct {
+		name      string
+		machines  []string
+		requested string
+		expected  string
+		expectErr bool
+	}{
+		{"single machine", []string{"default"}, "", "default", false},
+		{"requested machine", []string{"web", "db"}, "db", "db", false},
+		{"ambiguous machines", []string{"web", "db"}, "", "", true},
+		{"unknown machine", []string{"web", "db"}, "cache", "", true},
+		{"no machines", nil, "", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, err := selectMachine(tc.machines, tc.requested)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("Expected error %v but got %v", tc.expectErr, err)
+			}
+			if machine != tc.expected {
+				t.Errorf("Expected machine %q but got %q", tc.expected, machine)
+			}
+		})
+	}
+}
+
+func TestParseMachineNames(t *testing.T) {
+	output := "1700000000,web,metadata,provider,virtualbox\n" +
+		"1700000000,web,state,running\n" +
+		"1700000000,db,state,not_created\n" +
+		"1700000000,,ui,info,Current machine states:\n"
+	machines := parseMachineNames(output)
+	if len(machines) != 2 || machines[0] != "web" || machines[1] != "db" {
+		t.Errorf("Expected machines [web db] but got %v", machines)
+	}
+}
+
+func TestVMDir(t *testing.T) {
+	baseDir := t.TempDir()
+	if dir := VMDir(baseDir, "dev"); dir != filepath.Join(baseDir, "dev") {
+		t.Errorf("Expected VM directory under the base directory but got %s", dir)
+	}
+	if machine := MachineName(baseDir, "dev"); machine != "default" {
+		t.Errorf("Expected machine default but got %s", machine)
+	}
+
+	vmDir := filepath.Join(baseDir, "adopted")
+	if err := os.MkdirAll(vmDir, 0755); err != nil {
+		t.Fatalf("Failed to create VM directory: %v", err)
+	}
+	record := `{"vagrant_dir": "/projects/app", "machine": "web", "adopted_at": "` + time.Now().Format(time.RFC3339) + `"}`
+	if err := os.WriteFile(filepath.Join(vmDir, adoptionFile), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write adoption record: %v", err)
+	}
+	if dir := VMDir(baseDir, "adopted"); dir != "/projects/app" {
+		t.Errorf("Expected adopted project directory but got %s", dir)
+	}
+	if machine := MachineName(baseDir, "adopted"); machine != "web" {
+		t.Errorf("Expected machine web but got %s", machine)
+	}
+}
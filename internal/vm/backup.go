@@ -0,0 +1,318 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/database"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// backupDir is the directory under the base directory holding the backups of guest data.
+// It is outside the VM directories, so backups outlive the VMs they were taken from.
+const backupDir = ".backups"
+
+// DefaultBackupRetention is how many backups of a VM are kept when no retention is given
+const DefaultBackupRetention = 5
+
+// backupIDLayout is the timestamp format of backup IDs, which sort by age. The milliseconds
+// keep apart backups taken within the same second, e.g. by a schedule and by hand.
+const backupIDLayout = "20060102-150405.000"
+
+// Backup presets name groups of guest paths that backup_vm_data archives
+const (
+	BackupPresetProject   = "project"
+	BackupPresetHome      = "home"
+	BackupPresetDatabases = "databases"
+)
+
+// databaseDumpDir is the guest directory the databases preset dumps the database servers to
+// before archiving it. Logical dumps are consistent, unlike the files of running servers.
+const databaseDumpDir = "/var/backups/vagrant-mcp/databases"
+
+// databaseDump is how the databases preset dumps and loads one of the database servers
+// setup_dev_environment installs
+type databaseDump struct {
+	// service is the server's systemd unit; servers that are not running are skipped
+	service string
+	file    string
+	// dump writes the dump to stdout and load reads it from stdin
+	dump string
+	load string
+}
+
+// databaseDumps returns how the databases preset dumps and loads each database server
+func databaseDumps() []databaseDump {
+	postgresDump, _ := database.DumpAllCommand(database.EnginePostgres)
+	postgresLoad, _ := database.LoadCommand(database.EnginePostgres)
+	mysqlDump, _ := database.DumpAllCommand(database.EngineMySQL)
+	mysqlLoad, _ := database.LoadCommand(database.EngineMySQL)
+	return []databaseDump{
+		{service: "postgresql", file: "postgresql.sql", dump: postgresDump, load: postgresLoad},
+		{service: "mysql", file: "mysql.sql", dump: mysqlDump, load: mysqlLoad},
+		{service: "mongod", file: "mongodb.archive", dump: "sudo -n mongodump --archive --quiet", load: "sudo -n mongorestore --drop --archive --quiet"},
+		// Redis loads its snapshot when it starts
+		{service: "redis-server", file: "redis.rdb",
+			dump: "sudo -n redis-cli SAVE >/dev/null && sudo -n cat /var/lib/redis/dump.rdb",
+			load: "sudo -n systemctl stop redis-server && sudo -n tee /var/lib/redis/dump.rdb >/dev/null && sudo -n chown redis:redis /var/lib/redis/dump.rdb && sudo -n systemctl start redis-server"},
+	}
+}
+
+// databaseDumpCommand returns the guest command that dumps the running database servers
+// into databaseDumpDir
+func databaseDumpCommand() string {
+	steps := []string{fmt.Sprintf(`sudo -n rm -rf %[1]s && sudo -n install -d -m 0700 -o "$(id -u)" %[1]s`, databaseDumpDir)}
+	for _, dump := range databaseDumps() {
+		steps = append(steps, fmt.Sprintf("{ ! systemctl is-active --quiet %s || (%s) > %s; }", dump.service, dump.dump, path.Join(databaseDumpDir, dump.file)))
+	}
+	return strings.Join(steps, " && ")
+}
+
+// databaseLoadCommand returns the guest command that loads the dumps restored into
+// databaseDumpDir and removes them
+func databaseLoadCommand() string {
+	var steps []string
+	for _, dump := range databaseDumps() {
+		file := path.Join(databaseDumpDir, dump.file)
+		steps = append(steps, fmt.Sprintf("{ [ ! -f %[1]s ] || (%[2]s) < %[1]s; }", file, dump.load))
+	}
+	return strings.Join(append(steps, "sudo -n rm -rf "+databaseDumpDir), " && ")
+}
+
+// Backup describes an archive of guest paths taken from a VM
+type Backup struct {
+	ID        string    `json:"id"`
+	VMName    string    `json:"vm_name"`
+	Paths     []string  `json:"paths"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	Archive   string    `json:"archive"`
+}
+
+// BackupPaths expands a selection of presets and absolute guest paths into the paths to
+// archive. The project preset is the directory the project is synced to, projectRoot.
+func BackupPaths(selection []string, projectRoot string) ([]string, error) {
+	if len(selection) == 0 {
+		selection = []string{BackupPresetProject, BackupPresetHome}
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	for _, item := range selection {
+		switch item {
+		case BackupPresetProject:
+			add(path.Clean(projectRoot))
+		case BackupPresetHome:
+			add("/home/vagrant")
+		case BackupPresetDatabases:
+			add(databaseDumpDir)
+		default:
+			if !path.IsAbs(item) {
+				return nil, errors.InvalidInput(fmt.Sprintf("'%s' is neither a preset (project, home or databases) nor an absolute path", item))
+			}
+			if path.Clean(item) == "/" {
+				return nil, errors.InvalidInput("the whole guest filesystem cannot be backed up")
+			}
+			add(path.Clean(item))
+		}
+	}
+	return paths, nil
+}
+
+// CreateBackup archives guest paths of a running VM to a timestamped archive on the host and
+// removes the oldest backups of the VM beyond keep, returning the backup and those removed.
+// Paths that do not exist in the guest are skipped.
+func CreateBackup(ctx context.Context, baseDir, vmName string, sshArgs []string, paths []string, keep int) (Backup, []Backup, error) {
+	if keep < 1 {
+		return Backup{}, nil, errors.InvalidInput("at least one backup must be kept")
+	}
+	command, err := guestArchiveCommand(paths)
+	if err != nil {
+		return Backup{}, nil, err
+	}
+	if slices.Contains(paths, databaseDumpDir) {
+		// The dumps are only kept in the archive
+		command = fmt.Sprintf("%s && %s; status=$?; sudo -n rm -rf %s; exit $status", databaseDumpCommand(), command, databaseDumpDir)
+	}
+	dir := vmBackupDir(baseDir, vmName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Backup{}, nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	createdAt := time.Now().UTC()
+	backup := Backup{
+		ID:        createdAt.Format(backupIDLayout),
+		VMName:    vmName,
+		Paths:     paths,
+		CreatedAt: createdAt,
+	}
+	backup.Archive = filepath.Join(dir, backup.ID+".tar.gz")
+	if _, err := os.Stat(backup.Archive); err == nil {
+		return Backup{}, nil, errors.AlreadyExists("backup", backup.ID)
+	}
+	partial := backup.Archive + ".partial"
+	file, err := os.Create(partial)
+	if err != nil {
+		return Backup{}, nil, fmt.Errorf("failed to create backup archive: %w", err)
+	}
+	defer os.Remove(partial)
+
+	var stderr bytes.Buffer
+	runErr := cmdexec.RunSSH(ctx, cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: command, Stdout: file, Stderr: &stderr})
+	closeErr := file.Close()
+	if runErr != nil {
+		return Backup{}, nil, fmt.Errorf("failed to archive guest paths: %w: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+	if closeErr != nil {
+		return Backup{}, nil, fmt.Errorf("failed to write backup archive: %w", closeErr)
+	}
+	if err := os.Rename(partial, backup.Archive); err != nil {
+		return Backup{}, nil, fmt.Errorf("failed to save backup archive: %w", err)
+	}
+	if info, err := os.Stat(backup.Archive); err == nil {
+		backup.SizeBytes = info.Size()
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return Backup{}, nil, fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, backup.ID+".json"), data, 0644); err != nil {
+		os.Remove(backup.Archive)
+		return Backup{}, nil, fmt.Errorf("failed to save backup manifest: %w", err)
+	}
+	log.Info().Str("name", vmName).Str("backup", backup.ID).Strs("paths", paths).Int64("bytes", backup.SizeBytes).Msg("Backup saved")
+
+	removed, err := PruneBackups(baseDir, vmName, keep)
+	if err != nil {
+		log.Warn().Err(err).Str("name", vmName).Msg("Failed to remove old backups")
+	}
+	return backup, removed, nil
+}
+
+// ListBackups returns the backups of a VM, newest first. Backups whose archive is missing are
+// left out.
+func ListBackups(baseDir, vmName string) ([]Backup, error) {
+	manifests, err := filepath.Glob(filepath.Join(vmBackupDir(baseDir, vmName), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	backups := []Backup{}
+	for _, manifest := range manifests {
+		data, err := os.ReadFile(manifest)
+		if err != nil {
+			return nil, err
+		}
+		var backup Backup
+		if err := json.Unmarshal(data, &backup); err != nil {
+			log.Warn().Err(err).Str("manifest", manifest).Msg("Skipping unreadable backup manifest")
+			continue
+		}
+		if _, err := os.Stat(backup.Archive); err != nil {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// PruneBackups removes the oldest backups of a VM beyond keep and returns them
+func PruneBackups(baseDir, vmName string, keep int) ([]Backup, error) {
+	backups, err := ListBackups(baseDir, vmName)
+	if err != nil || len(backups) <= keep {
+		return nil, err
+	}
+	removed := backups[keep:]
+	for _, backup := range removed {
+		for _, file := range []string{backup.Archive, filepath.Join(vmBackupDir(baseDir, vmName), backup.ID+".json")} {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// RestoreBackup extracts a backup of a VM, or its latest one when id is empty, into the running
+// guest. Files in the backup overwrite those in the guest; other files are left alone.
+func RestoreBackup(ctx context.Context, baseDir, vmName, id string, sshArgs []string) (Backup, error) {
+	backups, err := ListBackups(baseDir, vmName)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to list backups: %w", err)
+	}
+	var backup *Backup
+	for i := range backups {
+		if id == "" || backups[i].ID == id {
+			backup = &backups[i]
+			break
+		}
+	}
+	if backup == nil {
+		if id == "" {
+			return Backup{}, errors.NotFound("backup of VM", vmName)
+		}
+		return Backup{}, errors.NotFound("backup", id)
+	}
+	file, err := os.Open(backup.Archive)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	defer file.Close()
+
+	command := "sudo -n tar -xzpf - -C /"
+	if slices.Contains(backup.Paths, databaseDumpDir) {
+		command += " && " + databaseLoadCommand()
+	}
+	var stderr bytes.Buffer
+	if err := cmdexec.RunSSH(ctx, cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: command, Stdin: file, Stderr: &stderr}); err != nil {
+		return Backup{}, fmt.Errorf("failed to restore guest paths: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	log.Info().Str("name", vmName).Str("backup", backup.ID).Strs("paths", backup.Paths).Msg("Backup restored")
+	return *backup, nil
+}
+
+// vmBackupDir returns the directory holding the backups of a VM
+func vmBackupDir(baseDir, vmName string) string {
+	return filepath.Join(baseDir, backupDir, vmName)
+}
+
+// guestArchiveCommand returns the guest command that writes a tar.gz of absolute guest paths
+// to stdout. Paths that do not exist are skipped.
+func guestArchiveCommand(paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no paths to archive")
+	}
+	members := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !path.IsAbs(p) {
+			return "", fmt.Errorf("path '%s' must be absolute", p)
+		}
+		cleaned := path.Clean(p)
+		if cleaned == "/" {
+			return "", fmt.Errorf("path '%s' cannot be archived", p)
+		}
+		// Archive relative to / so the archive extracts to the same place
+		members = append(members, shellquote.Quote(strings.TrimPrefix(cleaned, "/")))
+	}
+	return "sudo -n tar -czf - --ignore-failed-read -C / " + strings.Join(members, " "), nil
+}
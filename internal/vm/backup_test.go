@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+func TestBackupPaths(t *testing.T) {
+	testCases := []struct {
+		name      string
+		selection []string
+		expected  []string
+		hasError  bool
+	}{
+		{"default", nil, []string{"/srv/app", "/home/vagrant"}, false},
+		{"databases and a path", []string{"databases", "/etc/nginx/"}, []string{databaseDumpDir, "/etc/nginx"}, false},
+		{"duplicates", []string{"home", "/home/vagrant"}, []string{"/home/vagrant"}, false},
+		{"relative path", []string{"data"}, nil, true},
+		{"root", []string{"/"}, nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, err := BackupPaths(tc.selection, "/srv/app")
+			if (err != nil) != tc.hasError {
+				t.Fatalf("Expected error %v but got %v", tc.hasError, err)
+			}
+			if !tc.hasError && !reflect.DeepEqual(paths, tc.expected) {
+				t.Errorf("Expected %v but got %v", tc.expected, paths)
+			}
+		})
+	}
+
+	command, err := guestArchiveCommand([]string{"/vagrant", "/home/vagrant"})
+	if err != nil || command != "sudo -n tar -czf - --ignore-failed-read -C / 'vagrant' 'home/vagrant'" {
+		t.Errorf("Expected the synced folder to be archived but got %q: %v", command, err)
+	}
+}
+
+func TestDatabaseDumpCommands(t *testing.T) {
+	dump := databaseDumpCommand()
+	for _, expected := range []string{
+		"{ ! systemctl is-active --quiet postgresql || (cd / && sudo -n -u postgres pg_dumpall --clean --if-exists) > /var/backups/vagrant-mcp/databases/postgresql.sql; }",
+		"sudo -n mysqldump --all-databases",
+		"sudo -n mongodump --archive",
+		"sudo -n redis-cli SAVE",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Expected the dump command to contain %q but got %q", expected, dump)
+		}
+	}
+	if strings.Contains(dump, "tar ") {
+		t.Errorf("Expected the dump command to leave the data directories alone but got %q", dump)
+	}
+	load := databaseLoadCommand()
+	for _, expected := range []string{
+		"{ [ ! -f /var/backups/vagrant-mcp/databases/postgresql.sql ] || (cd / && sudo -n -u postgres psql -q -X -d postgres) < /var/backups/vagrant-mcp/databases/postgresql.sql; }",
+		"(sudo -n mysql) < /var/backups/vagrant-mcp/databases/mysql.sql",
+		"sudo -n rm -rf /var/backups/vagrant-mcp/databases",
+	} {
+		if !strings.Contains(load, expected) {
+			t.Errorf("Expected the load command to contain %q but got %q", expected, load)
+		}
+	}
+}
+
+func TestListAndPruneBackups(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := vmBackupDir(baseDir, "web")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		createdAt := started.Add(time.Duration(i) * time.Hour)
+		backup := Backup{ID: createdAt.Format(backupIDLayout), VMName: "web", Paths: []string{"/vagrant"}, CreatedAt: createdAt}
+		backup.Archive = filepath.Join(dir, backup.ID+".tar.gz")
+		// The archive of the oldest backup is missing
+		if i > 0 {
+			if err := os.WriteFile(backup.Archive, []byte("archive"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, _ := json.Marshal(backup)
+		if err := os.WriteFile(filepath.Join(dir, backup.ID+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := ListBackups(baseDir, "web")
+	if err != nil || len(backups) != 3 || backups[0].ID != "20250102-130000.000" {
+		t.Fatalf("Expected 3 backups, newest first, but got %+v: %v", backups, err)
+	}
+	removed, err := PruneBackups(baseDir, "web", 2)
+	if err != nil || len(removed) != 1 || removed[0].ID != "20250102-110000.000" {
+		t.Fatalf("Expected the oldest backup to be removed but got %+v: %v", removed, err)
+	}
+	if _, err := os.Stat(removed[0].Archive); !os.IsNotExist(err) {
+		t.Errorf("Expected the archive of the removed backup to be deleted")
+	}
+	if backups, _ := ListBackups(baseDir, "web"); len(backups) != 2 {
+		t.Errorf("Expected 2 backups to be kept but got %d", len(backups))
+	}
+
+	_, err = RestoreBackup(context.Background(), baseDir, "web", "20250101-000000", nil)
+	if !errors.IsNotFound(err) || !strings.Contains(err.Error(), "20250101-000000") {
+		t.Errorf("Expected an unknown backup to be reported but got %v", err)
+	}
+	if _, err := RestoreBackup(context.Background(), baseDir, "api", "", nil); !errors.IsNotFound(err) {
+		t.Errorf("Expected a VM without backups to be reported but got %v", err)
+	}
+}
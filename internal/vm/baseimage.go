@@ -0,0 +1,147 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/config"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// boxNamePattern restricts the names of baked boxes, e.g. "mycorp/node-dev"
+var boxNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(/[A-Za-z0-9][A-Za-z0-9._-]*)?$`)
+
+// generalizeScript removes the VM specific settings written by the provisioners generated by
+// this server, so VMs created from the baked box only get the settings of their own config
+const generalizeScript = `sudo sed -i '/^# BEGIN vagrant-mcp /,/^# END vagrant-mcp /d' /etc/environment /etc/hosts
+sudo rm -f /etc/apt/apt.conf.d/95vagrant-mcp-proxy /etc/apt/apt.conf.d/96vagrant-mcp-package-cache
+if ! mountpoint -q /var/cache/apt/archives; then sudo apt-get clean; fi
+rm -f ~/.bash_history`
+
+// BaseImage is a box baked from a provisioned VM
+type BaseImage struct {
+	Box      string    `json:"box"`
+	SourceVM string    `json:"source_vm"`
+	BakedAt  time.Time `json:"baked_at"`
+	// Default is set when create_dev_vm uses the box unless told otherwise
+	Default bool `json:"default"`
+}
+
+// baseImageFile returns the file recording the default base image
+func baseImageFile(baseDir string) string {
+	return filepath.Join(baseDir, ".base-image.json")
+}
+
+// GetBaseImage returns the default base image, or nil when none has been baked
+func (m *Manager) GetBaseImage() (*BaseImage, error) {
+	data, err := os.ReadFile(baseImageFile(m.baseDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.OperationFailed("read base image record", err)
+	}
+	var image BaseImage
+	if err := json.Unmarshal(data, &image); err != nil {
+		return nil, errors.OperationFailed("parse base image record", err)
+	}
+	return &image, nil
+}
+
+// DefaultBox returns the box new VirtualBox VMs use when their configuration names none: the
+// default base image when one has been baked, otherwise the standard Ubuntu box
+func (m *Manager) DefaultBox() string {
+	return m.DefaultBoxFor(PlatformOption{Provider: ProviderVirtualBox, Box: config.DefaultVM.Boxes.Ubuntu})
+}
+
+// DefaultBoxFor returns the box new VMs run by a platform option use when their configuration
+// names none: the default base image when one has been baked, otherwise the option's box
+func (m *Manager) DefaultBoxFor(option PlatformOption) string {
+	image, err := m.GetBaseImage()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read base image record")
+	}
+	if image != nil && image.Default {
+		return image.Box
+	}
+	return option.Box
+}
+
+// BakeBaseImage packages a running, provisioned VM as a local box named boxName. The VM is
+// stripped of its VM specific settings and halted by 'vagrant package'. When setDefault is
+// set, VMs created without an explicit box use the new box.
+func (m *Manager) BakeBaseImage(ctx context.Context, vmName, boxName string, setDefault bool) (BaseImage, error) {
+	if !boxNamePattern.MatchString(boxName) {
+		return BaseImage{}, errors.InvalidInput(fmt.Sprintf("invalid box name '%s': use 'name' or 'org/name' with letters, digits, '.', '_' or '-'", boxName))
+	}
+	release, err := m.operations.acquire(vmName, OperationPackage)
+	if err != nil {
+		return BaseImage{}, err
+	}
+	defer release()
+	state, err := m.GetVMState(ctx, vmName)
+	if err != nil {
+		return BaseImage{}, err
+	}
+	if state != core.Running {
+		return BaseImage{}, errors.InvalidInput(fmt.Sprintf("VM '%s' must be running to be prepared for packaging (current state: %s)", vmName, state))
+	}
+
+	vmDir := m.getVMDir(vmName)
+	if output, err := m.runGuestCommand(ctx, vmName, generalizeScript); err != nil {
+		return BaseImage{}, errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to prepare VM for packaging: %s", output))
+	}
+
+	boxDir := filepath.Join(m.baseDir, ".boxes")
+	if err := os.MkdirAll(boxDir, 0755); err != nil {
+		return BaseImage{}, errors.OperationFailed("create box directory", err)
+	}
+	boxFile := filepath.Join(boxDir, strings.ReplaceAll(boxName, "/", "-")+".box")
+	defer os.Remove(boxFile)
+
+	log.Info().Str("name", vmName).Str("box", boxName).Msg("Packaging VM")
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vmDir, m.vagrantArgs(vmName, "package", "--output", boxFile)...)
+	// Packaging halts the VM, even when it fails part way
+	publishStateChange(vmName, "package", core.Stopped)
+	if err != nil {
+		return BaseImage{}, errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to package VM: %s", output))
+	}
+
+	if output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), "", "box", "add", "--force", "--name", boxName, boxFile); err != nil {
+		return BaseImage{}, errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to add box: %s", output))
+	}
+
+	image := BaseImage{Box: boxName, SourceVM: vmName, BakedAt: time.Now(), Default: setDefault}
+	if setDefault {
+		data, err := json.MarshalIndent(image, "", "  ")
+		if err != nil {
+			return BaseImage{}, errors.OperationFailed("marshal base image record", err)
+		}
+		if err := os.WriteFile(baseImageFile(m.baseDir), data, 0644); err != nil {
+			return BaseImage{}, errors.OperationFailed("write base image record", err)
+		}
+	}
+	log.Info().Str("name", vmName).Str("box", boxName).Bool("default", setDefault).Msg("Base image baked")
+	return image, nil
+}
+
+// vagrantProviderConfig returns the extra provider settings of a VM
+func vagrantProviderConfig(config core.VMConfig) string {
+	if config.LinkedClone {
+		// The box is imported once and VMs are created as differencing disks on top of it
+		return "\n    vb.linked_clone = true"
+	}
+	return ""
+}
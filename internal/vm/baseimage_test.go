@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestDefaultBox(t *testing.T) {
+	m := &Manager{baseDir: t.TempDir()}
+	if box := m.DefaultBox(); box != "ubuntu/focal64" {
+		t.Errorf("Expected ubuntu/focal64 without a base image but got %s", box)
+	}
+
+	testCases := []struct {
+		name     string
+		image    BaseImage
+		expected string
+	}{
+		{"default base image", BaseImage{Box: "mycorp/node-dev", Default: true}, "mycorp/node-dev"},
+		{"non-default base image", BaseImage{Box: "mycorp/node-dev"}, "ubuntu/focal64"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, _ := json.Marshal(tc.image)
+			if err := os.WriteFile(baseImageFile(m.baseDir), data, 0644); err != nil {
+				t.Fatalf("Failed to write base image record: %v", err)
+			}
+			if box := m.DefaultBox(); box != tc.expected {
+				t.Errorf("Expected %s but got %s", tc.expected, box)
+			}
+		})
+	}
+}
+
+func TestBoxNamePattern(t *testing.T) {
+	testCases := []struct {
+		name  string
+		valid bool
+	}{
+		{"node-dev", true},
+		{"mycorp/node-dev.v2", true},
+		{"mycorp/node/dev", false},
+		{"-dev", false},
+		{"node dev", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if valid := boxNamePattern.MatchString(tc.name); valid != tc.valid {
+				t.Errorf("Expected valid %v but got %v", tc.valid, valid)
+			}
+		})
+	}
+}
+
+func TestGenerateVagrantfileLinkedClone(t *testing.T) {
+	t.Setenv("SKIP_VAGRANT_VALIDATION", "true")
+	m := &Manager{baseDir: filepath.Join(t.TempDir(), "vms")}
+	if err := os.MkdirAll(filepath.Join(m.baseDir, "dev"), 0755); err != nil {
+		t.Fatalf("Failed to create VM directory: %v", err)
+	}
+
+	for _, linkedClone := range []bool{false, true} {
+		if err := m.generateVagrantfile("dev", core.VMConfig{Box: "mycorp/node-dev", CPU: 2, Memory: 2048, LinkedClone: linkedClone}); err != nil {
+			t.Fatalf("Failed to generate Vagrantfile: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(m.baseDir, "dev", "Vagrantfile"))
+		if err != nil {
+			t.Fatalf("Failed to read Vagrantfile: %v", err)
+		}
+		if contains := strings.Contains(string(content), "\n    vb.linked_clone = true\n"); contains != linkedClone {
+			t.Errorf("Expected linked clone setting %v but got Vagrantfile:\n%s", linkedClone, content)
+		}
+	}
+}
+
+func TestGenerateVagrantfileProvisioners(t *testing.T) {
+	t.Setenv("SKIP_VAGRANT_VALIDATION", "true")
+	m := &Manager{baseDir: filepath.Join(t.TempDir(), "vms")}
+	if err := os.MkdirAll(filepath.Join(m.baseDir, "dev"), 0755); err != nil {
+		t.Fatalf("Failed to create VM directory: %v", err)
+	}
+
+	config := core.VMConfig{Box: "ubuntu/focal64", CPU: 2, Memory: 2048, Provisioners: []string{"sudo apt-get install -y golang"}}
+	if err := m.generateVagrantfile("dev", config); err != nil {
+		t.Fatalf("Failed to generate Vagrantfile: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(m.baseDir, "dev", "Vagrantfile"))
+	if err != nil {
+		t.Fatalf("Failed to read Vagrantfile: %v", err)
+	}
+	if !strings.Contains(string(content), "\n    sudo apt-get install -y golang\n") {
+		t.Errorf("Expected the provisioner in the Vagrantfile but got:\n%s", content)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// bootReportsFile is the file under the base directory holding boot reports for all VMs,
+// so that history survives destroying and recreating a VM
+const bootReportsFile = ".boot-reports.json"
+
+// maxBootReports bounds the stored boot report history
+const maxBootReports = 200
+
+// Regression thresholds: a phase regresses when it is both this much slower
+// in relative terms and at least this many seconds slower than its baseline
+const (
+	bootRegressionRatio      = 1.5
+	bootRegressionMinSeconds = 5.0
+)
+
+// Boot phase names
+const (
+	BootPhasePrepare     = "prepare"
+	BootPhaseBoxDownload = "box_download"
+	BootPhaseImport      = "import"
+	BootPhaseBoot        = "boot"
+	BootPhaseSSHWait     = "ssh_wait"
+	BootPhaseConfigure   = "configure"
+	// BootPhaseProvisionPrefix prefixes provisioner phases, e.g. "provision:shell"
+	BootPhaseProvisionPrefix = "provision:"
+)
+
+// BootPhase is a timed phase of a VM bring-up
+type BootPhase struct {
+	Name            string    `json:"name"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// BootReport records the phase timings of one vagrant up
+type BootReport struct {
+	VMName       string      `json:"vm_name"`
+	Box          string      `json:"box,omitempty"`
+	StartedAt    time.Time   `json:"started_at"`
+	TotalSeconds float64     `json:"total_seconds"`
+	Success      bool        `json:"success"`
+	Phases       []BootPhase `json:"phases"`
+}
+
+// BootRegression is a phase that took notably longer than on previous boots of the same box
+type BootRegression struct {
+	Phase           string  `json:"phase"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	BaselineSeconds float64 `json:"baseline_seconds,omitempty"`
+	// New is set for phases that did not run on previous boots, such as a newly added provisioner
+	New     bool   `json:"new,omitempty"`
+	Message string `json:"message"`
+}
+
+// BootComparison compares a boot report with previous boots of the same box
+type BootComparison struct {
+	PreviousBoots int                `json:"previous_boots"`
+	Baseline      map[string]float64 `json:"baseline_seconds"`
+	Regressions   []BootRegression   `json:"regressions"`
+}
+
+// bootPhaseMarkers maps vagrant up output to the phase that starts there
+var bootPhaseMarkers = []struct {
+	marker string
+	phase  string
+}{
+	{"could not be found. Attempting to find and install", BootPhaseBoxDownload},
+	{"Loading metadata for box", BootPhaseBoxDownload},
+	{"Importing base box", BootPhaseImport},
+	{"Cloning VM", BootPhaseImport},
+	{"Booting VM", BootPhaseBoot},
+	{"Starting domain", BootPhaseBoot},
+	{"Waiting for machine to boot", BootPhaseSSHWait},
+	{"Machine booted and ready", BootPhaseConfigure},
+}
+
+var (
+	provisionerPattern = regexp.MustCompile(`Running provisioner: ([^.\s]+(?:\s*\([^)]*\))?)`)
+	importBoxPattern   = regexp.MustCompile(`Importing base box '([^']+)'`)
+)
+
+// bootReportsMu serialises access to the boot reports file
+var bootReportsMu sync.Mutex
+
+// timedLine is a line of command output with the time it was written
+type timedLine struct {
+	at   time.Time
+	text string
+}
+
+// timedOutput collects command output and timestamps each line as it arrives
+type timedOutput struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	partial []byte
+	lines   []timedLine
+}
+
+// Write implements io.Writer
+func (o *timedOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	o.buf.Write(p)
+	o.partial = append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(o.partial, '\n')
+		if i < 0 {
+			break
+		}
+		o.lines = append(o.lines, timedLine{at: now, text: string(o.partial[:i])})
+		o.partial = o.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Bytes returns all output written so far
+func (o *timedOutput) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Bytes()
+}
+
+// parseBootPhases splits timed vagrant up output into phases ending at end
+func parseBootPhases(started time.Time, lines []timedLine, end time.Time) []BootPhase {
+	phases := []BootPhase{{Name: BootPhasePrepare, StartedAt: started}}
+	provisioners := make(map[string]int)
+
+	for _, line := range lines {
+		phase := ""
+		for _, m := range bootPhaseMarkers {
+			if strings.Contains(line.text, m.marker) {
+				phase = m.phase
+				break
+			}
+		}
+		if match := provisionerPattern.FindStringSubmatch(line.text); match != nil {
+			name := strings.TrimSpace(match[1])
+			provisioners[name]++
+			phase = BootPhaseProvisionPrefix + name
+			if provisioners[name] > 1 {
+				phase = fmt.Sprintf("%s#%d", phase, provisioners[name])
+			}
+		}
+		if phase == "" || phase == phases[len(phases)-1].Name {
+			continue
+		}
+		phases = append(phases, BootPhase{Name: phase, StartedAt: line.at})
+	}
+
+	for i := range phases {
+		phaseEnd := end
+		if i+1 < len(phases) {
+			phaseEnd = phases[i+1].StartedAt
+		}
+		phases[i].DurationSeconds = roundSeconds(phaseEnd.Sub(phases[i].StartedAt))
+	}
+	return phases
+}
+
+// recordBootReport builds a boot report from timed vagrant up output and stores it
+func (m *Manager) recordBootReport(name, box string, started time.Time, output *timedOutput, runErr error) {
+	end := time.Now()
+	output.mu.Lock()
+	lines := humanTimedLines(output.lines)
+	output.mu.Unlock()
+
+	if box == "" {
+		for _, line := range lines {
+			if match := importBoxPattern.FindStringSubmatch(line.text); match != nil {
+				box = match[1]
+				break
+			}
+		}
+	}
+
+	report := BootReport{
+		VMName:       name,
+		Box:          box,
+		StartedAt:    started,
+		TotalSeconds: roundSeconds(end.Sub(started)),
+		Success:      runErr == nil,
+		Phases:       parseBootPhases(started, lines, end),
+	}
+
+	bootReportsMu.Lock()
+	defer bootReportsMu.Unlock()
+	reports, err := loadBootReportsLocked(m.baseDir)
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("Failed to load boot reports")
+	}
+	reports = append(reports, report)
+	if len(reports) > maxBootReports {
+		reports = reports[len(reports)-maxBootReports:]
+	}
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("Failed to marshal boot reports")
+		return
+	}
+	if err := os.WriteFile(filepath.Join(m.baseDir, bootReportsFile), data, 0644); err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("Failed to save boot report")
+	}
+}
+
+// LoadBootReports returns the stored boot reports, oldest first
+func LoadBootReports(baseDir string) ([]BootReport, error) {
+	bootReportsMu.Lock()
+	defer bootReportsMu.Unlock()
+	return loadBootReportsLocked(baseDir)
+}
+
+// loadBootReportsLocked reads the boot reports file; the caller must hold bootReportsMu
+func loadBootReportsLocked(baseDir string) ([]BootReport, error) {
+	data, err := os.ReadFile(filepath.Join(baseDir, bootReportsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var reports []BootReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// CompareBootReport compares report with earlier successful boots of the same box in history.
+// The baseline for each phase is its median duration over those boots.
+func CompareBootReport(report BootReport, history []BootReport) BootComparison {
+	durations := make(map[string][]float64)
+	previous := 0
+	for _, earlier := range history {
+		if !earlier.Success || earlier.Box != report.Box || !earlier.StartedAt.Before(report.StartedAt) {
+			continue
+		}
+		previous++
+		for _, phase := range earlier.Phases {
+			durations[phase.Name] = append(durations[phase.Name], phase.DurationSeconds)
+		}
+	}
+
+	comparison := BootComparison{
+		PreviousBoots: previous,
+		Baseline:      make(map[string]float64),
+		Regressions:   []BootRegression{},
+	}
+	if previous == 0 {
+		return comparison
+	}
+	for name, values := range durations {
+		comparison.Baseline[name] = median(values)
+	}
+
+	for _, phase := range report.Phases {
+		baseline, known := comparison.Baseline[phase.Name]
+		switch {
+		case !known && strings.HasPrefix(phase.Name, BootPhaseProvisionPrefix) && phase.DurationSeconds >= bootRegressionMinSeconds:
+			comparison.Regressions = append(comparison.Regressions, BootRegression{
+				Phase:           phase.Name,
+				DurationSeconds: phase.DurationSeconds,
+				New:             true,
+				Message:         fmt.Sprintf("New phase %s added %.1fs to the boot", phase.Name, phase.DurationSeconds),
+			})
+		case known && phase.DurationSeconds >= baseline*bootRegressionRatio && phase.DurationSeconds-baseline >= bootRegressionMinSeconds:
+			comparison.Regressions = append(comparison.Regressions, BootRegression{
+				Phase:           phase.Name,
+				DurationSeconds: phase.DurationSeconds,
+				BaselineSeconds: baseline,
+				Message:         fmt.Sprintf("%s took %.1fs, up from a usual %.1fs", phase.Name, phase.DurationSeconds, baseline),
+			})
+		}
+	}
+	return comparison
+}
+
+// median returns the median of values
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return roundSeconds(time.Duration((sorted[mid-1] + sorted[mid]) / 2 * float64(time.Second)))
+	}
+	return sorted[mid]
+}
+
+// roundSeconds converts d to seconds rounded to milliseconds
+func roundSeconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
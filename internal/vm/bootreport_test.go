@@ -0,0 +1,106 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBootPhases(t *testing.T) {
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	lines := []timedLine{
+		{at(1), "Bringing machine 'default' up with 'virtualbox' provider..."},
+		{at(2), "==> default: Box 'ubuntu/focal64' could not be found. Attempting to find and install..."},
+		{at(40), "==> default: Importing base box 'ubuntu/focal64'..."},
+		{at(55), "==> default: Booting VM..."},
+		{at(57), "==> default: Waiting for machine to boot. This may take a few minutes..."},
+		{at(80), "==> default: Machine booted and ready!"},
+		{at(85), "==> default: Running provisioner: shell..."},
+		{at(120), "==> default: Running provisioner: shell..."},
+	}
+
+	phases := parseBootPhases(start, lines, at(130))
+
+	expected := []struct {
+		name     string
+		duration float64
+	}{
+		{BootPhasePrepare, 2},
+		{BootPhaseBoxDownload, 38},
+		{BootPhaseImport, 15},
+		{BootPhaseBoot, 2},
+		{BootPhaseSSHWait, 23},
+		{BootPhaseConfigure, 5},
+		{"provision:shell", 35},
+		{"provision:shell#2", 10},
+	}
+	if len(phases) != len(expected) {
+		t.Fatalf("Expected %d phases but got %d: %+v", len(expected), len(phases), phases)
+	}
+	for i, phase := range phases {
+		if phase.Name != expected[i].name || phase.DurationSeconds != expected[i].duration {
+			t.Errorf("Expected phase %d to be %s (%.0fs) but got %s (%.1fs)", i, expected[i].name, expected[i].duration, phase.Name, phase.DurationSeconds)
+		}
+	}
+}
+
+func TestCompareBootReport(t *testing.T) {
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	boot := func(day int, box string, phases ...BootPhase) BootReport {
+		return BootReport{VMName: "dev", Box: box, StartedAt: start.AddDate(0, 0, day), Success: true, Phases: phases}
+	}
+
+	history := []BootReport{
+		boot(0, "ubuntu/focal64", BootPhase{Name: BootPhaseBoot, DurationSeconds: 20}, BootPhase{Name: "provision:shell", DurationSeconds: 30}),
+		boot(1, "ubuntu/focal64", BootPhase{Name: BootPhaseBoot, DurationSeconds: 22}, BootPhase{Name: "provision:shell", DurationSeconds: 32}),
+		boot(2, "debian/bookworm64", BootPhase{Name: BootPhaseBoot, DurationSeconds: 5}),
+	}
+	latest := boot(3, "ubuntu/focal64",
+		BootPhase{Name: BootPhaseBoot, DurationSeconds: 23},
+		BootPhase{Name: "provision:shell", DurationSeconds: 70},
+		BootPhase{Name: "provision:ansible", DurationSeconds: 40},
+	)
+
+	comparison := CompareBootReport(latest, append(history, latest))
+
+	if comparison.PreviousBoots != 2 {
+		t.Errorf("Expected 2 previous boots of the same box but got %d", comparison.PreviousBoots)
+	}
+	if comparison.Baseline["provision:shell"] != 31 {
+		t.Errorf("Expected a 31s provisioning baseline but got %.1f", comparison.Baseline["provision:shell"])
+	}
+	if len(comparison.Regressions) != 2 {
+		t.Fatalf("Expected 2 regressions but got %+v", comparison.Regressions)
+	}
+	if comparison.Regressions[0].Phase != "provision:shell" || comparison.Regressions[0].New {
+		t.Errorf("Expected a slower shell provisioner but got %+v", comparison.Regressions[0])
+	}
+	if comparison.Regressions[1].Phase != "provision:ansible" || !comparison.Regressions[1].New || !strings.Contains(comparison.Regressions[1].Message, "New phase") {
+		t.Errorf("Expected a new ansible provisioner but got %+v", comparison.Regressions[1])
+	}
+}
+
+func TestRecordBootReport(t *testing.T) {
+	manager := &Manager{baseDir: t.TempDir()}
+	output := &timedOutput{}
+	if _, err := output.Write([]byte("==> default: Importing base box 'generic/alpine318'...\n==> default: Booting ")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := output.Write([]byte("VM...\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	manager.recordBootReport("dev", "", time.Now(), output, nil)
+
+	reports, err := LoadBootReports(manager.baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if len(reports) != 1 || reports[0].Box != "generic/alpine318" || !reports[0].Success {
+		t.Fatalf("Unexpected reports: %+v", reports)
+	}
+	if phases := reports[0].Phases; len(phases) != 3 || phases[2].Name != BootPhaseBoot {
+		t.Errorf("Expected prepare, import and boot phases but got %+v", phases)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Features that need a minimum Vagrant version
+const (
+	// FeatureUploadCompression is 'vagrant upload --compress'
+	FeatureUploadCompression = "upload_compression"
+	// FeatureDisks is the disk settings used for disk_size_gb, disks and resize_vm_disk
+	FeatureDisks = "disks"
+	// FeatureCloudInit is Vagrant's native cloud-init support, enabled with
+	// VAGRANT_EXPERIMENTAL=cloud_init
+	FeatureCloudInit = "cloud_init"
+)
+
+// featureRequirements lists the Vagrant version each feature needs, in the order they are
+// reported
+var featureRequirements = []struct {
+	Name       string
+	MinVersion string
+}{
+	{FeatureUploadCompression, "2.2.0"},
+	{FeatureDisks, "2.2.8"},
+	{FeatureCloudInit, "2.2.19"},
+}
+
+// providerPlugins maps the plugins providing Vagrant providers to their provider names
+var providerPlugins = map[string]string{
+	"vagrant-libvirt":        "libvirt",
+	"vagrant-qemu":           "qemu",
+	"vagrant-parallels":      "parallels",
+	"vagrant-vmware-desktop": "vmware_desktop",
+}
+
+// capabilitiesTTL is how long detected capabilities are reused
+const capabilitiesTTL = 10 * time.Minute
+
+var vagrantVersionPattern = regexp.MustCompile(`Vagrant (\d+\.\d+\.\d+)`)
+
+// FeatureSupport reports whether the installed Vagrant supports a feature
+type FeatureSupport struct {
+	Name       string `json:"name"`
+	Available  bool   `json:"available"`
+	MinVersion string `json:"min_vagrant_version"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// Capabilities are the installed Vagrant CLI, its plugins and the features they support
+type Capabilities struct {
+	// VagrantVersion is empty when it could not be detected
+	VagrantVersion string `json:"vagrant_version"`
+	// Plugins maps installed plugin names to their versions
+	Plugins map[string]string `json:"plugins"`
+	// Providers maps the providers whose version is known to it: VirtualBox and plugin providers
+	Providers  map[string]string `json:"providers"`
+	Features   []FeatureSupport  `json:"features"`
+	DetectedAt time.Time         `json:"detected_at"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// CapabilityDetector detects and caches the capabilities of the installed Vagrant
+type CapabilityDetector struct {
+	// Run runs a command and returns its combined output; replaced in tests
+	Run func(ctx context.Context, name string, args ...string) (string, error)
+
+	mu       sync.Mutex
+	detected *Capabilities
+}
+
+// GlobalCapabilities is the capability detector shared by the server
+var GlobalCapabilities = NewCapabilityDetector()
+
+// NewCapabilityDetector creates a detector running the installed vagrant
+func NewCapabilityDetector() *CapabilityDetector {
+	return &CapabilityDetector{
+		Run: func(ctx context.Context, name string, args ...string) (string, error) {
+			output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+			return string(output), err
+		},
+	}
+}
+
+// Get returns the capabilities of the installed Vagrant, detecting them again when they were
+// detected more than 10 minutes ago, e.g. before Vagrant was upgraded
+func (d *CapabilityDetector) Get(ctx context.Context) Capabilities {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.detected != nil && time.Since(d.detected.DetectedAt) < capabilitiesTTL {
+		return *d.detected
+	}
+	detected := d.detect(ctx)
+	d.detected = &detected
+	return detected
+}
+
+// detect runs vagrant to find its version and plugins
+func (d *CapabilityDetector) detect(ctx context.Context) Capabilities {
+	capabilities := Capabilities{
+		Plugins:    map[string]string{},
+		Providers:  map[string]string{},
+		DetectedAt: time.Now(),
+	}
+	output, err := d.Run(ctx, "vagrant", "--version")
+	if err != nil {
+		capabilities.Error = fmt.Sprintf("failed to run vagrant --version: %v", err)
+	} else if capabilities.VagrantVersion = ParseVagrantVersion(output); capabilities.VagrantVersion == "" {
+		capabilities.Error = fmt.Sprintf("unrecognised vagrant --version output: %s", strings.TrimSpace(output))
+	}
+	if capabilities.VagrantVersion != "" {
+		if output, err := d.Run(ctx, "vagrant", "plugin", "list", machineReadableFlag); err == nil {
+			capabilities.Plugins = ParsePluginList(output)
+		} else {
+			log.Warn().Err(err).Msg("Failed to list vagrant plugins")
+		}
+	}
+	for plugin, version := range capabilities.Plugins {
+		if provider, ok := providerPlugins[plugin]; ok {
+			capabilities.Providers[provider] = version
+		}
+	}
+	if output, err := d.Run(ctx, "VBoxManage", "--version"); err == nil {
+		if version := versionPrefix(output); version != "" {
+			capabilities.Providers["virtualbox"] = version
+		}
+	}
+	for _, requirement := range featureRequirements {
+		capabilities.Features = append(capabilities.Features, featureSupport(capabilities.VagrantVersion, requirement.Name, requirement.MinVersion))
+	}
+	return capabilities
+}
+
+// featureSupport reports whether a Vagrant version supports a feature. An unknown version is
+// assumed to support every feature, so detection problems never block anything.
+func featureSupport(version, name, minVersion string) FeatureSupport {
+	support := FeatureSupport{Name: name, Available: true, MinVersion: minVersion}
+	switch {
+	case version == "":
+		support.Reason = "the Vagrant version is unknown"
+	case CompareVersions(version, minVersion) < 0:
+		support.Available = false
+		support.Reason = fmt.Sprintf("needs Vagrant %s or newer; %s is installed", minVersion, version)
+	}
+	return support
+}
+
+// Require returns an error when the installed Vagrant does not support a feature
+func (d *CapabilityDetector) Require(ctx context.Context, feature string) error {
+	for _, support := range d.Get(ctx).Features {
+		if support.Name == feature && !support.Available {
+			return errors.New(errors.CodeDependencyMissing, fmt.Sprintf("%s %s", feature, support.Reason)).
+				WithContext("feature", feature).
+				WithContext("min_vagrant_version", support.MinVersion)
+		}
+	}
+	return nil
+}
+
+// ParseVagrantVersion returns the version in 'vagrant --version' output, e.g. 2.4.1
+func ParseVagrantVersion(output string) string {
+	if match := vagrantVersionPattern.FindStringSubmatch(output); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// ParsePluginList returns the plugins and versions in 'vagrant plugin list --machine-readable'
+// output, whose plugin-version lines look like "0.12.2, global"
+func ParsePluginList(output string) map[string]string {
+	plugins := make(map[string]string)
+	for _, raw := range strings.Split(output, "\n") {
+		line, ok := ParseMachineLine(raw)
+		if !ok || len(line.Data) == 0 {
+			continue
+		}
+		switch line.Type {
+		case "plugin-name":
+			if _, seen := plugins[line.Data[0]]; !seen {
+				plugins[line.Data[0]] = ""
+			}
+		case "plugin-version":
+			if line.Target != "" {
+				version, _, _ := strings.Cut(line.Data[0], ",")
+				plugins[line.Target] = strings.TrimSpace(version)
+			}
+		}
+	}
+	return plugins
+}
+
+// versionPrefix returns the dotted version at the start of output, e.g. 7.0.14 for
+// "7.0.14r161095"
+func versionPrefix(output string) string {
+	output = strings.TrimSpace(output)
+	end := 0
+	for end < len(output) && (output[end] == '.' || (output[end] >= '0' && output[end] <= '9')) {
+		end++
+	}
+	return strings.Trim(output[:end], ".")
+}
+
+// CompareVersions compares dotted versions numerically, returning -1, 0 or 1. Missing parts
+// count as zero and pre-release suffixes such as .dev are ignored.
+func CompareVersions(a, b string) int {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		var numA, numB int
+		if i < len(partsA) {
+			numA, _ = strconv.Atoi(versionPrefix(partsA[i]))
+		}
+		if i < len(partsB) {
+			numB, _ = strconv.Atoi(versionPrefix(partsB[i]))
+		}
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package vm
+
+import (
+	"context"
+	stderrors "errors"
+	"reflect"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+const pluginListOutput = `1700000000,,ui,info,vagrant-libvirt (0.12.2%!(VAGRANT_COMMA) global)
+1700000000,,plugin-name,vagrant-libvirt
+1700000000,vagrant-libvirt,plugin-version,0.12.2%!(VAGRANT_COMMA) global
+1700000000,,plugin-name,vagrant-vbguest
+1700000000,vagrant-vbguest,plugin-version,0.32.0%!(VAGRANT_COMMA) global
+`
+
+func TestParseVagrantVersion(t *testing.T) {
+	testCases := []struct {
+		output   string
+		expected string
+	}{
+		{"Vagrant 2.4.1\n", "2.4.1"},
+		{"Vagrant 2.2.19.dev\n", "2.2.19"},
+		{"command not found", ""},
+	}
+	for _, tc := range testCases {
+		if version := ParseVagrantVersion(tc.output); version != tc.expected {
+			t.Errorf("Expected version %q for %q but got %q", tc.expected, tc.output, version)
+		}
+	}
+}
+
+func TestParsePluginList(t *testing.T) {
+	expected := map[string]string{"vagrant-libvirt": "0.12.2", "vagrant-vbguest": "0.32.0"}
+	if plugins := ParsePluginList(pluginListOutput); !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("Expected %v but got %v", expected, plugins)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"2.4.1", "2.2.19", 1},
+		{"2.2.9", "2.2.19", -1},
+		{"2.2.19", "2.2.19", 0},
+		{"2.3", "2.3.0", 0},
+		{"2.2.19.dev", "2.2.19", 0},
+	}
+	for _, tc := range testCases {
+		if result := CompareVersions(tc.a, tc.b); result != tc.expected {
+			t.Errorf("Expected CompareVersions(%q, %q) to be %d but got %d", tc.a, tc.b, tc.expected, result)
+		}
+	}
+}
+
+func TestCapabilityDetector(t *testing.T) {
+	fakeRun := func(version string) func(ctx context.Context, name string, args ...string) (string, error) {
+		return func(ctx context.Context, name string, args ...string) (string, error) {
+			switch {
+			case name == "vagrant" && args[0] == "--version":
+				if version == "" {
+					return "", stderrors.New("executable file not found")
+				}
+				return "Vagrant " + version + "\n", nil
+			case name == "vagrant" && args[0] == "plugin":
+				return pluginListOutput, nil
+			case name == "VBoxManage":
+				return "7.0.14r161095\n", nil
+			}
+			return "", stderrors.New("unexpected command")
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		version     string
+		unsupported []string
+	}{
+		{name: "current vagrant", version: "2.4.1"},
+		{name: "old vagrant", version: "2.2.10", unsupported: []string{FeatureCloudInit}},
+		{name: "older vagrant", version: "2.1.5", unsupported: []string{FeatureUploadCompression, FeatureDisks, FeatureCloudInit}},
+		{name: "unknown version", version: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			detector := NewCapabilityDetector()
+			detector.Run = fakeRun(tc.version)
+			capabilities := detector.Get(context.Background())
+			if capabilities.VagrantVersion != tc.version {
+				t.Errorf("Expected version %q but got %q", tc.version, capabilities.VagrantVersion)
+			}
+			if tc.version != "" && capabilities.Providers["libvirt"] != "0.12.2" {
+				t.Errorf("Expected the libvirt provider version but got %v", capabilities.Providers)
+			}
+			if capabilities.Providers["virtualbox"] != "7.0.14" {
+				t.Errorf("Expected the VirtualBox version but got %v", capabilities.Providers)
+			}
+			var unsupported []string
+			for _, feature := range capabilities.Features {
+				if !feature.Available {
+					unsupported = append(unsupported, feature.Name)
+				}
+				err := detector.Require(context.Background(), feature.Name)
+				if feature.Available != (err == nil) {
+					t.Errorf("Expected Require(%s) to match availability %v but got %v", feature.Name, feature.Available, err)
+				}
+				if err != nil && !errors.Is(err, errors.CodeDependencyMissing) {
+					t.Errorf("Expected a dependency error but got %v", err)
+				}
+			}
+			if !reflect.DeepEqual(unsupported, tc.unsupported) {
+				t.Errorf("Expected unsupported features %v but got %v", tc.unsupported, unsupported)
+			}
+		})
+	}
+}
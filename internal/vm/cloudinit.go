@@ -0,0 +1,120 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// maxCloudInitSize bounds user-data documents; NoCloud seeds are meant for configuration, not
+// payloads
+const maxCloudInitSize = 64 * 1024
+
+// Guest locations of the first-boot cloud-init fallback
+const (
+	guestCloudInitSeedDir = "/var/lib/cloud/seed/nocloud"
+	guestCloudInitConfig  = "/etc/cloud/cloud.cfg.d/99-vagrant-mcp.cfg"
+)
+
+// cloudInitFallbackScript seeds cloud-init's NoCloud datasource with the user-data and reruns
+// cloud-init as a new instance. Host keys are kept so Vagrant can still connect.
+const cloudInitFallbackScript = `if ! command -v cloud-init >/dev/null; then
+  echo "cloud-init is not installed in the box; user-data was not applied" >&2
+  exit 1
+fi
+mkdir -p %[1]s
+echo '%[3]s' | base64 -d > %[1]s/user-data
+echo 'instance-id: vagrant-mcp-%[4]s' > %[1]s/meta-data
+printf 'datasource_list: [NoCloud, None]\nssh_deletekeys: false\n' > %[2]s
+cloud-init clean --logs
+cloud-init init --local && cloud-init init && cloud-init modules --mode=config && cloud-init modules --mode=final`
+
+// ValidateCloudInit checks the cloud-init user-data of a VM configuration
+func ValidateCloudInit(config core.VMConfig) error {
+	if config.CloudInit == "" {
+		return nil
+	}
+	if len(config.CloudInit) > maxCloudInitSize {
+		return errors.InvalidInput(fmt.Sprintf("cloud-init user-data is larger than %d bytes", maxCloudInitSize))
+	}
+	if _, ok := cloudInitContentType(config.CloudInit); !ok {
+		return errors.InvalidInput("cloud-init user-data must start with '#cloud-config' or a '#!' script line")
+	}
+	return nil
+}
+
+// LoadCloudInit returns the user-data in source, which is either the document itself or the
+// path of a file on the host holding it
+func LoadCloudInit(source string) (string, error) {
+	if _, ok := cloudInitContentType(source); ok {
+		return source, nil
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cloud-init user-data: %w", err)
+	}
+	return string(data), nil
+}
+
+// cloudInitContentType returns the MIME type of a user-data document
+func cloudInitContentType(userData string) (string, bool) {
+	switch {
+	case strings.HasPrefix(userData, "#cloud-config"):
+		return "text/cloud-config", true
+	case strings.HasPrefix(userData, "#!"):
+		return "text/x-shellscript", true
+	default:
+		return "", false
+	}
+}
+
+// cloudInitNative reports whether Vagrant's experimental cloud_init feature is enabled for the
+// vagrant commands this server runs, and the installed Vagrant has it
+func cloudInitNative() bool {
+	for _, feature := range strings.Split(os.Getenv("VAGRANT_EXPERIMENTAL"), ",") {
+		feature = strings.TrimSpace(feature)
+		if feature == "1" || feature == "cloud_init" {
+			if err := GlobalCapabilities.Require(context.Background(), FeatureCloudInit); err != nil {
+				log.Warn().Err(err).Msg("Applying cloud-init with a provisioner")
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// vagrantCloudInitConfig returns the Vagrantfile settings that apply the cloud-init user-data,
+// or an empty string when there is none. Vagrant attaches it as a NoCloud seed when its
+// cloud_init feature is enabled; otherwise a provisioner applies it on the first boot.
+func vagrantCloudInitConfig(config core.VMConfig) string {
+	if config.CloudInit == "" {
+		return ""
+	}
+	contentType, _ := cloudInitContentType(config.CloudInit)
+	// base64 keeps the document intact through Ruby and shell quoting
+	encoded := base64.StdEncoding.EncodeToString([]byte(config.CloudInit))
+
+	var b strings.Builder
+	b.WriteString("\n  # cloud-init user-data\n")
+	if cloudInitNative() {
+		fmt.Fprintf(&b, "  config.vm.cloud_init :user_data, content_type: \"%s\", inline: \"%s\".unpack1(\"m\")\n", contentType, encoded)
+		return b.String()
+	}
+	sum := sha256.Sum256([]byte(config.CloudInit))
+	b.WriteString("  config.vm.provision \"shell\", name: \"cloud-init\", inline: <<-SHELL\n")
+	writeIndented(&b, fmt.Sprintf(cloudInitFallbackScript, guestCloudInitSeedDir, guestCloudInitConfig, encoded, hex.EncodeToString(sum[:8])))
+	b.WriteString("  SHELL\n")
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+const testUserData = "#cloud-config\nusers:\n  - name: alice\n    ssh_authorized_keys:\n      - ssh-ed25519 AAAA alice@example\npackages: [htop]\n"
+
+func TestValidateCloudInit(t *testing.T) {
+	testCases := []struct {
+		name      string
+		userData  string
+		expectErr bool
+	}{
+		{"none", "", false},
+		{"cloud-config", testUserData, false},
+		{"script", "#!/bin/sh\necho hello\n", false},
+		{"plain yaml", "users: []\n", true},
+		{"too large", "#cloud-config\n" + strings.Repeat("#", maxCloudInitSize), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateCloudInit(core.VMConfig{CloudInit: tc.userData}); tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadCloudInit(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "user-data.yaml")
+	if err := os.WriteFile(file, []byte(testUserData), 0644); err != nil {
+		t.Fatalf("Failed to write user-data: %v", err)
+	}
+	for _, source := range []string{file, testUserData} {
+		userData, err := LoadCloudInit(source)
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		if userData != testUserData {
+			t.Errorf("Expected the user-data document but got %q", userData)
+		}
+	}
+	if _, err := LoadCloudInit(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestVagrantCloudInitConfig(t *testing.T) {
+	if config := vagrantCloudInitConfig(core.VMConfig{}); config != "" {
+		t.Errorf("Expected no cloud-init settings but got %q", config)
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(testUserData))
+
+	testCases := []struct {
+		name         string
+		experimental string
+		contains     []string
+		excludes     []string
+	}{
+		{
+			name:         "native",
+			experimental: "disks,cloud_init",
+			contains:     []string{`config.vm.cloud_init :user_data, content_type: "text/cloud-config", inline: "` + encoded + `".unpack1("m")`},
+			excludes:     []string{"config.vm.provision"},
+		},
+		{
+			name:     "provisioner fallback",
+			contains: []string{`config.vm.provision "shell", name: "cloud-init"`, "echo '" + encoded + "' | base64 -d > /var/lib/cloud/seed/nocloud/user-data", "ssh_deletekeys: false", "cloud-init modules --mode=final"},
+			excludes: []string{"config.vm.cloud_init"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("VAGRANT_EXPERIMENTAL", tc.experimental)
+			config := vagrantCloudInitConfig(core.VMConfig{CloudInit: testUserData})
+			for _, want := range tc.contains {
+				if !strings.Contains(config, want) {
+					t.Errorf("Expected settings to contain %q but got:\n%s", want, config)
+				}
+			}
+			for _, unwanted := range tc.excludes {
+				if strings.Contains(config, unwanted) {
+					t.Errorf("Expected settings not to contain %q but got:\n%s", unwanted, config)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// vmConfigFile is the file in a VM directory that holds the VM's configuration
+const vmConfigFile = "config.json"
+
+// configPath returns the path of a VM's configuration. An adopted VM keeps it in its VM
+// directory too, not in the project it runs in.
+func (m *Manager) configPath(name string) string {
+	return filepath.Join(m.baseDir, name, vmConfigFile)
+}
+
+// legacyConfigPath returns where VM configurations were kept before they moved into the VM
+// directories: next to the base directory, among the server's other settings
+func legacyConfigPath(baseDir, name string) string {
+	return filepath.Join(filepath.Dir(baseDir), name+".json")
+}
+
+// MigrateVMConfigs moves the configurations kept next to the base directory into the VM
+// directories and returns how many moved. Files without a VM directory, such as the server's
+// other settings, are left alone, as is a legacy file whose VM already has a configuration.
+func MigrateVMConfigs(baseDir string) (int, error) {
+	legacyFiles, err := filepath.Glob(filepath.Join(filepath.Dir(baseDir), "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, legacyFile := range legacyFiles {
+		name := strings.TrimSuffix(filepath.Base(legacyFile), ".json")
+		vmDir := filepath.Join(baseDir, name)
+		if info, err := os.Stat(vmDir); err != nil || !info.IsDir() {
+			continue
+		}
+		configFile := filepath.Join(vmDir, vmConfigFile)
+		if _, err := os.Stat(configFile); err == nil {
+			log.Warn().Str("name", name).Str("file", legacyFile).Msg("VM already has a configuration; leaving the legacy one in place")
+			continue
+		}
+		data, err := os.ReadFile(legacyFile)
+		if err != nil {
+			return migrated, err
+		}
+		if err := writeFileAtomic(configFile, data, 0644); err != nil {
+			return migrated, err
+		}
+		if err := os.Remove(legacyFile); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// writeFileAtomic writes a file through a temporary file in the same directory renamed over
+// it, so readers see the old or the new content and never a partial write
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+func TestMigrateVMConfigs(t *testing.T) {
+	root := t.TempDir()
+	baseDir := filepath.Join(root, "vms")
+	for _, name := range []string{"web", "api"} {
+		if err := os.MkdirAll(filepath.Join(baseDir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name+".json"), []byte(`{"name": "`+name+`", "cpu": 2}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// api was configured again after the move, and hooks.json is a server setting
+	if err := os.WriteFile(filepath.Join(baseDir, "api", vmConfigFile), []byte(`{"name": "api", "cpu": 4}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "hooks.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := MigrateVMConfigs(baseDir)
+	if err != nil || migrated != 1 {
+		t.Fatalf("Expected 1 config to migrate but got %d: %v", migrated, err)
+	}
+	m := &Manager{baseDir: baseDir}
+	if config, err := m.GetVMConfig(context.Background(), "web"); err != nil || config.CPU != 2 {
+		t.Errorf("Expected the migrated config of web but got %+v: %v", config, err)
+	}
+	if config, err := m.GetVMConfig(context.Background(), "api"); err != nil || config.CPU != 4 {
+		t.Errorf("Expected the current config of api to be kept but got %+v: %v", config, err)
+	}
+	for file, exists := range map[string]bool{"web.json": false, "api.json": true, "hooks.json": true} {
+		if _, err := os.Stat(filepath.Join(root, file)); (err == nil) != exists {
+			t.Errorf("Expected %s to exist: %v", file, exists)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, vmConfigFile)
+	for _, content := range []string{`{"cpu": 2}`, `{"cpu": 4}`} {
+		if err := writeFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != `{"cpu": 4}` {
+		t.Errorf("Expected the last content but got %s: %v", data, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left but got %d entries", len(entries))
+	}
+}
+
+func TestSaveVMConfigRevisions(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "vms")
+	m := &Manager{baseDir: baseDir}
+	config := core.VMConfig{Name: "web", CPU: 2}
+	if err := m.saveVMConfig("web", &config); err != nil || config.Revision != 1 {
+		t.Fatalf("Expected the first save to be revision 1 but got %d: %v", config.Revision, err)
+	}
+
+	// Two updates read the same revision; the second one to save conflicts
+	first, _ := m.GetVMConfig(context.Background(), "web")
+	second, _ := m.GetVMConfig(context.Background(), "web")
+	first.CPU = 4
+	if err := m.UpdateVMConfig(context.Background(), "web", first); err != nil {
+		t.Fatalf("Failed to update VM config: %v", err)
+	}
+	second.Memory = 4096
+	err := m.UpdateVMConfig(context.Background(), "web", second)
+	if !errors.IsConflict(err) {
+		t.Fatalf("Expected a conflict but got %v", err)
+	}
+	stored, _ := m.GetVMConfig(context.Background(), "web")
+	if stored.CPU != 4 || stored.Memory != 0 || stored.Revision != 2 {
+		t.Errorf("Expected the first update at revision 2 but got %+v", stored)
+	}
+
+	// A config without a revision does not overwrite a stored one unless it replaces it
+	if err := m.UpdateVMConfig(context.Background(), "web", core.VMConfig{Name: "web"}); !errors.IsConflict(err) {
+		t.Fatalf("Expected a conflict but got %v", err)
+	}
+	if err := m.replaceVMConfig("web", &core.VMConfig{Name: "web"}); err != nil {
+		t.Fatalf("Failed to replace VM config: %v", err)
+	}
+	if stored, _ := m.GetVMConfig(context.Background(), "web"); stored.Revision != 3 {
+		t.Errorf("Expected revision 3 but got %d", stored.Revision)
+	}
+}
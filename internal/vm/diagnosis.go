@@ -0,0 +1,346 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// setupProvisioner is how vagrant names the unnamed setup provisioner of generated Vagrantfiles
+const setupProvisioner = "shell"
+
+// Diagnosis limits
+const (
+	maxDiagnosisCommands   = 5
+	maxDiagnosisErrorLines = 10
+	// guestLogLines is how many lines of each guest log a diagnosis fetches
+	guestLogLines = 50
+)
+
+// guestLogMarker starts the output of each log in the output of a ProvisionLogsCommand
+const guestLogMarker = "### log "
+
+// GuestLogJournal names the errors of the current boot in the systemd journal
+const GuestLogJournal = "journal"
+
+var (
+	// transcriptStatusPattern matches the status of an operation log header
+	transcriptStatusPattern = regexp.MustCompile(`^# started: .*, status: (.*)$`)
+	// machinePrefixPattern matches the machine name vagrant puts before guest output
+	machinePrefixPattern = regexp.MustCompile(`^\s+[\w.-]+: ?`)
+	// errorLinePattern matches output lines reporting an error
+	errorLinePattern = regexp.MustCompile(`(?i)(\berror\b|\bfatal\b|\bfailed\b|\bcannot\b|could not|unable to|not found|denied|^E: )`)
+)
+
+// DiagnosisFinding is a likely cause of a provisioning failure and how to fix it
+type DiagnosisFinding struct {
+	Cause string `json:"cause"`
+	Fix   string `json:"fix"`
+	// Evidence is the output line the cause was recognised in
+	Evidence string `json:"evidence"`
+}
+
+// GuestLog is the end of a guest log fetched for a diagnosis
+type GuestLog struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ProvisionDiagnosis explains the last provisioning run of a VM
+type ProvisionDiagnosis struct {
+	VMName string `json:"vm_name"`
+	Failed bool   `json:"failed"`
+	// Status is the status recorded in the operation log header
+	Status string `json:"status,omitempty"`
+	// Provisioner is the failing provisioner as vagrant names it, and Step the provisioning
+	// step it runs, if any
+	Provisioner string              `json:"provisioner,omitempty"`
+	Step        *core.ProvisionStep `json:"step,omitempty"`
+	// Completed are the provisioners that succeeded before it
+	Completed []string `json:"completed"`
+	// LastCommands are the commands the provisioner traced last with set -x, or else the last
+	// commands of its script
+	LastCommands []string `json:"last_commands,omitempty"`
+	ErrorLines   []string `json:"error_lines,omitempty"`
+	// Output is the end of the failing provisioner's output
+	Output    string             `json:"output,omitempty"`
+	Findings  []DiagnosisFinding `json:"findings"`
+	GuestLogs []GuestLog         `json:"guest_logs,omitempty"`
+}
+
+// diagnosisRule recognises a cause of provisioning failures in provisioner output
+type diagnosisRule struct {
+	pattern *regexp.Regexp
+	cause   string
+	fix     string
+	// logs are the guest logs that explain the cause
+	logs []string
+}
+
+// diagnosisRules are checked against the failing provisioner's output, in order
+var diagnosisRules = []diagnosisRule{
+	{
+		pattern: regexp.MustCompile(`Could not get lock|Unable to acquire the dpkg frontend lock|is another process using it`),
+		cause:   "Another apt or dpkg process, usually unattended-upgrades on the first boot, held the package lock",
+		fix:     "Rerun the provisioner with provision_vm, or wait for the lock first, e.g. 'while fuser /var/lib/dpkg/lock-frontend >/dev/null 2>&1; do sleep 5; done'",
+		logs:    []string{"/var/log/apt/term.log", "/var/log/unattended-upgrades/unattended-upgrades.log"},
+	},
+	{
+		pattern: regexp.MustCompile(`dpkg was interrupted`),
+		cause:   "An earlier package installation was interrupted",
+		fix:     "Run 'sudo dpkg --configure -a' in the VM, then rerun the provisioner",
+		logs:    []string{"/var/log/dpkg.log"},
+	},
+	{
+		pattern: regexp.MustCompile(`Unable to locate package|has no installation candidate|Package .* is not available`),
+		cause:   "A package is not in the box's apt sources, or the package lists are stale",
+		fix:     "Check the package name for the box's release and run 'apt-get update' before installing",
+		logs:    []string{"/var/log/apt/term.log"},
+	},
+	{
+		pattern: regexp.MustCompile(`Temporary failure (in name )?resolution|Could not resolve|Name or service not known`),
+		cause:   "The guest could not resolve host names",
+		fix:     "Check the host's network and VPN; behind a proxy, create the VM with http_proxy and https_proxy",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)connection timed out|failed to connect|connection refused|network is unreachable`),
+		cause:   "The guest could not reach a download server",
+		fix:     "Check that the server is reachable from the guest; behind a proxy, create the VM with http_proxy and https_proxy",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)certificate verify failed|SSL certificate problem|x509: certificate`),
+		cause:   "The guest does not trust a TLS certificate, often one of an intercepting proxy",
+		fix:     "Create the VM with ca_certificates holding the proxy's CA certificate",
+	},
+	{
+		pattern: regexp.MustCompile(`No space left on device`),
+		cause:   "The guest disk is full",
+		fix:     "Grow the disk with resize_vm_disk or free space with cleanup_vm",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)out of memory|Killed\s*$|Cannot allocate memory`),
+		cause:   "A command ran out of memory",
+		fix:     "Give the VM more memory with set_vm_resources",
+		logs:    []string{GuestLogJournal},
+	},
+	{
+		pattern: regexp.MustCompile(`\$'\\r': command not found|\r: command not found|/bin/(ba)?sh\^M`),
+		cause:   "The script has Windows line endings",
+		fix:     "Convert the script to LF line endings, e.g. with dos2unix, or set core.autocrlf=input in git",
+	},
+	{
+		pattern: regexp.MustCompile(`command not found`),
+		cause:   "A command the script uses is not installed, or not on the PATH of the user the provisioner runs as",
+		fix:     "Install the command in an earlier step; tools installed for the vagrant user need privileged: false",
+	},
+	{
+		pattern: regexp.MustCompile(`Permission denied`),
+		cause:   "The provisioner lacked permission for a file or command",
+		fix:     "Run the step privileged (the default), or use sudo for the commands that need root",
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)cloud-init.*(error|fail)`),
+		cause:   "cloud-init reported a problem",
+		fix:     "Check the cloud-init user-data with the guest log below",
+		logs:    []string{"/var/log/cloud-init-output.log"},
+	},
+}
+
+// provisionSection is the output of one provisioner in a provisioning transcript
+type provisionSection struct {
+	name   string
+	output []string
+}
+
+// DiagnoseProvisionLog diagnoses a provision operation log of a VM, whose configuration names
+// its provisioning steps. The failing provisioner is the last one vagrant started.
+func DiagnoseProvisionLog(vmName, transcript string, config core.VMConfig) ProvisionDiagnosis {
+	diagnosis := ProvisionDiagnosis{VMName: vmName, Completed: []string{}, Findings: []DiagnosisFinding{}}
+	var sections []provisionSection
+	var trailer []string
+	for _, line := range strings.Split(transcript, "\n") {
+		if match := transcriptStatusPattern.FindStringSubmatch(line); match != nil && diagnosis.Status == "" {
+			diagnosis.Status = match[1]
+			diagnosis.Failed = strings.HasPrefix(diagnosis.Status, "failed")
+			continue
+		}
+		if match := provisionerPattern.FindStringSubmatch(line); match != nil {
+			sections = append(sections, provisionSection{name: strings.TrimSpace(match[1])})
+			continue
+		}
+		if len(sections) == 0 {
+			continue
+		}
+		// Vagrant's own lines, such as its error, follow the guest output
+		if machinePrefixPattern.MatchString(line) {
+			current := &sections[len(sections)-1]
+			current.output = append(current.output, machinePrefixPattern.ReplaceAllString(line, ""))
+		} else if strings.TrimSpace(line) != "" {
+			trailer = append(trailer, line)
+		}
+	}
+	if !diagnosis.Failed || len(sections) == 0 {
+		for _, section := range sections {
+			diagnosis.Completed = append(diagnosis.Completed, section.name)
+		}
+		return diagnosis
+	}
+
+	failing := sections[len(sections)-1]
+	for _, section := range sections[:len(sections)-1] {
+		diagnosis.Completed = append(diagnosis.Completed, section.name)
+	}
+	diagnosis.Provisioner = failing.name
+	name, _, _ := strings.Cut(failing.name, " (")
+	for i, step := range config.ProvisionSteps {
+		if step.Name == name {
+			diagnosis.Step = &config.ProvisionSteps[i]
+		}
+	}
+
+	output := append(append([]string{}, failing.output...), trailer...)
+	diagnosis.Output = tail(strings.TrimSpace(strings.Join(output, "\n")), maxProvisionerOutput)
+	diagnosis.LastCommands = lastCommands(failing, diagnosis.Step, config)
+	for _, line := range output {
+		if errorLinePattern.MatchString(line) {
+			diagnosis.ErrorLines = append(diagnosis.ErrorLines, strings.TrimSpace(line))
+		}
+	}
+	if len(diagnosis.ErrorLines) > maxDiagnosisErrorLines {
+		diagnosis.ErrorLines = diagnosis.ErrorLines[len(diagnosis.ErrorLines)-maxDiagnosisErrorLines:]
+	}
+	diagnosis.Findings = diagnoseOutput(output)
+	if len(diagnosis.Findings) == 0 {
+		fix := "Rerun it with provision_vm after fixing its script; add 'set -x' to trace the commands it runs"
+		if diagnosis.Step == nil && name == setupProvisioner {
+			fix = "Check the environment and provisioner commands the VM was created with; add 'set -x' to trace them"
+		}
+		diagnosis.Findings = append(diagnosis.Findings, DiagnosisFinding{
+			Cause:    fmt.Sprintf("The %s provisioner exited with an error", failing.name),
+			Fix:      fix,
+			Evidence: lastLine(output),
+		})
+	}
+	return diagnosis
+}
+
+// lastCommands returns the commands a failing provisioner traced last, or else the last
+// commands of the script it runs when it is known
+func lastCommands(section provisionSection, step *core.ProvisionStep, config core.VMConfig) []string {
+	var commands []string
+	for _, line := range section.output {
+		if command, ok := strings.CutPrefix(strings.TrimSpace(line), "+ "); ok {
+			commands = append(commands, command)
+		}
+	}
+	if len(commands) == 0 {
+		script := ""
+		switch {
+		case step != nil:
+			script = step.Inline
+		case strings.HasPrefix(section.name, setupProvisioner):
+			script = strings.Join(append(append([]string{}, config.Environment...), config.Provisioners...), "\n")
+		}
+		for _, line := range strings.Split(script, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				commands = append(commands, line)
+			}
+		}
+	}
+	if len(commands) > maxDiagnosisCommands {
+		commands = commands[len(commands)-maxDiagnosisCommands:]
+	}
+	return commands
+}
+
+// diagnoseOutput returns the findings of the rules matching provisioner output, each once,
+// with the last line it was recognised in
+func diagnoseOutput(output []string) []DiagnosisFinding {
+	findings := []DiagnosisFinding{}
+	for _, rule := range diagnosisRules {
+		evidence := ""
+		for _, line := range output {
+			if rule.pattern.MatchString(line) {
+				evidence = strings.TrimSpace(line)
+			}
+		}
+		if evidence != "" {
+			findings = append(findings, DiagnosisFinding{Cause: rule.cause, Fix: rule.fix, Evidence: evidence})
+		}
+	}
+	return findings
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output []string) string {
+	for i := len(output) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(output[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// DiagnosisGuestLogs returns the guest logs that explain the findings of a diagnosis, after
+// the errors of the journal
+func DiagnosisGuestLogs(diagnosis ProvisionDiagnosis) []string {
+	logs := []string{GuestLogJournal}
+	for _, finding := range diagnosis.Findings {
+		for _, rule := range diagnosisRules {
+			if rule.cause != finding.Cause {
+				continue
+			}
+			for _, path := range rule.logs {
+				if !slices.Contains(logs, path) {
+					logs = append(logs, path)
+				}
+			}
+		}
+	}
+	return logs
+}
+
+// ProvisionLogsCommand returns the command printing the end of each guest log, after a log
+// marker line naming it. Missing logs print nothing.
+func ProvisionLogsCommand(logs []string) string {
+	var script strings.Builder
+	for _, path := range logs {
+		fmt.Fprintf(&script, "echo %s; ", shellquote.Quote(guestLogMarker+path))
+		if path == GuestLogJournal {
+			fmt.Fprintf(&script, "sudo -n journalctl -b -p err -n %d --no-pager 2>/dev/null; ", guestLogLines)
+			continue
+		}
+		fmt.Fprintf(&script, "sudo -n tail -n %d %s 2>/dev/null; ", guestLogLines, shellquote.Quote(path))
+	}
+	return "sh -c " + shellquote.Quote(script.String()+"true")
+}
+
+// ParseProvisionLogs parses the output of a ProvisionLogsCommand, leaving out empty logs
+func ParseProvisionLogs(output string) []GuestLog {
+	logs := []GuestLog{}
+	var current *GuestLog
+	var content []string
+	flush := func() {
+		if current != nil && strings.TrimSpace(strings.Join(content, "\n")) != "" {
+			current.Content = strings.TrimRight(strings.Join(content, "\n"), "\n")
+			logs = append(logs, *current)
+		}
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if path, ok := strings.CutPrefix(line, guestLogMarker); ok {
+			flush()
+			current, content = &GuestLog{Path: strings.TrimSpace(path)}, nil
+			continue
+		}
+		content = append(content, line)
+	}
+	flush()
+	return logs
+}
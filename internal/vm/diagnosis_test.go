@@ -0,0 +1,86 @@
+package vm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestDiagnoseProvisionLog(t *testing.T) {
+	transcript := `$ vagrant up --machine-readable
+# started: 2025-01-02T10:00:00Z, duration: 1m2s, status: failed: exit status 1
+==> default: Running provisioner: shell...
+    default: Running: inline script
+    default: Reading package lists...
+==> default: Running provisioner: deps (shell)...
+    default: Running: inline script
+    default: + apt-get update
+    default: + apt-get install -y libpq-dev
+    default: E: Could not get lock /var/lib/dpkg/lock-frontend. It is held by process 1234 (unattended-upgr)
+The SSH command responded with a non-zero exit status. Vagrant
+assumes that this means the command failed.
+`
+	config := core.VMConfig{ProvisionSteps: []core.ProvisionStep{
+		{Name: "deps", Inline: "apt-get update\napt-get install -y libpq-dev"},
+		{Name: "migrate", Path: "scripts/migrate.sh"},
+	}}
+	diagnosis := DiagnoseProvisionLog("web", transcript, config)
+
+	if !diagnosis.Failed || diagnosis.Provisioner != "deps (shell)" || diagnosis.Step == nil || diagnosis.Step.Name != "deps" {
+		t.Fatalf("Expected the deps step to fail but got %+v", diagnosis)
+	}
+	if !reflect.DeepEqual(diagnosis.Completed, []string{"shell"}) {
+		t.Errorf("Expected the setup provisioner to complete but got %v", diagnosis.Completed)
+	}
+	if !reflect.DeepEqual(diagnosis.LastCommands, []string{"apt-get update", "apt-get install -y libpq-dev"}) {
+		t.Errorf("Expected the traced commands but got %v", diagnosis.LastCommands)
+	}
+	if len(diagnosis.ErrorLines) != 2 || !strings.HasPrefix(diagnosis.ErrorLines[0], "E: Could not get lock") {
+		t.Errorf("Expected the lock error and vagrant's error but got %v", diagnosis.ErrorLines)
+	}
+	if len(diagnosis.Findings) != 1 || !strings.Contains(diagnosis.Findings[0].Cause, "package lock") {
+		t.Fatalf("Expected the package lock to be found but got %+v", diagnosis.Findings)
+	}
+	logs := DiagnosisGuestLogs(diagnosis)
+	if !reflect.DeepEqual(logs, []string{GuestLogJournal, "/var/log/apt/term.log", "/var/log/unattended-upgrades/unattended-upgrades.log"}) {
+		t.Errorf("Expected the journal and apt logs but got %v", logs)
+	}
+}
+
+func TestDiagnoseProvisionLogWithoutKnownCause(t *testing.T) {
+	transcript := "$ vagrant provision\n# started: 2025-01-02T10:00:00Z, duration: 5s, status: failed: exit status 1\n" +
+		"==> default: Running provisioner: shell...\n" +
+		"    default: make: *** [install] Error 2\n"
+	config := core.VMConfig{Provisioners: []string{"cd /vagrant", "make install"}}
+	diagnosis := DiagnoseProvisionLog("web", transcript, config)
+
+	if diagnosis.Step != nil || !reflect.DeepEqual(diagnosis.LastCommands, []string{"cd /vagrant", "make install"}) {
+		t.Errorf("Expected the setup commands as the last commands but got %+v", diagnosis)
+	}
+	if len(diagnosis.Findings) != 1 || diagnosis.Findings[0].Evidence != "make: *** [install] Error 2" {
+		t.Errorf("Expected a generic finding with the last output line but got %+v", diagnosis.Findings)
+	}
+
+	succeeded := DiagnoseProvisionLog("web", strings.Replace(transcript, "status: failed: exit status 1", "status: ok", 1), config)
+	if succeeded.Failed || succeeded.Provisioner != "" || !reflect.DeepEqual(succeeded.Completed, []string{"shell"}) {
+		t.Errorf("Expected a successful run but got %+v", succeeded)
+	}
+}
+
+func TestParseProvisionLogs(t *testing.T) {
+	command := ProvisionLogsCommand([]string{GuestLogJournal, "/var/log/apt/term.log"})
+	for _, expected := range []string{"journalctl -b -p err -n 50", "tail -n 50 '\\''/var/log/apt/term.log'\\''"} {
+		if !strings.Contains(command, expected) {
+			t.Errorf("Expected %q in the command but got %s", expected, command)
+		}
+	}
+
+	output := "### log journal\n### log /var/log/apt/term.log\nLog started: 2025-01-02\nSetting up jq\n"
+	logs := ParseProvisionLogs(output)
+	expected := []GuestLog{{Path: "/var/log/apt/term.log", Content: "Log started: 2025-01-02\nSetting up jq"}}
+	if !reflect.DeepEqual(logs, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, logs)
+	}
+}
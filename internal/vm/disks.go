@@ -0,0 +1,151 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// diskNamePattern limits disk names to what can be used as an ext4 label
+var diskNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,15}$`)
+
+// growRootScript grows the partition and filesystem holding / to fill its disk. It does
+// nothing when the disk has not grown, or when / is not a partition of a plain disk (LVM).
+const growRootScript = `root=$(findmnt -no SOURCE /)
+if [ -e "/sys/class/block/$(basename "$root")/partition" ] && command -v growpart >/dev/null; then
+  disk="/dev/$(lsblk -no PKNAME "$root" | head -n 1)"
+  part=$(cat "/sys/class/block/$(basename "$root")/partition")
+  if growpart "$disk" "$part"; then
+    case $(findmnt -no FSTYPE /) in
+      ext*) resize2fs "$root" ;;
+      xfs) xfs_growfs / ;;
+    esac
+  fi
+fi`
+
+// ValidateDisks checks the root disk size and additional disks of a VM configuration
+func ValidateDisks(config core.VMConfig) error {
+	if config.DiskSizeGB < 0 {
+		return errors.InvalidInput("disk_size_gb must not be negative")
+	}
+	names := make(map[string]bool)
+	mountPoints := make(map[string]bool)
+	for _, disk := range config.Disks {
+		if !diskNamePattern.MatchString(disk.Name) {
+			return errors.InvalidInput(fmt.Sprintf("invalid disk name '%s': use up to 16 lowercase letters, digits, '-' or '_'", disk.Name))
+		}
+		if names[disk.Name] {
+			return errors.InvalidInput(fmt.Sprintf("duplicate disk name '%s'", disk.Name))
+		}
+		names[disk.Name] = true
+		if disk.SizeGB <= 0 {
+			return errors.InvalidInput(fmt.Sprintf("disk '%s' must have a positive size", disk.Name))
+		}
+		if !path.IsAbs(disk.MountPoint) {
+			return errors.InvalidInput(fmt.Sprintf("disk '%s' must have an absolute mount point", disk.Name))
+		}
+		mountPoint := path.Clean(disk.MountPoint)
+		if mountPoint == "/" || mountPoint == "/vagrant" || strings.ContainsAny(mountPoint, " '\"\\$`") {
+			return errors.InvalidInput(fmt.Sprintf("disk '%s' cannot be mounted at '%s'", disk.Name, disk.MountPoint))
+		}
+		if mountPoints[mountPoint] {
+			return errors.InvalidInput(fmt.Sprintf("duplicate mount point '%s'", disk.MountPoint))
+		}
+		mountPoints[mountPoint] = true
+	}
+	return nil
+}
+
+// ResizeDisk grows the root disk of a VM to sizeGB. The Vagrantfile is regenerated and a
+// running VM is reloaded so the provider resizes the disk and the guest grows its root
+// filesystem on boot. Disks can only grow.
+func (m *Manager) ResizeDisk(ctx context.Context, name string, sizeGB int) (core.VMConfig, error) {
+	release, err := m.operations.acquire(name, OperationReconfigure)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	defer release()
+	config, err := m.GetVMConfig(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	if sizeGB <= 0 {
+		return core.VMConfig{}, errors.InvalidInput("size_gb must be positive")
+	}
+	if sizeGB <= config.DiskSizeGB {
+		return core.VMConfig{}, errors.InvalidInput(fmt.Sprintf("the root disk is already %dGB; disks can only grow", config.DiskSizeGB))
+	}
+	if err := GlobalCapabilities.Require(ctx, FeatureDisks); err != nil {
+		return core.VMConfig{}, err
+	}
+
+	config.DiskSizeGB = sizeGB
+	if err := m.applyConfig(ctx, name, config); err != nil {
+		return core.VMConfig{}, err
+	}
+	log.Info().Str("name", name).Int("size_gb", sizeGB).Msg("Root disk size updated")
+	return config, nil
+}
+
+// vagrantDiskConfig returns the Vagrantfile disk settings and the provisioner that formats
+// and mounts the additional disks, or an empty string when the VM uses the box defaults
+func vagrantDiskConfig(config core.VMConfig) string {
+	if config.DiskSizeGB == 0 && len(config.Disks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n  # Disk settings\n")
+	if config.DiskSizeGB > 0 {
+		fmt.Fprintf(&b, "  config.vm.disk :disk, size: \"%dGB\", primary: true\n", config.DiskSizeGB)
+	}
+	for _, disk := range config.Disks {
+		fmt.Fprintf(&b, "  config.vm.disk :disk, size: \"%dGB\", name: \"%s\"\n", disk.SizeGB, disk.Name)
+	}
+	if config.DiskSizeGB > 0 {
+		b.WriteString("  config.vm.provision \"shell\", name: \"grow-root\", run: \"always\", inline: <<-SHELL\n")
+		writeIndented(&b, growRootScript)
+		b.WriteString("  SHELL\n")
+	}
+	if len(config.Disks) > 0 {
+		b.WriteString("  config.vm.provision \"shell\", name: \"disks\", run: \"always\", inline: <<-SHELL\n")
+		for _, disk := range config.Disks {
+			writeIndented(&b, diskMountScript(disk))
+		}
+		b.WriteString("  SHELL\n")
+	}
+	return b.String()
+}
+
+// diskMountScript formats the first blank disk with the disk's label, unless a filesystem with
+// that label already exists, and mounts it. Disks are attached in order, so formatting the
+// first blank disk matches them to the configuration in order.
+func diskMountScript(disk core.Disk) string {
+	mountPoint := path.Clean(disk.MountPoint)
+	return fmt.Sprintf(`if ! blkid -L %[1]s >/dev/null 2>&1; then
+  for dev in $(lsblk -dpno NAME,TYPE | awk '$2 == "disk" {print $1}'); do
+    if [ "$(lsblk -no NAME "$dev" | wc -l)" -eq 1 ] && ! blkid "$dev" >/dev/null 2>&1; then
+      mkfs.ext4 -q -L %[1]s "$dev"
+      break
+    fi
+  done
+fi
+mkdir -p %[2]s
+grep -q "^LABEL=%[1]s " /etc/fstab || echo "LABEL=%[1]s %[2]s ext4 defaults,nofail 0 2" >> /etc/fstab
+mountpoint -q %[2]s || mount %[2]s`, disk.Name, mountPoint)
+}
+
+// writeIndented writes a shell script into a Vagrantfile heredoc
+func writeIndented(b *strings.Builder, script string) {
+	for _, line := range strings.Split(script, "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+}
@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestValidateDisks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		config    core.VMConfig
+		expectErr bool
+	}{
+		{"no disks", core.VMConfig{}, false},
+		{"root disk and data disk", core.VMConfig{DiskSizeGB: 80, Disks: []core.Disk{{Name: "data", SizeGB: 20, MountPoint: "/data"}}}, false},
+		{"negative root disk", core.VMConfig{DiskSizeGB: -1}, true},
+		{"invalid name", core.VMConfig{Disks: []core.Disk{{Name: "Data Disk", SizeGB: 20, MountPoint: "/data"}}}, true},
+		{"name too long for a label", core.VMConfig{Disks: []core.Disk{{Name: "a-very-long-disk-name", SizeGB: 20, MountPoint: "/data"}}}, true},
+		{"zero size", core.VMConfig{Disks: []core.Disk{{Name: "data", MountPoint: "/data"}}}, true},
+		{"relative mount point", core.VMConfig{Disks: []core.Disk{{Name: "data", SizeGB: 20, MountPoint: "data"}}}, true},
+		{"root mount point", core.VMConfig{Disks: []core.Disk{{Name: "data", SizeGB: 20, MountPoint: "/"}}}, true},
+		{"synced folder mount point", core.VMConfig{Disks: []core.Disk{{Name: "data", SizeGB: 20, MountPoint: "/vagrant/"}}}, true},
+		{"mount point with shell characters", core.VMConfig{Disks: []core.Disk{{Name: "data", SizeGB: 20, MountPoint: "/data$(id)"}}}, true},
+		{"duplicate names", core.VMConfig{Disks: []core.Disk{{Name: "data", SizeGB: 20, MountPoint: "/a"}, {Name: "data", SizeGB: 20, MountPoint: "/b"}}}, true},
+		{"duplicate mount points", core.VMConfig{Disks: []core.Disk{{Name: "a", SizeGB: 20, MountPoint: "/data"}, {Name: "b", SizeGB: 20, MountPoint: "/data/"}}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDisks(tc.config)
+			if tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestVagrantDiskConfig(t *testing.T) {
+	if config := vagrantDiskConfig(core.VMConfig{}); config != "" {
+		t.Errorf("Expected no disk settings but got %q", config)
+	}
+
+	config := vagrantDiskConfig(core.VMConfig{
+		DiskSizeGB: 80,
+		Disks: []core.Disk{
+			{Name: "data", SizeGB: 20, MountPoint: "/data"},
+			{Name: "pg", SizeGB: 10, MountPoint: "/var/lib/postgresql/"},
+		},
+	})
+	expected := []string{
+		`config.vm.disk :disk, size: "80GB", primary: true`,
+		`config.vm.disk :disk, size: "20GB", name: "data"`,
+		`config.vm.disk :disk, size: "10GB", name: "pg"`,
+		`name: "grow-root", run: "always"`,
+		`growpart "$disk" "$part"`,
+		`mkfs.ext4 -q -L data "$dev"`,
+		`LABEL=pg /var/lib/postgresql ext4 defaults,nofail 0 2`,
+		`mountpoint -q /data || mount /data`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(config, fragment) {
+			t.Errorf("Expected disk settings to contain %q but got:\n%s", fragment, config)
+		}
+	}
+	if strings.Contains(config, "#{") {
+		t.Errorf("Expected no Ruby interpolation in the provisioning scripts but got:\n%s", config)
+	}
+}
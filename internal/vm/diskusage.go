@@ -0,0 +1,296 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// Cleanup actions, in the order cleanup_vm runs them
+const (
+	CleanupApt     = "apt"
+	CleanupJournal = "journal"
+	CleanupDocker  = "docker"
+	CleanupTmp     = "tmp"
+)
+
+// CleanupActions lists the cleanup actions
+var CleanupActions = []string{CleanupApt, CleanupJournal, CleanupDocker, CleanupTmp}
+
+// DefaultTmpAgeDays is the age in days above which CleanupTmp removes temporary files
+const DefaultTmpAgeDays = 2
+
+// journalVacuumSize is the size CleanupJournal shrinks the journal to
+const journalVacuumSize = "100M"
+
+// DefaultDiskUsageEntries is the number of largest directories a disk usage report lists
+const DefaultDiskUsageEntries = 15
+
+// Section markers of the disk usage and cleanup command output
+const (
+	sectionMarker = "## "
+	dfSection     = "df"
+	duSection     = "du"
+	cachesSection = "caches"
+	statusSection = "status"
+)
+
+// suggestedCacheKB is the cache size from which a disk usage report suggests its cleanup action
+const suggestedCacheKB = 50 * 1024
+
+// cacheDirs are the guest directories reported as caches, with the cleanup action that shrinks
+// them, if any
+var cacheDirs = []struct {
+	Path   string
+	Action string
+}{
+	{"/var/cache/apt", CleanupApt},
+	{"/var/log/journal", CleanupJournal},
+	{"/var/lib/docker", CleanupDocker},
+	{"/tmp", CleanupTmp},
+	{"/var/tmp", CleanupTmp},
+	{"/home/vagrant/.cache", ""},
+	{"/home/vagrant/.npm", ""},
+	{"/home/vagrant/go/pkg/mod", ""},
+	{"/root/.cache", ""},
+}
+
+// FilesystemUsage is the usage of a mounted filesystem, as reported by df
+type FilesystemUsage struct {
+	Filesystem  string `json:"filesystem"`
+	MountPoint  string `json:"mount_point"`
+	SizeKB      int64  `json:"size_kb"`
+	UsedKB      int64  `json:"used_kb"`
+	AvailableKB int64  `json:"available_kb"`
+	UsePercent  int    `json:"use_percent"`
+}
+
+// DirectoryUsage is the disk space used by a guest directory
+type DirectoryUsage struct {
+	Path   string `json:"path"`
+	SizeKB int64  `json:"size_kb"`
+	// Cleanup is the cleanup action that shrinks the directory, if any
+	Cleanup string `json:"cleanup,omitempty"`
+}
+
+// DiskUsageReport describes where the disk space of a VM goes
+type DiskUsageReport struct {
+	Filesystems []FilesystemUsage `json:"filesystems"`
+	Path        string            `json:"path"`
+	TotalKB     int64             `json:"total_kb"`
+	// Directories are the largest directories directly under Path, largest first
+	Directories []DirectoryUsage `json:"directories"`
+	Caches      []DirectoryUsage `json:"caches"`
+	// SuggestedCleanup lists the cleanup actions that would free significant space
+	SuggestedCleanup []string `json:"suggested_cleanup,omitempty"`
+}
+
+// CleanupResult is the outcome of a cleanup action
+type CleanupResult struct {
+	Action   string `json:"action"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
+
+// CleanupReport is the outcome of a VM cleanup
+type CleanupReport struct {
+	Results []CleanupResult `json:"results"`
+	// FreedKB is the space freed on the root filesystem
+	FreedKB     int64 `json:"freed_kb"`
+	AvailableKB int64 `json:"available_kb"`
+}
+
+// DiskUsageCommand returns the command that reports the filesystems of / and /vagrant, the
+// largest directories under dir, which stays on its filesystem, and the size of the caches
+func DiskUsageCommand(dir string, entries int) (string, error) {
+	if err := validateGuestFilePath(dir); err != nil && path.Clean(dir) != "/" {
+		return "", err
+	}
+	if entries <= 0 {
+		entries = DefaultDiskUsageEntries
+	}
+	caches := make([]string, 0, len(cacheDirs))
+	for _, cache := range cacheDirs {
+		caches = append(caches, cache.Path)
+	}
+	script := fmt.Sprintf(`echo '%[1]s%[2]s'; df -P -k / /vagrant 2>/dev/null | tail -n +2; `+
+		`echo '%[1]s%[3]s'; du -x -k -d 1 %[4]s 2>/dev/null | sort -rn | head -n %[5]d; `+
+		`echo '%[1]s%[6]s'; for d in %[7]s; do [ -d "$d" ] && du -s -k "$d" 2>/dev/null; done; true`,
+		sectionMarker, dfSection, duSection, shellquote.Quote(path.Clean(dir)), entries+1, cachesSection, strings.Join(caches, " "))
+	return guestShell(script, true), nil
+}
+
+// ParseDiskUsage parses the output of a DiskUsageCommand
+func ParseDiskUsage(dir, output string) DiskUsageReport {
+	dir = path.Clean(dir)
+	report := DiskUsageReport{Path: dir, Filesystems: []FilesystemUsage{}, Directories: []DirectoryUsage{}, Caches: []DirectoryUsage{}}
+	mounts := make(map[string]bool)
+	suggested := make(map[string]bool)
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, sectionMarker) {
+			section = strings.TrimPrefix(line, sectionMarker)
+			continue
+		}
+		fields := strings.Fields(line)
+		switch section {
+		case dfSection:
+			if usage, ok := parseDfLine(fields); ok && !mounts[usage.MountPoint] {
+				mounts[usage.MountPoint] = true
+				report.Filesystems = append(report.Filesystems, usage)
+			}
+		case duSection, cachesSection:
+			if len(fields) < 2 {
+				continue
+			}
+			size, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			usage := DirectoryUsage{Path: strings.Join(fields[1:], " "), SizeKB: size}
+			if section == duSection {
+				if usage.Path == dir {
+					report.TotalKB = size
+				} else {
+					report.Directories = append(report.Directories, usage)
+				}
+				continue
+			}
+			for _, cache := range cacheDirs {
+				if cache.Path == usage.Path {
+					usage.Cleanup = cache.Action
+				}
+			}
+			if usage.Cleanup != "" && usage.SizeKB >= suggestedCacheKB {
+				suggested[usage.Cleanup] = true
+			}
+			report.Caches = append(report.Caches, usage)
+		}
+	}
+	sort.SliceStable(report.Directories, func(i, j int) bool {
+		return report.Directories[i].SizeKB > report.Directories[j].SizeKB
+	})
+	for _, action := range CleanupActions {
+		if suggested[action] {
+			report.SuggestedCleanup = append(report.SuggestedCleanup, action)
+		}
+	}
+	return report
+}
+
+// parseDfLine parses a line of 'df -P -k' output
+func parseDfLine(fields []string) (FilesystemUsage, bool) {
+	if len(fields) < 6 {
+		return FilesystemUsage{}, false
+	}
+	size, err1 := strconv.ParseInt(fields[1], 10, 64)
+	used, err2 := strconv.ParseInt(fields[2], 10, 64)
+	available, err3 := strconv.ParseInt(fields[3], 10, 64)
+	percent, err4 := strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return FilesystemUsage{}, false
+	}
+	return FilesystemUsage{
+		Filesystem:  fields[0],
+		MountPoint:  strings.Join(fields[5:], " "),
+		SizeKB:      size,
+		UsedKB:      used,
+		AvailableKB: available,
+		UsePercent:  percent,
+	}, true
+}
+
+// cleanupCommand returns the guest command of a cleanup action, or "" for unknown actions
+func cleanupCommand(action string, tmpAgeDays int) string {
+	switch action {
+	case CleanupApt:
+		return "apt-get clean"
+	case CleanupJournal:
+		return "journalctl --vacuum-size=" + journalVacuumSize
+	case CleanupDocker:
+		// Stopped containers, unused networks, dangling images and build cache; tagged images stay
+		return "if command -v docker >/dev/null; then docker system prune -f; else echo 'docker is not installed'; fi"
+	case CleanupTmp:
+		return fmt.Sprintf("find /tmp /var/tmp -xdev -mindepth 1 -type f -mtime +%d -delete -print | wc -l | sed 's/$/ files removed/'", tmpAgeDays-1)
+	}
+	return ""
+}
+
+// CleanupCommand returns the command that runs cleanup actions as root, reporting the available
+// space on / before and after them and the exit code and output of each action
+func CleanupCommand(actions []string, tmpAgeDays int) (string, error) {
+	if len(actions) == 0 {
+		actions = CleanupActions
+	}
+	if tmpAgeDays <= 0 {
+		tmpAgeDays = DefaultTmpAgeDays
+	}
+	requested := make(map[string]bool)
+	for _, action := range actions {
+		if cleanupCommand(action, tmpAgeDays) == "" {
+			return "", errors.InvalidInput(fmt.Sprintf("invalid cleanup action '%s': use %s", action, strings.Join(CleanupActions, ", ")))
+		}
+		requested[action] = true
+	}
+	df := fmt.Sprintf("echo '%s%s'; df -P -k / | tail -n 1; ", sectionMarker, dfSection)
+	var script strings.Builder
+	script.WriteString(df)
+	for _, action := range CleanupActions {
+		if requested[action] {
+			fmt.Fprintf(&script, "echo '%s%s'; (%s) 2>&1; echo \"%s%s $?\"; ", sectionMarker, action, cleanupCommand(action, tmpAgeDays), sectionMarker, statusSection)
+		}
+	}
+	script.WriteString(df)
+	return guestShell(strings.TrimSuffix(script.String(), " "), true), nil
+}
+
+// ParseCleanup parses the output of a CleanupCommand
+func ParseCleanup(output string) CleanupReport {
+	report := CleanupReport{Results: []CleanupResult{}}
+	var available []int64
+	var current *CleanupResult
+	var lines []string
+	section := ""
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if strings.HasPrefix(line, sectionMarker) {
+			name, value, _ := strings.Cut(strings.TrimPrefix(line, sectionMarker), " ")
+			switch name {
+			case statusSection:
+				if current != nil {
+					current.ExitCode, _ = strconv.Atoi(value)
+					current.Output = strings.TrimSpace(strings.Join(lines, "\n"))
+					report.Results = append(report.Results, *current)
+					current = nil
+				}
+			case dfSection:
+			default:
+				current = &CleanupResult{Action: name}
+				lines = nil
+			}
+			section = name
+			continue
+		}
+		if section == dfSection {
+			if usage, ok := parseDfLine(strings.Fields(line)); ok {
+				available = append(available, usage.AvailableKB)
+			}
+			continue
+		}
+		if current != nil {
+			lines = append(lines, line)
+		}
+	}
+	if len(available) == 2 {
+		report.AvailableKB = available[1]
+		report.FreedKB = max(available[1]-available[0], 0)
+	}
+	return report
+}
@@ -0,0 +1,137 @@
+package vm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiskUsage(t *testing.T) {
+	output := strings.Join([]string{
+		"## df",
+		"/dev/sda1 41152736 30412312 8627080 78% /",
+		"/dev/sda1 41152736 30412312 8627080 78% /",
+		"vagrant 487260152 301234567 186025585 62% /vagrant",
+		"## du",
+		"30412312 /",
+		"1024 /etc",
+		"20971520 /var",
+		"8388608 /home",
+		"## caches",
+		"204800 /var/cache/apt",
+		"40960 /var/log/journal",
+		"1048576 /var/lib/docker",
+		"512000 /home/vagrant/.cache",
+	}, "\n") + "\n"
+
+	report := ParseDiskUsage("/", output)
+	if len(report.Filesystems) != 2 {
+		t.Fatalf("Expected 2 filesystems but got %d", len(report.Filesystems))
+	}
+	if fs := report.Filesystems[1]; fs.MountPoint != "/vagrant" || fs.UsePercent != 62 || fs.AvailableKB != 186025585 {
+		t.Errorf("Expected /vagrant at 62%% with 186025585KB available but got %+v", fs)
+	}
+	if report.TotalKB != 30412312 {
+		t.Errorf("Expected total 30412312 but got %d", report.TotalKB)
+	}
+	var dirs []string
+	for _, dir := range report.Directories {
+		dirs = append(dirs, dir.Path)
+	}
+	if expected := []string{"/var", "/home", "/etc"}; !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("Expected directories %v but got %v", expected, dirs)
+	}
+	if len(report.Caches) != 4 || report.Caches[0].Cleanup != CleanupApt || report.Caches[3].Cleanup != "" {
+		t.Errorf("Expected caches with their cleanup actions but got %+v", report.Caches)
+	}
+	// The journal is below the suggestion threshold and the user cache has no cleanup action
+	if expected := []string{CleanupApt, CleanupDocker}; !reflect.DeepEqual(report.SuggestedCleanup, expected) {
+		t.Errorf("Expected suggested cleanup %v but got %v", expected, report.SuggestedCleanup)
+	}
+}
+
+func TestDiskUsageCommand(t *testing.T) {
+	testCases := []struct {
+		dir       string
+		expected  string
+		expectErr bool
+	}{
+		{"/", "du -x -k -d 1 '/'", false},
+		{"/var/lib/", "du -x -k -d 1 '/var/lib'", false},
+		{"var", "", true},
+	}
+	for _, tc := range testCases {
+		command, err := DiskUsageCommand(tc.dir, 0)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("Expected an error for %q", tc.dir)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error for %q but got %v", tc.dir, err)
+		}
+		if !strings.HasPrefix(command, "sudo -n sh -c ") || !strings.Contains(command, strings.ReplaceAll(tc.expected, "'", `'\''`)) {
+			t.Errorf("Expected %q in the command but got %s", tc.expected, command)
+		}
+	}
+}
+
+func TestCleanupCommand(t *testing.T) {
+	command, err := CleanupCommand([]string{CleanupTmp, CleanupApt}, 0)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	apt, tmp := strings.Index(command, "apt-get clean"), strings.Index(command, "-mtime +1")
+	if apt < 0 || tmp < 0 || apt > tmp {
+		t.Errorf("Expected apt before tmp with the default age but got %s", command)
+	}
+	if strings.Contains(command, "journalctl") || strings.Contains(command, "docker") {
+		t.Errorf("Expected only the requested actions but got %s", command)
+	}
+
+	all, err := CleanupCommand(nil, 7)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	for _, expected := range []string{"apt-get clean", "journalctl --vacuum-size=100M", "docker system prune -f", "-mtime +6"} {
+		if !strings.Contains(all, expected) {
+			t.Errorf("Expected %q in the command but got %s", expected, all)
+		}
+	}
+
+	if _, err := CleanupCommand([]string{"apt", "snap"}, 0); err == nil {
+		t.Error("Expected an error for an invalid action")
+	}
+}
+
+func TestParseCleanup(t *testing.T) {
+	output := strings.Join([]string{
+		"## df",
+		"/dev/sda1 41152736 30412312 8627080 78% /",
+		"## apt",
+		"## status 0",
+		"## docker",
+		"Total reclaimed space: 1.2GB",
+		"## status 0",
+		"## tmp",
+		"find: '/tmp/x': Permission denied",
+		"3 files removed",
+		"## status 1",
+		"## df",
+		"/dev/sda1 41152736 29000000 10039392 71% /",
+	}, "\n") + "\n"
+
+	report := ParseCleanup(output)
+	expected := []CleanupResult{
+		{Action: CleanupApt, ExitCode: 0, Output: ""},
+		{Action: CleanupDocker, ExitCode: 0, Output: "Total reclaimed space: 1.2GB"},
+		{Action: CleanupTmp, ExitCode: 1, Output: "find: '/tmp/x': Permission denied\n3 files removed"},
+	}
+	if !reflect.DeepEqual(report.Results, expected) {
+		t.Errorf("Expected results %+v but got %+v", expected, report.Results)
+	}
+	if report.FreedKB != 1412312 || report.AvailableKB != 10039392 {
+		t.Errorf("Expected 1412312KB freed and 10039392KB available but got %d and %d", report.FreedKB, report.AvailableKB)
+	}
+}
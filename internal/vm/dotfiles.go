@@ -0,0 +1,209 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// dotfilesProvisioner names the provisioner that clones the dotfiles repository
+const dotfilesProvisioner = "dotfiles"
+
+// guestHome is the home directory of the vagrant user in the guest
+const guestHome = "/home/vagrant"
+
+// DefaultDotfileAllowlist lists the host dotfiles that can be copied to a guest when
+// MCP_DOTFILES_ALLOWLIST is not set. Files that usually hold credentials, such as SSH keys,
+// .netrc or .npmrc, are left out.
+var DefaultDotfileAllowlist = []string{
+	".bash_aliases",
+	".editorconfig",
+	".gitconfig",
+	".gitignore_global",
+	".inputrc",
+	".ssh/known_hosts",
+	".tmux.conf",
+	".vimrc",
+}
+
+var (
+	// dotfilesRepoPattern matches the git URLs a dotfiles repository can be cloned from
+	dotfilesRepoPattern = regexp.MustCompile(`^(https://|ssh://|git@)[A-Za-z0-9._~:/?=&%@+-]+$`)
+	// dotfilesRefPattern matches branch, tag and commit names
+	dotfilesRefPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+)
+
+// DotfileAllowlist returns the host dotfiles that can be copied to a guest: the comma-separated
+// paths in MCP_DOTFILES_ALLOWLIST, relative to the home directory, or DefaultDotfileAllowlist
+func DotfileAllowlist() []string {
+	value := os.Getenv("MCP_DOTFILES_ALLOWLIST")
+	if value == "" {
+		return DefaultDotfileAllowlist
+	}
+	var allowlist []string
+	for _, file := range strings.Split(value, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			allowlist = append(allowlist, path.Clean(filepath.ToSlash(file)))
+		}
+	}
+	return allowlist
+}
+
+// ValidateDotfiles checks the dotfiles settings of a VM configuration: the repository URL and
+// ref, and that every file is allowlisted and exists in the host home directory homeDir
+func ValidateDotfiles(config core.VMConfig, homeDir string) error {
+	dotfiles := config.Dotfiles
+	if dotfiles == nil {
+		return nil
+	}
+	if dotfiles.Repo == "" && (dotfiles.Ref != "" || dotfiles.Install != "") {
+		return errors.InvalidInput("a dotfiles ref or install script needs a dotfiles repository")
+	}
+	if dotfiles.Repo != "" && !dotfilesRepoPattern.MatchString(dotfiles.Repo) {
+		return errors.InvalidInput(fmt.Sprintf("invalid dotfiles repository '%s': use an https://, ssh:// or git@ URL", dotfiles.Repo))
+	}
+	if dotfiles.Ref != "" && !dotfilesRefPattern.MatchString(dotfiles.Ref) {
+		return errors.InvalidInput(fmt.Sprintf("invalid dotfiles ref '%s'", dotfiles.Ref))
+	}
+	if dotfiles.Install != "" && (!dotfilesRefPattern.MatchString(dotfiles.Install) || path.IsAbs(dotfiles.Install) || strings.Contains(dotfiles.Install, "..")) {
+		return errors.InvalidInput(fmt.Sprintf("invalid dotfiles install script '%s': use a path inside the repository", dotfiles.Install))
+	}
+	allowed := make(map[string]bool)
+	for _, file := range DotfileAllowlist() {
+		allowed[file] = true
+	}
+	for _, file := range dotfiles.Files {
+		if !allowed[file] {
+			return errors.InvalidInput(fmt.Sprintf("dotfile '%s' is not in the allowlist (%s); set MCP_DOTFILES_ALLOWLIST to change it", file, strings.Join(DotfileAllowlist(), ", ")))
+		}
+		if info, err := os.Stat(filepath.Join(homeDir, filepath.FromSlash(file))); err != nil || !info.Mode().IsRegular() {
+			return errors.InvalidInput(fmt.Sprintf("dotfile '%s' does not exist in %s", file, homeDir))
+		}
+	}
+	return nil
+}
+
+// dotfilesRepoScript returns the script, run as the vagrant user, that clones the dotfiles
+// repository to ~/.dotfiles, or updates an earlier clone, and runs its install script
+func dotfilesRepoScript(dotfiles core.Dotfiles) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	b.WriteString("command -v git >/dev/null || sudo DEBIAN_FRONTEND=noninteractive apt-get install -y git >/dev/null\n")
+	b.WriteString("dir=\"$HOME/.dotfiles\"\n")
+	fmt.Fprintf(&b, "repo=%s\nref=%s\ninstall=%s\n", shellquote.Quote(dotfiles.Repo), shellquote.Quote(dotfiles.Ref), shellquote.Quote(dotfiles.Install))
+	b.WriteString(`if [ -d "$dir/.git" ]; then
+  git -C "$dir" remote set-url origin "$repo"
+  git -C "$dir" fetch --quiet origin
+else
+  git clone --quiet "$repo" "$dir"
+fi
+if [ -n "$ref" ]; then git -C "$dir" checkout --quiet "$ref"; fi
+if git -C "$dir" symbolic-ref -q HEAD >/dev/null; then git -C "$dir" merge --quiet --ff-only "@{upstream}"; fi
+if [ -n "$install" ]; then cd "$dir" && bash "./$install"; fi`)
+	return b.String()
+}
+
+// vagrantDotfilesConfig returns the provisioners that copy the host dotfiles of a VM from
+// homeDir and clone its dotfiles repository on the first boot, or an empty string when it has
+// none. Files missing from the host are skipped.
+func vagrantDotfilesConfig(config core.VMConfig, homeDir string) string {
+	dotfiles := config.Dotfiles
+	if dotfiles == nil || (dotfiles.Repo == "" && len(dotfiles.Files) == 0) {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n  # Dotfiles\n")
+	for _, file := range dotfiles.Files {
+		source := filepath.Join(homeDir, filepath.FromSlash(file))
+		if _, err := os.Stat(source); err != nil || homeDir == "" {
+			log.Warn().Str("vm", config.Name).Str("file", file).Msg("Skipping dotfile missing on the host")
+			continue
+		}
+		fmt.Fprintf(&b, "  config.vm.provision \"file\", name: %s, source: %s, destination: %s\n",
+			strconv.Quote("dotfile "+file), strconv.Quote(source), strconv.Quote(path.Join(guestHome, file)))
+	}
+	if dotfiles.Repo != "" {
+		fmt.Fprintf(&b, "  config.vm.provision \"shell\", name: \"%s\", privileged: false, inline: <<-SHELL\n", dotfilesProvisioner)
+		writeIndented(&b, dotfilesRepoScript(*dotfiles))
+		b.WriteString("  SHELL\n")
+	}
+	return b.String()
+}
+
+// SetDotfiles replaces the dotfiles of a VM, or removes them when dotfiles is nil. The
+// Vagrantfile is regenerated so a recreated VM gets them on its first boot, and a running VM
+// gets the files and repository right away. Removing dotfiles leaves the guest's copies.
+func (m *Manager) SetDotfiles(ctx context.Context, name string, dotfiles *core.Dotfiles) (core.VMConfig, error) {
+	release, err := m.operations.acquire(name, OperationReconfigure)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	defer release()
+	config, err := m.GetVMConfig(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	if _, err := loadAdoption(filepath.Join(m.baseDir, name)); err == nil {
+		return core.VMConfig{}, errors.InvalidInput("adopted VMs keep their own Vagrantfile; change it there and reload the VM")
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return core.VMConfig{}, errors.OperationFailed("find home directory", err)
+	}
+	config.Dotfiles = dotfiles
+	if err := ValidateDotfiles(config, homeDir); err != nil {
+		return core.VMConfig{}, err
+	}
+	state, err := m.GetVMState(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	if err := m.saveVMConfig(name, &config); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("save VM configuration", err)
+	}
+	if err := m.generateVagrantfile(name, config); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("generate Vagrantfile", err)
+	}
+	if state == core.Running && dotfiles != nil {
+		if err := m.applyDotfiles(ctx, name, *dotfiles, homeDir); err != nil {
+			return core.VMConfig{}, err
+		}
+	}
+	log.Info().Str("name", name).Bool("repo", dotfiles != nil && dotfiles.Repo != "").Msg("VM dotfiles updated")
+	return config, nil
+}
+
+// applyDotfiles copies the host dotfiles to a running VM and clones its dotfiles repository
+func (m *Manager) applyDotfiles(ctx context.Context, name string, dotfiles core.Dotfiles, homeDir string) error {
+	vmDir := m.getVMDir(name)
+	for _, file := range dotfiles.Files {
+		source := filepath.Join(homeDir, filepath.FromSlash(file))
+		args := m.vagrantArgs(name, "upload", source, path.Join(guestHome, file))
+		if output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vmDir, args...); err != nil {
+			return errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to copy dotfile %s: %s", file, output))
+		}
+	}
+	if dotfiles.Repo != "" {
+		// base64 keeps the script intact through vagrant ssh's shell
+		script := base64.StdEncoding.EncodeToString([]byte(dotfilesRepoScript(dotfiles)))
+		if output, err := m.runGuestCommand(ctx, name, fmt.Sprintf("echo %s | base64 -d | bash", script)); err != nil {
+			return errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to set up the dotfiles repository: %s", output))
+		}
+	}
+	return nil
+}
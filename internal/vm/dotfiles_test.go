@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestValidateDotfiles(t *testing.T) {
+	homeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(homeDir, ".gitconfig"), []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(homeDir, ".vimrc"), 0755); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		dotfiles  *core.Dotfiles
+		expectErr bool
+	}{
+		{"none", nil, false},
+		{"https repository", &core.Dotfiles{Repo: "https://github.com/me/dotfiles.git", Ref: "main", Install: "install.sh"}, false},
+		{"ssh repository", &core.Dotfiles{Repo: "git@github.com:me/dotfiles.git"}, false},
+		{"allowlisted file", &core.Dotfiles{Files: []string{".gitconfig"}}, false},
+		{"local repository", &core.Dotfiles{Repo: "/home/me/dotfiles"}, true},
+		{"repository with shell characters", &core.Dotfiles{Repo: "https://example.com/$(id)"}, true},
+		{"ref without repository", &core.Dotfiles{Ref: "main"}, true},
+		{"invalid ref", &core.Dotfiles{Repo: "https://example.com/d.git", Ref: "main;id"}, true},
+		{"install script outside repository", &core.Dotfiles{Repo: "https://example.com/d.git", Install: "../install.sh"}, true},
+		{"file not allowlisted", &core.Dotfiles{Files: []string{".ssh/id_rsa"}}, true},
+		{"file missing", &core.Dotfiles{Files: []string{".tmux.conf"}}, true},
+		{"file is a directory", &core.Dotfiles{Files: []string{".vimrc"}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateDotfiles(core.VMConfig{Dotfiles: tc.dotfiles}, homeDir); tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestDotfileAllowlist(t *testing.T) {
+	t.Setenv("MCP_DOTFILES_ALLOWLIST", "")
+	if allowlist := DotfileAllowlist(); !reflect.DeepEqual(allowlist, DefaultDotfileAllowlist) {
+		t.Errorf("Expected the default allowlist but got %v", allowlist)
+	}
+
+	t.Setenv("MCP_DOTFILES_ALLOWLIST", " .gitconfig, ,.config/starship.toml ")
+	expected := []string{".gitconfig", ".config/starship.toml"}
+	if allowlist := DotfileAllowlist(); !reflect.DeepEqual(allowlist, expected) {
+		t.Errorf("Expected allowlist %v but got %v", expected, allowlist)
+	}
+}
+
+func TestVagrantDotfilesConfig(t *testing.T) {
+	homeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(homeDir, ".gitconfig"), []byte("[user]\n"), 0644); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	if config := vagrantDotfilesConfig(core.VMConfig{}, homeDir); config != "" {
+		t.Errorf("Expected no dotfiles configuration but got %q", config)
+	}
+
+	config := vagrantDotfilesConfig(core.VMConfig{Dotfiles: &core.Dotfiles{
+		Repo:  "https://github.com/me/dotfiles.git",
+		Files: []string{".gitconfig", ".vimrc"},
+	}}, homeDir)
+	for _, expected := range []string{
+		`name: "dotfile .gitconfig"`,
+		`destination: "/home/vagrant/.gitconfig"`,
+		`name: "dotfiles", privileged: false`,
+		`repo='https://github.com/me/dotfiles.git'`,
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("Expected configuration to contain %q but got %q", expected, config)
+		}
+	}
+	if strings.Contains(config, ".vimrc") {
+		t.Errorf("Expected the missing .vimrc to be skipped but got %q", config)
+	}
+}
+
+func TestDotfilesRepoScript(t *testing.T) {
+	script := dotfilesRepoScript(core.Dotfiles{Repo: "https://example.com/d.git", Ref: "v1.0", Install: "install.sh"})
+	for _, expected := range []string{"repo='https://example.com/d.git'", "ref='v1.0'", "install='install.sh'", `git clone --quiet "$repo" "$dir"`} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected script to contain %q but got %q", expected, script)
+		}
+	}
+}
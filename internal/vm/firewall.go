@@ -0,0 +1,185 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// firewallProvisioner names the provisioner that applies the firewall rules
+const firewallProvisioner = "firewall"
+
+// guestSSHPort is always open so Vagrant can connect
+const guestSSHPort = 22
+
+// firewallUFWScript applies the rules with ufw, installing it when missing, and falls back to
+// plain iptables rules when ufw is unavailable. The rules are rebuilt from scratch each time.
+const firewallUFWScript = `ports="%[1]s"
+subnet="%[2]s"
+if command -v ufw >/dev/null || DEBIAN_FRONTEND=noninteractive apt-get install -y ufw >/dev/null 2>&1; then
+  ufw --force reset >/dev/null
+  ufw default deny incoming
+  ufw default allow outgoing
+  for port in $ports; do ufw allow "$port/tcp"; done
+  if [ -n "$subnet" ]; then ufw allow from "$subnet"; fi
+  ufw --force enable
+else
+  iptables -P INPUT ACCEPT
+  iptables -F INPUT
+  iptables -A INPUT -i lo -j ACCEPT
+  iptables -A INPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+  iptables -A INPUT -p icmp -j ACCEPT
+  iptables -A INPUT -p udp --sport 67 --dport 68 -j ACCEPT
+  for port in $ports; do iptables -A INPUT -p tcp --dport "$port" -j ACCEPT; done
+  if [ -n "$subnet" ]; then iptables -A INPUT -s "$subnet" -j ACCEPT; fi
+  iptables -P INPUT DROP
+fi`
+
+// firewallDisableScript removes the rules applied by firewallUFWScript
+const firewallDisableScript = `if command -v ufw >/dev/null; then
+  ufw --force reset >/dev/null
+  ufw disable
+fi
+if command -v iptables >/dev/null; then
+  iptables -P INPUT ACCEPT
+  iptables -F INPUT
+fi`
+
+// ValidateFirewall checks the firewall settings of a VM configuration
+func ValidateFirewall(config core.VMConfig) error {
+	if config.Firewall == nil {
+		return nil
+	}
+	for _, port := range config.Firewall.AllowPorts {
+		if port < 1 || port > 65535 {
+			return errors.InvalidInput(fmt.Sprintf("invalid firewall port %d: must be between 1 and 65535", port))
+		}
+	}
+	return nil
+}
+
+// FirewallPorts returns the guest TCP ports the firewall of a VM leaves open: SSH, the
+// forwarded guest ports and the extra allowed ports, sorted
+func FirewallPorts(config core.VMConfig) []int {
+	seen := map[int]bool{guestSSHPort: true}
+	ports := []int{guestSSHPort}
+	add := func(port int) {
+		if port > 0 && !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	for _, port := range config.Ports {
+		add(port.Guest)
+	}
+	if config.Firewall != nil {
+		for _, port := range config.Firewall.AllowPorts {
+			add(port)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// firewallScript returns the script that applies the firewall of a VM, or removes it when the
+// VM has none. Members of the VM's shared private network can reach every port.
+func firewallScript(config core.VMConfig, subnet string) string {
+	if config.Firewall == nil {
+		return firewallDisableScript
+	}
+	ports := make([]string, 0, len(config.Ports)+1)
+	for _, port := range FirewallPorts(config) {
+		ports = append(ports, strconv.Itoa(port))
+	}
+	return fmt.Sprintf(firewallUFWScript, strings.Join(ports, " "), subnet)
+}
+
+// vagrantFirewallConfig returns the provisioner that applies the firewall of a VM, or an empty
+// string when it has none
+func (m *Manager) vagrantFirewallConfig(config core.VMConfig) (string, error) {
+	if config.Firewall == nil {
+		return "", nil
+	}
+	subnet, err := m.firewallSubnet(config)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("\n  # Firewall settings\n")
+	fmt.Fprintf(&b, "  config.vm.provision \"shell\", name: \"%s\", run: \"always\", inline: <<-SHELL\n", firewallProvisioner)
+	writeIndented(&b, firewallScript(config, subnet))
+	b.WriteString("  SHELL\n")
+	return b.String(), nil
+}
+
+// firewallSubnet returns the subnet of the VM's shared private network, if it is on one
+func (m *Manager) firewallSubnet(config core.VMConfig) (string, error) {
+	if config.Network == "" {
+		return "", nil
+	}
+	network, err := m.GetNetwork(config.Network)
+	if err != nil {
+		return "", err
+	}
+	return network.Subnet, nil
+}
+
+// SetFirewall enables the firewall of a VM with the given settings, or disables it when
+// firewall is nil. The Vagrantfile is regenerated so the rules survive restarts, and the rules
+// of a running VM are changed in place without a reload.
+func (m *Manager) SetFirewall(ctx context.Context, name string, firewall *core.Firewall) (core.VMConfig, error) {
+	release, err := m.operations.acquire(name, OperationReconfigure)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	defer release()
+	config, err := m.GetVMConfig(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	if _, err := loadAdoption(filepath.Join(m.baseDir, name)); err == nil {
+		return core.VMConfig{}, errors.InvalidInput("adopted VMs keep their own Vagrantfile; change it there and reload the VM")
+	}
+	state, err := m.GetVMState(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	// The guest keeps ufw rules across reboots, so they can only be removed from a running VM
+	if firewall == nil && config.Firewall != nil && state != core.Running && state != core.NotCreated {
+		return core.VMConfig{}, errors.InvalidInput("the guest keeps its firewall rules while stopped; start the VM to disable its firewall")
+	}
+	config.Firewall = firewall
+	if err := ValidateFirewall(config); err != nil {
+		return core.VMConfig{}, err
+	}
+	subnet, err := m.firewallSubnet(config)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	if err := m.saveVMConfig(name, &config); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("save VM configuration", err)
+	}
+	if err := m.generateVagrantfile(name, config); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("generate Vagrantfile", err)
+	}
+	if state == core.Running {
+		// base64 keeps the script intact through vagrant ssh's shell
+		script := base64.StdEncoding.EncodeToString([]byte(firewallScript(config, subnet)))
+		if output, err := m.runGuestCommand(ctx, name, fmt.Sprintf("echo %s | base64 -d | sudo -n bash", script)); err != nil {
+			return core.VMConfig{}, errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to apply firewall rules: %s", output))
+		}
+	}
+	log.Info().Str("name", name).Bool("enabled", firewall != nil).Ints("ports", FirewallPorts(config)).Msg("VM firewall updated")
+	return config, nil
+}
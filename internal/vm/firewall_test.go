@@ -0,0 +1,75 @@
+package vm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestValidateFirewall(t *testing.T) {
+	testCases := []struct {
+		name      string
+		firewall  *core.Firewall
+		expectErr bool
+	}{
+		{"none", nil, false},
+		{"no extra ports", &core.Firewall{}, false},
+		{"valid ports", &core.Firewall{AllowPorts: []int{80, 443}}, false},
+		{"zero port", &core.Firewall{AllowPorts: []int{0}}, true},
+		{"port too large", &core.Firewall{AllowPorts: []int{70000}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateFirewall(core.VMConfig{Firewall: tc.firewall}); tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestFirewallPorts(t *testing.T) {
+	config := core.VMConfig{
+		Ports:    []core.Port{{Guest: 3000, Host: 3000}, {Guest: 5432, Host: 15432}, {Guest: 22, Host: 2200}},
+		Firewall: &core.Firewall{AllowPorts: []int{8080, 3000}},
+	}
+	expected := []int{22, 3000, 5432, 8080}
+	if ports := FirewallPorts(config); !reflect.DeepEqual(ports, expected) {
+		t.Errorf("Expected ports %v but got %v", expected, ports)
+	}
+}
+
+func TestFirewallScript(t *testing.T) {
+	config := core.VMConfig{
+		Ports:    []core.Port{{Guest: 3000, Host: 3000}},
+		Firewall: &core.Firewall{},
+	}
+	script := firewallScript(config, "192.168.57.0/24")
+	for _, expected := range []string{`ports="22 3000"`, `subnet="192.168.57.0/24"`, "ufw default deny incoming", "iptables -P INPUT DROP"} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected script to contain %q but got %q", expected, script)
+		}
+	}
+
+	config.Firewall = nil
+	if script := firewallScript(config, ""); script != firewallDisableScript {
+		t.Errorf("Expected the disable script but got %q", script)
+	}
+}
+
+func TestVagrantFirewallConfig(t *testing.T) {
+	m := &Manager{baseDir: t.TempDir()}
+	config, err := m.vagrantFirewallConfig(core.VMConfig{})
+	if err != nil || config != "" {
+		t.Errorf("Expected no firewall settings but got %q, %v", config, err)
+	}
+	config, err = m.vagrantFirewallConfig(core.VMConfig{Firewall: &core.Firewall{AllowPorts: []int{9000}}})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !strings.Contains(config, `name: "firewall", run: "always"`) || !strings.Contains(config, `ports="22 9000"`) {
+		t.Errorf("Expected the firewall provisioner but got %q", config)
+	}
+}
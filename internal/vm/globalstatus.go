@@ -0,0 +1,253 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Vagrant environment ownership, relative to the VMs this server manages
+const (
+	EnvironmentManaged   = "managed"
+	EnvironmentUnmanaged = "unmanaged"
+	EnvironmentOrphaned  = "orphaned"
+)
+
+// VagrantEnvironment is a machine known to Vagrant on this host, as listed by 'vagrant global-status'
+type VagrantEnvironment struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`
+	State     string `json:"state"`
+	Directory string `json:"directory"`
+	Ownership string `json:"ownership"`
+	VMName    string `json:"vm_name,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ListVagrantEnvironments lists every machine Vagrant knows about on this host and
+// reconciles it with the VMs under the base directory
+func (m *Manager) ListVagrantEnvironments(ctx context.Context) ([]VagrantEnvironment, error) {
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), "", "global-status", machineReadableFlag)
+	if err != nil {
+		return nil, errors.OperationFailed("get global Vagrant status", fmt.Errorf("%w: %s", err, output))
+	}
+	return reconcileEnvironments(ParseGlobalStatus(string(output)), m.baseDir), nil
+}
+
+// Ways an environment was removed
+const (
+	RemovedByVagrant  = "vagrant_destroy"
+	RemovedByProvider = "provider"
+)
+
+// EnvironmentRemoval reports what removing a Vagrant environment took away
+type EnvironmentRemoval struct {
+	ID string `json:"id"`
+	// Method is how the machine was removed: with 'vagrant destroy', or through the provider
+	// when the environment directory is gone and Vagrant cannot load it
+	Method string `json:"method"`
+	// ProviderMachines are the provider machines deleted, e.g. VirtualBox VMs or libvirt domains
+	ProviderMachines []string `json:"provider_machines,omitempty"`
+	// Pruned reports whether the entry was pruned from Vagrant's machine index
+	Pruned bool   `json:"pruned"`
+	Note   string `json:"note,omitempty"`
+}
+
+// DestroyEnvironment force-destroys a machine found by 'vagrant global-status', for machines
+// whose directory is gone or is no longer managed. Vagrant cannot destroy a machine whose
+// directory is gone, so its provider machine is deleted through the provider instead and the
+// stale entry is pruned from Vagrant's machine index.
+func (m *Manager) DestroyEnvironment(ctx context.Context, environment VagrantEnvironment) (EnvironmentRemoval, error) {
+	removal := EnvironmentRemoval{ID: environment.ID, Method: RemovedByVagrant}
+	if dirExists(environment.Directory) {
+		output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), "", "destroy", environment.ID, "--force")
+		if err != nil {
+			return removal, errors.OperationFailed("destroy Vagrant environment", fmt.Errorf("%w: %s", err, output))
+		}
+		log.Info().Str("id", environment.ID).Msg("Vagrant environment destroyed")
+		return removal, nil
+	}
+
+	if environment.Provider != ProviderVirtualBox && environment.Provider != ProviderLibvirt {
+		return removal, errors.New(errors.CodeNotImplemented, fmt.Sprintf("the directory of environment %s is gone and machines of the %s provider cannot be deleted without it; remove the machine with the provider's tools and run 'vagrant global-status --prune'", environment.ID, environment.Provider))
+	}
+	removal.Method = RemovedByProvider
+	machines, err := m.deleteProviderMachines(ctx, environment)
+	removal.ProviderMachines = machines
+	if err != nil {
+		return removal, errors.OperationFailed("delete provider machine", err)
+	}
+	if len(machines) == 0 {
+		removal.Note = "no provider machine was found for the environment; only its machine index entry was pruned"
+	}
+
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), "", "global-status", "--prune")
+	if err != nil {
+		return removal, errors.OperationFailed("prune Vagrant machine index", fmt.Errorf("%w: %s", err, output))
+	}
+	removal.Pruned = true
+	log.Info().Str("id", environment.ID).Strs("provider_machines", machines).Msg("Orphaned Vagrant environment removed")
+	return removal, nil
+}
+
+// deleteProviderMachines deletes the provider machines of an environment whose directory is
+// gone, returning the names of the machines deleted. VirtualBox VMs of this server's VMs are
+// named after the VM; others get Vagrant's default name, the directory name and machine name
+// followed by a timestamp. libvirt domains are named after the directory and the machine.
+func (m *Manager) deleteProviderMachines(ctx context.Context, environment VagrantEnvironment) ([]string, error) {
+	dirName := filepath.Base(environment.Directory)
+	var deleted []string
+	switch environment.Provider {
+	case ProviderVirtualBox:
+		output, err := m.hostCommand(ctx, "VBoxManage", "list", "vms")
+		if err != nil {
+			return nil, fmt.Errorf("VBoxManage list vms failed: %w: %s", err, output)
+		}
+		defaultPrefix := dirName + "_" + environment.Name + "_"
+		for name, uuid := range parseVirtualBoxVMs(output) {
+			if !strings.HasPrefix(name, defaultPrefix) && (name != dirName || !isWithin(m.baseDir, environment.Directory)) {
+				continue
+			}
+			// Powering off fails for machines that are not running
+			_, _ = m.hostCommand(ctx, "VBoxManage", "controlvm", uuid, "poweroff")
+			if output, err := m.hostCommand(ctx, "VBoxManage", "unregistervm", uuid, "--delete"); err != nil {
+				return deleted, fmt.Errorf("VBoxManage unregistervm %s failed: %w: %s", name, err, output)
+			}
+			deleted = append(deleted, name)
+		}
+	case ProviderLibvirt:
+		domain := dirName + "_" + environment.Name
+		output, err := m.hostCommand(ctx, "virsh", "-c", "qemu:///system", "list", "--all", "--name")
+		if err != nil {
+			return nil, fmt.Errorf("virsh list failed: %w: %s", err, output)
+		}
+		for _, name := range strings.Fields(output) {
+			if name != domain {
+				continue
+			}
+			// Destroying fails for domains that are not running
+			_, _ = m.hostCommand(ctx, "virsh", "-c", "qemu:///system", "destroy", domain)
+			if output, err := m.hostCommand(ctx, "virsh", "-c", "qemu:///system", "undefine", domain, "--remove-all-storage"); err != nil {
+				return deleted, fmt.Errorf("virsh undefine %s failed: %w: %s", domain, err, output)
+			}
+			deleted = append(deleted, domain)
+		}
+	}
+	sort.Strings(deleted)
+	return deleted, nil
+}
+
+// hostCommand runs a host command other than vagrant and returns its combined output
+func (m *Manager) hostCommand(ctx context.Context, name string, args ...string) (string, error) {
+	if m.hostRunner != nil {
+		return m.hostRunner(ctx, name, args...)
+	}
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	return string(output), err
+}
+
+// parseVirtualBoxVMs parses the output of 'VBoxManage list vms' into a map of VM names to UUIDs
+func parseVirtualBoxVMs(output string) map[string]string {
+	vms := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		open := strings.LastIndex(line, "{")
+		if !strings.HasPrefix(line, `"`) || open < 0 || !strings.HasSuffix(line, "}") {
+			continue
+		}
+		name := strings.TrimSpace(line[:open])
+		vms[strings.Trim(name, `"`)] = line[open+1 : len(line)-1]
+	}
+	return vms
+}
+
+// ParseGlobalStatus parses the output of 'vagrant global-status --machine-readable'. Each
+// machine is reported as machine-id, provider-name, machine-home and state lines whose
+// target is the machine name.
+func ParseGlobalStatus(output string) []VagrantEnvironment {
+	var environments []VagrantEnvironment
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ",", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		if parts[2] == "machine-id" {
+			environments = append(environments, VagrantEnvironment{ID: parts[3], Name: parts[1]})
+			continue
+		}
+		if len(environments) == 0 {
+			continue
+		}
+		current := &environments[len(environments)-1]
+		switch parts[2] {
+		case "provider-name":
+			current.Provider = parts[3]
+		case "machine-home":
+			current.Directory = parts[3]
+		case "state":
+			current.State = parts[3]
+		}
+	}
+	return environments
+}
+
+// reconcileEnvironments marks each environment as managed by a VM under baseDir, orphaned or
+// unmanaged. An environment is orphaned when its directory no longer exists, or when it lies
+// under baseDir but no VM is registered there, as left behind by crashed runs.
+func reconcileEnvironments(environments []VagrantEnvironment, baseDir string) []VagrantEnvironment {
+	type machineKey struct{ dir, machine string }
+	managed := make(map[machineKey]string)
+	if entries, err := os.ReadDir(baseDir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			key := machineKey{filepath.Clean(VMDir(baseDir, entry.Name())), MachineName(baseDir, entry.Name())}
+			managed[key] = entry.Name()
+		}
+	}
+
+	reconciled := make([]VagrantEnvironment, 0, len(environments))
+	for _, environment := range environments {
+		dir := filepath.Clean(environment.Directory)
+		switch name, ok := managed[machineKey{dir, environment.Name}]; {
+		case ok:
+			environment.Ownership = EnvironmentManaged
+			environment.VMName = name
+		case !dirExists(dir):
+			environment.Ownership = EnvironmentOrphaned
+			environment.Reason = "environment directory no longer exists"
+		case isWithin(baseDir, dir):
+			environment.Ownership = EnvironmentOrphaned
+			environment.Reason = "no managed VM is registered for this directory"
+		default:
+			environment.Ownership = EnvironmentUnmanaged
+		}
+		reconciled = append(reconciled, environment)
+	}
+	return reconciled
+}
+
+// dirExists reports whether path is an existing directory
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isWithin reports whether path is baseDir or lies below it
+func isWithin(baseDir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(baseDir), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,133 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+)
+
+func TestParseGlobalStatus(t *testing.T) {
+	output := "1700000000,,metadata,machine-count,2\n" +
+		"1700000000,default,machine-id,a1b2c3d\n" +
+		"1700000000,default,provider-name,virtualbox\n" +
+		"1700000000,default,machine-home,/home/dev/.vagrant-mcp/vms/api\n" +
+		"1700000000,default,state,running\n" +
+		"1700000000,web,machine-id,e4f5a6b\n" +
+		"1700000000,web,provider-name,libvirt\n" +
+		"1700000000,web,machine-home,/projects/shop\n" +
+		"1700000000,web,state,poweroff\n" +
+		"1700000000,,ui,info,id       name    provider   state   directory\n"
+
+	environments := ParseGlobalStatus(output)
+	if len(environments) != 2 {
+		t.Fatalf("Expected 2 environments but got %d", len(environments))
+	}
+	expected := []VagrantEnvironment{
+		{ID: "a1b2c3d", Name: "default", Provider: "virtualbox", State: "running", Directory: "/home/dev/.vagrant-mcp/vms/api"},
+		{ID: "e4f5a6b", Name: "web", Provider: "libvirt", State: "poweroff", Directory: "/projects/shop"},
+	}
+	for i, environment := range environments {
+		if environment != expected[i] {
+			t.Errorf("Expected environment %+v but got %+v", expected[i], environment)
+		}
+	}
+}
+
+func TestReconcileEnvironments(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(baseDir, "api"), 0755); err != nil {
+		t.Fatalf("Failed to create VM directory: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(baseDir, "crashed"), 0755); err != nil {
+		t.Fatalf("Failed to create VM directory: %v", err)
+	}
+	projectDir := t.TempDir()
+
+	environments := []VagrantEnvironment{
+		{ID: "1", Name: "default", Directory: filepath.Join(baseDir, "api")},
+		{ID: "2", Name: "other", Directory: filepath.Join(baseDir, "crashed")},
+		{ID: "3", Name: "default", Directory: filepath.Join(baseDir, "removed")},
+		{ID: "4", Name: "default", Directory: projectDir},
+		{ID: "5", Name: "default", Directory: filepath.Join(projectDir, "deleted")},
+	}
+	testCases := []struct {
+		id        string
+		ownership string
+		vmName    string
+	}{
+		{"1", EnvironmentManaged, "api"},
+		{"2", EnvironmentOrphaned, ""},
+		{"3", EnvironmentOrphaned, ""},
+		{"4", EnvironmentUnmanaged, ""},
+		{"5", EnvironmentOrphaned, ""},
+	}
+
+	reconciled := reconcileEnvironments(environments, baseDir)
+	for i, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			if reconciled[i].Ownership != tc.ownership {
+				t.Errorf("Expected ownership %s but got %s", tc.ownership, reconciled[i].Ownership)
+			}
+			if reconciled[i].VMName != tc.vmName {
+				t.Errorf("Expected VM name %q but got %q", tc.vmName, reconciled[i].VMName)
+			}
+		})
+	}
+}
+
+func TestDestroyEnvironmentWithMissingDirectory(t *testing.T) {
+	ctx := context.Background()
+	fake := cmdexec.NewFakeVagrant()
+	baseDir := t.TempDir()
+	manager, err := NewManagerWithRunner(baseDir, fake)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	var hostCommands []string
+	manager.hostRunner = func(ctx context.Context, name string, args ...string) (string, error) {
+		command := strings.Join(append([]string{name}, args...), " ")
+		hostCommands = append(hostCommands, command)
+		if command == "VBoxManage list vms" {
+			return "\"api\" {1111-aaaa}\n" +
+				"\"shop_default_1700000000000_1234\" {2222-bbbb}\n" +
+				"\"other\" {3333-cccc}\n", nil
+		}
+		return "", nil
+	}
+
+	// A VM of this server is named after it, a machine elsewhere gets Vagrant's default name
+	testCases := []struct {
+		environment VagrantEnvironment
+		deleted     string
+	}{
+		{VagrantEnvironment{ID: "a1", Name: "default", Provider: ProviderVirtualBox, Directory: filepath.Join(baseDir, "api")}, "1111-aaaa"},
+		{VagrantEnvironment{ID: "b2", Name: "default", Provider: ProviderVirtualBox, Directory: "/gone/shop"}, "2222-bbbb"},
+	}
+	for _, tc := range testCases {
+		hostCommands = nil
+		removal, err := manager.DestroyEnvironment(ctx, tc.environment)
+		if err != nil {
+			t.Fatalf("Expected %s to be removed but got %v", tc.environment.ID, err)
+		}
+		if removal.Method != RemovedByProvider || !removal.Pruned || len(removal.ProviderMachines) != 1 {
+			t.Errorf("Expected one provider machine deleted and the index pruned but got %+v", removal)
+		}
+		if !slices.Contains(hostCommands, "VBoxManage unregistervm "+tc.deleted+" --delete") {
+			t.Errorf("Expected VM %s to be unregistered but got %v", tc.deleted, hostCommands)
+		}
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 2 || strings.Join(calls[0].Args, " ") != "global-status --prune" {
+		t.Errorf("Expected only 'vagrant global-status --prune' calls but got %v", calls)
+	}
+
+	if _, err := manager.DestroyEnvironment(ctx, VagrantEnvironment{ID: "c3", Name: "default", Provider: "vmware_desktop", Directory: "/gone/vm"}); err == nil {
+		t.Error("Expected an error for a provider whose machines cannot be deleted")
+	}
+}
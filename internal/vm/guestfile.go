@@ -0,0 +1,451 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// MaxGuestFileBytes bounds the content written to a guest file in one call. The content travels
+// base64 encoded in the command line, which Linux hosts limit to 128KB per argument.
+const MaxGuestFileBytes = 64 * 1024
+
+// diffEndMarker separates the diff from the rest of the output of a guest file write; diff
+// output lines never start with "="
+const diffEndMarker = "=== end of diff ==="
+
+var (
+	// fileModePattern matches the octal modes a guest file may be given
+	fileModePattern = regexp.MustCompile(`^[0-7]{3,4}$`)
+	// fileOwnerPattern matches user or user:group owners
+	fileOwnerPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}(:[a-z_][a-z0-9_-]{0,31})?$`)
+)
+
+// GuestFile describes a file to write in a VM
+type GuestFile struct {
+	// Path is the absolute guest path of the file
+	Path    string
+	Content string
+	// Mode and Owner are applied after writing; new files default to mode 0644
+	Mode  string
+	Owner string
+	// Sudo writes the file as root, for files the vagrant user cannot write
+	Sudo bool
+	// Backup copies an existing file to <path>.bak.<timestamp> before it is replaced
+	Backup bool
+	// CreateDirs creates missing parent directories
+	CreateDirs bool
+	// DryRun only computes the diff
+	DryRun bool
+}
+
+// FileEdit replaces text in a guest file. Old must occur exactly once unless ReplaceAll is set.
+type FileEdit struct {
+	Old        string `json:"old"`
+	New        string `json:"new"`
+	ReplaceAll bool   `json:"replace_all"`
+}
+
+// GuestFileWrite is the outcome of a guest file write
+type GuestFileWrite struct {
+	Path    string `json:"path"`
+	Diff    string `json:"diff"`
+	Changed bool   `json:"changed"`
+	Backup  string `json:"backup,omitempty"`
+	Written bool   `json:"written"`
+}
+
+// ValidateGuestFile checks a file to write in a VM
+func ValidateGuestFile(file GuestFile) error {
+	if err := validateGuestFilePath(file.Path); err != nil {
+		return err
+	}
+	if len(file.Content) > MaxGuestFileBytes {
+		return errors.InvalidInput(fmt.Sprintf("content is larger than %d bytes; copy larger files with the sync tools", MaxGuestFileBytes))
+	}
+	if file.Mode != "" && !fileModePattern.MatchString(file.Mode) {
+		return errors.InvalidInput(fmt.Sprintf("invalid mode '%s': use octal, e.g. 0644", file.Mode))
+	}
+	if file.Owner != "" && !fileOwnerPattern.MatchString(file.Owner) {
+		return errors.InvalidInput(fmt.Sprintf("invalid owner '%s': use user or user:group", file.Owner))
+	}
+	return nil
+}
+
+// validateGuestFilePath returns an error unless p is an absolute guest file path
+func validateGuestFilePath(p string) error {
+	if !strings.HasPrefix(p, "/") {
+		return errors.InvalidInput(fmt.Sprintf("path '%s' must be absolute", p))
+	}
+	if strings.ContainsAny(p, "\x00\n") || path.Clean(p) == "/" {
+		return errors.InvalidInput(fmt.Sprintf("invalid path '%s'", p))
+	}
+	return nil
+}
+
+// WriteFileCommand returns the command that writes a guest file, printing the unified diff
+// between its current and new content, the diff end marker and the path of the backup if one
+// was made. Existing files keep their mode and owner unless they are set.
+func WriteFileCommand(file GuestFile, now time.Time) (string, error) {
+	if err := ValidateGuestFile(file); err != nil {
+		return "", err
+	}
+	p := path.Clean(file.Path)
+	var script strings.Builder
+	fmt.Fprintf(&script, "set -e; f=%s; t=$(mktemp); trap 'rm -f \"$t\"' EXIT; ", shellquote.Quote(p))
+	fmt.Fprintf(&script, "printf '%%s' %s | base64 -d > \"$t\"; ", shellquote.Quote(base64.StdEncoding.EncodeToString([]byte(file.Content))))
+	script.WriteString(`if [ -e "$f" ]; then old="$f"; else old=/dev/null; fi; `)
+	script.WriteString(`diff -u --label "$f" --label "$f" "$old" "$t" || [ $? -eq 1 ]; `)
+	fmt.Fprintf(&script, "echo %s; ", shellquote.Quote(diffEndMarker))
+	if file.DryRun {
+		script.WriteString("exit 0; ")
+	} else {
+		if file.CreateDirs {
+			script.WriteString(`mkdir -p "$(dirname "$f")"; `)
+		}
+		if file.Backup {
+			fmt.Fprintf(&script, `if [ -e "$f" ]; then b="$f.bak.%s"; cp -p "$f" "$b"; echo "$b"; fi; `, now.UTC().Format("20060102T150405Z"))
+		}
+		mode := file.Mode
+		if mode == "" {
+			mode = "0644"
+		}
+		fmt.Fprintf(&script, `if [ -e "$f" ]; then cat "$t" > "$f"; else install -m %s "$t" "$f"; fi; `, mode)
+		if file.Mode != "" {
+			fmt.Fprintf(&script, `chmod %s "$f"; `, file.Mode)
+		}
+		if file.Owner != "" {
+			fmt.Fprintf(&script, `chown %s "$f"; `, file.Owner)
+		}
+	}
+	return guestShell(strings.TrimSuffix(script.String(), " "), file.Sudo), nil
+}
+
+// ParseWriteFileOutput parses the output of a WriteFileCommand
+func ParseWriteFileOutput(file GuestFile, output string) GuestFileWrite {
+	diff, rest, _ := strings.Cut(output, diffEndMarker+"\n")
+	return GuestFileWrite{
+		Path:    path.Clean(file.Path),
+		Diff:    diff,
+		Changed: diff != "",
+		Backup:  strings.TrimSpace(rest),
+		Written: !file.DryRun,
+	}
+}
+
+// ReadFileCommand returns the command that prints a guest file base64 encoded
+func ReadFileCommand(p string, sudo bool) (string, error) {
+	if err := validateGuestFilePath(p); err != nil {
+		return "", err
+	}
+	return guestShell(fmt.Sprintf("base64 -w 0 -- %s", shellquote.Quote(path.Clean(p))), sudo), nil
+}
+
+// ApplyEdits applies text replacements to file content in order
+func ApplyEdits(content string, edits []FileEdit) (string, error) {
+	if len(edits) == 0 {
+		return "", errors.InvalidInput("no edits given")
+	}
+	for i, edit := range edits {
+		if edit.Old == "" {
+			return "", errors.InvalidInput(fmt.Sprintf("edit %d: old text is empty", i+1))
+		}
+		count := strings.Count(content, edit.Old)
+		switch {
+		case count == 0:
+			return "", errors.InvalidInput(fmt.Sprintf("edit %d: old text not found in the file", i+1))
+		case count > 1 && !edit.ReplaceAll:
+			return "", errors.InvalidInput(fmt.Sprintf("edit %d: old text occurs %d times; add context to make it unique or set replace_all", i+1, count))
+		}
+		content = strings.ReplaceAll(content, edit.Old, edit.New)
+	}
+	return content, nil
+}
+
+// guestShell returns the command running script with sh, as root when sudo is set
+func guestShell(script string, sudo bool) string {
+	if sudo {
+		return "sudo -n sh -c " + shellquote.Quote(script)
+	}
+	return "sh -c " + shellquote.Quote(script)
+}
+
+// Find result limits
+const (
+	DefaultFindResults = 200
+	MaxFindResults     = 2000
+)
+
+// findTypes maps the file types find_files accepts to find's -type letters
+var findTypes = map[string]string{
+	"file":      "f",
+	"directory": "d",
+	"symlink":   "l",
+}
+
+// FileQuery describes a search for guest files
+type FileQuery struct {
+	// Root is the guest directory searched; relative roots start from /vagrant
+	Root string
+	// Name is a glob matched against file names
+	Name            string
+	CaseInsensitive bool
+	// Type is file, directory or symlink; any type when empty
+	Type       string
+	MinSizeKB  int
+	MaxSizeKB  int
+	ModifiedIn time.Duration
+	MaxDepth   int
+	// Exclude lists directory names that are not descended into, e.g. node_modules
+	Exclude    []string
+	MaxResults int
+	Sudo       bool
+}
+
+// FoundFile is a guest file matching a FileQuery
+type FoundFile struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// FindFilesCommand returns the find command for a query. It prints one more result than the
+// query's limit, so callers can tell whether results were left out.
+func FindFilesCommand(query FileQuery) (string, error) {
+	root := query.Root
+	if root == "" {
+		root = "/vagrant"
+	}
+	if strings.ContainsAny(root, "\x00\n") {
+		return "", errors.InvalidInput(fmt.Sprintf("invalid path '%s'", root))
+	}
+	if !strings.HasPrefix(root, "/") {
+		root = path.Join("/vagrant", root)
+	}
+	if query.MinSizeKB < 0 || query.MaxSizeKB < 0 || query.MaxDepth < 0 || query.ModifiedIn < 0 {
+		return "", errors.InvalidInput("sizes, max_depth and modified_within must not be negative")
+	}
+	if query.MaxResults <= 0 {
+		query.MaxResults = DefaultFindResults
+	}
+	query.MaxResults = min(query.MaxResults, MaxFindResults)
+
+	args := []string{"find", shellquote.Quote(root)}
+	if query.MaxDepth > 0 {
+		args = append(args, "-maxdepth", fmt.Sprint(query.MaxDepth))
+	}
+	if len(query.Exclude) > 0 {
+		prune := []string{`\(`}
+		for i, name := range query.Exclude {
+			if name == "" || strings.Contains(name, "/") {
+				return "", errors.InvalidInput(fmt.Sprintf("invalid exclude '%s': use directory names", name))
+			}
+			if i > 0 {
+				prune = append(prune, "-o")
+			}
+			prune = append(prune, "-name", shellquote.Quote(name))
+		}
+		args = append(args, append(prune, `\)`, "-type", "d", "-prune", "-o")...)
+	}
+	if query.Name != "" {
+		test := "-name"
+		if query.CaseInsensitive {
+			test = "-iname"
+		}
+		args = append(args, test, shellquote.Quote(query.Name))
+	}
+	if query.Type != "" {
+		letter, ok := findTypes[query.Type]
+		if !ok {
+			return "", errors.InvalidInput(fmt.Sprintf("invalid type '%s': use file, directory or symlink", query.Type))
+		}
+		args = append(args, "-type", letter)
+	}
+	if query.MinSizeKB > 0 {
+		args = append(args, "-size", fmt.Sprintf("+%dc", query.MinSizeKB*1024-1))
+	}
+	if query.MaxSizeKB > 0 {
+		args = append(args, "-size", fmt.Sprintf("-%dc", query.MaxSizeKB*1024+1))
+	}
+	if query.ModifiedIn > 0 {
+		minutes := int((query.ModifiedIn + time.Minute - 1) / time.Minute)
+		args = append(args, "-mmin", fmt.Sprintf("-%d", minutes))
+	}
+	args = append(args, "-printf", `'%p\t%y\t%s\t%m\t%T@\n'`)
+	command := strings.Join(args, " ") + fmt.Sprintf(" 2>/dev/null | head -n %d", query.MaxResults+1)
+	if query.Sudo {
+		return "sudo -n sh -c " + shellquote.Quote(command), nil
+	}
+	return command, nil
+}
+
+// ParseFindOutput parses the output of a FindFilesCommand and reports whether more than limit
+// files matched
+func ParseFindOutput(output string, limit int) ([]FoundFile, bool) {
+	if limit <= 0 {
+		limit = DefaultFindResults
+	}
+	limit = min(limit, MaxFindResults)
+	files := []FoundFile{}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[2], 10, 64)
+		seconds, _ := strconv.ParseFloat(fields[4], 64)
+		fileType := "other"
+		for name, letter := range findTypes {
+			if letter == fields[1] {
+				fileType = name
+			}
+		}
+		files = append(files, FoundFile{
+			Path:    fields[0],
+			Type:    fileType,
+			Size:    size,
+			Mode:    fields[3],
+			ModTime: time.Unix(int64(seconds), 0).UTC(),
+		})
+	}
+	if len(files) > limit {
+		return files[:limit], true
+	}
+	return files, false
+}
+
+// Content search limits
+const (
+	DefaultGrepResults = 100
+	MaxGrepResults     = 1000
+	// maxGrepLineBytes bounds the text returned for a matching line, e.g. of a minified file
+	maxGrepLineBytes = 500
+)
+
+// ContentQuery describes a search for text in guest files
+type ContentQuery struct {
+	// Paths are the guest files and directories searched; relative paths start from /vagrant
+	Paths   []string
+	Pattern string
+	// Regex matches Pattern as an extended regular expression rather than as fixed text
+	Regex           bool
+	CaseInsensitive bool
+	// WholeWord only matches Pattern as a whole word, e.g. as an identifier
+	WholeWord bool
+	// Include lists globs the names of searched files must match, e.g. *.conf
+	Include []string
+	// Exclude lists directory names that are not descended into, e.g. node_modules
+	Exclude    []string
+	MaxResults int
+	Sudo       bool
+}
+
+// ContentMatch is a guest file line matching a ContentQuery
+type ContentMatch struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	// TextTruncated is set when the line was longer than the text returned
+	TextTruncated bool `json:"text_truncated,omitempty"`
+}
+
+// GrepCommand returns the grep command for a query. Binary files and unreadable files are
+// skipped. It prints one more match than the query's limit, so callers can tell whether
+// matches were left out.
+func GrepCommand(query ContentQuery) (string, error) {
+	if query.Pattern == "" {
+		return "", errors.InvalidInput("pattern is empty")
+	}
+	if len(query.Paths) == 0 {
+		return "", errors.InvalidInput("no paths to search")
+	}
+	if query.MaxResults <= 0 {
+		query.MaxResults = DefaultGrepResults
+	}
+	query.MaxResults = min(query.MaxResults, MaxGrepResults)
+
+	// -I skips binary files, -s unreadable ones, and -Z ends file names with NUL, as they may
+	// hold colons
+	args := []string{"grep", "-rnHIsZ"}
+	if query.Regex {
+		args = append(args, "-E")
+	} else {
+		args = append(args, "-F")
+	}
+	if query.CaseInsensitive {
+		args = append(args, "-i")
+	}
+	if query.WholeWord {
+		args = append(args, "-w")
+	}
+	for _, glob := range query.Include {
+		if glob == "" || strings.Contains(glob, "/") {
+			return "", errors.InvalidInput(fmt.Sprintf("invalid include '%s': use file name globs", glob))
+		}
+		args = append(args, "--include="+shellquote.Quote(glob))
+	}
+	for _, name := range query.Exclude {
+		if name == "" || strings.Contains(name, "/") {
+			return "", errors.InvalidInput(fmt.Sprintf("invalid exclude '%s': use directory names", name))
+		}
+		args = append(args, "--exclude-dir="+shellquote.Quote(name))
+	}
+	args = append(args, "-e", shellquote.Quote(query.Pattern), "--")
+	for _, p := range query.Paths {
+		if p == "" || strings.ContainsAny(p, "\x00\n") {
+			return "", errors.InvalidInput(fmt.Sprintf("invalid path '%s'", p))
+		}
+		if !strings.HasPrefix(p, "/") {
+			p = path.Join("/vagrant", p)
+		}
+		args = append(args, shellquote.Quote(p))
+	}
+	command := strings.Join(args, " ") + fmt.Sprintf(" | head -n %d", query.MaxResults+1)
+	if query.Sudo {
+		return "sudo -n sh -c " + shellquote.Quote(command), nil
+	}
+	return command, nil
+}
+
+// ParseGrepOutput parses the output of a GrepCommand and reports whether more than limit
+// lines matched
+func ParseGrepOutput(output string, limit int) ([]ContentMatch, bool) {
+	if limit <= 0 {
+		limit = DefaultGrepResults
+	}
+	limit = min(limit, MaxGrepResults)
+	matches := []ContentMatch{}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		file, rest, ok := strings.Cut(line, "\x00")
+		if !ok {
+			continue
+		}
+		number, text, ok := strings.Cut(rest, ":")
+		lineNumber, err := strconv.Atoi(number)
+		if !ok || err != nil {
+			continue
+		}
+		match := ContentMatch{Path: file, Line: lineNumber, Text: text}
+		if len(text) > maxGrepLineBytes {
+			match.Text = strings.ToValidUTF8(text[:maxGrepLineBytes], "")
+			match.TextTruncated = true
+		}
+		matches = append(matches, match)
+	}
+	if len(matches) > limit {
+		return matches[:limit], true
+	}
+	return matches, false
+}
@@ -0,0 +1,277 @@
+package vm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEdits(t *testing.T) {
+	content := "server {\n  listen 80;\n  root /var/www;\n  listen 80;\n}\n"
+	testCases := []struct {
+		name      string
+		edits     []FileEdit
+		expected  string
+		expectErr bool
+	}{
+		{"replace all", []FileEdit{{Old: "listen 80;", New: "listen 8080;", ReplaceAll: true}}, strings.ReplaceAll(content, "80;", "8080;"), false},
+		{"unique with context", []FileEdit{{Old: "root /var/www;\n  listen 80;", New: "root /srv;\n  listen 81;"}}, "server {\n  listen 80;\n  root /srv;\n  listen 81;\n}\n", false},
+		{"in order", []FileEdit{{Old: "/var/www", New: "/srv"}, {Old: "/srv", New: "/opt/app"}}, strings.Replace(content, "/var/www", "/opt/app", 1), false},
+		{"ambiguous", []FileEdit{{Old: "listen 80;", New: "listen 8080;"}}, "", true},
+		{"not found", []FileEdit{{Old: "listen 443;", New: "listen 8443;"}}, "", true},
+		{"empty old", []FileEdit{{Old: "", New: "x"}}, "", true},
+		{"no edits", nil, "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ApplyEdits(content, tc.edits)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected error but got %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidateGuestFile(t *testing.T) {
+	testCases := []struct {
+		name      string
+		file      GuestFile
+		expectErr bool
+	}{
+		{"valid", GuestFile{Path: "/etc/nginx/sites-available/app", Mode: "0644", Owner: "www-data:www-data"}, false},
+		{"relative path", GuestFile{Path: "etc/hosts"}, true},
+		{"root", GuestFile{Path: "/"}, true},
+		{"invalid mode", GuestFile{Path: "/etc/app.conf", Mode: "rw-r--r--"}, true},
+		{"invalid owner", GuestFile{Path: "/etc/app.conf", Owner: "root; rm -rf /"}, true},
+		{"too large", GuestFile{Path: "/etc/app.conf", Content: strings.Repeat("x", MaxGuestFileBytes+1)}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateGuestFile(tc.file); tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestWriteFileCommandSudo(t *testing.T) {
+	command, err := WriteFileCommand(GuestFile{Path: "/etc/app.conf", Content: "a", Sudo: true}, time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !strings.HasPrefix(command, "sudo -n sh -c '") {
+		t.Errorf("Expected the command to run with sudo but got %s", command)
+	}
+}
+
+// TestWriteFileCommandRuns runs the generated commands with the host's shell tools, which
+// match the guest's
+func TestWriteFileCommandRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	for _, tool := range []string{"diff", "base64", "install"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "conf.d", "app.conf")
+	run := func(file GuestFile) GuestFileWrite {
+		command, err := WriteFileCommand(file, now)
+		if err != nil {
+			t.Fatalf("Failed to build command: %v", err)
+		}
+		output, err := exec.Command("sh", "-c", command).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Command failed: %v: %s", err, output)
+		}
+		return ParseWriteFileOutput(file, string(output))
+	}
+
+	created := run(GuestFile{Path: path, Content: "a\nb\n", CreateDirs: true, Backup: true})
+	if !created.Changed || created.Backup != "" || !strings.Contains(created.Diff, "+a\n+b\n") {
+		t.Errorf("Expected a new file diff without backup but got %+v", created)
+	}
+
+	preview := run(GuestFile{Path: path, Content: "a\nc\n", DryRun: true})
+	if !strings.Contains(preview.Diff, "-b\n+c\n") || preview.Written {
+		t.Errorf("Expected a dry run diff but got %+v", preview)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "a\nb\n" {
+		t.Errorf("Expected the dry run to leave the file unchanged but got %q", content)
+	}
+
+	updated := run(GuestFile{Path: path, Content: "a\nc\n", Mode: "0600", Backup: true})
+	if updated.Backup != path+".bak.20250102T030405Z" {
+		t.Errorf("Expected a backup but got %+v", updated)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "a\nc\n" {
+		t.Errorf("Expected the new content but got %q", content)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 but got %v, %v", info.Mode(), err)
+	}
+
+	unchanged := run(GuestFile{Path: path, Content: "a\nc\n"})
+	if unchanged.Changed {
+		t.Errorf("Expected no change but got diff %q", unchanged.Diff)
+	}
+}
+
+func TestFindFilesCommand(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     FileQuery
+		contains  []string
+		expectErr bool
+	}{
+		{"defaults", FileQuery{}, []string{"find '/vagrant' -printf", "| head -n 201"}, false},
+		{"relative root", FileQuery{Root: "build", Name: "*.jar"}, []string{"find '/vagrant/build' -name '*.jar'"}, false},
+		{"filters", FileQuery{Root: "/var/log", Name: "*.LOG", CaseInsensitive: true, Type: "file", MinSizeKB: 1, MaxSizeKB: 10, ModifiedIn: 90 * time.Second, MaxDepth: 2},
+			[]string{"-maxdepth 2", "-iname '*.LOG'", "-type f", "-size +1023c", "-size -10241c", "-mmin -2"}, false},
+		{"exclude", FileQuery{Exclude: []string{"node_modules", ".git"}, MaxResults: 5000},
+			[]string{`\( -name 'node_modules' -o -name '.git' \) -type d -prune -o`, "| head -n 2001"}, false},
+		{"quoted name", FileQuery{Name: "x'; rm -rf /; '"}, []string{`-name 'x'\''; rm -rf /; '\'''`}, false},
+		{"sudo", FileQuery{Sudo: true}, []string{"sudo -n sh -c 'find "}, false},
+		{"invalid type", FileQuery{Type: "socket"}, nil, true},
+		{"invalid exclude", FileQuery{Exclude: []string{"a/b"}}, nil, true},
+		{"negative size", FileQuery{MinSizeKB: -1}, nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			command, err := FindFilesCommand(tc.query)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected error but got %s", command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			for _, expected := range tc.contains {
+				if !strings.Contains(command, expected) {
+					t.Errorf("Expected %q in %s", expected, command)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFindOutput(t *testing.T) {
+	output := "/vagrant/dist/app.js\tf\t2048\t644\t1700000000.25\n/vagrant/dist\td\t4096\t755\t1700000001\n/vagrant/dist/link\tl\t6\t777\t1700000002\n"
+	files, truncated := ParseFindOutput(output, 2)
+	if !truncated || len(files) != 2 {
+		t.Fatalf("Expected 2 files and truncation but got %+v, %v", files, truncated)
+	}
+	expected := FoundFile{Path: "/vagrant/dist/app.js", Type: "file", Size: 2048, Mode: "644", ModTime: time.Unix(1700000000, 0).UTC()}
+	if files[0] != expected {
+		t.Errorf("Expected %+v but got %+v", expected, files[0])
+	}
+	if files[1].Type != "directory" {
+		t.Errorf("Expected directory but got %s", files[1].Type)
+	}
+	if files, truncated := ParseFindOutput("", 10); len(files) != 0 || truncated {
+		t.Errorf("Expected no files but got %+v", files)
+	}
+}
+
+func TestGrepCommand(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     ContentQuery
+		contains  []string
+		expectErr bool
+	}{
+		{"fixed text", ContentQuery{Paths: []string{"/var/log", "src"}, Pattern: "ERROR"},
+			[]string{"grep -rnHIsZ -F -e 'ERROR' -- '/var/log' '/vagrant/src' | head -n 101"}, false},
+		{"regex", ContentQuery{Paths: []string{"/etc"}, Pattern: "^listen\\s+80", Regex: true, CaseInsensitive: true, MaxResults: 5000},
+			[]string{"-E -i", "| head -n 1001"}, false},
+		{"include and exclude", ContentQuery{Paths: []string{"/opt/venv"}, Pattern: "import", Include: []string{"*.py"}, Exclude: []string{"__pycache__"}},
+			[]string{"--include='*.py' --exclude-dir='__pycache__'"}, false},
+		{"quoted pattern", ContentQuery{Paths: []string{"/etc"}, Pattern: "x'; rm -rf /; '"}, []string{`-e 'x'\''; rm -rf /; '\'''`}, false},
+		{"sudo", ContentQuery{Paths: []string{"/root"}, Pattern: "token", Sudo: true}, []string{"sudo -n sh -c 'grep "}, false},
+		{"no pattern", ContentQuery{Paths: []string{"/etc"}}, nil, true},
+		{"no paths", ContentQuery{Pattern: "x"}, nil, true},
+		{"invalid include", ContentQuery{Paths: []string{"/etc"}, Pattern: "x", Include: []string{"a/*.py"}}, nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			command, err := GrepCommand(tc.query)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected error but got %s", command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			for _, expected := range tc.contains {
+				if !strings.Contains(command, expected) {
+					t.Errorf("Expected %q in %s", expected, command)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGrepOutput(t *testing.T) {
+	long := strings.Repeat("x", maxGrepLineBytes+10)
+	output := "/etc/a:b.conf\x0012:listen 80;\n/var/log/app.log\x003:" + long + "\n/etc/c\x001:more\n"
+	matches, truncated := ParseGrepOutput(output, 2)
+	if !truncated || len(matches) != 2 {
+		t.Fatalf("Expected 2 matches and truncation but got %+v, %v", matches, truncated)
+	}
+	expected := ContentMatch{Path: "/etc/a:b.conf", Line: 12, Text: "listen 80;"}
+	if matches[0] != expected {
+		t.Errorf("Expected %+v but got %+v", expected, matches[0])
+	}
+	if !matches[1].TextTruncated || len(matches[1].Text) != maxGrepLineBytes {
+		t.Errorf("Expected the long line to be truncated but got %d bytes", len(matches[1].Text))
+	}
+	if matches, truncated := ParseGrepOutput("", 10); len(matches) != 0 || truncated {
+		t.Errorf("Expected no matches but got %+v", matches)
+	}
+}
+
+func TestGrepCommandRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs the command with sh")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte("port = 80\nhost = example\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), []byte("port\x00\x01\x02"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	command, err := GrepCommand(ContentQuery{Paths: []string{dir}, Pattern: "PORT", CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	output, err := exec.Command("sh", "-c", command).Output()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	matches, _ := ParseGrepOutput(string(output), 10)
+	expected := []ContentMatch{{Path: filepath.Join(dir, "app.conf"), Line: 1, Text: "port = 80"}}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("Expected only the text file to match with %+v but got %+v", expected, matches)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/hostos"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// guestSSHConfigTTL is how long syncs reuse the 'vagrant ssh-config' output of a VM; a sync of
+// many single files would otherwise run vagrant once per file
+const guestSSHConfigTTL = time.Minute
+
+// sshConfigCache holds the recent 'vagrant ssh-config' output of VMs
+type sshConfigCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSSHConfig
+}
+
+// cachedSSHConfig is the ssh-config output of a VM and when it was read
+type cachedSSHConfig struct {
+	config map[string]string
+	readAt time.Time
+}
+
+// syncSSHConfig returns the ssh-config of a VM for syncs, reading it again once it is older
+// than guestSSHConfigTTL
+func (m *Manager) syncSSHConfig(ctx context.Context, name string) (map[string]string, error) {
+	m.sshConfigs.mu.Lock()
+	cached, ok := m.sshConfigs.entries[name]
+	m.sshConfigs.mu.Unlock()
+	if ok && time.Since(cached.readAt) < guestSSHConfigTTL {
+		return cached.config, nil
+	}
+
+	config, err := m.GetSSHConfig(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	m.sshConfigs.mu.Lock()
+	defer m.sshConfigs.mu.Unlock()
+	if m.sshConfigs.entries == nil {
+		m.sshConfigs.entries = make(map[string]cachedSSHConfig)
+	}
+	m.sshConfigs.entries[name] = cachedSSHConfig{config: config, readAt: time.Now()}
+	return config, nil
+}
+
+// forgetSSHConfig drops the cached ssh-config of a VM, e.g. after a sync using it failed
+// because the VM was reloaded on another port
+func (m *Manager) forgetSSHConfig(name string) {
+	m.sshConfigs.mu.Lock()
+	defer m.sshConfigs.mu.Unlock()
+	delete(m.sshConfigs.entries, name)
+}
+
+// guestRsyncArgs returns the rsync options of a guest sync. Directories are mirrored, deleting
+// the files of the target missing from the source; excluded files are neither copied nor
+// deleted.
+func guestRsyncArgs(dir bool, excludes []string) []string {
+	args := []string{"-az", "--protect-args", "--itemize-changes"}
+	if dir {
+		args = append(args, "--delete")
+	}
+	for _, pattern := range excludes {
+		args = append(args, "--exclude", pattern)
+	}
+	return args
+}
+
+// guestPathKindCommand returns the command printing dir or file for a guest path, and nothing
+// when it does not exist
+func guestPathKindCommand(guestPath string) string {
+	quoted := shellquote.Quote(guestPath)
+	return fmt.Sprintf("if [ -d %[1]s ]; then echo dir; elif [ -e %[1]s ]; then echo file; fi", quoted)
+}
+
+// guestSync copies between the host path hostPath and the guest path guestPath of a running VM
+// with rsync over ssh: to the guest when toVM is set, from it otherwise. Directories are
+// mirrored, single files copied. It returns the changes rsync made, one per line, and reports
+// the progress of the copy to progress when it is not nil.
+func (m *Manager) guestSync(ctx context.Context, name, hostPath, guestPath string, toVM bool, excludes []string, progress func(hostos.RsyncProgress)) ([]byte, error) {
+	guestPath = hostos.GuestSlashes(guestPath)
+	sshConfig, err := m.syncSSHConfig(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	sshArgs := SSHArgs(sshConfig)
+	options, destination := sshArgs[:len(sshArgs)-1], sshArgs[len(sshArgs)-1]
+
+	var dir bool
+	if toVM {
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			return nil, err
+		}
+		dir = info.IsDir()
+	} else {
+		output, err := cmdexec.SSHOutput(ctx, name, sshArgs, guestPathKindCommand(guestPath))
+		if err != nil {
+			m.forgetSSHConfig(name)
+			return nil, fmt.Errorf("failed to check %s in the VM: %w", guestPath, err)
+		}
+		switch strings.TrimSpace(string(output)) {
+		case "dir":
+			dir = true
+		case "file":
+		default:
+			return nil, fmt.Errorf("%s does not exist in the VM", guestPath)
+		}
+		parent := filepath.Dir(hostPath)
+		if dir {
+			parent = hostPath
+		}
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", parent, err)
+		}
+	}
+
+	args := guestRsyncArgs(dir, excludes)
+	if dir {
+		// The trailing slashes copy the contents of the directories rather than the directories
+		hostPath += string(filepath.Separator)
+		guestPath = strings.TrimSuffix(guestPath, "/") + "/"
+	}
+	if toVM {
+		guestDir := path.Dir(guestPath)
+		if dir {
+			guestDir = guestPath
+		}
+		args = append(args, "--rsync-path", fmt.Sprintf("mkdir -p %s && rsync", shellquote.Quote(guestDir)))
+	}
+	output, err := hostos.RsyncOverSSHWithProgress(ctx, options, hostPath, destination+":"+guestPath, toVM, progress, args...)
+	if err != nil {
+		m.forgetSSHConfig(name)
+		return output, err
+	}
+	return output, nil
+}
+
+// GuestFileVersion is the content of a guest file and when it was last modified
+type GuestFileVersion struct {
+	Content []byte
+	ModTime time.Time
+}
+
+// ReadGuestFiles reads the regular files among guest paths of a running VM over one ssh
+// connection. Paths that do not exist or are not regular files are left out.
+func (m *Manager) ReadGuestFiles(ctx context.Context, name string, paths []string) (map[string]GuestFileVersion, error) {
+	if len(paths) == 0 {
+		return map[string]GuestFileVersion{}, nil
+	}
+	sshConfig, err := m.syncSSHConfig(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	output, err := cmdexec.SSHOutput(ctx, name, SSHArgs(sshConfig), readGuestFilesCommand(paths))
+	if err != nil {
+		m.forgetSSHConfig(name)
+		return nil, fmt.Errorf("failed to read files in the VM: %w", err)
+	}
+	return parseGuestFiles(paths, string(output))
+}
+
+// readGuestFilesCommand returns the command printing a line for each regular file among paths:
+// its index in paths, its modification time in Unix seconds and its base64 encoded content
+func readGuestFilesCommand(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellquote.Quote(hostos.GuestSlashes(p))
+	}
+	script := "i=0; for p in " + strings.Join(quoted, " ") + `; do ` +
+		`if [ -f "$p" ]; then printf '%s %s ' "$i" "$(stat -c %Y -- "$p")"; base64 -w 0 -- "$p"; echo; fi; ` +
+		`i=$((i+1)); done`
+	return guestShell(script, false)
+}
+
+// parseGuestFiles parses the output of readGuestFilesCommand for paths
+func parseGuestFiles(paths []string, output string) (map[string]GuestFileVersion, error) {
+	files := make(map[string]GuestFileVersion)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil || index < 0 || index >= len(paths) {
+			return nil, fmt.Errorf("unexpected output reading guest files: %q", line)
+		}
+		modTime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected modification time of %s: %q", paths[index], fields[1])
+		}
+		var content []byte
+		if len(fields) > 2 {
+			if content, err = base64.StdEncoding.DecodeString(fields[2]); err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", paths[index], err)
+			}
+		}
+		files[paths[index]] = GuestFileVersion{Content: content, ModTime: time.Unix(modTime, 0)}
+	}
+	return files, nil
+}
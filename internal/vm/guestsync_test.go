@@ -0,0 +1,93 @@
+package vm
+
+import (
+	"context"
+	osexec "os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGuestRsyncArgs(t *testing.T) {
+	expected := []string{"-az", "--protect-args", "--itemize-changes", "--delete", "--exclude", "node_modules", "--exclude", "*.log"}
+	if got := guestRsyncArgs(true, []string{"node_modules", "*.log"}); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+	// Deleting only makes sense when mirroring a directory
+	if got := guestRsyncArgs(false, nil); slices.Contains(got, "--delete") {
+		t.Errorf("Expected a single file copy not to delete but got %v", got)
+	}
+}
+
+func TestGuestPathKindCommand(t *testing.T) {
+	sh, err := osexec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "it's a file")
+	if err := osexec.Command(sh, "-c", "touch \"$1\"", "sh", file).Run(); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	for path, expected := range map[string]string{dir: "dir", file: "file", filepath.Join(dir, "missing"): ""} {
+		output, err := osexec.Command(sh, "-c", guestPathKindCommand(path)).Output()
+		if err != nil || strings.TrimSpace(string(output)) != expected {
+			t.Errorf("Expected %q for %s but got %q (%v)", expected, path, output, err)
+		}
+	}
+}
+
+func TestSyncSSHConfigCache(t *testing.T) {
+	ctx := context.Background()
+	m, fake := newFakeManager(t, "sync-vm")
+	if err := m.StartVM(ctx, "sync-vm"); err != nil {
+		t.Fatalf("Failed to start VM: %v", err)
+	}
+	sshConfigCalls := func() int {
+		calls := 0
+		for _, call := range fake.Calls() {
+			if slices.Contains(call.Args, "ssh-config") {
+				calls++
+			}
+		}
+		return calls
+	}
+
+	for range 3 {
+		config, err := m.syncSSHConfig(ctx, "sync-vm")
+		if err != nil || config["Port"] != "2222" {
+			t.Fatalf("Expected the VM's ssh-config but got %v (%v)", config, err)
+		}
+	}
+	if calls := sshConfigCalls(); calls != 1 {
+		t.Errorf("Expected ssh-config to be read once but it was read %d times", calls)
+	}
+	m.forgetSSHConfig("sync-vm")
+	if _, err := m.syncSSHConfig(ctx, "sync-vm"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if calls := sshConfigCalls(); calls != 2 {
+		t.Errorf("Expected ssh-config to be read again after it was forgotten but it was read %d times", calls)
+	}
+}
+
+func TestReadGuestFiles(t *testing.T) {
+	paths := []string{"/vagrant/main.go", "/vagrant/missing.go", "/vagrant/empty.txt"}
+	if command := readGuestFilesCommand(paths); !strings.Contains(command, "/vagrant/missing.go") || !strings.HasPrefix(command, "sh -c ") {
+		t.Errorf("Expected a shell command over every path but got %s", command)
+	}
+	files, err := parseGuestFiles(paths, "0 1700000000 cGFja2FnZSBtYWluCg==\n2 1700000100 \n")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if len(files) != 2 || string(files["/vagrant/main.go"].Content) != "package main\n" || files["/vagrant/main.go"].ModTime.Unix() != 1700000000 {
+		t.Errorf("Expected main.go and empty.txt but got %+v", files)
+	}
+	if file, ok := files["/vagrant/empty.txt"]; !ok || len(file.Content) != 0 {
+		t.Errorf("Expected an empty empty.txt but got %+v", file)
+	}
+	if _, err := parseGuestFiles(paths, "7 1700000000 eA==\n"); err == nil {
+		t.Errorf("Expected an error for an unknown index")
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// infoTimeout bounds each provider and guest query of GetVMInfo
+const infoTimeout = 15 * time.Second
+
+// virtualBoxInfoKeys are the 'VBoxManage showvminfo --machinereadable' keys get_vm_info reports
+var virtualBoxInfoKeys = []string{"name", "ostype", "memory", "cpus", "VMState", "VMStateChangeTime", "firmware", "CfgFile"}
+
+// guestFactsCommand prints facts about the guest, one key=value per line
+const guestFactsCommand = `echo "hostname=$(hostname)"; . /etc/os-release 2>/dev/null; echo "os=$PRETTY_NAME"; ` +
+	`echo "kernel=$(uname -r)"; echo "arch=$(uname -m)"; echo "uptime=$(cut -d' ' -f1 /proc/uptime)"; ` +
+	`echo "ip_addresses=$(hostname -I 2>/dev/null)"; echo "cpus=$(nproc)"; ` +
+	`awk '/^MemTotal:/{print "memory_total_kb="$2} /^MemAvailable:/{print "memory_available_kb="$2}' /proc/meminfo; ` +
+	`df -Pk / | awk 'NR==2{print "disk_total_kb="$2; print "disk_used_kb="$3; print "disk_available_kb="$4}'`
+
+// GuestFacts describe the operating system and resources of a running VM as the guest sees them
+type GuestFacts struct {
+	Hostname          string   `json:"hostname,omitempty"`
+	OS                string   `json:"os,omitempty"`
+	Kernel            string   `json:"kernel,omitempty"`
+	Arch              string   `json:"arch,omitempty"`
+	UptimeSeconds     float64  `json:"uptime_seconds"`
+	IPAddresses       []string `json:"ip_addresses"`
+	CPUs              int      `json:"cpus,omitempty"`
+	MemoryTotalMB     int      `json:"memory_total_mb,omitempty"`
+	MemoryAvailableMB int      `json:"memory_available_mb,omitempty"`
+	// Disk sizes are of the root filesystem
+	DiskTotalGB     float64 `json:"disk_total_gb,omitempty"`
+	DiskUsedGB      float64 `json:"disk_used_gb,omitempty"`
+	DiskAvailableGB float64 `json:"disk_available_gb,omitempty"`
+}
+
+// VMInfo aggregates what vagrant, the provider and the guest report about a VM
+type VMInfo struct {
+	Name  string       `json:"name"`
+	State core.VMState `json:"state"`
+	// Provider is the provider the machine was created with, and ProviderID its ID there
+	Provider   string `json:"provider,omitempty"`
+	ProviderID string `json:"provider_id,omitempty"`
+	Box        string `json:"box,omitempty"`
+	// BoxVersion is the version of the box the machine was created from
+	BoxVersion  string      `json:"box_version,omitempty"`
+	CPU         int         `json:"cpu,omitempty"`
+	Memory      int         `json:"memory,omitempty"`
+	DiskSizeGB  int         `json:"disk_size_gb,omitempty"`
+	ProjectPath string      `json:"project_path,omitempty"`
+	SyncType    string      `json:"sync_type,omitempty"`
+	Network     string      `json:"network,omitempty"`
+	Ports       []core.Port `json:"ports"`
+	Tags        []string    `json:"tags,omitempty"`
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`
+	SSH         *SSHInfo    `json:"ssh,omitempty"`
+	// ProviderDetails are what the provider reports about the machine, e.g. VBoxManage
+	// showvminfo or virsh dominfo
+	ProviderDetails map[string]string `json:"provider_details,omitempty"`
+	Guest           *GuestFacts       `json:"guest,omitempty"`
+	// Notes explain the details that could not be collected
+	Notes []string `json:"notes,omitempty"`
+}
+
+// boxMeta is the box_meta file Vagrant writes for a machine
+type boxMeta struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// GetVMInfo collects the details of a VM from its configuration, its .vagrant directory, the
+// provider and, when it is running, the guest. Details that cannot be collected are left out
+// with a note.
+func (m *Manager) GetVMInfo(ctx context.Context, name string) (VMInfo, error) {
+	state, err := m.GetVMState(ctx, name)
+	if err != nil {
+		return VMInfo{}, err
+	}
+	info := VMInfo{Name: name, State: state, Ports: []core.Port{}}
+	if config, err := m.GetVMConfig(ctx, name); err == nil {
+		info.Provider = config.Provider
+		info.Box = config.Box
+		info.CPU = config.CPU
+		info.Memory = config.Memory
+		info.DiskSizeGB = config.DiskSizeGB
+		info.ProjectPath = config.ProjectPath
+		info.SyncType = config.SyncType
+		info.Network = config.Network
+		info.Tags = config.Tags
+		info.ExpiresAt = config.ExpiresAt
+		if config.Ports != nil {
+			info.Ports = config.Ports
+		}
+	}
+
+	machineDir := filepath.Join(m.getVMDir(name), ".vagrant", "machines", MachineName(m.baseDir, name))
+	if provider, id := machineProvider(machineDir); provider != "" {
+		info.Provider, info.ProviderID = provider, id
+		if meta, err := readBoxMeta(filepath.Join(machineDir, provider)); err == nil {
+			info.BoxVersion = meta.Version
+			if info.Box == "" {
+				info.Box = meta.Name
+			}
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, infoTimeout)
+		details, err := providerDetails(queryCtx, provider, id)
+		cancel()
+		if err != nil {
+			info.Notes = append(info.Notes, fmt.Sprintf("provider details unavailable: %v", err))
+		} else {
+			info.ProviderDetails = details
+		}
+	}
+
+	if state != core.Running {
+		return info, nil
+	}
+	sshConfig, err := m.GetSSHConfig(ctx, name)
+	if err != nil {
+		info.Notes = append(info.Notes, fmt.Sprintf("SSH details unavailable: %v", err))
+		return info, nil
+	}
+	ssh := NewSSHInfo(name, sshConfig)
+	info.SSH = &ssh
+	queryCtx, cancel := context.WithTimeout(ctx, infoTimeout)
+	defer cancel()
+	var output, stderr bytes.Buffer
+	query := cmdexec.SSHCommand{VMName: name, Args: SSHArgs(sshConfig), Command: "sh -c " + shellquote.Quote(guestFactsCommand), Stdout: &output, Stderr: &stderr}
+	if err := cmdexec.RunSSH(queryCtx, query); err != nil {
+		info.Notes = append(info.Notes, fmt.Sprintf("guest facts unavailable: %v: %s", err, strings.TrimSpace(stderr.String())))
+		return info, nil
+	}
+	facts := ParseGuestFacts(output.String())
+	info.Guest = &facts
+	return info, nil
+}
+
+// machineProvider returns the provider a machine was created with and its ID there, read from
+// the machine's directory under .vagrant
+func machineProvider(machineDir string) (string, string) {
+	providers, err := os.ReadDir(machineDir)
+	if err != nil {
+		return "", ""
+	}
+	for _, provider := range providers {
+		if id, err := os.ReadFile(filepath.Join(machineDir, provider.Name(), "id")); err == nil && strings.TrimSpace(string(id)) != "" {
+			return provider.Name(), strings.TrimSpace(string(id))
+		}
+	}
+	return "", ""
+}
+
+// readBoxMeta reads the box a machine was created from in its provider directory
+func readBoxMeta(providerDir string) (boxMeta, error) {
+	data, err := os.ReadFile(filepath.Join(providerDir, "box_meta"))
+	if err != nil {
+		return boxMeta{}, err
+	}
+	var meta boxMeta
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// providerDetails queries the provider about a machine. Only VirtualBox and libvirt are
+// queried; other providers report no details.
+func providerDetails(ctx context.Context, provider, id string) (map[string]string, error) {
+	switch provider {
+	case ProviderVirtualBox:
+		output, err := exec.CommandContext(ctx, "VBoxManage", "showvminfo", id, "--machinereadable").Output()
+		if err != nil {
+			return nil, fmt.Errorf("VBoxManage showvminfo failed: %w", err)
+		}
+		return ParseVirtualBoxInfo(string(output)), nil
+	case ProviderLibvirt:
+		output, err := exec.CommandContext(ctx, "virsh", "-c", "qemu:///system", "dominfo", id).Output()
+		if err != nil {
+			return nil, fmt.Errorf("virsh dominfo failed: %w", err)
+		}
+		return ParseVirshDominfo(string(output)), nil
+	}
+	return nil, nil
+}
+
+// ParseVirtualBoxInfo parses 'VBoxManage showvminfo --machinereadable' output, keeping the
+// keys get_vm_info reports
+func ParseVirtualBoxInfo(output string) map[string]string {
+	details := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(key, `"`)
+		for _, wanted := range virtualBoxInfoKeys {
+			if key == wanted {
+				details[key] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return details
+}
+
+// ParseVirshDominfo parses 'virsh dominfo' output of "Key:   value" lines
+func ParseVirshDominfo(output string) map[string]string {
+	details := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		details[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return details
+}
+
+// ParseGuestFacts parses the output of the guest facts command
+func ParseGuestFacts(output string) GuestFacts {
+	facts := GuestFacts{IPAddresses: []string{}}
+	kilobytes := func(value string) float64 {
+		kb, _ := strconv.ParseFloat(value, 64)
+		return kb
+	}
+	gigabytes := func(value string) float64 {
+		return math.Round(kilobytes(value)/1024/1024*10) / 10
+	}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "hostname":
+			facts.Hostname = value
+		case "os":
+			facts.OS = value
+		case "kernel":
+			facts.Kernel = value
+		case "arch":
+			facts.Arch = value
+		case "uptime":
+			facts.UptimeSeconds, _ = strconv.ParseFloat(value, 64)
+		case "ip_addresses":
+			facts.IPAddresses = append(facts.IPAddresses, strings.Fields(value)...)
+		case "cpus":
+			facts.CPUs, _ = strconv.Atoi(value)
+		case "memory_total_kb":
+			facts.MemoryTotalMB = int(kilobytes(value) / 1024)
+		case "memory_available_kb":
+			facts.MemoryAvailableMB = int(kilobytes(value) / 1024)
+		case "disk_total_kb":
+			facts.DiskTotalGB = gigabytes(value)
+		case "disk_used_kb":
+			facts.DiskUsedGB = gigabytes(value)
+		case "disk_available_kb":
+			facts.DiskAvailableGB = gigabytes(value)
+		}
+	}
+	return facts
+}
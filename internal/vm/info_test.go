@@ -0,0 +1,92 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGuestFacts(t *testing.T) {
+	output := `hostname=webapp-dev
+os=Ubuntu 22.04.4 LTS
+kernel=5.15.0-105-generic
+arch=x86_64
+uptime=3725.41
+ip_addresses=10.0.2.15 192.168.56.10
+cpus=4
+memory_total_kb=4028104
+memory_available_kb=3174688
+disk_total_kb=40581564
+disk_used_kb=5242880
+disk_available_kb=35338684
+`
+	expected := GuestFacts{
+		Hostname:          "webapp-dev",
+		OS:                "Ubuntu 22.04.4 LTS",
+		Kernel:            "5.15.0-105-generic",
+		Arch:              "x86_64",
+		UptimeSeconds:     3725.41,
+		IPAddresses:       []string{"10.0.2.15", "192.168.56.10"},
+		CPUs:              4,
+		MemoryTotalMB:     3933,
+		MemoryAvailableMB: 3100,
+		DiskTotalGB:       38.7,
+		DiskUsedGB:        5,
+		DiskAvailableGB:   33.7,
+	}
+	if facts := ParseGuestFacts(output); !reflect.DeepEqual(facts, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, facts)
+	}
+}
+
+func TestParseProviderDetails(t *testing.T) {
+	vbox := `name="webapp-dev_default_1700000000000_1234"
+groups="/"
+ostype="Ubuntu (64-bit)"
+memory=4096
+cpus=4
+VMState="running"
+VMStateChangeTime="2025-01-02T10:00:00.000000000"
+`
+	expected := map[string]string{
+		"name":              "webapp-dev_default_1700000000000_1234",
+		"ostype":            "Ubuntu (64-bit)",
+		"memory":            "4096",
+		"cpus":              "4",
+		"VMState":           "running",
+		"VMStateChangeTime": "2025-01-02T10:00:00.000000000",
+	}
+	if details := ParseVirtualBoxInfo(vbox); !reflect.DeepEqual(details, expected) {
+		t.Errorf("Expected %v but got %v", expected, details)
+	}
+
+	virsh := "Id:             3\nName:           webapp-dev_default\nState:          running\nCPU(s):         4\nMax memory:     4194304 KiB\n\n"
+	details := ParseVirshDominfo(virsh)
+	if details["State"] != "running" || details["Max memory"] != "4194304 KiB" || len(details) != 5 {
+		t.Errorf("Expected the dominfo fields but got %v", details)
+	}
+}
+
+func TestMachineProvider(t *testing.T) {
+	machineDir := filepath.Join(t.TempDir(), ".vagrant", "machines", "default")
+	providerDir := filepath.Join(machineDir, "virtualbox")
+	if err := os.MkdirAll(providerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if provider, id := machineProvider(machineDir); provider != "" || id != "" {
+		t.Errorf("Expected no provider before the machine is created but got %s %s", provider, id)
+	}
+	if err := os.WriteFile(filepath.Join(providerDir, "id"), []byte("0f1e2d3c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(providerDir, "box_meta"), []byte(`{"name":"ubuntu/focal64","version":"20240821.0.1","provider":"virtualbox"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if provider, id := machineProvider(machineDir); provider != "virtualbox" || id != "0f1e2d3c" {
+		t.Errorf("Expected the virtualbox machine 0f1e2d3c but got %s %s", provider, id)
+	}
+	if meta, err := readBoxMeta(providerDir); err != nil || meta.Version != "20240821.0.1" || meta.Name != "ubuntu/focal64" {
+		t.Errorf("Expected the box version but got %+v, %v", meta, err)
+	}
+}
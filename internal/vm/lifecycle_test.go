@@ -0,0 +1,185 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// newFakeManager returns a manager running vagrant commands on a fake provider, with a created VM
+func newFakeManager(t *testing.T, name string) (*Manager, *cmdexec.FakeVagrant) {
+	t.Helper()
+	fake := cmdexec.NewFakeVagrant()
+	m, err := NewManagerWithRunner(filepath.Join(t.TempDir(), "vms"), fake)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := m.CreateVM(context.Background(), name, t.TempDir(), core.VMConfig{Box: "ubuntu/focal64", CPU: 2, Memory: 2048}); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+	return m, fake
+}
+
+func TestLifecycleWithFakeVagrant(t *testing.T) {
+	ctx := context.Background()
+	m, fake := newFakeManager(t, "lifecycle-vm")
+
+	expectState := func(expected core.VMState) {
+		t.Helper()
+		state, err := m.GetVMState(ctx, "lifecycle-vm")
+		if err != nil {
+			t.Fatalf("Failed to get VM state: %v", err)
+		}
+		if state != expected {
+			t.Fatalf("Expected state %s but got %s", expected, state)
+		}
+	}
+
+	expectState(core.NotCreated)
+	if err := m.StartVM(ctx, "lifecycle-vm"); err != nil {
+		t.Fatalf("Failed to start VM: %v", err)
+	}
+	expectState(core.Running)
+	if upLog, err := os.ReadFile(core.OperationLogPath(m.baseDir, "lifecycle-vm", core.OperationLogUp)); err != nil || !strings.Contains(string(upLog), "Booting VM...") {
+		t.Errorf("Expected the up log to hold the boot output but got %q (%v)", upLog, err)
+	}
+	if err := m.SuspendVM(ctx, "lifecycle-vm"); err != nil {
+		t.Fatalf("Failed to suspend VM: %v", err)
+	}
+	expectState(core.Suspended)
+	if err := m.StartVM(ctx, "lifecycle-vm"); err != nil {
+		t.Fatalf("Failed to resume VM: %v", err)
+	}
+	if err := m.StopVM(ctx, "lifecycle-vm"); err != nil {
+		t.Fatalf("Failed to stop VM: %v", err)
+	}
+	expectState(core.Stopped)
+	if _, err := m.ProvisionVM(ctx, "lifecycle-vm", nil); !errors.Is(err, errors.CodeInvalidState) {
+		t.Errorf("Expected provisioning a stopped VM to fail with an invalid state error but got %v", err)
+	}
+	if err := m.DestroyVM(ctx, "lifecycle-vm"); err != nil {
+		t.Fatalf("Failed to destroy VM: %v", err)
+	}
+	if _, err := os.Stat(m.getVMDir("lifecycle-vm")); !os.IsNotExist(err) {
+		t.Errorf("Expected the VM directory to be removed but got %v", err)
+	}
+	expectState(core.NotCreated)
+
+	var commands []string
+	for _, call := range fake.Calls() {
+		// Vagrantfile validation is skipped in CI
+		if call.Args[0] != "status" && call.Args[0] != "validate" {
+			commands = append(commands, call.Args[0])
+		}
+	}
+	expected := "up suspend up halt destroy"
+	if strings.Join(commands, " ") != expected {
+		t.Errorf("Expected vagrant commands %q but got %q", expected, strings.Join(commands, " "))
+	}
+}
+
+func TestStartVMFailureWithFakeVagrant(t *testing.T) {
+	m, fake := newFakeManager(t, "failing-vm")
+	fake.Script(cmdexec.FakeResponse{Args: []string{"up"}, Output: failedUpOutput, ExitCode: 1})
+
+	err := m.StartVM(context.Background(), "failing-vm")
+	if !errors.Is(err, errors.CodeVagrantError) {
+		t.Fatalf("Expected a vagrant error but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "The SSH command responded with a non-zero exit status") {
+		t.Errorf("Expected the cause vagrant reported in the error but got %q", err.Error())
+	}
+	if state := fake.State(m.getVMDir("failing-vm")); state != cmdexec.FakeStateNotCreated {
+		t.Errorf("Expected a failed up to leave the machine not created but got %s", state)
+	}
+	reports, reportErr := LoadBootReports(m.baseDir)
+	if reportErr != nil || len(reports) != 1 || reports[0].Success {
+		t.Errorf("Expected a failed boot report but got %+v (%v)", reports, reportErr)
+	}
+}
+
+func TestGetVMStateWithFakeVagrant(t *testing.T) {
+	testCases := []struct {
+		state    string
+		expected core.VMState
+	}{
+		{cmdexec.FakeStateRunning, core.Running},
+		{cmdexec.FakeStatePoweroff, core.Stopped},
+		{"aborted", core.Stopped},
+		{cmdexec.FakeStateSaved, core.Suspended},
+		{cmdexec.FakeStateNotCreated, core.NotCreated},
+	}
+	m, fake := newFakeManager(t, "state-vm")
+	for _, tc := range testCases {
+		t.Run(tc.state, func(t *testing.T) {
+			fake.SetState(m.getVMDir("state-vm"), tc.state)
+			state, err := m.GetVMState(context.Background(), "state-vm")
+			if err != nil {
+				t.Fatalf("Failed to get VM state: %v", err)
+			}
+			if state != tc.expected {
+				t.Errorf("Expected state %s but got %s", tc.expected, state)
+			}
+		})
+	}
+
+	fake.Script(cmdexec.FakeResponse{Args: []string{"status"}, Output: "1700000000,default,state,inaccessible\n", Times: 1})
+	if _, err := m.GetVMState(context.Background(), "state-vm"); err == nil {
+		t.Error("Expected an unknown vagrant state to be reported as an error")
+	}
+}
+
+func TestProvisionVMWithFakeVagrant(t *testing.T) {
+	m, fake := newFakeManager(t, "provision-vm")
+	fake.SetState(m.getVMDir("provision-vm"), cmdexec.FakeStateRunning)
+	fake.Script(cmdexec.FakeResponse{
+		Args: []string{"provision"},
+		Output: "1700000000,default,ui,info,==> default: Running provisioner: setup (shell)...\n" +
+			"1700000001,default,ui,output,    default: setup complete\n" +
+			"1700000002,default,ui,info,==> default: Running provisioner: migrate (shell)...\n" +
+			"1700000003,default,ui,error,    default: ERROR: relation \"users\" already exists\n" +
+			"1700000004,,error-exit,Vagrant::Errors::VagrantError,The SSH command responded with a non-zero exit status.\n",
+		ExitCode: 1,
+		Delay:    50 * time.Millisecond,
+	})
+
+	result, err := m.ProvisionVM(context.Background(), "provision-vm", []string{"setup", "migrate", "seed"})
+	if err != nil {
+		t.Fatalf("Expected the failure in the result but got %v", err)
+	}
+	if result.Success || result.ErrorType != "Vagrant::Errors::VagrantError" {
+		t.Errorf("Expected a failed result with the vagrant error type but got %+v", result)
+	}
+	var statuses []string
+	for _, provisioner := range result.Provisioners {
+		statuses = append(statuses, provisioner.Status)
+	}
+	expected := []string{ProvisionerSucceeded, ProvisionerFailed, ProvisionerNotRun}
+	if strings.Join(statuses, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected provisioner statuses %v but got %v", expected, statuses)
+	}
+	if calls := fake.Calls(); strings.Join(calls[len(calls)-1].Args, " ") != "provision --machine-readable --provision-with setup,migrate,seed" {
+		t.Errorf("Expected the provisioners to be passed to vagrant but got %q", calls[len(calls)-1].Args)
+	}
+}
+
+func TestStartVMCancelledWithFakeVagrant(t *testing.T) {
+	m, fake := newFakeManager(t, "slow-vm")
+	fake.Script(cmdexec.FakeResponse{Args: []string{"up"}, Output: "1700000000,default,ui,info,==> default: Booting VM...\n", Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.StartVM(ctx, "slow-vm"); err == nil {
+		t.Fatal("Expected a cancelled start to fail")
+	}
+	if state := fake.State(m.getVMDir("slow-vm")); state != cmdexec.FakeStateNotCreated {
+		t.Errorf("Expected a cancelled up to leave the machine not created but got %s", state)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lint finding severities
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	LintSeverityInfo    = "info"
+)
+
+// LintFinding is a problem found in a Vagrantfile
+type LintFinding struct {
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity"`
+	Line       int    `json:"line,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+// vagrantStatement is a logical Vagrantfile statement, joined across continuation lines
+type vagrantStatement struct {
+	line int
+	text string
+}
+
+// deprecatedOption describes a Vagrant v1 or provider option with a modern replacement
+type deprecatedOption struct {
+	pattern    *regexp.Regexp
+	severity   string
+	message    string
+	suggestion string
+}
+
+var (
+	boxPattern           = regexp.MustCompile(`\bconfig\.vm\.box\s*=`)
+	boxVersionPattern    = regexp.MustCompile(`\bconfig\.vm\.box_version\s*=`)
+	forwardedPortPattern = regexp.MustCompile(`\.network\s*\(?\s*["':]forwarded_port`)
+	hostPortPattern      = regexp.MustCompile(`(?:\bhost:|:host\s*=>)\s*(\d+)`)
+	hostIPPattern        = regexp.MustCompile(`(?:\bhost_ip:|:host_ip\s*=>)\s*["']([^"']*)["']`)
+	syncedFolderPattern  = regexp.MustCompile(`\.synced_folder\s*\(?\s*["']([^"']*)["']`)
+	worldWritablePattern = regexp.MustCompile(`\b[dfu]?mode=0?777\b|\bumask=0+\b`)
+	smbPasswordPattern   = regexp.MustCompile(`\bsmb_password\s*(?::|=>)\s*["'][^"']+["']`)
+	nfsInsecurePattern   = regexp.MustCompile(`\bno_root_squash\b|\binsecure\b`)
+	insertKeyPattern     = regexp.MustCompile(`\bconfig\.ssh\.insert_key\s*=\s*false\b`)
+	passwordAuthPattern  = regexp.MustCompile(`\bconfig\.ssh\.password\s*=`)
+)
+
+// deprecatedOptions lists options Vagrant has deprecated or replaced
+var deprecatedOptions = []deprecatedOption{
+	{regexp.MustCompile(`\bVagrant::Config\.run\b`), LintSeverityWarning, "Vagrant 1.0 configuration syntax", `Use Vagrant.configure("2") do |config| ... end`},
+	{regexp.MustCompile(`\bVagrant\.configure\s*\(\s*["']1["']`), LintSeverityWarning, "Configuration version 1 is deprecated", `Use Vagrant.configure("2")`},
+	{regexp.MustCompile(`\bconfig\.vm\.forward_port\b`), LintSeverityWarning, "config.vm.forward_port is Vagrant 1.0 syntax", `Use config.vm.network "forwarded_port", guest: ..., host: ...`},
+	{regexp.MustCompile(`\bconfig\.vm\.share_folder\b`), LintSeverityWarning, "config.vm.share_folder is Vagrant 1.0 syntax", `Use config.vm.synced_folder "host/path", "/guest/path"`},
+	{regexp.MustCompile(`\bconfig\.vm\.customize\b`), LintSeverityWarning, "config.vm.customize is Vagrant 1.0 syntax", `Use vb.customize inside a config.vm.provider "virtualbox" block`},
+	{regexp.MustCompile(`\bconfig\.vm\.host_name\b`), LintSeverityWarning, "config.vm.host_name is Vagrant 1.0 syntax", "Use config.vm.hostname"},
+	{regexp.MustCompile(`\bconfig\.vm\.boot_mode\b`), LintSeverityWarning, "config.vm.boot_mode is Vagrant 1.0 syntax", "Use vb.gui = true inside the virtualbox provider block"},
+	{regexp.MustCompile(`\bconfig\.ssh\.max_tries\b`), LintSeverityWarning, "config.ssh.max_tries is no longer supported", "Use config.vm.boot_timeout"},
+	{regexp.MustCompile(`\bconfig\.ssh\.timeout\b`), LintSeverityWarning, "config.ssh.timeout is no longer supported", "Use config.vm.boot_timeout"},
+	{regexp.MustCompile(`customize\s*\[\s*["']modifyvm["']\s*,\s*:id\s*,\s*["']--memory["']`), LintSeverityInfo, "Setting memory through modifyvm is superseded by the provider option", "Use vb.memory = <MB>"},
+	{regexp.MustCompile(`customize\s*\[\s*["']modifyvm["']\s*,\s*:id\s*,\s*["']--cpus["']`), LintSeverityInfo, "Setting CPUs through modifyvm is superseded by the provider option", "Use vb.cpus = <count>"},
+	{regexp.MustCompile(`customize\s*\[\s*["']modifyvm["']\s*,\s*:id\s*,\s*["']--name["']`), LintSeverityInfo, "Setting the VM name through modifyvm is superseded by the provider option", `Use vb.name = "<name>"`},
+}
+
+// LintVagrantfile checks a Vagrantfile for problems vagrant validate does not report.
+// otherVMPorts maps other managed VMs to the host ports they forward.
+func LintVagrantfile(content string, otherVMPorts map[string][]int) []LintFinding {
+	findings := []LintFinding{}
+	statements := splitVagrantStatements(content)
+
+	hasBox, hasBoxVersion := false, false
+	boxLine := 0
+	hostPorts := make(map[int]int)
+	for _, statement := range statements {
+		text := statement.text
+
+		if boxPattern.MatchString(text) {
+			hasBox = true
+			boxLine = statement.line
+		}
+		if boxVersionPattern.MatchString(text) {
+			hasBoxVersion = true
+		}
+
+		for _, option := range deprecatedOptions {
+			if option.pattern.MatchString(text) {
+				findings = append(findings, LintFinding{
+					Rule:       "deprecated_option",
+					Severity:   option.severity,
+					Line:       statement.line,
+					Message:    option.message,
+					Suggestion: option.suggestion,
+				})
+			}
+		}
+
+		if insertKeyPattern.MatchString(text) {
+			findings = append(findings, LintFinding{
+				Rule:       "insecure_ssh_key",
+				Severity:   LintSeverityWarning,
+				Line:       statement.line,
+				Message:    "config.ssh.insert_key = false keeps the publicly known insecure Vagrant key",
+				Suggestion: "Remove the setting so Vagrant replaces the insecure key on first boot",
+			})
+		}
+		if passwordAuthPattern.MatchString(text) {
+			findings = append(findings, LintFinding{
+				Rule:       "ssh_password",
+				Severity:   LintSeverityWarning,
+				Line:       statement.line,
+				Message:    "SSH password authentication is configured in plain text",
+				Suggestion: "Use key-based authentication instead of config.ssh.password",
+			})
+		}
+
+		if syncedFolderPattern.MatchString(text) {
+			findings = append(findings, lintSyncedFolder(statement)...)
+		}
+
+		if forwardedPortPattern.MatchString(text) {
+			match := hostPortPattern.FindStringSubmatch(text)
+			if match == nil {
+				continue
+			}
+			port, _ := strconv.Atoi(match[1])
+
+			if firstLine, seen := hostPorts[port]; seen {
+				findings = append(findings, LintFinding{
+					Rule:       "port_collision",
+					Severity:   LintSeverityError,
+					Line:       statement.line,
+					Message:    fmt.Sprintf("Host port %d is already forwarded on line %d", port, firstLine),
+					Suggestion: "Use a different host port or add auto_correct: true",
+				})
+			} else {
+				hostPorts[port] = statement.line
+			}
+
+			for _, vmName := range sortedKeys(otherVMPorts) {
+				for _, otherPort := range otherVMPorts[vmName] {
+					if otherPort == port {
+						findings = append(findings, LintFinding{
+							Rule:       "port_collision",
+							Severity:   LintSeverityError,
+							Line:       statement.line,
+							Message:    fmt.Sprintf("Host port %d is also forwarded by VM '%s'", port, vmName),
+							Suggestion: "Use a different host port or add auto_correct: true",
+						})
+					}
+				}
+			}
+
+			if ip := hostIPPattern.FindStringSubmatch(text); ip == nil || ip[1] == "0.0.0.0" || ip[1] == "" {
+				findings = append(findings, LintFinding{
+					Rule:       "port_exposed",
+					Severity:   LintSeverityWarning,
+					Line:       statement.line,
+					Message:    fmt.Sprintf("Host port %d is reachable from other machines on the network", port),
+					Suggestion: `Add host_ip: "127.0.0.1" to bind the port to localhost only`,
+				})
+			}
+		}
+	}
+
+	if !hasBox {
+		findings = append(findings, LintFinding{
+			Rule:       "missing_box",
+			Severity:   LintSeverityError,
+			Message:    "No box is configured",
+			Suggestion: `Set config.vm.box, e.g. config.vm.box = "ubuntu/focal64"`,
+		})
+	} else if !hasBoxVersion {
+		findings = append(findings, LintFinding{
+			Rule:       "box_version_unpinned",
+			Severity:   LintSeverityWarning,
+			Line:       boxLine,
+			Message:    "The box version is not pinned, so new VMs may boot a different image",
+			Suggestion: `Pin the version with config.vm.box_version = "<version>"`,
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// lintSyncedFolder checks a synced_folder statement for insecure options
+func lintSyncedFolder(statement vagrantStatement) []LintFinding {
+	var findings []LintFinding
+	text := statement.text
+
+	if match := syncedFolderPattern.FindStringSubmatch(text); match != nil {
+		hostPath := strings.TrimRight(match[1], "/")
+		if hostPath == "" || hostPath == "~" || hostPath == "/" {
+			findings = append(findings, LintFinding{
+				Rule:       "synced_folder_too_broad",
+				Severity:   LintSeverityError,
+				Line:       statement.line,
+				Message:    fmt.Sprintf("Synced folder %q exposes the whole host file system or home directory to the guest", match[1]),
+				Suggestion: "Sync only the project directory",
+			})
+		}
+	}
+	if worldWritablePattern.MatchString(text) {
+		findings = append(findings, LintFinding{
+			Rule:       "synced_folder_world_writable",
+			Severity:   LintSeverityWarning,
+			Line:       statement.line,
+			Message:    "Synced folder is mounted world-writable",
+			Suggestion: `Use restrictive mount options such as mount_options: ["dmode=755", "fmode=644"]`,
+		})
+	}
+	if smbPasswordPattern.MatchString(text) {
+		findings = append(findings, LintFinding{
+			Rule:       "synced_folder_plaintext_password",
+			Severity:   LintSeverityError,
+			Line:       statement.line,
+			Message:    "SMB password is stored in plain text in the Vagrantfile",
+			Suggestion: "Remove smb_password and let Vagrant prompt for credentials, or read it from an environment variable",
+		})
+	}
+	if strings.Contains(text, "nfs") && nfsInsecurePattern.MatchString(text) {
+		findings = append(findings, LintFinding{
+			Rule:       "synced_folder_insecure_nfs",
+			Severity:   LintSeverityWarning,
+			Line:       statement.line,
+			Message:    "NFS export uses no_root_squash or insecure options",
+			Suggestion: "Remove no_root_squash and insecure from the NFS options",
+		})
+	}
+	return findings
+}
+
+// ForwardedHostPorts returns the host ports forwarded by a Vagrantfile
+func ForwardedHostPorts(content string) []int {
+	var ports []int
+	for _, statement := range splitVagrantStatements(content) {
+		if !forwardedPortPattern.MatchString(statement.text) {
+			continue
+		}
+		if match := hostPortPattern.FindStringSubmatch(statement.text); match != nil {
+			if port, err := strconv.Atoi(match[1]); err == nil {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+// splitVagrantStatements strips comments and joins lines continued with a trailing comma or backslash
+func splitVagrantStatements(content string) []vagrantStatement {
+	var statements []vagrantStatement
+	var current *vagrantStatement
+	for i, raw := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(raw)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current != nil {
+			current.text += " " + line
+		} else if line != "" {
+			statements = append(statements, vagrantStatement{line: i + 1, text: line})
+			current = &statements[len(statements)-1]
+		}
+		if current != nil && !strings.HasSuffix(line, ",") && !strings.HasSuffix(line, "\\") {
+			current = nil
+		}
+	}
+	return statements
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string][]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"testing"
+)
+
+func TestLintVagrantfile(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		otherVMPorts  map[string][]int
+		expectedRules []string
+	}{
+		{
+			name: "pinned box with localhost port",
+			content: `Vagrant.configure("2") do |config|
+  config.vm.box = "ubuntu/focal64"
+  config.vm.box_version = "20240821.0.1"
+  config.vm.network "forwarded_port", guest: 80, host: 8080, host_ip: "127.0.0.1"
+end`,
+			expectedRules: []string{},
+		},
+		{
+			name: "unpinned box",
+			content: `Vagrant.configure("2") do |config|
+  config.vm.box = "ubuntu/focal64"
+end`,
+			expectedRules: []string{"box_version_unpinned"},
+		},
+		{
+			name: "missing box",
+			content: `Vagrant.configure("2") do |config|
+  # config.vm.box = "ubuntu/focal64"
+end`,
+			expectedRules: []string{"missing_box"},
+		},
+		{
+			name: "insecure multi-line synced folder",
+			content: `Vagrant.configure("2") do |config|
+  config.vm.box = "base"
+  config.vm.box_version = "1.0"
+  config.vm.synced_folder "/", "/host",
+    type: "smb",
+    smb_password: "hunter2",
+    mount_options: ["dmode=777", "fmode=777"]
+end`,
+			expectedRules: []string{"synced_folder_too_broad", "synced_folder_world_writable", "synced_folder_plaintext_password"},
+		},
+		{
+			name: "port collisions and exposure",
+			content: `Vagrant.configure("2") do |config|
+  config.vm.box = "base"
+  config.vm.box_version = "1.0"
+  config.vm.network "forwarded_port", guest: 80, host: 8080, host_ip: "127.0.0.1"
+  config.vm.network :forwarded_port, guest: 81, host: 8080, host_ip: "127.0.0.1"
+  config.vm.network "forwarded_port", guest: 5432, host: 5432
+end`,
+			otherVMPorts:  map[string][]int{"db": {5432}},
+			expectedRules: []string{"port_collision", "port_collision", "port_exposed"},
+		},
+		{
+			name: "deprecated options",
+			content: `Vagrant::Config.run do |config|
+  config.vm.box = "base"
+  config.vm.box_version = "1.0"
+  config.vm.forward_port 80, 8080
+  config.ssh.insert_key = false
+end`,
+			expectedRules: []string{"deprecated_option", "deprecated_option", "insecure_ssh_key"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := LintVagrantfile(tc.content, tc.otherVMPorts)
+			if len(findings) != len(tc.expectedRules) {
+				t.Fatalf("Expected %d findings but got %d: %+v", len(tc.expectedRules), len(findings), findings)
+			}
+			for i, finding := range findings {
+				if finding.Rule != tc.expectedRules[i] {
+					t.Errorf("Expected finding %d to be %s but got %s (%s)", i, tc.expectedRules[i], finding.Rule, finding.Message)
+				}
+				if finding.Suggestion == "" {
+					t.Errorf("Expected a suggestion for %s", finding.Rule)
+				}
+			}
+		})
+	}
+}
+
+func TestForwardedHostPorts(t *testing.T) {
+	content := `config.vm.network "forwarded_port", guest: 3000, host: 3000
+# config.vm.network "forwarded_port", guest: 22, host: 2222
+config.vm.network "forwarded_port", :guest => 80, :host => 8080
+config.vm.network "private_network", ip: "192.168.56.10"`
+
+	ports := ForwardedHostPorts(content)
+	if len(ports) != 2 || ports[0] != 3000 || ports[1] != 8080 {
+		t.Errorf("Expected [3000 8080] but got %v", ports)
+	}
+}
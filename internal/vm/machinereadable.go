@@ -0,0 +1,200 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Types of machine-readable output lines
+const (
+	MachineTypeUI        = "ui"
+	MachineTypeAction    = "action"
+	MachineTypeErrorExit = "error-exit"
+	MachineTypeMetadata  = "metadata"
+)
+
+// machineReadableFlag makes vagrant print one "timestamp,target,type,data..." line per event
+const machineReadableFlag = "--machine-readable"
+
+// MachineLine is a line of vagrant's machine-readable output
+type MachineLine struct {
+	Timestamp time.Time
+	// Target is the machine the line is about; empty for the whole command
+	Target string
+	Type   string
+	Data   []string
+}
+
+// VagrantAction is a machine action vagrant started or finished, e.g. up or halt
+type VagrantAction struct {
+	Target string    `json:"target,omitempty"`
+	Action string    `json:"action"`
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// VagrantError is the cause vagrant reports when a command fails
+type VagrantError struct {
+	// Type is vagrant's error class, e.g. Vagrant::Errors::VMBootBadState
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// VagrantOutput is the parsed output of a vagrant command run with --machine-readable
+type VagrantOutput struct {
+	// Text is the output as vagrant prints it without --machine-readable
+	Text     string          `json:"-"`
+	Actions  []VagrantAction `json:"actions,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Errors   []string        `json:"errors,omitempty"`
+	Error    *VagrantError   `json:"error,omitempty"`
+}
+
+// ParseMachineLine parses a line of machine-readable output. Lines that are not
+// machine-readable, such as output plugins write directly, are reported as not ok.
+func ParseMachineLine(line string) (MachineLine, bool) {
+	parts := strings.Split(strings.TrimRight(line, "\r"), ",")
+	if len(parts) < 3 {
+		return MachineLine{}, false
+	}
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || parts[2] == "" {
+		return MachineLine{}, false
+	}
+	data := make([]string, 0, len(parts)-3)
+	for _, field := range parts[3:] {
+		data = append(data, unescapeMachineField(field))
+	}
+	return MachineLine{
+		Timestamp: time.Unix(seconds, 0).UTC(),
+		Target:    parts[1],
+		Type:      parts[2],
+		Data:      data,
+	}, true
+}
+
+// unescapeMachineField restores the commas and line breaks vagrant escapes in data fields
+func unescapeMachineField(field string) string {
+	field = strings.ReplaceAll(field, "%!(VAGRANT_COMMA)", ",")
+	field = strings.ReplaceAll(field, `\n`, "\n")
+	return strings.ReplaceAll(field, `\r`, "")
+}
+
+// humanText returns the text a machine-readable line stands for in vagrant's normal output,
+// and whether it has any
+func (l MachineLine) humanText() (string, bool) {
+	switch l.Type {
+	case MachineTypeUI:
+		if len(l.Data) < 2 {
+			return "", false
+		}
+		return strings.TrimRight(l.Data[1], "\n"), true
+	case MachineTypeErrorExit:
+		if len(l.Data) < 2 {
+			return "", false
+		}
+		return strings.TrimRight(l.Data[1], "\n"), true
+	}
+	return "", false
+}
+
+// ParseVagrantOutput parses the output of a vagrant command run with --machine-readable
+func ParseVagrantOutput(output string) VagrantOutput {
+	var parsed VagrantOutput
+	var text strings.Builder
+	for _, raw := range strings.Split(output, "\n") {
+		line, ok := ParseMachineLine(raw)
+		if !ok {
+			if strings.TrimSpace(raw) != "" {
+				text.WriteString(strings.TrimRight(raw, "\r") + "\n")
+			}
+			continue
+		}
+		if message, ok := line.humanText(); ok {
+			text.WriteString(message + "\n")
+		}
+		switch line.Type {
+		case MachineTypeUI:
+			if len(line.Data) < 2 {
+				continue
+			}
+			message := strings.TrimSpace(line.Data[1])
+			switch line.Data[0] {
+			case "warn":
+				parsed.Warnings = append(parsed.Warnings, message)
+			case "error":
+				parsed.Errors = append(parsed.Errors, message)
+			}
+		case MachineTypeAction:
+			if len(line.Data) < 2 {
+				continue
+			}
+			parsed.Actions = append(parsed.Actions, VagrantAction{Target: line.Target, Action: line.Data[0], Status: line.Data[1], At: line.Timestamp})
+		case MachineTypeErrorExit:
+			if len(line.Data) < 2 {
+				continue
+			}
+			parsed.Error = &VagrantError{Type: line.Data[0], Message: strings.TrimSpace(line.Data[1])}
+		}
+	}
+	parsed.Text = text.String()
+	return parsed
+}
+
+// humanTimedLines returns timed machine-readable output as the lines vagrant prints without
+// --machine-readable, keeping the time each line arrived
+func humanTimedLines(lines []timedLine) []timedLine {
+	human := make([]timedLine, 0, len(lines))
+	for _, l := range lines {
+		line, ok := ParseMachineLine(l.text)
+		if !ok {
+			human = append(human, l)
+			continue
+		}
+		text, ok := line.humanText()
+		if !ok {
+			continue
+		}
+		for _, part := range strings.Split(text, "\n") {
+			human = append(human, timedLine{at: l.at, text: part})
+		}
+	}
+	return human
+}
+
+// Cause returns the reason a vagrant command failed: the error vagrant exited with, else the
+// last error it printed, else the last line of its output
+func (o VagrantOutput) Cause() string {
+	if o.Error != nil && o.Error.Message != "" {
+		return o.Error.Message
+	}
+	if len(o.Errors) > 0 {
+		return o.Errors[len(o.Errors)-1]
+	}
+	lines := strings.Split(strings.TrimSpace(o.Text), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// vagrantFailure returns the error of a failed vagrant command, with the cause vagrant reported
+// as its message and the error type and warnings as context
+func vagrantFailure(err error, operation string, output VagrantOutput) *errors.AppError {
+	message := fmt.Sprintf("failed to %s VM", operation)
+	if cause := output.Cause(); cause != "" {
+		message += ": " + cause
+	}
+	appErr := errors.Wrap(err, errors.CodeVagrantError, message).WithContext("operation", operation)
+	if output.Error != nil {
+		appErr.WithContext("vagrant_error", output.Error.Type)
+	}
+	if len(output.Warnings) > 0 {
+		appErr.WithContext("warnings", output.Warnings)
+	}
+	return appErr
+}
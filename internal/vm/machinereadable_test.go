@@ -0,0 +1,135 @@
+package vm
+
+import (
+	stderrors "errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+const failedUpOutput = `1700000000,default,metadata,provider,virtualbox
+1700000000,default,action,up,start
+1700000001,default,ui,info,Bringing machine 'default' up with 'virtualbox' provider...
+1700000002,default,ui,output,==> default: Booting VM...
+1700000003,default,ui,warn,Vagrant is currently configured to create VirtualBox synced folders with\n` + "`SharedFoldersEnableSymlinksCreate`" + ` enabled.
+1700000090,default,ui,info,==> default: Running provisioner: setup (shell)...
+plugin output written directly to stderr
+1700000095,default,ui,error,==> default: E: Unable to locate package nodjs
+1700000095,,error-exit,Vagrant::Errors::VagrantError,The SSH command responded with a non-zero exit status. Vagrant%!(VAGRANT_COMMA) assumes that this means the command failed.\n
+`
+
+func TestParseMachineLine(t *testing.T) {
+	testCases := []struct {
+		name     string
+		line     string
+		ok       bool
+		expected MachineLine
+	}{
+		{
+			name:     "ui line",
+			line:     "1700000002,default,ui,output,==> default: Booting VM...",
+			ok:       true,
+			expected: MachineLine{Timestamp: time.Unix(1700000002, 0).UTC(), Target: "default", Type: "ui", Data: []string{"output", "==> default: Booting VM..."}},
+		},
+		{
+			name:     "escaped commas and line breaks",
+			line:     `1700000003,,error-exit,Vagrant::Errors::X,one%!(VAGRANT_COMMA) two\nthree`,
+			ok:       true,
+			expected: MachineLine{Timestamp: time.Unix(1700000003, 0).UTC(), Type: "error-exit", Data: []string{"Vagrant::Errors::X", "one, two\nthree"}},
+		},
+		{name: "human output", line: "==> default: Booting VM...", ok: false},
+		{name: "empty line", line: "", ok: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			line, ok := ParseMachineLine(tc.line)
+			if ok != tc.ok {
+				t.Fatalf("Expected ok %v but got %v", tc.ok, ok)
+			}
+			if ok && !reflect.DeepEqual(line, tc.expected) {
+				t.Errorf("Expected %+v but got %+v", tc.expected, line)
+			}
+		})
+	}
+}
+
+func TestParseVagrantOutput(t *testing.T) {
+	output := ParseVagrantOutput(failedUpOutput)
+
+	expectedText := "Bringing machine 'default' up with 'virtualbox' provider...\n" +
+		"==> default: Booting VM...\n" +
+		"Vagrant is currently configured to create VirtualBox synced folders with\n`SharedFoldersEnableSymlinksCreate` enabled.\n" +
+		"==> default: Running provisioner: setup (shell)...\n" +
+		"plugin output written directly to stderr\n" +
+		"==> default: E: Unable to locate package nodjs\n" +
+		"The SSH command responded with a non-zero exit status. Vagrant, assumes that this means the command failed.\n"
+	if output.Text != expectedText {
+		t.Errorf("Expected text %q but got %q", expectedText, output.Text)
+	}
+	if len(output.Actions) != 1 || output.Actions[0].Action != "up" || output.Actions[0].Status != "start" || output.Actions[0].Target != "default" {
+		t.Errorf("Expected the up action to have started but got %+v", output.Actions)
+	}
+	if len(output.Warnings) != 1 || !strings.HasPrefix(output.Warnings[0], "Vagrant is currently configured") {
+		t.Errorf("Expected one warning but got %q", output.Warnings)
+	}
+	if !reflect.DeepEqual(output.Errors, []string{"==> default: E: Unable to locate package nodjs"}) {
+		t.Errorf("Expected one error but got %q", output.Errors)
+	}
+	if output.Error == nil || output.Error.Type != "Vagrant::Errors::VagrantError" {
+		t.Fatalf("Expected the error vagrant exited with but got %+v", output.Error)
+	}
+	if cause := output.Cause(); cause != "The SSH command responded with a non-zero exit status. Vagrant, assumes that this means the command failed." {
+		t.Errorf("Expected the error-exit message as the cause but got %q", cause)
+	}
+}
+
+func TestVagrantOutputCause(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{"last error", "1,default,ui,error,first\n2,default,ui,error,second\n", "second"},
+		{"last line", "1,default,ui,info,Booting VM...\nKilled\n", "Killed"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if cause := ParseVagrantOutput(tc.output).Cause(); cause != tc.expected {
+				t.Errorf("Expected cause %q but got %q", tc.expected, cause)
+			}
+		})
+	}
+}
+
+func TestVagrantFailure(t *testing.T) {
+	err := vagrantFailure(stderrors.New("exit status 1"), "start", ParseVagrantOutput(failedUpOutput))
+	if !errors.Is(err, errors.CodeVagrantError) {
+		t.Errorf("Expected a vagrant error but got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "failed to start VM: The SSH command responded with a non-zero exit status.") {
+		t.Errorf("Expected the cause in the message but got %q", err.Error())
+	}
+	if err.Context["vagrant_error"] != "Vagrant::Errors::VagrantError" || len(err.Context["warnings"].([]string)) != 1 {
+		t.Errorf("Expected the error type and warnings as context but got %v", err.Context)
+	}
+}
+
+func TestHumanTimedLines(t *testing.T) {
+	at := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	lines := humanTimedLines([]timedLine{
+		{at, "1700000000,default,action,up,start"},
+		{at, "1700000001,default,ui,info,==> default: Running provisioner: shell...\\n    default: Running: inline script"},
+		{at, "raw line"},
+	})
+	var texts []string
+	for _, line := range lines {
+		texts = append(texts, line.text)
+	}
+	expected := []string{"==> default: Running provisioner: shell...", "    default: Running: inline script", "raw line"}
+	if !reflect.DeepEqual(texts, expected) {
+		t.Errorf("Expected %q but got %q", expected, texts)
+	}
+}
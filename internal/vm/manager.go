@@ -4,17 +4,24 @@
 package vm
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/artifacts"
 	"github.com/vagrant-mcp/server/internal/cmdexec"
 	"github.com/vagrant-mcp/server/internal/core"
 	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/events"
+	"github.com/vagrant-mcp/server/internal/hostos"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+	"github.com/vagrant-mcp/server/internal/tracing"
 	"github.com/vagrant-mcp/server/internal/utils"
 )
 
@@ -22,7 +29,19 @@ import (
 
 // Manager handles VM lifecycle operations
 type Manager struct {
-	baseDir string
+	baseDir    string
+	operations operationLocks
+	// configWrites serialises config saves so a revision is compared and bumped at once
+	configWrites sync.Mutex
+	// runner runs vagrant commands; nil runs the Vagrant CLI
+	runner cmdexec.VagrantRunner
+	// hostRunner runs other host commands, such as VBoxManage, and returns their combined
+	// output; nil runs them directly
+	hostRunner func(ctx context.Context, name string, args ...string) (string, error)
+	// sshConfigs caches the ssh-config syncs connect to VMs with
+	sshConfigs sshConfigCache
+	// mountChecks caches the healthy mount checks of VMs with mounted synced folders
+	mountChecks mountCheckCache
 }
 
 // NewManager creates a new VM manager
@@ -42,90 +61,287 @@ func NewManager() (*Manager, error) {
 		baseDir = filepath.Join(homeDir, ".vagrant-mcp", "vms")
 	}
 
+	return NewManagerWithRunner(baseDir, cmdexec.CLIRunner{})
+}
+
+// NewManagerWithRunner creates a VM manager for the VMs under baseDir that runs vagrant
+// commands with runner, e.g. a cmdexec.FakeVagrant in tests
+func NewManagerWithRunner(baseDir string, runner cmdexec.VagrantRunner) (*Manager, error) {
 	// Ensure the base directory exists
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create VM base directory: %w", err)
 	}
 
+	if migrated, err := MigrateVMConfigs(baseDir); err != nil {
+		return nil, fmt.Errorf("failed to migrate VM configurations: %w", err)
+	} else if migrated > 0 {
+		log.Info().Int("count", migrated).Str("base_dir", baseDir).Msg("Moved VM configurations into their VM directories")
+	}
+
 	return &Manager{
 		baseDir: baseDir,
+		runner:  runner,
 	}, nil
 }
 
 // CreateVM creates a new Vagrant VM with the given configuration
 func (m *Manager) CreateVM(ctx context.Context, name string, projectPath string, config core.VMConfig) error {
+	if err := ValidateDisks(config); err != nil {
+		return err
+	}
+	if config.DiskSizeGB > 0 || len(config.Disks) > 0 {
+		if err := GlobalCapabilities.Require(ctx, FeatureDisks); err != nil {
+			return err
+		}
+	}
+	if err := ValidateProxy(config); err != nil {
+		return err
+	}
+	if err := ValidateCloudInit(config); err != nil {
+		return err
+	}
+	if err := ValidateFirewall(config); err != nil {
+		return err
+	}
+	if err := ValidateSnippets(config); err != nil {
+		return err
+	}
+	if err := ValidateTemplate(config); err != nil {
+		return err
+	}
+	// Step scripts are relative to the project
+	config.ProjectPath = projectPath
+	if err := ValidateProvisionSteps(config); err != nil {
+		return err
+	}
+	if config.Network != "" {
+		if err := ValidateNetworkName(config.Network); err != nil {
+			return err
+		}
+	}
+	release, err := m.operations.acquire(name, OperationCreate)
+	if err != nil {
+		return err
+	}
+	defer release()
 	vmDir := m.getVMDir(name)
 	if err := os.MkdirAll(vmDir, 0755); err != nil {
 		return errors.OperationFailed("create VM directory", err)
 	}
 	config.Name = name
 	config.ProjectPath = projectPath
-	if err := m.saveVMConfig(name, config); err != nil {
+	platform, err := SelectPlatform(HostPlatform(), config.Provider, config.Box)
+	if err != nil {
+		return err
+	}
+	config.Provider = platform.Provider
+	if config.Box == "" {
+		config.Box = m.DefaultBoxFor(platform)
+	}
+	if err := ValidateSyncProvider(config); err != nil {
+		return err
+	}
+	createdAt := time.Now()
+	config.CreatedAt = &createdAt
+	if err := m.replaceVMConfig(name, &config); err != nil {
 		return errors.OperationFailed("save VM configuration", err)
 	}
+	if err := m.ensureSMBCredentials(name, config); err != nil {
+		return errors.OperationFailed("save SMB credentials", err)
+	}
+	if config.Network != "" {
+		if _, err := m.joinNetwork(config.Network, []string{name}); err != nil {
+			return err
+		}
+	}
 	if err := m.generateVagrantfile(name, config); err != nil {
 		return errors.OperationFailed("generate Vagrantfile", err)
 	}
+	if config.Network != "" {
+		// The other members learn the new VM's hostname
+		if err := m.refreshNetworkMembers(ctx, config.Network, []string{name}); err != nil {
+			log.Warn().Err(err).Str("name", name).Str("network", config.Network).Msg("Failed to update network members")
+		}
+	}
 	log.Info().Str("name", name).Msg("VM created successfully")
+	publishStateChange(name, "create", core.NotCreated)
 	return nil
 }
 
 // StartVM starts the specified VM
 func (m *Manager) StartVM(ctx context.Context, name string) error {
-	vmDir := m.getVMDir(name)
-	cmd := exec.CommandContext(ctx, "vagrant", "up")
-	cmd.Dir = vmDir
-	output, err := cmd.CombinedOutput()
+	release, err := m.operations.acquire(name, OperationUp)
 	if err != nil {
-		return errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to start VM: %s", output))
+		return err
+	}
+	defer release()
+	if output, err := m.runUp(ctx, name, "up"); err != nil {
+		publishOperationFailure(name, "start", output)
+		return vagrantFailure(err, "start", output)
 	}
 	log.Info().Str("name", name).Msg("VM started successfully")
+	publishStateChange(name, "start", core.Running)
+	if err := m.VerifySyncMount(ctx, name); err != nil {
+		return errors.OperationFailed("verify synced folder", fmt.Errorf("VM started but %w", err))
+	}
+	return nil
+}
+
+// ReloadVM restarts the specified VM with 'vagrant reload' so Vagrantfile changes take effect
+func (m *Manager) ReloadVM(ctx context.Context, name string) error {
+	release, err := m.operations.acquire(name, OperationReload)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return m.reloadVM(ctx, name)
+}
+
+// reloadVM reloads a VM on behalf of an operation that already holds its lock
+func (m *Manager) reloadVM(ctx context.Context, name string) error {
+	if output, err := m.runUp(ctx, name, "reload"); err != nil {
+		publishOperationFailure(name, "reload", output)
+		return vagrantFailure(err, "reload", output)
+	}
+	log.Info().Str("name", name).Msg("VM reloaded successfully")
+	publishStateChange(name, "reload", core.Running)
+	m.forgetSyncMount(name)
+	if err := m.VerifySyncMount(ctx, name); err != nil {
+		return errors.OperationFailed("verify synced folder", fmt.Errorf("VM reloaded but %w", err))
+	}
 	return nil
 }
 
+// runUp runs a vagrant command that boots the VM, 'up' or 'reload', recording its
+// transcript as the up log and its phases as a boot report
+func (m *Manager) runUp(ctx context.Context, name string, command string) (VagrantOutput, error) {
+	vmDir := m.getVMDir(name)
+	started := time.Now()
+	box := ""
+	args := []string{command, machineReadableFlag}
+	if config, configErr := m.GetVMConfig(ctx, name); configErr == nil {
+		box = config.Box
+		// The provider chosen at creation wins over VAGRANT_DEFAULT_PROVIDER
+		if command == "up" && config.Provider != "" {
+			args = append(args, "--provider", config.Provider)
+		}
+	}
+	ctx, span := traceVagrant(ctx, name, command)
+	args = m.vagrantArgs(name, args...)
+	// Timestamp output lines as they arrive to measure the boot phases
+	timed := &timedOutput{}
+	err := m.vagrant().Run(ctx, cmdexec.VagrantCommand{Dir: vmDir, Args: args, Stdout: timed, Stderr: timed})
+	span.EndCommand(err)
+	output := ParseVagrantOutput(string(timed.Bytes()))
+	m.recordUpLogs(name, cmdexec.CommandLine(args), started, []byte(output.Text), err)
+	m.recordBootReport(name, box, started, timed, err)
+	return output, err
+}
+
 // StopVM stops the specified VM
 func (m *Manager) StopVM(ctx context.Context, name string) error {
+	release, err := m.operations.acquire(name, OperationHalt)
+	if err != nil {
+		return err
+	}
+	defer release()
 	vmDir := m.getVMDir(name)
-	cmd := exec.CommandContext(ctx, "vagrant", "halt")
-	cmd.Dir = vmDir
-	output, err := cmd.CombinedOutput()
+	started := time.Now()
+	ctx, span := traceVagrant(ctx, name, "halt")
+	args := m.vagrantArgs(name, "halt", machineReadableFlag)
+	combined, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vmDir, args...)
+	span.EndCommand(err)
+	output := ParseVagrantOutput(string(combined))
+	m.writeOperationLog(name, core.OperationLogHalt, cmdexec.CommandLine(args), started, []byte(output.Text), err)
 	if err != nil {
-		return errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to stop VM: %s", output))
+		return vagrantFailure(err, "stop", output)
 	}
 	log.Info().Str("name", name).Msg("VM stopped successfully")
+	m.forgetSyncMount(name)
+	publishStateChange(name, "stop", core.Stopped)
+	return nil
+}
+
+// SuspendVM suspends the specified VM, saving its memory so 'vagrant up' resumes it
+func (m *Manager) SuspendVM(ctx context.Context, name string) error {
+	release, err := m.operations.acquire(name, OperationSuspend)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx, span := traceVagrant(ctx, name, "suspend")
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), m.getVMDir(name), m.vagrantArgs(name, "suspend")...)
+	span.EndCommand(err)
+	if err != nil {
+		return errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("failed to suspend VM: %s", output))
+	}
+	log.Info().Str("name", name).Msg("VM suspended successfully")
+	m.forgetSyncMount(name)
+	publishStateChange(name, "suspend", core.Suspended)
 	return nil
 }
 
 // DestroyVM destroys the specified VM and cleans up resources
 func (m *Manager) DestroyVM(ctx context.Context, name string) error {
+	release, err := m.operations.acquire(name, OperationDestroy)
+	if err != nil {
+		return err
+	}
+	defer release()
 	vmDir := m.getVMDir(name)
-	cmd := exec.CommandContext(ctx, "vagrant", "destroy", "-f")
-	cmd.Dir = vmDir
-	output, err := cmd.CombinedOutput()
+	// Vagrant removes the NFS exports of the machines it destroys; those it leaves are pruned below
+	var nfsMachines []string
+	if config, err := m.GetVMConfig(ctx, name); err == nil && config.SyncType == "nfs" {
+		nfsMachines = vagrantMachineIDs(vmDir)
+	}
+	started := time.Now()
+	ctx, span := traceVagrant(ctx, name, "destroy")
+	args := m.vagrantArgs(name, "destroy", "-f", machineReadableFlag)
+	combined, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vmDir, args...)
+	span.EndCommand(err)
+	output := ParseVagrantOutput(string(combined))
+	// The destroy log is kept outside the VM directory, which is removed below
+	m.writeOperationLog(name, core.OperationLogDestroy, cmdexec.CommandLine(args), started, []byte(output.Text), err)
 	if err != nil {
-		log.Error().Str("name", name).Err(err).Str("output", string(output)).Msg("Failed to destroy VM")
+		log.Error().Str("name", name).Err(err).Str("cause", output.Cause()).Str("output", output.Text).Msg("Failed to destroy VM")
 		// Continue with cleanup even if destroy fails
 	}
-	if err := os.RemoveAll(vmDir); err != nil {
+	// An adopted VM runs in its own project directory, which is never removed
+	if err := os.RemoveAll(filepath.Join(m.baseDir, name)); err != nil {
 		return errors.OperationFailed("clean up VM directory", err)
 	}
-	configFile := filepath.Join(filepath.Dir(m.baseDir), fmt.Sprintf("%s.json", name))
-	if err := os.Remove(configFile); err != nil && !os.IsNotExist(err) {
+	// The config was removed with the VM directory, unless it was never migrated
+	if err := os.Remove(legacyConfigPath(m.baseDir, name)); err != nil && !os.IsNotExist(err) {
 		return errors.OperationFailed("clean up VM config", err)
 	}
+	m.leaveNetwork(name)
+	m.forgetSyncMount(name)
+	pruneNFSExports(name, nfsMachines)
 	log.Info().Str("name", name).Msg("VM destroyed successfully")
+	publishStateChange(name, "destroy", core.NotCreated)
 	return nil
 }
 
+// traceVagrant starts the span of a vagrant command run on a VM
+func traceVagrant(ctx context.Context, name, command string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "vagrant "+command, tracing.String("vm.name", name), tracing.String("vagrant.command", command))
+}
+
+// vagrant returns the runner of the manager's vagrant commands
+func (m *Manager) vagrant() cmdexec.VagrantRunner {
+	if m.runner == nil {
+		return cmdexec.CLIRunner{}
+	}
+	return m.runner
+}
+
 // GetVMState returns the current state of the VM as core.VMState
 func (m *Manager) GetVMState(ctx context.Context, name string) (core.VMState, error) {
 	vmDir := m.getVMDir(name)
 	if _, err := os.Stat(vmDir); os.IsNotExist(err) {
 		return core.NotCreated, nil
 	}
-	cmd := exec.CommandContext(ctx, "vagrant", "status", "--machine-readable")
-	cmd.Dir = vmDir
-	output, err := cmd.CombinedOutput()
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vmDir, m.vagrantArgs(name, "status", machineReadableFlag)...)
 	if err != nil {
 		return core.Unknown, errors.OperationFailed("get VM status", err)
 	}
@@ -138,8 +354,7 @@ func (m *Manager) GetVMState(ctx context.Context, name string) (core.VMState, er
 
 // GetVMConfig returns the VM configuration as core.VMConfig
 func (m *Manager) GetVMConfig(ctx context.Context, name string) (core.VMConfig, error) {
-	configFile := filepath.Join(filepath.Dir(m.baseDir), fmt.Sprintf("%s.json", name))
-	data, err := os.ReadFile(configFile)
+	data, err := os.ReadFile(m.configPath(name))
 	if err != nil {
 		return core.VMConfig{}, errors.OperationFailed("read VM config", err)
 	}
@@ -150,21 +365,35 @@ func (m *Manager) GetVMConfig(ctx context.Context, name string) (core.VMConfig,
 	return config, nil
 }
 
-// UpdateVMConfig updates the VM configuration using core.VMConfig
+// UpdateVMConfig updates the VM configuration using core.VMConfig. A config read before another
+// update was saved fails with a conflict error.
 func (m *Manager) UpdateVMConfig(ctx context.Context, name string, config core.VMConfig) error {
 	log.Debug().Str("vm", name).Msg("Updating VM configuration")
 	vmDir := filepath.Join(m.baseDir, name)
 	if _, err := os.Stat(vmDir); os.IsNotExist(err) {
 		return errors.NotFound("VM directory", vmDir)
 	}
-	configPath := filepath.Join(vmDir, "config.json")
-	configData, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return errors.OperationFailed("marshal VM config", err)
+	if err := ValidateSyncProvider(config); err != nil {
+		return err
 	}
-	if err := os.WriteFile(configPath, configData, 0644); err != nil {
+	previous, previousErr := m.GetVMConfig(ctx, name)
+	// The config is saved where GetVMConfig reads it
+	if err := m.saveVMConfig(name, &config); err != nil {
+		if errors.IsConflict(err) {
+			return err
+		}
 		return errors.OperationFailed("write VM config", err)
 	}
+	// A new sync type changes the synced folder of the Vagrantfile, used from the next reload
+	if previousErr == nil && previous.SyncType != config.SyncType {
+		if err := m.ensureSMBCredentials(name, config); err != nil {
+			return errors.OperationFailed("save SMB credentials", err)
+		}
+		if err := m.generateVagrantfile(name, config); err != nil {
+			return err
+		}
+		m.forgetSyncMount(name)
+	}
 	log.Info().Str("vm", name).Msg("VM configuration updated")
 	return nil
 }
@@ -174,114 +403,138 @@ func (m *Manager) Close() {
 	// Nothing to clean up currently
 }
 
-// getVMDir returns the directory path for a VM
+// publishStateChange publishes a VM state change event on the global event bus
+func publishStateChange(name string, operation string, state core.VMState) {
+	events.GlobalBus.Publish(events.VMStateChanged, name, map[string]interface{}{
+		"operation": operation,
+		"state":     state,
+	})
+}
+
+// maxFailureOutput bounds the vagrant output published with a failed operation
+const maxFailureOutput = 2048
+
+// publishOperationFailure publishes a failed boot, reload or provisioning on the global event
+// bus with the cause vagrant reported and the end of its output
+func publishOperationFailure(name string, operation string, output VagrantOutput) {
+	data := map[string]interface{}{
+		"operation": operation,
+		"error":     output.Cause(),
+		"output":    tail(output.Text, maxFailureOutput),
+	}
+	if output.Error != nil {
+		data["error_type"] = output.Error.Type
+	}
+	events.GlobalBus.Publish(events.VMOperationFailed, name, data)
+}
+
+// getVMDir returns the directory vagrant runs in for a VM
 func (m *Manager) getVMDir(name string) string {
-	return filepath.Join(m.baseDir, name)
+	return VMDir(m.baseDir, name)
+}
+
+// saveVMConfig saves the VM configuration to a file as the next revision. A config read at
+// another revision than the stored one is a conflict, and so is a config without a revision
+// once the stored one has one.
+func (m *Manager) saveVMConfig(name string, config *core.VMConfig) error {
+	return m.writeVMConfig(name, config, false)
 }
 
-// saveVMConfig saves the VM configuration to a file
-func (m *Manager) saveVMConfig(name string, config core.VMConfig) error {
-	configDir := filepath.Dir(m.baseDir)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+// replaceVMConfig saves a VM configuration built from scratch as the next revision, replacing
+// the stored one whatever its revision
+func (m *Manager) replaceVMConfig(name string, config *core.VMConfig) error {
+	return m.writeVMConfig(name, config, true)
+}
+
+// writeVMConfig saves the VM configuration as the next revision, checking the revision it was
+// read at unless overwrite is set
+func (m *Manager) writeVMConfig(name string, config *core.VMConfig, overwrite bool) error {
+	configFile := m.configPath(name)
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
 		return errors.OperationFailed("create config directory", err)
 	}
 
-	configFile := filepath.Join(configDir, fmt.Sprintf("%s.json", name))
+	m.configWrites.Lock()
+	defer m.configWrites.Unlock()
+	previous, err := m.GetVMConfig(context.Background(), name)
+	if err != nil {
+		previous = core.VMConfig{}
+	}
+	if !overwrite && config.Revision != previous.Revision {
+		return errors.Conflict("VM config", name, config.Revision, previous.Revision)
+	}
+	revision := config.Revision
+	config.Revision = previous.Revision + 1
+	// Configs built from scratch keep the creation time of the one they replace
+	if config.CreatedAt == nil {
+		config.CreatedAt = previous.CreatedAt
+	}
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
+		config.Revision = revision
 		return errors.OperationFailed("marshal VM config", err)
 	}
-
-	return os.WriteFile(configFile, data, 0644)
+	if err := writeFileAtomic(configFile, data, 0644); err != nil {
+		config.Revision = revision
+		return err
+	}
+	return nil
 }
 
-// generateVagrantfile creates a Vagrantfile for the VM and validates it
+// generateVagrantfile creates a Vagrantfile for the VM and validates it. A Vagrantfile that
+// fails validation is replaced by the previous one.
 func (m *Manager) generateVagrantfile(name string, config core.VMConfig) error {
-	vagrantfile := `# -*- mode: ruby -*-
-# vi: set ft=ruby :
-# Generated by Vagrant MCP Server
-
-Vagrant.configure("2") do |config|
-  # Box settings
-  config.vm.box = "%s"
-  
-  # Provider-specific configuration
-  config.vm.provider "virtualbox" do |vb|
-    vb.gui = false
-    vb.name = "%s"
-    vb.memory = %d
-    vb.cpus = %d
-    
-    # Performance optimizations
-    vb.customize ["modifyvm", :id, "--natdnshostresolver1", "on"]
-    vb.customize ["modifyvm", :id, "--natdnsproxy1", "on"]
-    vb.customize ["modifyvm", :id, "--ioapic", "on"]
-  end
-
-  # Network settings
-%s
-  
-  # Sync settings
-%s
-  
-  # Provisioning
-  config.vm.provision "shell", inline: <<-SHELL
-    # Update package list
-    apt-get update
-    
-    # Install basic development tools
-    apt-get install -y build-essential curl git unzip
-%s
-    echo "Development VM setup completed!"
-  SHELL
-end`
-
-	// Generate port forwarding configuration
-	portsConfig := ""
-	for _, port := range config.Ports {
-		portsConfig += fmt.Sprintf("  config.vm.network \"forwarded_port\", guest: %d, host: %d, host_ip: \"127.0.0.1\"\n",
-			port.Guest, port.Host)
-	}
-
-	// Generate sync configuration
-	syncConfig := ""
-	switch config.SyncType {
-	case "rsync":
-		syncConfig = fmt.Sprintf(`  config.vm.synced_folder "%s", "/vagrant", 
-    type: "rsync",
-    rsync__exclude: [".git/", "node_modules/", "dist/", ".vagrant/"],
-    rsync__args: ["--verbose", "--archive", "--delete", "-z"]`, config.ProjectPath)
-	case "nfs":
-		syncConfig = fmt.Sprintf(`  config.vm.synced_folder "%s", "/vagrant", 
-    type: "nfs",
-    nfs_udp: false,
-    nfs_version: 4`, config.ProjectPath)
-	case "smb":
-		syncConfig = fmt.Sprintf(`  config.vm.synced_folder "%s", "/vagrant", 
-    type: "smb"`, config.ProjectPath)
-	default:
-		syncConfig = fmt.Sprintf(`  config.vm.synced_folder "%s", "/vagrant"`, config.ProjectPath)
-	}
-
-	// Generate environment setup
-	envSetup := ""
-	for _, line := range config.Environment {
-		envSetup += "    " + line + "\n"
-	}
-
-	// Format the complete Vagrantfile
-	content := fmt.Sprintf(vagrantfile,
-		config.Box,    // Box name
-		name,          // VM name
-		config.Memory, // Memory
-		config.CPU,    // CPU
-		portsConfig,   // Port forwarding
-		syncConfig,    // Sync configuration
-		envSetup)      // Environment setup
+	// Generate proxy, CA certificate, cloud-init, package cache, disk, firewall, dotfiles and
+	// SSH agent configuration
+	packageCacheConfig, err := vagrantPackageCacheConfig(m.PackageCacheDir(), config)
+	if err != nil {
+		return err
+	}
+	firewallConfig, err := m.vagrantFirewallConfig(config)
+	if err != nil {
+		return err
+	}
+	homeDir, _ := os.UserHomeDir()
+	dotfilesConfig := vagrantDotfilesConfig(config, homeDir)
+
+	// Generate shared private network configuration
+	networkConfig, err := m.vagrantNetworkConfig(name, config)
+	if err != nil {
+		return err
+	}
+
+	templatePath, err := VagrantfileTemplate(config)
+	if err != nil {
+		return err
+	}
+	content, err := renderVagrantfile(vagrantfileData{
+		Name:          name,
+		Config:        config,
+		Box:           config.Box,
+		Provider:      vagrantProviderBlock(name, config, HostPlatform()),
+		Settings:      vagrantProxyConfig(config) + vagrantCloudInitConfig(config) + packageCacheConfig + vagrantDiskConfig(config) + firewallConfig + dotfilesConfig + vagrantSSHAgentConfig(config),
+		Ports:         config.Ports,
+		Network:       networkConfig,
+		SyncType:      config.SyncType,
+		ProjectPath:   config.ProjectPath,
+		RsyncExcludes: rsyncExcludes(config),
+		NFSVersion:    nfsVersion(HostPlatform()),
+		// Environment setup and provisioners
+		Setup:   append(append([]string{}, config.Environment...), config.Provisioners...),
+		Steps:   vagrantProvisionStepsConfig(config),
+		Startup: vagrantStartupConfig(config),
+	}, config.Snippets, templatePath)
+	if err != nil {
+		return errors.OperationFailed("render Vagrantfile", err)
+	}
+	if templatePath != "" {
+		log.Info().Str("name", name).Str("template", templatePath).Msg("Rendering Vagrantfile from custom template")
+	}
 
 	// Write the Vagrantfile
 	vmDir := m.getVMDir(name)
 	vagrantfilePath := filepath.Join(vmDir, "Vagrantfile")
+	previous, readErr := os.ReadFile(vagrantfilePath)
 	if err := os.WriteFile(vagrantfilePath, []byte(content), 0644); err != nil {
 		return errors.OperationFailed("write Vagrantfile", err)
 	}
@@ -293,10 +546,13 @@ end`
 	}
 
 	// Always validate the Vagrantfile to ensure it's correct
-	cmd := exec.Command("vagrant", "validate")
-	cmd.Dir = vmDir
-	output, err := cmd.CombinedOutput()
+	output, err := cmdexec.CombinedOutput(context.Background(), m.vagrant(), vmDir, "validate")
 	if err != nil {
+		if readErr == nil {
+			if restoreErr := os.WriteFile(vagrantfilePath, previous, 0644); restoreErr != nil {
+				log.Warn().Err(restoreErr).Str("name", name).Msg("Failed to restore previous Vagrantfile")
+			}
+		}
 		return errors.Wrap(err, errors.CodeOperationFailed, fmt.Sprintf("vagrantfile validation failed: %s", output))
 	}
 	log.Info().Str("name", name).Msg("Vagrantfile validated successfully")
@@ -339,22 +595,44 @@ func (m *Manager) parseSSHConfig(output string) (map[string]string, error) {
 	return utils.GlobalOutputParser.ParseSSHConfig(output)
 }
 
-// ExecuteCommand executes a command in a VM
+// ExecuteCommand executes a command in a VM with 'vagrant ssh -c', in workingDir when it is
+// set, and records it in the audit log. Arguments are quoted for the guest shell.
 func (m *Manager) ExecuteCommand(ctx context.Context, name string, cmd string, args []string, workingDir string) (string, string, int, error) {
-	vmDir := m.getVMDir(name)
-	options := cmdexec.CmdOptions{
-		Directory:  vmDir,
-		OutputMode: cmdexec.OutputModeCapture,
+	command := cmd
+	for _, arg := range args {
+		command += " " + shellquote.Quote(arg)
 	}
-	// If a workingDir is provided, use it as a subdirectory inside the VM directory
 	if workingDir != "" {
-		options.Directory = filepath.Join(vmDir, workingDir)
-	}
-	result, err := cmdexec.Execute(ctx, cmd, args, options)
+		command = fmt.Sprintf("cd %s && %s", shellquote.Quote(workingDir), command)
+	}
+	var stdout, stderr bytes.Buffer
+	err := cmdexec.RunInGuest(ctx, m.vagrant(), name, command, cmdexec.VagrantCommand{
+		Dir:    m.getVMDir(name),
+		Args:   m.vagrantArgs(name, "ssh", "-c", command),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
 	if err != nil {
-		return string(result.StdOut), string(result.StdErr), result.ExitCode, errors.OperationFailed("execute command in VM", err)
+		exitCode := -1
+		if exitErr, ok := err.(interface{ ExitCode() int }); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		return stdout.String(), stderr.String(), exitCode, errors.OperationFailed("execute command in VM", err)
 	}
-	return string(result.StdOut), string(result.StdErr), result.ExitCode, nil
+	return stdout.String(), stderr.String(), 0, nil
+}
+
+// runGuestCommand runs a command in a VM with 'vagrant ssh -c', records it in the audit log
+// and returns its stdout and stderr together
+func (m *Manager) runGuestCommand(ctx context.Context, name, command string) ([]byte, error) {
+	var output bytes.Buffer
+	err := cmdexec.RunInGuest(ctx, m.vagrant(), name, command, cmdexec.VagrantCommand{
+		Dir:    m.getVMDir(name),
+		Args:   m.vagrantArgs(name, "ssh", "-c", command),
+		Stdout: &output,
+		Stderr: &output,
+	})
+	return output.Bytes(), err
 }
 
 // UploadToVM uploads a file or directory to the VM using vagrant upload
@@ -375,18 +653,19 @@ func (m *Manager) UploadToVM(ctx context.Context, name string, source string, de
 	}
 	args := []string{"upload"}
 	if compress {
+		if err := GlobalCapabilities.Require(ctx, FeatureUploadCompression); err != nil {
+			return err
+		}
 		args = append(args, "--compress")
 		if compressionType != "" {
 			args = append(args, "--compression-type", compressionType)
 		}
 	}
-	args = append(args, source, destination)
-	cmd := exec.CommandContext(ctx, "vagrant", args...)
-	cmd.Dir = vmDir
+	args = m.vagrantArgs(name, append(args, source, destination)...)
 	log.Debug().Str("vm", name).Str("source", source).Str("destination", destination).
 		Bool("compress", compress).Str("compression", compressionType).
 		Msg("Uploading file to VM")
-	output, err := cmd.CombinedOutput()
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vmDir, args...)
 	if err != nil {
 		return errors.OperationFailed("upload file to VM", fmt.Errorf("%w: %s", err, output))
 	}
@@ -395,44 +674,63 @@ func (m *Manager) UploadToVM(ctx context.Context, name string, source string, de
 	return nil
 }
 
-// SyncToVM synchronizes files from host to VM using rsync
-func (m *Manager) SyncToVM(name, source, target string) error {
-	// Use rsync to copy files from host to VM
-	// This is a simplified implementation; in production, handle SSH config, errors, etc.
-	vmDir := m.getVMDir(name)
-	if vmDir == "" {
+// SyncToVM copies the host file or directory source to the guest path target with rsync over
+// ssh, deleting the files of a target directory that are missing from source. Files matching
+// the rsync exclude patterns excludes are left alone. On Windows hosts rsync comes from PATH or
+// WSL, as hostos.MirrorTool finds it.
+func (m *Manager) SyncToVM(name, source, target string, excludes []string) error {
+	return m.SyncToVMWithProgress(name, source, target, excludes, nil)
+}
+
+// SyncToVMWithProgress is SyncToVM reporting the progress of the copy to progress while it runs
+func (m *Manager) SyncToVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error {
+	if m.getVMDir(name) == "" {
 		return fmt.Errorf("could not determine VM directory for %s", name)
 	}
-	// Assume target is relative to /vagrant in the VM
-	cmd := exec.Command("rsync", "-az", "--delete", source+"/", vmDir+"/vagrant/"+target+"/")
-	output, err := cmd.CombinedOutput()
+	output, err := m.guestSync(context.Background(), name, source, target, true, excludes, progress)
 	if err != nil {
-		return fmt.Errorf("rsync to VM failed: %v, output: %s", err, string(output))
+		return fmt.Errorf("sync to VM failed: %v, output: %s", err, string(output))
 	}
+	m.storeSyncReport(name, "to_vm", output)
 	return nil
 }
 
-// SyncFromVM synchronizes files from VM to host using rsync
-func (m *Manager) SyncFromVM(name, source, target string) error {
-	// Use rsync to copy files from VM to host
-	vmDir := m.getVMDir(name)
-	if vmDir == "" {
+// SyncFromVM copies the guest file or directory source to the host path target with rsync over
+// ssh, deleting the files of a target directory that are missing from source. Files matching
+// the rsync exclude patterns excludes are left alone.
+func (m *Manager) SyncFromVM(name, source, target string, excludes []string) error {
+	return m.SyncFromVMWithProgress(name, source, target, excludes, nil)
+}
+
+// SyncFromVMWithProgress is SyncFromVM reporting the progress of the copy to progress while it
+// runs
+func (m *Manager) SyncFromVMWithProgress(name, source, target string, excludes []string, progress func(hostos.RsyncProgress)) error {
+	if m.getVMDir(name) == "" {
 		return fmt.Errorf("could not determine VM directory for %s", name)
 	}
-	cmd := exec.Command("rsync", "-az", "--delete", vmDir+"/vagrant/"+source+"/", target+"/")
-	output, err := cmd.CombinedOutput()
+	output, err := m.guestSync(context.Background(), name, target, source, false, excludes, progress)
 	if err != nil {
-		return fmt.Errorf("rsync from VM failed: %v, output: %s", err, string(output))
+		return fmt.Errorf("sync from VM failed: %v, output: %s", err, string(output))
 	}
+	m.storeSyncReport(name, "from_vm", output)
 	return nil
 }
 
+// storeSyncReport keeps the changes a sync made, as the mirror tool listed them, as a sync
+// report artifact of the VM
+func (m *Manager) storeSyncReport(name, direction string, output []byte) {
+	if len(bytes.TrimSpace(output)) == 0 {
+		return
+	}
+	if _, err := artifacts.NewStore(m.baseDir).Put(name, artifacts.KindSyncReport, direction, bytes.NewReader(output)); err != nil {
+		log.Warn().Err(err).Str("vm", name).Msg("Failed to store sync report")
+	}
+}
+
 // GetSSHConfig retrieves the SSH configuration for the VM using 'vagrant ssh-config'
 func (m *Manager) GetSSHConfig(ctx context.Context, name string) (map[string]string, error) {
 	vmDir := m.getVMDir(name)
-	cmd := exec.CommandContext(ctx, "vagrant", "ssh-config")
-	cmd.Dir = vmDir
-	output, err := cmd.CombinedOutput()
+	output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), vmDir, m.vagrantArgs(name, "ssh-config")...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get SSH config: %w, output: %s", err, string(output))
 	}
@@ -0,0 +1,365 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// networksFile is the file under the base directory that records the shared private networks
+const networksFile = ".networks.json"
+
+// networkHostsProvisioner names the provisioner that writes a network's members to /etc/hosts
+const networkHostsProvisioner = "network-hosts"
+
+// Private networks are allocated from 192.168.56.0/21, the range VirtualBox allows for
+// host-only networks by default, one /24 per network
+const (
+	networkFirstOctet = 56
+	networkSubnets    = 8
+	networkFirstHost  = 10
+	networkLastHost   = 254
+)
+
+// networksMu serialises access to the networks file
+var networksMu sync.Mutex
+
+var (
+	networkNamePattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,30}$`)
+	hostnameInvalidChar = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// Network is a private network shared by several VMs of a project
+type Network struct {
+	Name    string            `json:"name"`
+	Subnet  string            `json:"subnet"`
+	Members map[string]string `json:"members"`
+}
+
+// NetworkMember is a VM on a shared private network
+type NetworkMember struct {
+	VMName    string   `json:"vm_name"`
+	IP        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+}
+
+// SortedMembers returns the members of the network ordered by VM name
+func (n Network) SortedMembers() []NetworkMember {
+	names := make([]string, 0, len(n.Members))
+	for name := range n.Members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	members := make([]NetworkMember, 0, len(names))
+	for _, name := range names {
+		members = append(members, NetworkMember{VMName: name, IP: n.Members[name], Hostnames: NetworkHostnames(name, n.Name)})
+	}
+	return members
+}
+
+// NetworkHostnames returns the deterministic hostnames of a VM on a network: the VM name
+// and a fully qualified name under the network
+func NetworkHostnames(vmName, network string) []string {
+	host := strings.Trim(hostnameInvalidChar.ReplaceAllString(strings.ToLower(vmName), "-"), "-")
+	return []string{host, fmt.Sprintf("%s.%s.internal", host, network)}
+}
+
+// ValidateNetworkName checks that a network name can be used in hostnames
+func ValidateNetworkName(name string) error {
+	if !networkNamePattern.MatchString(name) {
+		return errors.InvalidInput(fmt.Sprintf("invalid network name '%s': use up to 31 lowercase letters, digits or '-'", name))
+	}
+	return nil
+}
+
+// GetNetwork returns a shared private network
+func (m *Manager) GetNetwork(name string) (Network, error) {
+	networksMu.Lock()
+	defer networksMu.Unlock()
+	networks, err := loadNetworksLocked(m.baseDir)
+	if err != nil {
+		return Network{}, errors.OperationFailed("read networks", err)
+	}
+	network, ok := networks[name]
+	if !ok {
+		return Network{}, errors.NotFound("network", name)
+	}
+	return *network, nil
+}
+
+// ConnectVMs puts VMs on a shared private network, creating the network if needed. Each VM gets
+// a fixed address, and every member's /etc/hosts lists the other members by hostname. Running
+// VMs that joined are reloaded so the new interface comes up; running members that were
+// already connected only have their hosts file refreshed.
+func (m *Manager) ConnectVMs(ctx context.Context, network string, vmNames []string) (Network, error) {
+	if err := ValidateNetworkName(network); err != nil {
+		return Network{}, err
+	}
+	release, err := m.operations.acquireAll(vmNames, OperationReconfigure)
+	if err != nil {
+		return Network{}, err
+	}
+	defer release()
+	configs := make(map[string]core.VMConfig, len(vmNames))
+	for _, name := range vmNames {
+		if _, ok := configs[name]; ok {
+			return Network{}, errors.InvalidInput(fmt.Sprintf("VM '%s' is listed more than once", name))
+		}
+		if _, err := loadAdoption(filepath.Join(m.baseDir, name)); err == nil {
+			return Network{}, errors.InvalidInput(fmt.Sprintf("VM '%s' is adopted and keeps its own Vagrantfile", name))
+		}
+		config, err := m.GetVMConfig(ctx, name)
+		if err != nil {
+			return Network{}, err
+		}
+		if config.Network != "" && config.Network != network {
+			return Network{}, errors.InvalidInput(fmt.Sprintf("VM '%s' is already on network '%s'", name, config.Network))
+		}
+		configs[name] = config
+	}
+
+	joined, err := m.joinNetwork(network, vmNames)
+	if err != nil {
+		return Network{}, err
+	}
+	var newMembers []string
+	for _, name := range vmNames {
+		config := configs[name]
+		if config.Network == network {
+			continue
+		}
+		config.Network = network
+		if err := m.applyConfig(ctx, name, config); err != nil {
+			return Network{}, err
+		}
+		newMembers = append(newMembers, name)
+	}
+	if err := m.refreshNetworkMembers(ctx, network, newMembers); err != nil {
+		return Network{}, err
+	}
+	log.Info().Str("network", network).Strs("vms", vmNames).Msg("VMs connected")
+	return joined, nil
+}
+
+// joinNetwork allocates addresses on a network for the VMs that are not members yet
+func (m *Manager) joinNetwork(name string, vmNames []string) (Network, error) {
+	networksMu.Lock()
+	defer networksMu.Unlock()
+	networks, err := loadNetworksLocked(m.baseDir)
+	if err != nil {
+		return Network{}, errors.OperationFailed("read networks", err)
+	}
+	network, ok := networks[name]
+	if !ok {
+		subnet, err := allocateSubnet(name, networks)
+		if err != nil {
+			return Network{}, err
+		}
+		network = &Network{Name: name, Subnet: subnet, Members: make(map[string]string)}
+		networks[name] = network
+	}
+	for _, vmName := range vmNames {
+		if _, ok := network.Members[vmName]; ok {
+			continue
+		}
+		ip, err := allocateAddress(network)
+		if err != nil {
+			return Network{}, err
+		}
+		network.Members[vmName] = ip
+	}
+	if err := saveNetworksLocked(m.baseDir, networks); err != nil {
+		return Network{}, errors.OperationFailed("save networks", err)
+	}
+	return *network, nil
+}
+
+// leaveNetwork removes a VM from the network it is on and regenerates the Vagrantfiles of the
+// remaining members so their hosts files drop it on their next provisioning
+func (m *Manager) leaveNetwork(name string) {
+	networksMu.Lock()
+	networks, err := loadNetworksLocked(m.baseDir)
+	if err != nil {
+		networksMu.Unlock()
+		log.Warn().Err(err).Str("name", name).Msg("Failed to read networks")
+		return
+	}
+	var remaining []string
+	for networkName, network := range networks {
+		if _, ok := network.Members[name]; !ok {
+			continue
+		}
+		delete(network.Members, name)
+		if len(network.Members) == 0 {
+			delete(networks, networkName)
+		}
+		for member := range network.Members {
+			remaining = append(remaining, member)
+		}
+	}
+	err = saveNetworksLocked(m.baseDir, networks)
+	networksMu.Unlock()
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("Failed to save networks")
+		return
+	}
+
+	for _, member := range remaining {
+		if config, err := m.GetVMConfig(context.Background(), member); err == nil {
+			if err := m.generateVagrantfile(member, config); err != nil {
+				log.Warn().Err(err).Str("name", member).Msg("Failed to regenerate Vagrantfile")
+			}
+		}
+	}
+}
+
+// refreshNetworkMembers regenerates the Vagrantfiles of a network's members other than skip, which
+// already have an up to date Vagrantfile, and reruns the hosts provisioner on those that are running
+func (m *Manager) refreshNetworkMembers(ctx context.Context, network string, skip []string) error {
+	current, err := m.GetNetwork(network)
+	if err != nil {
+		return err
+	}
+	skipped := make(map[string]bool, len(skip))
+	for _, name := range skip {
+		skipped[name] = true
+	}
+	for _, member := range current.SortedMembers() {
+		if skipped[member.VMName] {
+			continue
+		}
+		config, err := m.GetVMConfig(ctx, member.VMName)
+		if err != nil {
+			return err
+		}
+		if err := m.generateVagrantfile(member.VMName, config); err != nil {
+			return errors.OperationFailed("generate Vagrantfile", err)
+		}
+		state, err := m.GetVMState(ctx, member.VMName)
+		if err != nil {
+			return err
+		}
+		if state != core.Running {
+			continue
+		}
+		if output, err := cmdexec.CombinedOutput(ctx, m.vagrant(), m.getVMDir(member.VMName), "provision", "--provision-with", networkHostsProvisioner); err != nil {
+			return errors.OperationFailed("update hosts file", fmt.Errorf("%w: %s", err, output))
+		}
+	}
+	return nil
+}
+
+// vagrantNetworkConfig returns the private network settings and hosts provisioner for a VM on
+// a shared network, or an empty string when it is not on one
+func (m *Manager) vagrantNetworkConfig(name string, config core.VMConfig) (string, error) {
+	if config.Network == "" {
+		return "", nil
+	}
+	network, err := m.GetNetwork(config.Network)
+	if err != nil {
+		return "", err
+	}
+	ip, ok := network.Members[name]
+	if !ok {
+		return "", errors.InvalidInput(fmt.Sprintf("VM '%s' has no address on network '%s'", name, config.Network))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "  config.vm.network \"private_network\", ip: \"%s\"\n", ip)
+	fmt.Fprintf(&b, "  config.vm.hostname = \"%s\"\n", NetworkHostnames(name, network.Name)[0])
+	fmt.Fprintf(&b, "  config.vm.provision \"shell\", name: \"%s\", run: \"always\", inline: <<-SHELL\n", networkHostsProvisioner)
+	writeIndented(&b, networkHostsScript(network))
+	b.WriteString("  SHELL\n")
+	return b.String(), nil
+}
+
+// networkHostsScript replaces the network's block in /etc/hosts with its current members
+func networkHostsScript(network Network) string {
+	begin := fmt.Sprintf("# BEGIN vagrant-mcp network %s", network.Name)
+	end := fmt.Sprintf("# END vagrant-mcp network %s", network.Name)
+	lines := []string{
+		fmt.Sprintf("sed -i '/^%s$/,/^%s$/d' /etc/hosts", begin, end),
+		fmt.Sprintf("echo '%s' >> /etc/hosts", begin),
+	}
+	for _, member := range network.SortedMembers() {
+		lines = append(lines, fmt.Sprintf("echo '%s %s' >> /etc/hosts", member.IP, strings.Join(member.Hostnames, " ")))
+	}
+	lines = append(lines, fmt.Sprintf("echo '%s' >> /etc/hosts", end))
+	return strings.Join(lines, "\n")
+}
+
+// allocateSubnet picks a free /24 for a network, starting from one derived from its name so
+// a network usually gets the same subnet when it is recreated
+func allocateSubnet(name string, networks map[string]*Network) (string, error) {
+	used := make(map[string]bool, len(networks))
+	for _, network := range networks {
+		used[network.Subnet] = true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	start := int(h.Sum32() % networkSubnets)
+	for i := 0; i < networkSubnets; i++ {
+		subnet := fmt.Sprintf("192.168.%d", networkFirstOctet+(start+i)%networkSubnets)
+		if !used[subnet] {
+			return subnet, nil
+		}
+	}
+	return "", errors.OperationFailed("allocate network", fmt.Errorf("all %d private network subnets are in use", networkSubnets))
+}
+
+// allocateAddress returns the lowest free host address on a network
+func allocateAddress(network *Network) (string, error) {
+	used := make(map[string]bool, len(network.Members))
+	for _, ip := range network.Members {
+		used[ip] = true
+	}
+	for host := networkFirstHost; host <= networkLastHost; host++ {
+		ip := fmt.Sprintf("%s.%d", network.Subnet, host)
+		if !used[ip] {
+			return ip, nil
+		}
+	}
+	return "", errors.OperationFailed("allocate address", fmt.Errorf("network '%s' is full", network.Name))
+}
+
+// loadNetworksLocked reads the networks file; the caller must hold networksMu
+func loadNetworksLocked(baseDir string) (map[string]*Network, error) {
+	networks := make(map[string]*Network)
+	data, err := os.ReadFile(filepath.Join(baseDir, networksFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return networks, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &networks); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
+
+// saveNetworksLocked writes the networks file; the caller must hold networksMu
+func saveNetworksLocked(baseDir string, networks map[string]*Network) error {
+	data, err := json.MarshalIndent(networks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(baseDir, networksFile), data, 0644)
+}
@@ -0,0 +1,126 @@
+package vm
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestNetworkHostnames(t *testing.T) {
+	testCases := []struct {
+		vmName   string
+		expected []string
+	}{
+		{"db", []string{"db", "db.shop.internal"}},
+		{"API_Server", []string{"api-server", "api-server.shop.internal"}},
+		{"_web.1_", []string{"web-1", "web-1.shop.internal"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.vmName, func(t *testing.T) {
+			hostnames := NetworkHostnames(tc.vmName, "shop")
+			if strings.Join(hostnames, " ") != strings.Join(tc.expected, " ") {
+				t.Errorf("Expected hostnames %v but got %v", tc.expected, hostnames)
+			}
+		})
+	}
+}
+
+func TestAllocateSubnet(t *testing.T) {
+	networks := make(map[string]*Network)
+	first, err := allocateSubnet("shop", networks)
+	if err != nil {
+		t.Fatalf("Failed to allocate subnet: %v", err)
+	}
+	if again, _ := allocateSubnet("shop", networks); again != first {
+		t.Errorf("Expected the same subnet for the same name but got %s and %s", first, again)
+	}
+
+	// Every subnet in the range is handed out once before the range is exhausted
+	seen := make(map[string]bool)
+	for i := 0; i < networkSubnets; i++ {
+		name := string(rune('a' + i))
+		subnet, err := allocateSubnet(name, networks)
+		if err != nil {
+			t.Fatalf("Failed to allocate subnet %d: %v", i, err)
+		}
+		if seen[subnet] || !strings.HasPrefix(subnet, "192.168.") {
+			t.Errorf("Expected a new subnet in 192.168.56.0/21 but got %s", subnet)
+		}
+		seen[subnet] = true
+		networks[name] = &Network{Name: name, Subnet: subnet}
+	}
+	if _, err := allocateSubnet("full", networks); err == nil {
+		t.Errorf("Expected an error when every subnet is in use")
+	}
+}
+
+func TestJoinAndLeaveNetwork(t *testing.T) {
+	m := &Manager{baseDir: filepath.Join(t.TempDir(), "vms")}
+
+	network, err := m.joinNetwork("shop", []string{"app", "db"})
+	if err != nil {
+		t.Fatalf("Failed to join network: %v", err)
+	}
+	if network.Members["app"] != network.Subnet+".10" || network.Members["db"] != network.Subnet+".11" {
+		t.Errorf("Expected app and db at .10 and .11 but got %v", network.Members)
+	}
+
+	// Joining again keeps existing addresses
+	network, err = m.joinNetwork("shop", []string{"db", "cache"})
+	if err != nil {
+		t.Fatalf("Failed to join network: %v", err)
+	}
+	if network.Members["db"] != network.Subnet+".11" || network.Members["cache"] != network.Subnet+".12" {
+		t.Errorf("Expected db to keep .11 and cache to get .12 but got %v", network.Members)
+	}
+
+	m.leaveNetwork("app")
+	network, err = m.GetNetwork("shop")
+	if err != nil {
+		t.Fatalf("Failed to get network: %v", err)
+	}
+	if _, ok := network.Members["app"]; ok || len(network.Members) != 2 {
+		t.Errorf("Expected app to have left the network but got %v", network.Members)
+	}
+
+	// The freed address is reused
+	network, _ = m.joinNetwork("shop", []string{"worker"})
+	if network.Members["worker"] != network.Subnet+".10" {
+		t.Errorf("Expected worker to reuse .10 but got %s", network.Members["worker"])
+	}
+}
+
+func TestVagrantNetworkConfig(t *testing.T) {
+	m := &Manager{baseDir: filepath.Join(t.TempDir(), "vms")}
+	if config, err := m.vagrantNetworkConfig("app", core.VMConfig{}); err != nil || config != "" {
+		t.Errorf("Expected no network settings but got %q, %v", config, err)
+	}
+
+	network, err := m.joinNetwork("shop", []string{"app", "db"})
+	if err != nil {
+		t.Fatalf("Failed to join network: %v", err)
+	}
+	config, err := m.vagrantNetworkConfig("app", core.VMConfig{Network: "shop"})
+	if err != nil {
+		t.Fatalf("Failed to generate network settings: %v", err)
+	}
+	expected := []string{
+		`config.vm.network "private_network", ip: "` + network.Members["app"] + `"`,
+		`config.vm.hostname = "app"`,
+		`name: "network-hosts", run: "always"`,
+		`sed -i '/^# BEGIN vagrant-mcp network shop$/,/^# END vagrant-mcp network shop$/d' /etc/hosts`,
+		`echo '` + network.Members["db"] + ` db db.shop.internal' >> /etc/hosts`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(config, fragment) {
+			t.Errorf("Expected network settings to contain %q but got:\n%s", fragment, config)
+		}
+	}
+
+	if _, err := m.vagrantNetworkConfig("other", core.VMConfig{Network: "shop"}); err == nil {
+		t.Errorf("Expected an error for a VM without an address on the network")
+	}
+}
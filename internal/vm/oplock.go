@@ -0,0 +1,97 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Lifecycle operations serialised per VM
+const (
+	OperationCreate      = "create"
+	OperationAdopt       = "adopt"
+	OperationUp          = "up"
+	OperationReload      = "reload"
+	OperationHalt        = "halt"
+	OperationSuspend     = "suspend"
+	OperationDestroy     = "destroy"
+	OperationReconfigure = "reconfigure"
+	OperationPackage     = "package"
+	OperationProvision   = "provision"
+)
+
+// Operation is a lifecycle operation running on a VM
+type Operation struct {
+	Name      string    `json:"operation"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// operationLocks holds the operation running on each VM. A VM runs one lifecycle operation
+// at a time; a second one fails instead of racing the first through vagrant.
+type operationLocks struct {
+	mu      sync.Mutex
+	running map[string]Operation
+}
+
+// acquire starts an operation on a VM and returns the function that ends it
+func (l *operationLocks) acquire(name, operation string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if current, ok := l.running[name]; ok {
+		return nil, errors.New(errors.CodeInvalidState,
+			fmt.Sprintf("operation in progress: %s (VM '%s', started %s ago)", current.Name, name, time.Since(current.StartedAt).Round(time.Second))).
+			WithContext("vm", name).
+			WithContext("operation", current.Name)
+	}
+	if l.running == nil {
+		l.running = make(map[string]Operation)
+	}
+	l.running[name] = Operation{Name: operation, StartedAt: time.Now()}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.running, name)
+	}, nil
+}
+
+// acquireAll starts an operation on several VMs, or on none of them when one is busy
+func (l *operationLocks) acquireAll(names []string, operation string) (func(), error) {
+	releases := make([]func(), 0, len(names))
+	releaseAll := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		release, err := l.acquire(name, operation)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releaseAll, nil
+}
+
+// current returns the operation running on a VM
+func (l *operationLocks) current(name string) (Operation, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	operation, ok := l.running[name]
+	return operation, ok
+}
+
+// CurrentOperation returns the lifecycle operation running on a VM, if any
+func (m *Manager) CurrentOperation(name string) (Operation, bool) {
+	return m.operations.current(name)
+}
@@ -0,0 +1,76 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+func TestOperationLocks(t *testing.T) {
+	var locks operationLocks
+	release, err := locks.acquire("web", OperationUp)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if operation, ok := locks.current("web"); !ok || operation.Name != OperationUp {
+		t.Errorf("Expected the up operation to be running but got %+v", operation)
+	}
+
+	_, err = locks.acquire("web", OperationDestroy)
+	if err == nil || !errors.Is(err, errors.CodeInvalidState) || !strings.Contains(err.Error(), "operation in progress: up") {
+		t.Errorf("Expected an operation in progress error but got %v", err)
+	}
+	if _, err := locks.acquire("db", OperationDestroy); err != nil {
+		t.Errorf("Expected other VMs to be unaffected but got %v", err)
+	}
+
+	release()
+	if _, ok := locks.current("web"); ok {
+		t.Errorf("Expected no operation after release")
+	}
+	if _, err := locks.acquire("web", OperationDestroy); err != nil {
+		t.Errorf("Expected the VM to be free after release but got %v", err)
+	}
+}
+
+func TestOperationLocks_AcquireAll(t *testing.T) {
+	var locks operationLocks
+	releaseDB, _ := locks.acquire("db", OperationHalt)
+
+	if _, err := locks.acquireAll([]string{"web", "db"}, OperationReconfigure); err == nil {
+		t.Fatalf("Expected an error while db is busy")
+	}
+	if _, ok := locks.current("web"); ok {
+		t.Errorf("Expected web to be released when db is busy")
+	}
+
+	releaseDB()
+	release, err := locks.acquireAll([]string{"web", "db", "web"}, OperationReconfigure)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	release()
+	for _, name := range []string{"web", "db"} {
+		if _, ok := locks.current(name); ok {
+			t.Errorf("Expected %s to be released", name)
+		}
+	}
+}
+
+func TestManagerRejectsConcurrentOperations(t *testing.T) {
+	m := &Manager{baseDir: t.TempDir()}
+	release, err := m.operations.acquire("web", OperationUp)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	defer release()
+
+	if err := m.DestroyVM(context.Background(), "web"); err == nil || !strings.Contains(err.Error(), "operation in progress: up") {
+		t.Errorf("Expected destroy to be refused during up but got %v", err)
+	}
+	if operation, ok := m.CurrentOperation("web"); !ok || operation.Name != OperationUp {
+		t.Errorf("Expected the up operation to be reported but got %+v", operation)
+	}
+}
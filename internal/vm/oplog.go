@@ -0,0 +1,60 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// writeOperationLog saves the transcript of a vagrant command as an operation log
+func (m *Manager) writeOperationLog(name, operation string, args []string, started time.Time, output []byte, runErr error) {
+	path := core.OperationLogPath(m.baseDir, name, operation)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Warn().Err(err).Str("name", name).Str("operation", operation).Msg("Failed to create operation log directory")
+		return
+	}
+	if err := os.WriteFile(path, []byte(formatTranscript(args, started, time.Since(started), output, runErr)), 0644); err != nil {
+		log.Warn().Err(err).Str("name", name).Str("operation", operation).Msg("Failed to save operation log")
+	}
+}
+
+// recordUpLogs saves the vagrant up transcript and, when provisioners ran, their section as the provision log
+func (m *Manager) recordUpLogs(name string, args []string, started time.Time, output []byte, runErr error) {
+	m.writeOperationLog(name, core.OperationLogUp, args, started, output, runErr)
+	if provision := extractProvisionOutput(string(output)); provision != "" {
+		m.writeOperationLog(name, core.OperationLogProvision, args, started, []byte(provision), runErr)
+	}
+}
+
+// formatTranscript renders a command transcript with a short header
+func formatTranscript(args []string, started time.Time, duration time.Duration, output []byte, runErr error) string {
+	status := "ok"
+	if runErr != nil {
+		status = fmt.Sprintf("failed: %v", runErr)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ %s\n", strings.Join(args, " "))
+	fmt.Fprintf(&b, "# started: %s, duration: %s, status: %s\n", started.UTC().Format(time.RFC3339), duration.Round(time.Millisecond), status)
+	b.Write(output)
+	return b.String()
+}
+
+// extractProvisionOutput returns the provisioner section of vagrant up output
+func extractProvisionOutput(output string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "Running provisioner") {
+			return strings.Join(lines[i:], "\n")
+		}
+	}
+	return ""
+}
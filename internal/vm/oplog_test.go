@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestRecordUpLogs(t *testing.T) {
+	manager := &Manager{baseDir: t.TempDir()}
+	output := []byte("==> default: Booting VM...\n==> default: Running provisioner: shell...\n    default: installing\n")
+
+	manager.recordUpLogs("dev", []string{"vagrant", "up"}, time.Now(), output, nil)
+
+	up, err := os.ReadFile(core.OperationLogPath(manager.baseDir, "dev", core.OperationLogUp))
+	if err != nil {
+		t.Fatalf("Expected up log but got %v", err)
+	}
+	if !strings.HasPrefix(string(up), "$ vagrant up\n") || !strings.Contains(string(up), "status: ok") || !strings.Contains(string(up), "Booting VM") {
+		t.Errorf("Unexpected up log: %q", up)
+	}
+
+	provision, err := os.ReadFile(core.OperationLogPath(manager.baseDir, "dev", core.OperationLogProvision))
+	if err != nil {
+		t.Fatalf("Expected provision log but got %v", err)
+	}
+	if strings.Contains(string(provision), "Booting VM") || !strings.Contains(string(provision), "installing") {
+		t.Errorf("Unexpected provision log: %q", provision)
+	}
+}
+
+func TestWriteOperationLogFailure(t *testing.T) {
+	manager := &Manager{baseDir: t.TempDir()}
+
+	manager.writeOperationLog("dev", core.OperationLogDestroy, []string{"vagrant", "destroy", "-f"}, time.Now(), []byte("boom\n"), errors.New("exit status 1"))
+
+	data, err := os.ReadFile(core.OperationLogPath(manager.baseDir, "dev", core.OperationLogDestroy))
+	if err != nil {
+		t.Fatalf("Expected destroy log but got %v", err)
+	}
+	if !strings.Contains(string(data), "status: failed: exit status 1") || !strings.HasSuffix(string(data), "boom\n") {
+		t.Errorf("Unexpected destroy log: %q", data)
+	}
+}
+
+func TestExtractProvisionOutput(t *testing.T) {
+	if actual := extractProvisionOutput("==> default: Booting VM...\n"); actual != "" {
+		t.Errorf("Expected no provisioning output but got %q", actual)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// PackageCache is a package manager cache shared between VMs through a synced folder
+type PackageCache struct {
+	Name string `json:"name"`
+	// GuestPath is where the cache is mounted in the guest
+	GuestPath string `json:"guest_path"`
+	// EnvVar points the package manager at GuestPath; empty when it uses GuestPath by default
+	EnvVar string `json:"env_var,omitempty"`
+}
+
+// PackageCaches lists the caches mounted into VMs with a shared package cache
+var PackageCaches = []PackageCache{
+	{Name: "apt", GuestPath: "/var/cache/apt/archives"},
+	{Name: "npm", GuestPath: "/var/cache/vagrant-mcp/npm", EnvVar: "npm_config_cache"},
+	{Name: "pip", GuestPath: "/var/cache/vagrant-mcp/pip", EnvVar: "PIP_CACHE_DIR"},
+	{Name: "go", GuestPath: "/var/cache/vagrant-mcp/go", EnvVar: "GOMODCACHE"},
+}
+
+// guestAPTKeepFile keeps apt from deleting downloaded packages after installing them
+const guestAPTKeepFile = "/etc/apt/apt.conf.d/96vagrant-mcp-package-cache"
+
+// PackageCacheDir returns the host directory holding the shared package caches, set by
+// MCP_PACKAGE_CACHE_DIR or under the VM base directory
+func (m *Manager) PackageCacheDir() string {
+	if dir := os.Getenv("MCP_PACKAGE_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(m.baseDir, ".package-cache")
+}
+
+// PackageCacheUsage returns the size in bytes of each shared package cache
+func (m *Manager) PackageCacheUsage() (map[string]int64, error) {
+	usage := make(map[string]int64)
+	for _, cache := range PackageCaches {
+		size, err := dirSize(filepath.Join(m.PackageCacheDir(), cache.Name))
+		if err != nil {
+			return nil, errors.OperationFailed("measure package cache", err)
+		}
+		usage[cache.Name] = size
+	}
+	return usage, nil
+}
+
+// ClearPackageCache empties one shared package cache, or all of them when name is empty, and
+// returns the number of bytes freed. The cache directories are kept because running VMs
+// have them mounted.
+func (m *Manager) ClearPackageCache(name string) (int64, error) {
+	var freed int64
+	found := false
+	for _, cache := range PackageCaches {
+		if name != "" && cache.Name != name {
+			continue
+		}
+		found = true
+		dir := filepath.Join(m.PackageCacheDir(), cache.Name)
+		size, err := dirSize(dir)
+		if err != nil {
+			return freed, errors.OperationFailed("measure package cache", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return freed, errors.OperationFailed("read package cache", err)
+		}
+		for _, entry := range entries {
+			if err := makeWritable(filepath.Join(dir, entry.Name())); err != nil {
+				return freed, errors.OperationFailed("clear package cache", err)
+			}
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return freed, errors.OperationFailed("clear package cache", err)
+			}
+		}
+		freed += size
+		log.Info().Str("cache", cache.Name).Int64("bytes", size).Msg("Package cache cleared")
+	}
+	if !found {
+		return 0, errors.NotFound("package cache", name)
+	}
+	return freed, nil
+}
+
+// vagrantPackageCacheConfig returns the synced folders that share the package caches in cacheDir
+// and the provisioner that points the package managers at them, or an empty string when the VM
+// does not use the shared cache
+func vagrantPackageCacheConfig(cacheDir string, config core.VMConfig) (string, error) {
+	if !config.SharedPackageCache {
+		return "", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("\n  # Shared package cache\n")
+	for _, cache := range PackageCaches {
+		hostDir := filepath.Join(cacheDir, cache.Name)
+		if err := os.MkdirAll(hostDir, 0755); err != nil {
+			return "", errors.OperationFailed("create package cache directory", err)
+		}
+		// Caches are live shared folders even when the project is synced with rsync. They belong
+		// to the vagrant user; root, which runs apt, writes to them regardless.
+		fmt.Fprintf(&b, "  config.vm.synced_folder %s, %s, owner: \"vagrant\", group: \"vagrant\", mount_options: [\"dmode=775\", \"fmode=664\"]\n", rubyString(hostDir), rubyString(cache.GuestPath))
+	}
+
+	var script strings.Builder
+	script.WriteString("sed -i '/^# BEGIN vagrant-mcp package-cache$/,/^# END vagrant-mcp package-cache$/d' /etc/environment\n")
+	script.WriteString("echo '# BEGIN vagrant-mcp package-cache' >> /etc/environment\n")
+	for _, cache := range PackageCaches {
+		if cache.EnvVar != "" {
+			fmt.Fprintf(&script, "echo '%s=%s' >> /etc/environment\n", cache.EnvVar, cache.GuestPath)
+		}
+	}
+	script.WriteString("echo '# END vagrant-mcp package-cache' >> /etc/environment\n")
+	fmt.Fprintf(&script, "echo 'APT::Keep-Downloaded-Packages \"true\";' > %s\n", guestAPTKeepFile)
+	fmt.Fprintf(&script, "echo 'Binary::apt::APT::Keep-Downloaded-Packages \"true\";' >> %s\n", guestAPTKeepFile)
+	script.WriteString("rm -f /etc/apt/apt.conf.d/docker-clean\n")
+	script.WriteString("mkdir -p /var/cache/apt/archives/partial")
+
+	b.WriteString("  config.vm.provision \"shell\", name: \"package-cache\", run: \"always\", inline: <<-SHELL\n")
+	writeIndented(&b, script.String())
+	b.WriteString("  SHELL\n")
+	return b.String(), nil
+}
+
+// dirSize returns the total size of the regular files under dir; a missing dir is empty
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// makeWritable adds owner write permission to the directories under root, which the Go
+// module cache makes read-only
+func makeWritable(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			return os.Chmod(path, info.Mode().Perm()|0200)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestVagrantPackageCacheConfig(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	if config, err := vagrantPackageCacheConfig(cacheDir, core.VMConfig{}); err != nil || config != "" {
+		t.Errorf("Expected no package cache settings but got %q, %v", config, err)
+	}
+
+	config, err := vagrantPackageCacheConfig(cacheDir, core.VMConfig{SharedPackageCache: true})
+	if err != nil {
+		t.Fatalf("Failed to generate package cache settings: %v", err)
+	}
+	expected := []string{
+		`config.vm.synced_folder '` + filepath.Join(cacheDir, "apt") + `', '/var/cache/apt/archives', owner: "vagrant", group: "vagrant", mount_options: ["dmode=775", "fmode=664"]`,
+		`config.vm.synced_folder '` + filepath.Join(cacheDir, "go") + `', '/var/cache/vagrant-mcp/go'`,
+		`name: "package-cache", run: "always"`,
+		`echo 'PIP_CACHE_DIR=/var/cache/vagrant-mcp/pip' >> /etc/environment`,
+		`APT::Keep-Downloaded-Packages "true";`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(config, fragment) {
+			t.Errorf("Expected package cache settings to contain %q but got:\n%s", fragment, config)
+		}
+	}
+	for _, cache := range PackageCaches {
+		if _, err := os.Stat(filepath.Join(cacheDir, cache.Name)); err != nil {
+			t.Errorf("Expected host cache directory for %s but got %v", cache.Name, err)
+		}
+	}
+}
+
+func TestPackageCacheVagrantfilePassesLint(t *testing.T) {
+	t.Setenv("MCP_PACKAGE_CACHE_DIR", filepath.Join(t.TempDir(), "cache"))
+	m, err := NewManagerWithRunner(filepath.Join(t.TempDir(), "vms"), cmdexec.NewFakeVagrant())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	config := core.VMConfig{Name: "cache-vm", Box: "ubuntu/jammy64", SharedPackageCache: true}
+	if err := m.CreateVM(context.Background(), "cache-vm", t.TempDir(), config); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(m.baseDir, "cache-vm", "Vagrantfile"))
+	if err != nil {
+		t.Fatalf("Failed to read Vagrantfile: %v", err)
+	}
+	if !strings.Contains(string(content), "# Shared package cache") {
+		t.Fatalf("Expected the Vagrantfile to share the package caches but got:\n%s", content)
+	}
+	for _, finding := range LintVagrantfile(string(content), nil) {
+		if strings.HasPrefix(finding.Rule, "synced_folder_") {
+			t.Errorf("Expected the shared package caches to pass lint_vagrantfile but got %+v", finding)
+		}
+	}
+}
+
+func TestClearPackageCache(t *testing.T) {
+	m := &Manager{baseDir: filepath.Join(t.TempDir(), "vms")}
+	t.Setenv("MCP_PACKAGE_CACHE_DIR", "")
+
+	// The Go module cache is read-only
+	module := filepath.Join(m.PackageCacheDir(), "go", "example.com", "mod@v1.0.0")
+	if err := os.MkdirAll(module, 0755); err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(module, "go.mod"), []byte("module example.com/mod\n"), 0444); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+	if err := os.Chmod(module, 0555); err != nil {
+		t.Fatalf("Failed to make cache read-only: %v", err)
+	}
+	aptDir := filepath.Join(m.PackageCacheDir(), "apt")
+	if err := os.MkdirAll(aptDir, 0755); err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(aptDir, "git.deb"), []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+
+	usage, err := m.PackageCacheUsage()
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if usage["apt"] != 10 || usage["go"] != 23 || usage["npm"] != 0 {
+		t.Errorf("Expected apt 10, go 23 and npm 0 bytes but got %v", usage)
+	}
+
+	if freed, err := m.ClearPackageCache("go"); err != nil || freed != 23 {
+		t.Errorf("Expected 23 bytes freed but got %d, %v", freed, err)
+	}
+	if _, err := os.Stat(filepath.Join(m.PackageCacheDir(), "go")); err != nil {
+		t.Errorf("Expected the cache directory to be kept but got %v", err)
+	}
+	if freed, err := m.ClearPackageCache(""); err != nil || freed != 10 {
+		t.Errorf("Expected 10 bytes freed but got %d, %v", freed, err)
+	}
+	if _, err := m.ClearPackageCache("cargo"); err == nil {
+		t.Errorf("Expected an error for an unknown cache")
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/boxes"
+	"github.com/vagrant-mcp/server/internal/config"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Vagrant providers the generated Vagrantfiles configure
+const (
+	ProviderVirtualBox = "virtualbox"
+	ProviderVMware     = "vmware_desktop"
+	ProviderParallels  = "parallels"
+	ProviderLibvirt    = "libvirt"
+	ProviderQEMU       = "qemu"
+)
+
+// Platform is the operating system and architecture of the host
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+}
+
+// String returns the platform as os/architecture
+func (p Platform) String() string {
+	return p.OS + "/" + p.Architecture
+}
+
+// PlatformOption is a provider that runs VMs on a host platform and the box it uses by default
+type PlatformOption struct {
+	Provider string `json:"provider"`
+	Box      string `json:"box"`
+	// Requires is what the host needs to run the provider
+	Requires string `json:"requires"`
+}
+
+// platformOptions lists the providers of each host platform in order of preference. The first
+// installed one is used when the VM names none.
+var platformOptions = map[string][]PlatformOption{
+	"darwin/arm64": {
+		{Provider: ProviderVMware, Box: "bento/ubuntu-22.04", Requires: "VMware Fusion and the vagrant-vmware-desktop plugin"},
+		{Provider: ProviderParallels, Box: "bento/ubuntu-22.04", Requires: "Parallels Desktop and the vagrant-parallels plugin"},
+		{Provider: ProviderQEMU, Box: "perk/ubuntu-2204-arm64", Requires: "QEMU and the vagrant-qemu plugin"},
+	},
+	"linux/arm64": {
+		{Provider: ProviderLibvirt, Box: "cloud-image/ubuntu-22.04", Requires: "libvirt and the vagrant-libvirt plugin"},
+		{Provider: ProviderQEMU, Box: "perk/ubuntu-2204-arm64", Requires: "QEMU and the vagrant-qemu plugin"},
+	},
+	"darwin/amd64": {
+		{Provider: ProviderVirtualBox, Box: config.DefaultVM.Boxes.Ubuntu, Requires: "VirtualBox"},
+		{Provider: ProviderVMware, Box: "bento/ubuntu-20.04", Requires: "VMware Fusion and the vagrant-vmware-desktop plugin"},
+		{Provider: ProviderParallels, Box: "bento/ubuntu-20.04", Requires: "Parallels Desktop and the vagrant-parallels plugin"},
+	},
+	"linux/amd64": {
+		{Provider: ProviderVirtualBox, Box: config.DefaultVM.Boxes.Ubuntu, Requires: "VirtualBox"},
+		{Provider: ProviderLibvirt, Box: "generic/ubuntu2004", Requires: "libvirt and the vagrant-libvirt plugin"},
+	},
+	"windows/amd64": {
+		{Provider: ProviderVirtualBox, Box: config.DefaultVM.Boxes.Ubuntu, Requires: "VirtualBox"},
+		{Provider: ProviderVMware, Box: "bento/ubuntu-20.04", Requires: "VMware Workstation and the vagrant-vmware-desktop plugin"},
+	},
+}
+
+// providerCommands are the host commands that show a provider is installed, as names looked up
+// in PATH or absolute paths
+var providerCommands = map[string][]string{
+	ProviderVirtualBox: {"VBoxManage"},
+	ProviderVMware:     {"vmrun", "/Applications/VMware Fusion.app/Contents/Library/vmrun"},
+	ProviderParallels:  {"prlctl"},
+	ProviderLibvirt:    {"virsh"},
+	ProviderQEMU:       {"qemu-system-aarch64", "qemu-system-x86_64"},
+}
+
+// providerNamePattern matches Vagrant provider names such as vmware_desktop
+var providerNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// amd64OnlyBoxPattern matches the official Ubuntu boxes, which are only built for amd64
+var amd64OnlyBoxPattern = regexp.MustCompile(`^ubuntu/[a-z]+64$`)
+
+// amd64OnlyBoxes are the standard boxes that are only built for amd64
+var amd64OnlyBoxes = map[string]bool{
+	config.DefaultVM.Boxes.Alpine: true,
+	config.DefaultVM.Boxes.Ubuntu: true,
+	config.DefaultVM.Boxes.Debian: true,
+	config.DefaultVM.Boxes.CentOS: true,
+	"generic/ubuntu2004":          true,
+}
+
+// providerInstalled reports whether the host has a provider; it is replaced in tests
+var providerInstalled = func(provider string) bool {
+	for _, command := range providerCommands[provider] {
+		if strings.HasPrefix(command, "/") {
+			if _, err := os.Stat(command); err == nil {
+				return true
+			}
+		} else if _, err := exec.LookPath(command); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	hostPlatformOnce sync.Once
+	hostPlatform     Platform
+)
+
+// HostPlatform returns the platform of the host, set with MCP_HOST_ARCH when the architecture
+// is misdetected. An amd64 build running under Rosetta on Apple Silicon reports arm64.
+func HostPlatform() Platform {
+	hostPlatformOnce.Do(func() {
+		hostPlatform = Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+		if arch := os.Getenv("MCP_HOST_ARCH"); arch != "" {
+			hostPlatform.Architecture = boxes.NormalizeArchitecture(arch)
+			return
+		}
+		if hostPlatform.OS == "darwin" && hostPlatform.Architecture == "amd64" {
+			output, err := exec.Command("sysctl", "-n", "sysctl.proc_translated").Output()
+			if err == nil && strings.TrimSpace(string(output)) == "1" {
+				hostPlatform.Architecture = "arm64"
+			}
+		}
+		log.Debug().Str("platform", hostPlatform.String()).Msg("Detected host platform")
+	})
+	return hostPlatform
+}
+
+// PlatformOptions returns the providers that run VMs on a platform, in order of preference
+func PlatformOptions(platform Platform) []PlatformOption {
+	return platformOptions[platform.String()]
+}
+
+// SelectPlatform picks the provider and default box for a VM on a platform. provider, or else
+// VAGRANT_DEFAULT_PROVIDER, chooses the provider; otherwise the first installed provider of the
+// platform is used. It fails, suggesting alternatives, when the provider or box cannot run on
+// an arm64 host.
+func SelectPlatform(platform Platform, provider, box string) (PlatformOption, error) {
+	options := PlatformOptions(platform)
+	if provider == "" {
+		provider = os.Getenv("VAGRANT_DEFAULT_PROVIDER")
+	}
+
+	if provider != "" && !providerNamePattern.MatchString(provider) {
+		return PlatformOption{}, errors.InvalidInput(fmt.Sprintf("invalid provider '%s'", provider))
+	}
+
+	var selected *PlatformOption
+	if provider != "" {
+		for i := range options {
+			if options[i].Provider == provider {
+				selected = &options[i]
+				break
+			}
+		}
+		if selected == nil {
+			if platform.Architecture == "arm64" {
+				return PlatformOption{}, unsupportedPlatformError(platform, fmt.Sprintf("provider '%s' cannot run VMs on this %s host", provider, platform), options)
+			}
+			// Any provider may be chosen on amd64 hosts, with the standard box
+			return PlatformOption{Provider: provider, Box: config.DefaultVM.Boxes.Ubuntu}, nil
+		}
+	} else {
+		for i := range options {
+			if providerInstalled(options[i].Provider) {
+				selected = &options[i]
+				break
+			}
+		}
+		if selected == nil && len(options) > 0 {
+			selected = &options[0]
+		}
+		if selected == nil {
+			if platform.Architecture == "arm64" {
+				return PlatformOption{}, unsupportedPlatformError(platform, fmt.Sprintf("no provider is known to run VMs on this %s host", platform), nil)
+			}
+			selected = &PlatformOption{Provider: ProviderVirtualBox, Box: config.DefaultVM.Boxes.Ubuntu}
+		}
+	}
+
+	if platform.Architecture == "arm64" && (amd64OnlyBoxes[box] || amd64OnlyBoxPattern.MatchString(box)) {
+		// Suggest the box of the chosen provider first
+		alternatives := []PlatformOption{*selected}
+		for _, option := range options {
+			if option.Provider != selected.Provider {
+				alternatives = append(alternatives, option)
+			}
+		}
+		return PlatformOption{}, unsupportedPlatformError(platform, fmt.Sprintf("box '%s' is only built for amd64 and cannot run on this %s host", box, platform), alternatives)
+	}
+	return *selected, nil
+}
+
+// unsupportedPlatformError describes why a VM cannot run on the host and the providers and
+// boxes that can
+func unsupportedPlatformError(platform Platform, reason string, alternatives []PlatformOption) error {
+	suggestions := make([]string, 0, len(alternatives))
+	for _, option := range alternatives {
+		suggestions = append(suggestions, fmt.Sprintf("box '%s' with provider '%s' (needs %s)", option.Box, option.Provider, option.Requires))
+	}
+	message := reason
+	if len(suggestions) > 0 {
+		message += "; use " + strings.Join(suggestions, ", or ")
+	}
+	message += fmt.Sprintf(", or find a box with search_boxes and architecture '%s'", platform.Architecture)
+	return errors.New(errors.CodeInvalidInput, message).
+		WithContext("platform", platform.String()).
+		WithContext("alternatives", alternatives)
+}
+
+// vagrantProviderBlock returns the provider block of a generated Vagrantfile. VMs created before
+// providers were recorded use VirtualBox.
+func vagrantProviderBlock(name string, config core.VMConfig, platform Platform) string {
+	switch config.Provider {
+	case ProviderVMware:
+		block := fmt.Sprintf(`  config.vm.provider "vmware_desktop" do |v|
+    v.gui = false
+    v.vmx["displayName"] = "%s"
+    v.vmx["memsize"] = "%d"
+    v.vmx["numvcpus"] = "%d"`, name, config.Memory, config.CPU)
+		if config.LinkedClone {
+			block += "\n    v.linked_clone = true"
+		}
+		return block + "\n  end"
+	case ProviderParallels:
+		return fmt.Sprintf(`  config.vm.provider "parallels" do |prl|
+    prl.name = "%s"
+    prl.memory = %d
+    prl.cpus = %d
+    prl.linked_clone = %t
+  end`, name, config.Memory, config.CPU, config.LinkedClone)
+	case ProviderLibvirt:
+		block := fmt.Sprintf(`  config.vm.provider "libvirt" do |lv|
+    lv.memory = %d
+    lv.cpus = %d`, config.Memory, config.CPU)
+		if config.SyncType == "virtiofs" {
+			// virtiofsd maps the guest memory, which must be shared with it
+			block += `
+    lv.memorybacking :source, :type => "memfd"
+    lv.memorybacking :access, :mode => "shared"`
+		}
+		return block + "\n  end"
+	case ProviderQEMU:
+		// vagrant-qemu defaults to arm64 guests accelerated with Hypervisor.framework
+		accel := "hvf"
+		if platform.OS != "darwin" {
+			accel = "kvm"
+		}
+		block := fmt.Sprintf(`  config.vm.provider "qemu" do |qe|
+    qe.memory = "%dM"
+    qe.smp = "%d"`, config.Memory, config.CPU)
+		if platform.Architecture == "amd64" {
+			block += fmt.Sprintf(`
+    qe.arch = "x86_64"
+    qe.machine = "q35,accel=%s"
+    qe.cpu = "max"
+    qe.net_device = "virtio-net-pci"`, accel)
+		} else {
+			block += fmt.Sprintf(`
+    qe.machine = "virt,accel=%s,highmem=on"`, accel)
+		}
+		return block + "\n  end"
+	case ProviderVirtualBox, "":
+		return fmt.Sprintf(`  config.vm.provider "virtualbox" do |vb|
+    vb.gui = false
+    vb.name = "%s"
+    vb.memory = %d
+    vb.cpus = %d%s
+
+    # Performance optimizations
+    vb.customize ["modifyvm", :id, "--natdnshostresolver1", "on"]
+    vb.customize ["modifyvm", :id, "--natdnsproxy1", "on"]
+    vb.customize ["modifyvm", :id, "--ioapic", "on"]
+  end`, name, config.Memory, config.CPU, vagrantProviderConfig(config))
+	}
+	// Other providers get their defaults
+	return fmt.Sprintf(`  config.vm.provider "%s"`, config.Provider)
+}
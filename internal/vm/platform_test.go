@@ -0,0 +1,128 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// stubProviders makes the listed providers the installed ones for a test
+func stubProviders(t *testing.T, installed ...string) {
+	original := providerInstalled
+	t.Cleanup(func() { providerInstalled = original })
+	providerInstalled = func(provider string) bool {
+		for _, name := range installed {
+			if name == provider {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestSelectPlatform(t *testing.T) {
+	t.Setenv("VAGRANT_DEFAULT_PROVIDER", "")
+	appleSilicon := Platform{OS: "darwin", Architecture: "arm64"}
+	linuxAMD64 := Platform{OS: "linux", Architecture: "amd64"}
+
+	testCases := []struct {
+		name             string
+		platform         Platform
+		installed        []string
+		provider         string
+		box              string
+		expectedProvider string
+		expectedBox      string
+		expectedError    string
+	}{
+		{"amd64 defaults to VirtualBox", linuxAMD64, nil, "", "", ProviderVirtualBox, "ubuntu/focal64", ""},
+		{"amd64 uses the installed provider", linuxAMD64, []string{ProviderLibvirt}, "", "", ProviderLibvirt, "generic/ubuntu2004", ""},
+		{"amd64 accepts other providers", linuxAMD64, nil, "hyperv", "", "hyperv", "ubuntu/focal64", ""},
+		{"arm64 prefers VMware", appleSilicon, nil, "", "", ProviderVMware, "bento/ubuntu-22.04", ""},
+		{"arm64 uses the installed provider", appleSilicon, []string{ProviderQEMU}, "", "", ProviderQEMU, "perk/ubuntu-2204-arm64", ""},
+		{"arm64 requested provider", appleSilicon, []string{ProviderVMware}, ProviderParallels, "", ProviderParallels, "bento/ubuntu-22.04", ""},
+		{"arm64 rejects VirtualBox", appleSilicon, nil, ProviderVirtualBox, "", "", "", "provider 'virtualbox' cannot run VMs on this darwin/arm64 host"},
+		{"arm64 rejects amd64 boxes", appleSilicon, []string{ProviderQEMU}, "", "ubuntu/jammy64", "", "", "box 'ubuntu/jammy64' is only built for amd64"},
+		{"arm64 accepts other boxes", appleSilicon, nil, "", "bento/debian-12", ProviderVMware, "bento/ubuntu-22.04", ""},
+		{"unknown arm64 platform", Platform{OS: "windows", Architecture: "arm64"}, nil, "", "", "", "", "no provider is known"},
+		{"invalid provider", linuxAMD64, nil, `virtualbox" do`, "", "", "", "invalid provider"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubProviders(t, tc.installed...)
+			option, err := SelectPlatform(tc.platform, tc.provider, tc.box)
+			if tc.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+					t.Fatalf("Expected error containing %q but got %v", tc.expectedError, err)
+				}
+				if !errors.Is(err, errors.CodeInvalidInput) {
+					t.Errorf("Expected an invalid input error but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if option.Provider != tc.expectedProvider || option.Box != tc.expectedBox {
+				t.Errorf("Expected %s with %s but got %s with %s", tc.expectedProvider, tc.expectedBox, option.Provider, option.Box)
+			}
+		})
+	}
+}
+
+func TestSelectPlatformSuggestsAlternatives(t *testing.T) {
+	t.Setenv("VAGRANT_DEFAULT_PROVIDER", "")
+	stubProviders(t, ProviderQEMU)
+	_, err := SelectPlatform(Platform{OS: "darwin", Architecture: "arm64"}, "", "ubuntu/focal64")
+	if err == nil {
+		t.Fatalf("Expected error for an amd64 box on Apple Silicon")
+	}
+	message := err.Error()
+	// The installed provider is suggested first
+	qemu := strings.Index(message, "box 'perk/ubuntu-2204-arm64' with provider 'qemu'")
+	vmware := strings.Index(message, "box 'bento/ubuntu-22.04' with provider 'vmware_desktop'")
+	if qemu < 0 || vmware < qemu {
+		t.Errorf("Expected the QEMU box then the VMware box in %q", message)
+	}
+	if !strings.Contains(message, "search_boxes") {
+		t.Errorf("Expected search_boxes to be suggested in %q", message)
+	}
+}
+
+func TestSelectPlatformDefaultProviderEnv(t *testing.T) {
+	t.Setenv("VAGRANT_DEFAULT_PROVIDER", ProviderLibvirt)
+	stubProviders(t)
+	option, err := SelectPlatform(Platform{OS: "linux", Architecture: "arm64"}, "", "")
+	if err != nil || option.Provider != ProviderLibvirt {
+		t.Errorf("Expected libvirt from VAGRANT_DEFAULT_PROVIDER but got %+v, %v", option, err)
+	}
+}
+
+func TestVagrantProviderBlock(t *testing.T) {
+	config := core.VMConfig{CPU: 2, Memory: 2048}
+	testCases := []struct {
+		provider string
+		platform Platform
+		expected []string
+	}{
+		{"", Platform{OS: "linux", Architecture: "amd64"}, []string{`config.vm.provider "virtualbox" do |vb|`, "vb.memory = 2048", "vb.cpus = 2"}},
+		{ProviderVMware, Platform{OS: "darwin", Architecture: "arm64"}, []string{`config.vm.provider "vmware_desktop" do |v|`, `v.vmx["memsize"] = "2048"`, `v.vmx["numvcpus"] = "2"`}},
+		{ProviderParallels, Platform{OS: "darwin", Architecture: "arm64"}, []string{`config.vm.provider "parallels" do |prl|`, "prl.memory = 2048"}},
+		{ProviderLibvirt, Platform{OS: "linux", Architecture: "arm64"}, []string{`config.vm.provider "libvirt" do |lv|`, "lv.cpus = 2"}},
+		{ProviderQEMU, Platform{OS: "darwin", Architecture: "arm64"}, []string{`qe.memory = "2048M"`, `qe.machine = "virt,accel=hvf,highmem=on"`}},
+		{ProviderQEMU, Platform{OS: "linux", Architecture: "amd64"}, []string{`qe.arch = "x86_64"`, `qe.machine = "q35,accel=kvm"`}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.provider+" "+tc.platform.String(), func(t *testing.T) {
+			config.Provider = tc.provider
+			block := vagrantProviderBlock("dev", config, tc.platform)
+			for _, expected := range tc.expected {
+				if !strings.Contains(block, expected) {
+					t.Errorf("Expected %q in provider block:\n%s", expected, block)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Provisioner statuses
+const (
+	ProvisionerSucceeded = "succeeded"
+	ProvisionerFailed    = "failed"
+	// ProvisionerNotRun is a requested provisioner vagrant did not reach
+	ProvisionerNotRun = "not_run"
+)
+
+// maxProvisionerOutput bounds the output kept for each provisioner
+const maxProvisionerOutput = 4096
+
+// ProvisionerResult is the outcome of a provisioner run by vagrant provision
+type ProvisionerResult struct {
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Output is the end of the provisioner's output
+	Output string `json:"output,omitempty"`
+}
+
+// ProvisionResult is the outcome of vagrant provision on a VM
+type ProvisionResult struct {
+	VMName          string              `json:"vm_name"`
+	Success         bool                `json:"success"`
+	DurationSeconds float64             `json:"duration_seconds"`
+	Provisioners    []ProvisionerResult `json:"provisioners"`
+	Warnings        []string            `json:"warnings,omitempty"`
+	// Error is the cause vagrant reported when it failed, and ErrorType its error class
+	Error     string `json:"error,omitempty"`
+	ErrorType string `json:"error_type,omitempty"`
+}
+
+// ProvisionVM runs the provisioners of a running VM with 'vagrant provision', only those
+// named in provisioners when it is not empty, without reloading the VM. A failed provisioner
+// is reported in the result rather than as an error.
+func (m *Manager) ProvisionVM(ctx context.Context, name string, provisioners []string) (ProvisionResult, error) {
+	for _, provisioner := range provisioners {
+		if strings.TrimSpace(provisioner) == "" || strings.Contains(provisioner, ",") {
+			return ProvisionResult{}, errors.InvalidInput(fmt.Sprintf("invalid provisioner name %q", provisioner))
+		}
+	}
+	state, err := m.GetVMState(ctx, name)
+	if err != nil {
+		return ProvisionResult{}, err
+	}
+	if state != core.Running {
+		return ProvisionResult{}, errors.New(errors.CodeInvalidState, fmt.Sprintf("VM '%s' is %s; provisioners run in a running VM", name, state))
+	}
+	release, err := m.operations.acquire(name, OperationProvision)
+	if err != nil {
+		return ProvisionResult{}, err
+	}
+	defer release()
+
+	args := []string{"provision", machineReadableFlag}
+	if len(provisioners) > 0 {
+		args = append(args, "--provision-with", strings.Join(provisioners, ","))
+	}
+	started := time.Now()
+	ctx, span := traceVagrant(ctx, name, "provision")
+	args = m.vagrantArgs(name, args...)
+	timed := &timedOutput{}
+	runErr := m.vagrant().Run(ctx, cmdexec.VagrantCommand{Dir: m.getVMDir(name), Args: args, Stdout: timed, Stderr: timed})
+	span.EndCommand(runErr)
+	end := time.Now()
+	output := ParseVagrantOutput(string(timed.Bytes()))
+	m.writeOperationLog(name, core.OperationLogProvision, cmdexec.CommandLine(args), started, []byte(output.Text), runErr)
+
+	timed.mu.Lock()
+	lines := humanTimedLines(timed.lines)
+	timed.mu.Unlock()
+	result := ProvisionResult{
+		VMName:          name,
+		Success:         runErr == nil,
+		DurationSeconds: roundSeconds(end.Sub(started)),
+		Provisioners:    parseProvisionerResults(lines, end, runErr != nil, provisioners),
+		Warnings:        output.Warnings,
+	}
+	if runErr != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result.Error = output.Cause()
+		if output.Error != nil {
+			result.ErrorType = output.Error.Type
+		}
+		publishOperationFailure(name, "provision", output)
+		log.Warn().Err(runErr).Str("name", name).Msg("VM provisioning failed")
+		return result, nil
+	}
+	log.Info().Str("name", name).Strs("provisioners", provisioners).Msg("VM provisioned successfully")
+	return result, nil
+}
+
+// parseProvisionerResults splits timed vagrant provision output into the provisioners it ran.
+// Vagrant stops at the first failing provisioner, so when the run failed the last one started
+// failed; requested provisioners that never started are reported as not run.
+func parseProvisionerResults(lines []timedLine, end time.Time, failed bool, requested []string) []ProvisionerResult {
+	type section struct {
+		name    string
+		started time.Time
+		output  []string
+	}
+	var sections []section
+	for _, line := range lines {
+		if match := provisionerPattern.FindStringSubmatch(line.text); match != nil {
+			sections = append(sections, section{name: strings.TrimSpace(match[1]), started: line.at})
+			continue
+		}
+		if len(sections) > 0 {
+			current := &sections[len(sections)-1]
+			current.output = append(current.output, line.text)
+		}
+	}
+
+	results := []ProvisionerResult{}
+	for i, s := range sections {
+		sectionEnd := end
+		if i+1 < len(sections) {
+			sectionEnd = sections[i+1].started
+		}
+		status := ProvisionerSucceeded
+		if failed && i == len(sections)-1 {
+			status = ProvisionerFailed
+		}
+		results = append(results, ProvisionerResult{
+			Name:            s.name,
+			Status:          status,
+			DurationSeconds: roundSeconds(sectionEnd.Sub(s.started)),
+			Output:          tail(strings.TrimSpace(strings.Join(s.output, "\n")), maxProvisionerOutput),
+		})
+	}
+	for _, name := range requested {
+		ran := false
+		for _, result := range results {
+			// Named provisioners are reported as "name (type)"
+			ran = ran || result.Name == name || strings.HasPrefix(result.Name, name+" (")
+		}
+		if !ran {
+			results = append(results, ProvisionerResult{Name: name, Status: ProvisionerNotRun})
+		}
+	}
+	return results
+}
+
+// tail returns the last max bytes of s
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return "..." + s[len(s)-max:]
+}
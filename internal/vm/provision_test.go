@@ -0,0 +1,64 @@
+package vm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProvisionerResults(t *testing.T) {
+	start := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	lines := []timedLine{
+		{at(0), "==> default: Running provisioner: setup (shell)..."},
+		{at(1), "    default: Running: inline script"},
+		{at(12), "    default: setup complete"},
+		{at(20), "==> default: Running provisioner: migrate (shell)..."},
+		{at(21), "    default: Running: inline script"},
+		{at(25), "    default: ERROR: relation \"users\" already exists"},
+		{at(26), "The SSH command responded with a non-zero exit status."},
+	}
+
+	testCases := []struct {
+		name      string
+		failed    bool
+		requested []string
+		expected  []ProvisionerResult
+	}{
+		{
+			name:   "all succeeded",
+			failed: false,
+			expected: []ProvisionerResult{
+				{Name: "setup (shell)", Status: ProvisionerSucceeded, DurationSeconds: 20},
+				{Name: "migrate (shell)", Status: ProvisionerSucceeded, DurationSeconds: 10},
+			},
+		},
+		{
+			name:      "last provisioner failed",
+			failed:    true,
+			requested: []string{"setup", "migrate", "seed"},
+			expected: []ProvisionerResult{
+				{Name: "setup (shell)", Status: ProvisionerSucceeded, DurationSeconds: 20},
+				{Name: "migrate (shell)", Status: ProvisionerFailed, DurationSeconds: 10},
+				{Name: "seed", Status: ProvisionerNotRun},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := parseProvisionerResults(lines, at(30), tc.failed, tc.requested)
+			if len(results) != len(tc.expected) {
+				t.Fatalf("Expected %d provisioners but got %d: %+v", len(tc.expected), len(results), results)
+			}
+			for i, result := range results {
+				expected := tc.expected[i]
+				if result.Name != expected.Name || result.Status != expected.Status || result.DurationSeconds != expected.DurationSeconds {
+					t.Errorf("Expected provisioner %d to be %+v but got %+v", i, expected, result)
+				}
+			}
+			if output := results[1].Output; output != "default: Running: inline script\n    default: ERROR: relation \"users\" already exists\nThe SSH command responded with a non-zero exit status." {
+				t.Errorf("Expected the output of the migrate provisioner but got %q", output)
+			}
+		})
+	}
+}
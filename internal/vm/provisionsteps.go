@@ -0,0 +1,99 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// When provisioning steps run
+const (
+	ProvisionRunOnce   = "once"
+	ProvisionRunAlways = "always"
+)
+
+// reservedProvisioners are the names of the provisioners the server generates, which steps
+// cannot take
+var reservedProvisioners = []string{"shell", "startup", "cloud-init", "grow-root", "disks", "proxy", "package-cache",
+	dotfilesProvisioner, firewallProvisioner, networkHostsProvisioner}
+
+// ValidateProvisionSteps checks the provisioning steps of a VM configuration. Script paths are
+// resolved against the project path.
+func ValidateProvisionSteps(config core.VMConfig) error {
+	names := make(map[string]bool)
+	for _, step := range config.ProvisionSteps {
+		if !snippetNamePattern.MatchString(step.Name) {
+			return errors.InvalidInput(fmt.Sprintf("invalid provisioning step name '%s': use letters, digits, '_' or '-'", step.Name))
+		}
+		if names[step.Name] {
+			return errors.InvalidInput(fmt.Sprintf("duplicate provisioning step '%s'", step.Name))
+		}
+		names[step.Name] = true
+		for _, reserved := range reservedProvisioners {
+			if step.Name == reserved {
+				return errors.InvalidInput(fmt.Sprintf("provisioning step name '%s' is used by a generated provisioner", step.Name))
+			}
+		}
+		switch step.Run {
+		case "", ProvisionRunOnce, ProvisionRunAlways:
+		default:
+			return errors.InvalidInput(fmt.Sprintf("provisioning step '%s' has unknown run '%s': use once or always", step.Name, step.Run))
+		}
+		if (strings.TrimSpace(step.Inline) == "") == (step.Path == "") {
+			return errors.InvalidInput(fmt.Sprintf("provisioning step '%s' needs either inline or path", step.Name))
+		}
+		if len(step.Inline) > maxSnippetBytes {
+			return errors.InvalidInput(fmt.Sprintf("provisioning step '%s' is larger than %d bytes", step.Name, maxSnippetBytes))
+		}
+		if step.Path != "" {
+			path := provisionStepPath(config, step)
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				return errors.InvalidInput(fmt.Sprintf("script of provisioning step '%s' not found: expected %s", step.Name, path))
+			}
+		}
+	}
+	return nil
+}
+
+// provisionStepPath returns the host path of the script of a step
+func provisionStepPath(config core.VMConfig, step core.ProvisionStep) string {
+	if filepath.IsAbs(step.Path) || config.ProjectPath == "" {
+		return step.Path
+	}
+	return filepath.Join(config.ProjectPath, step.Path)
+}
+
+// vagrantProvisionStepsConfig returns a provisioner for each provisioning step of a VM, in
+// order, or an empty string when it has none. Each provisioner is named after its step, so
+// vagrant reports which step failed and provision_vm can rerun it alone.
+func vagrantProvisionStepsConfig(config core.VMConfig) string {
+	if len(config.ProvisionSteps) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n  # Provisioning steps\n")
+	for _, step := range config.ProvisionSteps {
+		fmt.Fprintf(&b, "  config.vm.provision \"shell\", name: \"%s\"", step.Name)
+		if step.Run == ProvisionRunAlways {
+			b.WriteString(", run: \"always\"")
+		}
+		if step.Privileged != nil {
+			fmt.Fprintf(&b, ", privileged: %t", *step.Privileged)
+		}
+		if step.Path != "" {
+			fmt.Fprintf(&b, ", path: %s\n", rubyString(provisionStepPath(config, step)))
+			continue
+		}
+		// base64 keeps the script intact through Ruby and shell quoting
+		fmt.Fprintf(&b, ", inline: \"%s\".unpack1(\"m\")\n", base64.StdEncoding.EncodeToString([]byte(step.Inline)))
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestValidateProvisionSteps(t *testing.T) {
+	projectPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectPath, "setup.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	testCases := []struct {
+		name  string
+		steps []core.ProvisionStep
+		valid bool
+	}{
+		{"inline and path", []core.ProvisionStep{{Name: "deps", Inline: "apt-get install -y jq"}, {Name: "seed", Path: "setup.sh", Run: ProvisionRunAlways}}, true},
+		{"invalid name", []core.ProvisionStep{{Name: "install deps", Inline: "true"}}, false},
+		{"duplicate name", []core.ProvisionStep{{Name: "deps", Inline: "true"}, {Name: "deps", Inline: "false"}}, false},
+		{"reserved name", []core.ProvisionStep{{Name: "startup", Inline: "true"}}, false},
+		{"unknown run", []core.ProvisionStep{{Name: "deps", Inline: "true", Run: "never"}}, false},
+		{"no script", []core.ProvisionStep{{Name: "deps"}}, false},
+		{"inline and path together", []core.ProvisionStep{{Name: "deps", Inline: "true", Path: "setup.sh"}}, false},
+		{"missing script", []core.ProvisionStep{{Name: "deps", Path: "missing.sh"}}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateProvisionSteps(core.VMConfig{ProjectPath: projectPath, ProvisionSteps: tc.steps})
+			if tc.valid && err != nil {
+				t.Errorf("Expected no error but got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("Expected an error but got none")
+			}
+		})
+	}
+}
+
+func TestVagrantProvisionStepsConfig(t *testing.T) {
+	if snippet := vagrantProvisionStepsConfig(core.VMConfig{}); snippet != "" {
+		t.Errorf("Expected no provisioners without steps but got %q", snippet)
+	}
+
+	unprivileged := false
+	config := core.VMConfig{
+		ProjectPath: "/home/dev/web",
+		ProvisionSteps: []core.ProvisionStep{
+			{Name: "deps", Inline: "apt-get install -y jq"},
+			{Name: "migrate", Path: "scripts/migrate.sh", Run: ProvisionRunAlways, Privileged: &unprivileged},
+		},
+		StartupCommands: []string{"make dev"},
+	}
+	content, err := renderVagrantfile(vagrantfileData{
+		Box:     "ubuntu/focal64",
+		Setup:   []string{"sudo apt-get install -y nodejs"},
+		Steps:   vagrantProvisionStepsConfig(config),
+		Startup: vagrantStartupConfig(config),
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to render Vagrantfile: %v", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("apt-get install -y jq"))
+	deps := `  config.vm.provision "shell", name: "deps", inline: "` + encoded + `".unpack1("m")` + "\n"
+	migrate := `  config.vm.provision "shell", name: "migrate", run: "always", privileged: false, path: '` + filepath.Join("/home/dev/web", "scripts/migrate.sh") + `'` + "\n"
+	positions := []int{
+		strings.Index(content, "sudo apt-get install -y nodejs"),
+		strings.Index(content, deps),
+		strings.Index(content, migrate),
+		strings.Index(content, `name: "startup"`),
+	}
+	for i, position := range positions {
+		if position < 0 || i > 0 && position < positions[i-1] {
+			t.Fatalf("Expected the setup, deps, migrate and startup provisioners in order but got:\n%s", content)
+		}
+	}
+}
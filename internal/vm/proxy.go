@@ -0,0 +1,146 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Guest locations of the proxy and CA settings
+const (
+	guestAPTProxyFile = "/etc/apt/apt.conf.d/95vagrant-mcp-proxy"
+	guestCADir        = "/usr/local/share/ca-certificates/vagrant-mcp"
+	guestCABundle     = "/etc/ssl/certs/ca-certificates.crt"
+)
+
+var (
+	// proxyURLUnsafe matches characters that cannot appear in a proxy URL written into the
+	// provisioning script
+	proxyURLUnsafe = regexp.MustCompile("[\\s'\"\\\\`$#]")
+	// noProxyPattern restricts no_proxy lists to hosts, domains, addresses and CIDRs
+	noProxyPattern = regexp.MustCompile(`^[A-Za-z0-9.,*:/_\-\[\]]*$`)
+)
+
+// ValidateProxy checks the proxy settings and CA certificates of a VM configuration
+func ValidateProxy(config core.VMConfig) error {
+	if config.Proxy != nil {
+		for name, value := range map[string]string{"http_proxy": config.Proxy.HTTPProxy, "https_proxy": config.Proxy.HTTPSProxy} {
+			if value == "" {
+				continue
+			}
+			parsed, err := url.Parse(value)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || proxyURLUnsafe.MatchString(value) {
+				return errors.InvalidInput(fmt.Sprintf("invalid %s '%s': use http://host:port or https://host:port", name, value))
+			}
+		}
+		if !noProxyPattern.MatchString(config.Proxy.NoProxy) {
+			return errors.InvalidInput(fmt.Sprintf("invalid no_proxy '%s': use a comma-separated list of hosts, domains or CIDRs", config.Proxy.NoProxy))
+		}
+	}
+	for i, certificate := range config.CACertificates {
+		if _, err := normalizeCertificates(certificate); err != nil {
+			return errors.InvalidInput(fmt.Sprintf("invalid CA certificate %d: %v", i+1, err))
+		}
+	}
+	return nil
+}
+
+// LoadCACertificate returns the PEM certificates in source, which is either PEM content or the
+// path of a PEM file on the host
+func LoadCACertificate(source string) (string, error) {
+	content := source
+	if !strings.Contains(source, "-----BEGIN") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		content = string(data)
+	}
+	return normalizeCertificates(content)
+}
+
+// normalizeCertificates re-encodes the CERTIFICATE blocks of PEM content, dropping anything else
+func normalizeCertificates(content string) (string, error) {
+	var b strings.Builder
+	rest := []byte(content)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			b.Write(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes}))
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("no PEM encoded certificate found")
+	}
+	return b.String(), nil
+}
+
+// vagrantProxyConfig returns the provisioner that configures the proxy for apt and login
+// environments and installs the CA certificates, or an empty string when neither is set.
+// It runs before the setup provisioner, which needs the proxy for apt-get.
+func vagrantProxyConfig(config core.VMConfig) string {
+	proxy := config.Proxy
+	if proxy == nil {
+		proxy = &core.Proxy{}
+	}
+	if *proxy == (core.Proxy{}) && len(config.CACertificates) == 0 {
+		return ""
+	}
+
+	var environment []string
+	for _, setting := range []struct{ name, value string }{
+		{"http_proxy", proxy.HTTPProxy},
+		{"https_proxy", proxy.HTTPSProxy},
+		{"no_proxy", proxy.NoProxy},
+	} {
+		if setting.value != "" {
+			environment = append(environment, setting.name+"="+setting.value, strings.ToUpper(setting.name)+"="+setting.value)
+		}
+	}
+	if len(config.CACertificates) > 0 {
+		// Node and Python requests ship their own CA bundles
+		environment = append(environment, "NODE_EXTRA_CA_CERTS="+guestCABundle, "REQUESTS_CA_BUNDLE="+guestCABundle)
+	}
+
+	var script strings.Builder
+	script.WriteString("sed -i '/^# BEGIN vagrant-mcp proxy$/,/^# END vagrant-mcp proxy$/d' /etc/environment\n")
+	script.WriteString("echo '# BEGIN vagrant-mcp proxy' >> /etc/environment\n")
+	for _, line := range environment {
+		fmt.Fprintf(&script, "echo '%s' >> /etc/environment\n", line)
+	}
+	script.WriteString("echo '# END vagrant-mcp proxy' >> /etc/environment\n")
+	fmt.Fprintf(&script, "rm -f %s\n", guestAPTProxyFile)
+	if proxy.HTTPProxy != "" {
+		fmt.Fprintf(&script, "echo 'Acquire::http::Proxy \"%s\";' >> %s\n", proxy.HTTPProxy, guestAPTProxyFile)
+	}
+	if proxy.HTTPSProxy != "" {
+		fmt.Fprintf(&script, "echo 'Acquire::https::Proxy \"%s\";' >> %s\n", proxy.HTTPSProxy, guestAPTProxyFile)
+	}
+	fmt.Fprintf(&script, "rm -rf %[1]s\nmkdir -p %[1]s", guestCADir)
+	for i, certificate := range config.CACertificates {
+		// base64 keeps each certificate on one line of the heredoc
+		fmt.Fprintf(&script, "\necho '%s' | base64 -d > %s/ca-%d.crt", base64.StdEncoding.EncodeToString([]byte(certificate)), guestCADir, i+1)
+	}
+	script.WriteString("\nupdate-ca-certificates --fresh")
+
+	var b strings.Builder
+	b.WriteString("\n  # Proxy and CA certificate settings\n")
+	b.WriteString("  config.vm.provision \"shell\", name: \"proxy\", run: \"always\", inline: <<-SHELL\n")
+	writeIndented(&b, script.String())
+	b.WriteString("  SHELL\n")
+	return b.String()
+}
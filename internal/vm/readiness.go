@@ -0,0 +1,137 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// DefaultReadyTimeout is how long ensure_dev_vm waits for a started VM to finish booting
+const DefaultReadyTimeout = 5 * time.Minute
+
+// readyPollInterval is how often readiness is checked while waiting
+var readyPollInterval = 2 * time.Second
+
+// readinessNone is the state of cloud-init or systemd in guests that do not have it
+const readinessNone = "none"
+
+// readinessCommand prints the state of cloud-init and systemd in the guest, one key=value per
+// line. Guests without them report none.
+const readinessCommand = `if command -v cloud-init >/dev/null 2>&1; then echo "cloud_init=$(cloud-init status 2>/dev/null | sed -n 's/^status: //p')"; else echo cloud_init=none; fi; ` +
+	`if command -v systemctl >/dev/null 2>&1; then echo "system=$(systemctl is-system-running 2>/dev/null)"; ` +
+	`echo "failed_units=$(systemctl list-units --state=failed --no-legend --plain 2>/dev/null | awk '{print $1}' | tr '\n' ' ')"; else echo system=none; fi`
+
+// Readiness is whether a VM finished booting: cloud-init is done and systemd reached its
+// default target
+type Readiness struct {
+	Ready bool `json:"ready"`
+	// CloudInit is the status cloud-init reports, e.g. running or done, or none
+	CloudInit string `json:"cloud_init"`
+	// System is the state systemctl is-system-running reports, e.g. starting or running, or none
+	System string `json:"system"`
+	// FailedUnits are the systemd units that failed when the system is degraded
+	FailedUnits   []string `json:"failed_units,omitempty"`
+	WaitedSeconds float64  `json:"waited_seconds"`
+	// Error is why the VM will not become ready, e.g. cloud-init failed
+	Error string `json:"error,omitempty"`
+}
+
+// Describe summarises the readiness of a VM
+func (r Readiness) Describe() string {
+	parts := []string{fmt.Sprintf("cloud-init %s", r.CloudInit), fmt.Sprintf("system %s", r.System)}
+	if len(r.FailedUnits) > 0 {
+		parts = append(parts, "failed units: "+strings.Join(r.FailedUnits, ", "))
+	}
+	if r.Error != "" {
+		parts = append(parts, r.Error)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseReadiness parses the output of the readiness command. A degraded system is ready, with
+// its failed units reported; failed cloud-init will not become ready.
+func ParseReadiness(output string) Readiness {
+	readiness := Readiness{CloudInit: readinessNone, System: readinessNone}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "cloud_init":
+			if value != "" {
+				readiness.CloudInit = value
+			}
+		case "system":
+			if value != "" {
+				readiness.System = value
+			}
+		case "failed_units":
+			readiness.FailedUnits = strings.Fields(value)
+		}
+	}
+
+	cloudInitDone := false
+	switch readiness.CloudInit {
+	// degraded done means cloud-init recovered from its errors
+	case readinessNone, "done", "degraded done", "disabled", "not run", "not started":
+		cloudInitDone = true
+	case "error":
+		readiness.Error = "cloud-init finished with errors; see /var/log/cloud-init-output.log in the guest"
+	}
+	systemUp := false
+	switch readiness.System {
+	case readinessNone, "running", "degraded", "offline":
+		systemUp = true
+	case "maintenance", "stopping":
+		readiness.Error = fmt.Sprintf("the system is in %s state", readiness.System)
+	}
+	readiness.Ready = cloudInitDone && systemUp && readiness.Error == ""
+	return readiness
+}
+
+// WaitUntilReady checks the readiness of a VM over ssh until it is ready, will not become
+// ready, or timeout passes. Readiness is returned in each case; only a canceled context is an
+// error.
+func WaitUntilReady(ctx context.Context, vmName string, sshArgs []string, timeout time.Duration) (Readiness, error) {
+	return waitUntilReady(ctx, timeout, func(ctx context.Context) (string, error) {
+		var output bytes.Buffer
+		err := cmdexec.RunSSH(ctx, cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: "sh -c " + shellquote.Quote(readinessCommand), Stdout: &output})
+		return output.String(), err
+	})
+}
+
+// waitUntilReady polls check, which runs the readiness command, until the VM is ready
+func waitUntilReady(ctx context.Context, timeout time.Duration, check func(context.Context) (string, error)) (Readiness, error) {
+	started := time.Now()
+	deadline := started.Add(timeout)
+	for {
+		output, err := check(ctx)
+		readiness := ParseReadiness(output)
+		if err != nil && output == "" {
+			// sshd may restart while the guest boots
+			readiness = Readiness{CloudInit: "unknown", System: "unknown", Error: fmt.Sprintf("readiness check failed: %v", err)}
+		}
+		readiness.WaitedSeconds = roundSeconds(time.Since(started))
+		if readiness.Ready || readiness.Error != "" && err == nil {
+			return readiness, nil
+		}
+		if !time.Now().Add(readyPollInterval).Before(deadline) {
+			return readiness, nil
+		}
+		select {
+		case <-ctx.Done():
+			return readiness, ctx.Err()
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
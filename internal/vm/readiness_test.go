@@ -0,0 +1,75 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseReadiness(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		ready    bool
+		hasError bool
+	}{
+		{"booted", "cloud_init=done\nsystem=running\nfailed_units=\n", true, false},
+		{"cloud-init running", "cloud_init=running\nsystem=running\nfailed_units=\n", false, false},
+		{"system starting", "cloud_init=done\nsystem=starting\nfailed_units=\n", false, false},
+		{"degraded", "cloud_init=done\nsystem=degraded\nfailed_units=snapd.service \n", true, false},
+		{"without cloud-init", "cloud_init=none\nsystem=running\nfailed_units=\n", true, false},
+		{"without systemd", "cloud_init=none\nsystem=none\n", true, false},
+		{"cloud-init failed", "cloud_init=error\nsystem=running\nfailed_units=\n", false, true},
+		{"maintenance", "cloud_init=done\nsystem=maintenance\nfailed_units=\n", false, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			readiness := ParseReadiness(tc.output)
+			if readiness.Ready != tc.ready || (readiness.Error != "") != tc.hasError {
+				t.Errorf("Expected ready %v and error %v but got %+v", tc.ready, tc.hasError, readiness)
+			}
+		})
+	}
+	if units := ParseReadiness("system=degraded\nfailed_units=snapd.service cups.service\n").FailedUnits; !reflect.DeepEqual(units, []string{"snapd.service", "cups.service"}) {
+		t.Errorf("Expected the failed units but got %v", units)
+	}
+}
+
+func TestWaitUntilReady(t *testing.T) {
+	originalInterval := readyPollInterval
+	t.Cleanup(func() { readyPollInterval = originalInterval })
+	readyPollInterval = time.Millisecond
+
+	// The first check fails as sshd restarts, then cloud-init finishes
+	outputs := []string{"", "cloud_init=running\nsystem=starting\n", "cloud_init=done\nsystem=running\n"}
+	checks := 0
+	readiness, err := waitUntilReady(context.Background(), time.Minute, func(context.Context) (string, error) {
+		output := outputs[checks]
+		checks++
+		if output == "" {
+			return "", fmt.Errorf("connection refused")
+		}
+		return output, nil
+	})
+	if err != nil || !readiness.Ready || checks != 3 {
+		t.Errorf("Expected the VM ready after 3 checks but got %+v after %d: %v", readiness, checks, err)
+	}
+
+	readiness, err = waitUntilReady(context.Background(), 10*time.Millisecond, func(context.Context) (string, error) {
+		return "cloud_init=running\nsystem=running\n", nil
+	})
+	if err != nil || readiness.Ready || readiness.CloudInit != "running" {
+		t.Errorf("Expected cloud-init still running at the timeout but got %+v: %v", readiness, err)
+	}
+
+	checks = 0
+	readiness, _ = waitUntilReady(context.Background(), time.Minute, func(context.Context) (string, error) {
+		checks++
+		return "cloud_init=error\nsystem=running\n", nil
+	})
+	if readiness.Ready || readiness.Error == "" || checks != 1 {
+		t.Errorf("Expected failed cloud-init to stop waiting at once but got %+v after %d checks", readiness, checks)
+	}
+}
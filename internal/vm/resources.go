@@ -0,0 +1,70 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// SetResources changes the CPU count and memory of a VM. Zero keeps the current value. A
+// running VM is reloaded so the new resources take effect; a stopped VM picks them up on its
+// next start. Both the previous and the new configuration are returned.
+func (m *Manager) SetResources(ctx context.Context, name string, cpu, memory int) (core.VMConfig, core.VMConfig, error) {
+	release, err := m.operations.acquire(name, OperationReconfigure)
+	if err != nil {
+		return core.VMConfig{}, core.VMConfig{}, err
+	}
+	defer release()
+	before, err := m.GetVMConfig(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, core.VMConfig{}, err
+	}
+	if cpu < 0 || memory < 0 {
+		return core.VMConfig{}, core.VMConfig{}, errors.InvalidInput("cpu and memory must be positive")
+	}
+	if cpu == 0 && memory == 0 {
+		return core.VMConfig{}, core.VMConfig{}, errors.InvalidInput("set cpu, memory or both")
+	}
+
+	after := before
+	if cpu > 0 {
+		after.CPU = cpu
+	}
+	if memory > 0 {
+		after.Memory = memory
+	}
+	if err := m.applyConfig(ctx, name, after); err != nil {
+		return core.VMConfig{}, core.VMConfig{}, err
+	}
+	log.Info().Str("name", name).Int("cpu", after.CPU).Int("memory", after.Memory).Msg("VM resources updated")
+	return before, after, nil
+}
+
+// applyConfig saves a changed configuration of a VM, regenerates its Vagrantfile and reloads
+// it when it is running. The caller holds the VM's operation lock. Adopted VMs are refused, since their Vagrantfile is not generated.
+func (m *Manager) applyConfig(ctx context.Context, name string, config core.VMConfig) error {
+	if _, err := loadAdoption(filepath.Join(m.baseDir, name)); err == nil {
+		return errors.InvalidInput("adopted VMs keep their own Vagrantfile; change it there and reload the VM")
+	}
+	state, err := m.GetVMState(ctx, name)
+	if err != nil {
+		return err
+	}
+	// A Vagrantfile that fails validation leaves the saved configuration unchanged
+	if err := m.generateVagrantfile(name, config); err != nil {
+		return errors.OperationFailed("generate Vagrantfile", err)
+	}
+	if err := m.saveVMConfig(name, &config); err != nil {
+		return errors.OperationFailed("save VM configuration", err)
+	}
+	if state == core.Running {
+		return m.reloadVM(ctx, name)
+	}
+	return nil
+}
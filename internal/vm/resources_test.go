@@ -0,0 +1,51 @@
+package vm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+func TestSetResourcesValidation(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "vms")
+	m := &Manager{baseDir: baseDir}
+	if err := m.saveVMConfig("dev", &core.VMConfig{Name: "dev", CPU: 2, Memory: 2048}); err != nil {
+		t.Fatalf("Failed to save VM config: %v", err)
+	}
+	adoptedDir := filepath.Join(baseDir, "adopted")
+	if err := os.MkdirAll(adoptedDir, 0755); err != nil {
+		t.Fatalf("Failed to create VM directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(adoptedDir, adoptionFile), []byte(`{"vagrant_dir": "/projects/app"}`), 0644); err != nil {
+		t.Fatalf("Failed to write adoption record: %v", err)
+	}
+	if err := m.saveVMConfig("adopted", &core.VMConfig{Name: "adopted", CPU: 2, Memory: 2048}); err != nil {
+		t.Fatalf("Failed to save VM config: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		vmName   string
+		cpu      int
+		memory   int
+		expected errors.ErrorCode
+	}{
+		{"unknown VM", "missing", 4, 0, errors.CodeOperationFailed},
+		{"nothing to change", "dev", 0, 0, errors.CodeInvalidInput},
+		{"negative memory", "dev", 2, -1, errors.CodeInvalidInput},
+		{"adopted VM", "adopted", 4, 4096, errors.CodeInvalidInput},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := m.SetResources(context.Background(), tc.vmName, tc.cpu, tc.memory)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %s error but got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// sharedFolderGuestPath is where the generated Vagrantfiles mount the project in the guest
+const sharedFolderGuestPath = "/vagrant"
+
+// mountCheckTTL is how long a healthy mount check of a VM is trusted; syncs of mounted folders
+// check the mount instead of copying files, and would otherwise ssh into the VM every time
+const mountCheckTTL = 30 * time.Second
+
+// smbCredentialsFile is the file in the VM directory holding the SMB username and password, one
+// per line, which the Vagrantfile reads so Vagrant does not prompt for them during 'vagrant up'
+const smbCredentialsFile = "smb-credentials"
+
+// machineIDPattern matches the provider machine IDs written into the NFS exports sed script
+var machineIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// nfsExportsFile is the host file Vagrant adds the NFS exports of VMs to
+var nfsExportsFile = "/etc/exports"
+
+// mountFilesystems lists the guest filesystem types of each mounted sync type
+var mountFilesystems = map[string][]string{
+	"nfs":      {"nfs", "nfs4"},
+	"smb":      {"cifs", "smb3"},
+	"virtiofs": {"virtiofs"},
+	"9p":       {"9p"},
+}
+
+// libvirtSyncTypes are the sync types only the libvirt provider supports
+var libvirtSyncTypes = []string{"virtiofs", "9p"}
+
+// ValidateSyncProvider checks that the provider of a VM supports its sync type
+func ValidateSyncProvider(config core.VMConfig) error {
+	if slices.Contains(libvirtSyncTypes, config.SyncType) && config.Provider != ProviderLibvirt {
+		provider := config.Provider
+		if provider == "" {
+			provider = ProviderVirtualBox
+		}
+		return errors.InvalidInput(fmt.Sprintf("the %s sync type needs the %s provider, but the VM uses %s; use nfs or rsync instead", config.SyncType, ProviderLibvirt, provider))
+	}
+	return nil
+}
+
+// IsMountedSyncType reports whether a sync type shares the project with the guest through a
+// mount, so files need no copying between host and VM
+func IsMountedSyncType(syncType string) bool {
+	_, ok := mountFilesystems[syncType]
+	return ok
+}
+
+// nfsVersion returns the NFS version of synced folders on a host; the macOS NFS server only
+// exports NFSv3 to Vagrant
+func nfsVersion(platform Platform) int {
+	if platform.OS == "darwin" {
+		return 3
+	}
+	return 4
+}
+
+// mountCheckCommand returns the guest command printing the filesystem type and source of the
+// mount at dir, and 'unresponsive' when listing it hangs, as a stale NFS mount does
+func mountCheckCommand(dir string) string {
+	quoted := shellquote.Quote(dir)
+	return fmt.Sprintf("awk -v dir=%[1]s '$2 == dir {print $3, $1}' /proc/mounts | tail -n 1; timeout 10 ls -d %[1]s/. >/dev/null 2>&1 || echo unresponsive", quoted)
+}
+
+// parseMountCheck checks the output of mountCheckCommand against the filesystems of a sync type
+func parseMountCheck(output, syncType, dir string) error {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) == 0 || fields[0] == "unresponsive" {
+		return fmt.Errorf("the %s synced folder is not mounted at %s in the VM", syncType, dir)
+	}
+	if !slices.Contains(mountFilesystems[syncType], fields[0]) {
+		return fmt.Errorf("%s in the VM is a %s mount, not a %s synced folder", dir, fields[0], syncType)
+	}
+	if lines[len(lines)-1] == "unresponsive" {
+		return fmt.Errorf("the %s mount at %s in the VM does not respond; reload the VM to remount it", syncType, dir)
+	}
+	return nil
+}
+
+// mountCheckCache remembers when the mounts of VMs were last found healthy
+type mountCheckCache struct {
+	mu      sync.Mutex
+	checked map[string]time.Time
+}
+
+// VerifySyncMount checks that the synced folder of a running VM using a mounted sync type is
+// mounted at /vagrant and responds. VMs syncing by copying files pass.
+func (m *Manager) VerifySyncMount(ctx context.Context, name string) error {
+	config, err := m.GetVMConfig(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !IsMountedSyncType(config.SyncType) {
+		return nil
+	}
+	sshConfig, err := m.syncSSHConfig(ctx, name)
+	if err != nil {
+		return err
+	}
+	output, err := cmdexec.SSHOutput(ctx, name, SSHArgs(sshConfig), mountCheckCommand(sharedFolderGuestPath))
+	if err != nil {
+		m.forgetSSHConfig(name)
+		return fmt.Errorf("failed to check the %s synced folder in the VM: %w", config.SyncType, err)
+	}
+	if err := parseMountCheck(string(output), config.SyncType, sharedFolderGuestPath); err != nil {
+		return err
+	}
+	m.mountChecks.mu.Lock()
+	defer m.mountChecks.mu.Unlock()
+	if m.mountChecks.checked == nil {
+		m.mountChecks.checked = make(map[string]time.Time)
+	}
+	m.mountChecks.checked[name] = time.Now()
+	return nil
+}
+
+// CheckSyncMount is the health check syncs of mounted folders run in place of copying files.
+// A mount found healthy within mountCheckTTL is not checked again.
+func (m *Manager) CheckSyncMount(name string) error {
+	m.mountChecks.mu.Lock()
+	checked, ok := m.mountChecks.checked[name]
+	m.mountChecks.mu.Unlock()
+	if ok && time.Since(checked) < mountCheckTTL {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return m.VerifySyncMount(ctx, name)
+}
+
+// forgetSyncMount drops the last healthy mount check of a VM, e.g. once it stopped
+func (m *Manager) forgetSyncMount(name string) {
+	m.mountChecks.mu.Lock()
+	defer m.mountChecks.mu.Unlock()
+	delete(m.mountChecks.checked, name)
+}
+
+// SetSMBCredentials stores the SMB username and password the Vagrantfile of a VM mounts its SMB
+// synced folder with. The file is only readable by the current user.
+func (m *Manager) SetSMBCredentials(name, username, password string) error {
+	vmDir := m.getVMDir(name)
+	if _, err := os.Stat(vmDir); err != nil {
+		return fmt.Errorf("VM '%s' does not exist: %w", name, err)
+	}
+	if strings.ContainsAny(username, "\r\n") || strings.ContainsAny(password, "\r\n") {
+		return fmt.Errorf("SMB credentials cannot contain line breaks")
+	}
+	return os.WriteFile(filepath.Join(vmDir, smbCredentialsFile), []byte(username+"\n"+password+"\n"), 0600)
+}
+
+// ensureSMBCredentials stores the SMB credentials of MCP_SMB_USERNAME and MCP_SMB_PASSWORD for a
+// VM using the smb sync type that has none yet
+func (m *Manager) ensureSMBCredentials(name string, config core.VMConfig) error {
+	if config.SyncType != "smb" {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(m.getVMDir(name), smbCredentialsFile)); err == nil {
+		return nil
+	}
+	username := os.Getenv("MCP_SMB_USERNAME")
+	if username == "" {
+		log.Warn().Str("name", name).Msg("No SMB credentials for the VM; set them with configure_sync or MCP_SMB_USERNAME and MCP_SMB_PASSWORD, or 'vagrant up' will prompt for them")
+		return nil
+	}
+	return m.SetSMBCredentials(name, username, os.Getenv("MCP_SMB_PASSWORD"))
+}
+
+// vagrantMachineIDs returns the IDs of the provider machines of a VM, read from its .vagrant
+// directory
+func vagrantMachineIDs(vmDir string) []string {
+	files, _ := filepath.Glob(filepath.Join(vmDir, ".vagrant", "machines", "*", "*", "id"))
+	var ids []string
+	for _, file := range files {
+		if id, err := os.ReadFile(file); err == nil && strings.TrimSpace(string(id)) != "" {
+			ids = append(ids, strings.TrimSpace(string(id)))
+		}
+	}
+	return ids
+}
+
+// hasNFSExport reports whether the exports file holds the block Vagrant adds for a machine,
+// between '# VAGRANT-BEGIN: <uid> <id>' and '# VAGRANT-END: <uid> <id>'
+func hasNFSExport(exports, id string) bool {
+	for _, line := range strings.Split(exports, "\n") {
+		if strings.HasPrefix(line, "# VAGRANT-BEGIN:") && strings.HasSuffix(strings.TrimSpace(line), " "+id) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneNFSExportsCommand returns the command removing the export block of a machine from the
+// exports file and reloading the NFS server. 'sed -i.bak' works with GNU and BSD sed alike.
+func pruneNFSExportsCommand(goos, exportsFile, id string) string {
+	command := fmt.Sprintf("sudo -n sed -i.bak -e '/^# VAGRANT-BEGIN:.* %[1]s$/,/^# VAGRANT-END:.* %[1]s$/d' %[2]s", id, shellquote.Quote(exportsFile))
+	if goos == "darwin" {
+		return command + " && sudo -n nfsd update"
+	}
+	return command + " && sudo -n exportfs -ra"
+}
+
+// pruneNFSExports removes the NFS exports of destroyed machines that Vagrant left behind, e.g.
+// when 'vagrant destroy' failed. Without passwordless sudo the entries are only reported.
+func pruneNFSExports(name string, ids []string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	exports, err := os.ReadFile(nfsExportsFile)
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		if !machineIDPattern.MatchString(id) || !hasNFSExport(string(exports), id) {
+			continue
+		}
+		if output, err := exec.Command("sh", "-c", pruneNFSExportsCommand(runtime.GOOS, nfsExportsFile, id)).CombinedOutput(); err != nil {
+			log.Warn().Err(err).Str("name", name).Str("machine", id).Str("output", strings.TrimSpace(string(output))).
+				Msgf("Stale NFS export left in %s; remove the VAGRANT-BEGIN/VAGRANT-END block of the machine by hand", nfsExportsFile)
+			continue
+		}
+		log.Info().Str("name", name).Str("machine", id).Msg("Removed stale NFS export")
+	}
+}
@@ -0,0 +1,154 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestParseMountCheck(t *testing.T) {
+	tests := []struct {
+		output   string
+		syncType string
+		err      string
+	}{
+		{"nfs4 192.168.56.1:/home/me/project\n", "nfs", ""},
+		{"cifs //192.168.56.1/vgt-abc\n", "smb", ""},
+		{"", "nfs", "not mounted"},
+		{"ext4 /dev/sda1\n", "nfs", "is a ext4 mount"},
+		{"nfs 192.168.56.1:/home/me/project\nunresponsive\n", "nfs", "does not respond"},
+		{"unresponsive\n", "smb", "not mounted"},
+	}
+	for _, tt := range tests {
+		err := parseMountCheck(tt.output, tt.syncType, "/vagrant")
+		if tt.err == "" && err != nil {
+			t.Errorf("Expected %q to pass but got %v", tt.output, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("Expected %q to fail with %q but got %v", tt.output, tt.err, err)
+		}
+	}
+}
+
+func TestNFSExports(t *testing.T) {
+	exports := "/srv 10.0.0.0/8(ro)\n# VAGRANT-BEGIN: 501 4f5e-11aa\n\"/home/me/project\" 192.168.56.10(rw,no_subtree_check,all_squash)\n# VAGRANT-END: 501 4f5e-11aa\n"
+	if !hasNFSExport(exports, "4f5e-11aa") {
+		t.Error("Expected the machine's export block to be found")
+	}
+	if hasNFSExport(exports, "11aa") {
+		t.Error("Expected only whole machine IDs to match")
+	}
+
+	command := pruneNFSExportsCommand("linux", "/etc/exports", "4f5e-11aa")
+	if !strings.Contains(command, "/^# VAGRANT-BEGIN:.* 4f5e-11aa$/,/^# VAGRANT-END:.* 4f5e-11aa$/d") || !strings.HasSuffix(command, "sudo -n exportfs -ra") {
+		t.Errorf("Expected a sed script removing the block and an exportfs reload but got %s", command)
+	}
+	if command := pruneNFSExportsCommand("darwin", "/etc/exports", "4f5e-11aa"); !strings.HasSuffix(command, "sudo -n nfsd update") {
+		t.Errorf("Expected macOS to reload nfsd but got %s", command)
+	}
+
+	vmDir := t.TempDir()
+	idFile := filepath.Join(vmDir, ".vagrant", "machines", "default", "virtualbox", "id")
+	if err := os.MkdirAll(filepath.Dir(idFile), 0755); err != nil {
+		t.Fatalf("Failed to create machine dir: %v", err)
+	}
+	if err := os.WriteFile(idFile, []byte("4f5e-11aa\n"), 0644); err != nil {
+		t.Fatalf("Failed to write machine id: %v", err)
+	}
+	if ids := vagrantMachineIDs(vmDir); len(ids) != 1 || ids[0] != "4f5e-11aa" {
+		t.Errorf("Expected the machine id but got %v", ids)
+	}
+}
+
+func TestSetSMBCredentials(t *testing.T) {
+	m, _ := newFakeManager(t, "smb-vm")
+	if err := m.SetSMBCredentials("smb-vm", "me", "line\nbreak"); err == nil {
+		t.Error("Expected an error for a password with a line break")
+	}
+	if err := m.SetSMBCredentials("smb-vm", "me", "secret"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	file := filepath.Join(m.getVMDir("smb-vm"), smbCredentialsFile)
+	content, err := os.ReadFile(file)
+	if err != nil || string(content) != "me\nsecret\n" {
+		t.Errorf("Expected the credentials one per line but got %q, %v", content, err)
+	}
+	if info, err := os.Stat(file); runtime.GOOS != "windows" && (err != nil || info.Mode().Perm() != 0600) {
+		t.Errorf("Expected the credentials to be readable by the owner only but got %v, %v", info.Mode(), err)
+	}
+	if err := m.SetSMBCredentials("missing", "me", "secret"); err == nil {
+		t.Error("Expected an error for a missing VM")
+	}
+}
+
+func TestRenderMountedSyncedFolders(t *testing.T) {
+	nfs, err := renderVagrantfile(vagrantfileData{SyncType: "nfs", ProjectPath: "/home/me/project", NFSVersion: 3}, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	for _, expected := range []string{`config.vm.network "private_network", type: "dhcp"`, `type: "nfs"`, "nfs_version: 3,", `linux__nfs_options: ["rw", "no_subtree_check", "all_squash", "async"]`} {
+		if !strings.Contains(nfs, expected) {
+			t.Errorf("Expected %q in the NFS synced folder but got:\n%s", expected, nfs)
+		}
+	}
+	// A VM on a shared network already has a private network
+	if nfs, _ := renderVagrantfile(vagrantfileData{SyncType: "nfs", Network: "  config.vm.network \"private_network\", ip: \"10.1.2.3\"\n"}, nil, ""); strings.Contains(nfs, `type: "dhcp"`) {
+		t.Errorf("Expected no extra private network but got:\n%s", nfs)
+	}
+
+	smb, err := renderVagrantfile(vagrantfileData{SyncType: "smb", ProjectPath: "C:/project"}, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	for _, expected := range []string{`File.expand_path("smb-credentials", __dir__)`, "smb_password: smb_password,", `mount_options: ["vers=3.0", "mfsymlinks"]`} {
+		if !strings.Contains(smb, expected) {
+			t.Errorf("Expected %q in the SMB synced folder but got:\n%s", expected, smb)
+		}
+	}
+	for _, finding := range LintVagrantfile(smb, nil) {
+		if finding.Rule == "synced_folder_plaintext_password" {
+			t.Errorf("Expected no plain text password finding but got %+v", finding)
+		}
+	}
+}
+
+func TestLibvirtSyncTypes(t *testing.T) {
+	if err := ValidateSyncProvider(core.VMConfig{SyncType: "virtiofs", Provider: ProviderLibvirt}); err != nil {
+		t.Errorf("Expected virtiofs to be valid on libvirt but got %v", err)
+	}
+	if err := ValidateSyncProvider(core.VMConfig{SyncType: "9p"}); err == nil || !strings.Contains(err.Error(), "virtualbox") {
+		t.Errorf("Expected 9p to be refused on virtualbox but got %v", err)
+	}
+	if err := ValidateSyncProvider(core.VMConfig{SyncType: "nfs", Provider: ProviderVMware}); err != nil {
+		t.Errorf("Expected nfs to be valid on any provider but got %v", err)
+	}
+
+	virtiofs, err := renderVagrantfile(vagrantfileData{SyncType: "virtiofs", ProjectPath: "/home/me/project"}, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !strings.Contains(virtiofs, `type: "virtiofs"`) {
+		t.Errorf("Expected a virtiofs synced folder but got:\n%s", virtiofs)
+	}
+	ninep, err := renderVagrantfile(vagrantfileData{SyncType: "9p", ProjectPath: "/home/me/project"}, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !strings.Contains(ninep, `type: "9p",`) || !strings.Contains(ninep, `accessmode: "mapped"`) {
+		t.Errorf("Expected a mapped 9p synced folder but got:\n%s", ninep)
+	}
+
+	// virtiofs needs the guest memory shared with virtiofsd
+	config := core.VMConfig{CPU: 2, Memory: 2048, Provider: ProviderLibvirt, SyncType: "virtiofs"}
+	if block := vagrantProviderBlock("dev", config, Platform{OS: "linux", Architecture: "amd64"}); !strings.Contains(block, `lv.memorybacking :access, :mode => "shared"`) {
+		t.Errorf("Expected shared memory backing in provider block:\n%s", block)
+	}
+	config.SyncType = "9p"
+	if block := vagrantProviderBlock("dev", config, Platform{OS: "linux", Architecture: "amd64"}); strings.Contains(block, "memorybacking") {
+		t.Errorf("Expected no memory backing for 9p in provider block:\n%s", block)
+	}
+}
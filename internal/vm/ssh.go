@@ -0,0 +1,50 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/hostos"
+)
+
+// SSHInfo describes how to connect to a VM with ssh directly, without 'vagrant ssh'
+type SSHInfo struct {
+	Host         string `json:"host"`
+	Port         string `json:"port"`
+	User         string `json:"user"`
+	IdentityFile string `json:"identity_file"`
+	// Command is a ready-to-paste ssh command line
+	Command string `json:"command"`
+	// Config is a Host entry for ~/.ssh/config
+	Config string `json:"config"`
+}
+
+// NewSSHInfo builds the connection details of a VM from its 'vagrant ssh-config' output
+func NewSSHInfo(name string, sshConfig map[string]string) SSHInfo {
+	identityFile := strings.Trim(sshConfig["IdentityFile"], `"`)
+	words := []string{"ssh"}
+	for _, arg := range SSHArgs(sshConfig) {
+		words = append(words, commandLineWord(arg))
+	}
+	return SSHInfo{
+		Host:         sshConfig["HostName"],
+		Port:         sshConfig["Port"],
+		User:         sshConfig["User"],
+		IdentityFile: identityFile,
+		Command:      strings.Join(words, " "),
+		Config: fmt.Sprintf("Host %s\n  HostName %s\n  Port %s\n  User %s\n  IdentityFile %s\n  IdentitiesOnly yes\n  StrictHostKeyChecking no\n  UserKnownHostsFile %s\n",
+			name, sshConfig["HostName"], sshConfig["Port"], sshConfig["User"], commandLineWord(identityFile), hostos.NullDevice()),
+	}
+}
+
+// commandLineWord double quotes a word containing spaces, which both POSIX shells and Windows
+// consoles then read as one argument
+func commandLineWord(word string) string {
+	if strings.ContainsAny(word, " \t") {
+		return `"` + word + `"`
+	}
+	return word
+}
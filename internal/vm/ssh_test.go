@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestNewSSHInfo(t *testing.T) {
+	info := NewSSHInfo("dev", map[string]string{
+		"HostName":     "127.0.0.1",
+		"Port":         "2222",
+		"User":         "vagrant",
+		"IdentityFile": `"/Users/dev/My VMs/dev/.vagrant/machines/default/virtualbox/private_key"`,
+	})
+
+	if info.Host != "127.0.0.1" || info.Port != "2222" || info.User != "vagrant" {
+		t.Errorf("Expected vagrant@127.0.0.1:2222 but got %s@%s:%s", info.User, info.Host, info.Port)
+	}
+	if info.IdentityFile != "/Users/dev/My VMs/dev/.vagrant/machines/default/virtualbox/private_key" {
+		t.Errorf("Expected the identity file without quotes but got %s", info.IdentityFile)
+	}
+	expected := `ssh -p 2222 -i "/Users/dev/My VMs/dev/.vagrant/machines/default/virtualbox/private_key" -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null vagrant@127.0.0.1`
+	if info.Command != expected {
+		t.Errorf("Expected command %s but got %s", expected, info.Command)
+	}
+	for _, line := range []string{"Host dev\n", "  HostName 127.0.0.1\n", "  Port 2222\n", `  IdentityFile "/Users/dev/My VMs/dev`} {
+		if !strings.Contains(info.Config, line) {
+			t.Errorf("Expected %q in ssh config:\n%s", line, info.Config)
+		}
+	}
+}
+
+func TestSSHArgsForwardAgent(t *testing.T) {
+	sshConfig := map[string]string{"HostName": "127.0.0.1", "Port": "2222", "User": "vagrant", "IdentityFile": "/tmp/key"}
+	for _, arg := range SSHArgs(sshConfig) {
+		if arg == "-A" {
+			t.Error("Expected no agent forwarding without ForwardAgent")
+		}
+	}
+
+	sshConfig["ForwardAgent"] = "yes"
+	args := SSHArgs(sshConfig)
+	if len(args) < 2 || args[len(args)-2] != "-A" || args[len(args)-1] != "vagrant@127.0.0.1" {
+		t.Errorf("Expected -A before the destination but got %v", args)
+	}
+	if config := vagrantSSHAgentConfig(core.VMConfig{ForwardSSHAgent: true}); !strings.Contains(config, "config.ssh.forward_agent = true") {
+		t.Errorf("Expected the Vagrantfile to forward the agent but got %q", config)
+	}
+}
+
+func TestForwardAgentArgs(t *testing.T) {
+	args := []string{"-p", "2222", "vagrant@127.0.0.1"}
+	expected := []string{"-p", "2222", "-A", "vagrant@127.0.0.1"}
+	forwarded := ForwardAgentArgs(args)
+	if strings.Join(forwarded, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected %v but got %v", expected, forwarded)
+	}
+	if strings.Join(args, " ") != "-p 2222 vagrant@127.0.0.1" {
+		t.Errorf("Expected the original arguments to be left alone but got %v", args)
+	}
+	if again := ForwardAgentArgs(forwarded); len(again) != len(forwarded) {
+		t.Errorf("Expected -A once but got %v", again)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// vagrantSSHAgentConfig returns the Vagrantfile setting that forwards the host's SSH agent,
+// or an empty string when the VM does not forward it
+func vagrantSSHAgentConfig(config core.VMConfig) string {
+	if !config.ForwardSSHAgent {
+		return ""
+	}
+	return "\n  # SSH agent forwarding\n  config.ssh.forward_agent = true\n"
+}
+
+// ForwardAgentArgs returns ssh arguments from SSHArgs that also forward the host's SSH agent
+func ForwardAgentArgs(sshArgs []string) []string {
+	if len(sshArgs) == 0 || slices.Contains(sshArgs, "-A") {
+		return sshArgs
+	}
+	last := len(sshArgs) - 1
+	return append(append(slices.Clone(sshArgs[:last]), "-A"), sshArgs[last])
+}
+
+// SetSSHAgentForwarding turns forwarding of the host's SSH agent to a VM on or off. 'vagrant
+// ssh-config' reads the regenerated Vagrantfile, so the next ssh session of a running VM
+// picks the change up without a reload.
+func (m *Manager) SetSSHAgentForwarding(ctx context.Context, name string, enabled bool) (core.VMConfig, error) {
+	release, err := m.operations.acquire(name, OperationReconfigure)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	defer release()
+	config, err := m.GetVMConfig(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	if _, err := loadAdoption(filepath.Join(m.baseDir, name)); err == nil {
+		return core.VMConfig{}, errors.InvalidInput("adopted VMs keep their own Vagrantfile; set config.ssh.forward_agent there")
+	}
+	if config.ForwardSSHAgent == enabled {
+		return config, nil
+	}
+	config.ForwardSSHAgent = enabled
+	if err := m.saveVMConfig(name, &config); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("save VM configuration", err)
+	}
+	if err := m.generateVagrantfile(name, config); err != nil {
+		return core.VMConfig{}, errors.OperationFailed("generate Vagrantfile", err)
+	}
+	log.Info().Str("name", name).Bool("enabled", enabled).Msg("VM SSH agent forwarding updated")
+	return config, nil
+}
@@ -0,0 +1,34 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+// vagrantStartupConfig returns the provisioner that runs the startup commands of a VM in the
+// synced folder on every boot, or an empty string when it has none. It follows the setup
+// provisioner so the first boot installs runtimes before the commands use them.
+func vagrantStartupConfig(config core.VMConfig) string {
+	if len(config.StartupCommands) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n  # Startup commands, run on every boot:\n")
+	script := "set -e\ncd /vagrant\n"
+	for _, command := range config.StartupCommands {
+		for _, line := range strings.Split(command, "\n") {
+			b.WriteString("  #   " + line + "\n")
+		}
+		script += command + "\n"
+	}
+	// base64 keeps the commands intact through Ruby and shell quoting
+	fmt.Fprintf(&b, "  config.vm.provision \"shell\", name: \"startup\", run: \"always\", privileged: false, inline: \"%s\".unpack1(\"m\")\n",
+		base64.StdEncoding.EncodeToString([]byte(script)))
+	return b.String()
+}
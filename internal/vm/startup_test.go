@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestVagrantStartupConfig(t *testing.T) {
+	if snippet := vagrantStartupConfig(core.VMConfig{}); snippet != "" {
+		t.Errorf("Expected no provisioner without startup commands but got %q", snippet)
+	}
+
+	config := core.VMConfig{StartupCommands: []string{`npm install`, `echo "it's up"`}}
+	content, err := renderVagrantfile(vagrantfileData{
+		Box:     "ubuntu/focal64",
+		Setup:   []string{"sudo apt-get install -y nodejs"},
+		Startup: vagrantStartupConfig(config),
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to render Vagrantfile: %v", err)
+	}
+	setup := strings.Index(content, "sudo apt-get install -y nodejs")
+	startup := strings.Index(content, `name: "startup", run: "always", privileged: false`)
+	if setup < 0 || startup < setup {
+		t.Fatalf("Expected the startup provisioner after the setup provisioner but got:\n%s", content)
+	}
+	if !strings.Contains(content, "  #   echo \"it's up\"\n") {
+		t.Errorf("Expected the commands listed in a comment but got:\n%s", content)
+	}
+
+	match := regexp.MustCompile(`inline: "([A-Za-z0-9+/=]+)"\.unpack1\("m"\)`).FindStringSubmatch(content)
+	if match == nil {
+		t.Fatalf("Expected a base64 inline script but got:\n%s", content)
+	}
+	script, err := base64.StdEncoding.DecodeString(match[1])
+	if err != nil {
+		t.Fatalf("Failed to decode script: %v", err)
+	}
+	expected := "set -e\ncd /vagrant\nnpm install\necho \"it's up\"\n"
+	if string(script) != expected {
+		t.Errorf("Expected script %q but got %q", expected, script)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// Indexers find_symbol resolves symbols with, in the order their definitions are preferred
+const (
+	IndexerGopls = "gopls"
+	IndexerCtags = "ctags"
+	IndexerGrep  = "grep"
+)
+
+// Symbol search limits
+const (
+	DefaultSymbolResults = 100
+	MaxSymbolResults     = 500
+	// symbolIndexTimeout bounds each indexer run in seconds; gopls loads every package of the
+	// module on its first run
+	symbolIndexTimeout = 120
+)
+
+// indexerMarker starts the output of each indexer in the output of a SymbolDefinitionsCommand
+const indexerMarker = "### indexer "
+
+// symbolExcludes are the directories indexers and grep leave out
+var symbolExcludes = []string{".git", "node_modules", ".venv", "__pycache__"}
+
+var (
+	// symbolNamePattern matches the identifiers find_symbol looks up
+	symbolNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	// goplsLocationPattern matches the location starting a gopls output line, e.g.
+	// 'internal/vm/manager.go:120:17-25'
+	goplsLocationPattern = regexp.MustCompile(`^(.+?):(\d+):(\d+)(?:-[\d:]+)?`)
+)
+
+// SymbolQuery describes a lookup of the definitions and references of a symbol
+type SymbolQuery struct {
+	Name string
+	// Root is the guest directory searched; relative roots start from /vagrant
+	Root string
+	// Kind only keeps definitions of this kind, e.g. function or class
+	Kind       string
+	MaxResults int
+}
+
+// SymbolLocation is a definition or reference of a symbol
+type SymbolLocation struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	Language string `json:"language,omitempty"`
+	// Text is the matching source line, for references found by grep
+	Text string `json:"text,omitempty"`
+	// Indexer is the tool that found the location
+	Indexer string `json:"indexer"`
+}
+
+// ctagsTag is a tag of the JSON output of Universal Ctags
+type ctagsTag struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Kind     string `json:"kind"`
+	Language string `json:"language"`
+	Scope    string `json:"scope"`
+}
+
+// symbolRoot returns the absolute guest directory of a query
+func symbolRoot(query SymbolQuery) (string, error) {
+	if !symbolNamePattern.MatchString(query.Name) {
+		return "", errors.InvalidInput(fmt.Sprintf("invalid symbol '%s': use an identifier such as parseConfig", query.Name))
+	}
+	root := query.Root
+	if root == "" {
+		root = "/vagrant"
+	}
+	if strings.ContainsAny(root, "\x00\n") {
+		return "", errors.InvalidInput(fmt.Sprintf("invalid path '%s'", root))
+	}
+	if !strings.HasPrefix(root, "/") {
+		root = path.Join("/vagrant", root)
+	}
+	return path.Clean(root), nil
+}
+
+// SymbolDefinitionsCommand returns the command looking up the definitions of a symbol with the
+// indexers installed in the VM: gopls in Go modules, and Universal Ctags for every language it
+// parses. The output of each indexer follows an indexer marker line naming it.
+func SymbolDefinitionsCommand(query SymbolQuery) (string, error) {
+	root, err := symbolRoot(query)
+	if err != nil {
+		return "", err
+	}
+	var script strings.Builder
+	fmt.Fprintf(&script, "cd %s || exit 1; ", shellquote.Quote(root))
+	fmt.Fprintf(&script, "if command -v gopls >/dev/null 2>&1 && [ -f go.mod ]; then echo '%s%s'; timeout %d gopls workspace_symbol -matcher=caseSensitive %s 2>/dev/null; fi; ",
+		indexerMarker, IndexerGopls, symbolIndexTimeout, shellquote.Quote(query.Name))
+	excludes := make([]string, len(symbolExcludes))
+	for i, name := range symbolExcludes {
+		excludes[i] = "--exclude=" + shellquote.Quote(name)
+	}
+	fmt.Fprintf(&script, "if ctags --version 2>/dev/null | grep -q 'Universal Ctags'; then echo '%s%s'; timeout %d ctags -R --output-format=json --fields=+nKl %s -f - . 2>/dev/null | grep -F %s; fi",
+		indexerMarker, IndexerCtags, symbolIndexTimeout, strings.Join(excludes, " "), shellquote.Quote(fmt.Sprintf(`"name": %q`, query.Name)))
+	return "sh -c " + shellquote.Quote(script.String()), nil
+}
+
+// ParseSymbolDefinitions parses the output of a SymbolDefinitionsCommand, returning the
+// definitions of the query's symbol, gopls ones first, and the indexers that ran
+func ParseSymbolDefinitions(query SymbolQuery, output string) ([]SymbolLocation, []string) {
+	root, _ := symbolRoot(query)
+	definitions := []SymbolLocation{}
+	indexers := []string{}
+	seen := make(map[string]bool)
+	indexer := ""
+	for _, line := range strings.Split(output, "\n") {
+		if name, ok := strings.CutPrefix(line, indexerMarker); ok {
+			indexer = strings.TrimSpace(name)
+			indexers = append(indexers, indexer)
+			continue
+		}
+		var location SymbolLocation
+		var ok bool
+		switch indexer {
+		case IndexerGopls:
+			location, ok = parseGoplsSymbol(root, query.Name, line)
+		case IndexerCtags:
+			location, ok = parseCtagsTag(root, query.Name, line)
+		}
+		if !ok || query.Kind != "" && !strings.EqualFold(location.Kind, query.Kind) {
+			continue
+		}
+		// Both indexers find the definitions of Go symbols
+		key := fmt.Sprintf("%s:%d", location.Path, location.Line)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		definitions = append(definitions, location)
+	}
+	return definitions, indexers
+}
+
+// parseGoplsSymbol parses a 'gopls workspace_symbol' line, e.g.
+// 'internal/vm/manager.go:120:17-25 Manager.StartVM Method'. gopls matches names containing the
+// symbol; only symbols named exactly, or members named so, are kept.
+func parseGoplsSymbol(root, name, line string) (SymbolLocation, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return SymbolLocation{}, false
+	}
+	symbol := fields[len(fields)-2]
+	if symbol != name && !strings.HasSuffix(symbol, "."+name) {
+		return SymbolLocation{}, false
+	}
+	location, ok := parseGoplsLocation(root, fields[0])
+	if !ok {
+		return SymbolLocation{}, false
+	}
+	location.Kind = strings.ToLower(fields[len(fields)-1])
+	if scope, _, found := strings.Cut(symbol, "."); found && symbol != name {
+		location.Scope = scope
+	}
+	location.Language = "Go"
+	return location, true
+}
+
+// parseGoplsLocation parses a gopls location such as 'main.go:12:6-10', resolving relative
+// paths against root
+func parseGoplsLocation(root, field string) (SymbolLocation, bool) {
+	match := goplsLocationPattern.FindStringSubmatch(field)
+	if match == nil {
+		return SymbolLocation{}, false
+	}
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	return SymbolLocation{Path: guestAbs(root, match[1]), Line: line, Column: column, Indexer: IndexerGopls}, true
+}
+
+// parseCtagsTag parses a line of Universal Ctags JSON output holding a tag of the symbol
+func parseCtagsTag(root, name, line string) (SymbolLocation, bool) {
+	var tag ctagsTag
+	if err := json.Unmarshal([]byte(line), &tag); err != nil || tag.Name != name || tag.Line == 0 {
+		return SymbolLocation{}, false
+	}
+	return SymbolLocation{
+		Path:     guestAbs(root, tag.Path),
+		Line:     tag.Line,
+		Kind:     tag.Kind,
+		Scope:    tag.Scope,
+		Language: tag.Language,
+		Indexer:  IndexerCtags,
+	}, true
+}
+
+// guestAbs resolves a guest path relative to root
+func guestAbs(root, p string) string {
+	if strings.HasPrefix(p, "/") {
+		return path.Clean(p)
+	}
+	return path.Join(root, p)
+}
+
+// GoReferencesCommand returns the command listing the references of the Go symbol defined at
+// a location found by gopls
+func GoReferencesCommand(query SymbolQuery, definition SymbolLocation) (string, error) {
+	root, err := symbolRoot(query)
+	if err != nil {
+		return "", err
+	}
+	position := fmt.Sprintf("%s:%d:%d", definition.Path, definition.Line, definition.Column)
+	return fmt.Sprintf("cd %s && timeout %d gopls references %s", shellquote.Quote(root), symbolIndexTimeout, shellquote.Quote(position)), nil
+}
+
+// ParseGoReferences parses the output of a GoReferencesCommand
+func ParseGoReferences(query SymbolQuery, output string) []SymbolLocation {
+	root, _ := symbolRoot(query)
+	references := []SymbolLocation{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if location, ok := parseGoplsLocation(root, strings.TrimSpace(line)); ok {
+			location.Language = "Go"
+			references = append(references, location)
+		}
+	}
+	return references
+}
+
+// SymbolReferencesQuery returns the grep query finding the lines using a symbol as a whole
+// word, for symbols no indexer resolves references of. It asks for one more match than the
+// query's limit, so callers can tell whether matches were left out.
+func SymbolReferencesQuery(query SymbolQuery) (ContentQuery, error) {
+	root, err := symbolRoot(query)
+	if err != nil {
+		return ContentQuery{}, err
+	}
+	maxResults := query.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultSymbolResults
+	}
+	return ContentQuery{
+		Paths:      []string{root},
+		Pattern:    query.Name,
+		WholeWord:  true,
+		Exclude:    symbolExcludes,
+		MaxResults: min(maxResults, MaxSymbolResults) + 1,
+	}, nil
+}
+
+// GrepReferences turns grep matches of a symbol into references, leaving out its definitions
+func GrepReferences(matches []ContentMatch, definitions []SymbolLocation) []SymbolLocation {
+	defined := make(map[string]bool, len(definitions))
+	for _, definition := range definitions {
+		defined[fmt.Sprintf("%s:%d", definition.Path, definition.Line)] = true
+	}
+	references := []SymbolLocation{}
+	for _, match := range matches {
+		if defined[fmt.Sprintf("%s:%d", match.Path, match.Line)] {
+			continue
+		}
+		references = append(references, SymbolLocation{Path: match.Path, Line: match.Line, Text: strings.TrimSpace(match.Text), Indexer: IndexerGrep})
+	}
+	return references
+}
@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSymbolDefinitionsCommand(t *testing.T) {
+	command, err := SymbolDefinitionsCommand(SymbolQuery{Name: "StartVM", Root: "internal"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	for _, expected := range []string{"cd '\\''/vagrant/internal'\\''", "gopls workspace_symbol -matcher=caseSensitive", "ctags -R --output-format=json", `"name": "StartVM"`} {
+		if !strings.Contains(command, expected) {
+			t.Errorf("Expected %q in %s", expected, command)
+		}
+	}
+	for _, name := range []string{"", "rm -rf", "a'b", "1abc"} {
+		if _, err := SymbolDefinitionsCommand(SymbolQuery{Name: name}); err == nil {
+			t.Errorf("Expected an error for symbol %q", name)
+		}
+	}
+}
+
+func TestParseSymbolDefinitions(t *testing.T) {
+	output := strings.Join([]string{
+		indexerMarker + IndexerGopls,
+		"internal/vm/manager.go:120:18-25 Manager.StartVM Method",
+		"internal/vm/manager.go:300:6-20 StartVMWithRetry Function",
+		indexerMarker + IndexerCtags,
+		`{"_type": "tag", "name": "StartVM", "path": "internal/vm/manager.go", "pattern": "/^func (m *Manager) StartVM(/", "line": 120, "kind": "method", "language": "Go", "scope": "Manager"}`,
+		`{"_type": "tag", "name": "StartVM", "path": "web/api.py", "line": 7, "kind": "function", "language": "Python"}`,
+		`{"_type": "tag", "name": "StartVMs", "path": "web/api.py", "line": 9, "kind": "function", "language": "Python"}`,
+		"",
+	}, "\n")
+	query := SymbolQuery{Name: "StartVM"}
+	definitions, indexers := ParseSymbolDefinitions(query, output)
+	if strings.Join(indexers, ",") != "gopls,ctags" {
+		t.Errorf("Expected gopls and ctags but got %v", indexers)
+	}
+	expected := []SymbolLocation{
+		{Path: "/vagrant/internal/vm/manager.go", Line: 120, Column: 18, Kind: "method", Scope: "Manager", Language: "Go", Indexer: IndexerGopls},
+		{Path: "/vagrant/web/api.py", Line: 7, Kind: "function", Language: "Python", Indexer: IndexerCtags},
+	}
+	if len(definitions) != len(expected) {
+		t.Fatalf("Expected %+v but got %+v", expected, definitions)
+	}
+	for i := range expected {
+		if definitions[i] != expected[i] {
+			t.Errorf("Expected %+v but got %+v", expected[i], definitions[i])
+		}
+	}
+
+	query.Kind = "Function"
+	if definitions, _ := ParseSymbolDefinitions(query, output); len(definitions) != 1 || definitions[0].Path != "/vagrant/web/api.py" {
+		t.Errorf("Expected only the function but got %+v", definitions)
+	}
+	if definitions, indexers := ParseSymbolDefinitions(query, ""); len(definitions) != 0 || len(indexers) != 0 {
+		t.Errorf("Expected nothing without indexers but got %+v, %v", definitions, indexers)
+	}
+}
+
+func TestSymbolReferences(t *testing.T) {
+	query := SymbolQuery{Name: "StartVM", MaxResults: 10}
+	definition := SymbolLocation{Path: "/vagrant/internal/vm/manager.go", Line: 120, Column: 18, Indexer: IndexerGopls}
+	command, err := GoReferencesCommand(query, definition)
+	if err != nil || command != "cd '/vagrant' && timeout 120 gopls references '/vagrant/internal/vm/manager.go:120:18'" {
+		t.Errorf("Expected a gopls references command but got %q, %v", command, err)
+	}
+	references := ParseGoReferences(query, "/vagrant/cmd/main.go:40:5-12\ninternal/handlers/vm_tools.go:88:20-27\n")
+	if len(references) != 2 || references[0].Line != 40 || references[1].Path != "/vagrant/internal/handlers/vm_tools.go" {
+		t.Errorf("Expected two references but got %+v", references)
+	}
+
+	grepQuery, err := SymbolReferencesQuery(query)
+	if err != nil || !grepQuery.WholeWord || grepQuery.MaxResults != 11 || grepQuery.Paths[0] != "/vagrant" {
+		t.Errorf("Expected a whole word grep of /vagrant for 11 matches but got %+v, %v", grepQuery, err)
+	}
+	matches := []ContentMatch{
+		{Path: "/vagrant/internal/vm/manager.go", Line: 120, Text: "func (m *Manager) StartVM("},
+		{Path: "/vagrant/cmd/main.go", Line: 40, Text: "\tmanager.StartVM(ctx)"},
+	}
+	grepReferences := GrepReferences(matches, []SymbolLocation{definition})
+	if len(grepReferences) != 1 || grepReferences[0].Text != "manager.StartVM(ctx)" || grepReferences[0].Indexer != IndexerGrep {
+		t.Errorf("Expected the call without the definition but got %+v", grepReferences)
+	}
+}
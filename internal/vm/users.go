@@ -0,0 +1,259 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/errors"
+	"github.com/vagrant-mcp/server/internal/shellquote"
+)
+
+// usernamePattern matches the guest user names the tools accept, as useradd does by default
+var usernamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// authorizedKeyTypes are the public key types accepted for authorized_keys
+var authorizedKeyTypes = map[string]bool{
+	"ssh-ed25519":                        true,
+	"ssh-rsa":                            true,
+	"ecdsa-sha2-nistp256":                true,
+	"ecdsa-sha2-nistp384":                true,
+	"ecdsa-sha2-nistp521":                true,
+	"sk-ssh-ed25519@openssh.com":         true,
+	"sk-ecdsa-sha2-nistp256@openssh.com": true,
+}
+
+// GuestUser describes a user to create in a VM
+type GuestUser struct {
+	Name  string
+	Shell string
+	// Sudo adds the user to the sudo group; PasswordlessSudo also lets it run sudo without a
+	// password, as the vagrant user does
+	Sudo             bool
+	PasswordlessSudo bool
+}
+
+// RotatedKey describes the SSH key that replaced a VM's key
+type RotatedKey struct {
+	User         string `json:"user"`
+	IdentityFile string `json:"identity_file"`
+	Fingerprint  string `json:"fingerprint"`
+	PublicKey    string `json:"public_key"`
+}
+
+// ValidateUsername returns an error unless name is a valid guest user name
+func ValidateUsername(name string) error {
+	if !usernamePattern.MatchString(name) {
+		return errors.InvalidInput(fmt.Sprintf("invalid user name '%s': use up to 32 lowercase letters, digits, '_' or '-', starting with a letter or '_'", name))
+	}
+	return nil
+}
+
+// ParseAuthorizedKey validates an OpenSSH public key line and returns it normalized to
+// "type base64 [comment]"
+func ParseAuthorizedKey(key string) (string, error) {
+	fields := strings.Fields(strings.TrimSpace(key))
+	if len(fields) < 2 {
+		return "", errors.InvalidInput("invalid public key: expected '<type> <base64> [comment]'")
+	}
+	if !authorizedKeyTypes[fields[0]] {
+		return "", errors.InvalidInput(fmt.Sprintf("unsupported public key type '%s'", fields[0]))
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", errors.InvalidInput("invalid public key: the key data is not base64")
+	}
+	// The key data starts with its own type, length prefixed
+	if len(blob) < 4 || uint64(binary.BigEndian.Uint32(blob)) > uint64(len(blob)-4) || string(blob[4:4+binary.BigEndian.Uint32(blob)]) != fields[0] {
+		return "", errors.InvalidInput("invalid public key: the key data does not match its type")
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// LoadAuthorizedKey returns the public key in source, which is either the key itself or the
+// path of a public key file on the host
+func LoadAuthorizedKey(source string) (string, error) {
+	if fields := strings.Fields(source); len(fields) > 0 && authorizedKeyTypes[fields[0]] {
+		return ParseAuthorizedKey(source)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to read public key: %w", err)
+	}
+	return ParseAuthorizedKey(string(data))
+}
+
+// KeyFingerprint returns the SHA256 fingerprint of a public key, as ssh-keygen -l prints it
+func KeyFingerprint(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return ""
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// CreateUserCommand returns the command that creates a guest user with a home directory
+func CreateUserCommand(user GuestUser) (string, error) {
+	if err := ValidateUsername(user.Name); err != nil {
+		return "", err
+	}
+	shell := user.Shell
+	if shell == "" {
+		shell = "/bin/bash"
+	}
+	if !filepath.IsAbs(shell) || strings.ContainsAny(shell, " '\"\\$`") {
+		return "", errors.InvalidInput(fmt.Sprintf("invalid shell '%s'", user.Shell))
+	}
+
+	commands := []string{
+		fmt.Sprintf("if id %[1]s >/dev/null 2>&1; then echo \"user %[1]s already exists\" >&2; exit 1; fi", user.Name),
+		fmt.Sprintf("sudo -n useradd --create-home --shell %s %s", shellquote.Quote(shell), user.Name),
+	}
+	if user.Sudo || user.PasswordlessSudo {
+		commands = append(commands, fmt.Sprintf("sudo -n usermod -aG sudo %s", user.Name))
+	}
+	if user.PasswordlessSudo {
+		sudoers := fmt.Sprintf("/etc/sudoers.d/90-vagrant-mcp-%s", user.Name)
+		commands = append(commands, fmt.Sprintf("echo '%s ALL=(ALL) NOPASSWD:ALL' | sudo -n tee %s >/dev/null && sudo -n chmod 0440 %s", user.Name, sudoers, sudoers))
+	}
+	return strings.Join(commands, " && "), nil
+}
+
+// AuthorizedKeysCommand returns the command that adds public keys to a guest user's
+// authorized_keys, or replaces its content with them
+func AuthorizedKeysCommand(username string, keys []string, replace bool) (string, error) {
+	if err := ValidateUsername(username); err != nil {
+		return "", err
+	}
+	normalized := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parsed, err := ParseAuthorizedKey(key)
+		if err != nil {
+			return "", err
+		}
+		normalized = append(normalized, parsed)
+	}
+	if len(normalized) == 0 {
+		return "", errors.InvalidInput("no public key given")
+	}
+
+	existing := `sudo -n cat "$keys" 2>/dev/null || true; `
+	if replace {
+		existing = ""
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte(strings.Join(normalized, "\n") + "\n"))
+	return fmt.Sprintf(`home=$(getent passwd %[1]s | cut -d: -f6) && [ -n "$home" ] || { echo "user %[1]s does not exist" >&2; exit 1; }; `+
+		`keys="$home/.ssh/authorized_keys" && `+
+		`sudo -n install -d -m 700 -o %[1]s -g "$(id -gn %[1]s)" "$home/.ssh" && `+
+		`{ %[2]secho '%[3]s' | base64 -d; } | awk 'NF && !seen[$0]++' | sudo -n tee "$keys.new" >/dev/null && `+
+		`sudo -n chown %[1]s: "$keys.new" && sudo -n chmod 600 "$keys.new" && sudo -n mv "$keys.new" "$keys"`,
+		username, existing, encoded), nil
+}
+
+// RotateSSHKey replaces the key Vagrant uses to connect to a VM with a new ed25519 key. The
+// new key is authorized and verified before it replaces the old one in authorized_keys and in
+// the machine's private_key file, so a failure leaves the old key working. Only keys Vagrant
+// generated for the machine are rotated; the shared insecure key is never overwritten.
+func RotateSSHKey(ctx context.Context, vmName string, sshConfig map[string]string) (RotatedKey, error) {
+	identity := strings.Trim(sshConfig["IdentityFile"], `"`)
+	user := sshConfig["User"]
+	if !isMachineKey(identity) {
+		return RotatedKey{}, errors.InvalidInput(fmt.Sprintf("the VM uses the key '%s', which is not a per-machine key generated by Vagrant; only those are rotated", identity))
+	}
+	if err := ValidateUsername(user); err != nil {
+		return RotatedKey{}, err
+	}
+
+	dir, err := os.MkdirTemp("", "vagrant-mcp-key-")
+	if err != nil {
+		return RotatedKey{}, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "private_key")
+	if output, err := exec.CommandContext(ctx, "ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "vagrant-mcp", "-f", keyFile).CombinedOutput(); err != nil {
+		return RotatedKey{}, fmt.Errorf("failed to generate key: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	publicKey, err := os.ReadFile(keyFile + ".pub")
+	if err != nil {
+		return RotatedKey{}, fmt.Errorf("failed to read generated key: %w", err)
+	}
+	rotated := RotatedKey{
+		User:         user,
+		IdentityFile: identity,
+		PublicKey:    strings.TrimSpace(string(publicKey)),
+		Fingerprint:  KeyFingerprint(string(publicKey)),
+	}
+
+	addCommand, err := AuthorizedKeysCommand(user, []string{rotated.PublicKey}, false)
+	if err != nil {
+		return RotatedKey{}, err
+	}
+	if err := runSSH(ctx, vmName, SSHArgs(sshConfig), addCommand); err != nil {
+		return RotatedKey{}, fmt.Errorf("failed to authorize the new key: %w", err)
+	}
+	newConfig := make(map[string]string, len(sshConfig))
+	for key, value := range sshConfig {
+		newConfig[key] = value
+	}
+	newConfig["IdentityFile"] = keyFile
+	newArgs := SSHArgs(newConfig)
+	if err := runSSH(ctx, vmName, newArgs, "true"); err != nil {
+		return RotatedKey{}, fmt.Errorf("failed to connect with the new key: %w", err)
+	}
+
+	// Stage the new private key next to the old one so the final swap is a rename
+	privateKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return RotatedKey{}, fmt.Errorf("failed to read generated key: %w", err)
+	}
+	staged := identity + ".new"
+	if err := os.WriteFile(staged, privateKey, 0600); err != nil {
+		return RotatedKey{}, fmt.Errorf("failed to write new key: %w", err)
+	}
+	replaceCommand, err := AuthorizedKeysCommand(user, []string{rotated.PublicKey}, true)
+	if err != nil {
+		os.Remove(staged)
+		return RotatedKey{}, err
+	}
+	if err := runSSH(ctx, vmName, newArgs, replaceCommand); err != nil {
+		os.Remove(staged)
+		return RotatedKey{}, fmt.Errorf("failed to remove the old key from the VM: %w", err)
+	}
+	if err := os.Rename(staged, identity); err != nil {
+		return RotatedKey{}, fmt.Errorf("the VM only accepts the new key, but it could not replace %s; it is at %s: %w", identity, staged, err)
+	}
+	return rotated, nil
+}
+
+// isMachineKey reports whether path is a private key Vagrant generated for one machine
+func isMachineKey(path string) bool {
+	slashed := filepath.ToSlash(path)
+	return strings.Contains(slashed, "/.vagrant/machines/") && filepath.Base(path) == "private_key"
+}
+
+// runSSH runs a command in the guest of a VM over ssh and records it in the audit log
+func runSSH(ctx context.Context, vmName string, sshArgs []string, command string) error {
+	var output bytes.Buffer
+	err := cmdexec.RunSSH(ctx, cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: command, Stdout: &output, Stderr: &output})
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
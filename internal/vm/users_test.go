@@ -0,0 +1,164 @@
+package vm
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEJfapLW8JFPWQKPjBBZ4/9azjunqG1GqjzIIpzbw4Lv alice@example"
+
+func TestValidateUsername(t *testing.T) {
+	testCases := []struct {
+		name      string
+		username  string
+		expectErr bool
+	}{
+		{"plain", "alice", false},
+		{"with digits and dash", "dev-2", false},
+		{"underscore start", "_svc", false},
+		{"empty", "", true},
+		{"uppercase", "Alice", true},
+		{"digit start", "2dev", true},
+		{"shell characters", "bob;reboot", true},
+		{"too long", strings.Repeat("a", 33), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateUsername(tc.username); tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestParseAuthorizedKey(t *testing.T) {
+	testCases := []struct {
+		name      string
+		key       string
+		expected  string
+		expectErr bool
+	}{
+		{"valid", testPublicKey, testPublicKey, false},
+		{"extra whitespace", "  ssh-ed25519   AAAAC3NzaC1lZDI1NTE5AAAAIEJfapLW8JFPWQKPjBBZ4/9azjunqG1GqjzIIpzbw4Lv \n", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEJfapLW8JFPWQKPjBBZ4/9azjunqG1GqjzIIpzbw4Lv", false},
+		{"missing data", "ssh-ed25519", "", true},
+		{"unknown type", "ssh-dss AAAAB3NzaC1kc3M=", "", true},
+		{"not base64", "ssh-ed25519 not*base64", "", true},
+		{"type mismatch", "ssh-rsa AAAAC3NzaC1lZDI1NTE5AAAAIEJfapLW8JFPWQKPjBBZ4/9azjunqG1GqjzIIpzbw4Lv", "", true},
+		{"truncated data", "ssh-ed25519 " + base64.StdEncoding.EncodeToString([]byte{0, 0, 0, 99, 's'}), "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := ParseAuthorizedKey(tc.key)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("Expected error %v but got %v", tc.expectErr, err)
+			}
+			if key != tc.expected {
+				t.Errorf("Expected key %q but got %q", tc.expected, key)
+			}
+		})
+	}
+}
+
+func TestLoadAuthorizedKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "id_ed25519.pub")
+	if err := os.WriteFile(file, []byte(testPublicKey+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	for _, source := range []string{file, testPublicKey} {
+		key, err := LoadAuthorizedKey(source)
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		if key != testPublicKey {
+			t.Errorf("Expected the public key but got %q", key)
+		}
+	}
+	if _, err := LoadAuthorizedKey(filepath.Join(t.TempDir(), "missing.pub")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	expected := "SHA256:ppCVVE4lQUQtcrgd0X50Kbm/ybd4ZhZ4YI0vMjsUdYk"
+	if fingerprint := KeyFingerprint(testPublicKey); fingerprint != expected {
+		t.Errorf("Expected fingerprint %s but got %s", expected, fingerprint)
+	}
+}
+
+func TestCreateUserCommand(t *testing.T) {
+	command, err := CreateUserCommand(GuestUser{Name: "alice", PasswordlessSudo: true})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	for _, expected := range []string{
+		"useradd --create-home --shell '/bin/bash' alice",
+		"usermod -aG sudo alice",
+		"/etc/sudoers.d/90-vagrant-mcp-alice",
+	} {
+		if !strings.Contains(command, expected) {
+			t.Errorf("Expected command to contain %q but got %q", expected, command)
+		}
+	}
+
+	command, err = CreateUserCommand(GuestUser{Name: "bob", Shell: "/bin/zsh"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if strings.Contains(command, "sudo -n usermod") || strings.Contains(command, "sudoers") {
+		t.Errorf("Expected no sudo rights but got %q", command)
+	}
+	if _, err := CreateUserCommand(GuestUser{Name: "bob", Shell: "zsh"}); err == nil {
+		t.Errorf("Expected an error for a relative shell")
+	}
+}
+
+func TestAuthorizedKeysCommand(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(testPublicKey + "\n"))
+	command, err := AuthorizedKeysCommand("alice", []string{testPublicKey}, false)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if !strings.Contains(command, encoded) || !strings.Contains(command, `sudo -n cat "$keys"`) {
+		t.Errorf("Expected the key appended to the existing keys but got %q", command)
+	}
+	if strings.Contains(command, "alice@example") {
+		t.Errorf("Expected the key to be encoded but got %q", command)
+	}
+
+	command, err = AuthorizedKeysCommand("alice", []string{testPublicKey}, true)
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if strings.Contains(command, `sudo -n cat "$keys"`) {
+		t.Errorf("Expected the existing keys to be replaced but got %q", command)
+	}
+
+	if _, err := AuthorizedKeysCommand("alice", nil, false); err == nil {
+		t.Errorf("Expected an error without keys")
+	}
+	if _, err := AuthorizedKeysCommand("alice", []string{"ssh-ed25519 bogus"}, false); err == nil {
+		t.Errorf("Expected an error for an invalid key")
+	}
+}
+
+func TestRotateSSHKeyRefusesSharedKeys(t *testing.T) {
+	for _, identity := range []string{
+		"/home/dev/.vagrant.d/insecure_private_key",
+		"/home/dev/.vagrant.d/insecure_private_keys/vagrant.key.ed25519",
+		"",
+	} {
+		config := map[string]string{"User": "vagrant", "HostName": "127.0.0.1", "Port": "2222", "IdentityFile": identity}
+		if _, err := RotateSSHKey(context.Background(), "test-vm", config); err == nil {
+			t.Errorf("Expected an error for identity file %q", identity)
+		}
+	}
+	if !isMachineKey("/projects/app/.vagrant/machines/default/virtualbox/private_key") {
+		t.Errorf("Expected the machine's private_key to be rotatable")
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/core"
+	"github.com/vagrant-mcp/server/internal/errors"
+)
+
+// Vagrantfile sections, in the order they are generated. Snippets are inserted after the
+// section they name.
+const (
+	SectionBox          = "box"
+	SectionProvider     = "provider"
+	SectionSettings     = "settings"
+	SectionNetwork      = "network"
+	SectionSync         = "sync"
+	SectionProvisioning = "provisioning"
+)
+
+// Sections lists the Vagrantfile sections snippets can follow
+var Sections = []string{SectionBox, SectionProvider, SectionSettings, SectionNetwork, SectionSync, SectionProvisioning}
+
+// TemplateFile is the name of the Vagrantfile template a project, or the templates directory,
+// provides in place of the built-in template
+const TemplateFile = "Vagrantfile.tmpl"
+
+// templateExtension is the extension of the named templates in the templates directory
+const templateExtension = ".tmpl"
+
+// maxSnippetBytes bounds the Ruby of a single snippet
+const maxSnippetBytes = 16 * 1024
+
+// snippetNamePattern restricts snippet names, which are written into Vagrantfile comments
+var snippetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// defaultRsyncExcludes are left out of Vagrant's rsync synced folder along with the VM's sync
+// exclude patterns
+var defaultRsyncExcludes = []string{".git/", "node_modules/", "dist/", ".vagrant/"}
+
+// rsyncExcludes returns the rsync__exclude entries of a VM's synced folder
+func rsyncExcludes(config core.VMConfig) []string {
+	excludes := append([]string{}, defaultRsyncExcludes...)
+	for _, pattern := range config.SyncExcludePatterns {
+		if !slices.Contains(excludes, pattern) {
+			excludes = append(excludes, pattern)
+		}
+	}
+	return excludes
+}
+
+// rubyString quotes s as a Ruby string literal that does not interpolate
+func rubyString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// vagrantfileTemplate generates the Vagrantfile of a VM. Each section is a named template, and
+// the snippets of a section follow it.
+var vagrantfileTemplate = template.Must(template.New("Vagrantfile").Funcs(template.FuncMap{
+	"indent": func(content string) string {
+		lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+		for i, line := range lines {
+			if strings.TrimSpace(line) != "" {
+				lines[i] = "  " + line
+			}
+		}
+		return strings.Join(lines, "\n")
+	},
+	"ruby": rubyString,
+}).Parse(`# -*- mode: ruby -*-
+# vi: set ft=ruby :
+# Generated by Vagrant MCP Server
+
+Vagrant.configure("2") do |config|
+{{- template "box" .}}{{template "snippets" index .Snippets "box"}}
+{{- template "provider" .}}{{template "snippets" index .Snippets "provider"}}
+{{- template "settings" .}}{{template "snippets" index .Snippets "settings"}}
+{{- template "network" .}}{{template "snippets" index .Snippets "network"}}
+{{- template "sync" .}}{{template "snippets" index .Snippets "sync"}}
+{{- template "provisioning" .}}{{template "snippets" index .Snippets "provisioning"}}end
+{{- define "box"}}
+  # Box settings
+  config.vm.box = {{ruby .Box}}
+{{end}}
+{{- define "provider"}}
+  # Provider-specific configuration
+{{.Provider}}
+{{end}}
+{{- define "settings"}}{{.Settings}}{{end}}
+{{- define "network"}}
+  # Network settings
+{{range .Ports}}  config.vm.network "forwarded_port", guest: {{.Guest}}, host: {{.Host}}, host_ip: "127.0.0.1"
+{{end}}{{.Network}}{{end}}
+{{- define "sync"}}
+  # Sync settings
+{{- if eq .SyncType "rsync"}}
+  config.vm.synced_folder {{ruby .ProjectPath}}, "/vagrant",
+    type: "rsync",
+    rsync__exclude: [{{range $i, $pattern := .RsyncExcludes}}{{if $i}}, {{end}}{{ruby $pattern}}{{end}}],
+    rsync__args: ["--verbose", "--archive", "--delete", "-z"]
+{{- else if eq .SyncType "nfs"}}
+{{- if not .Network}}
+  # NFS needs a private network between the host and the VM
+  config.vm.network "private_network", type: "dhcp"
+{{- end}}
+  config.vm.synced_folder {{ruby .ProjectPath}}, "/vagrant",
+    type: "nfs",
+    nfs_udp: false,
+    nfs_version: {{.NFSVersion}},
+    mount_options: ["actimeo=1"],
+    linux__nfs_options: ["rw", "no_subtree_check", "all_squash", "async"]
+{{- else if eq .SyncType "smb"}}
+  # The SMB credentials are kept next to this Vagrantfile, readable only by its owner
+  smb_username, smb_password = (File.readlines(File.expand_path("smb-credentials", __dir__), chomp: true) rescue [])
+  config.vm.synced_folder {{ruby .ProjectPath}}, "/vagrant",
+    type: "smb",
+    smb_username: smb_username,
+    smb_password: smb_password,
+    mount_options: ["vers=3.0", "mfsymlinks"]
+{{- else if eq .SyncType "virtiofs"}}
+  config.vm.synced_folder {{ruby .ProjectPath}}, "/vagrant",
+    type: "virtiofs"
+{{- else if eq .SyncType "9p"}}
+  config.vm.synced_folder {{ruby .ProjectPath}}, "/vagrant",
+    type: "9p",
+    accessmode: "mapped"
+{{- else}}
+  config.vm.synced_folder {{ruby .ProjectPath}}, "/vagrant"
+{{- end}}
+{{end}}
+{{- define "provisioning"}}
+  # Provisioning
+  config.vm.provision "shell", inline: <<-SHELL
+    # Update package list
+    apt-get update
+
+    # Install basic development tools
+    apt-get install -y build-essential curl git unzip
+{{range .Setup}}    {{.}}
+{{end}}    echo "Development VM setup completed!"
+  SHELL
+{{.Steps}}{{.Startup}}{{end}}
+{{- define "snippets"}}{{range .}}
+  # Snippet: {{.Name}}
+{{indent .Content}}
+{{end}}{{end}}
+`))
+
+// vagrantfileData is what the Vagrantfile template renders. Custom templates also get the VM
+// name and configuration.
+type vagrantfileData struct {
+	Name        string
+	Config      core.VMConfig
+	Box         string
+	Provider    string
+	Settings    string
+	Ports       []core.Port
+	Network     string
+	SyncType    string
+	ProjectPath string
+	// RsyncExcludes are left out of an rsync synced folder
+	RsyncExcludes []string
+	// NFSVersion is the NFS version of an nfs synced folder
+	NFSVersion int
+	Setup      []string
+	// Steps are the provisioners of the VM's provisioning steps, run after the setup
+	Steps string
+	// Startup is the provisioner running the startup commands after the setup on every boot
+	Startup  string
+	Snippets map[string][]core.VagrantfileSnippet
+}
+
+// renderVagrantfile renders the Vagrantfile template, or the custom template at templatePath
+// when it is set. Custom templates can use the built-in sections, e.g. {{template "provider" .}}.
+func renderVagrantfile(data vagrantfileData, snippets []core.VagrantfileSnippet, templatePath string) (string, error) {
+	tmpl := vagrantfileTemplate
+	if templatePath != "" {
+		text, err := os.ReadFile(templatePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read Vagrantfile template: %w", err)
+		}
+		builtin, err := vagrantfileTemplate.Clone()
+		if err != nil {
+			return "", err
+		}
+		if tmpl, err = builtin.New(filepath.Base(templatePath)).Parse(string(text)); err != nil {
+			return "", fmt.Errorf("invalid Vagrantfile template %s: %w", templatePath, err)
+		}
+	}
+	data.Snippets = make(map[string][]core.VagrantfileSnippet, len(Sections))
+	for _, snippet := range snippets {
+		section := snippet.Section
+		if section == "" {
+			section = SectionProvisioning
+		}
+		data.Snippets[section] = append(data.Snippets[section], snippet)
+	}
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+// TemplatesDir returns the directory of the user's Vagrantfile templates: MCP_TEMPLATES_DIR, or
+// ~/.vagrant-mcp/templates when it is not set
+func TemplatesDir() string {
+	if dir := os.Getenv("MCP_TEMPLATES_DIR"); dir != "" {
+		return dir
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".vagrant-mcp", "templates")
+}
+
+// VagrantfileTemplate returns the path of the custom template the Vagrantfile of a VM is
+// rendered from, or "" for the built-in template. A template named in the configuration comes
+// from the templates directory; otherwise Vagrantfile.tmpl in the project directory, then in
+// the templates directory, is used when it exists.
+func VagrantfileTemplate(config core.VMConfig) (string, error) {
+	if config.Template != "" {
+		if err := ValidateTemplate(config); err != nil {
+			return "", err
+		}
+		return filepath.Join(TemplatesDir(), config.Template+templateExtension), nil
+	}
+	candidates := []string{}
+	if config.ProjectPath != "" {
+		candidates = append(candidates, filepath.Join(config.ProjectPath, TemplateFile))
+	}
+	if dir := TemplatesDir(); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, TemplateFile))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+// ValidateTemplate checks that the template named in a VM configuration exists in the
+// templates directory
+func ValidateTemplate(config core.VMConfig) error {
+	if config.Template == "" {
+		return nil
+	}
+	if !snippetNamePattern.MatchString(config.Template) {
+		return errors.InvalidInput(fmt.Sprintf("invalid template name '%s': use letters, digits, '_' or '-'", config.Template))
+	}
+	path := filepath.Join(TemplatesDir(), config.Template+templateExtension)
+	if _, err := os.Stat(path); err != nil {
+		return errors.InvalidInput(fmt.Sprintf("Vagrantfile template '%s' not found: expected %s", config.Template, path))
+	}
+	return nil
+}
+
+// ValidateSnippets checks the Vagrantfile snippets of a VM configuration. Whether the Ruby is
+// valid is checked by 'vagrant validate' when the Vagrantfile is generated.
+func ValidateSnippets(config core.VMConfig) error {
+	names := make(map[string]bool)
+	for _, snippet := range config.Snippets {
+		if !snippetNamePattern.MatchString(snippet.Name) {
+			return errors.InvalidInput(fmt.Sprintf("invalid snippet name '%s': use letters, digits, '_' or '-'", snippet.Name))
+		}
+		if names[snippet.Name] {
+			return errors.InvalidInput(fmt.Sprintf("duplicate snippet '%s'", snippet.Name))
+		}
+		names[snippet.Name] = true
+		if snippet.Section != "" && !isSection(snippet.Section) {
+			return errors.InvalidInput(fmt.Sprintf("snippet '%s' has unknown section '%s': use one of %s", snippet.Name, snippet.Section, strings.Join(Sections, ", ")))
+		}
+		if strings.TrimSpace(snippet.Content) == "" {
+			return errors.InvalidInput(fmt.Sprintf("snippet '%s' is empty", snippet.Name))
+		}
+		if len(snippet.Content) > maxSnippetBytes {
+			return errors.InvalidInput(fmt.Sprintf("snippet '%s' is larger than %d bytes", snippet.Name, maxSnippetBytes))
+		}
+	}
+	return nil
+}
+
+// isSection reports whether name is a Vagrantfile section
+func isSection(name string) bool {
+	for _, section := range Sections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVagrantfileSnippets replaces the Vagrantfile snippets of a VM. Snippets that 'vagrant
+// validate' rejects leave the VM unchanged. A running VM is reloaded so they take effect.
+func (m *Manager) SetVagrantfileSnippets(ctx context.Context, name string, snippets []core.VagrantfileSnippet) (core.VMConfig, error) {
+	release, err := m.operations.acquire(name, OperationReconfigure)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	defer release()
+	config, err := m.GetVMConfig(ctx, name)
+	if err != nil {
+		return core.VMConfig{}, err
+	}
+	config.Snippets = snippets
+	if err := ValidateSnippets(config); err != nil {
+		return core.VMConfig{}, err
+	}
+	if err := m.applyConfig(ctx, name, config); err != nil {
+		return core.VMConfig{}, err
+	}
+	log.Info().Str("name", name).Int("snippets", len(snippets)).Msg("VM Vagrantfile snippets updated")
+	return config, nil
+}
@@ -0,0 +1,214 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vagrant-mcp/server/internal/core"
+)
+
+func TestRenderVagrantfileSnippets(t *testing.T) {
+	content, err := renderVagrantfile(vagrantfileData{
+		Box:           "ubuntu/focal64",
+		Provider:      `  config.vm.provider "virtualbox"`,
+		Ports:         []core.Port{{Guest: 3000, Host: 3000}},
+		SyncType:      "rsync",
+		ProjectPath:   "/src/app",
+		RsyncExcludes: rsyncExcludes(core.VMConfig{SyncExcludePatterns: []string{"node_modules/", "build", "it's#{x}"}}),
+		Setup:         []string{"sudo apt-get install -y golang"},
+	}, []core.VagrantfileSnippet{
+		{Name: "version", Section: SectionBox, Content: `config.vm.box_version = "20240101.0.0"`},
+		{Name: "hello", Content: "config.vm.provision \"shell\", inline: <<-SHELL\n  echo hello\nSHELL"},
+	}, "")
+	if err != nil {
+		t.Fatalf("Failed to render Vagrantfile: %v", err)
+	}
+
+	// Each snippet follows its section, indented inside Vagrant.configure
+	expectedOrder := []string{
+		`  config.vm.box = 'ubuntu/focal64'`,
+		"  # Snippet: version\n  config.vm.box_version = \"20240101.0.0\"\n",
+		`  # Provider-specific configuration`,
+		`  config.vm.network "forwarded_port", guest: 3000, host: 3000, host_ip: "127.0.0.1"`,
+		`  config.vm.synced_folder '/src/app', "/vagrant",`,
+		`    rsync__exclude: ['.git/', 'node_modules/', 'dist/', '.vagrant/', 'build', 'it\'s#{x}'],`,
+		"    sudo apt-get install -y golang\n",
+		"  SHELL\n\n  # Snippet: hello\n  config.vm.provision \"shell\", inline: <<-SHELL\n    echo hello\n  SHELL\nend\n",
+	}
+	position := 0
+	for _, expected := range expectedOrder {
+		index := strings.Index(content[position:], expected)
+		if index < 0 {
+			t.Fatalf("Expected %q after position %d in Vagrantfile:\n%s", expected, position, content)
+		}
+		position += index + len(expected)
+	}
+	if !strings.HasSuffix(content, "\nend\n") {
+		t.Errorf("Expected the Vagrantfile to end with the configure block but got:\n%s", content)
+	}
+}
+
+func TestRenderVagrantfileQuotesPaths(t *testing.T) {
+	testCases := []struct {
+		projectPath string
+		expected    string
+	}{
+		// Backslashes are kept, not taken as escapes
+		{`C:\Users\x\proj`, `config.vm.synced_folder 'C:\\Users\\x\\proj', "/vagrant"`},
+		// Quotes and interpolation stay inside the string
+		{`/src/say "hi" #{exit}`, `config.vm.synced_folder '/src/say "hi" #{exit}', "/vagrant"`},
+		{`/src/it's`, `config.vm.synced_folder '/src/it\'s', "/vagrant"`},
+	}
+	for _, syncType := range []string{"rsync", "nfs", "smb", "virtiofs", "9p", "virtualbox"} {
+		for _, tc := range testCases {
+			content, err := renderVagrantfile(vagrantfileData{Box: `evil"box`, SyncType: syncType, ProjectPath: tc.projectPath}, nil, "")
+			if err != nil {
+				t.Fatalf("Failed to render Vagrantfile: %v", err)
+			}
+			if !strings.Contains(content, tc.expected) {
+				t.Errorf("Expected %s with %s sync but got:\n%s", tc.expected, syncType, content)
+			}
+			if !strings.Contains(content, `config.vm.box = 'evil"box'`) {
+				t.Errorf("Expected the box name to be quoted but got:\n%s", content)
+			}
+		}
+	}
+}
+
+func TestValidateSnippets(t *testing.T) {
+	testCases := []struct {
+		name      string
+		snippets  []core.VagrantfileSnippet
+		expectErr bool
+	}{
+		{"none", nil, false},
+		{"valid", []core.VagrantfileSnippet{{Name: "gui", Section: SectionProvider, Content: "config.vm.boot_timeout = 600"}}, false},
+		{"default section", []core.VagrantfileSnippet{{Name: "timeout", Content: "config.vm.boot_timeout = 600"}}, false},
+		{"invalid name", []core.VagrantfileSnippet{{Name: "my snippet", Content: "x = 1"}}, true},
+		{"duplicate name", []core.VagrantfileSnippet{{Name: "a", Content: "x = 1"}, {Name: "a", Content: "y = 1"}}, true},
+		{"unknown section", []core.VagrantfileSnippet{{Name: "a", Section: "header", Content: "x = 1"}}, true},
+		{"empty", []core.VagrantfileSnippet{{Name: "a", Content: "  \n"}}, true},
+		{"too large", []core.VagrantfileSnippet{{Name: "a", Content: strings.Repeat("#", maxSnippetBytes+1)}}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateSnippets(core.VMConfig{Snippets: tc.snippets}); tc.expectErr != (err != nil) {
+				t.Errorf("Expected error %v but got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestGenerateVagrantfileRestoresOnFailedValidation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake vagrant is a shell script")
+	}
+	t.Setenv("CI", "")
+	t.Setenv("GITHUB_ACTIONS", "")
+	t.Setenv("SKIP_VAGRANT_VALIDATION", "")
+	t.Setenv("MCP_TEMPLATES_DIR", t.TempDir())
+	bin := t.TempDir()
+	// The fake vagrant rejects Vagrantfiles containing "invalid"
+	script := "#!/bin/sh\nif grep -q invalid Vagrantfile; then echo 'syntax error' >&2; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "vagrant"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake vagrant: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	m := &Manager{baseDir: filepath.Join(t.TempDir(), "vms")}
+	if err := os.MkdirAll(filepath.Join(m.baseDir, "dev"), 0755); err != nil {
+		t.Fatalf("Failed to create VM directory: %v", err)
+	}
+	config := core.VMConfig{Box: "ubuntu/focal64", CPU: 2, Memory: 2048}
+	if err := m.generateVagrantfile("dev", config); err != nil {
+		t.Fatalf("Failed to generate Vagrantfile: %v", err)
+	}
+	vagrantfile := filepath.Join(m.baseDir, "dev", "Vagrantfile")
+	valid, _ := os.ReadFile(vagrantfile)
+
+	config.Snippets = []core.VagrantfileSnippet{{Name: "broken", Content: "invalid ruby"}}
+	if err := m.generateVagrantfile("dev", config); err == nil {
+		t.Fatalf("Expected validation error")
+	}
+	if restored, _ := os.ReadFile(vagrantfile); string(restored) != string(valid) {
+		t.Errorf("Expected the previous Vagrantfile to be restored but got:\n%s", restored)
+	}
+}
+
+func TestVagrantfileTemplate(t *testing.T) {
+	templatesDir := t.TempDir()
+	t.Setenv("MCP_TEMPLATES_DIR", templatesDir)
+	project := t.TempDir()
+	config := core.VMConfig{ProjectPath: project}
+
+	if path, err := VagrantfileTemplate(config); err != nil || path != "" {
+		t.Errorf("Expected the built-in template but got %q, %v", path, err)
+	}
+	userTemplate := filepath.Join(templatesDir, TemplateFile)
+	if err := os.WriteFile(userTemplate, []byte("user"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if path, _ := VagrantfileTemplate(config); path != userTemplate {
+		t.Errorf("Expected %s but got %s", userTemplate, path)
+	}
+	// The project's template takes precedence over the user's
+	projectTemplate := filepath.Join(project, TemplateFile)
+	if err := os.WriteFile(projectTemplate, []byte("project"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if path, _ := VagrantfileTemplate(config); path != projectTemplate {
+		t.Errorf("Expected %s but got %s", projectTemplate, path)
+	}
+	// A named template takes precedence over both
+	namedTemplate := filepath.Join(templatesDir, "corp.tmpl")
+	if err := os.WriteFile(namedTemplate, []byte("corp"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	config.Template = "corp"
+	if path, _ := VagrantfileTemplate(config); path != namedTemplate {
+		t.Errorf("Expected %s but got %s", namedTemplate, path)
+	}
+
+	for _, name := range []string{"missing", "../corp"} {
+		if err := ValidateTemplate(core.VMConfig{Template: name}); err == nil {
+			t.Errorf("Expected error for template %q", name)
+		}
+	}
+}
+
+func TestRenderCustomTemplate(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), TemplateFile)
+	custom := `Vagrant.configure("2") do |config|
+  config.vagrant.plugins = ["vagrant-registry"]
+  config.vm.hostname = "{{.Name}}"
+{{- template "box" .}}{{template "snippets" index .Snippets "box"}}
+  # {{.Config.CPU}} cores
+end
+`
+	if err := os.WriteFile(templatePath, []byte(custom), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	content, err := renderVagrantfile(vagrantfileData{
+		Name:   "dev",
+		Config: core.VMConfig{CPU: 4},
+		Box:    "ubuntu/focal64",
+	}, []core.VagrantfileSnippet{{Name: "version", Section: SectionBox, Content: "config.vm.box_version = \"1\""}}, templatePath)
+	if err != nil {
+		t.Fatalf("Failed to render custom template: %v", err)
+	}
+	for _, expected := range []string{`config.vagrant.plugins = ["vagrant-registry"]`, `config.vm.hostname = "dev"`, `  config.vm.box = 'ubuntu/focal64'`, "  # Snippet: version", "# 4 cores"} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("Expected %q in Vagrantfile:\n%s", expected, content)
+		}
+	}
+
+	if err := os.WriteFile(templatePath, []byte("{{.Missing"), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if _, err := renderVagrantfile(vagrantfileData{}, nil, templatePath); err == nil {
+		t.Errorf("Expected error for an invalid template")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/cmdexec"
+	"github.com/vagrant-mcp/server/internal/hostos"
+)
+
+// warmCacheDir is the directory under the base directory holding guest directories
+// preserved across destroying and recreating a VM
+const warmCacheDir = ".warm-cache"
+
+// WarmCache describes an archive of guest directories taken before a VM was destroyed
+type WarmCache struct {
+	VMName    string    `json:"vm_name"`
+	Box       string    `json:"box,omitempty"`
+	Paths     []string  `json:"paths"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	Archive   string    `json:"archive"`
+}
+
+// SSHArgs returns the ssh arguments for a VM's 'vagrant ssh-config' output, ending with the user@host destination.
+// The arguments suit ssh.exe on Windows hosts, where Vagrant quotes key paths with spaces.
+// The host's SSH agent is forwarded when the VM enables it with config.ssh.forward_agent.
+func SSHArgs(sshConfig map[string]string) []string {
+	args := []string{
+		"-p", sshConfig["Port"],
+		"-i", strings.Trim(sshConfig["IdentityFile"], `"`),
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=" + hostos.NullDevice(),
+	}
+	if strings.EqualFold(sshConfig["ForwardAgent"], "yes") {
+		args = append(args, "-A")
+	}
+	return append(args, fmt.Sprintf("%s@%s", sshConfig["User"], sshConfig["HostName"]))
+}
+
+// SaveWarmCache archives guest directories of a running VM to the host so they can be
+// restored after the VM is recreated. Paths that do not exist in the guest are skipped.
+func SaveWarmCache(ctx context.Context, baseDir, vmName, box string, sshArgs []string, paths []string) (WarmCache, error) {
+	command, err := warmCacheArchiveCommand(paths)
+	if err != nil {
+		return WarmCache{}, err
+	}
+	dir := filepath.Join(baseDir, warmCacheDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return WarmCache{}, fmt.Errorf("failed to create warm cache directory: %w", err)
+	}
+
+	archive := warmCacheArchive(baseDir, vmName)
+	partial := archive + ".partial"
+	file, err := os.Create(partial)
+	if err != nil {
+		return WarmCache{}, fmt.Errorf("failed to create warm cache archive: %w", err)
+	}
+	defer os.Remove(partial)
+
+	var stderr bytes.Buffer
+	runErr := cmdexec.RunSSH(ctx, cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: command, Stdout: file, Stderr: &stderr})
+	closeErr := file.Close()
+	if runErr != nil {
+		return WarmCache{}, fmt.Errorf("failed to archive guest directories: %w: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+	if closeErr != nil {
+		return WarmCache{}, fmt.Errorf("failed to write warm cache archive: %w", closeErr)
+	}
+	if err := os.Rename(partial, archive); err != nil {
+		return WarmCache{}, fmt.Errorf("failed to save warm cache archive: %w", err)
+	}
+
+	cache := WarmCache{
+		VMName:    vmName,
+		Box:       box,
+		Paths:     paths,
+		CreatedAt: time.Now(),
+		Archive:   archive,
+	}
+	if info, err := os.Stat(archive); err == nil {
+		cache.SizeBytes = info.Size()
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return WarmCache{}, fmt.Errorf("failed to marshal warm cache manifest: %w", err)
+	}
+	if err := os.WriteFile(warmCacheManifest(baseDir, vmName), data, 0644); err != nil {
+		return WarmCache{}, fmt.Errorf("failed to save warm cache manifest: %w", err)
+	}
+	log.Info().Str("name", vmName).Strs("paths", paths).Int64("bytes", cache.SizeBytes).Msg("Warm cache saved")
+	return cache, nil
+}
+
+// LoadWarmCache returns the warm cache saved for a VM, or nil when there is none
+func LoadWarmCache(baseDir, vmName string) (*WarmCache, error) {
+	data, err := os.ReadFile(warmCacheManifest(baseDir, vmName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var cache WarmCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse warm cache manifest: %w", err)
+	}
+	if _, err := os.Stat(cache.Archive); err != nil {
+		return nil, fmt.Errorf("warm cache archive is missing: %w", err)
+	}
+	return &cache, nil
+}
+
+// RestoreWarmCache extracts the warm cache saved for a VM into the running guest and discards it
+func RestoreWarmCache(ctx context.Context, baseDir, vmName string, sshArgs []string) (*WarmCache, error) {
+	cache, err := LoadWarmCache(baseDir, vmName)
+	if err != nil || cache == nil {
+		return nil, err
+	}
+	file, err := os.Open(cache.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open warm cache archive: %w", err)
+	}
+	defer file.Close()
+
+	var stderr bytes.Buffer
+	restore := cmdexec.SSHCommand{VMName: vmName, Args: sshArgs, Command: "sudo -n tar -xzpf - -C /", Stdin: file, Stderr: &stderr}
+	if err := cmdexec.RunSSH(ctx, restore); err != nil {
+		return nil, fmt.Errorf("failed to restore guest directories: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	log.Info().Str("name", vmName).Strs("paths", cache.Paths).Msg("Warm cache restored")
+
+	if err := DiscardWarmCache(baseDir, vmName); err != nil {
+		log.Warn().Err(err).Str("name", vmName).Msg("Failed to discard restored warm cache")
+	}
+	return cache, nil
+}
+
+// DiscardWarmCache removes the warm cache saved for a VM
+func DiscardWarmCache(baseDir, vmName string) error {
+	for _, file := range []string{warmCacheArchive(baseDir, vmName), warmCacheManifest(baseDir, vmName)} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmCacheArchiveCommand returns the guest command that writes a tar.gz of paths to stdout
+func warmCacheArchiveCommand(paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no paths to preserve")
+	}
+	// The synced folder is recreated from the host with the VM
+	for _, p := range paths {
+		if cleaned := path.Clean(p); cleaned == "/vagrant" || strings.HasPrefix(cleaned, "/vagrant/") {
+			return "", fmt.Errorf("path '%s' cannot be preserved", p)
+		}
+	}
+	return guestArchiveCommand(paths)
+}
+
+// warmCacheArchive returns the path of a VM's warm cache archive
+func warmCacheArchive(baseDir, vmName string) string {
+	return filepath.Join(baseDir, warmCacheDir, vmName+".tar.gz")
+}
+
+// warmCacheManifest returns the path of a VM's warm cache manifest
+func warmCacheManifest(baseDir, vmName string) string {
+	return filepath.Join(baseDir, warmCacheDir, vmName+".json")
+}
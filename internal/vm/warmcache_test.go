@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWarmCacheArchiveCommand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		paths       []string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "cache directories",
+			paths:    []string{"/var/cache/apt/archives", "/home/vagrant/.cache/pip/"},
+			expected: "sudo -n tar -czf - --ignore-failed-read -C / 'var/cache/apt/archives' 'home/vagrant/.cache/pip'",
+		},
+		{name: "no paths", paths: nil, expectError: true},
+		{name: "relative path", paths: []string{"node_modules"}, expectError: true},
+		{name: "root", paths: []string{"/tmp/.."}, expectError: true},
+		{name: "synced folder", paths: []string{"/vagrant/node_modules"}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			command, err := warmCacheArchiveCommand(tc.paths)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error but got command %s", command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if command != tc.expected {
+				t.Errorf("Expected %q but got %q", tc.expected, command)
+			}
+		})
+	}
+}
+
+func TestLoadAndDiscardWarmCache(t *testing.T) {
+	baseDir := t.TempDir()
+	if cache, err := LoadWarmCache(baseDir, "dev"); cache != nil || err != nil {
+		t.Fatalf("Expected no warm cache but got %+v, %v", cache, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(baseDir, warmCacheDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	archive := warmCacheArchive(baseDir, "dev")
+	if err := os.WriteFile(archive, []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"vm_name":"dev","box":"ubuntu/focal64","paths":["/var/cache/apt"],"archive":"` + archive + `"}`
+	if err := os.WriteFile(warmCacheManifest(baseDir, "dev"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := LoadWarmCache(baseDir, "dev")
+	if err != nil || cache == nil || cache.Box != "ubuntu/focal64" || len(cache.Paths) != 1 {
+		t.Fatalf("Unexpected warm cache: %+v, %v", cache, err)
+	}
+	if err := DiscardWarmCache(baseDir, "dev"); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Errorf("Expected the archive to be removed but got %v", err)
+	}
+}
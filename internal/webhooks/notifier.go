@@ -0,0 +1,364 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package webhooks posts server events to configured webhook URLs
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+// Payload formats of a webhook
+const (
+	// FormatJSON posts the event as it appears in devvm://events
+	FormatJSON = "json"
+	// FormatSlack posts a Slack incoming webhook message describing the event
+	FormatSlack = "slack"
+)
+
+// Request headers set on every delivery
+const (
+	HeaderEvent     = "X-Vagrant-MCP-Event"
+	HeaderDelivery  = "X-Vagrant-MCP-Delivery"
+	HeaderSignature = "X-Vagrant-MCP-Signature"
+)
+
+// Delivery settings
+const (
+	DefaultAttempts = 4
+	DefaultBackoff  = time.Second
+	// queueSize bounds the deliveries waiting while webhooks are slow or unreachable
+	queueSize = 256
+)
+
+// GlobalNotifier is the webhook notifier shared by the server
+var GlobalNotifier = NewNotifierFromEnv()
+
+// Webhook is a URL events are posted to
+type Webhook struct {
+	URL string `json:"url"`
+	// Events lists globs of the event types posted, e.g. vm.*; every event when empty
+	Events []string `json:"events,omitempty"`
+	// VMs lists globs of the VM names whose events are posted; every VM when empty
+	VMs []string `json:"vms,omitempty"`
+	// Format is json or slack; json when empty
+	Format string `json:"format,omitempty"`
+	// Secret signs the body with HMAC-SHA256; SecretEnv names an environment variable holding it
+	Secret    string `json:"secret,omitempty"`
+	SecretEnv string `json:"secret_env,omitempty"`
+}
+
+// Config is the webhooks file
+type Config struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// delivery is an event waiting to be posted to a webhook
+type delivery struct {
+	webhook Webhook
+	event   events.Event
+}
+
+// Notifier posts events to webhooks in the background, retrying failed deliveries
+type Notifier struct {
+	webhooks []Webhook
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	queue    chan delivery
+}
+
+// NewNotifier creates a notifier posting to webhooks
+func NewNotifier(webhooks []Webhook) *Notifier {
+	return &Notifier{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		attempts: DefaultAttempts,
+		backoff:  DefaultBackoff,
+		queue:    make(chan delivery, queueSize),
+	}
+}
+
+// NewNotifierFromEnv creates a notifier with the webhooks in MCP_WEBHOOKS_FILE, or
+// ~/.vagrant-mcp/webhooks.json when it is not set. Without a valid file nothing is posted.
+func NewNotifierFromEnv() *Notifier {
+	file := File()
+	if file == "" {
+		return NewNotifier(nil)
+	}
+	config, err := LoadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("file", file).Msg("Failed to load webhooks; no events will be posted")
+		}
+		return NewNotifier(nil)
+	}
+	log.Info().Str("file", file).Int("webhooks", len(config.Webhooks)).Msg("Loaded webhooks")
+	return NewNotifier(config.Webhooks)
+}
+
+// File returns the path of the webhooks file
+func File() string {
+	if file := os.Getenv("MCP_WEBHOOKS_FILE"); file != "" {
+		return file
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".vagrant-mcp", "webhooks.json")
+}
+
+// LoadFile reads and validates a webhooks file
+func LoadFile(file string) (Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse webhooks file: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Validate checks the webhooks of a webhooks file
+func (c Config) Validate() error {
+	for i, webhook := range c.Webhooks {
+		parsed, err := url.Parse(webhook.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid URL in webhook #%d: use an http or https URL", i+1)
+		}
+		if webhook.Format != "" && webhook.Format != FormatJSON && webhook.Format != FormatSlack {
+			return fmt.Errorf("invalid format %q in webhook #%d: use %s or %s", webhook.Format, i+1, FormatJSON, FormatSlack)
+		}
+		for _, pattern := range append(append([]string{}, webhook.Events...), webhook.VMs...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q in webhook #%d: %w", pattern, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether any webhook is configured
+func (n *Notifier) Enabled() bool {
+	return len(n.webhooks) > 0
+}
+
+// Run posts the events published on bus to the webhooks until ctx is done
+func (n *Notifier) Run(ctx context.Context, bus *events.Bus) {
+	if !n.Enabled() {
+		return
+	}
+	unsubscribe := bus.Subscribe(n.enqueue)
+	defer unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-n.queue:
+			if err := n.deliver(ctx, d.webhook, d.event); err != nil {
+				log.Warn().Err(err).Str("event", string(d.event.Type)).Str("url", redactURL(d.webhook.URL)).Msg("Failed to post event to webhook")
+			}
+		}
+	}
+}
+
+// enqueue queues an event for the webhooks it matches, dropping it when the queue is full so
+// publishers never wait on webhooks
+func (n *Notifier) enqueue(event events.Event) {
+	for _, webhook := range n.webhooks {
+		if !webhook.matches(event) {
+			continue
+		}
+		select {
+		case n.queue <- delivery{webhook: webhook, event: event}:
+		default:
+			log.Warn().Str("event", string(event.Type)).Str("url", redactURL(webhook.URL)).Msg("Webhook queue is full; dropping event")
+		}
+	}
+}
+
+// matches reports whether an event is posted to a webhook
+func (w Webhook) matches(event events.Event) bool {
+	return matchesAny(w.Events, string(event.Type)) && matchesAny(w.VMs, event.VMName)
+}
+
+// matchesAny reports whether value matches one of the globs, or there are none
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver posts an event to a webhook, retrying network errors, 429 and 5xx responses with
+// exponential backoff
+func (n *Notifier) deliver(ctx context.Context, webhook Webhook, event events.Event) error {
+	body, err := payload(webhook, event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, webhook, event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.attempts {
+			return fmt.Errorf("attempt %d: %w", attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one delivery attempt and reports whether a failure is worth retrying
+func (n *Notifier) post(ctx context.Context, webhook Webhook, event events.Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(event.ID, 10))
+	if secret := webhook.secret(); secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// secret returns the key deliveries to a webhook are signed with
+func (w Webhook) secret() string {
+	if w.SecretEnv != "" {
+		return os.Getenv(w.SecretEnv)
+	}
+	return w.Secret
+}
+
+// Sign returns the signature header value of a body: sha256= followed by the hex HMAC-SHA256
+// of the body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// payload returns the request body posting an event to a webhook
+func payload(webhook Webhook, event events.Event) ([]byte, error) {
+	if webhook.Format == FormatSlack {
+		return json.Marshal(map[string]string{"text": Describe(event)})
+	}
+	return json.Marshal(event)
+}
+
+// Describe returns a one-line description of an event for chat messages
+func Describe(event events.Event) string {
+	vm := fmt.Sprintf("VM '%s'", event.VMName)
+	str := func(key string) string {
+		value, _ := event.Data[key].(string)
+		return value
+	}
+	switch event.Type {
+	case events.VMStateChanged:
+		switch str("operation") {
+		case "create":
+			return vm + " was created"
+		case "destroy":
+			return vm + " was destroyed"
+		case "start":
+			return vm + " was started"
+		case "stop":
+			return vm + " was halted"
+		}
+		return fmt.Sprintf("%s is now %v after %s", vm, event.Data["state"], str("operation"))
+	case events.VMOperationFailed:
+		message := fmt.Sprintf(":x: %s failed to %s", vm, str("operation"))
+		cause := str("error")
+		if cause == "" {
+			cause = lastLine(str("output"))
+		}
+		if cause != "" {
+			message += ": " + cause
+		}
+		return message
+	case events.VMExpiring:
+		if message := str("message"); message != "" {
+			return message
+		}
+	case events.VMExpired:
+		return fmt.Sprintf("%s expired and was %s", vm, pastTense(str("action")))
+	case events.SyncCompleted:
+		return fmt.Sprintf("%v files were synced %s %s", event.Data["file_count"], str("direction"), vm)
+	case events.ConflictDetected:
+		return fmt.Sprintf("A sync conflict was detected in %s at %s", vm, str("path"))
+	case events.WatcherError:
+		return fmt.Sprintf("The file watcher of %s failed", vm)
+	}
+	if event.VMName == "" {
+		return string(event.Type)
+	}
+	return fmt.Sprintf("%s: %s", event.Type, vm)
+}
+
+// pastTense returns the past participle of an expiry action
+func pastTense(action string) string {
+	if action == "destroy" {
+		return "destroyed"
+	}
+	return "halted"
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// redactURL returns a webhook URL without its path, which often holds a token
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,184 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vagrant-mcp/server/internal/events"
+)
+
+// recorder is a webhook endpoint answering with the given statuses in turn, then 200
+type recorder struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if len(r.statuses) > 0 {
+		w.WriteHeader(r.statuses[0])
+		r.statuses = r.statuses[1:]
+	}
+}
+
+// newTestNotifier returns a notifier that retries without waiting
+func newTestNotifier(webhooks []Webhook) *Notifier {
+	notifier := NewNotifier(webhooks)
+	notifier.backoff = time.Millisecond
+	return notifier
+}
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		webhook   Webhook
+		expectErr bool
+	}{
+		{"json webhook", Webhook{URL: "https://ci.example.com/hooks/vagrant", Events: []string{"vm.*"}}, false},
+		{"slack webhook", Webhook{URL: "https://hooks.slack.com/services/T0/B0/x", Format: FormatSlack}, false},
+		{"missing URL", Webhook{}, true},
+		{"unsupported scheme", Webhook{URL: "ftp://example.com/hook"}, true},
+		{"unknown format", Webhook{URL: "https://example.com", Format: "xml"}, true},
+		{"invalid pattern", Webhook{URL: "https://example.com", VMs: []string{"shop-["}}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Config{Webhooks: []Webhook{tc.webhook}}.Validate()
+			if tc.expectErr && err == nil {
+				t.Errorf("Expected an error")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("Expected no error but got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	webhook := Webhook{Events: []string{"vm.state_changed", "vm.operation_failed"}, VMs: []string{"shop-*"}}
+	testCases := []struct {
+		eventType events.Type
+		vmName    string
+		expected  bool
+	}{
+		{events.VMStateChanged, "shop-web", true},
+		{events.VMOperationFailed, "shop-db", true},
+		{events.SyncCompleted, "shop-web", false},
+		{events.VMStateChanged, "blog", false},
+	}
+	for _, tc := range testCases {
+		if matched := webhook.matches(events.Event{Type: tc.eventType, VMName: tc.vmName}); matched != tc.expected {
+			t.Errorf("Expected %s for %s to match %v but got %v", tc.eventType, tc.vmName, tc.expected, matched)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	event := events.Event{ID: 7, Type: events.VMStateChanged, VMName: "web", Data: map[string]interface{}{"operation": "create"}}
+	testCases := []struct {
+		name             string
+		statuses         []int
+		expectErr        bool
+		expectedAttempts int
+	}{
+		{"delivered", nil, false, 1},
+		{"retried after server errors", []int{http.StatusBadGateway, http.StatusTooManyRequests}, false, 3},
+		{"not retried after a client error", []int{http.StatusNotFound}, true, 1},
+		{"gives up after the last attempt", []int{500, 500, 500, 500, 500}, true, DefaultAttempts},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			endpoint := &recorder{statuses: tc.statuses}
+			server := httptest.NewServer(endpoint)
+			defer server.Close()
+
+			webhook := Webhook{URL: server.URL, Secret: "s3cret"}
+			err := newTestNotifier([]Webhook{webhook}).deliver(context.Background(), webhook, event)
+			if tc.expectErr && err == nil {
+				t.Errorf("Expected an error")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("Expected no error but got %v", err)
+			}
+			if len(endpoint.requests) != tc.expectedAttempts {
+				t.Fatalf("Expected %d attempts but got %d", tc.expectedAttempts, len(endpoint.requests))
+			}
+			req, body := endpoint.requests[0], endpoint.bodies[0]
+			if req.Header.Get(HeaderEvent) != "vm.state_changed" || req.Header.Get(HeaderDelivery) != "7" {
+				t.Errorf("Expected event headers but got %v", req.Header)
+			}
+			if signature := req.Header.Get(HeaderSignature); signature != Sign("s3cret", body) {
+				t.Errorf("Expected signature %s but got %s", Sign("s3cret", body), signature)
+			}
+			var posted events.Event
+			if err := json.Unmarshal(body, &posted); err != nil || posted.VMName != "web" {
+				t.Errorf("Expected the event as JSON but got %s", body)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	endpoint := &recorder{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+	t.Setenv("TEST_WEBHOOK_SECRET", "from-env")
+
+	notifier := newTestNotifier([]Webhook{
+		{URL: server.URL + "/slack", Format: FormatSlack, Events: []string{"vm.operation_failed"}},
+		{URL: server.URL + "/ci", SecretEnv: "TEST_WEBHOOK_SECRET"},
+	})
+	bus := events.NewBus(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx, bus)
+
+	// Publish until the notifier has subscribed and posted an event
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		endpoint.mu.Lock()
+		done := len(endpoint.requests) >= 2
+		endpoint.mu.Unlock()
+		if done {
+			break
+		}
+		bus.Publish(events.VMOperationFailed, "web", map[string]interface{}{"operation": "start", "output": "==> default: Running provisioner: shell...\nThe SSH command responded with a non-zero exit status.\n"})
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+	if len(endpoint.requests) < 2 {
+		t.Fatalf("Expected the event to be posted to both webhooks but got %d requests", len(endpoint.requests))
+	}
+	for i, req := range endpoint.requests[:2] {
+		switch req.URL.Path {
+		case "/slack":
+			var message map[string]string
+			_ = json.Unmarshal(endpoint.bodies[i], &message)
+			expected := ":x: VM 'web' failed to start: The SSH command responded with a non-zero exit status."
+			if message["text"] != expected {
+				t.Errorf("Expected Slack text %q but got %q", expected, message["text"])
+			}
+			if req.Header.Get(HeaderSignature) != "" {
+				t.Errorf("Expected no signature without a secret")
+			}
+		case "/ci":
+			if req.Header.Get(HeaderSignature) != Sign("from-env", endpoint.bodies[i]) {
+				t.Errorf("Expected the body to be signed with the secret from the environment")
+			}
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright Ricardo Oliveira 2025.
+// SPDX-License-Identifier: MPL-2.0
+
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+)
+
+// Limit names reported in throttling errors
+const (
+	LimitConcurrentBoots = "concurrent_boots"
+	LimitVMsPerSession   = "vms_per_session"
+	LimitExecPerMinute   = "exec_per_minute"
+)
+
+// Default limits; zero disables a limit
+const (
+	DefaultMaxConcurrentBoots = 3
+	DefaultMaxVMsPerSession   = 10
+	DefaultMaxExecPerMinute   = 120
+)
+
+// defaultSession keys the usage of calls made outside a client session
+const defaultSession = "default"
+
+// bootTools run 'vagrant up' or 'vagrant reload'
+var bootTools = map[string]bool{
+	"create_dev_vm":            true,
+	"ensure_dev_vm":            true,
+	"bake_base_image":          true,
+	"set_vm_resources":         true,
+	"resize_vm_disk":           true,
+	"connect_vms":              true,
+	"set_vagrantfile_snippets": true,
+}
+
+// execTools run commands in a VM
+var execTools = map[string]bool{
+	"exec_in_vm":               true,
+	"exec_with_sync":           true,
+	"run_background_task":      true,
+	"run_script_in_vm":         true,
+	"run_tests":                true,
+	"run_sql":                  true,
+	"send_to_shell":            true,
+	"start_background_process": true,
+	"start_dev_server":         true,
+}
+
+// Limits bounds the expensive operations clients may start
+type Limits struct {
+	// MaxConcurrentBoots bounds the tools running 'vagrant up' or 'vagrant reload' at once
+	MaxConcurrentBoots int
+	// MaxVMsPerSession bounds the VMs a client session may create
+	MaxVMsPerSession int
+	// MaxExecPerMinute bounds the commands a client session may run in VMs per minute
+	MaxExecPerMinute int
+}
+
+// LimitsFromEnv returns the limits set with MCP_MAX_CONCURRENT_BOOTS, MCP_MAX_VMS_PER_SESSION
+// and MCP_MAX_EXEC_PER_MINUTE
+func LimitsFromEnv() Limits {
+	return Limits{
+		MaxConcurrentBoots: intFromEnv("MCP_MAX_CONCURRENT_BOOTS", DefaultMaxConcurrentBoots),
+		MaxVMsPerSession:   intFromEnv("MCP_MAX_VMS_PER_SESSION", DefaultMaxVMsPerSession),
+		MaxExecPerMinute:   intFromEnv("MCP_MAX_EXEC_PER_MINUTE", DefaultMaxExecPerMinute),
+	}
+}
+
+// intFromEnv returns a non-negative integer environment variable, or fallback when it is unset
+// or invalid
+func intFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		log.Warn().Str("value", value).Msgf("Ignoring invalid %s", name)
+		return fallback
+	}
+	return parsed
+}
+
+// Throttle describes a tool call refused by a limit
+type Throttle struct {
+	Error             string `json:"error"`
+	Limit             string `json:"limit"`
+	Max               int    `json:"max"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+	Message           string `json:"message"`
+}
+
+// sessionUsage is what a client session has started
+type sessionUsage struct {
+	vms            map[string]bool
+	pendingCreates int
+	execs          []time.Time
+}
+
+// pendingCreate is a running tool call that may create a VM. vmName is empty for calls that
+// create the VM a project manifest names.
+type pendingCreate struct {
+	session string
+	vmName  string
+	created bool
+}
+
+// RateLimiter refuses tool calls that would exceed the limits. The VMs a session created are
+// counted as the VM manager reports them through VMCreated and VMDestroyed.
+type RateLimiter struct {
+	limits   Limits
+	mu       sync.Mutex
+	boots    int
+	sessions map[string]*sessionUsage
+	creates  []*pendingCreate
+	now      func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter enforcing limits
+func NewRateLimiter(limits Limits) *RateLimiter {
+	return &RateLimiter{
+		limits:   limits,
+		sessions: make(map[string]*sessionUsage),
+		now:      time.Now,
+	}
+}
+
+// Middleware refuses the tool calls that exceed the limits with a structured throttling error
+func (l *RateLimiter) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			tool := request.Params.Name
+			session := sessionKey(ctx)
+			arguments := request.GetArguments()
+			vmName, _ := arguments["name"].(string)
+			// ensure_dev_vm without a name creates the VM its project manifest declares
+			creates := tool == "create_dev_vm" || (tool == "ensure_dev_vm" && (arguments["project_path"] != nil || vmName == ""))
+
+			release, throttle := l.acquire(session, tool, vmName, creates)
+			if throttle != nil {
+				log.Warn().Str("tool", tool).Str("session", session).Str("limit", throttle.Limit).Msg("Tool call throttled")
+				return throttledResult(*throttle), nil
+			}
+			defer release()
+			return next(ctx, request)
+		}
+	}
+}
+
+// acquire checks the limits for a tool call and reserves what it uses. The returned function
+// releases the reservation.
+func (l *RateLimiter) acquire(session, tool, vmName string, creates bool) (func(), *Throttle) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	usage := l.usage(session)
+
+	if execTools[tool] && l.limits.MaxExecPerMinute > 0 {
+		now := l.now()
+		window := now.Add(-time.Minute)
+		recent := usage.execs[:0]
+		for _, at := range usage.execs {
+			if at.After(window) {
+				recent = append(recent, at)
+			}
+		}
+		usage.execs = recent
+		if len(usage.execs) >= l.limits.MaxExecPerMinute {
+			retryAfter := usage.execs[0].Add(time.Minute).Sub(now)
+			return nil, &Throttle{
+				Limit:             LimitExecPerMinute,
+				Max:               l.limits.MaxExecPerMinute,
+				RetryAfterSeconds: int(math.Ceil(retryAfter.Seconds())),
+				Message:           fmt.Sprintf("this session ran %d commands in VMs in the last minute, the most allowed", len(usage.execs)),
+			}
+		}
+		usage.execs = append(usage.execs, now)
+	}
+
+	newVM := creates && !usage.vms[vmName]
+	if newVM && l.limits.MaxVMsPerSession > 0 && len(usage.vms)+usage.pendingCreates >= l.limits.MaxVMsPerSession {
+		return nil, &Throttle{
+			Limit:   LimitVMsPerSession,
+			Max:     l.limits.MaxVMsPerSession,
+			Message: fmt.Sprintf("this session created %d VMs, the most allowed; destroy one with destroy_dev_vm or destroy_vms before creating another", len(usage.vms)),
+		}
+	}
+
+	boot := bootTools[tool]
+	if boot && l.limits.MaxConcurrentBoots > 0 && l.boots >= l.limits.MaxConcurrentBoots {
+		return nil, &Throttle{
+			Limit:             LimitConcurrentBoots,
+			Max:               l.limits.MaxConcurrentBoots,
+			RetryAfterSeconds: 30,
+			Message:           fmt.Sprintf("%d VMs are booting or reloading, the most allowed at once; retry when one has finished", l.boots),
+		}
+	}
+
+	if boot {
+		l.boots++
+	}
+	if newVM {
+		usage.pendingCreates++
+	}
+	var pending *pendingCreate
+	if creates {
+		pending = &pendingCreate{session: session, vmName: vmName}
+		l.creates = append(l.creates, pending)
+	}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if boot {
+			l.boots--
+		}
+		if newVM {
+			usage.pendingCreates--
+		}
+		if pending != nil {
+			l.creates = slices.DeleteFunc(l.creates, func(p *pendingCreate) bool { return p == pending })
+		}
+	}, nil
+}
+
+// VMCreated counts a VM the VM manager created against the session whose tool call created
+// it: the running call naming the VM or, failing that, one creating the VM a project
+// manifest names. VMs created outside a limited tool call are not counted.
+func (l *RateLimiter) VMCreated(vmName string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var creator *pendingCreate
+	for _, pending := range l.creates {
+		if pending.vmName == vmName {
+			creator = pending
+			break
+		}
+	}
+	if creator == nil {
+		for _, pending := range l.creates {
+			if pending.vmName == "" && !pending.created {
+				creator = pending
+				break
+			}
+		}
+	}
+	if creator == nil {
+		return
+	}
+	creator.created = true
+	l.usage(creator.session).vms[vmName] = true
+}
+
+// VMDestroyed stops counting a destroyed VM against the session that created it, however it
+// was destroyed: by a tool, an approved operation or the expiry reaper
+func (l *RateLimiter) VMDestroyed(vmName string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, usage := range l.sessions {
+		delete(usage.vms, vmName)
+	}
+}
+
+// usage returns the usage of a session, creating it on first use
+func (l *RateLimiter) usage(session string) *sessionUsage {
+	usage, ok := l.sessions[session]
+	if !ok {
+		usage = &sessionUsage{vms: make(map[string]bool)}
+		l.sessions[session] = usage
+	}
+	return usage
+}
+
+// sessionKey returns the client session of a tool call
+func sessionKey(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil && session.SessionID() != "" {
+		return session.SessionID()
+	}
+	return defaultSession
+}
+
+// throttledResult returns the tool error for a throttled call
+func throttledResult(throttle Throttle) *mcpgo.CallToolResult {
+	throttle.Error = "rate_limited"
+	jsonData, err := json.Marshal(throttle)
+	if err != nil {
+		return mcpgo.NewToolResultError(throttle.Message)
+	}
+	return mcpgo.NewToolResultError(string(jsonData))
+}
@@ -0,0 +1,186 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+// callTool calls a tool through the rate limiter with a handler returning text
+func callTool(limiter *RateLimiter, tool string, arguments map[string]interface{}, text string) *mcpgo.CallToolResult {
+	handler := limiter.Middleware()(func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		return mcpgo.NewToolResultText(text), nil
+	})
+	request := mcpgo.CallToolRequest{}
+	request.Params.Name = tool
+	request.Params.Arguments = arguments
+	result, _ := handler(context.Background(), request)
+	return result
+}
+
+// callToolDoing calls a tool through the rate limiter with a handler running effect, such as
+// the VM manager reporting a VM it created
+func callToolDoing(limiter *RateLimiter, tool string, arguments map[string]interface{}, effect func()) *mcpgo.CallToolResult {
+	handler := limiter.Middleware()(func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		if effect != nil {
+			effect()
+		}
+		return mcpgo.NewToolResultText("ok"), nil
+	})
+	request := mcpgo.CallToolRequest{}
+	request.Params.Name = tool
+	request.Params.Arguments = arguments
+	result, _ := handler(context.Background(), request)
+	return result
+}
+
+// resultText returns the text of a tool result
+func resultText(result *mcpgo.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := content.(mcpgo.TextContent); ok {
+			return text.Text
+		}
+	}
+	return ""
+}
+
+// throttleOf returns the throttle in a tool result, if any
+func throttleOf(t *testing.T, result *mcpgo.CallToolResult) *Throttle {
+	if !result.IsError {
+		return nil
+	}
+	var throttle Throttle
+	if err := json.Unmarshal([]byte(resultText(result)), &throttle); err != nil {
+		t.Fatalf("Expected structured throttling error but got %q", resultText(result))
+	}
+	return &throttle
+}
+
+func TestRateLimiterExecPerMinute(t *testing.T) {
+	limiter := NewRateLimiter(Limits{MaxExecPerMinute: 2})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if throttle := throttleOf(t, callTool(limiter, "exec_in_vm", nil, "ok")); throttle != nil {
+			t.Fatalf("Expected call %d to run but got %+v", i, throttle)
+		}
+	}
+	throttle := throttleOf(t, callTool(limiter, "exec_in_vm", nil, "ok"))
+	if throttle == nil || throttle.Limit != LimitExecPerMinute || throttle.Error != "rate_limited" {
+		t.Fatalf("Expected exec limit throttle but got %+v", throttle)
+	}
+	if throttle.RetryAfterSeconds != 60 {
+		t.Errorf("Expected retry after 60 seconds but got %d", throttle.RetryAfterSeconds)
+	}
+	// Other tools are not counted
+	if throttle := throttleOf(t, callTool(limiter, "get_vm_status", nil, "ok")); throttle != nil {
+		t.Errorf("Expected get_vm_status to run but got %+v", throttle)
+	}
+
+	now = now.Add(61 * time.Second)
+	if throttle := throttleOf(t, callTool(limiter, "exec_in_vm", nil, "ok")); throttle != nil {
+		t.Errorf("Expected call after the window to run but got %+v", throttle)
+	}
+}
+
+func TestRateLimiterVMsPerSession(t *testing.T) {
+	limiter := NewRateLimiter(Limits{MaxVMsPerSession: 2})
+
+	callToolDoing(limiter, "create_dev_vm", map[string]interface{}{"name": "a"}, func() { limiter.VMCreated("a") })
+	// ensure_dev_vm only counts when it creates the VM
+	callToolDoing(limiter, "ensure_dev_vm", map[string]interface{}{"name": "b", "project_path": "/p"}, nil)
+	callToolDoing(limiter, "ensure_dev_vm", map[string]interface{}{"name": "c", "project_path": "/p"}, func() { limiter.VMCreated("c") })
+
+	throttle := throttleOf(t, callTool(limiter, "create_dev_vm", map[string]interface{}{"name": "d"}, "created"))
+	if throttle == nil || throttle.Limit != LimitVMsPerSession || throttle.Max != 2 {
+		t.Fatalf("Expected VM limit throttle but got %+v", throttle)
+	}
+	// Starting a VM without creating it is never limited
+	if throttle := throttleOf(t, callTool(limiter, "ensure_dev_vm", map[string]interface{}{"name": "b"}, "started")); throttle != nil {
+		t.Errorf("Expected ensure_dev_vm without project_path to run but got %+v", throttle)
+	}
+
+	// A destroy parked for approval keeps counting the VM until the approved destroy runs
+	callToolDoing(limiter, "destroy_dev_vm", map[string]interface{}{"name": "a"}, nil)
+	if throttle := throttleOf(t, callTool(limiter, "create_dev_vm", map[string]interface{}{"name": "d"}, "created")); throttle == nil {
+		t.Fatalf("Expected VM limit throttle while destroy awaits approval")
+	}
+	callToolDoing(limiter, "approve_operation", map[string]interface{}{}, func() { limiter.VMDestroyed("a") })
+	if throttle := throttleOf(t, callToolDoing(limiter, "create_dev_vm", map[string]interface{}{"name": "d"}, func() { limiter.VMCreated("d") })); throttle != nil {
+		t.Errorf("Expected create after destroy to run but got %+v", throttle)
+	}
+
+	// destroy_vms frees the slots of the VMs it destroyed
+	callToolDoing(limiter, "destroy_vms", map[string]interface{}{"name_pattern": "*"}, func() { limiter.VMDestroyed("c") })
+	if throttle := throttleOf(t, callToolDoing(limiter, "create_dev_vm", map[string]interface{}{"name": "e"}, func() { limiter.VMCreated("e") })); throttle != nil {
+		t.Errorf("Expected create after destroy_vms to run but got %+v", throttle)
+	}
+	if throttle := throttleOf(t, callTool(limiter, "create_dev_vm", map[string]interface{}{"name": "f"}, "created")); throttle == nil {
+		t.Errorf("Expected VM limit throttle with the VM destroy_vms kept still counted")
+	}
+}
+
+func TestRateLimiterManifestVM(t *testing.T) {
+	limiter := NewRateLimiter(Limits{MaxVMsPerSession: 1})
+
+	// VMs created outside a tool call are not counted against a session
+	limiter.VMCreated("scheduled")
+
+	// ensure_dev_vm without a name creates the VM named by the project manifest
+	callToolDoing(limiter, "ensure_dev_vm", map[string]interface{}{}, func() { limiter.VMCreated("web") })
+	if throttle := throttleOf(t, callTool(limiter, "ensure_dev_vm", map[string]interface{}{}, "created")); throttle == nil {
+		t.Fatal("Expected VM limit throttle after a manifest VM was created")
+	}
+	// The expiry reaper destroying the VM frees its slot
+	limiter.VMDestroyed("web")
+	if throttle := throttleOf(t, callTool(limiter, "create_dev_vm", map[string]interface{}{"name": "api"}, "created")); throttle != nil {
+		t.Errorf("Expected destroying the manifest VM to free its slot but got %+v", throttle)
+	}
+}
+
+func TestRateLimiterConcurrentBoots(t *testing.T) {
+	limiter := NewRateLimiter(Limits{MaxConcurrentBoots: 1})
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler := limiter.Middleware()(func(ctx context.Context, request mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		close(started)
+		<-finish
+		return mcpgo.NewToolResultText("ok"), nil
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		request := mcpgo.CallToolRequest{}
+		request.Params.Name = "set_vm_resources"
+		_, _ = handler(context.Background(), request)
+	}()
+	<-started
+
+	throttle := throttleOf(t, callTool(limiter, "ensure_dev_vm", map[string]interface{}{"name": "b"}, "started"))
+	if throttle == nil || throttle.Limit != LimitConcurrentBoots {
+		t.Fatalf("Expected concurrent boot throttle but got %+v", throttle)
+	}
+	close(finish)
+	wg.Wait()
+	if throttle := throttleOf(t, callTool(limiter, "ensure_dev_vm", map[string]interface{}{"name": "b"}, "started")); throttle != nil {
+		t.Errorf("Expected boot after the first finished to run but got %+v", throttle)
+	}
+}
+
+func TestLimitsFromEnv(t *testing.T) {
+	t.Setenv("MCP_MAX_CONCURRENT_BOOTS", "0")
+	t.Setenv("MCP_MAX_VMS_PER_SESSION", "bogus")
+	t.Setenv("MCP_MAX_EXEC_PER_MINUTE", "30")
+	limits := LimitsFromEnv()
+	expected := Limits{MaxConcurrentBoots: 0, MaxVMsPerSession: DefaultMaxVMsPerSession, MaxExecPerMinute: 30}
+	if limits != expected {
+		t.Errorf("Expected %+v but got %+v", expected, limits)
+	}
+}